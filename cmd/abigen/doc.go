@@ -0,0 +1,25 @@
+/*
+
+Command abigen generates Go bindings for a contract from its
+interface file (see package i10r.io/protocol/contracts/abi).
+
+Usage:
+
+	abigen [-pkg name] [-o file] [-json] file.abi
+
+By default, abigen writes Go source to file_abi.go, next to the
+interface file, in the package named by -pkg, which defaults to the
+name of the file's directory. Flag -o names another output file, or
+- for stdout.
+
+Flag -json instead writes the contract's entry for a contracts file
+(see package i10r.io/protocol/contracts), which the command-line
+tools read from the file named by the environment variable
+TXVMCONTRACTS. The interface must give the contract's seed.
+
+Example:
+
+	//go:generate abigen escrow.abi
+
+*/
+package main
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"i10r.io/protocol/contracts"
+	"i10r.io/protocol/contracts/abi"
+)
+
+func main() {
+	pkg := flag.String("pkg", "", "package name")
+	out := flag.String("o", "", "output file")
+	doJSON := flag.Bool("json", false, "write a contracts file entry")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: abigen [-pkg name] [-o file] [-json] file.abi")
+		os.Exit(2)
+	}
+	name := flag.Arg(0)
+	f, err := os.Open(name)
+	must(err)
+	iface, err := abi.Parse(f, filepath.Base(name))
+	must(err)
+	f.Close()
+
+	var src []byte
+	if *doJSON {
+		if iface.Seed.IsZero() {
+			fmt.Fprintf(os.Stderr, "abigen: %s gives no seed\n", name)
+			os.Exit(1)
+		}
+		src, err = json.MarshalIndent([]*contracts.Contract{iface.Contract()}, "", "  ")
+		must(err)
+		src = append(src, '\n')
+		if *out == "" {
+			*out = "-"
+		}
+	} else {
+		if *pkg == "" {
+			abs, err := filepath.Abs(name)
+			must(err)
+			*pkg = filepath.Base(filepath.Dir(abs))
+		}
+		src, err = abi.Generate(iface, *pkg, filepath.Base(name))
+		must(err)
+		if *out == "" {
+			*out = strings.TrimSuffix(name, filepath.Ext(name)) + "_abi.go"
+		}
+	}
+	if *out == "-" {
+		_, err = os.Stdout.Write(src)
+	} else {
+		err = ioutil.WriteFile(*out, src, 0644)
+	}
+	must(err)
+}
+
+func must(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "abigen:", err)
+		os.Exit(1)
+	}
+}
@@ -6,12 +6,20 @@ import (
 	"io/ioutil"
 	"os"
 
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/contracts"
+	_ "i10r.io/protocol/txbuilder/standard" // registers the standard contracts
+	"i10r.io/protocol/txvm"
 	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/op"
 )
 
 func main() {
 	doDisasm := flag.Bool("d", false, "disassemble")
 	flag.Parse()
+	if err := contracts.LoadEnv(); err != nil {
+		panic(err)
+	}
 	if *doDisasm {
 		disassemble()
 	} else {
@@ -41,4 +49,29 @@ func disassemble() {
 		panic(err)
 	}
 	fmt.Println(dis)
+	printContracts(b)
+}
+
+// printContracts prints a comment naming each known contract whose
+// program appears in prog, before a contract instruction.
+func printContracts(prog []byte) {
+	var prev []byte
+	for len(prog) > 0 {
+		opcode, data, n, err := op.DecodeInst(prog)
+		if err != nil {
+			return
+		}
+		prog = prog[n:]
+		if opcode == op.Contract && prev != nil {
+			seed := txvm.ContractSeed(prev)
+			if c, ok := contracts.Lookup(bc.NewHash(seed)); ok {
+				fmt.Printf("# contract %x: %s\n", seed, c.Name)
+			}
+			printContracts(prev)
+		}
+		prev = nil
+		if op.IsPushdataOp(opcode) {
+			prev = data
+		}
+	}
 }
@@ -9,7 +9,11 @@ Usage:
 By default, asm assembles a binary code from a TxVM assembly language.
 
 Flag -d inverts the behavior: the binary code is read from stdin,
-and the TxVM assembly is printed to stdout.
+and the TxVM assembly is printed to stdout. It is followed by a
+comment line naming each known contract whose program the code
+creates with the contract instruction: the standard contracts, and
+any in the JSON file named by the environment variable TXVMCONTRACTS
+(see package i10r.io/protocol/contracts).
 
 Examples:
 
@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
@@ -15,6 +16,8 @@ func main() {
 	var (
 		blockFile = flag.String("block", "", "filename containing block to apply")
 		stateFile = flag.String("state", "", "filename containing previous state")
+		toFile    = flag.String("to", "", "with -diff, filename containing the new state")
+		diff      = flag.Bool("diff", false, "report the changes instead of writing the new state")
 	)
 
 	flag.Parse()
@@ -22,6 +25,9 @@ func main() {
 	if *blockFile == "-" && *stateFile == "-" {
 		log.Fatal("only one of -block and -state may be -")
 	}
+	if *toFile != "" && (!*diff || *blockFile != "") {
+		log.Fatal("-to requires -diff and no -block")
+	}
 
 	blockInp := getReader(*blockFile)
 	if blockInp != nil {
@@ -38,12 +44,9 @@ func main() {
 	if stateInp == nil {
 		snapshot = state.Empty()
 	} else {
-		b, err := ioutil.ReadAll(stateInp)
-		must(err)
-		snapshot = new(state.Snapshot)
-		err = snapshot.FromBytes(b)
-		must(err)
+		snapshot = readState(stateInp)
 	}
+	prev := state.Copy(snapshot)
 
 	if blockInp != nil {
 		b, err := ioutil.ReadAll(blockInp)
@@ -57,11 +60,29 @@ func main() {
 		}
 	}
 
+	if *diff {
+		if toInp := getReader(*toFile); toInp != nil {
+			snapshot = readState(toInp)
+			toInp.Close()
+		}
+		fmt.Print(state.Diff(prev, snapshot))
+		return
+	}
+
 	b, err := snapshot.Bytes()
 	must(err)
 	os.Stdout.Write(b)
 }
 
+func readState(r io.Reader) *state.Snapshot {
+	b, err := ioutil.ReadAll(r)
+	must(err)
+	snapshot := new(state.Snapshot)
+	err = snapshot.FromBytes(b)
+	must(err)
+	return snapshot
+}
+
 func getReader(arg string) io.ReadCloser {
 	switch arg {
 	case "":
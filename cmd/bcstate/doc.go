@@ -6,6 +6,7 @@ a block.
 Usage:
 
 	bcstate [-block BLOCKFILE] [-state STATEFILE] >NEWSTATE
+	bcstate -diff [-block BLOCKFILE | -to NEWSTATEFILE] [-state STATEFILE]
 
 BLOCKFILE and STATEFILE are the names of files containing a block and
 a previous state, respectively. Either (but not both) may be - to read
@@ -17,5 +18,11 @@ to standard output. If STATEFILE is not specified then a new blank
 state snapshot is used. If BLOCKFILE is not specified then the input
 state is simply copied to standard output.
 
+With -diff, bcstate instead reports what changed: the contracts and
+nonces added and removed, and the old and new roots of their trees.
+The new state is the result of applying BLOCKFILE or, with -to, the
+state in NEWSTATEFILE, such as one imported from a checkpoint. See
+state.Diff.
+
 */
 package main
@@ -0,0 +1,33 @@
+/*
+
+Command benchgate compares two sets of Go benchmark results, as
+benchstat does, and fails if the second shows a significant
+regression.
+
+Usage:
+
+	benchgate [-threshold PCT] [-alpha P] OLD NEW
+
+OLD and NEW hold the output of go test -bench, each benchmark run
+several times with -count; 10 runs each give the test power enough
+to find a change of a few percent. For each benchmark and unit in
+both, benchgate prints the median of each, the change between them,
+and the p-value of the Mann-Whitney U test that they differ, and
+flags the change as a regression if it is worse by more than
+threshold percent (default 5) with a p-value under alpha (default
+0.05). Higher is worse for every unit but those, such as MB/s,
+ending in "/s". It exits with status 1 if it flags any.
+
+To check a change to the crypto and VM layers in review:
+
+	git checkout main
+	go test -run '^$' -bench . -count 10 ./crypto/... ./protocol/txvm/... ./protocol/patricia ./protocol >old.txt
+	git checkout feature
+	go test -run '^$' -bench . -count 10 ./crypto/... ./protocol/txvm/... ./protocol/patricia ./protocol >new.txt
+	benchgate old.txt new.txt
+
+Run both on the same quiet machine; benchmarks on different hardware
+do not compare.
+
+*/
+package main
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// key identifies a benchmark measurement.
+type key struct {
+	pkg, name, unit string
+}
+
+func main() {
+	threshold := flag.Float64("threshold", 5, "percent change flagged as a regression")
+	alpha := flag.Float64("alpha", 0.05, "significance level")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: benchgate [-threshold PCT] [-alpha P] OLD NEW")
+		os.Exit(2)
+	}
+	old, order, err := parseFile(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	new, _, err := parseFile(flag.Arg(1))
+	if err != nil {
+		fatal(err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "name\tunit\told\tnew\tdelta\tp\t")
+	regressions := 0
+	for _, k := range order {
+		o, n := old[k], new[k]
+		if len(n) == 0 {
+			continue
+		}
+		om, nm := median(o), median(n)
+		delta := 100 * (nm - om) / om
+		p := mannWhitney(o, n)
+		worse := delta
+		if strings.HasSuffix(k.unit, "/s") {
+			worse = -delta
+		}
+		flag := ""
+		if worse > *threshold && p < *alpha {
+			flag = "REGRESSION"
+			regressions++
+		}
+		d := fmt.Sprintf("%+.2f%%", delta)
+		if p >= *alpha {
+			d = "~"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name(k), k.unit, summary(o), summary(n), d, fmt.Sprintf("p=%.3f n=%d+%d", p, len(o), len(n)), flag)
+	}
+	tw.Flush()
+	if regressions > 0 {
+		fmt.Printf("%d regressions\n", regressions)
+		os.Exit(1)
+	}
+}
+
+func name(k key) string {
+	n := strings.TrimPrefix(k.name, "Benchmark")
+	if k.pkg == "" {
+		return n
+	}
+	return k.pkg + "." + n
+}
+
+// summary formats a median and the largest deviation from it, as a
+// percentage.
+func summary(xs []float64) string {
+	m := median(xs)
+	if m == 0 {
+		return "0"
+	}
+	var dev float64
+	for _, x := range xs {
+		dev = math.Max(dev, math.Abs(x-m))
+	}
+	return fmt.Sprintf("%.4g ±%.0f%%", m, 100*dev/m)
+}
+
+func parseFile(filename string) (map[key][]float64, []key, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	return parse(f)
+}
+
+// parse reads the output of go test -bench, returning the samples of
+// each benchmark and unit, and their keys in the order first seen.
+func parse(r io.Reader) (map[key][]float64, []key, error) {
+	samples := make(map[key][]float64)
+	var (
+		order []key
+		pkg   string
+	)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "pkg: ") {
+			pkg = strings.TrimSpace(strings.TrimPrefix(line, "pkg: "))
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue // not a result line
+		}
+		name := fields[0]
+		if i := strings.LastIndex(name, "-"); i > 0 {
+			if _, err := strconv.Atoi(name[i+1:]); err == nil {
+				name = name[:i] // strip the GOMAXPROCS suffix
+			}
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				break
+			}
+			k := key{pkg, name, fields[i+1]}
+			if _, ok := samples[k]; !ok {
+				order = append(order, k)
+			}
+			samples[k] = append(samples[k], v)
+		}
+	}
+	return samples, order, s.Err()
+}
+
+func median(xs []float64) float64 {
+	s := append([]float64(nil), xs...)
+	sort.Float64s(s)
+	n := len(s)
+	if n%2 == 1 {
+		return s[n/2]
+	}
+	return (s[n/2-1] + s[n/2]) / 2
+}
+
+// mannWhitney returns the two-sided p-value of the Mann-Whitney U
+// test that xs and ys come from the same distribution: exact for
+// small samples without ties, and otherwise from the normal
+// approximation with a correction for ties.
+func mannWhitney(xs, ys []float64) float64 {
+	n1, n2 := len(xs), len(ys)
+	type obs struct {
+		v float64
+		x bool
+	}
+	all := make([]obs, 0, n1+n2)
+	for _, v := range xs {
+		all = append(all, obs{v, true})
+	}
+	for _, v := range ys {
+		all = append(all, obs{v, false})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Rank, averaging over ties.
+	var (
+		rankX float64
+		ties  float64 // sum of t³-t over tied groups
+	)
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		if t := float64(j - i); t > 1 {
+			ties += t*t*t - t
+		}
+		for k := i; k < j; k++ {
+			if all[k].x {
+				rankX += rank
+			}
+		}
+		i = j
+	}
+	u := rankX - float64(n1*(n1+1))/2
+	mean := float64(n1*n2) / 2
+
+	if ties == 0 && n1 <= 50 && n2 <= 50 {
+		// P(U <= u) from the exact distribution, counted by
+		// recurrence.
+		lo := math.Min(u, float64(n1*n2)-u)
+		p := 2 * uCDF(n1, n2, int(lo))
+		return math.Min(p, 1)
+	}
+	n := float64(n1 + n2)
+	sigma := math.Sqrt(float64(n1*n2) / 12 * ((n + 1) - ties/(n*(n-1))))
+	if sigma == 0 {
+		return 1
+	}
+	z := (math.Abs(u-mean) - 0.5) / sigma
+	if z < 0 {
+		z = 0
+	}
+	return math.Erfc(z / math.Sqrt2)
+}
+
+// uCDF returns P(U <= u) for samples of sizes n1 and n2 with no
+// ties.
+func uCDF(n1, n2, u int) float64 {
+	// counts[m][k] is the number of arrangements of m x's among the
+	// observations so far with U = k, built up one observation at a
+	// time.
+	max := n1 * n2
+	prev := make([][]float64, n1+1)
+	for m := range prev {
+		prev[m] = make([]float64, max+1)
+	}
+	prev[0][0] = 1
+	for total := 1; total <= n1+n2; total++ {
+		next := make([][]float64, n1+1)
+		for m := range next {
+			next[m] = make([]float64, max+1)
+		}
+		for m := 0; m <= n1 && m <= total; m++ {
+			ys := total - m
+			if ys > n2 {
+				continue
+			}
+			for k := 0; k <= max; k++ {
+				var c float64
+				// The last observation is a y: U is unchanged.
+				if ys > 0 {
+					c += prev[m][k]
+				}
+				// The last observation is an x, above ys y's.
+				if m > 0 && k >= ys {
+					c += prev[m-1][k-ys]
+				}
+				next[m][k] = c
+			}
+		}
+		prev = next
+	}
+	var below, all float64
+	for k, c := range prev[n1] {
+		all += c
+		if k <= u {
+			below += c
+		}
+	}
+	return below / all
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "benchgate:", err)
+	os.Exit(1)
+}
@@ -80,7 +80,7 @@ func build(args []string) {
 		rawTx := new(bc.RawTx)
 		err = proto.Unmarshal(txbits, rawTx)
 		must(err)
-		tx, err := bc.NewTx(rawTx.Program, rawTx.Version, rawTx.Runlimit)
+		tx, err := bc.NewTx(rawTx.Program, rawTx.Version, rawTx.Runlimit, bc.NetworkOption(snapshot.InitialBlockID))
 		must(err)
 		err = bb.AddTx(bc.NewCommitmentsTx(tx))
 		must(err)
@@ -291,8 +291,14 @@ func header(args []string) {
 			pubkeys []string
 		)
 
-		for _, p := range bh.NextPredicate.Pubkeys {
-			pubkeys = append(pubkeys, hex.EncodeToString(p))
+		signers, threshold, err := bh.NextPredicate.Signers()
+		must(err)
+		for _, s := range signers {
+			if bh.NextPredicate.Version == bc.WeightedPredicateVersion {
+				pubkeys = append(pubkeys, fmt.Sprintf("%x:%d", s.Pubkey, s.Weight))
+			} else {
+				pubkeys = append(pubkeys, hex.EncodeToString(s.Pubkey))
+			}
 		}
 
 		fmt.Printf("Version: %d\n", bh.Version)
@@ -309,7 +315,11 @@ func header(args []string) {
 		fmt.Printf("ContractsRoot: %x\n", bh.ContractsRoot.Bytes())
 		fmt.Printf("NoncesRoot: %x\n", bh.NoncesRoot.Bytes())
 		fmt.Printf("NextPredicate.Version: %d\n", bh.NextPredicate.Version)
-		fmt.Printf("NextPredicate.Quorum: %d\n", bh.NextPredicate.Quorum)
+		if bh.NextPredicate.Version == bc.WeightedPredicateVersion {
+			fmt.Printf("NextPredicate.Threshold: %d\n", threshold)
+		} else {
+			fmt.Printf("NextPredicate.Quorum: %d\n", bh.NextPredicate.Quorum)
+		}
 		fmt.Printf("NextPredicate.Pubkeys: %s\n", strings.Join(pubkeys, " "))
 		fmt.Printf("Transactions: %d\n", len(rb.Transactions))
 		return
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"i10r.io/protocol"
+	"i10r.io/protocol/archive"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/costsim"
+	"i10r.io/protocol/replay"
+	"i10r.io/protocol/state"
+)
+
+var modes = map[string]func([]string){
+	"export":  export,
+	"import":  importArchive,
+	"replay":  replayArchive,
+	"reprice": repriceArchive,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	fn, ok := modes[os.Args[1]]
+	if !ok {
+		usage()
+	}
+
+	fn(os.Args[2:])
+}
+
+func export(args []string) {
+	fs := flag.NewFlagSet("export", flag.PanicOnError)
+	err := fs.Parse(args)
+	must(err)
+
+	w := archive.NewWriter(os.Stdout)
+	for _, arg := range fs.Args() {
+		bits, err := ioutil.ReadFile(arg)
+		must(err)
+		b := new(bc.Block)
+		err = b.FromBytes(bits)
+		must(err)
+		err = w.WriteBlock(b)
+		must(err)
+	}
+	must(w.Close())
+}
+
+func importArchive(args []string) {
+	fs := flag.NewFlagSet("import", flag.PanicOnError)
+
+	var (
+		blockDir = fs.String("blockdir", "", "directory for writing block files")
+		snapOut  = fs.String("snapout", "", "output file for snapshot")
+	)
+
+	err := fs.Parse(args)
+	must(err)
+
+	snapshot := state.Empty()
+	_, err = archive.Import(context.Background(), os.Stdin, func(_ context.Context, b *bc.Block) error {
+		err := snapshot.ApplyBlock(b.UnsignedBlock)
+		if err != nil {
+			return err
+		}
+		if b.ContractsRoot.Byte32() != snapshot.ContractsTree.RootHash() {
+			return protocol.ErrBadContractsRoot
+		}
+		if b.NoncesRoot.Byte32() != snapshot.NonceTree.RootHash() {
+			return protocol.ErrBadNoncesRoot
+		}
+		if *blockDir != "" {
+			bits, err := b.Bytes()
+			if err != nil {
+				return err
+			}
+			err = ioutil.WriteFile(filepath.Join(*blockDir, fmt.Sprintf("%d.block", b.Height)), bits, 0644)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *snapOut != "" {
+		bits, err := snapshot.Bytes()
+		must(err)
+		err = ioutil.WriteFile(*snapOut, bits, 0644)
+		must(err)
+	}
+}
+
+func replayArchive(args []string) {
+	fs := flag.NewFlagSet("replay", flag.PanicOnError)
+	noSig := fs.Bool("nosig", false, "skip signature validation")
+	err := fs.Parse(args)
+	must(err)
+
+	r := replay.New()
+	r.SkipSignatures = *noSig
+	n, err := archive.Import(context.Background(), os.Stdin, func(_ context.Context, b *bc.Block) error {
+		return r.Apply(b)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("replayed %d blocks to height %d\n", n, r.Snapshot().Height())
+}
+
+func repriceArchive(args []string) {
+	fs := flag.NewFlagSet("reprice", flag.PanicOnError)
+	var (
+		tableFile = fs.String("table", "", "JSON file of the new costs")
+		asJSON    = fs.Bool("json", false, "write the report as JSON")
+	)
+	err := fs.Parse(args)
+	must(err)
+	if *tableFile == "" {
+		usage()
+	}
+
+	bits, err := ioutil.ReadFile(*tableFile)
+	must(err)
+	var table costsim.Table
+	err = json.Unmarshal(bits, &table)
+	must(err)
+
+	r, err := costsim.Archive(context.Background(), os.Stdin, table)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if r == nil {
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "report of the first %d blocks:\n", r.Blocks)
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		must(enc.Encode(r))
+	} else {
+		must(r.WriteText(os.Stdout))
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	fmt.Fprintln(os.Stderr, "  chain export BLOCKFILE BLOCKFILE ... >ARCHIVE")
+	fmt.Fprintln(os.Stderr, "  chain import [-blockdir DIR] [-snapout FILE] <ARCHIVE")
+	fmt.Fprintln(os.Stderr, "  chain replay [-nosig] <ARCHIVE")
+	fmt.Fprintln(os.Stderr, "  chain reprice -table FILE [-json] <ARCHIVE")
+	os.Exit(1)
+}
@@ -0,0 +1,45 @@
+/*
+
+Command chain exports and imports raw blockchain archives.
+
+Usage:
+
+	chain export BLOCKFILE BLOCKFILE ... >ARCHIVE
+	chain import [-blockdir DIR] [-snapout FILE] <ARCHIVE
+	chain replay [-nosig] <ARCHIVE
+	chain reprice -table FILE [-json] <ARCHIVE
+
+The export subcommand reads the named block files (as produced by the
+block command, qv), which must be contiguous and in height order, and
+writes them to standard output as an archive (see package
+i10r.io/protocol/archive).
+
+The import subcommand reads an archive from standard input, checking
+the integrity of each record and the linkage between blocks. Each
+block is applied to a blockchain state beginning with an empty
+snapshot, and its contracts and nonces roots are checked against the
+resulting state. With -blockdir, each block is written to a file named
+HEIGHT.block in DIR. With -snapout, the final state snapshot is written
+to FILE.
+
+The replay subcommand reads an archive from standard input and
+re-validates it from the initial block (see package
+i10r.io/protocol/replay), checking each block against its predecessor
+and its declared transactions, contracts, and nonces roots against
+recomputed ones. It reports the first divergence, if any. With
+-nosig, block signatures are not checked.
+
+The reprice subcommand reads an archive from standard input and runs
+its transactions again under the new instruction costs in FILE (see
+package i10r.io/protocol/costsim), a JSON object mapping opcode names
+to prices, as in
+
+	{"checksig": {"scale": 2}, "pushdata": {"add": 1}}
+
+It reports how the runlimit used by the transactions, in all and per
+block, would change, the transactions that would exceed the runlimits
+they declare, and the blocks that would exceed their limits on
+runlimit. With -json, the report is written as JSON.
+
+*/
+package main
@@ -8,11 +8,17 @@ Usage:
 	ed25519 pub <privatekey >publickey
 	ed25519 sign PRIVATEKEY_HEX <message >signature
 	ed25519 verify [-s] PUBLICKEY_HEX SIG_HEX <message
+	ed25519 addr [-network NAME] [-hrp HRP] [-quorum N] PUBLICKEY_HEX...
 
 The gen subcommand generates a new, random private key.
 The pub subcommand reads a private key and produces the corresponding public key.
 The sign subcommand produces a signature from a message and private key.
 The verify subcommand verifies a signature with a message and a public key.
+The addr subcommand prints the bech32m address paying to a quorum of public keys,
+for the network with the given human-readable part; see
+package i10r.io/protocol/txbuilder/address. By default the part is that of the
+network given with -network, or named by the environment variable TXVMNETWORK
+(see package i10r.io/protocol/netparams), or else txvm.
 
 The verify subcommand prints "OK" or "BAD" to stdout unless the -s ("silent") flag is given.
 The program exits with 0 when the signature is verified, nonzero when it's not.
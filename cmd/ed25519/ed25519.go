@@ -2,11 +2,14 @@ package main
 
 import (
 	"encoding/hex"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 
 	"i10r.io/crypto/ed25519"
+	"i10r.io/protocol/netparams"
+	"i10r.io/protocol/txbuilder/address"
 )
 
 func main() {
@@ -68,6 +71,34 @@ func main() {
 			os.Exit(1)
 		}
 
+	case "addr":
+		must(netparams.LoadEnv())
+		fs := flag.NewFlagSet("addr", flag.ExitOnError)
+		network := fs.String("network", "", "network, whose human-readable part to use (default $"+netparams.EnvNetwork+")")
+		hrp := fs.String("hrp", "", "network's human-readable part (default that of the network, or "+address.Mainnet+")")
+		quorum := fs.Int("quorum", 1, "quorum")
+		fs.Parse(os.Args[2:])
+		if *hrp == "" {
+			*hrp = address.Mainnet
+			net, err := netparams.Select(*network)
+			must(err)
+			if net != nil {
+				*hrp = net.HRP
+			}
+		}
+		if fs.NArg() < 1 {
+			usage()
+		}
+		a := &address.Address{HRP: *hrp, Quorum: *quorum}
+		for _, arg := range fs.Args() {
+			pub, err := hex.DecodeString(arg)
+			must(err)
+			a.Pubkeys = append(a.Pubkeys, ed25519.PublicKey(pub))
+		}
+		s, err := a.Encode()
+		must(err)
+		fmt.Println(s)
+
 	default:
 		usage()
 	}
@@ -85,6 +116,7 @@ func usage() {
 		"pub <privatekey >publickey",
 		"sign PRIVHEX <message >signature",
 		"verify [-s] PUBHEX SIGHEX <message",
+		"addr [-network NAME] [-hrp HRP] [-quorum N] PUBHEX...",
 	}
 	fmt.Println("Usage:")
 	for _, o := range opts {
@@ -95,5 +127,6 @@ func usage() {
 	fmt.Println("SIGHEX is a hex-encoded signature.")
 	fmt.Println("The verify subcommand prints OK or BAD to stdout;")
 	fmt.Println("or, if -s (\"silent\") is given, exits with a zero or non-zero exit code.")
+	fmt.Println("The addr subcommand prints the address of a quorum of public keys.")
 	os.Exit(1)
 }
@@ -0,0 +1,16 @@
+/*
+
+Command ivyc compiles Ivy contracts (see package
+i10r.io/protocol/contracts/ivy).
+
+Usage:
+
+	ivyc [-x] file.ivy
+
+For each contract in the file, ivyc writes a comment line giving the
+contract's name and seed, then the txvm assembly of the program that
+locks a value in it, annotated with the Ivy source of each line.
+Flag -x writes the program's bytecode, in hex, instead.
+
+*/
+package main
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"i10r.io/protocol/contracts/ivy"
+)
+
+func main() {
+	doHex := flag.Bool("x", false, "write hex bytecode")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: ivyc [-x] file.ivy")
+		os.Exit(2)
+	}
+	name := flag.Arg(0)
+	src, err := ioutil.ReadFile(name)
+	must(err)
+	contracts, err := ivy.Compile(string(src))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", name, err)
+		os.Exit(1)
+	}
+	for _, c := range contracts {
+		fmt.Printf("# contract %s seed %x\n", c.Name, c.Seed.Bytes())
+		if *doHex {
+			fmt.Println(hex.EncodeToString(c.Program))
+		} else {
+			fmt.Print(c.Asm)
+		}
+	}
+}
+
+func must(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ivyc:", err)
+		os.Exit(1)
+	}
+}
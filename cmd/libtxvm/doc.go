@@ -0,0 +1,22 @@
+/*
+
+Command libtxvm builds a C shared library exposing txvm transaction
+validation, so that software not written in Go can embed validation
+identical to that of a Go node.
+
+Build it with:
+
+	go build -buildmode=c-shared -o libtxvm.so i10r.io/cmd/libtxvm
+
+It requires cgo; built without it, the command only reports that.
+
+The file txvm.h in this directory declares the library's interface.
+It is stable: functions are only ever added, and the value returned
+by txvm_abi_version is incremented when that happens.
+
+All functions are safe to call concurrently. None retain pointers
+passed to them after returning. Programs of 2^31 bytes or more are
+refused with TXVM_EARG.
+
+*/
+package main
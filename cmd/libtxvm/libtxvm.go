@@ -0,0 +1,85 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+/*
+#include <stddef.h>
+#include <stdint.h>
+
+static const char *txvm_messages[] = {
+	"ok",
+	"invalid transaction",
+	"transaction not finalized",
+	"bad argument",
+	"transaction version requires a network",
+};
+static const char *txvm_message(int code) {
+	if (code < 0 || code > 4) {
+		return "unknown error";
+	}
+	return txvm_messages[code];
+}
+*/
+import "C"
+
+import (
+	"math"
+	"unsafe"
+)
+
+func main() {}
+
+//export txvm_abi_version
+func txvm_abi_version() C.int {
+	return abiVersion
+}
+
+//export txvm_validate_tx
+func txvm_validate_tx(prog *C.uint8_t, progLen C.size_t, version, runlimit C.int64_t, txidOut *C.uint8_t) C.int {
+	return txvm_validate_tx_network(prog, progLen, version, runlimit, nil, txidOut)
+}
+
+//export txvm_tx_id
+func txvm_tx_id(prog *C.uint8_t, progLen C.size_t, version, runlimit C.int64_t, txidOut *C.uint8_t) C.int {
+	return txvm_tx_id_network(prog, progLen, version, runlimit, nil, txidOut)
+}
+
+//export txvm_validate_tx_network
+func txvm_validate_tx_network(prog *C.uint8_t, progLen C.size_t, version, runlimit C.int64_t, network, txidOut *C.uint8_t) C.int {
+	if (prog == nil && progLen > 0) || progLen > math.MaxInt32 {
+		return codeArg
+	}
+	txid, code := validateTx(C.GoBytes(unsafe.Pointer(prog), C.int(progLen)), int64(version), int64(runlimit), networkID(network), false)
+	if code == codeOK && txidOut != nil {
+		copy((*[32]byte)(unsafe.Pointer(txidOut))[:], txid[:])
+	}
+	return C.int(code)
+}
+
+//export txvm_tx_id_network
+func txvm_tx_id_network(prog *C.uint8_t, progLen C.size_t, version, runlimit C.int64_t, network, txidOut *C.uint8_t) C.int {
+	if (prog == nil && progLen > 0) || progLen > math.MaxInt32 || txidOut == nil {
+		return codeArg
+	}
+	txid, code := validateTx(C.GoBytes(unsafe.Pointer(prog), C.int(progLen)), int64(version), int64(runlimit), networkID(network), true)
+	if code == codeOK {
+		copy((*[32]byte)(unsafe.Pointer(txidOut))[:], txid[:])
+	}
+	return C.int(code)
+}
+
+// networkID copies the 32-byte network ID at p, if p is not nil.
+func networkID(p *C.uint8_t) *[32]byte {
+	if p == nil {
+		return nil
+	}
+	var id [32]byte
+	copy(id[:], (*[32]byte)(unsafe.Pointer(p))[:])
+	return &id
+}
+
+//export txvm_strerror
+func txvm_strerror(code C.int) *C.char {
+	return C.txvm_message(code)
+}
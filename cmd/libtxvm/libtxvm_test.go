@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/txvmtest"
+)
+
+func TestValidateTx(t *testing.T) {
+	prog, err := asm.Assemble(txvmtest.SimplePayment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := txvm.Validate(prog, 3, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txid, code := validateTx(prog, 3, 100000, nil, false)
+	if code != codeOK || txid != vm.TxID {
+		t.Errorf("validateTx = %x, %d; want %x, %d", txid[:], code, vm.TxID[:], codeOK)
+	}
+	txid, code = validateTx(prog, 3, 100000, nil, true)
+	if code != codeOK || txid != vm.TxID {
+		t.Errorf("validateTx(idOnly) = %x, %d; want %x, %d", txid[:], code, vm.TxID[:], codeOK)
+	}
+	if _, code = validateTx(prog, 3, 10, nil, false); code != codeInvalid {
+		t.Errorf("validateTx with low runlimit: code %d, want %d", code, codeInvalid)
+	}
+	if _, code = validateTx([]byte{0x01, 0x52}, 3, 100, nil, false); code != codeUnfinalized {
+		t.Errorf("validateTx without finalize: code %d, want %d", code, codeUnfinalized)
+	}
+}
+
+func TestValidateTxNetwork(t *testing.T) {
+	prog, err := asm.Assemble("'id' 10 nonce finalize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	network := [32]byte{7}
+	vm, err := txvm.Validate(prog, txvm.NetworkVersion, 100000, txvm.Network(network))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, code := validateTx(prog, txvm.NetworkVersion, 100000, nil, false); code != codeNetwork {
+		t.Errorf("validateTx without network: code %d, want %d", code, codeNetwork)
+	}
+	txid, code := validateTx(prog, txvm.NetworkVersion, 100000, &network, true)
+	if code != codeOK || txid != vm.TxID {
+		t.Errorf("validateTx with network = %x, %d; want %x, %d", txid[:], code, vm.TxID[:], codeOK)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Without cgo there is no library to build, but the package still
+// compiles, so that the rest of the tree can be built and
+// cross-compiled with CGO_ENABLED=0.
+func main() {
+	fmt.Fprintln(os.Stderr, "libtxvm: build with cgo enabled and -buildmode=c-shared")
+	os.Exit(2)
+}
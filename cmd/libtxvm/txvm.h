@@ -0,0 +1,72 @@
+/* txvm.h - C interface to libtxvm. See doc.go. */
+
+#ifndef TXVM_H
+#define TXVM_H
+
+#include <stddef.h>
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+/* Result codes. */
+#define TXVM_OK          0 /* success */
+#define TXVM_EINVALID    1 /* the transaction is invalid */
+#define TXVM_EUNFINALIZED 2 /* the program did not execute finalize */
+#define TXVM_EARG        3 /* bad argument (e.g. null pointer, or
+                            prog_len of 2^31 or more) */
+#define TXVM_ENETWORK    4 /* the transaction version requires a
+                            network (since ABI version 2) */
+
+/* txvm_abi_version returns the version of this interface. */
+extern int txvm_abi_version(void);
+
+/*
+ * txvm_validate_tx fully validates the transaction program of length
+ * prog_len at prog, with the given transaction version and runlimit.
+ * On TXVM_OK, the 32-byte transaction ID is written to txid_out
+ * (which may be NULL if the ID is not wanted). Transactions of
+ * version 5 or later, whose IDs commit to their blockchain, are
+ * refused with TXVM_ENETWORK; see txvm_validate_tx_network.
+ */
+extern int txvm_validate_tx(const uint8_t *prog, size_t prog_len,
+                            int64_t version, int64_t runlimit,
+                            uint8_t *txid_out);
+
+/*
+ * txvm_tx_id computes the ID of the transaction program, stopping
+ * after its finalize instruction. Signatures appearing after
+ * finalize are not checked, so this works for not-yet-signed
+ * transactions. The 32-byte ID is written to txid_out.
+ */
+extern int txvm_tx_id(const uint8_t *prog, size_t prog_len,
+                      int64_t version, int64_t runlimit,
+                      uint8_t *txid_out);
+
+/*
+ * txvm_validate_tx_network and txvm_tx_id_network are
+ * txvm_validate_tx and txvm_tx_id for the blockchain whose initial
+ * block has the 32-byte ID at network, to which the IDs of
+ * transactions of version 5 or later commit. With a NULL network they
+ * are the same as those functions. Since ABI version 2.
+ */
+extern int txvm_validate_tx_network(const uint8_t *prog, size_t prog_len,
+                                    int64_t version, int64_t runlimit,
+                                    const uint8_t *network,
+                                    uint8_t *txid_out);
+extern int txvm_tx_id_network(const uint8_t *prog, size_t prog_len,
+                              int64_t version, int64_t runlimit,
+                              const uint8_t *network,
+                              uint8_t *txid_out);
+
+/*
+ * txvm_strerror returns a static description of a result code.
+ */
+extern const char *txvm_strerror(int code);
+
+#ifdef __cplusplus
+}
+#endif
+
+#endif /* TXVM_H */
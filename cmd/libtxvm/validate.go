@@ -0,0 +1,38 @@
+package main
+
+import "i10r.io/protocol/txvm"
+
+// These must agree with txvm.h.
+const (
+	abiVersion = 2
+
+	codeOK          = 0
+	codeInvalid     = 1
+	codeUnfinalized = 2
+	codeArg         = 3
+	codeNetwork     = 4
+)
+
+// validateTx runs prog and maps the outcome to a result code. With
+// idOnly, execution stops after finalize. The ID of a transaction of
+// version txvm.NetworkVersion or later commits to network, without
+// which such a transaction is refused with codeNetwork.
+func validateTx(prog []byte, version, runlimit int64, network *[32]byte, idOnly bool) (txid [32]byte, code int) {
+	var opts []txvm.Option
+	if network != nil {
+		opts = append(opts, txvm.Network(*network))
+	} else if version >= txvm.NetworkVersion {
+		return txid, codeNetwork
+	}
+	if idOnly {
+		opts = append(opts, txvm.StopAfterFinalize)
+	}
+	vm, err := txvm.Validate(prog, version, runlimit, opts...)
+	if err != nil {
+		return txid, codeInvalid
+	}
+	if !vm.Finalized {
+		return txid, codeUnfinalized
+	}
+	return vm.TxID, codeOK
+}
@@ -0,0 +1,50 @@
+/*
+
+Command signerd holds block- and transaction-signing keys for txvmd
+nodes and wallets, so that they need not hold the keys themselves,
+and signs for them what its policies allow.
+
+Usage:
+
+	signerd -config FILE
+
+Signerd serves the protocol of package
+i10r.io/protocol/remotesigner, keeping its keys in an encrypted
+keystore (see package i10r.io/crypto/keystore) opened with the
+passphrase in the environment variable SIGNERD_PASSPHRASE. Keys are
+added to the keystore beforehand.
+
+The configuration file is JSON:
+
+	{
+	  "listen":     "127.0.0.1:2424", // address to serve on
+	  "keystore":   "keys.json",      // encrypted file holding the keys
+	  "state_file": "signed.json",    // the heights block keys have signed at
+	  "clients":    [...]             // the clients allowed to sign
+	}
+
+Each client authenticates with an Ed25519 key and may sign with the
+keys it lists:
+
+	{
+	  "pubkey":     "HEX",       // the client's public key
+	  "block_keys": ["block"],   // keys that sign its blocks
+	  "tx_keys":    ["wallet"],  // keys that sign its templates
+	  "policy":     null         // limits on the templates tx_keys sign
+	}
+
+A block key signs at most one block at each height, and never below
+the highest it has signed, which state_file remembers across
+restarts. The policy, if given, is a signpolicy.Policy (see package
+i10r.io/protocol/txbuilder/signpolicy), with pubkeys and asset IDs in
+hex:
+
+	{
+	  "own":          [{"quorum": 1, "pubkeys": ["HEX"]}],
+	  "destinations": [{"quorum": 1, "pubkeys": ["HEX"]}],
+	  "limits":       [{"asset_id": "HEX", "amount": 1000, "window": "24h"}],
+	  "cosign":       [{"asset_id": "HEX", "above": 100, "quorum": 1, "keys": ["HEX"]}]
+	}
+
+*/
+package main
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/keystore"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/log"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/remotesigner"
+	"i10r.io/protocol/txbuilder/signpolicy"
+)
+
+type config struct {
+	Listen    string         `json:"listen"`
+	Keystore  string         `json:"keystore"`
+	StateFile string         `json:"state_file"`
+	Clients   []clientConfig `json:"clients"`
+}
+
+type clientConfig struct {
+	Pubkey    chainjson.HexBytes `json:"pubkey"`
+	BlockKeys []string           `json:"block_keys"`
+	TxKeys    []string           `json:"tx_keys"`
+	Policy    *policyConfig      `json:"policy"`
+}
+
+// policyConfig is the JSON form of a signpolicy.Policy.
+type policyConfig struct {
+	Own          []destinationConfig `json:"own"`
+	Destinations []destinationConfig `json:"destinations"`
+	Limits       []struct {
+		AssetID bc.Hash            `json:"asset_id"`
+		Amount  int64              `json:"amount"`
+		Window  chainjson.Duration `json:"window"`
+	} `json:"limits"`
+	Cosign []struct {
+		AssetID bc.Hash              `json:"asset_id"`
+		Above   int64                `json:"above"`
+		Quorum  int                  `json:"quorum"`
+		Keys    []chainjson.HexBytes `json:"keys"`
+	} `json:"cosign"`
+}
+
+type destinationConfig struct {
+	Quorum  int                  `json:"quorum"`
+	Pubkeys []chainjson.HexBytes `json:"pubkeys"`
+}
+
+func (pc *policyConfig) policy() *signpolicy.Policy {
+	p := &signpolicy.Policy{
+		Own:          destinations(pc.Own),
+		Destinations: destinations(pc.Destinations),
+	}
+	for _, l := range pc.Limits {
+		p.Limits = append(p.Limits, signpolicy.Limit{AssetID: l.AssetID, Amount: l.Amount, Window: l.Window.Duration})
+	}
+	for _, c := range pc.Cosign {
+		p.Cosign = append(p.Cosign, signpolicy.Cosign{AssetID: c.AssetID, Above: c.Above, Quorum: c.Quorum, Keys: pubkeys(c.Keys)})
+	}
+	return p
+}
+
+func destinations(dcs []destinationConfig) []signpolicy.Destination {
+	var ds []signpolicy.Destination
+	for _, dc := range dcs {
+		ds = append(ds, signpolicy.Destination{Quorum: dc.Quorum, Pubkeys: pubkeys(dc.Pubkeys)})
+	}
+	return ds
+}
+
+func pubkeys(hs []chainjson.HexBytes) []ed25519.PublicKey {
+	var keys []ed25519.PublicKey
+	for _, h := range hs {
+		keys = append(keys, ed25519.PublicKey(h))
+	}
+	return keys
+}
+
+func main() {
+	configFile := flag.String("config", "", "configuration file")
+	flag.Parse()
+
+	ctx := context.Background()
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		fatal(err)
+	}
+	passphrase := []byte(os.Getenv("SIGNERD_PASSPHRASE"))
+	if len(passphrase) == 0 {
+		fatal(errors.New("keystore requires SIGNERD_PASSPHRASE"))
+	}
+	ks, err := keystore.Open(cfg.Keystore, passphrase)
+	if err != nil {
+		fatal(err)
+	}
+
+	var policies []*remotesigner.Policy
+	for _, cc := range cfg.Clients {
+		p := &remotesigner.Policy{
+			Pubkey:    ed25519.PublicKey(cc.Pubkey),
+			BlockKeys: cc.BlockKeys,
+			TxKeys:    cc.TxKeys,
+		}
+		if cc.Policy != nil {
+			p.Engine = signpolicy.NewEngine(cc.Policy.policy())
+		}
+		policies = append(policies, p)
+	}
+	s := remotesigner.NewServer(ks, policies...)
+	s.StateFile = cfg.StateFile
+
+	log.Printkv(ctx, "event", "listening", "addr", cfg.Listen, "clients", len(policies))
+	err = http.ListenAndServe(cfg.Listen, remotesigner.Handler(s))
+	fatal(err)
+}
+
+func loadConfig(filename string) (*config, error) {
+	if filename == "" {
+		return nil, errors.New("no configuration file")
+	}
+	bits, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &config{Listen: "127.0.0.1:2424"}
+	err = json.Unmarshal(bits, cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", filename)
+	}
+	if cfg.Keystore == "" {
+		return nil, fmt.Errorf("%s: keystore is required", filename)
+	}
+	for i, cc := range cfg.Clients {
+		if len(cc.Pubkey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%s: client %d: pubkey must be %d bytes", filename, i, ed25519.PublicKeySize)
+		}
+	}
+	return cfg, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "signerd:", err)
+	os.Exit(1)
+}
@@ -7,15 +7,19 @@ Usage:
 
 	tx SUBCOMMAND ...args...
 
-Available subcommands are: id, validate, trace, log, result, build.
+Available subcommands are: id, validate, trace, log, result, diff,
+malleability, contracts, build.
 
-All subcommands except build expect a transaction program on standard
+All subcommands except contracts and build expect a transaction program on standard
 input, assigning it a default version of 3 and a default runlimit of
 2^63-1. The -runlimit and -version flags can override those default
 values. These subcommands also accept a -witness flag tells tx to
 expect a transaction witness tuple on standard input instead (such as
 can be produced with the "block tx -raw" command, qv), which dictates
-the version and runlimit.
+the version and runlimit. The ID of a transaction of version 5 or
+later commits to its blockchain, which must be given, as the
+hex-encoded ID of its initial block, with the -network flag; the diff
+subcommand does not support such transactions.
 
 The id subcommand causes tx to compute the transaction's ID and send
 it to standard output. Errors in the transaction beyond the "finalize"
@@ -23,6 +27,8 @@ instruction are not detected.
 
 The validate subcommand causes tx to validate the transaction. Exit
 value 0 means the transaction is valid, non-zero means it is not.
+An invalid transaction's error is sent to standard error, along with
+the contract that failed and the log entries made before the failure.
 
 The trace subcommand causes an execution trace of the tx to be sent to
 standard output.
@@ -33,7 +39,31 @@ the transaction beyond the "finalize" instruction are not detected.
 
 The result subcommand parses the transaction log for information
 produced by "standard" issuance, retirement, input, and output
-contracts and prints the information in human-readable form.
+contracts and prints the information in human-readable form. It
+also prints the runlimit attributed to each input and output (see
+txvm.Validate) and the runlimit the transaction used.
+
+The diff subcommand runs the transaction on both this build's VM and
+a reference VM loaded from a Go plugin, and reports any differences
+in the transaction ID, log, runlimit consumed, or success. It is used
+like this:
+
+	tx diff PLUGIN [-witness] [-runlimit N] [-version V]
+
+See package i10r.io/protocol/txvm/txvmdiff for what the plugin must
+export. Exit value 0 means the two VMs agree.
+
+The contracts subcommand lists the known contracts: the standard
+ones, and any in the JSON file named by the environment variable
+TXVMCONTRACTS (see package i10r.io/protocol/contracts). Other
+subcommands show known contracts by name rather than by seed:
+validate, trace, log (in a comment after each log entry), and result
+(with a label such as "2-of-3" for a contract's stack).
+
+The malleability subcommand reports the data in the transaction
+program that could be changed without changing the transaction ID or
+making the transaction invalid, one finding per line. See package
+i10r.io/protocol/txvm/txvmwitness. Exit value 0 means there are none.
 
 The build subcommand creates a transaction. It is used like this:
 
@@ -71,6 +101,7 @@ Each directive has its own set of arguments:
 	output:
 		-quorum N        integer quorum
 		-pub 'P1 P2 ...' hex-encoded, space-separated public keys
+		-addr ADDRESS    bech32m address, in place of -quorum and -pub
 		-amount N        integer amount to issue
 		-assetid HEX     hex-encoded asset ID
 		-refdata D       hex- or JSON-encoded reference data
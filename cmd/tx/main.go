@@ -16,10 +16,14 @@ import (
 	"i10r.io/crypto/ed25519"
 	chainjson "i10r.io/encoding/json"
 	"i10r.io/protocol/bc"
+	"i10r.io/protocol/contracts"
 	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/address"
 	"i10r.io/protocol/txbuilder/txresult"
 	"i10r.io/protocol/txvm"
 	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/txvmdiff"
+	"i10r.io/protocol/txvm/txvmwitness"
 )
 
 var args []string
@@ -30,11 +34,12 @@ func main() {
 	}
 	subcommand := os.Args[1]
 	args = os.Args[2:]
+	must(contracts.LoadEnv())
 
 	switch subcommand {
 	case "id":
-		prog, version, runlimit := getWitness()
-		vm, err := txvm.Validate(prog, version, runlimit, txvm.StopAfterFinalize)
+		prog, version, runlimit, opts := getWitness()
+		vm, err := txvm.Validate(prog, version, runlimit, append(opts, txvm.StopAfterFinalize)...)
 		must(err)
 		if !vm.Finalized {
 			panic(txvm.ErrUnfinalized)
@@ -42,31 +47,45 @@ func main() {
 		os.Stdout.Write(vm.TxID[:])
 
 	case "validate":
-		prog, version, runlimit := getWitness()
-		_, err := txvm.Validate(prog, version, runlimit)
+		prog, version, runlimit, opts := getWitness()
+		var rec txvm.Recording
+		_, err := txvm.Validate(prog, version, runlimit, append(opts, txvm.Record(&rec))...)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
+			if f := rec.Fault; f != nil {
+				fmt.Fprintf(os.Stderr, "in contract %s\n", contracts.Name(f.Seed))
+			}
+			for _, tuple := range rec.Log {
+				dis, err := asm.Disassemble(txvm.Encode(tuple))
+				must(err)
+				fmt.Fprintf(os.Stderr, "log: %s\n", dis)
+			}
 			os.Exit(1)
 		}
 
 	case "trace":
-		prog, version, runlimit := getWitness()
-		txvm.Validate(prog, version, runlimit, txvm.Trace(os.Stdout))
+		prog, version, runlimit, opts := getWitness()
+		txvm.Validate(prog, version, runlimit, append(opts, txvm.TraceNames(os.Stdout, contracts.Name))...)
 
 	case "log":
-		prog, version, runlimit := getWitness()
-		_, err := txvm.Validate(prog, version, runlimit, txvm.StopAfterFinalize, txvm.OnFinalize(func(vm *txvm.VM) {
+		prog, version, runlimit, opts := getWitness()
+		_, err := txvm.Validate(prog, version, runlimit, append(opts, txvm.StopAfterFinalize, txvm.OnFinalize(func(vm *txvm.VM) {
 			for _, tuple := range vm.Log {
 				dis, err := asm.Disassemble(txvm.Encode(tuple))
 				must(err)
+				if seed, ok := logSeed(tuple); ok {
+					if c, ok := contracts.Lookup(seed); ok {
+						dis += " # " + c.Name
+					}
+				}
 				fmt.Println(dis)
 			}
-		}))
+		}))...)
 		must(err)
 
 	case "result":
-		prog, version, runlimit := getWitness()
-		tx, err := bc.NewTx(prog, version, runlimit)
+		prog, version, runlimit, opts := getWitness()
+		tx, err := bc.NewTx(prog, version, runlimit, opts...)
 		must(err)
 		result := txresult.New(tx)
 		for i, iss := range tx.Issuances {
@@ -95,7 +114,11 @@ func main() {
 			if i == 0 {
 				fmt.Println("Inputs:")
 			}
-			fmt.Printf("  contractID %x seed %x program [%x]", inp.ID.Bytes(), inp.Seed.Bytes(), inp.Program)
+			fmt.Printf("  contractID %x seed %x", inp.ID.Bytes(), inp.Seed.Bytes())
+			if _, ok := contracts.Lookup(inp.Seed); ok {
+				fmt.Printf(" contract %q", contracts.Label(inp.Seed.Bytes(), inp.Stack))
+			}
+			fmt.Printf(" program [%x] runlimit %d", inp.Program, tx.EntryRunlimit[inp.LogPos])
 			if meta := result.Inputs[i]; meta != nil {
 				fmt.Printf(" refdata [%x]", meta.RefData)
 				if value := meta.Value; value != nil {
@@ -108,7 +131,11 @@ func main() {
 			if i == 0 {
 				fmt.Println("Outputs:")
 			}
-			fmt.Printf("  contractID %x seed %x program [%x]", out.ID.Bytes(), out.Seed.Bytes(), out.Program)
+			fmt.Printf("  contractID %x seed %x", out.ID.Bytes(), out.Seed.Bytes())
+			if _, ok := contracts.Lookup(out.Seed); ok {
+				fmt.Printf(" contract %q", contracts.Label(out.Seed.Bytes(), out.Stack))
+			}
+			fmt.Printf(" program [%x] runlimit %d", out.Program, tx.EntryRunlimit[out.LogPos])
 			if meta := result.Outputs[i]; meta != nil {
 				var pkstrs []string
 				for _, p := range meta.Pubkeys {
@@ -121,6 +148,46 @@ func main() {
 			}
 			fmt.Println()
 		}
+		fmt.Printf("Runlimit: used %d of %d, refund %d\n", tx.RunlimitUsed, tx.Runlimit, tx.Refund())
+
+	case "diff":
+		if len(args) < 1 {
+			usage()
+		}
+		ref, err := txvmdiff.LoadPlugin(args[0])
+		must(err)
+		args = args[1:]
+		prog, version, runlimit, _ := getWitness()
+		if version >= txvm.NetworkVersion {
+			// A txvmdiff.Engine takes no network to compute the
+			// transaction ID with.
+			must(fmt.Errorf("diff: transactions of version %d are not supported", version))
+		}
+		_, _, diffs := txvmdiff.Compare(txvmdiff.Current, ref, prog, version, runlimit, true)
+		for _, d := range diffs {
+			fmt.Println(d)
+		}
+		if len(diffs) > 0 {
+			os.Exit(1)
+		}
+
+	case "contracts":
+		for _, c := range contracts.All() {
+			fmt.Printf("%x %s\n", c.Seed.Bytes(), c.Name)
+			printArgs("  args:", c.Args)
+			printArgs("  output stack:", c.Stack)
+		}
+
+	case "malleability":
+		prog, version, runlimit, opts := getWitness()
+		findings, err := txvmwitness.Analyze(prog, version, runlimit, opts...)
+		must(err)
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		if len(findings) > 0 {
+			os.Exit(1)
+		}
 
 	case "build":
 		var (
@@ -289,11 +356,13 @@ func main() {
 				var (
 					quorum     int
 					pubStrs    string
+					addrStr    string
 					assetIDStr string
 					tagsStr    string
 				)
 				fs.IntVar(&quorum, "quorum", 1, "quorum")
 				fs.StringVar(&pubStrs, "pub", "", "public keys (as hex, space-separated)")
+				fs.StringVar(&addrStr, "addr", "", "address, in place of -quorum and -pub")
 				fs.StringVar(&assetIDStr, "assetid", "", "asset ID (as hex)")
 				fs.StringVar(&tagsStr, "tags", "", "tags (as hex or JSON object)")
 
@@ -322,6 +391,11 @@ func main() {
 					must(err)
 					pubs = append(pubs, ed25519.PublicKey(pub))
 				}
+				if addrStr != "" {
+					addr, err := address.Parse(addrStr)
+					must(err)
+					quorum, pubs = addr.Quorum, addr.Pubkeys
+				}
 				err = assetID.UnmarshalText([]byte(assetIDStr))
 				must(err)
 				if len(tagsStr) > 0 {
@@ -382,11 +456,17 @@ func main() {
 	}
 }
 
-func getWitness() (prog []byte, version, runlimit int64) {
+// getWitness reads the transaction on stdin and parses the flags
+// describing it. The options it returns set the network given with
+// -network, without which transactions of version
+// txvm.NetworkVersion or later are refused, since their IDs commit
+// to it.
+func getWitness() (prog []byte, version, runlimit int64, opts []txvm.Option) {
 	var fs flag.FlagSet
 	witness := fs.Bool("witness", false, "expect a witness tuple on stdin")
 	fs.Int64Var(&runlimit, "runlimit", math.MaxInt64, "runlimit")
 	fs.Int64Var(&version, "version", 3, "tx version")
+	network := fs.String("network", "", "initial block ID of the blockchain, in hex")
 	err := fs.Parse(args)
 	must(err)
 	args = fs.Args()
@@ -406,7 +486,41 @@ func getWitness() (prog []byte, version, runlimit int64) {
 		prog = inp
 	}
 
-	return prog, version, runlimit
+	if *network != "" {
+		id, err := hex.DecodeString(*network)
+		must(err)
+		if len(id) != 32 {
+			must(fmt.Errorf("network: %d bytes, want 32", len(id)))
+		}
+		opts = append(opts, bc.NetworkOption(bc.HashFromBytes(id)))
+	} else if version >= txvm.NetworkVersion {
+		must(fmt.Errorf("transactions of version %d need -network", version))
+	}
+
+	return prog, version, runlimit, opts
+}
+
+// logSeed returns the contract seed in a log entry.
+func logSeed(tuple txvm.Tuple) (bc.Hash, bool) {
+	if len(tuple) < 2 {
+		return bc.Hash{}, false
+	}
+	seed, ok := tuple[1].(txvm.Bytes)
+	if !ok || len(seed) != 32 {
+		return bc.Hash{}, false
+	}
+	return bc.HashFromBytes(seed), true
+}
+
+func printArgs(heading string, args []contracts.Arg) {
+	if len(args) == 0 {
+		return
+	}
+	var strs []string
+	for _, a := range args {
+		strs = append(strs, a.Name+" "+a.Type)
+	}
+	fmt.Printf("%s [%s]\n", heading, strings.Join(strs, ", "))
 }
 
 func usage() {
@@ -414,9 +528,10 @@ func usage() {
 
 	tx SUBCOMMAND ...args...
 
-Available subcommands are: id, validate, trace, log, result, build.
+Available subcommands are: id, validate, trace, log, result, diff,
+malleability, contracts, build.
 
-All subcommands except build expect a transaction program on standard
+All subcommands except contracts and build expect a transaction program on standard
 input, assigning it a default version of 3 and a default runlimit of
 2^63-1. The -runlimit and -version flags can override those default
 values. These subcommands also accept a -witness flag tells tx to
@@ -442,6 +557,28 @@ The result subcommand parses the transaction log for information
 produced by "standard" issuance, retirement, input, and output
 contracts and prints the information in human-readable form.
 
+The diff subcommand runs the transaction on both this build's VM and
+a reference VM loaded from a Go plugin, and reports any differences
+in the transaction ID, log, runlimit consumed, or success. It is used
+like this:
+
+	tx diff PLUGIN [-witness] [-runlimit N] [-version V]
+
+See package i10r.io/protocol/txvm/txvmdiff for what the plugin must
+export. Exit value 0 means the two VMs agree.
+
+The contracts subcommand lists the known contracts: the standard
+ones, and any in the JSON file named by the environment variable
+TXVMCONTRACTS (see package i10r.io/protocol/contracts). Other
+subcommands show known contracts by name rather than by seed:
+validate, trace, log (in a comment after each log entry), and result
+(with a label such as "2-of-3" for a contract's stack).
+
+The malleability subcommand reports the data in the transaction
+program that could be changed without changing the transaction ID or
+making the transaction invalid, one finding per line. See package
+i10r.io/protocol/txvm/txvmwitness. Exit value 0 means there are none.
+
 The build subcommand creates a transaction. It is used like this:
 
 	tx build [-ttl TIME] [-tags TAGS] DIRECTIVE ...args... DIRECTIVE ...args...
@@ -478,6 +615,7 @@ Each directive has its own set of arguments:
 	output:
 		-quorum N        integer quorum
 		-pub 'P1 P2 ...' hex-encoded, space-separated public keys
+		-addr ADDRESS    bech32m address, in place of -quorum and -pub
 		-amount N        integer amount to issue
 		-assetid HEX     hex-encoded asset ID
 		-refdata D       hex- or JSON-encoded reference data
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+)
+
+var errNoBlock = errors.New("no such block")
+
+// client calls a txvmd node's HTTP API.
+type client struct {
+	url string
+}
+
+type status struct {
+	Network        string  `json:"network"`
+	Height         uint64  `json:"height"`
+	InitialBlockID bc.Hash `json:"initial_block_id"`
+	BlockVersion   uint64  `json:"block_version"`
+	Pending        int     `json:"pending"`
+}
+
+func (c *client) status(ctx context.Context) (*status, error) {
+	var st status
+	err := c.get(ctx, "/status", func(bits []byte) error {
+		return json.Unmarshal(bits, &st)
+	})
+	return &st, err
+}
+
+func (c *client) getBlock(ctx context.Context, height uint64) (*bc.Block, error) {
+	b := new(bc.Block)
+	err := c.get(ctx, fmt.Sprintf("/get-block?height=%d", height), func(bits []byte) error {
+		return b.FromBytesContext(ctx, bits)
+	})
+	return b, err
+}
+
+func (c *client) submit(ctx context.Context, tx *bc.Tx) (bc.Hash, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"version":  tx.Version,
+		"runlimit": tx.Runlimit,
+		"program":  chainjson.HexBytes(tx.Program),
+	})
+	if err != nil {
+		return bc.Hash{}, err
+	}
+	req, err := http.NewRequest("POST", c.url+"/submit", bytes.NewReader(body))
+	if err != nil {
+		return bc.Hash{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var resp struct {
+		ID bc.Hash `json:"id"`
+	}
+	err = c.do(req.WithContext(ctx), func(bits []byte) error {
+		return json.Unmarshal(bits, &resp)
+	})
+	return resp.ID, err
+}
+
+func (c *client) get(ctx context.Context, path string, f func([]byte) error) error {
+	req, err := http.NewRequest("GET", c.url+path, nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req.WithContext(ctx), f)
+}
+
+func (c *client) do(req *http.Request, f func([]byte) error) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return f(bits)
+	case http.StatusNotFound:
+		return errNoBlock
+	}
+	return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL, resp.Status, bytes.TrimSpace(bits))
+}
@@ -0,0 +1,101 @@
+/*
+
+Command txvmcli is a wallet and transaction tool for use with a
+txvmd node.
+
+Usage:
+
+	txvmcli init [-network NAME] [-node URL] [-xprv HEX] [-keystore FILE]
+	txvmcli keygen
+	txvmcli address
+	txvmcli sync [-rescan]
+	txvmcli balance [-nosync]
+	txvmcli issue -tag TAG -amount AMOUNT [-to RECIPIENT] [-refdata DATA]
+	txvmcli send -asset ASSETID -amount AMOUNT -to RECIPIENT [-refdata DATA]
+	txvmcli pay [-payee ROOTKEY] URI
+	txvmcli retire -asset ASSETID -amount AMOUNT [-refdata DATA]
+	txvmcli asset id -tag TAG
+	txvmcli asset doc -tag TAG -name NAME [-decimals N] [-url URL]
+	txvmcli asset add <DOCUMENT
+	txvmcli sign [-submit] <TEMPLATE >TEMPLATE
+	txvmcli decode [-asm] [-network ID] <RAWTX
+
+The wallet is a JSON file named by the environment variable
+TXVMCLI_WALLET, or $HOME/.txvmcli/wallet.json by default. It holds a
+chainkd root key (or, with -keystore, only its xpub), the URL of
+the node, the outputs the wallet controls, and the metadata documents of the assets it knows about.
+The init subcommand creates it; -xprv restores a wallet from an
+existing root key. With -keystore, the root key is kept not in the
+wallet but, as the key named "wallet", in the given encrypted
+keystore file (see package i10r.io/crypto/keystore), which is
+created if need be. The keystore's passphrase is read from the
+environment variable TXVMCLI_PASSPHRASE whenever the wallet signs.
+
+With -network, or the environment variable TXVMNETWORK, the wallet
+is for the named network (see package i10r.io/protocol/netparams),
+and the node URL defaults to the network's port on 127.0.0.1. The
+wallet then refuses a node that reports another network, or, if the
+network has a fixed initial block, another initial block; accepts
+only addresses with the network's prefix; and binds its transactions
+to the blockchain if the network's transactions are of version 5 or
+later. Networks besides mainnet, testnet, and devnet are loaded from
+the JSON file named by the environment variable TXVMNETWORKS.
+
+The keygen subcommand prints a fresh chainkd key pair without
+touching the wallet.
+
+The address subcommand derives the wallet's next key and prints its
+public key and, if the wallet has a network, its address. Payments to the wallet are single-key outputs locked to
+such a key.
+
+The sync subcommand fetches blocks the wallet has not yet seen from
+the node and records outputs paid to the wallet's keys and spends of
+them. With -rescan it starts again from the initial block, which
+also clears the pending mark from outputs spent by transactions that
+never made it into a block. The balance subcommand syncs (unless
+-nosync is given) and prints the wallet's holdings of each asset.
+Amounts of an asset whose metadata document the wallet has are shown
+with that asset's decimal places.
+
+The issue subcommand issues units of the asset with the given tag
+under the standard issuance contract, authorized by the wallet's
+issuer key, and pays them to RECIPIENT or to a new wallet address. The
+send subcommand pays units of an asset the wallet holds to RECIPIENT,
+and the retire subcommand retires them; both return any change to a
+new wallet address. The pay subcommand verifies the payee's
+signature on an invoice URI (see package
+i10r.io/protocol/txbuilder/invoice) and, with -payee, that the
+payee is the current key of the identity with that root key, by way
+of the key rotation certificates the invoice carries (see package
+i10r.io/protocol/identity). It prints what it is paying, and pays
+the output the invoice requests. Each prints the ID of the
+transaction it submitted.
+
+The asset id subcommand prints the ID of the asset the wallet's
+issuer key issues with the given tag. The asset doc subcommand signs
+a metadata document for that asset with the issuer key, adds it to
+the wallet, and prints it as JSON for publishing. The asset add
+subcommand verifies a metadata document read from stdin and adds it
+to the wallet.
+
+The sign subcommand reads a protobuf-encoded transaction template
+(see package i10r.io/protocol/txbuilder) from stdin, adds signatures
+for every entry the wallet has the key for, and writes the template
+back out, or with -submit builds the transaction and submits it.
+
+The decode subcommand reads a protobuf-encoded raw transaction from
+stdin and prints its ID and the issuances, inputs, outputs, and
+retirements it contains. With -asm it also prints the disassembled
+program. The ID of a network-bound transaction (version 5 or later)
+depends on the blockchain it is for, given with -network, or by
+default the initial block of the network named by TXVMNETWORK, if it
+has a fixed one.
+
+A RECIPIENT is a hex public key or an address (see package
+i10r.io/protocol/txbuilder/address).
+
+When the node makes blocks of version 5 or later, sign, and the
+subcommands that submit, bind their transactions to its blockchain.
+
+*/
+package main
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/ed25519/chainkd"
+	"i10r.io/errors"
+	"i10r.io/protocol/assets"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/netparams"
+	"i10r.io/protocol/txbuilder"
+	addr "i10r.io/protocol/txbuilder/address"
+	"i10r.io/protocol/txbuilder/invoice"
+	"i10r.io/protocol/txbuilder/txresult"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+)
+
+// issuanceVersion is the version of the standard issuance contract
+// used by the issue subcommand.
+const issuanceVersion = 2
+
+// txTTL is how long a transaction built here remains valid.
+const txTTL = 10 * time.Minute
+
+var modes = map[string]func([]string){
+	"address": address,
+	"asset":   asset,
+	"balance": balance,
+	"decode":  decode,
+	"init":    initWallet,
+	"issue":   issue,
+	"keygen":  keygen,
+	"pay":     pay,
+	"retire":  retire,
+	"send":    send,
+	"sign":    sign,
+	"sync":    syncWallet,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	must(netparams.LoadEnv())
+	fn, ok := modes[os.Args[1]]
+	if !ok {
+		usage()
+	}
+	fn(os.Args[2:])
+}
+
+func keygen(args []string) {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	must(fs.Parse(args))
+	xprv, xpub, err := chainkd.NewXKeys(nil)
+	must(err)
+	fmt.Printf("xprv %s\nxpub %s\npubkey %x\n", xprv, xpub, xpub.PublicKey())
+}
+
+func initWallet(args []string) {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	network := fs.String("network", "", "network the wallet is for (default $"+netparams.EnvNetwork+")")
+	node := fs.String("node", "", "txvmd node URL (default http://127.0.0.1:1999, or on the network's port)")
+	xprvStr := fs.String("xprv", "", "restore from this hex root key instead of generating one")
+	ksFile := fs.String("keystore", "", "keep the root key in this encrypted keystore file")
+	must(fs.Parse(args))
+
+	filename := walletFile()
+	if _, err := os.Stat(filename); err == nil {
+		must(fmt.Errorf("%s already exists", filename))
+	}
+	net, err := netparams.Select(*network)
+	must(err)
+	w := &wallet{Node: *node, net: net, filename: filename}
+	if net != nil {
+		w.Network = net.Name
+	}
+	if w.Node == "" {
+		w.Node = "http://127.0.0.1:1999"
+		if net != nil {
+			w.Node = net.NodeURL()
+		}
+	}
+	var xprv chainkd.XPrv
+	if *xprvStr != "" {
+		must(xprv.UnmarshalText([]byte(*xprvStr)))
+	} else {
+		xprv, err = chainkd.NewXPrv(nil)
+		must(err)
+	}
+	w.XPub = xprv.XPub()
+	if *ksFile != "" {
+		abs, err := filepath.Abs(*ksFile)
+		must(err)
+		ks, err := openKeystore(abs)
+		must(err)
+		_, err = ks.AddXPrv(walletKey, xprv)
+		must(err)
+		w.Keystore = abs
+	} else {
+		w.XPrv = &xprv
+	}
+	must(w.save())
+	fmt.Println(filename)
+}
+
+func address(args []string) {
+	fs := flag.NewFlagSet("address", flag.ExitOnError)
+	must(fs.Parse(args))
+	w := mustLoad()
+	pub, _ := w.newAddress()
+	must(w.save())
+	fmt.Printf("%x\n", pub)
+	if w.net != nil {
+		a := &addr.Address{HRP: w.net.HRP, Quorum: 1, Pubkeys: []ed25519.PublicKey{pub}}
+		s, err := a.Encode()
+		must(err)
+		fmt.Println(s)
+	}
+}
+
+func syncWallet(args []string) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	rescan := fs.Bool("rescan", false, "rescan from the initial block, clearing pending spends")
+	must(fs.Parse(args))
+	w := mustLoad()
+	if *rescan {
+		w.Height = 0
+		w.UTXOs = nil
+	}
+	must(w.sync(context.Background()))
+	must(w.save())
+	fmt.Printf("height %d, %d outputs\n", w.Height, len(w.UTXOs))
+}
+
+func (w *wallet) sync(ctx context.Context) error {
+	_, err := w.status(ctx)
+	if err != nil {
+		return err
+	}
+	c := &client{url: w.Node}
+	for {
+		b, err := c.getBlock(ctx, w.Height+1)
+		if err == errNoBlock {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		w.scan(b)
+	}
+}
+
+func balance(args []string) {
+	fs := flag.NewFlagSet("balance", flag.ExitOnError)
+	noSync := fs.Bool("nosync", false, "don't sync with the node first")
+	must(fs.Parse(args))
+	w := mustLoad()
+	if !*noSync {
+		must(w.sync(context.Background()))
+		must(w.save())
+	}
+	type bal struct{ avail, pending int64 }
+	bals := make(map[bc.Hash]*bal)
+	var ids []bc.Hash
+	for _, u := range w.UTXOs {
+		b := bals[u.AssetID]
+		if b == nil {
+			b = new(bal)
+			bals[u.AssetID] = b
+			ids = append(ids, u.AssetID)
+		}
+		if u.Pending {
+			b.pending += u.Amount
+		} else {
+			b.avail += u.Amount
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return string(ids[i].Bytes()) < string(ids[j].Bytes()) })
+	reg := w.registry()
+	for _, id := range ids {
+		name := fmt.Sprintf("%x", id.Bytes())
+		if doc, ok := reg.Lookup(id); ok {
+			name = fmt.Sprintf("%s (%s)", doc.Metadata.Name, name)
+		}
+		fmt.Printf("%s %s", reg.FormatAmount(id, bals[id].avail), name)
+		if bals[id].pending > 0 {
+			fmt.Printf(" [%s pending]", reg.FormatAmount(id, bals[id].pending))
+		}
+		fmt.Println()
+	}
+}
+
+func issue(args []string) {
+	fs := flag.NewFlagSet("issue", flag.ExitOnError)
+	var (
+		tag     = fs.String("tag", "", "asset tag")
+		amtStr  = fs.String("amount", "", "amount to issue")
+		to      = fs.String("to", "", "recipient pubkey (hex) or address; default is a new wallet address")
+		refdata = fs.String("refdata", "", "reference data")
+	)
+	must(fs.Parse(args))
+	ctx := context.Background()
+	w := mustLoad()
+
+	def := w.issuerDefinition([]byte(*tag))
+	assetID, err := def.ID()
+	must(err)
+	amt, err := w.registry().ParseAmount(assetID, *amtStr)
+	must(err)
+	st, err := w.status(ctx)
+	must(err)
+
+	tpl := txbuilder.NewTemplate(time.Now().Add(txTTL), nil)
+	nonce := make([]byte, 8)
+	_, err = rand.Read(nonce)
+	must(err)
+	keyIDs := [][]byte{def.Pubkeys[0]}
+	tpl.AddIssuance(issuanceVersion, st.InitialBlockID.Bytes(), def.Tag, def.Quorum, keyIDs, issuerPath, def.Pubkeys, amt, []byte(*refdata), nonce)
+	quorum, pubkeys := w.recipient(*to)
+	tpl.AddOutput(quorum, pubkeys, amt, assetID, nil, nil)
+	w.finish(ctx, tpl, nil)
+	fmt.Fprintf(os.Stderr, "asset %x\n", assetID.Bytes())
+}
+
+func send(args []string) {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	var (
+		assetStr = fs.String("asset", "", "asset ID (hex)")
+		amtStr   = fs.String("amount", "", "amount to send")
+		to       = fs.String("to", "", "recipient pubkey (hex) or address")
+		refdata  = fs.String("refdata", "", "reference data")
+	)
+	must(fs.Parse(args))
+	if *to == "" {
+		usage()
+	}
+	w := mustLoad()
+	assetID, amt := w.parseAmount(*assetStr, *amtStr)
+	tpl, spent := w.spendTemplate(assetID, amt)
+	quorum, pubkeys := w.recipient(*to)
+	tpl.AddOutput(quorum, pubkeys, amt, assetID, []byte(*refdata), nil)
+	w.finish(context.Background(), tpl, spent)
+}
+
+func pay(args []string) {
+	fs := flag.NewFlagSet("pay", flag.ExitOnError)
+	payee := fs.String("payee", "", "payee's root public key (hex)")
+	must(fs.Parse(args))
+	if fs.NArg() != 1 {
+		usage()
+	}
+	inv, err := invoice.Parse(fs.Arg(0))
+	must(err)
+	if *payee != "" {
+		root, err := hex.DecodeString(*payee)
+		must(err)
+		must(inv.VerifyPayee(root, time.Now()))
+	} else {
+		must(inv.Verify(time.Now()))
+	}
+	w := mustLoad()
+	fmt.Fprintf(os.Stderr, "paying %d of %x to %x", inv.Amount, inv.AssetID.Bytes(), inv.Payee)
+	if inv.Memo != "" {
+		fmt.Fprintf(os.Stderr, " for %q", inv.Memo)
+	}
+	fmt.Fprintln(os.Stderr)
+	tpl, spent := w.spendTemplate(inv.AssetID, inv.Amount)
+	inv.Pay(tpl)
+	w.finish(context.Background(), tpl, spent)
+}
+
+func retire(args []string) {
+	fs := flag.NewFlagSet("retire", flag.ExitOnError)
+	var (
+		assetStr = fs.String("asset", "", "asset ID (hex)")
+		amtStr   = fs.String("amount", "", "amount to retire")
+		refdata  = fs.String("refdata", "", "reference data")
+	)
+	must(fs.Parse(args))
+	w := mustLoad()
+	assetID, amt := w.parseAmount(*assetStr, *amtStr)
+	tpl, spent := w.spendTemplate(assetID, amt)
+	tpl.AddRetirement(amt, assetID, []byte(*refdata))
+	w.finish(context.Background(), tpl, spent)
+}
+
+// spendTemplate returns a template spending outputs of assetID
+// totaling at least amt, with any excess paid back to a new wallet
+// address.
+func (w *wallet) spendTemplate(assetID bc.Hash, amt int64) (*txbuilder.Template, []*utxo) {
+	inputs, total, err := w.spendable(assetID, amt)
+	must(err)
+	tpl := txbuilder.NewTemplate(time.Now().Add(txTTL), nil)
+	for _, u := range inputs {
+		path := indexPath(u.Index)
+		pub := w.pubkey(path)
+		tpl.AddInput(1, [][]byte{pub}, path, []ed25519.PublicKey{pub}, u.Amount, u.AssetID, u.Anchor, nil, u.Version)
+	}
+	if change := total - amt; change > 0 {
+		pub, _ := w.newAddress()
+		tpl.AddOutput(1, []ed25519.PublicKey{pub}, change, assetID, nil, nil)
+	}
+	return tpl, inputs
+}
+
+// status returns the node's status, checking that the node is on the
+// wallet's network, if it has one.
+func (w *wallet) status(ctx context.Context) (*status, error) {
+	st, err := (&client{url: w.Node}).status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if w.net != nil {
+		err = w.net.CheckName(st.Network)
+		if err == nil {
+			err = w.net.CheckInitialBlock(st.InitialBlockID)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "node %s", w.Node)
+		}
+	}
+	return st, nil
+}
+
+// setNetwork binds tpl to the node's blockchain if the node makes
+// blocks that require it, or the wallet's network has transactions
+// of a version that does.
+func (w *wallet) setNetwork(ctx context.Context, tpl *txbuilder.Template) {
+	st, err := w.status(ctx)
+	must(err)
+	if st.BlockVersion >= bc.NetworkVersion || (w.net != nil && w.net.TxVersion >= txvm.NetworkVersion) {
+		tpl.SetNetwork(st.InitialBlockID)
+	}
+}
+
+// finish signs tpl, submits it, and marks spent as pending.
+func (w *wallet) finish(ctx context.Context, tpl *txbuilder.Template, spent []*utxo) {
+	w.setNetwork(ctx, tpl)
+	must(tpl.Sign(ctx, w.signFunc))
+	tx, err := tpl.Tx()
+	must(err)
+	id, err := (&client{url: w.Node}).submit(ctx, tx)
+	must(err)
+	for _, u := range spent {
+		u.Pending = true
+	}
+	must(w.save())
+	fmt.Printf("%x\n", id.Bytes())
+}
+
+func (w *wallet) parseAmount(assetStr, amtStr string) (bc.Hash, int64) {
+	var assetID bc.Hash
+	must(assetID.UnmarshalText([]byte(assetStr)))
+	amt, err := w.registry().ParseAmount(assetID, amtStr)
+	must(err)
+	if amt <= 0 {
+		must(errors.New("amount must be positive"))
+	}
+	return assetID, amt
+}
+
+// recipient parses a hex pubkey or an address, which must be for
+// the wallet's network if it has one, and returns the quorum and keys
+// to pay. It returns a new wallet address if s is empty.
+func (w *wallet) recipient(s string) (int, []ed25519.PublicKey) {
+	if s == "" {
+		pub, _ := w.newAddress()
+		return 1, []ed25519.PublicKey{pub}
+	}
+	pub, err := hex.DecodeString(s)
+	if err == nil && len(pub) == ed25519.PublicKeySize {
+		return 1, []ed25519.PublicKey{pub}
+	}
+	var a *addr.Address
+	if w.net != nil {
+		a, err = w.net.ParseAddress(s)
+	} else {
+		a, err = addr.Parse(s)
+	}
+	if err != nil {
+		must(errors.Wrapf(err, "bad recipient %q", s))
+	}
+	return a.Quorum, a.Pubkeys
+}
+
+func (w *wallet) issuerDefinition(tag []byte) *assets.Definition {
+	return &assets.Definition{
+		Version: issuanceVersion,
+		Quorum:  1,
+		Pubkeys: []ed25519.PublicKey{w.pubkey(issuerPath)},
+		Tag:     tag,
+	}
+}
+
+func asset(args []string) {
+	if len(args) < 1 {
+		usage()
+	}
+	fs := flag.NewFlagSet("asset", flag.ExitOnError)
+	var (
+		tag      = fs.String("tag", "", "asset tag")
+		name     = fs.String("name", "", "asset name")
+		decimals = fs.Int("decimals", 0, "decimal places")
+		url      = fs.String("url", "", "issuer URL")
+	)
+	must(fs.Parse(args[1:]))
+	w := mustLoad()
+
+	switch args[0] {
+	case "id":
+		id, err := w.issuerDefinition([]byte(*tag)).ID()
+		must(err)
+		fmt.Printf("%x\n", id.Bytes())
+
+	case "doc":
+		doc, err := assets.NewDocument(*w.issuerDefinition([]byte(*tag)), assets.Metadata{
+			Name:      *name,
+			Decimals:  *decimals,
+			IssuerURL: *url,
+		})
+		must(err)
+		// The issuer key is a chainkd key, which assets.Document.Sign
+		// can't take, so sign directly.
+		doc.Signatures[0], err = w.sign(context.Background(), issuerPath, doc.SigningMessage())
+		must(err)
+		w.addAsset(doc)
+		bits, err := json.MarshalIndent(doc, "", "  ")
+		must(err)
+		fmt.Println(string(bits))
+
+	case "add":
+		bits, err := ioutil.ReadAll(os.Stdin)
+		must(err)
+		doc := new(assets.Document)
+		must(json.Unmarshal(bits, doc))
+		must(doc.Verify())
+		w.addAsset(doc)
+
+	default:
+		usage()
+	}
+}
+
+func (w *wallet) addAsset(doc *assets.Document) {
+	for i, d := range w.Assets {
+		if d.AssetID == doc.AssetID {
+			w.Assets[i] = doc
+			must(w.save())
+			return
+		}
+	}
+	w.Assets = append(w.Assets, doc)
+	must(w.save())
+}
+
+func sign(args []string) {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	submit := fs.Bool("submit", false, "build the transaction and submit it instead of writing the template")
+	must(fs.Parse(args))
+	ctx := context.Background()
+	w := mustLoad()
+
+	bits, err := ioutil.ReadAll(os.Stdin)
+	must(err)
+	var raw txbuilder.RawTemplate
+	must(proto.Unmarshal(bits, &raw))
+	tpl, err := txbuilder.TemplateFromRaw(&raw)
+	must(err)
+	w.setNetwork(ctx, tpl)
+	must(tpl.Sign(ctx, w.signFunc))
+	if *submit {
+		tx, err := tpl.Tx()
+		must(err)
+		id, err := (&client{url: w.Node}).submit(ctx, tx)
+		must(err)
+		fmt.Printf("%x\n", id.Bytes())
+		return
+	}
+	bits, err = proto.Marshal(tpl.Raw())
+	must(err)
+	os.Stdout.Write(bits)
+}
+
+func decode(args []string) {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	disasm := fs.Bool("asm", false, "also disassemble the program")
+	network := fs.String("network", "", "initial block ID (hex) of the blockchain a network-bound transaction is for")
+	must(fs.Parse(args))
+
+	var initialBlockID bc.Hash
+	if *network != "" {
+		must(initialBlockID.UnmarshalText([]byte(*network)))
+	} else if net, err := netparams.Select(""); err == nil && net != nil {
+		initialBlockID = net.InitialBlockID
+	}
+	bits, err := ioutil.ReadAll(os.Stdin)
+	must(err)
+	var raw bc.RawTx
+	must(proto.Unmarshal(bits, &raw))
+	tx, err := bc.NewTx(raw.Program, raw.Version, raw.Runlimit, bc.NetworkOption(initialBlockID))
+	must(err)
+	fmt.Printf("id %x\nversion %d runlimit %d\n", tx.ID.Bytes(), tx.Version, tx.Runlimit)
+	res := txresult.New(tx)
+	for _, iss := range res.Issuances {
+		fmt.Printf("issue   %d of %x\n", iss.Value.Amount, iss.Value.AssetID.Bytes())
+	}
+	for _, inp := range res.Inputs {
+		fmt.Printf("input   %x", inp.OutputID.Bytes())
+		if inp.Value != nil {
+			fmt.Printf(" %d of %x", inp.Value.Amount, inp.Value.AssetID.Bytes())
+		}
+		fmt.Println()
+	}
+	for _, out := range res.Outputs {
+		fmt.Printf("output  %x", out.OutputID.Bytes())
+		if out.Value != nil {
+			fmt.Printf(" %d of %x", out.Value.Amount, out.Value.AssetID.Bytes())
+		}
+		for _, pk := range out.Pubkeys {
+			fmt.Printf(" to %x", pk)
+		}
+		fmt.Println()
+	}
+	for _, ret := range res.Retirements {
+		fmt.Printf("retire  %d of %x\n", ret.Value.Amount, ret.Value.AssetID.Bytes())
+	}
+	if *disasm {
+		dis, err := asm.Disassemble(tx.Program)
+		must(err)
+		fmt.Println(dis)
+	}
+}
+
+func mustLoad() *wallet {
+	w, err := loadWallet()
+	must(err)
+	return w
+}
+
+func must(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "txvmcli:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `Usage:
+
+	txvmcli init [-network NAME] [-node URL] [-xprv HEX] [-keystore FILE]
+	txvmcli keygen
+	txvmcli address
+	txvmcli sync [-rescan]
+	txvmcli balance [-nosync]
+	txvmcli issue -tag TAG -amount AMOUNT [-to RECIPIENT] [-refdata DATA]
+	txvmcli send -asset ASSETID -amount AMOUNT -to RECIPIENT [-refdata DATA]
+	txvmcli pay [-payee ROOTKEY] URI
+	txvmcli retire -asset ASSETID -amount AMOUNT [-refdata DATA]
+	txvmcli asset id -tag TAG
+	txvmcli asset doc -tag TAG -name NAME [-decimals N] [-url URL]
+	txvmcli asset add <DOCUMENT
+	txvmcli sign [-submit] <TEMPLATE >TEMPLATE
+	txvmcli decode [-asm] [-network ID] <RAWTX
+
+`)
+	os.Exit(1)
+}
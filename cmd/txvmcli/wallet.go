@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/ed25519/chainkd"
+	"i10r.io/crypto/keystore"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/math/amount"
+	"i10r.io/protocol/assets"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/netparams"
+	"i10r.io/protocol/txbuilder/txresult"
+)
+
+// wallet is the contents of the wallet file.
+type wallet struct {
+	XPrv      *chainkd.XPrv      `json:"xprv,omitempty"`     // nil in a keystore wallet
+	XPub      chainkd.XPub       `json:"xpub"`               // of the root key
+	Keystore  string             `json:"keystore,omitempty"` // file holding the root key
+	Network   string             `json:"network,omitempty"`  // see package netparams
+	Node      string             `json:"node"`
+	NextIndex uint64             `json:"next_index"` // of the next address to hand out
+	Height    uint64             `json:"height"`     // of the last block scanned
+	UTXOs     []*utxo            `json:"utxos"`
+	Assets    []*assets.Document `json:"assets"`
+
+	filename string
+	signer   keystore.Signer   // see sign
+	net      *netparams.Params // named by Network, or nil
+}
+
+// walletKey is the name of the wallet's root key in its keystore.
+const walletKey = "wallet"
+
+// utxo is an unspent output controlled by a single wallet key.
+type utxo struct {
+	OutputID bc.Hash            `json:"output_id"`
+	AssetID  bc.Hash            `json:"asset_id"`
+	Amount   int64              `json:"amount"`
+	Anchor   chainjson.HexBytes `json:"anchor"`
+	Version  int                `json:"version"`
+	Index    uint64             `json:"index"`   // derivation index of its key
+	Pending  bool               `json:"pending"` // spent by a submitted transaction not yet seen in a block
+}
+
+// issuerPath is the derivation path of the wallet's issuance key.
+var issuerPath = [][]byte{[]byte("issuer")}
+
+func walletFile() string {
+	if f := os.Getenv("TXVMCLI_WALLET"); f != "" {
+		return f
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".txvmcli", "wallet.json")
+}
+
+func loadWallet() (*wallet, error) {
+	filename := walletFile()
+	bits, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "no wallet (run txvmcli init)")
+	}
+	if err != nil {
+		return nil, err
+	}
+	w := &wallet{filename: filename}
+	err = json.Unmarshal(bits, w)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", filename)
+	}
+	if w.Network != "" {
+		w.net, err = netparams.Lookup(w.Network)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s", filename)
+		}
+	}
+	if w.XPrv != nil {
+		w.XPub = w.XPrv.XPub()
+		w.signer = &keystore.Plain{XPrvs: map[string]chainkd.XPrv{walletKey: *w.XPrv}}
+	}
+	return w, nil
+}
+
+// openKeystore opens the keystore in filename, creating it if it
+// does not exist, with the passphrase in the environment variable
+// TXVMCLI_PASSPHRASE.
+func openKeystore(filename string) (*keystore.Keystore, error) {
+	passphrase := []byte(os.Getenv("TXVMCLI_PASSPHRASE"))
+	if len(passphrase) == 0 {
+		return nil, errors.New("keystore requires TXVMCLI_PASSPHRASE")
+	}
+	ks, err := keystore.Open(filename, passphrase)
+	if os.IsNotExist(errors.Root(err)) {
+		ks, err = keystore.Create(filename, passphrase, nil)
+	}
+	return ks, err
+}
+
+// sign signs msg with the root key derived along path, first opening
+// the keystore of a keystore wallet.
+func (w *wallet) sign(ctx context.Context, path [][]byte, msg []byte) ([]byte, error) {
+	if w.signer == nil {
+		ks, err := openKeystore(w.Keystore)
+		if err != nil {
+			return nil, err
+		}
+		w.signer = ks
+	}
+	return w.signer.Sign(ctx, walletKey, path, msg)
+}
+
+func (w *wallet) save() error {
+	bits, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(w.filename), 0700)
+	if err != nil {
+		return err
+	}
+	tmp := w.filename + ".tmp"
+	err = ioutil.WriteFile(tmp, append(bits, '\n'), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.filename)
+}
+
+func indexPath(i uint64) [][]byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], i)
+	return [][]byte{b[:]}
+}
+
+func (w *wallet) pubkey(path [][]byte) ed25519.PublicKey {
+	return w.XPub.Derive(path).PublicKey()
+}
+
+// newAddress returns the public key for the next unused index.
+func (w *wallet) newAddress() (ed25519.PublicKey, uint64) {
+	i := w.NextIndex
+	w.NextIndex++
+	return w.pubkey(indexPath(i)), i
+}
+
+// signFunc signs for any key ID that is the public key the wallet
+// derives along path.
+func (w *wallet) signFunc(ctx context.Context, msg, keyID []byte, path [][]byte) ([]byte, error) {
+	if string(w.pubkey(path)) != string(keyID) {
+		return nil, nil
+	}
+	return w.sign(ctx, path, msg)
+}
+
+func (w *wallet) registry() *assets.Registry {
+	r := new(assets.Registry)
+	for _, doc := range w.Assets {
+		r.Add(doc)
+	}
+	return r
+}
+
+// scan updates w's outputs from the transactions in b.
+func (w *wallet) scan(b *bc.Block) {
+	keys := make(map[string]uint64)
+	for i := uint64(0); i < w.NextIndex; i++ {
+		keys[string(w.pubkey(indexPath(i)))] = i
+	}
+	spent := make(map[bc.Hash]bool)
+	for _, res := range txresult.Results(b.Transactions) {
+		for _, inp := range res.Inputs {
+			spent[inp.OutputID] = true
+		}
+		for _, out := range res.Outputs {
+			if out.Value == nil || len(out.Pubkeys) != 1 {
+				continue
+			}
+			i, ok := keys[string(out.Pubkeys[0])]
+			if !ok {
+				continue
+			}
+			w.UTXOs = append(w.UTXOs, &utxo{
+				OutputID: out.OutputID,
+				AssetID:  out.Value.AssetID,
+				Amount:   int64(out.Value.Amount),
+				Anchor:   out.Value.Anchor,
+				Version:  out.Version,
+				Index:    i,
+			})
+		}
+	}
+	kept := w.UTXOs[:0]
+	for _, u := range w.UTXOs {
+		if !spent[u.OutputID] {
+			kept = append(kept, u)
+		}
+	}
+	w.UTXOs = kept
+	w.Height = b.Height
+}
+
+// spendable returns unspent, non-pending outputs of assetID totaling
+// at least amt, and their total.
+func (w *wallet) spendable(assetID bc.Hash, amt int64) ([]*utxo, int64, error) {
+	var (
+		picked []*utxo
+		total  int64
+	)
+	for _, u := range w.UTXOs {
+		if u.Pending || u.AssetID != assetID {
+			continue
+		}
+		picked = append(picked, u)
+		var err error
+		total, err = amount.Add(total, u.Amount)
+		if err != nil {
+			return nil, 0, err
+		}
+		if total >= amt {
+			return picked, total, nil
+		}
+	}
+	return nil, 0, errors.New("insufficient funds")
+}
@@ -0,0 +1,325 @@
+/*
+
+Command txvmd runs a blockchain node.
+
+Usage:
+
+	txvmd [-config FILE] [-repair]
+
+A node stores blocks and state snapshots in a directory on disk (see
+package i10r.io/protocol/filestore), holds submitted transactions in
+a mempool (see package i10r.io/protocol/mempool), and serves an HTTP
+API on its listen address.
+
+A node without a peer is a generator. On first run it creates a
+block-signing key, stored in the file block.key in its data
+directory, and an initial block whose predicate requires that key's
+signature. Then, once per block period, it makes a block from the
+pending transactions, signs it, and commits it.
+
+A node with a peer is a follower. It fetches blocks from the peer,
+validates them (including their signatures), and commits them. When
+it is 16 or more blocks behind, as when it first starts, it catches
+up by fetching and running several blocks at once (see package
+i10r.io/protocol/pipeline). It accepts transactions too, and relays
+them to the peer. At startup
+it compares the peer's consensus version, the fingerprint of the
+consensus rules compiled into each build (see package consensus), with
+its own, and refuses to start if they differ.
+
+The configuration file is JSON. Every field is optional:
+
+	{
+	  "network":       "",                // network profile
+	  "data_dir":      "txvmd-data",      // block and snapshot storage
+	  "listen":        "127.0.0.1:1999",  // HTTP API address
+	  "admin_listen":  "",                // operator API address, if any
+	  "block_period":  "1s",              // how often to make a block
+	  "empty_blocks":  false,             // make blocks with no transactions
+	  "block_version": 3,                 // version of blocks made
+	  "max_pool_txs":  10000,             // mempool capacity
+	  "peer":          "",                // base URL of a node to follow
+	  "fee_asset":     null,              // hex asset ID fees are ranked in
+	  "keystore":      "",                // encrypted file holding the block key
+	  "remote_signer": null,              // remote signer holding the block key
+	  "block_filters": false,             // commit to light-client filters
+	  "policy":        null,              // mempool admission policy
+	  "submit":        null,              // submission rate limits
+	  "checkpoints":   null,              // finality checkpoint parameters
+	  "block_params":  null,              // initial block limits
+	  "keep_snapshots": 2,                // state snapshots kept on disk
+	  "max_clock_drift": "1m",            // how far ahead of the clock a block may be
+	  "clock_warning": "5s",              // how far ahead before warning of skew
+	  "analytics":     0,                 // recent blocks to keep VM usage statistics of
+	  "plugins":       ""                 // Go plugin of extension operations
+	}
+
+With network set to the name of a network (see package
+i10r.io/protocol/netparams), such as "mainnet", "testnet", or
+"devnet", or without it but with the environment variable
+TXVMNETWORK set to one, the node is for that network. The network's
+port then replaces 1999 in the default listen address, and
+block_version defaults to the least version that accepts the
+network's transactions, as it must be at least. The node refuses to
+start with an initial block other than the network's, if it has a
+fixed one, or with a peer that reports a different network in its
+/status. Networks besides those three are loaded from the JSON file
+named by the environment variable TXVMNETWORKS, if it is set.
+
+With fee_asset set, a generator fills blocks with the pending
+transactions paying the highest fee in that asset per unit of
+runlimit first. Its blocks are then version 4 or later, and claim the fees
+they collect (in any asset) for its block-signing key; see package
+i10r.io/protocol/fee.
+
+Raising block_version on a generator upgrades the blockchain from its
+next block; versions never go down. From version 5, blocks accept
+only transactions whose IDs, and so signatures, are bound to this
+blockchain's initial block, so a transaction made for one network
+cannot be replayed on another (see bc.NetworkVersion).
+
+With keystore set, a generator keeps its block-signing key, named
+"block", in that encrypted keystore file (see package
+i10r.io/crypto/keystore) instead of in block.key, and opens it with
+the passphrase in the environment variable TXVMD_PASSPHRASE. It
+creates the file, and the key, on first run.
+
+With remote_signer set, a generator has a remote signer (see package
+i10r.io/protocol/remotesigner) sign its blocks with the key it holds
+named "block":
+
+	{
+	  "url":        "http://signer:2424", // base URL of the signer
+	  "client_key": "node.key"            // hex key the node authenticates with
+	}
+
+The node creates client_key if it does not exist; the signer must
+list its public key. Such a generator signs no checkpoints.
+
+With block_filters, a generator's blocks, of version 4 or later, commit
+to compact filters of the keys, asset IDs, and contracts their
+transactions touch, which light clients fetch from /get-filter; see
+package i10r.io/protocol/blockfilter.
+
+With block_params, {"max_block_bytes": N, "max_block_runlimit": N,
+"max_block_txs": N} (0 for no limit), a generator's blocks, of
+version 4 or later, commit to those limits and every node holds
+blocks to them. Once a block has limits, later blocks keep them, and
+block_params is ignored; they change only in a block with a
+transaction logging a parameter update signed by a quorum of the
+block-signing keys. See package i10r.io/protocol/chainparams.
+
+A "block_interval_ms" of N in block_params divides time into slots of
+N milliseconds, led in turn by the block-signing keys. Each block's
+timestamp is the start of a slot, so the generator waits for the
+next slot before making a block, makes it only if its key leads the
+slot, and every node refuses a block without the signature of its
+slot's leader. The "slots" section of /status counts the blocks and,
+by signing key, the slots that passed without one, so that an absent
+signer shows. An update changing the interval takes effect in the
+block that includes it, so the generator includes it only in a block
+whose timestamp starts a slot of the new interval.
+
+The policy, if given, limits the transactions the node accepts into
+its mempool, beyond what consensus requires:
+
+	{
+	  "min_fee_rate":    0,   // fee in fee_asset per unit of runlimit
+	  "max_tx_size":     0,   // bytes of program
+	  "max_runlimit":    0,
+	  "dust_threshold":  0,   // least amount per output
+	  "banned_ops":      [],  // opcode sequences, e.g. "pushdata drop"
+	  "strict_encoding": false,
+	  "replace":         false
+	}
+
+Zero means no limit. With replace, a transaction that conflicts with
+pending ones replaces them if it pays a higher fee rate in fee_asset.
+With strict_encoding, a transaction program must be canonically
+encoded (see txvm.StrictEncoding). See mempool.Policy.
+
+Submissions to /submit pass, before they run, through rate limits
+per source IP address and cheap structural checks, including the
+policy's max_tx_size and max_runlimit. They then
+wait for one of a fixed number of validation workers, peers first and
+then sources whose recent submissions have failed least (see package
+i10r.io/protocol/admission). The submit object, if given, sets:
+
+	{
+	  "rate":       10,   // submissions per second per source
+	  "burst":      20,
+	  "peer_rate":  200,  // the same for each peer
+	  "peer_burst": 400,
+	  "workers":    0,    // validations at once, default one per CPU
+	  "queue_len":  1024, // submissions waiting for a worker
+	  "peers":      []    // IP addresses of peers
+	}
+
+Zero means the default shown.
+
+Operators can manage peers while the node runs, through a second
+HTTP API served only on admin_listen, such as "127.0.0.1:2000", an
+address the operator does not expose. On a follower,
+/peers/connect checks another node as at startup, and that it has
+the same initial block, then follows it in place of the peer, and
+/peers/disconnect stops following any node until the next
+/peers/connect. The follower saves the change in the
+file upstream.json in its data directory, which at startup overrides
+the configured peer until the file is removed.
+
+Among submission sources, /peers/add treats a source as a peer,
+/peers/remove as an ordinary source again, and /peers/ban refuses
+its submissions, with 403 Forbidden, for a duration, such as "1h",
+or indefinitely without one, until /peers/unban. The node keeps
+these, and each source's count of recent failures, in the file
+peers.json in its data directory, saved on each change and every
+ten minutes, and restores them at startup. The peers in the submit
+object are peers at every startup, whatever the file says.
+
+With checkpoints, in the form of checkpoint.Params, the node keeps a
+finality layer: it refuses blocks that contradict the latest
+checkpoint signed by a quorum of the checkpoint keys.
+
+	{
+	  "interval": 100,          // blocks between checkpoints
+	  "quorum":   1,            // from 1 to the number of pubkeys
+	  "pubkeys":  ["HEX", ...]  // checkpoint signers
+	}
+
+A generator whose block key is a checkpoint key signs each
+checkpoint as it commits the block; with a quorum of one that
+finalizes it. Otherwise, signers exchange checkpoints through
+/get-checkpoint and /add-checkpoint. See package
+i10r.io/protocol/checkpoint.
+
+At startup a node checks that the blocks and snapshot in its data
+directory are consistent, and refuses to start if they are not. With
+-repair, it instead rolls the directory back to the last block up to
+which they are, moving the files after it to the corrupt
+subdirectory, and recomputes its state from the blocks that remain.
+A follower then fetches the removed blocks from its peer again.
+
+Every ten minutes, a node removes from its data directory the state
+snapshots older than the latest keep_snapshots of them, which repair
+can fall back to, and any temporary files left by a crash.
+
+A node records each transaction it admits to its mempool in the file
+mempool.log in its data directory, on disk before /submit responds.
+At startup it validates the transactions in the file again, against
+the latest block's state, and restores those still valid to the
+mempool, so that a restart does not drop pending transactions. The
+file is rewritten to hold just the pending transactions when it grows
+to several times their number (see mempool.Log).
+
+A follower refuses a block whose timestamp is more than
+max_clock_drift ahead of its clock ("0s" for no limit), and retries
+it until its clock catches up. A node logs a clock-skew warning when
+a block it receives, or the latest block when it makes the next, is
+more than clock_warning ahead of its clock ("0s" for none); its
+clock's synchronization, such as by NTP, should then be checked.
+
+With analytics set to N, greater than 0, the node aggregates
+statistics on how the transactions of each block it commits use the
+VM: the opcodes executed, the contracts spent and created, each
+asset's issuance, retirement, and outputs, and the distribution of
+the runlimit used (see package i10r.io/protocol/analytics). It keeps
+totals since it started and the statistics of the last N blocks, and
+serves them at /analytics. Counting opcodes means running every
+transaction again.
+
+With plugins set to the path of a Go plugin, built from the same
+source tree, whose exported variable Plugins is a *txvm.Plugins, the
+node's transactions may call its operations with ext (see
+txvm.NewPlugins). They are part of the consensus rules: the node
+runs every transaction, whether from a block, the peer, or a client,
+with them, and its consensus version covers them (see
+consensus.VersionWith), so a follower and its peer must load the
+same plugins.
+
+Without -config, the defaults above apply. So a single-node devnet
+is just:
+
+	txvmd
+
+The HTTP API is:
+
+	POST /submit              body {"version": V, "runlimit": R, "program": "HEX"}
+	POST /dry-run             body as for /submit, plus "trace": true
+	                          for an execution trace
+	GET  /status              network, height, initial block ID,
+	                          block version, pending count,
+	                          submission counts, consensus version,
+	                          program cache statistics
+	GET  /get-block?height=N  the block's protobuf encoding
+	                          (&wait=1 to wait for it to arrive)
+	GET  /get-filter?height=N the block's filter, if it commits to one
+	GET  /events?cursor=C     up to 100 events after cursor C
+	                          (&limit=N for up to N; see below for
+	                          output filters)
+	POST /events              body {"cursor": C, "limit": N,
+	                          "filter": events.Filter}
+	GET  /get-checkpoint      the latest finalized checkpoint
+	POST /add-checkpoint      body a JSON checkpoint.Checkpoint
+	GET  /analytics           VM usage statistics since startup, if
+	                          enabled (?blocks=N for each of the last N
+	                          blocks and their totals)
+	GET  /peers               the followed peer, the peers, and the
+	                          sources banned or recently failing
+
+The operator API on admin_listen is:
+
+	GET  /peers               as above
+	POST /peers/connect       body {"url": "http://host:port"}
+	POST /peers/disconnect
+	POST /peers/add           body {"addr": "IP"}
+	POST /peers/remove        body {"addr": "IP"}
+	POST /peers/ban           body {"addr": "IP", "duration": "1h"}
+	POST /peers/unban         body {"addr": "IP"}
+
+/events responds with {"events": [...], "cursor": C}, where C is
+the cursor of the last event, to pass to the next request. It waits
+up to 30 seconds for the first event; if none arrives, the list is
+empty and C is unchanged. Without a cursor, it starts at the first
+block. Each output event names the output's contract, if it is a
+standard contract or one in the JSON file named by the environment
+variable TXVMCONTRACTS (see package i10r.io/protocol/contracts).
+Output events are only for outputs matching the request's
+filter, given in a GET request by these parameters, each of which
+may repeat:
+
+	asset=HEX       the output's asset ID
+	address=ADDR    the output's predicate, a standard address
+	predicate=HEX   the output's predicate, by hash
+	pubkey=HEX      one of the output's keys
+	tag=HEX         a prefix of the output's reference data or
+	                tags, or of its transaction's tags
+	outputs=1       every output, if no other filter is given
+
+An output must match one of the values given for each parameter.
+See package i10r.io/protocol/events.
+
+A transaction accepted by /submit is pending, not yet in a block;
+/submit responds with its ID, the runlimit it used, and the runlimit
+attributed to each of its log entries (see txvm.Validate). A
+transaction that is valid but that
+the node's policy rejects gets status 403; one that is invalid gets
+400, or 409 if it conflicts with the state or another pending
+transaction. A submission over its source's rate limit gets 429, and
+one the node is too busy to validate 503.
+
+/dry-run runs a transaction as /submit would, against the latest
+block's state and the pending transactions, but neither keeps nor
+relays it, so that a rejection can be investigated. It responds with
+the status /submit would give and the error, if any; the transaction
+ID, if it finalized; the runlimit it used and attributed to each log
+entry; and the log as far as it got, each entry in assembly language
+with, for typed entries, its type and decoded value (see package
+i10r.io/protocol/logdata). If the VM failed, "fault" gives the error
+code, the pc and opcode of the failing instruction, the contract
+running, and the top of its stack. With "trace", "trace" lists every
+instruction executed, with its nesting depth, contract, and pc.
+Contracts are named as in /events. Submissions that fail the
+admission checks made before running are refused as by /submit.
+
+*/
+package main
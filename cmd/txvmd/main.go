@@ -0,0 +1,630 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"plugin"
+	"strings"
+	"sync"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/keystore"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/log"
+	"i10r.io/protocol"
+	"i10r.io/protocol/admission"
+	"i10r.io/protocol/analytics"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/blockfilter"
+	"i10r.io/protocol/chainparams"
+	"i10r.io/protocol/checkpoint"
+	"i10r.io/protocol/contracts"
+	"i10r.io/protocol/fee"
+	"i10r.io/protocol/filestore"
+	"i10r.io/protocol/mempool"
+	"i10r.io/protocol/netparams"
+	"i10r.io/protocol/remotesigner"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+)
+
+type config struct {
+	Network       string              `json:"network"`
+	DataDir       string              `json:"data_dir"`
+	Listen        string              `json:"listen"`
+	AdminListen   string              `json:"admin_listen"`
+	BlockPeriod   chainjson.Duration  `json:"block_period"`
+	EmptyBlocks   bool                `json:"empty_blocks"`
+	BlockVersion  uint64              `json:"block_version"`
+	MaxPoolTxs    int                 `json:"max_pool_txs"`
+	Peer          string              `json:"peer"`
+	FeeAsset      *bc.Hash            `json:"fee_asset"`
+	Keystore      string              `json:"keystore"`
+	RemoteSigner  *remoteConfig       `json:"remote_signer"`
+	BlockFilters  bool                `json:"block_filters"`
+	Policy        *policyConfig       `json:"policy"`
+	Submit        *submitConfig       `json:"submit"`
+	Checkpoints   *checkpoint.Params  `json:"checkpoints"`
+	BlockParams   *chainparams.Params `json:"block_params"`
+	KeepSnapshots int                 `json:"keep_snapshots"`
+	MaxClockDrift chainjson.Duration  `json:"max_clock_drift"`
+	ClockWarning  chainjson.Duration  `json:"clock_warning"`
+	Analytics     int                 `json:"analytics"`
+	Plugins       string              `json:"plugins"`
+
+	net *netparams.Params // the selected network, or nil
+}
+
+// remoteConfig names a remote signer holding the block key.
+type remoteConfig struct {
+	URL       string `json:"url"`
+	ClientKey string `json:"client_key"` // file of the hex key authenticating the node
+}
+
+// policyConfig is the JSON form of a mempool.Policy.
+type policyConfig struct {
+	MinFeeRate     float64  `json:"min_fee_rate"`
+	MaxTxSize      int      `json:"max_tx_size"`
+	MaxRunlimit    int64    `json:"max_runlimit"`
+	DustThreshold  int64    `json:"dust_threshold"`
+	BannedOps      []string `json:"banned_ops"`
+	StrictEncoding bool     `json:"strict_encoding"`
+	Replace        bool     `json:"replace"`
+}
+
+func (pc *policyConfig) policy(feeAsset *bc.Hash) (*mempool.Policy, error) {
+	pol := &mempool.Policy{
+		MinFeeRate:     pc.MinFeeRate,
+		MaxSize:        pc.MaxTxSize,
+		MaxRunlimit:    pc.MaxRunlimit,
+		DustThreshold:  pc.DustThreshold,
+		StrictEncoding: pc.StrictEncoding,
+		Replace:        pc.Replace,
+	}
+	if pol.MinFeeRate > 0 || pol.Replace {
+		if feeAsset == nil {
+			return nil, errors.New("policy min_fee_rate and replace require fee_asset")
+		}
+		pol.FeeAsset = *feeAsset
+	}
+	for _, seq := range pc.BannedOps {
+		var ops []byte
+		for _, name := range strings.Fields(seq) {
+			if name == "pushdata" {
+				ops = append(ops, op.MinPushdata)
+				continue
+			}
+			code, ok := op.Code(name)
+			if !ok {
+				return nil, fmt.Errorf("policy banned_ops: unknown opcode %q", name)
+			}
+			ops = append(ops, code)
+		}
+		pol.BannedOps = append(pol.BannedOps, ops)
+	}
+	return pol, nil
+}
+
+// submitConfig is the JSON form of an admission.Config, with the
+// sources treated as peers.
+type submitConfig struct {
+	Rate      float64  `json:"rate"`
+	Burst     int      `json:"burst"`
+	PeerRate  float64  `json:"peer_rate"`
+	PeerBurst int      `json:"peer_burst"`
+	Workers   int      `json:"workers"`
+	QueueLen  int      `json:"queue_len"`
+	Peers     []string `json:"peers"`
+}
+
+// defaultConfig returns the default configuration for the network
+// net, if it is not nil.
+func defaultConfig(net *netparams.Params) *config {
+	cfg := &config{
+		DataDir:       "txvmd-data",
+		Listen:        "127.0.0.1:1999",
+		BlockPeriod:   chainjson.Duration{Duration: time.Second},
+		MaxPoolTxs:    mempool.DefaultMaxTxs,
+		KeepSnapshots: 2,
+		MaxClockDrift: chainjson.Duration{Duration: time.Minute},
+		ClockWarning:  chainjson.Duration{Duration: 5 * time.Second},
+	}
+	if net != nil {
+		cfg.Network = net.Name
+		cfg.Listen = net.Listen()
+		cfg.BlockVersion = net.BlockVersion()
+		cfg.net = net
+	}
+	return cfg
+}
+
+func loadConfig(filename string) (*config, error) {
+	if filename == "" {
+		net, err := netparams.Select("")
+		if err != nil {
+			return nil, err
+		}
+		return defaultConfig(net), nil
+	}
+	bits, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	// The network sets the defaults for the rest of the file.
+	var sel struct {
+		Network string `json:"network"`
+	}
+	err = json.Unmarshal(bits, &sel)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", filename)
+	}
+	net, err := netparams.Select(sel.Network)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: network", filename)
+	}
+	cfg := defaultConfig(net)
+	err = json.Unmarshal(bits, cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", filename)
+	}
+	if cfg.BlockPeriod.Duration <= 0 {
+		return nil, fmt.Errorf("%s: block_period must be positive", filename)
+	}
+	if cfg.BlockVersion != 0 && (cfg.BlockVersion < 3 || cfg.BlockVersion > bc.NetworkVersion) {
+		return nil, fmt.Errorf("%s: block_version must be from 3 to %d", filename, bc.NetworkVersion)
+	}
+	if net != nil && (cfg.BlockVersion < net.BlockVersion() || (cfg.BlockVersion >= bc.NetworkVersion) != (net.TxVersion >= txvm.NetworkVersion)) {
+		return nil, fmt.Errorf("%s: blocks of version %d do not accept %s's transactions, of version %d", filename, cfg.BlockVersion, net.Name, net.TxVersion)
+	}
+	cfg.Peer = strings.TrimSuffix(cfg.Peer, "/")
+	if cfg.Checkpoints != nil {
+		if err := cfg.Checkpoints.Check(); err != nil {
+			return nil, errors.Wrapf(err, "%s: checkpoints", filename)
+		}
+	}
+	if cfg.Policy != nil {
+		if _, err := cfg.Policy.policy(cfg.FeeAsset); err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", filename)
+		}
+	}
+	return cfg, nil
+}
+
+type node struct {
+	cfg   *config
+	store *filestore.Store
+	chain *protocol.Chain
+	pool  *mempool.Pool
+	gate  *admission.Gate
+	// signer or, with a remote signer, remote holds the block key,
+	// whose public key is pub. All are nil for a follower.
+	signer keystore.Signer
+	remote *remotesigner.Client
+	pub    ed25519.PublicKey
+
+	analytics *analytics.Collector // nil unless configured
+	plugins   *txvm.Plugins        // nil unless configured
+
+	// follower is set for a node configured with a peer. The peer it
+	// follows, which the operator API can change or remove while it
+	// runs, is peer, nil for a generator or while disconnected;
+	// peerChanged is closed when peer changes. Both are guarded by
+	// peerMu.
+	follower    bool
+	peerMu      sync.Mutex
+	peer        *peer
+	peerChanged chan struct{}
+}
+
+// blockKey is the name of the block-signing key.
+const blockKey = "block"
+
+func main() {
+	configFile := flag.String("config", "", "configuration file")
+	repair := flag.Bool("repair", false, "roll back a corrupt data directory to its last consistent block")
+	flag.Parse()
+
+	ctx := context.Background()
+	err := netparams.LoadEnv()
+	if err != nil {
+		fatal(err)
+	}
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		fatal(err)
+	}
+	err = contracts.LoadEnv()
+	if err != nil {
+		fatal(err)
+	}
+	n, err := start(ctx, cfg, *repair)
+	if err != nil {
+		fatal(err)
+	}
+
+	if n.follower {
+		go n.follow(ctx)
+	} else {
+		go n.generate(ctx)
+	}
+	go n.collect(ctx)
+	if cfg.AdminListen != "" {
+		go func() {
+			log.Printkv(ctx, "event", "listening", "addr", cfg.AdminListen, "api", "admin")
+			fatal(http.ListenAndServe(cfg.AdminListen, n.adminHandler()))
+		}()
+	}
+	log.Printkv(ctx, "event", "listening", "addr", cfg.Listen, "height", n.chain.Height())
+	err = http.ListenAndServe(cfg.Listen, n.handler())
+	fatal(err)
+}
+
+// start opens the store, repairing it if repair is set, and brings
+// the chain up to date with it, creating or fetching the initial
+// block if the store is empty.
+func start(ctx context.Context, cfg *config, repair bool) (*node, error) {
+	var (
+		store *filestore.Store
+		err   error
+	)
+	if repair {
+		var moved []string
+		store, moved, err = filestore.Repair(cfg.DataDir)
+		for _, name := range moved {
+			log.Printkv(ctx, "event", "repair", "removed", name)
+		}
+		if err == nil && len(moved) > 0 {
+			if h, _ := store.Height(ctx); h == 0 {
+				// Don't start a new blockchain in place of the old one.
+				return nil, errors.New("repair removed the initial block")
+			}
+		}
+	} else {
+		store, err = filestore.Open(cfg.DataDir)
+	}
+	if errors.Root(err) == filestore.ErrCorrupt {
+		return nil, errors.Wrap(err, "opening store (see -repair)")
+	}
+	if err != nil {
+		return nil, err
+	}
+	n := &node{cfg: cfg, store: store, peerChanged: make(chan struct{})}
+	if cfg.Analytics > 0 {
+		n.analytics = analytics.NewCollector(cfg.Analytics)
+	}
+	if cfg.Plugins != "" {
+		n.plugins, err = loadPlugins(cfg.Plugins)
+		if err != nil {
+			return nil, err
+		}
+		store.SetPlugins(n.plugins)
+	}
+	if cfg.Peer != "" {
+		n.follower = true
+		url, err := n.loadUpstream(cfg.Peer)
+		if err != nil {
+			return nil, err
+		}
+		if url != "" {
+			n.peer = &peer{url: url, plugins: n.plugins}
+			_, err = n.peer.handshake(ctx, cfg.net)
+			if err != nil {
+				return nil, errors.Wrapf(err, "checking peer %s", url)
+			}
+		}
+	} else {
+		switch {
+		case cfg.RemoteSigner != nil:
+			n.remote, n.pub, err = openRemote(ctx, cfg.RemoteSigner)
+		case cfg.Keystore != "":
+			n.signer, n.pub, err = openKeystore(cfg.Keystore)
+		default:
+			var prv ed25519.PrivateKey
+			prv, err = loadKey(filepath.Join(cfg.DataDir, "block.key"))
+			n.signer = &keystore.Plain{Keys: map[string]ed25519.PrivateKey{blockKey: prv}}
+			n.pub = prv.Public().(ed25519.PublicKey)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	height, err := store.Height(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var b1 *bc.Block
+	if height > 0 {
+		b1, err = store.GetBlock(ctx, 1)
+	} else if n.peer != nil {
+		b1, err = n.peer.getBlock(ctx, 1, false)
+	} else if n.follower {
+		err = fmt.Errorf("disconnected from the peer and without blocks (see %s)", n.upstreamFile())
+	} else {
+		b1, err = protocol.NewInitialBlock([]ed25519.PublicKey{n.pub}, 1, time.Now())
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "getting initial block")
+	}
+
+	if cfg.net != nil {
+		err = cfg.net.CheckInitialBlock(b1.Hash())
+		if err != nil {
+			return nil, err
+		}
+	}
+	n.chain, err = protocol.NewChain(ctx, b1, store, nil)
+	if err != nil {
+		return nil, err
+	}
+	n.chain.SetPlugins(n.plugins)
+	n.chain.SetTimePolicy(protocol.TimePolicy{
+		MaxFutureDrift: cfg.MaxClockDrift.Duration,
+		SkewWarning:    cfg.ClockWarning.Duration,
+	})
+	if cfg.Checkpoints != nil {
+		f, err := checkpoint.NewFinality(*cfg.Checkpoints, n.chain.InitialBlockHash)
+		if err != nil {
+			return nil, errors.Wrap(err, "checkpoints")
+		}
+		n.chain.SetFinality(f)
+	}
+	if height > 0 {
+		_, err = n.chain.Recover(ctx)
+	} else {
+		snapshot := state.Empty()
+		err = snapshot.ApplyBlock(b1.UnsignedBlock)
+		if err == nil {
+			err = n.chain.CommitAppliedBlock(ctx, b1, snapshot)
+		}
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "loading blockchain")
+	}
+	n.pool = mempool.New(n.chain.State(), cfg.MaxPoolTxs)
+	n.pool.SetPlugins(n.plugins)
+	if cfg.Policy != nil {
+		pol, err := cfg.Policy.policy(cfg.FeeAsset)
+		if err != nil {
+			return nil, err
+		}
+		n.pool.SetPolicy(pol)
+	}
+	err = n.restorePool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	acfg := admission.Config{}
+	if cfg.Policy != nil {
+		acfg.MaxSize, acfg.MaxRunlimit = cfg.Policy.MaxTxSize, cfg.Policy.MaxRunlimit
+	}
+	if sc := cfg.Submit; sc != nil {
+		acfg.Source = admission.Limit{Rate: sc.Rate, Burst: sc.Burst}
+		acfg.Peer = admission.Limit{Rate: sc.PeerRate, Burst: sc.PeerBurst}
+		acfg.Workers, acfg.QueueLen = sc.Workers, sc.QueueLen
+	}
+	n.gate = admission.New(acfg)
+	if sc := cfg.Submit; sc != nil {
+		for _, p := range sc.Peers {
+			n.gate.SetPeer(p, true)
+		}
+	}
+	rep, err := admission.LoadReputation(n.reputationFile())
+	if err != nil {
+		return nil, err
+	}
+	n.gate.Restore(rep)
+	bb := n.chain.BlockBuilder()
+	if cfg.BlockVersion != 0 {
+		bb.Version = cfg.BlockVersion
+	}
+	if cfg.FeeAsset != nil && n.pub != nil {
+		if bb.Version < bc.CommitmentsVersion {
+			bb.Version = bc.CommitmentsVersion
+		}
+		bb.FeeClaim = &fee.Claim{Quorum: 1, Pubkeys: []ed25519.PublicKey{n.pub}}
+	}
+	if cfg.BlockFilters {
+		if bb.Version < bc.CommitmentsVersion {
+			bb.Version = bc.CommitmentsVersion
+		}
+		bb.Commitments = map[string]protocol.CommitmentFunc{
+			blockfilter.Commitment: func(b *bc.UnsignedBlock, _ *state.Snapshot) ([]byte, error) {
+				return blockfilter.Value(blockfilter.Build(b)), nil
+			},
+		}
+	}
+	if cfg.BlockParams != nil {
+		if bb.Version < bc.CommitmentsVersion {
+			bb.Version = bc.CommitmentsVersion
+		}
+		bb.Params = cfg.BlockParams
+	}
+	return n, nil
+}
+
+// restorePool re-admits to the mempool the transactions in the
+// mempool log in the data directory, those pending when the node
+// last stopped, and then logs the transactions admitted from now on.
+func (n *node) restorePool(ctx context.Context) error {
+	l, txs, err := mempool.OpenLog(filepath.Join(n.cfg.DataDir, "mempool.log"))
+	if err != nil {
+		return err
+	}
+	if len(txs) > 0 {
+		results := n.pool.AddBatch(ctx, txs, bc.NetworkOption(n.chain.InitialBlockHash), txvm.WithProgramCache(bc.ProgramCache))
+		var dropped int
+		for _, r := range results {
+			if r.Err != nil {
+				dropped++
+			}
+		}
+		log.Printkv(ctx, "event", "mempool-restore", "logged", len(txs), "dropped", dropped, "pending", n.pool.Len())
+	}
+	return n.pool.SetLog(l)
+}
+
+// pluginsSymbol is the name of the variable loadPlugins looks up, of
+// type *txvm.Plugins. The plugin must be built from the same source
+// tree as txvmd.
+const pluginsSymbol = "Plugins"
+
+// loadPlugins opens the Go plugin at path and returns its plugin
+// operations, which the node's transactions may then use, and which
+// its peers must share (see consensus.VersionWith).
+func loadPlugins(path string) (*txvm.Plugins, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening plugin %s", path)
+	}
+	sym, err := p.Lookup(pluginsSymbol)
+	if err != nil {
+		return nil, errors.Wrapf(err, "plugin %s", path)
+	}
+	ps, ok := sym.(**txvm.Plugins)
+	if !ok || *ps == nil {
+		return nil, fmt.Errorf("plugin %s: %s has type %T, want *txvm.Plugins", path, pluginsSymbol, sym)
+	}
+	return *ps, nil
+}
+
+func (n *node) reputationFile() string {
+	return filepath.Join(n.cfg.DataDir, "peers.json")
+}
+
+// saveReputation writes the reputation of the node's submission
+// sources to the data directory, so that their bans and standing
+// as peers outlast a restart.
+func (n *node) saveReputation(ctx context.Context) {
+	err := n.gate.Reputation().Save(n.reputationFile())
+	if err != nil {
+		log.Error(ctx, err, "saving peer reputation")
+	}
+}
+
+func (n *node) upstreamFile() string {
+	return filepath.Join(n.cfg.DataDir, "upstream.json")
+}
+
+// upstreamState is the form of the file upstream.json, in which a
+// follower records the peer last connected to or disconnected from
+// through the operator API. An empty URL means it is disconnected.
+type upstreamState struct {
+	URL string `json:"url"`
+}
+
+// loadUpstream returns the URL of the peer saved in upstream.json,
+// or def if the file does not exist.
+func (n *node) loadUpstream(def string) (string, error) {
+	bits, err := ioutil.ReadFile(n.upstreamFile())
+	if os.IsNotExist(err) {
+		return def, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var st upstreamState
+	err = json.Unmarshal(bits, &st)
+	return st.URL, errors.Wrapf(err, "parsing %s", n.upstreamFile())
+}
+
+// saveUpstream writes the URL of p, or none if p is nil, to
+// upstream.json, so that the peer outlasts a restart.
+func (n *node) saveUpstream(ctx context.Context, p *peer) {
+	var st upstreamState
+	if p != nil {
+		st.URL = p.url
+	}
+	bits, err := json.Marshal(st)
+	if err != nil {
+		log.Error(ctx, err, "saving upstream peer")
+		return
+	}
+	name := n.upstreamFile()
+	tmp := name + ".tmp"
+	err = ioutil.WriteFile(tmp, append(bits, '\n'), 0600)
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		log.Error(ctx, err, "saving upstream peer")
+	}
+}
+
+// loadKey reads the hex-encoded private key in filename, first
+// generating and writing one if the file does not exist.
+func loadKey(filename string) (ed25519.PrivateKey, error) {
+	bits, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		_, prv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			return nil, err
+		}
+		err = ioutil.WriteFile(filename, []byte(hex.EncodeToString(prv)+"\n"), 0600)
+		return prv, errors.Wrap(err, "writing key")
+	}
+	if err != nil {
+		return nil, err
+	}
+	prv, err := hex.DecodeString(strings.TrimSpace(string(bits)))
+	if err != nil || len(prv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%s does not contain a hex private key", filename)
+	}
+	return ed25519.PrivateKey(prv), nil
+}
+
+// openKeystore opens the keystore in filename, with the passphrase
+// in the environment variable TXVMD_PASSPHRASE, first creating it if
+// it does not exist and adding a block key if it has none.
+func openKeystore(filename string) (*keystore.Keystore, ed25519.PublicKey, error) {
+	passphrase := []byte(os.Getenv("TXVMD_PASSPHRASE"))
+	if len(passphrase) == 0 {
+		return nil, nil, errors.New("keystore requires TXVMD_PASSPHRASE")
+	}
+	ks, err := keystore.Open(filename, passphrase)
+	if os.IsNotExist(errors.Root(err)) {
+		ks, err = keystore.Create(filename, passphrase, nil)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, err := ks.PublicKey(blockKey)
+	if errors.Root(err) == keystore.ErrNoKey {
+		_, err = ks.Generate(blockKey, keystore.Ed25519)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "generating block key")
+		}
+		pub, err = ks.PublicKey(blockKey)
+	}
+	return ks, pub, err
+}
+
+// openRemote returns a client of the remote signer in rc and the
+// public key of the block key it holds.
+func openRemote(ctx context.Context, rc *remoteConfig) (*remotesigner.Client, ed25519.PublicKey, error) {
+	prv, err := loadKey(rc.ClientKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	c := &remotesigner.Client{URL: rc.URL, Key: prv}
+	pub, err := c.PublicKey(ctx, blockKey)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "getting block key from %s", rc.URL)
+	}
+	return c, pub, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "txvmd:", err)
+	os.Exit(1)
+}
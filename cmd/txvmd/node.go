@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/log"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/chainparams"
+	"i10r.io/protocol/checkpoint"
+	"i10r.io/protocol/consensus"
+	"i10r.io/protocol/netparams"
+	"i10r.io/protocol/pipeline"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/validation"
+)
+
+// generate makes a block once per block period until ctx is done.
+func (n *node) generate(ctx context.Context) {
+	ticker := time.NewTicker(n.cfg.BlockPeriod.Duration)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if expired := n.pool.Expire(n.chain.Now()); len(expired) > 0 {
+			log.Printkv(ctx, "event", "expire", "txs", len(expired), "pending", n.pool.Len())
+		}
+		if n.pool.Len() == 0 && !n.cfg.EmptyBlocks {
+			continue
+		}
+		err := n.makeBlock(ctx)
+		if err != nil {
+			log.Error(ctx, err, "making block")
+		}
+	}
+}
+
+func (n *node) makeBlock(ctx context.Context) error {
+	prev := n.chain.State()
+	ts := n.chain.NextTimestampMS(ctx)
+	if wait := bc.FromMillis(ts).Sub(n.chain.Now()); wait > 0 {
+		// The next block slot starts in the future.
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	txs := n.pool.Pending()
+	if n.cfg.FeeAsset != nil {
+		txs = n.pool.Prioritized(*n.cfg.FeeAsset)
+	}
+	ub, snapshot, err := n.chain.GenerateBlock(ctx, ts, txs)
+	if err != nil {
+		return errors.Wrap(err, "generating block")
+	}
+	err = n.checkLeader(ub, prev.Header)
+	if err != nil {
+		return err
+	}
+	b, err := bc.SignBlock(ub, prev.Header, func(int) (interface{}, error) {
+		if n.remote != nil {
+			return n.remote.SignBlock(ctx, blockKey, ub.BlockHeader)
+		}
+		return n.signer.Sign(ctx, blockKey, nil, ub.BlockHeader.Hash().Bytes())
+	})
+	if err != nil {
+		return err
+	}
+	err = n.chain.CommitAppliedBlock(ctx, b, snapshot)
+	if err != nil {
+		return errors.Wrap(err, "committing block")
+	}
+	n.committed(ctx, b)
+	return nil
+}
+
+// checkLeader returns an error whose root is chainparams.ErrSlot if
+// ub's parameters have a block interval and n's block key does not
+// lead ub's slot among the signers of prev's next-block predicate.
+// Other nodes would refuse the block.
+func (n *node) checkLeader(ub *bc.UnsignedBlock, prev *bc.BlockHeader) error {
+	p, err := chainparams.Of(ub.BlockHeader)
+	if err != nil || p == nil || p.BlockIntervalMS == 0 {
+		return err
+	}
+	slot := p.Slot(ub.TimestampMs)
+	leader, err := chainparams.Leader(prev.NextPredicate, slot)
+	if err != nil {
+		return err
+	}
+	signers, _, err := prev.NextPredicate.Signers()
+	if err != nil {
+		return err
+	}
+	if pk := signers[leader].Pubkey; !bytes.Equal(pk, n.pub) {
+		return errors.WithDetailf(chainparams.ErrSlot, "slot %d is led by block key %x", slot, pk)
+	}
+	return nil
+}
+
+// follow fetches, validates, and commits blocks from the followed
+// peer until ctx is done. It catches up first, and again after any
+// error or change of peer. While disconnected, it waits for a peer.
+func (n *node) follow(ctx context.Context) {
+	for ctx.Err() == nil {
+		p, changed := n.upstream()
+		if p == nil {
+			select {
+			case <-ctx.Done():
+			case <-changed:
+			}
+			continue
+		}
+		pctx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-changed:
+			case <-pctx.Done():
+			}
+			cancel()
+		}()
+		err := n.catchUp(pctx, p)
+		for err == nil && pctx.Err() == nil {
+			err = n.fetchBlock(pctx, p)
+		}
+		if err != nil && pctx.Err() == nil {
+			log.Error(ctx, err, "fetching block from ", p.url)
+			select {
+			case <-pctx.Done():
+			case <-time.After(n.cfg.BlockPeriod.Duration):
+			}
+		}
+		cancel()
+	}
+}
+
+// upstream returns the peer n follows, nil if there is none, and a
+// channel closed when that changes.
+func (n *node) upstream() (*peer, <-chan struct{}) {
+	n.peerMu.Lock()
+	defer n.peerMu.Unlock()
+	return n.peer, n.peerChanged
+}
+
+// setUpstream makes n follow p, or no peer if p is nil, stopping any
+// fetch from the peer it followed, and saves the change.
+func (n *node) setUpstream(ctx context.Context, p *peer) {
+	n.peerMu.Lock()
+	n.peer = p
+	close(n.peerChanged)
+	n.peerChanged = make(chan struct{})
+	n.peerMu.Unlock()
+	n.saveUpstream(ctx, p)
+}
+
+// catchUpBlocks is how far behind its peer a follower must be to
+// catch up with a pipeline rather than block by block.
+const catchUpBlocks = 16
+
+// catchUp applies the blocks up to p's height, if it is at least
+// catchUpBlocks ahead, fetching and executing several at once (see
+// package pipeline). Each is checked and committed as fetchBlock
+// would.
+func (n *node) catchUp(ctx context.Context, p *peer) error {
+	st, err := p.status(ctx)
+	if err != nil {
+		return err
+	}
+	prev := n.chain.State()
+	if st.Height < prev.Height()+catchUpBlocks {
+		return nil
+	}
+	log.Printkv(ctx, "event", "catch-up", "height", prev.Height(), "peer", st.Height)
+	a := &pipeline.Applier{
+		Fetch: func(ctx context.Context, height uint64) ([]byte, error) {
+			return p.blockBytes(ctx, height, false)
+		},
+		Plugins: n.plugins,
+		Commit: func(ctx context.Context, b *bc.Block, snapshot *state.Snapshot) error {
+			err := n.chain.CommitAppliedBlock(ctx, b, snapshot)
+			if err != nil {
+				return err
+			}
+			n.committed(ctx, b)
+			return nil
+		},
+	}
+	_, err = a.Apply(ctx, prev, st.Height)
+	return err
+}
+
+// collect removes the store's old snapshots and leftover temporary
+// files, and saves the reputation of submission sources, once per
+// collectInterval until ctx is done. It goes slowly, pausing after
+// each file, to leave the disk to block processing.
+func (n *node) collect(ctx context.Context) {
+	ticker := time.NewTicker(collectInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		removed, err := n.store.Collect(ctx, n.cfg.KeepSnapshots, collectPause)
+		if len(removed) > 0 {
+			log.Printkv(ctx, "event", "collect", "removed", len(removed))
+		}
+		if err != nil {
+			log.Error(ctx, err, "collecting store files")
+		}
+		n.saveReputation(ctx)
+	}
+}
+
+const (
+	collectInterval = 10 * time.Minute
+	collectPause    = 100 * time.Millisecond
+)
+
+func (n *node) fetchBlock(ctx context.Context, p *peer) error {
+	prev := n.chain.State()
+	b, err := p.getBlock(ctx, prev.Height()+1, true)
+	if err == errNoBlock {
+		return nil // waited and nothing came; try again
+	}
+	if err != nil {
+		return err
+	}
+	err = validation.Block(b.UnsignedBlock, prev.Header)
+	if err != nil {
+		return errors.Wrapf(err, "validating block %d", b.Height)
+	}
+	err = validation.BlockSig(b, prev.Header.NextPredicate)
+	if err != nil {
+		return errors.Wrapf(err, "validating block %d signatures", b.Height)
+	}
+	err = n.chain.CommitBlock(ctx, b)
+	if err != nil {
+		return errors.Wrapf(err, "committing block %d", b.Height)
+	}
+	n.committed(ctx, b)
+	return nil
+}
+
+func (n *node) committed(ctx context.Context, b *bc.Block) {
+	dropped := n.pool.Update(n.chain.State(), b)
+	log.Printkv(ctx, "event", "block", "height", b.Height, "txs", len(b.Transactions), "dropped", len(dropped), "pending", n.pool.Len())
+	if params := n.cfg.Checkpoints; params != nil && n.signer != nil && params.Interval > 0 && b.Height%params.Interval == 0 {
+		n.checkpoint(ctx, params, b)
+	}
+	if n.analytics != nil {
+		if err := n.analytics.Add(b.UnsignedBlock, txvm.WithPlugins(n.plugins)); err != nil {
+			log.Error(ctx, err, "collecting analytics of block ", b.Height)
+		}
+	}
+}
+
+// checkpoint signs a checkpoint of b, if the node's block key is a
+// checkpoint key, and adds it to the chain. Without a quorum of
+// one, the checkpoint needs other signers' signatures before it can
+// be added; see POST /add-checkpoint.
+func (n *node) checkpoint(ctx context.Context, params *checkpoint.Params, b *bc.Block) {
+	cp := checkpoint.New(n.chain.InitialBlockHash, b.BlockHeader)
+	if !params.HasKey(n.pub) {
+		return // not a checkpoint key
+	}
+	sig, err := n.signer.Sign(ctx, blockKey, nil, cp.SigningMessage())
+	if err == nil {
+		err = cp.AddSignature(params, n.pub, sig)
+	}
+	if err != nil {
+		log.Error(ctx, err, "signing checkpoint")
+		return
+	}
+	err = n.chain.AddCheckpoint(ctx, cp)
+	if errors.Root(err) == checkpoint.ErrQuorum {
+		return
+	}
+	if err != nil {
+		log.Error(ctx, err, "adding checkpoint")
+		return
+	}
+	log.Printkv(ctx, "event", "checkpoint", "height", b.Height)
+}
+
+var errNoBlock = errors.New("no such block")
+
+// peer is a client for another node's HTTP API.
+type peer struct {
+	url     string
+	plugins *txvm.Plugins // this node's, for the handshake and decoding
+}
+
+// handshake checks that the peer runs the same consensus rules as
+// this build, with the same plugins, so that a divergent follower
+// stops before it rejects, or worse accepts, a block the peer
+// disagrees about, and that it is on the network net, if that is not
+// nil. It returns the peer's status.
+func (p *peer) handshake(ctx context.Context, net *netparams.Params) (*statusResponse, error) {
+	st, err := p.status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if net != nil {
+		err = net.CheckName(st.Network)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return st, consensus.CheckWith(p.plugins, st.ConsensusVersion)
+}
+
+func (p *peer) status(ctx context.Context) (*statusResponse, error) {
+	req, err := http.NewRequest("GET", p.url+"/status", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s/status: %s", p.url, resp.Status)
+	}
+	st := new(statusResponse)
+	err = json.NewDecoder(resp.Body).Decode(st)
+	return st, errors.Wrap(err, "decoding peer status")
+}
+
+func (p *peer) getBlock(ctx context.Context, height uint64, wait bool) (*bc.Block, error) {
+	bits, err := p.blockBytes(ctx, height, wait)
+	if err != nil {
+		return nil, err
+	}
+	b := new(bc.Block)
+	err = b.FromBytesContext(ctx, bits, txvm.WithPlugins(p.plugins))
+	return b, errors.Wrapf(err, "decoding block %d from peer", height)
+}
+
+// blockBytes returns the encoding of the peer's block at the given
+// height.
+func (p *peer) blockBytes(ctx context.Context, height uint64, wait bool) ([]byte, error) {
+	u := fmt.Sprintf("%s/get-block?height=%d", p.url, height)
+	if wait {
+		u += "&wait=1"
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errNoBlock
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (p *peer) submit(ctx context.Context, req *submitRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	hreq, err := http.NewRequest("POST", p.url+"/submit", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(hreq.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("relaying to %s: %s: %s", p.url, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,687 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/log"
+	"i10r.io/protocol"
+	"i10r.io/protocol/admission"
+	"i10r.io/protocol/analytics"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/blockfilter"
+	"i10r.io/protocol/checkpoint"
+	"i10r.io/protocol/consensus"
+	"i10r.io/protocol/contracts"
+	"i10r.io/protocol/events"
+	"i10r.io/protocol/logdata"
+	"i10r.io/protocol/mempool"
+	"i10r.io/protocol/txbuilder/address"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/op"
+)
+
+// maxWait is how long /get-block?wait=1 waits for a block, and
+// /events for an event.
+const maxWait = 30 * time.Second
+
+// maxEvents is the most events /events returns at once.
+const maxEvents = 1000
+
+type submitRequest struct {
+	Version  int64              `json:"version"`
+	Runlimit int64              `json:"runlimit"`
+	Program  chainjson.HexBytes `json:"program"`
+}
+
+type statusResponse struct {
+	Network          string          `json:"network,omitempty"`
+	Height           uint64          `json:"height"`
+	InitialBlockID   bc.Hash         `json:"initial_block_id"`
+	BlockVersion     uint64          `json:"block_version"`
+	Pending          int             `json:"pending"`
+	Submit           admission.Stats `json:"submit"`
+	Follower         bool            `json:"follower"`
+	ConsensusVersion bc.Hash         `json:"consensus_version"`
+
+	ProgramCache txvm.ProgramCacheStats `json:"program_cache"`
+	Slots        protocol.SlotStats     `json:"slots"`
+}
+
+func (n *node) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/submit", n.serveSubmit)
+	mux.HandleFunc("/dry-run", n.serveDryRun)
+	mux.HandleFunc("/status", n.serveStatus)
+	mux.HandleFunc("/get-block", n.serveGetBlock)
+	mux.HandleFunc("/get-filter", n.serveGetFilter)
+	mux.HandleFunc("/events", n.serveEvents)
+	mux.HandleFunc("/get-checkpoint", n.serveGetCheckpoint)
+	mux.HandleFunc("/add-checkpoint", n.serveAddCheckpoint)
+	mux.HandleFunc("/analytics", n.serveAnalytics)
+	mux.HandleFunc("/peers", n.servePeers)
+	return mux
+}
+
+// adminHandler serves the operator API, which changes how the node
+// treats submission sources. It is served only on the admin_listen
+// address.
+func (n *node) adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/peers", n.servePeers)
+	mux.HandleFunc("/peers/connect", n.serveConnect)
+	mux.HandleFunc("/peers/disconnect", n.serveDisconnect)
+	mux.HandleFunc("/peers/add", n.servePeerChange)
+	mux.HandleFunc("/peers/remove", n.servePeerChange)
+	mux.HandleFunc("/peers/ban", n.servePeerChange)
+	mux.HandleFunc("/peers/unban", n.servePeerChange)
+	return mux
+}
+
+func (n *node) serveSubmit(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if n.cfg.Policy != nil && n.cfg.Policy.MaxTxSize > 0 {
+		// Hex doubles the program, and the rest of the request is
+		// small.
+		req.Body = http.MaxBytesReader(w, req.Body, int64(2*n.cfg.Policy.MaxTxSize+1024))
+	}
+	var sreq submitRequest
+	err := json.NewDecoder(req.Body).Decode(&sreq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	source, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		source = req.RemoteAddr
+	}
+	sub := &admission.Submission{
+		Source:   source,
+		Version:  sreq.Version,
+		Runlimit: sreq.Runlimit,
+		Program:  sreq.Program,
+	}
+	var (
+		tx  *bc.Tx
+		dup bool
+	)
+	err = n.gate.Submit(req.Context(), sub, func() error {
+		var err error
+		tx, err = bc.NewTx(sreq.Program, sreq.Version, sreq.Runlimit, bc.NetworkOption(n.chain.InitialBlockHash), txvm.WithPlugins(n.plugins), txvm.WithProgramCache(bc.ProgramCache))
+		if err != nil {
+			return err
+		}
+		err = n.pool.Add(tx)
+		if errors.Root(err) == mempool.ErrDuplicate {
+			dup = true
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		http.Error(w, err.Error(), submitStatus(err))
+		return
+	}
+	if p, _ := n.upstream(); p != nil && !dup {
+		ctx, cancel := context.WithTimeout(req.Context(), 10*time.Second)
+		defer cancel()
+		if err := p.submit(ctx, &sreq); err != nil {
+			log.Error(req.Context(), err, "relaying tx")
+		}
+	}
+	writeJSON(w, map[string]interface{}{
+		"id":             tx.ID,
+		"runlimit_used":  tx.RunlimitUsed,
+		"entry_runlimit": tx.EntryRunlimit,
+	})
+}
+
+func submitStatus(err error) int {
+	if mempool.IsPolicy(err) {
+		return http.StatusForbidden
+	}
+	switch errors.Root(err) {
+	case admission.ErrBanned:
+		return http.StatusForbidden
+	case admission.ErrRateLimited:
+		return http.StatusTooManyRequests
+	case mempool.ErrFull, admission.ErrBusy:
+		return http.StatusServiceUnavailable
+	case mempool.ErrConflict:
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+type dryRunRequest struct {
+	submitRequest
+	Trace bool `json:"trace"`
+}
+
+type dryRunResponse struct {
+	ID            *bc.Hash      `json:"id,omitempty"`
+	Status        int           `json:"status"`
+	Error         string        `json:"error,omitempty"`
+	Fault         *dryRunFault  `json:"fault,omitempty"`
+	RunlimitUsed  int64         `json:"runlimit_used"`
+	EntryRunlimit []int64       `json:"entry_runlimit"`
+	Log           []dryRunEntry `json:"log"`
+	Trace         []dryRunStep  `json:"trace,omitempty"`
+}
+
+type dryRunFault struct {
+	Code     txvm.ErrorCode `json:"code"`
+	PC       int64          `json:"pc"`
+	Opcode   string         `json:"opcode,omitempty"`
+	Contract string         `json:"contract"`
+	Stack    []string       `json:"stack"`
+}
+
+// dryRunEntry is a log entry, in assembly language, with the typed
+// entry it holds, if any (see package logdata).
+type dryRunEntry struct {
+	Data  string        `json:"data"`
+	Type  string        `json:"type,omitempty"`
+	Value logdata.Value `json:"value,omitempty"`
+}
+
+type dryRunStep struct {
+	Depth    int    `json:"depth"`
+	Contract string `json:"contract"`
+	PC       int64  `json:"pc"`
+	Opcode   string `json:"opcode"`
+}
+
+// serveDryRun runs a transaction as /submit would, against the
+// state of the latest block plus the pending transactions, but
+// without adding it to the pool or relaying it. It responds with
+// the status /submit would give and what the run logged.
+func (n *node) serveDryRun(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if n.cfg.Policy != nil && n.cfg.Policy.MaxTxSize > 0 {
+		req.Body = http.MaxBytesReader(w, req.Body, int64(2*n.cfg.Policy.MaxTxSize+1024))
+	}
+	var dreq dryRunRequest
+	err := json.NewDecoder(req.Body).Decode(&dreq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	source, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		source = req.RemoteAddr
+	}
+	sub := &admission.Submission{
+		Source:   source,
+		Version:  dreq.Version,
+		Runlimit: dreq.Runlimit,
+		Program:  dreq.Program,
+	}
+	var (
+		tx    *bc.Tx
+		rec   txvm.Recording
+		txErr error
+	)
+	err = n.gate.Submit(req.Context(), sub, func() error {
+		tx, txErr = bc.NewTx(dreq.Program, dreq.Version, dreq.Runlimit, bc.NetworkOption(n.chain.InitialBlockHash), txvm.WithPlugins(n.plugins), txvm.Record(&rec))
+		if txErr == nil {
+			txErr = n.pool.Check(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), submitStatus(err))
+		return
+	}
+
+	resp := &dryRunResponse{
+		Status:        http.StatusOK,
+		RunlimitUsed:  tx.RunlimitUsed,
+		EntryRunlimit: tx.EntryRunlimit,
+		Log:           []dryRunEntry{},
+	}
+	if tx.Finalized {
+		resp.ID = &tx.ID
+	}
+	if txErr != nil {
+		resp.Status = submitStatus(txErr)
+		resp.Error = txErr.Error()
+	}
+	if f := rec.Fault; f != nil {
+		resp.Fault = &dryRunFault{
+			Code:     f.Code,
+			PC:       f.PC,
+			Contract: contracts.Name(f.Seed),
+			Stack:    f.Stack,
+		}
+		if f.PC >= 0 {
+			resp.Fault.Opcode = opName(f.Opcode)
+		}
+	}
+	for _, item := range rec.Log {
+		entry := dryRunEntry{Data: fmt.Sprintf("%x", txvm.Encode(item))}
+		if s, err := asm.Disassemble(txvm.Encode(item)); err == nil {
+			entry.Data = s
+		}
+		if code, ok := item[0].(txvm.Bytes); ok && len(code) == 1 && code[0] == txvm.LogCode && len(item) >= 3 {
+			if e, err := logdata.Parse(item[2]); err == nil {
+				entry.Type = e.Type
+				entry.Value, _ = logdata.Decode(e)
+			}
+		}
+		resp.Log = append(resp.Log, entry)
+	}
+	if dreq.Trace {
+		for _, s := range rec.Steps {
+			resp.Trace = append(resp.Trace, dryRunStep{
+				Depth:    s.Depth,
+				Contract: contracts.Name(s.Seed),
+				PC:       s.PC,
+				Opcode:   opName(s.Opcode),
+			})
+		}
+	}
+	writeJSON(w, resp)
+}
+
+func opName(opcode byte) string {
+	switch {
+	case op.IsSmallIntOp(opcode):
+		return strconv.Itoa(int(opcode - op.MinSmallInt))
+	case op.IsPushdataOp(opcode):
+		return "pushdata"
+	}
+	return op.Name(opcode)
+}
+
+func (n *node) serveStatus(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, &statusResponse{
+		Network:          n.cfg.Network,
+		Height:           n.chain.Height(),
+		InitialBlockID:   n.chain.InitialBlockHash,
+		BlockVersion:     n.chain.State().Header.Version,
+		Pending:          n.pool.Len(),
+		Submit:           n.gate.Stats(),
+		Follower:         n.follower,
+		ConsensusVersion: consensus.VersionWith(n.plugins),
+		ProgramCache:     bc.ProgramCache.Stats(),
+		Slots:            n.chain.SlotStats(),
+	})
+}
+
+type analyticsResponse struct {
+	Total  analytics.Stats         `json:"total"`
+	Recent *analytics.Stats        `json:"recent,omitempty"`
+	Blocks []*analytics.BlockStats `json:"blocks,omitempty"`
+}
+
+func (n *node) serveAnalytics(w http.ResponseWriter, req *http.Request) {
+	if n.analytics == nil {
+		http.Error(w, "analytics not enabled", http.StatusNotFound)
+		return
+	}
+	resp := &analyticsResponse{Total: n.analytics.Total()}
+	if v := req.FormValue("blocks"); v != "" {
+		count, err := strconv.Atoi(v)
+		if err != nil || count <= 0 {
+			http.Error(w, "bad blocks", http.StatusBadRequest)
+			return
+		}
+		blocks, recent := n.analytics.Recent(count)
+		resp.Blocks, resp.Recent = blocks, &recent
+	}
+	writeJSON(w, resp)
+}
+
+type peersResponse struct {
+	Upstream string `json:"upstream,omitempty"` // the peer a follower follows
+	*admission.Reputation
+}
+
+func (n *node) servePeers(w http.ResponseWriter, req *http.Request) {
+	resp := &peersResponse{Reputation: n.gate.Reputation()}
+	if p, _ := n.upstream(); p != nil {
+		resp.Upstream = p.url
+	}
+	writeJSON(w, resp)
+}
+
+type peerRequest struct {
+	Addr     string             `json:"addr"`
+	Duration chainjson.Duration `json:"duration"` // of a ban; zero for indefinitely
+}
+
+// permanentBan is how long a ban with no duration lasts.
+const permanentBan = 100 * 365 * 24 * time.Hour
+
+// servePeerChange makes a submission source a peer or not, or bans
+// or unbans it, according to the request path, and saves the
+// sources' reputation.
+func (n *node) servePeerChange(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	var preq peerRequest
+	err := json.NewDecoder(req.Body).Decode(&preq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if preq.Addr == "" {
+		http.Error(w, "missing addr", http.StatusBadRequest)
+		return
+	}
+	if preq.Duration.Duration < 0 {
+		http.Error(w, "negative duration", http.StatusBadRequest)
+		return
+	}
+	switch req.URL.Path {
+	case "/peers/add":
+		n.gate.SetPeer(preq.Addr, true)
+	case "/peers/remove":
+		n.gate.SetPeer(preq.Addr, false)
+	case "/peers/ban":
+		d := preq.Duration.Duration
+		if d == 0 {
+			d = permanentBan
+		}
+		n.gate.Ban(preq.Addr, time.Now().Add(d))
+	case "/peers/unban":
+		n.gate.Ban(preq.Addr, time.Time{})
+	}
+	log.Printkv(req.Context(), "event", "peer-change", "op", req.URL.Path, "addr", preq.Addr)
+	n.saveReputation(req.Context())
+	n.servePeers(w, req)
+}
+
+type connectRequest struct {
+	URL string `json:"url"` // the peer's base URL
+}
+
+// serveConnect makes a follower follow the peer at the requested URL
+// in place of the one it followed, if any, once the peer passes the
+// handshake and has the same initial block, and saves the change.
+func (n *node) serveConnect(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !n.follower {
+		http.Error(w, "not a follower", http.StatusConflict)
+		return
+	}
+	var creq connectRequest
+	err := json.NewDecoder(req.Body).Decode(&creq)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if creq.URL == "" {
+		http.Error(w, "missing url", http.StatusBadRequest)
+		return
+	}
+	p := &peer{url: creq.URL, plugins: n.plugins}
+	st, err := p.handshake(req.Context(), n.cfg.net)
+	if err == nil && st.InitialBlockID != n.chain.InitialBlockHash {
+		err = fmt.Errorf("peer has initial block %x, not %x", st.InitialBlockID.Bytes(), n.chain.InitialBlockHash.Bytes())
+	}
+	if err != nil {
+		http.Error(w, errors.Wrapf(err, "checking peer %s", p.url).Error(), http.StatusBadGateway)
+		return
+	}
+	n.setUpstream(req.Context(), p)
+	log.Printkv(req.Context(), "event", "peer-change", "op", req.URL.Path, "url", p.url)
+	n.servePeers(w, req)
+}
+
+// serveDisconnect makes a follower stop following its peer until
+// the next /peers/connect, and saves the change.
+func (n *node) serveDisconnect(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if !n.follower {
+		http.Error(w, "not a follower", http.StatusConflict)
+		return
+	}
+	n.setUpstream(req.Context(), nil)
+	log.Printkv(req.Context(), "event", "peer-change", "op", req.URL.Path)
+	n.servePeers(w, req)
+}
+
+func (n *node) serveGetBlock(w http.ResponseWriter, req *http.Request) {
+	height, err := strconv.ParseUint(req.FormValue("height"), 10, 64)
+	if err != nil || height == 0 {
+		http.Error(w, "bad height", http.StatusBadRequest)
+		return
+	}
+	if height > n.chain.Height() && req.FormValue("wait") != "" {
+		select {
+		case <-n.chain.BlockWaiter(height):
+		case <-time.After(maxWait):
+		case <-req.Context().Done():
+			return
+		}
+	}
+	if height > n.chain.Height() {
+		http.Error(w, fmt.Sprintf("no block at height %d", height), http.StatusNotFound)
+		return
+	}
+	b, err := n.chain.GetBlock(req.Context(), height)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	bits, err := b.Bytes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(bits)
+}
+
+func (n *node) serveGetFilter(w http.ResponseWriter, req *http.Request) {
+	height, err := strconv.ParseUint(req.FormValue("height"), 10, 64)
+	if err != nil || height == 0 || height > n.chain.Height() {
+		http.Error(w, "bad height", http.StatusBadRequest)
+		return
+	}
+	b, err := n.chain.GetBlock(req.Context(), height)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filter := blockfilter.Build(b.UnsignedBlock)
+	if err := blockfilter.Check(b.BlockHeader, filter); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(filter)
+}
+
+type eventsRequest struct {
+	Cursor events.Cursor  `json:"cursor"`
+	Limit  int            `json:"limit"`
+	Filter *events.Filter `json:"filter"`
+}
+
+type eventsResponse struct {
+	Events []events.Event `json:"events"`
+	Cursor events.Cursor  `json:"cursor"`
+}
+
+func (n *node) serveEvents(w http.ResponseWriter, req *http.Request) {
+	var (
+		ereq eventsRequest
+		err  error
+	)
+	if req.Method == "POST" {
+		err = json.NewDecoder(req.Body).Decode(&ereq)
+	} else {
+		err = eventsQuery(req, &ereq)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ereq.Limit == 0 {
+		ereq.Limit = 100
+	}
+	if ereq.Limit < 0 || ereq.Limit > maxEvents {
+		http.Error(w, "bad limit", http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), maxWait)
+	defer cancel()
+	resp := &eventsResponse{Events: make([]events.Event, ereq.Limit), Cursor: ereq.Cursor}
+	num, err := events.New(n.chain, ereq.Cursor, ereq.Filter).Read(ctx, resp.Events)
+	if err != nil && num == 0 && ctx.Err() == nil {
+		status := http.StatusInternalServerError
+		if errors.Root(err) == events.ErrCursor {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	resp.Events = resp.Events[:num]
+	if num > 0 {
+		resp.Cursor = resp.Events[num-1].Cursor
+	}
+	writeJSON(w, resp)
+}
+
+// eventsQuery parses the query parameters of a GET /events request
+// into ereq.
+func eventsQuery(req *http.Request, ereq *eventsRequest) error {
+	var err error
+	ereq.Cursor, err = events.ParseCursor(req.FormValue("cursor"))
+	if err != nil {
+		return err
+	}
+	if s := req.FormValue("limit"); s != "" {
+		ereq.Limit, err = strconv.Atoi(s)
+		if err != nil {
+			return errors.New("bad limit")
+		}
+	}
+	f := new(events.Filter)
+	hexes := func(name string) ([][]byte, error) {
+		var bs [][]byte
+		for _, s := range req.Form[name] {
+			b, err := hex.DecodeString(s)
+			if err != nil {
+				return nil, errors.Wrapf(err, "bad %s", name)
+			}
+			bs = append(bs, b)
+		}
+		return bs, nil
+	}
+	hashes := func(name string) ([]bc.Hash, error) {
+		bs, err := hexes(name)
+		var hs []bc.Hash
+		for _, b := range bs {
+			if len(b) != 32 {
+				return nil, errors.New("bad " + name)
+			}
+			hs = append(hs, bc.HashFromBytes(b))
+		}
+		return hs, err
+	}
+	if f.AssetIDs, err = hashes("asset"); err != nil {
+		return err
+	}
+	if f.Predicates, err = hashes("predicate"); err != nil {
+		return err
+	}
+	for _, s := range req.Form["address"] {
+		a, err := address.Parse(s)
+		if err != nil {
+			return err
+		}
+		f.Predicates = append(f.Predicates, bc.NewHash(standard.PredicateHash(a.Quorum, a.Pubkeys)))
+	}
+	pubkeys, err := hexes("pubkey")
+	if err != nil {
+		return err
+	}
+	for _, pk := range pubkeys {
+		f.Pubkeys = append(f.Pubkeys, pk)
+	}
+	tags, err := hexes("tag")
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		f.TagPrefixes = append(f.TagPrefixes, tag)
+	}
+	if len(f.AssetIDs)+len(f.Predicates)+len(f.Pubkeys)+len(f.TagPrefixes) > 0 || req.FormValue("outputs") != "" {
+		ereq.Filter = f
+	}
+	return nil
+}
+
+func (n *node) serveGetCheckpoint(w http.ResponseWriter, req *http.Request) {
+	if n.cfg.Checkpoints == nil {
+		http.Error(w, "checkpoints not configured", http.StatusNotFound)
+		return
+	}
+	cp := n.chain.Finality().Latest()
+	if cp == nil {
+		http.Error(w, "no checkpoint yet", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, cp)
+}
+
+func (n *node) serveAddCheckpoint(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	if n.cfg.Checkpoints == nil {
+		http.Error(w, "checkpoints not configured", http.StatusNotFound)
+		return
+	}
+	cp := new(checkpoint.Checkpoint)
+	err := json.NewDecoder(req.Body).Decode(cp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = n.chain.AddCheckpoint(req.Context(), cp)
+	if errors.Root(err) == checkpoint.ErrFinalized {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"height": cp.Height()})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,112 @@
+//go:build js && wasm
+// +build js,wasm
+
+/*
+Command txvmwasm is a WebAssembly module exposing txvm assembly,
+validation, and transaction construction to JavaScript.
+
+Build it with:
+
+	GOOS=js GOARCH=wasm go build -o txvm.wasm i10r.io/cmd/txvmwasm
+
+and load it with the wasm_exec.js shim that ships with Go. Once
+running, it installs a global object named txvm with these methods:
+
+	txvm.assemble(src)                                -> {result: progHex}
+	txvm.disassemble(progHex)                         -> {result: src}
+	txvm.validate(progHex, version, limit, network)   -> {result: {finalized, txid, log, runlimit, error}}
+	txvm.txid(progHex, version, limit, network)       -> {result: txidHex}
+	txvm.sign(templateJSON, {keyID: prv})             -> {result: templateJSON}
+	txvm.build(templateJSON)                          -> {result: {txid, program, runlimit}}
+
+Each method returns an object with either a result field or an error
+field holding a message string. All byte strings are hex-encoded.
+The network, the ID of the blockchain's initial block, may be omitted
+for transactions of versions before 5.
+See package i10r.io/protocol/txvm/jsapi for details.
+*/
+package main
+
+import (
+	"syscall/js"
+
+	"i10r.io/protocol/txvm/jsapi"
+)
+
+func main() {
+	js.Global().Set("txvm", js.ValueOf(map[string]interface{}{
+		"assemble": js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+			return reply(jsapi.Assemble(arg(args, 0).String()))
+		}),
+		"disassemble": js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+			return reply(jsapi.Disassemble(arg(args, 0).String()))
+		}),
+		"validate": js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+			res, err := jsapi.Validate(arg(args, 0).String(), int64(arg(args, 1).Int()), int64(arg(args, 2).Int()), optString(arg(args, 3)))
+			if err != nil {
+				return reply(nil, err)
+			}
+			log := make([]interface{}, 0, len(res.Log))
+			for _, entry := range res.Log {
+				log = append(log, entry)
+			}
+			return reply(map[string]interface{}{
+				"finalized": res.Finalized,
+				"txid":      res.TxID,
+				"log":       log,
+				"runlimit":  res.Runlimit,
+				"error":     res.Error,
+			}, nil)
+		}),
+		"txid": js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+			return reply(jsapi.TxID(arg(args, 0).String(), int64(arg(args, 1).Int()), int64(arg(args, 2).Int()), optString(arg(args, 3))))
+		}),
+		"sign": js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+			keys := make(map[string]string)
+			if obj := arg(args, 1); obj.Type() == js.TypeObject {
+				names := js.Global().Get("Object").Call("keys", obj)
+				for i := 0; i < names.Length(); i++ {
+					k := names.Index(i).String()
+					keys[k] = obj.Get(k).String()
+				}
+			}
+			return reply(jsapi.Sign(arg(args, 0).String(), keys))
+		}),
+		"build": js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+			txid, prog, runlimit, err := jsapi.Build(arg(args, 0).String())
+			if err != nil {
+				return reply(nil, err)
+			}
+			return reply(map[string]interface{}{
+				"txid":     txid,
+				"program":  prog,
+				"runlimit": runlimit,
+			}, nil)
+		}),
+	}))
+
+	// Keep the Go runtime alive so the callbacks remain valid.
+	select {}
+}
+
+func arg(args []js.Value, i int) js.Value {
+	if i < len(args) {
+		return args[i]
+	}
+	return js.Undefined()
+}
+
+// optString returns the string v, or "" if v is undefined or null.
+func optString(v js.Value) string {
+	if v.IsUndefined() || v.IsNull() {
+		return ""
+	}
+	return v.String()
+}
+
+func reply(result interface{}, err error) interface{} {
+	if err != nil {
+		return map[string]interface{}{"error": err.Error()}
+	}
+	return map[string]interface{}{"result": result}
+}
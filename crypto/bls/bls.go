@@ -0,0 +1,245 @@
+// Package bls implements BLS signatures over the BLS12-381 curve,
+// with public keys in G1 and signatures in G2.
+//
+// Signatures on the same message aggregate into one
+// (FastAggregateVerify), as do signatures on distinct messages
+// (AggregateVerify), and a Batch checks many independent signatures
+// with a single final exponentiation. Point encodings are the
+// compressed forms used by Zcash and Ethereum, and decoding checks
+// subgroup membership with the endomorphism tests of Bowe and Scott.
+// Messages are hashed to G2 as in RFC 9380, and signatures and proofs
+// of possession are those of the proof-of-possession ciphersuite
+// BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_ of the IETF BLS
+// signature draft, so they interoperate with other libraries using it.
+//
+// The arithmetic is written with math/big for clarity. It is neither
+// fast nor constant-time: Sign must not be used where timing is
+// observable.
+package bls
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	"i10r.io/errors"
+)
+
+// Sizes of encodings.
+const (
+	PublicKeySize  = 48
+	SignatureSize  = 96
+	PrivateKeySize = 32
+)
+
+var (
+	// ErrEncoding is returned for a malformed point encoding, or
+	// one of a point not in its subgroup.
+	ErrEncoding = errors.New("invalid BLS12-381 point encoding")
+
+	// ErrPrivateKey is returned for a private key out of range.
+	ErrPrivateKey = errors.New("invalid BLS private key")
+)
+
+var (
+	sigDST = []byte("BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_")
+	popDST = []byte("BLS_POP_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_")
+)
+
+// PublicKey is a point of G1 other than the identity.
+type PublicKey struct {
+	p g1
+}
+
+// Signature is a point of G2.
+type Signature struct {
+	p g2
+}
+
+// PrivateKey is a scalar in [1, r-1].
+type PrivateKey struct {
+	s   *big.Int
+	pub PublicKey
+}
+
+// GenerateKey returns a new private key using randomness from rnd,
+// or from crypto/rand if rnd is nil.
+func GenerateKey(rnd io.Reader) (*PrivateKey, error) {
+	s, err := randScalar(rnd, r)
+	if err != nil {
+		return nil, err
+	}
+	return newPrivateKey(s), nil
+}
+
+// NewPrivateKey decodes a 32-byte big-endian private key.
+func NewPrivateKey(b []byte) (*PrivateKey, error) {
+	if len(b) != PrivateKeySize {
+		return nil, ErrPrivateKey
+	}
+	s := new(big.Int).SetBytes(b)
+	if s.Sign() == 0 || s.Cmp(r) >= 0 {
+		return nil, ErrPrivateKey
+	}
+	return newPrivateKey(s), nil
+}
+
+func newPrivateKey(s *big.Int) *PrivateKey {
+	return &PrivateKey{s: s, pub: PublicKey{g1Gen.mul(s)}}
+}
+
+// Bytes returns the 32-byte encoding of priv.
+func (priv *PrivateKey) Bytes() []byte {
+	b := make([]byte, PrivateKeySize)
+	return priv.s.FillBytes(b)
+}
+
+// Public returns priv's public key.
+func (priv *PrivateKey) Public() *PublicKey {
+	return &priv.pub
+}
+
+// Sign signs msg.
+func (priv *PrivateKey) Sign(msg []byte) *Signature {
+	return &Signature{hashToG2(sigDST, msg).mul(priv.s)}
+}
+
+// ProvePossession returns a proof that the holder of priv knows it,
+// to be checked with VerifyPossession before priv's public key is
+// aggregated with FastAggregateVerify.
+func (priv *PrivateKey) ProvePossession() *Signature {
+	return &Signature{hashToG2(popDST, priv.pub.Bytes()).mul(priv.s)}
+}
+
+// Verify reports whether sig is pub's signature of msg.
+func Verify(pub *PublicKey, msg []byte, sig *Signature) bool {
+	return pairingCheck(
+		[]g1{g1Gen.neg(), pub.p},
+		[]g2{sig.p, hashToG2(sigDST, msg)},
+	)
+}
+
+// VerifyPossession reports whether proof shows possession of the
+// private key for pub.
+func VerifyPossession(pub *PublicKey, proof *Signature) bool {
+	return pairingCheck(
+		[]g1{g1Gen.neg(), pub.p},
+		[]g2{proof.p, hashToG2(popDST, pub.Bytes())},
+	)
+}
+
+// AggregateSignatures combines signatures into one.
+func AggregateSignatures(sigs ...*Signature) *Signature {
+	agg := g2{inf: true}
+	for _, sig := range sigs {
+		agg = agg.add(sig.p)
+	}
+	return &Signature{agg}
+}
+
+// AggregatePublicKeys combines public keys into one, which verifies
+// the aggregate of their signatures on a common message. It returns
+// nil if there are no keys or they sum to the identity.
+//
+// The keys' possession must have been proven (see VerifyPossession):
+// otherwise one signer can choose a key that cancels out the others.
+func AggregatePublicKeys(pubs ...*PublicKey) *PublicKey {
+	agg := g1{inf: true}
+	for _, pub := range pubs {
+		agg = agg.add(pub.p)
+	}
+	if agg.inf {
+		return nil
+	}
+	return &PublicKey{agg}
+}
+
+// FastAggregateVerify reports whether sig is the aggregate of the
+// signatures by each of pubs on msg. See AggregatePublicKeys.
+func FastAggregateVerify(pubs []*PublicKey, msg []byte, sig *Signature) bool {
+	agg := AggregatePublicKeys(pubs...)
+	return agg != nil && Verify(agg, msg, sig)
+}
+
+// AggregateVerify reports whether sig is the aggregate of signatures
+// by pubs[i] on msgs[i]. The messages must be distinct.
+func AggregateVerify(pubs []*PublicKey, msgs [][]byte, sig *Signature) bool {
+	if len(pubs) != len(msgs) || len(pubs) == 0 {
+		return false
+	}
+	seen := make(map[string]bool)
+	as := []g1{g1Gen.neg()}
+	bs := []g2{sig.p}
+	for i, msg := range msgs {
+		if seen[string(msg)] {
+			return false
+		}
+		seen[string(msg)] = true
+		as = append(as, pubs[i].p)
+		bs = append(bs, hashToG2(sigDST, msg))
+	}
+	return pairingCheck(as, bs)
+}
+
+// Batch accumulates signatures to verify together. Checking n
+// signatures as a batch costs n+1 Miller loops and one final
+// exponentiation, rather than 2n of each. The zero value is an empty
+// Batch.
+type Batch struct {
+	pubs []*PublicKey
+	msgs [][]byte
+	sigs []*Signature
+}
+
+// Add adds a signature to b.
+func (b *Batch) Add(pub *PublicKey, msg []byte, sig *Signature) {
+	b.pubs = append(b.pubs, pub)
+	b.msgs = append(b.msgs, msg)
+	b.sigs = append(b.sigs, sig)
+}
+
+// Len returns the number of signatures in b.
+func (b *Batch) Len() int {
+	return len(b.pubs)
+}
+
+// Verify reports whether every signature in b is valid, except with
+// negligible probability. It weights each signature by a random
+// 128-bit scalar from rnd (crypto/rand if nil), so that invalid
+// signatures cannot cancel each other out.
+func (b *Batch) Verify(rnd io.Reader) (bool, error) {
+	if len(b.pubs) == 0 {
+		return true, nil
+	}
+	as := []g1{g1Gen.neg()}
+	bs := []g2{{inf: true}}
+	lim := new(big.Int).Lsh(big.NewInt(1), 128)
+	for i := range b.pubs {
+		c, err := randScalar(rnd, lim)
+		if err != nil {
+			return false, err
+		}
+		as = append(as, b.pubs[i].p.mul(c))
+		bs = append(bs, hashToG2(sigDST, b.msgs[i]))
+		bs[0] = bs[0].add(b.sigs[i].p.mul(c))
+	}
+	return pairingCheck(as, bs), nil
+}
+
+// randScalar returns a random integer in [1, n-1].
+func randScalar(rnd io.Reader, n *big.Int) (*big.Int, error) {
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	var buf [64]byte
+	for {
+		if _, err := io.ReadFull(rnd, buf[:]); err != nil {
+			return nil, errors.Wrap(err, "reading randomness")
+		}
+		s := new(big.Int).SetBytes(buf[:])
+		s.Mod(s, n)
+		if s.Sign() != 0 {
+			return s, nil
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package bls
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestEncoding(t *testing.T) {
+	pub := &PublicKey{g1Gen}
+	want := "97f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb"
+	if got := hex.EncodeToString(pub.Bytes()); got != want {
+		t.Errorf("G1 generator encodes as %s, want %s", got, want)
+	}
+	sig := &Signature{g2Gen}
+	want = "93e02b6052719f607dacd3a088274f65596bd0d09920b61ab5da61bbdc7f5049334cf11213945d57e5ac7d055d042b7e024aa2b2f08f0a91260805272dc51051c6e47ad4fa403b02b4510b647ae3d1770bac0326a805bbefd48056c8c121bdb8"
+	if got := hex.EncodeToString(sig.Bytes()); got != want {
+		t.Errorf("G2 generator encodes as %s, want %s", got, want)
+	}
+
+	priv, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pt := range []*PublicKey{pub, priv.Public(), {g1Gen.neg()}} {
+		got, err := ParsePublicKey(pt.Bytes())
+		if err != nil || !got.p.equal(pt.p) {
+			t.Errorf("ParsePublicKey(%x) = %v, %v", pt.Bytes(), got, err)
+		}
+	}
+	for _, s := range []*Signature{sig, priv.Sign([]byte("x")), {g2Gen.neg()}, {g2{inf: true}}} {
+		got, err := ParseSignature(s.Bytes())
+		if err != nil || !got.p.equal(s.p) {
+			t.Errorf("ParseSignature(%x) = %v, %v", s.Bytes(), got, err)
+		}
+	}
+
+	// A point on E' outside G2.
+	bad := (&Signature{mapToE2(hashToField([]byte("test"), []byte("point"))[0])}).Bytes()
+	if _, err := ParseSignature(bad); err != ErrEncoding {
+		t.Errorf("ParseSignature(non-subgroup point) error = %v, want ErrEncoding", err)
+	}
+	if _, err := ParsePublicKey(pub.Bytes()[1:]); err != ErrEncoding {
+		t.Errorf("ParsePublicKey(short) error = %v, want ErrEncoding", err)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	priv, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := NewPrivateKey(priv.Bytes())
+	if err != nil || !bytes.Equal(again.Public().Bytes(), priv.Public().Bytes()) {
+		t.Fatalf("NewPrivateKey(Bytes()) = %v, %v", again, err)
+	}
+	msg := []byte("hello")
+	sig := priv.Sign(msg)
+	if !Verify(priv.Public(), msg, sig) {
+		t.Error("valid signature does not verify")
+	}
+	if Verify(priv.Public(), []byte("hellp"), sig) {
+		t.Error("signature verifies for the wrong message")
+	}
+	if !VerifyPossession(priv.Public(), priv.ProvePossession()) {
+		t.Error("proof of possession does not verify")
+	}
+	if VerifyPossession(priv.Public(), sig) {
+		t.Error("message signature verifies as proof of possession")
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	var (
+		privs []*PrivateKey
+		pubs  []*PublicKey
+		msgs  [][]byte
+	)
+	for i := 0; i < 3; i++ {
+		priv, err := GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs = append(privs, priv)
+		pubs = append(pubs, priv.Public())
+		msgs = append(msgs, []byte{byte(i)})
+	}
+
+	msg := []byte("block 7")
+	var same, distinct []*Signature
+	var batch Batch
+	for i, priv := range privs {
+		same = append(same, priv.Sign(msg))
+		sig := priv.Sign(msgs[i])
+		distinct = append(distinct, sig)
+		batch.Add(pubs[i], msgs[i], sig)
+	}
+	if !FastAggregateVerify(pubs, msg, AggregateSignatures(same...)) {
+		t.Error("FastAggregateVerify rejects a valid aggregate")
+	}
+	if FastAggregateVerify(pubs[:2], msg, AggregateSignatures(same...)) {
+		t.Error("FastAggregateVerify accepts an aggregate over the wrong keys")
+	}
+	if !AggregateVerify(pubs, msgs, AggregateSignatures(distinct...)) {
+		t.Error("AggregateVerify rejects a valid aggregate")
+	}
+	if AggregateVerify(pubs, [][]byte{msgs[0], msgs[0], msgs[2]}, AggregateSignatures(distinct...)) {
+		t.Error("AggregateVerify accepts repeated messages")
+	}
+	if ok, err := batch.Verify(nil); err != nil || !ok {
+		t.Errorf("Batch.Verify = %v, %v; want true", ok, err)
+	}
+	batch.sigs[1], batch.sigs[2] = batch.sigs[2], batch.sigs[1]
+	if ok, _ := batch.Verify(nil); ok {
+		t.Error("Batch.Verify accepts swapped signatures")
+	}
+}
+
+func BenchmarkSign(b *testing.B) {
+	priv, err := GenerateKey(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg := []byte("hello")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		priv.Sign(msg)
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	priv, err := GenerateKey(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg := []byte("hello")
+	sig := priv.Sign(msg)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !Verify(priv.Public(), msg, sig) {
+			b.Fatal("valid signature does not verify")
+		}
+	}
+}
+
+func BenchmarkFastAggregateVerify16(b *testing.B) {
+	msg := []byte("hello")
+	var (
+		pubs []*PublicKey
+		sigs []*Signature
+	)
+	for i := 0; i < 16; i++ {
+		priv, err := GenerateKey(nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		pubs = append(pubs, priv.Public())
+		sigs = append(sigs, priv.Sign(msg))
+	}
+	sig := AggregateSignatures(sigs...)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !FastAggregateVerify(pubs, msg, sig) {
+			b.Fatal("valid signature does not verify")
+		}
+	}
+}
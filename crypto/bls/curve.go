@@ -0,0 +1,241 @@
+package bls
+
+import "math/big"
+
+var (
+	// r is the order of G1, G2, and GT.
+	r = fromHex("73eda753299d7d483339d80809a1d80553bda402fffe5bfeffffffff00000001")
+
+	// curveX is the curve parameter; it is negative.
+	curveX = new(big.Int).Neg(fromHex("d201000000010000"))
+
+	g1Gen = g1{
+		x: fromHex("17f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb"),
+		y: fromHex("08b3f481e3aaa0f1a09e30ed741d8ae4fcf5e095d5d00af600db18cb2c04b3edd03cc744a2888ae40caa232946c5e7e1"),
+	}
+	g2Gen = g2{
+		x: fp2{
+			fromHex("024aa2b2f08f0a91260805272dc51051c6e47ad4fa403b02b4510b647ae3d1770bac0326a805bbefd48056c8c121bdb8"),
+			fromHex("13e02b6052719f607dacd3a088274f65596bd0d09920b61ab5da61bbdc7f5049334cf11213945d57e5ac7d055d042b7e"),
+		},
+		y: fp2{
+			fromHex("0ce5d527727d6e118cc9cdc6da2e351aadfd9baa8cbdd3a76d429a695160d12c923ac9cc3baca289e193548608b82801"),
+			fromHex("0606c4a02ea734cc32acd2b02bc28b99cb3e287e85a763af267492ab572e99ab3f370d275cec1da1aaa9075ff05f79be"),
+		},
+	}
+
+	b1 = big.NewInt(4)
+	b2 = newFp2(4, 4) // 4ξ
+
+	// h2 is the cofactor of G2 in E'(Fp2),
+	// (x⁸ - 4x⁷ + 5x⁶ - 4x⁴ + 6x³ - 4x² - 4x + 13)/9.
+	h2 *big.Int
+
+	// beta is the cube root of unity for which (x, y) ↦ (βx, y) is
+	// multiplication by -x² on G1.
+	beta *big.Int
+
+	// psiX and psiY are 1/ξ^((p-1)/3) and 1/ξ^((p-1)/2), the
+	// constants of the endomorphism ψ of E'.
+	psiX, psiY fp2
+)
+
+func init() {
+	coef := []int64{13, -4, -4, 6, -4, 0, 5, -4, 1}
+	h2 = new(big.Int)
+	for i := len(coef) - 1; i >= 0; i-- {
+		h2.Mul(h2, curveX)
+		h2.Add(h2, big.NewInt(coef[i]))
+	}
+	h2.Div(h2, big.NewInt(9))
+
+	// Find a primitive cube root of unity by cubing out everything
+	// else from small bases, then pick the right one of it and its
+	// square.
+	e := new(big.Int).Div(new(big.Int).Sub(p, big.NewInt(1)), big.NewInt(3))
+	for g := int64(2); beta == nil; g++ {
+		c := new(big.Int).Exp(big.NewInt(g), e, p)
+		if c.Cmp(big.NewInt(1)) != 0 {
+			beta = c
+		}
+	}
+	want := g1Gen.mul(new(big.Int).Neg(new(big.Int).Mul(curveX, curveX)))
+	if !(g1{x: fpMul(g1Gen.x, beta), y: g1Gen.y}).equal(want) {
+		beta = fpMul(beta, beta)
+	}
+
+	xi := fp2One.mulXi()
+	psiX = xi.exp(new(big.Int).Div(new(big.Int).Sub(p, big.NewInt(1)), big.NewInt(3))).inv()
+	psiY = xi.exp(new(big.Int).Div(new(big.Int).Sub(p, big.NewInt(1)), big.NewInt(2))).inv()
+}
+
+// g1 is a point on E: y² = x³ + 4 over Fp, in affine coordinates.
+type g1 struct {
+	x, y *big.Int
+	inf  bool
+}
+
+func (a g1) onCurve() bool {
+	if a.inf {
+		return true
+	}
+	if a.x.Cmp(p) >= 0 || a.y.Cmp(p) >= 0 {
+		return false
+	}
+	rhs := fpAdd(fpMul(fpMul(a.x, a.x), a.x), b1)
+	return fpMul(a.y, a.y).Cmp(rhs) == 0
+}
+
+func (a g1) equal(b g1) bool {
+	if a.inf || b.inf {
+		return a.inf == b.inf
+	}
+	return a.x.Cmp(b.x) == 0 && a.y.Cmp(b.y) == 0
+}
+
+func (a g1) neg() g1 {
+	if a.inf {
+		return a
+	}
+	return g1{x: a.x, y: fpNeg(a.y)}
+}
+
+func (a g1) add(b g1) g1 {
+	switch {
+	case a.inf:
+		return b
+	case b.inf:
+		return a
+	case a.x.Cmp(b.x) == 0:
+		if a.y.Cmp(b.y) == 0 {
+			return a.double()
+		}
+		return g1{inf: true}
+	}
+	l := fpMul(fpSub(b.y, a.y), fpInv(fpSub(b.x, a.x)))
+	x3 := fpSub(fpSub(fpMul(l, l), a.x), b.x)
+	return g1{x: x3, y: fpSub(fpMul(l, fpSub(a.x, x3)), a.y)}
+}
+
+func (a g1) double() g1 {
+	if a.inf || a.y.Sign() == 0 {
+		return g1{inf: true}
+	}
+	xx := fpMul(a.x, a.x)
+	l := fpMul(fpAdd(fpAdd(xx, xx), xx), fpInv(fpAdd(a.y, a.y)))
+	x3 := fpSub(fpMul(l, l), fpAdd(a.x, a.x))
+	return g1{x: x3, y: fpSub(fpMul(l, fpSub(a.x, x3)), a.y)}
+}
+
+func (a g1) mul(k *big.Int) g1 {
+	res := g1{inf: true}
+	n := new(big.Int).Abs(k)
+	for i := n.BitLen() - 1; i >= 0; i-- {
+		res = res.double()
+		if n.Bit(i) == 1 {
+			res = res.add(a)
+		}
+	}
+	if k.Sign() < 0 {
+		res = res.neg()
+	}
+	return res
+}
+
+// inSubgroup tells whether a, a point on E, is in G1, using the check
+// (βx, y) = -x²·a of Bowe, "Faster subgroup checks for BLS12-381."
+func (a g1) inSubgroup() bool {
+	if a.inf {
+		return true
+	}
+	sigma := g1{x: fpMul(a.x, beta), y: a.y}
+	return sigma.equal(a.mul(new(big.Int).Neg(new(big.Int).Mul(curveX, curveX))))
+}
+
+// g2 is a point on E': y² = x³ + 4ξ over Fp2, in affine coordinates.
+type g2 struct {
+	x, y fp2
+	inf  bool
+}
+
+func (a g2) onCurve() bool {
+	if a.inf {
+		return true
+	}
+	for _, c := range []*big.Int{a.x.c0, a.x.c1, a.y.c0, a.y.c1} {
+		if c.Cmp(p) >= 0 {
+			return false
+		}
+	}
+	return a.y.square().equal(a.x.square().mul(a.x).add(b2))
+}
+
+func (a g2) equal(b g2) bool {
+	if a.inf || b.inf {
+		return a.inf == b.inf
+	}
+	return a.x.equal(b.x) && a.y.equal(b.y)
+}
+
+func (a g2) neg() g2 {
+	if a.inf {
+		return a
+	}
+	return g2{x: a.x, y: a.y.neg()}
+}
+
+func (a g2) add(b g2) g2 {
+	switch {
+	case a.inf:
+		return b
+	case b.inf:
+		return a
+	case a.x.equal(b.x):
+		if a.y.equal(b.y) {
+			return a.double()
+		}
+		return g2{inf: true}
+	}
+	l := b.y.sub(a.y).mul(b.x.sub(a.x).inv())
+	x3 := l.square().sub(a.x).sub(b.x)
+	return g2{x: x3, y: l.mul(a.x.sub(x3)).sub(a.y)}
+}
+
+func (a g2) double() g2 {
+	if a.inf || a.y.isZero() {
+		return g2{inf: true}
+	}
+	xx := a.x.square()
+	l := xx.add(xx).add(xx).mul(a.y.add(a.y).inv())
+	x3 := l.square().sub(a.x.add(a.x))
+	return g2{x: x3, y: l.mul(a.x.sub(x3)).sub(a.y)}
+}
+
+func (a g2) mul(k *big.Int) g2 {
+	res := g2{inf: true}
+	n := new(big.Int).Abs(k)
+	for i := n.BitLen() - 1; i >= 0; i-- {
+		res = res.double()
+		if n.Bit(i) == 1 {
+			res = res.add(a)
+		}
+	}
+	if k.Sign() < 0 {
+		res = res.neg()
+	}
+	return res
+}
+
+// psi is the untwist-Frobenius-twist endomorphism of E'.
+func (a g2) psi() g2 {
+	if a.inf {
+		return a
+	}
+	return g2{x: a.x.conj().mul(psiX), y: a.y.conj().mul(psiY)}
+}
+
+// inSubgroup tells whether a, a point on E', is in G2, using the
+// check ψ(a) = x·a.
+func (a g2) inSubgroup() bool {
+	return a.psi().equal(a.mul(curveX))
+}
@@ -0,0 +1,101 @@
+package bls
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestGenerators(t *testing.T) {
+	if !g1Gen.onCurve() || !g1Gen.mul(r).inf {
+		t.Error("G1 generator is not a point of order r")
+	}
+	if !g2Gen.onCurve() || !g2Gen.mul(r).inf {
+		t.Error("G2 generator is not a point of order r")
+	}
+	if !g1Gen.inSubgroup() || !g2Gen.inSubgroup() {
+		t.Error("generators fail the subgroup check")
+	}
+}
+
+func TestSubgroupCheck(t *testing.T) {
+	// Points of E and E' found by hashing are almost never in G1 or
+	// G2 before their cofactors are cleared.
+	q := mapToE2(hashToField([]byte("test"), []byte("point"))[0])
+	if !q.onCurve() {
+		t.Fatal("mapped point is not on E'")
+	}
+	if q.inSubgroup() {
+		t.Error("point outside G2 passes the subgroup check")
+	}
+	if !q.mul(h2).inSubgroup() {
+		t.Error("point with cleared cofactor fails the subgroup check")
+	}
+	if !q.mul(h2).mul(r).inf {
+		t.Error("h2·r does not annihilate E'(Fp2)")
+	}
+
+	var pt g1
+	for i := int64(1); ; i++ {
+		xc := big.NewInt(i)
+		y := new(big.Int).ModSqrt(fpAdd(fpMul(fpMul(xc, xc), xc), b1), p)
+		if y != nil {
+			pt = g1{x: xc, y: y}
+			break
+		}
+	}
+	if pt.inSubgroup() {
+		t.Error("point outside G1 passes the subgroup check")
+	}
+	h1 := new(big.Int).Sub(curveX, big.NewInt(1))
+	h1.Mul(h1, h1).Div(h1, big.NewInt(3))
+	if !pt.mul(h1).inSubgroup() {
+		t.Error("point with cleared cofactor fails the subgroup check")
+	}
+}
+
+func TestFrob(t *testing.T) {
+	a := fp12{
+		fp6{newFp2(1, 2), newFp2(3, 4), newFp2(5, 6)},
+		fp6{newFp2(7, 8), newFp2(9, 10), newFp2(11, 12)},
+	}
+	if !a.frob().equal(a.exp(p)) {
+		t.Error("frob(a) != a^p")
+	}
+}
+
+func TestBilinearity(t *testing.T) {
+	a, _ := rand.Int(rand.Reader, r)
+	b, _ := rand.Int(rand.Reader, r)
+	e := pairing(g1Gen, g2Gen)
+	if e.isOne() {
+		t.Fatal("pairing is degenerate")
+	}
+	if !e.exp(r).isOne() {
+		t.Error("e(G1, G2) does not have order r")
+	}
+	ab := new(big.Int).Mul(a, b)
+	if !pairing(g1Gen.mul(a), g2Gen.mul(b)).equal(e.exp(ab)) {
+		t.Error("e(aP, bQ) != e(P, Q)^ab")
+	}
+	if !pairingCheck([]g1{g1Gen.mul(a), g1Gen.neg()}, []g2{g2Gen, g2Gen.mul(a)}) {
+		t.Error("e(aP, Q)·e(-P, aQ) != 1")
+	}
+}
+
+func TestCycSquare(t *testing.T) {
+	a := fp12{
+		fp6{newFp2(1, 2), newFp2(3, 4), newFp2(5, 6)},
+		fp6{newFp2(7, 8), newFp2(9, 10), newFp2(11, 12)},
+	}
+	// Raise a to the power (p⁶ - 1)(p² + 1), into the cyclotomic
+	// subgroup.
+	a = a.conj().mul(a.inv())
+	a = a.frob().frob().mul(a)
+	if !a.cycSquare().equal(a.mul(a)) {
+		t.Error("cycSquare(a) != a·a")
+	}
+	if !a.square().equal(a.mul(a)) {
+		t.Error("square(a) != a·a")
+	}
+}
@@ -0,0 +1,146 @@
+package bls
+
+import "math/big"
+
+// Flags in the first byte of a compressed point.
+const (
+	flagCompressed = 0x80
+	flagInfinity   = 0x40
+	flagSign       = 0x20
+	flagMask       = 0xe0
+)
+
+func encodeG1(a g1) []byte {
+	b := make([]byte, PublicKeySize)
+	if a.inf {
+		b[0] = flagCompressed | flagInfinity
+		return b
+	}
+	a.x.FillBytes(b)
+	b[0] |= flagCompressed
+	if a.y.Cmp(pMinus1Over2) > 0 {
+		b[0] |= flagSign
+	}
+	return b
+}
+
+// decodeG1 decodes a compressed point, checking that it is in G1.
+func decodeG1(b []byte) (g1, error) {
+	if len(b) != PublicKeySize || b[0]&flagCompressed == 0 {
+		return g1{}, ErrEncoding
+	}
+	if b[0]&flagInfinity != 0 {
+		if !isInfinity(b) {
+			return g1{}, ErrEncoding
+		}
+		return g1{inf: true}, nil
+	}
+	xb := append([]byte{}, b...)
+	xb[0] &^= flagMask
+	xc := new(big.Int).SetBytes(xb)
+	if xc.Cmp(p) >= 0 {
+		return g1{}, ErrEncoding
+	}
+	y := new(big.Int).ModSqrt(fpAdd(fpMul(fpMul(xc, xc), xc), b1), p)
+	if y == nil {
+		return g1{}, ErrEncoding
+	}
+	if (y.Cmp(pMinus1Over2) > 0) != (b[0]&flagSign != 0) {
+		y = fpNeg(y)
+	}
+	pt := g1{x: xc, y: y}
+	if !pt.inSubgroup() {
+		return g1{}, ErrEncoding
+	}
+	return pt, nil
+}
+
+func encodeG2(a g2) []byte {
+	b := make([]byte, SignatureSize)
+	if a.inf {
+		b[0] = flagCompressed | flagInfinity
+		return b
+	}
+	a.x.c1.FillBytes(b[:48])
+	a.x.c0.FillBytes(b[48:])
+	b[0] |= flagCompressed
+	if a.y.sign() {
+		b[0] |= flagSign
+	}
+	return b
+}
+
+// decodeG2 decodes a compressed point, checking that it is in G2.
+func decodeG2(b []byte) (g2, error) {
+	if len(b) != SignatureSize || b[0]&flagCompressed == 0 {
+		return g2{}, ErrEncoding
+	}
+	if b[0]&flagInfinity != 0 {
+		if !isInfinity(b) {
+			return g2{}, ErrEncoding
+		}
+		return g2{inf: true}, nil
+	}
+	xb := append([]byte{}, b...)
+	xb[0] &^= flagMask
+	xc := fp2{new(big.Int).SetBytes(xb[48:]), new(big.Int).SetBytes(xb[:48])}
+	if xc.c0.Cmp(p) >= 0 || xc.c1.Cmp(p) >= 0 {
+		return g2{}, ErrEncoding
+	}
+	y, ok := xc.square().mul(xc).add(b2).sqrt()
+	if !ok {
+		return g2{}, ErrEncoding
+	}
+	if y.sign() != (b[0]&flagSign != 0) {
+		y = y.neg()
+	}
+	pt := g2{x: xc, y: y}
+	if !pt.inSubgroup() {
+		return g2{}, ErrEncoding
+	}
+	return pt, nil
+}
+
+// isInfinity tells whether b is the encoding of the identity: the
+// compressed and infinity flags, then all zeroes.
+func isInfinity(b []byte) bool {
+	if b[0] != flagCompressed|flagInfinity {
+		return false
+	}
+	for _, c := range b[1:] {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Bytes returns the 48-byte compressed encoding of pub.
+func (pub *PublicKey) Bytes() []byte {
+	return encodeG1(pub.p)
+}
+
+// ParsePublicKey decodes a compressed public key, checking that it
+// is in G1 and is not the identity.
+func ParsePublicKey(b []byte) (*PublicKey, error) {
+	pt, err := decodeG1(b)
+	if err != nil || pt.inf {
+		return nil, ErrEncoding
+	}
+	return &PublicKey{pt}, nil
+}
+
+// Bytes returns the 96-byte compressed encoding of sig.
+func (sig *Signature) Bytes() []byte {
+	return encodeG2(sig.p)
+}
+
+// ParseSignature decodes a compressed signature, checking that it is
+// in G2.
+func ParseSignature(b []byte) (*Signature, error) {
+	pt, err := decodeG2(b)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{pt}, nil
+}
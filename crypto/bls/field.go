@@ -0,0 +1,296 @@
+package bls
+
+import "math/big"
+
+// The base field Fp and its extensions Fp2 = Fp[u]/(u²+1),
+// Fp6 = Fp2[v]/(v³-ξ) with ξ = u+1, and Fp12 = Fp6[w]/(w²-v).
+// Elements are immutable values; every operation returns a new one.
+
+var (
+	p = fromHex("1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaaab")
+
+	// pMinus1Over2 is the largest "small" coordinate, for the sign
+	// bit of the compressed encoding.
+	pMinus1Over2 = new(big.Int).Rsh(new(big.Int).Sub(p, big.NewInt(1)), 1)
+
+	inv2 = new(big.Int).Add(pMinus1Over2, big.NewInt(1)) // 1/2
+)
+
+func fromHex(s string) *big.Int {
+	x, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("bls: bad constant " + s)
+	}
+	return x
+}
+
+func fpAdd(a, b *big.Int) *big.Int {
+	r := new(big.Int).Add(a, b)
+	if r.Cmp(p) >= 0 {
+		r.Sub(r, p)
+	}
+	return r
+}
+
+func fpSub(a, b *big.Int) *big.Int {
+	r := new(big.Int).Sub(a, b)
+	if r.Sign() < 0 {
+		r.Add(r, p)
+	}
+	return r
+}
+
+func fpMul(a, b *big.Int) *big.Int {
+	r := new(big.Int).Mul(a, b)
+	return r.Mod(r, p)
+}
+
+func fpNeg(a *big.Int) *big.Int {
+	if a.Sign() == 0 {
+		return new(big.Int)
+	}
+	return new(big.Int).Sub(p, a)
+}
+
+func fpInv(a *big.Int) *big.Int {
+	return new(big.Int).ModInverse(a, p)
+}
+
+// fp2 is c0 + c1·u.
+type fp2 struct {
+	c0, c1 *big.Int
+}
+
+func newFp2(c0, c1 int64) fp2 {
+	return fp2{big.NewInt(c0), big.NewInt(c1)}
+}
+
+var (
+	fp2Zero = newFp2(0, 0)
+	fp2One  = newFp2(1, 0)
+)
+
+func (a fp2) add(b fp2) fp2 { return fp2{fpAdd(a.c0, b.c0), fpAdd(a.c1, b.c1)} }
+func (a fp2) sub(b fp2) fp2 { return fp2{fpSub(a.c0, b.c0), fpSub(a.c1, b.c1)} }
+func (a fp2) neg() fp2      { return fp2{fpNeg(a.c0), fpNeg(a.c1)} }
+func (a fp2) conj() fp2     { return fp2{a.c0, fpNeg(a.c1)} }
+
+func (a fp2) mul(b fp2) fp2 {
+	t0 := fpMul(a.c0, b.c0)
+	t1 := fpMul(a.c1, b.c1)
+	c1 := fpMul(fpAdd(a.c0, a.c1), fpAdd(b.c0, b.c1))
+	return fp2{fpSub(t0, t1), fpSub(fpSub(c1, t0), t1)}
+}
+
+func (a fp2) square() fp2 {
+	c1 := fpMul(a.c0, a.c1)
+	return fp2{fpMul(fpAdd(a.c0, a.c1), fpSub(a.c0, a.c1)), fpAdd(c1, c1)}
+}
+
+func (a fp2) mulFp(k *big.Int) fp2 { return fp2{fpMul(a.c0, k), fpMul(a.c1, k)} }
+
+// mulXi multiplies by ξ = 1+u.
+func (a fp2) mulXi() fp2 { return fp2{fpSub(a.c0, a.c1), fpAdd(a.c0, a.c1)} }
+
+func (a fp2) inv() fp2 {
+	t := fpInv(fpAdd(fpMul(a.c0, a.c0), fpMul(a.c1, a.c1)))
+	return fp2{fpMul(a.c0, t), fpNeg(fpMul(a.c1, t))}
+}
+
+func (a fp2) isZero() bool     { return a.c0.Sign() == 0 && a.c1.Sign() == 0 }
+func (a fp2) equal(b fp2) bool { return a.c0.Cmp(b.c0) == 0 && a.c1.Cmp(b.c1) == 0 }
+
+func (a fp2) exp(e *big.Int) fp2 {
+	r := fp2One
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r = r.square()
+		if e.Bit(i) == 1 {
+			r = r.mul(a)
+		}
+	}
+	return r
+}
+
+// sqrt returns a square root of a, if there is one. It takes square
+// roots in Fp, where they are single exponentiations: if x0 + x1·u
+// squares to a, then x0² is (a.c0 ± n)/2, where n² = a.c0² + a.c1²
+// is the norm of a, and x1 = a.c1/2x0.
+func (a fp2) sqrt() (fp2, bool) {
+	var x fp2
+	if a.c1.Sign() == 0 {
+		if s := new(big.Int).ModSqrt(a.c0, p); s != nil {
+			x = fp2{s, new(big.Int)}
+		} else if s := new(big.Int).ModSqrt(fpNeg(a.c0), p); s != nil {
+			x = fp2{new(big.Int), s} // -1 is not a square
+		}
+	} else if n := new(big.Int).ModSqrt(fpAdd(fpMul(a.c0, a.c0), fpMul(a.c1, a.c1)), p); n != nil {
+		x0 := new(big.Int).ModSqrt(fpMul(fpAdd(a.c0, n), inv2), p)
+		if x0 == nil {
+			x0 = new(big.Int).ModSqrt(fpMul(fpSub(a.c0, n), inv2), p)
+		}
+		if x0 != nil {
+			x = fp2{x0, fpMul(a.c1, fpInv(fpAdd(x0, x0)))}
+		}
+	}
+	if x.c0 == nil || !x.square().equal(a) {
+		return fp2{}, false
+	}
+	return x, true
+}
+
+// sign tells whether a is the lexicographically larger of a and -a,
+// comparing c1 first.
+func (a fp2) sign() bool {
+	if a.c1.Sign() != 0 {
+		return a.c1.Cmp(pMinus1Over2) > 0
+	}
+	return a.c0.Cmp(pMinus1Over2) > 0
+}
+
+// fp6 is c0 + c1·v + c2·v².
+type fp6 struct {
+	c0, c1, c2 fp2
+}
+
+var (
+	fp6Zero = fp6{fp2Zero, fp2Zero, fp2Zero}
+	fp6One  = fp6{fp2One, fp2Zero, fp2Zero}
+)
+
+func (a fp6) add(b fp6) fp6 { return fp6{a.c0.add(b.c0), a.c1.add(b.c1), a.c2.add(b.c2)} }
+func (a fp6) sub(b fp6) fp6 { return fp6{a.c0.sub(b.c0), a.c1.sub(b.c1), a.c2.sub(b.c2)} }
+func (a fp6) neg() fp6      { return fp6{a.c0.neg(), a.c1.neg(), a.c2.neg()} }
+
+func (a fp6) mul(b fp6) fp6 {
+	t0 := a.c0.mul(b.c0)
+	t1 := a.c1.mul(b.c1)
+	t2 := a.c2.mul(b.c2)
+	c0 := a.c1.add(a.c2).mul(b.c1.add(b.c2)).sub(t1).sub(t2).mulXi().add(t0)
+	c1 := a.c0.add(a.c1).mul(b.c0.add(b.c1)).sub(t0).sub(t1).add(t2.mulXi())
+	c2 := a.c0.add(a.c2).mul(b.c0.add(b.c2)).sub(t0).sub(t2).add(t1)
+	return fp6{c0, c1, c2}
+}
+
+// mulV multiplies by v.
+func (a fp6) mulV() fp6 { return fp6{a.c2.mulXi(), a.c0, a.c1} }
+
+func (a fp6) inv() fp6 {
+	A := a.c0.square().sub(a.c1.mul(a.c2).mulXi())
+	B := a.c2.square().mulXi().sub(a.c0.mul(a.c1))
+	C := a.c1.square().sub(a.c0.mul(a.c2))
+	F := a.c0.mul(A).add(a.c2.mul(B).add(a.c1.mul(C)).mulXi()).inv()
+	return fp6{A.mul(F), B.mul(F), C.mul(F)}
+}
+
+func (a fp6) isZero() bool     { return a.c0.isZero() && a.c1.isZero() && a.c2.isZero() }
+func (a fp6) equal(b fp6) bool { return a.c0.equal(b.c0) && a.c1.equal(b.c1) && a.c2.equal(b.c2) }
+
+// fp12 is c0 + c1·w.
+type fp12 struct {
+	c0, c1 fp6
+}
+
+var fp12One = fp12{fp6One, fp6Zero}
+
+func fp12FromFp(a *big.Int) fp12 {
+	return fp12{fp6{fp2{a, new(big.Int)}, fp2Zero, fp2Zero}, fp6Zero}
+}
+
+func fp12FromFp2(a fp2) fp12 {
+	return fp12{fp6{a, fp2Zero, fp2Zero}, fp6Zero}
+}
+
+func (a fp12) add(b fp12) fp12 { return fp12{a.c0.add(b.c0), a.c1.add(b.c1)} }
+func (a fp12) sub(b fp12) fp12 { return fp12{a.c0.sub(b.c0), a.c1.sub(b.c1)} }
+func (a fp12) conj() fp12      { return fp12{a.c0, a.c1.neg()} }
+
+func (a fp12) mul(b fp12) fp12 {
+	t0 := a.c0.mul(b.c0)
+	t1 := a.c1.mul(b.c1)
+	c1 := a.c0.add(a.c1).mul(b.c0.add(b.c1)).sub(t0).sub(t1)
+	return fp12{t0.add(t1.mulV()), c1}
+}
+
+func (a fp12) square() fp12 {
+	ab := a.c0.mul(a.c1)
+	c0 := a.c0.add(a.c1).mul(a.c0.add(a.c1.mulV())).sub(ab).sub(ab.mulV())
+	return fp12{c0, ab.add(ab)}
+}
+
+// cycSquare returns a², for a in the cyclotomic subgroup of order
+// p⁴ - p² + 1, by the method of Granger and Scott, "Faster squaring
+// in the cyclotomic subgroup of sixth degree extensions."
+func (a fp12) cycSquare() fp12 {
+	z0, z4, z3 := a.c0.c0, a.c0.c1, a.c0.c2
+	z2, z1, z5 := a.c1.c0, a.c1.c1, a.c1.c2
+
+	t0, t1 := fp4Square(z0, z1)
+	z0 = t0.sub(z0)
+	z0 = z0.add(z0).add(t0)
+	z1 = t1.add(z1)
+	z1 = z1.add(z1).add(t1)
+
+	t0, t1 = fp4Square(z2, z3)
+	t2, t3 := fp4Square(z4, z5)
+	z4 = t0.sub(z4)
+	z4 = z4.add(z4).add(t0)
+	z5 = t1.add(z5)
+	z5 = z5.add(z5).add(t1)
+
+	t0 = t3.mulXi()
+	z2 = t0.add(z2)
+	z2 = z2.add(z2).add(t0)
+	z3 = t2.sub(z3)
+	z3 = z3.add(z3).add(t2)
+
+	return fp12{fp6{z0, z4, z3}, fp6{z2, z1, z5}}
+}
+
+// fp4Square returns the square of a + b·s in Fp4 = Fp2[s]/(s² - ξ).
+func fp4Square(a, b fp2) (fp2, fp2) {
+	t0, t1 := a.square(), b.square()
+	return t1.mulXi().add(t0), a.add(b).square().sub(t0).sub(t1)
+}
+
+func (a fp12) inv() fp12 {
+	t := a.c0.mul(a.c0).sub(a.c1.mul(a.c1).mulV()).inv()
+	return fp12{a.c0.mul(t), a.c1.mul(t).neg()}
+}
+
+func (a fp12) isOne() bool       { return a.equal(fp12One) }
+func (a fp12) equal(b fp12) bool { return a.c0.equal(b.c0) && a.c1.equal(b.c1) }
+
+func (a fp12) exp(e *big.Int) fp12 {
+	r := fp12One
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r = r.square()
+		if e.Bit(i) == 1 {
+			r = r.mul(a)
+		}
+	}
+	return r
+}
+
+// frobGamma[i] is ξ^(i(p-1)/6), by which the coefficient of w^i is
+// multiplied under the Frobenius map.
+var frobGamma [6]fp2
+
+func init() {
+	e := new(big.Int).Sub(p, big.NewInt(1))
+	e.Div(e, big.NewInt(6))
+	g := fp2One.mulXi().exp(e)
+	frobGamma[0] = fp2One
+	for i := 1; i < 6; i++ {
+		frobGamma[i] = frobGamma[i-1].mul(g)
+	}
+}
+
+// frob returns a^p.
+func (a fp12) frob() fp12 {
+	// The coefficients of 1, v, v² in c0 and c1 are those of w^0,
+	// w^2, w^4 and w^1, w^3, w^5.
+	return fp12{
+		fp6{a.c0.c0.conj(), a.c0.c1.conj().mul(frobGamma[2]), a.c0.c2.conj().mul(frobGamma[4])},
+		fp6{a.c1.c0.conj().mul(frobGamma[1]), a.c1.c1.conj().mul(frobGamma[3]), a.c1.c2.conj().mul(frobGamma[5])},
+	}
+}
@@ -0,0 +1,96 @@
+package bls
+
+import "math/big"
+
+// G1Point and G2Point expose the groups G1 and G2, and PairingCheck
+// the pairing, to other protocols built on BLS12-381, such as
+// package groth16.
+
+// Sizes of compressed points.
+const (
+	G1Size = PublicKeySize
+	G2Size = SignatureSize
+)
+
+// Order is the order r of G1 and G2. Scalars are reduced mod r.
+var Order = new(big.Int).Set(r)
+
+// G1Point is a point of G1.
+type G1Point struct {
+	p g1
+}
+
+// G2Point is a point of G2.
+type G2Point struct {
+	p g2
+}
+
+// G1Generator returns the standard generator of G1.
+func G1Generator() *G1Point { return &G1Point{g1Gen} }
+
+// G2Generator returns the standard generator of G2.
+func G2Generator() *G2Point { return &G2Point{g2Gen} }
+
+// ParseG1 decodes a compressed point, checking that it is in G1. The
+// identity is allowed.
+func ParseG1(b []byte) (*G1Point, error) {
+	pt, err := decodeG1(b)
+	if err != nil {
+		return nil, err
+	}
+	return &G1Point{pt}, nil
+}
+
+// ParseG2 decodes a compressed point, checking that it is in G2. The
+// identity is allowed.
+func ParseG2(b []byte) (*G2Point, error) {
+	pt, err := decodeG2(b)
+	if err != nil {
+		return nil, err
+	}
+	return &G2Point{pt}, nil
+}
+
+// Bytes returns the compressed encoding of a.
+func (a *G1Point) Bytes() []byte { return encodeG1(a.p) }
+
+// Bytes returns the compressed encoding of a.
+func (a *G2Point) Bytes() []byte { return encodeG2(a.p) }
+
+// Add returns a+b.
+func (a *G1Point) Add(b *G1Point) *G1Point { return &G1Point{a.p.add(b.p)} }
+
+// Add returns a+b.
+func (a *G2Point) Add(b *G2Point) *G2Point { return &G2Point{a.p.add(b.p)} }
+
+// Neg returns -a.
+func (a *G1Point) Neg() *G1Point { return &G1Point{a.p.neg()} }
+
+// Neg returns -a.
+func (a *G2Point) Neg() *G2Point { return &G2Point{a.p.neg()} }
+
+// ScalarMult returns k·a.
+func (a *G1Point) ScalarMult(k *big.Int) *G1Point { return &G1Point{a.p.mul(k)} }
+
+// ScalarMult returns k·a.
+func (a *G2Point) ScalarMult(k *big.Int) *G2Point { return &G2Point{a.p.mul(k)} }
+
+// Equal tells whether a and b are the same point.
+func (a *G1Point) Equal(b *G1Point) bool { return a.p.equal(b.p) }
+
+// Equal tells whether a and b are the same point.
+func (a *G2Point) Equal(b *G2Point) bool { return a.p.equal(b.p) }
+
+// PairingCheck tells whether the product of e(as[i], bs[i]) is the
+// identity of GT. It panics if the slices differ in length.
+func PairingCheck(as []*G1Point, bs []*G2Point) bool {
+	if len(as) != len(bs) {
+		panic("bls: PairingCheck with mismatched arguments")
+	}
+	ga := make([]g1, len(as))
+	gb := make([]g2, len(bs))
+	for i := range as {
+		ga[i], gb[i] = as[i].p, bs[i].p
+	}
+	return pairingCheck(ga, gb)
+}
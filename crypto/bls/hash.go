@@ -0,0 +1,170 @@
+package bls
+
+import (
+	"crypto/sha256"
+	"math/big"
+)
+
+// Messages are hashed to G2 with the suite
+// BLS12381G2_XMD:SHA-256_SSWU_RO_ of RFC 9380, "Hashing to Elliptic
+// Curves": expand_message_xmd with SHA-256, the simplified SWU map to
+// a curve E₃ 3-isogenous to E', and cofactor clearing with ψ.
+
+// expandMessageXMD is expand_message_xmd of RFC 9380, section 5.3.1,
+// with SHA-256. It returns n bytes; n must be at most 255·32.
+func expandMessageXMD(dst, msg []byte, n int) []byte {
+	if len(dst) > 255 {
+		h := sha256.Sum256(append([]byte("H2C-OVERSIZE-DST-"), dst...))
+		dst = h[:]
+	}
+	dstPrime := append(append([]byte{}, dst...), byte(len(dst)))
+
+	h := sha256.New()
+	h.Write(make([]byte, h.BlockSize()))
+	h.Write(msg)
+	h.Write([]byte{byte(n >> 8), byte(n), 0})
+	h.Write(dstPrime)
+	b0 := h.Sum(nil)
+
+	out := make([]byte, 0, n+sha256.Size)
+	bi := make([]byte, sha256.Size) // b_(i-1), initially zero
+	for i := 1; len(out) < n; i++ {
+		for j := range bi {
+			bi[j] ^= b0[j]
+		}
+		h.Reset()
+		h.Write(bi)
+		h.Write([]byte{byte(i)})
+		h.Write(dstPrime)
+		bi = h.Sum(bi[:0])
+		out = append(out, bi...)
+	}
+	return out[:n]
+}
+
+// hashToField is hash_to_field of RFC 9380, section 5.2, for Fp2
+// with L = 64, returning two elements.
+func hashToField(dst, msg []byte) [2]fp2 {
+	const l = 64
+	b := expandMessageXMD(dst, msg, 4*l)
+	elem := func(i int) *big.Int {
+		e := new(big.Int).SetBytes(b[i*l : (i+1)*l])
+		return e.Mod(e, p)
+	}
+	return [2]fp2{{elem(0), elem(1)}, {elem(2), elem(3)}}
+}
+
+// sgn0 is the sgn0 function of RFC 9380, section 4.1, for Fp2. It is
+// not the sign of the point encodings; see fp2.sign.
+func (a fp2) sgn0() bool {
+	if a.c0.Sign() != 0 {
+		return a.c0.Bit(0) == 1
+	}
+	return a.c1.Bit(0) == 1
+}
+
+var (
+	// E₃ is y² = x³ + isoA·x + isoB, and sswuZ is the Z of RFC 9380
+	// for mapping to it.
+	isoA  = newFp2(0, 240)
+	isoB  = newFp2(1012, 1012)
+	sswuZ = newFp2(2, 1).neg()
+
+	// The coefficients, lowest degree first, of the numerators and
+	// denominators of the 3-isogeny from E₃ to E', from RFC 9380,
+	// appendix E.3. The denominators are monic.
+	isoXNum = []fp2{
+		fp2Hex("05c759507e8e333ebb5b7a9a47d7ed8532c52d39fd3a042a88b58423c50ae15d5c2638e343d9c71c6238aaaaaaaa97d6", "05c759507e8e333ebb5b7a9a47d7ed8532c52d39fd3a042a88b58423c50ae15d5c2638e343d9c71c6238aaaaaaaa97d6"),
+		fp2Hex("0", "11560bf17baa99bc32126fced787c88f984f87adf7ae0c7f9a208c6b4f20a4181472aaa9cb8d555526a9ffffffffc71a"),
+		fp2Hex("11560bf17baa99bc32126fced787c88f984f87adf7ae0c7f9a208c6b4f20a4181472aaa9cb8d555526a9ffffffffc71e", "08ab05f8bdd54cde190937e76bc3e447cc27c3d6fbd7063fcd104635a790520c0a395554e5c6aaaa9354ffffffffe38d"),
+		fp2Hex("171d6541fa38ccfaed6dea691f5fb614cb14b4e7f4e810aa22d6108f142b85757098e38d0f671c7188e2aaaaaaaa5ed1", "0"),
+	}
+	isoXDen = []fp2{
+		fp2Hex("0", "1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaa63"),
+		fp2Hex("0c", "1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaa9f"),
+		fp2One,
+	}
+	isoYNum = []fp2{
+		fp2Hex("1530477c7ab4113b59a4c18b076d11930f7da5d4a07f649bf54439d87d27e500fc8c25ebf8c92f6812cfc71c71c6d706", "1530477c7ab4113b59a4c18b076d11930f7da5d4a07f649bf54439d87d27e500fc8c25ebf8c92f6812cfc71c71c6d706"),
+		fp2Hex("0", "05c759507e8e333ebb5b7a9a47d7ed8532c52d39fd3a042a88b58423c50ae15d5c2638e343d9c71c6238aaaaaaaa97be"),
+		fp2Hex("11560bf17baa99bc32126fced787c88f984f87adf7ae0c7f9a208c6b4f20a4181472aaa9cb8d555526a9ffffffffc71c", "08ab05f8bdd54cde190937e76bc3e447cc27c3d6fbd7063fcd104635a790520c0a395554e5c6aaaa9354ffffffffe38f"),
+		fp2Hex("124c9ad43b6cf79bfbf7043de3811ad0761b0f37a1e26286b0e977c69aa274524e79097a56dc4bd9e1b371c71c718b10", "0"),
+	}
+	isoYDen = []fp2{
+		fp2Hex("1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffa8fb", "1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffa8fb"),
+		fp2Hex("0", "1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffa9d3"),
+		fp2Hex("12", "1a0111ea397fe69a4b1ba7b6434bacd764774b84f38512bf6730d2a0f6b0f6241eabfffeb153ffffb9feffffffffaa99"),
+		fp2One,
+	}
+)
+
+func fp2Hex(c0, c1 string) fp2 {
+	return fp2{fromHex(c0), fromHex(c1)}
+}
+
+// mapToE2 maps u to a point of E'(Fp2), not necessarily in G2, with
+// the simplified SWU map to E₃ (RFC 9380, section 6.6.2) followed by
+// the isogeny to E'. It runs in variable time.
+func mapToE2(u fp2) g2 {
+	g := func(x fp2) fp2 {
+		return x.square().mul(x).add(isoA.mul(x)).add(isoB)
+	}
+	zuu := sswuZ.mul(u.square())
+	tv := zuu.square().add(zuu)
+	var x fp2
+	if tv.isZero() {
+		x = isoB.mul(sswuZ.mul(isoA).inv())
+	} else {
+		x = isoB.neg().mul(isoA.inv()).mul(fp2One.add(tv.inv()))
+	}
+	y, ok := g(x).sqrt()
+	if !ok {
+		// Then g(Z·u²·x) is square.
+		x = zuu.mul(x)
+		y, ok = g(x).sqrt()
+		if !ok {
+			panic("bls: simplified SWU found no square")
+		}
+	}
+	if u.sgn0() != y.sgn0() {
+		y = y.neg()
+	}
+	return iso3(x, y)
+}
+
+// iso3 maps a point of E₃ to E'.
+func iso3(x, y fp2) g2 {
+	poly := func(coef []fp2) fp2 {
+		r := coef[len(coef)-1]
+		for i := len(coef) - 2; i >= 0; i-- {
+			r = r.mul(x).add(coef[i])
+		}
+		return r
+	}
+	xDen, yDen := poly(isoXDen), poly(isoYDen)
+	if xDen.isZero() || yDen.isZero() {
+		return g2{inf: true}
+	}
+	return g2{
+		x: poly(isoXNum).mul(xDen.inv()),
+		y: y.mul(poly(isoYNum)).mul(yDen.inv()),
+	}
+}
+
+// clearCofactor maps a point of E'(Fp2) into G2 by multiplying it by
+// h_eff, computed as in RFC 9380, appendix G.3, as
+// (x² - x - 1)·a + (x - 1)·ψ(a) + ψ²(2a).
+func (a g2) clearCofactor() g2 {
+	t1 := a.mul(curveX)
+	t2 := a.psi()
+	t3 := a.double().psi().psi().add(t2.neg())
+	t2 = t1.add(t2).mul(curveX)
+	return t3.add(t2).add(t1.neg()).add(a.neg())
+}
+
+// hashToG2 is hash_to_curve of RFC 9380 with the suite
+// BLS12381G2_XMD:SHA-256_SSWU_RO_.
+func hashToG2(dst, msg []byte) g2 {
+	u := hashToField(dst, msg)
+	return mapToE2(u[0]).add(mapToE2(u[1])).clearCofactor()
+}
@@ -0,0 +1,77 @@
+package bls
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Test vectors from RFC 9380, appendices K.1 and J.10.1.
+
+func TestExpandMessageXMD(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-expander-SHA256-128")
+	cases := []struct {
+		msg  string
+		n    int
+		want string
+	}{
+		{"", 0x20, "68a985b87eb6b46952128911f2a4412bbc302a9d759667f87f7a21d803f07235"},
+		{"abc", 0x20, "d8ccab23b5985ccea865c6c97b6e5b8350e794e603b4b97902f53a8a0d605615"},
+	}
+	for _, c := range cases {
+		got := hex.EncodeToString(expandMessageXMD(dst, []byte(c.msg), c.n))
+		if got != c.want {
+			t.Errorf("expandMessageXMD(%q, %d) = %s, want %s", c.msg, c.n, got, c.want)
+		}
+	}
+}
+
+func TestHashToG2(t *testing.T) {
+	dst := []byte("QUUX-V01-CS02-with-BLS12381G2_XMD:SHA-256_SSWU_RO_")
+	cases := []struct {
+		msg  string
+		want g2
+	}{{
+		"",
+		g2{
+			x: fp2Hex(
+				"0141ebfbdca40eb85b87142e130ab689c673cf60f1a3e98d69335266f30d9b8d4ac44c1038e9dcdd5393faf5c41fb78a",
+				"05cb8437535e20ecffaef7752baddf98034139c38452458baeefab379ba13dff5bf5dd71b72418717047f5b0f37da03d",
+			),
+			y: fp2Hex(
+				"0503921d7f6a12805e72940b963c0cf3471c7b2a524950ca195d11062ee75ec076daf2d4bc358c4b190c0c98064fdd92",
+				"12424ac32561493f3fe3c260708a12b7c620e7be00099a974e259ddc7d1f6395c3c811cdd19f1e8dbf3e9ecfdcbab8d6",
+			),
+		},
+	}, {
+		"abc",
+		g2{
+			x: fp2Hex(
+				"02c2d18e033b960562aae3cab37a27ce00d80ccd5ba4b7fe0e7a210245129dbec7780ccc7954725f4168aff2787776e6",
+				"139cddbccdc5e91b9623efd38c49f81a6f83f175e80b06fc374de9eb4b41dfe4ca3a230ed250fbe3a2acf73a41177fd8",
+			),
+			y: fp2Hex(
+				"1787327b68159716a37440985269cf584bcb1e621d3a7202be6ea05c4cfe244aeb197642555a0645fb87bf7466b2ba48",
+				"00aa65dae3c8d732d10ecd2c50f8a1baf3001578f71c694e03866e9f3d49ac1e1ce70dd94a733534f106d4cec0eddd16",
+			),
+		},
+	}}
+	for _, c := range cases {
+		got := hashToG2(dst, []byte(c.msg))
+		if !got.equal(c.want) {
+			t.Errorf("hashToG2(%q) = %v, want %v", c.msg, got, c.want)
+		}
+	}
+}
+
+func TestIsogeny(t *testing.T) {
+	for _, msg := range []string{"a", "b", "c"} {
+		u := hashToField([]byte("test"), []byte(msg))
+		q := mapToE2(u[0])
+		if !q.onCurve() {
+			t.Errorf("mapToE2(%v) = %v, not on E'", u[0], q)
+		}
+		if !q.clearCofactor().inSubgroup() {
+			t.Errorf("clearCofactor(%v) is not in G2", q)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package bls
+
+import "math/big"
+
+// absX is -x, the absolute value of the curve parameter.
+var absX = new(big.Int).Neg(curveX)
+
+// millerLoop computes the product of the Miller loops of the
+// optimal ate pairing, f_{x,bs[i]}(as[i]), sharing the squarings.
+// Each T runs over E'(Fp2) in affine coordinates. Vertical lines are
+// omitted, as their values lie in Fp6 and vanish in the final
+// exponentiation.
+func millerLoop(as []g1, bs []g2) fp12 {
+	type pair struct {
+		p  g1
+		q  g2
+		tx fp2
+		ty fp2
+	}
+	var pairs []*pair
+	for i := range as {
+		if !as[i].inf && !bs[i].inf {
+			pairs = append(pairs, &pair{p: as[i], q: bs[i], tx: bs[i].x, ty: bs[i].y})
+		}
+	}
+	f := fp12One
+	for i := absX.BitLen() - 2; i >= 0; i-- {
+		f = f.square()
+		for _, pr := range pairs {
+			tx2 := pr.tx.square()
+			l := tx2.add(tx2).add(tx2).mul(pr.ty.add(pr.ty).inv())
+			f = f.mul(line(pr.p, pr.tx, pr.ty, l))
+			x3 := l.square().sub(pr.tx).sub(pr.tx)
+			pr.tx, pr.ty = x3, l.mul(pr.tx.sub(x3)).sub(pr.ty)
+			if absX.Bit(i) == 1 {
+				l = pr.q.y.sub(pr.ty).mul(pr.q.x.sub(pr.tx).inv())
+				f = f.mul(line(pr.p, pr.tx, pr.ty, l))
+				x3 = l.square().sub(pr.tx).sub(pr.q.x)
+				pr.tx, pr.ty = x3, l.mul(pr.tx.sub(x3)).sub(pr.ty)
+			}
+		}
+	}
+	// x is negative.
+	return f.conj()
+}
+
+// line evaluates at a the line of slope l through (tx, ty) on E',
+// both mapped into E(Fp12) by (x, y) ↦ (x·w⁻², y·w⁻³). The slope
+// becomes l·w⁻¹, so the value is a.y + (l·tx - ty)·w⁻³ - l·a.x·w⁻¹.
+// It is returned multiplied by ξ = w⁶, which lies in Fp2 and vanishes
+// in the final exponentiation, making it the sparse element
+// ξ·a.y + (l·tx - ty)·v·w - l·a.x·v²·w.
+func line(a g1, tx, ty, l fp2) fp12 {
+	return fp12{
+		fp6{fp2{a.y, a.y}, fp2Zero, fp2Zero},
+		fp6{fp2Zero, l.mul(tx).sub(ty), l.mulFp(a.x).neg()},
+	}
+}
+
+// finalExp raises f to the power 3(p¹² - 1)/r. The cube of the
+// pairing is as good as the pairing itself, since r is prime to 3,
+// and the factor allows the hard part to be computed with the
+// decomposition 3(p⁴ - p² + 1)/r = (x - 1)²(x + p)(x² + p² - 1) + 3
+// of Hayashida, Hayasaka, and Teruya, "Efficient final
+// exponentiation via cyclotomic structure for pairings over families
+// of elliptic curves."
+func finalExp(f fp12) fp12 {
+	f = f.conj().mul(f.inv())  // p⁶ - 1
+	f = f.frob().frob().mul(f) // p² + 1
+
+	// Now f is in the cyclotomic subgroup, where f^(p⁶) = f⁻¹ is
+	// its conjugate.
+	a := f.expX().mul(f.conj())                            // x - 1
+	a = a.expX().mul(a.conj())                             // x - 1
+	a = a.expX().mul(a.frob())                             // x + p
+	a = a.expX().expX().mul(a.frob().frob()).mul(a.conj()) // x² + p² - 1
+	return a.mul(f.square()).mul(f)
+}
+
+// expX returns a^x for a in the cyclotomic subgroup.
+func (a fp12) expX() fp12 {
+	r := a
+	for i := absX.BitLen() - 2; i >= 0; i-- {
+		r = r.cycSquare()
+		if absX.Bit(i) == 1 {
+			r = r.mul(a)
+		}
+	}
+	return r.conj()
+}
+
+// pairing computes e(a, b)³; see finalExp.
+func pairing(a g1, b g2) fp12 {
+	return finalExp(millerLoop([]g1{a}, []g2{b}))
+}
+
+// pairingCheck tells whether the product of e(as[i], bs[i]) is 1.
+func pairingCheck(as []g1, bs []g2) bool {
+	return finalExp(millerLoop(as, bs)).isOne()
+}
@@ -29,6 +29,7 @@ func NewXPrv(r io.Reader) (xprv XPrv, err error) {
 		r = rand.Reader
 	}
 	var entropy [32]byte
+	defer edwards25519.Wipe(entropy[:])
 	_, err = io.ReadFull(r, entropy[:])
 	if err != nil {
 		return xprv, err
@@ -45,6 +46,7 @@ func NewXPrv(r io.Reader) (xprv XPrv, err error) {
 func (xprv XPrv) XPub() XPub {
 	var buf [32]byte
 	copy(buf[:], xprv[:32])
+	defer edwards25519.Wipe(buf[:])
 
 	var P edwards25519.ExtendedGroupElement
 	edwards25519.GeScalarMultBase(&P, &buf)
@@ -65,6 +67,7 @@ func (xprv XPrv) Child(sel []byte, hardened bool) (res XPrv) {
 	}
 
 	var s [32]byte
+	defer edwards25519.Wipe(s[:])
 	copy(s[:], xprv[:32])
 	var P edwards25519.ExtendedGroupElement
 	edwards25519.GeScalarMultBase(&P, &s)
@@ -78,6 +81,8 @@ func (xprv XPrv) Child(sel []byte, hardened bool) (res XPrv) {
 		f  [32]byte
 		s2 [32]byte
 	)
+	defer edwards25519.Wipe(f[:])
+	defer edwards25519.Wipe(s2[:])
 	copy(f[:], res[:32])
 	edwards25519.ScMulAdd(&s2, &one, &f, &s)
 	copy(res[:32], s2[:])
@@ -119,13 +124,22 @@ func (xpub XPub) Child(sel []byte) (res XPub) {
 	return res
 }
 
+// Wipe overwrites xprv with zeroes, on a best-effort basis. Since
+// XPrv is an array, copies of it made by passing it by value are
+// not affected.
+func (xprv *XPrv) Wipe() {
+	edwards25519.Wipe(xprv[:])
+}
+
 // Derive produces the descendant of the XPrv at the given path. It's
 // equivalent to repeated calls of xprv.Child with the elements of
 // path.
 func (xprv XPrv) Derive(path [][]byte) XPrv {
 	res := xprv
 	for _, p := range path {
-		res = res.Child(p, false)
+		next := res.Child(p, false)
+		res.Wipe()
+		res = next
 	}
 	return res
 }
@@ -145,8 +159,10 @@ func (xpub XPub) Derive(path [][]byte) XPub {
 func (xprv XPrv) Sign(msg []byte) []byte {
 	var s [32]byte
 	copy(s[:], xprv[:32])
+	defer edwards25519.Wipe(s[:])
 
 	var h [64]byte
+	defer edwards25519.Wipe(h[:])
 	hashKeySalt(h[:], 2, xprv[:32], xprv[32:])
 
 	var P edwards25519.ExtendedGroupElement
@@ -156,12 +172,14 @@ func (xprv XPrv) Sign(msg []byte) []byte {
 	P.ToBytes(&pubkey)
 
 	var r [64]byte
+	defer edwards25519.Wipe(r[:])
 	hasher := sha512.New()
 	hasher.Write(h[:32])
 	hasher.Write(msg)
 	hasher.Sum(r[:0])
 
 	var rReduced [32]byte
+	defer edwards25519.Wipe(rReduced[:])
 	edwards25519.ScReduce(&rReduced, &r)
 
 	var rPoint edwards25519.ExtendedGroupElement
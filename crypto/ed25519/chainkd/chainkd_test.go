@@ -108,3 +108,18 @@ func doverify(t *testing.T, xpub XPub, msg, sig []byte, xpubdesc, xprvdesc strin
 		sig[i] ^= 0xff
 	}
 }
+
+func TestWipe(t *testing.T) {
+	xprv, err := NewXPrv(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child := xprv.Derive([][]byte{{1}, {2}})
+	if child != xprv.Child([]byte{1}, false).Child([]byte{2}, false) {
+		t.Error("Derive disagrees with repeated Child")
+	}
+	xprv.Wipe()
+	if xprv != (XPrv{}) {
+		t.Errorf("xprv not wiped: %x", xprv[:])
+	}
+}
@@ -0,0 +1,97 @@
+package ecmath
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var (
+	benchX, benchY, benchZ Scalar
+	benchP, benchQ         Point
+	benchWide              [64]byte
+)
+
+func init() {
+	r := rand.New(rand.NewSource(1))
+	r.Read(benchWide[:])
+	benchX.Reduce(&benchWide)
+	r.Read(benchWide[:])
+	benchY.Reduce(&benchWide)
+	benchP.ScMulBase(&benchX)
+	benchQ.ScMulBase(&benchY)
+}
+
+func BenchmarkScalarAdd(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchZ.Add(&benchX, &benchY)
+	}
+}
+
+func BenchmarkScalarMul(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchZ.Mul(&benchX, &benchY)
+	}
+}
+
+func BenchmarkScalarMulAdd(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchZ.MulAdd(&benchX, &benchY, &benchX)
+	}
+}
+
+func BenchmarkScalarReduce(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchZ.Reduce(&benchWide)
+	}
+}
+
+func BenchmarkPointAdd(b *testing.B) {
+	var z Point
+	for i := 0; i < b.N; i++ {
+		z.Add(&benchP, &benchQ)
+	}
+}
+
+func BenchmarkPointDouble(b *testing.B) {
+	var z Point
+	for i := 0; i < b.N; i++ {
+		z.Double(&benchP)
+	}
+}
+
+func BenchmarkScMul(b *testing.B) {
+	var z Point
+	for i := 0; i < b.N; i++ {
+		z.ScMul(&benchP, &benchX)
+	}
+}
+
+func BenchmarkScMulBase(b *testing.B) {
+	var z Point
+	for i := 0; i < b.N; i++ {
+		z.ScMulBase(&benchX)
+	}
+}
+
+func BenchmarkScMulAdd(b *testing.B) {
+	var z Point
+	for i := 0; i < b.N; i++ {
+		z.ScMulAdd(&benchP, &benchX, &benchY)
+	}
+}
+
+func BenchmarkPointEncode(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		benchP.Encode()
+	}
+}
+
+func BenchmarkPointDecode(b *testing.B) {
+	e := benchP.Encode()
+	var z Point
+	for i := 0; i < b.N; i++ {
+		if _, ok := z.Decode(e); !ok {
+			b.Fatal("decoding failed")
+		}
+	}
+}
@@ -0,0 +1,55 @@
+//go:build ctaudit
+// +build ctaudit
+
+package ecmath
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// TestTimingVariance checks that scalar multiplication takes about
+// as long for scalars with very few bits set as for scalars with
+// nearly all bits set, the classic leak of double-and-add. It is
+// only built with the ctaudit tag, and is a coarse smoke test rather
+// than a substitute for a proper statistical analysis (e.g. dudect)
+// on the target hardware.
+func TestTimingVariance(t *testing.T) {
+	if testing.Short() {
+		t.Skip("timing test skipped in short mode")
+	}
+	sparse := Scalar{1}
+	dense := NegOne
+
+	// Alternate between the two inputs so that drift in machine
+	// load affects both equally, and compare medians.
+	const rounds, samples = 20, 41
+	var sparseTimes, denseTimes []time.Duration
+	var p Point
+	for i := 0; i < samples; i++ {
+		for _, s := range []*Scalar{&sparse, &dense} {
+			start := time.Now()
+			for j := 0; j < rounds; j++ {
+				p.ScMul(&base, s)
+			}
+			d := time.Since(start)
+			if s == &sparse {
+				sparseTimes = append(sparseTimes, d)
+			} else {
+				denseTimes = append(denseTimes, d)
+			}
+		}
+	}
+	median := func(ds []time.Duration) time.Duration {
+		sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+		return ds[len(ds)/2]
+	}
+
+	ds, dd := median(sparseTimes), median(denseTimes)
+	ratio := float64(ds) / float64(dd)
+	t.Logf("median time sparse %s, dense %s, ratio %.3f", ds, dd, ratio)
+	if ratio < 0.9 || ratio > 1.1 {
+		t.Errorf("timing ratio %.3f between sparse and dense scalars exceeds 10%%", ratio)
+	}
+}
@@ -0,0 +1,31 @@
+package ecmath
+
+import (
+	"math/rand"
+	"testing"
+
+	"i10r.io/crypto/ed25519/internal/edwards25519"
+)
+
+func TestLadder(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20; i++ {
+		var b [64]byte
+		r.Read(b[:])
+		var x Scalar
+		x.Reduce(&b)
+
+		var want, got Point
+		want.ScMulAdd(&base, &x, &Zero)
+		edwards25519.GeScalarMultLadder((*edwards25519.ExtendedGroupElement)(&got), (*[32]byte)(&x), (*edwards25519.ExtendedGroupElement)(&base))
+		if !got.ConstTimeEqual(&want) {
+			t.Errorf("ladder %x * base disagrees with ScMulAdd", x[:])
+		}
+	}
+
+	var got Point
+	edwards25519.GeScalarMultLadder((*edwards25519.ExtendedGroupElement)(&got), (*[32]byte)(&Zero), (*edwards25519.ExtendedGroupElement)(&base))
+	if !got.IsIdentity() {
+		t.Error("0 * base is not the identity")
+	}
+}
@@ -9,6 +9,12 @@ import (
 // Point is a point on the ed25519 curve.
 type Point edwards25519.ExtendedGroupElement
 
+// ConstantTimeAudit reports whether this package was built with the
+// ctaudit tag. In that mode every scalar multiplication, including
+// ScMulAdd and the signing code in ed25519 and chainkd, uses a
+// table-free constant-time ladder.
+const ConstantTimeAudit = edwards25519.ConstantTimeAudit
+
 // ZeroPoint is the zero point on the ed25519 curve (not the zero value of Point).
 var ZeroPoint Point
 
@@ -38,6 +44,38 @@ func (z *Point) Sub(x, y *Point) *Point {
 	return z
 }
 
+// Double computes 2x, storing the result in z and returning that. X
+// and z may be the same pointer.
+func (z *Point) Double(x *Point) *Point {
+	var c edwards25519.CompletedGroupElement
+	(*edwards25519.ExtendedGroupElement)(x).Double(&c)
+	c.ToExtended((*edwards25519.ExtendedGroupElement)(z))
+	return z
+}
+
+// Neg computes -x, storing the result in z and returning that. X and
+// z may be the same pointer.
+func (z *Point) Neg(x *Point) *Point {
+	xe := (*edwards25519.ExtendedGroupElement)(x)
+	ze := (*edwards25519.ExtendedGroupElement)(z)
+	edwards25519.FeNeg(&ze.X, &xe.X)
+	edwards25519.FeCopy(&ze.Y, &xe.Y)
+	edwards25519.FeCopy(&ze.Z, &xe.Z)
+	edwards25519.FeNeg(&ze.T, &xe.T)
+	return z
+}
+
+// IsIdentity tells whether z is the identity element (ZeroPoint), in
+// constant time.
+func (z *Point) IsIdentity() bool {
+	// In projective coordinates the identity is (0 : c : c) for any
+	// nonzero c.
+	ze := (*edwards25519.ExtendedGroupElement)(z)
+	var d edwards25519.FieldElement
+	edwards25519.FeSub(&d, &ze.Y, &ze.Z)
+	return edwards25519.FeIsNonZero(&ze.X)|edwards25519.FeIsNonZero(&d) == 0
+}
+
 // ScMul multiplies the EC point x by the scalar y, placing the result
 // in z and returning that. X and z may be the same pointer.
 func (z *Point) ScMul(x *Point, y *Scalar) *Point {
@@ -53,7 +91,17 @@ func (z *Point) ScMulBase(x *Scalar) *Point {
 
 // ScMulAdd computes xa+yB, where B is the ed25519 base point, and
 // places the result in z, returning that.
+//
+// Unless the package is built with the ctaudit tag, this runs in
+// variable time and must not be used with secret scalars.
 func (z *Point) ScMulAdd(a *Point, x, y *Scalar) *Point {
+	if ConstantTimeAudit {
+		var xa, yB Point
+		edwards25519.GeScalarMultLadder((*edwards25519.ExtendedGroupElement)(&xa), (*[32]byte)(x), (*edwards25519.ExtendedGroupElement)(a))
+		yB.ScMulBase(y)
+		return z.Add(&xa, &yB)
+	}
+
 	// TODO: replace with constant-time implementation to avoid
 	// sidechannel attacks
 
@@ -83,6 +131,21 @@ func (z *Point) ScMulCofactor(p *Point) *Point {
 	return z
 }
 
+// ClearCofactor maps p into the prime-order subgroup by multiplying
+// it by the cofactor, placing the result in z and returning that. It
+// is the same as ScMulCofactor.
+func (z *Point) ClearCofactor(p *Point) *Point {
+	return z.ScMulCofactor(p)
+}
+
+// HasSmallOrder tells whether p lies in the small subgroup of order
+// dividing the cofactor, including the identity. Such points must
+// generally be rejected as public keys and commitments.
+func (p *Point) HasSmallOrder() bool {
+	var q Point
+	return q.ScMulCofactor(p).IsIdentity()
+}
+
 func (z *Point) Encode() [32]byte {
 	var e [32]byte
 	(*edwards25519.ExtendedGroupElement)(z).ToBytes(&e)
@@ -42,3 +42,69 @@ func TestBasePointArith(t *testing.T) {
 		t.Errorf("base+base [%x] != 2*base [%x] (2)", ebase2a[:], ebase2c[:])
 	}
 }
+
+func TestPointOps(t *testing.T) {
+	var two, three Scalar
+	two.Add(&One, &One)
+	three.Add(&two, &One)
+
+	var p2, p3 Point
+	p2.ScMulBase(&two)
+	p3.ScMulBase(&three)
+
+	var got Point
+	if !got.Double(&base).ConstTimeEqual(&p2) {
+		t.Error("2*base != base doubled")
+	}
+	if !got.Sub(&p3, &p2).ConstTimeEqual(&base) {
+		t.Error("3*base - 2*base != base")
+	}
+
+	var negBase Point
+	negBase.ScMulBase(&NegOne)
+	if !got.Neg(&base).ConstTimeEqual(&negBase) {
+		t.Error("-base != (-1)*base")
+	}
+	got.Neg(&got)
+	if !got.ConstTimeEqual(&base) {
+		t.Error("-(-base) != base")
+	}
+
+	if !ZeroPoint.IsIdentity() {
+		t.Error("ZeroPoint is not the identity")
+	}
+	if base.IsIdentity() {
+		t.Error("base is the identity")
+	}
+	if !got.Add(&base, &negBase).IsIdentity() {
+		t.Error("base + -base is not the identity")
+	}
+}
+
+func TestCofactor(t *testing.T) {
+	// A point of order 8 on edwards25519.
+	var small Point
+	_, ok := small.Decode([32]byte{
+		0xc7, 0x17, 0x6a, 0x70, 0x3d, 0x4d, 0xd8, 0x4f,
+		0xba, 0x3c, 0x0b, 0x76, 0x0d, 0x10, 0x67, 0x0f,
+		0x2a, 0x20, 0x53, 0xfa, 0x2c, 0x39, 0xcc, 0xc6,
+		0x4e, 0xc7, 0xfd, 0x77, 0x92, 0xac, 0x03, 0x7a,
+	})
+	if !ok {
+		t.Fatal("cannot decode small-order point")
+	}
+	if !small.HasSmallOrder() || small.IsIdentity() {
+		t.Error("small-order point not recognized")
+	}
+	if base.HasSmallOrder() {
+		t.Error("base point has small order")
+	}
+
+	var mixed, cleared, want Point
+	mixed.Add(&base, &small)
+	cleared.ClearCofactor(&mixed)
+	want.ScMulBase(&Cofactor)
+	if !cleared.ConstTimeEqual(&want) {
+		t.Error("clearing the cofactor of base+torsion != 8*base")
+	}
+}
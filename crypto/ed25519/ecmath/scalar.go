@@ -89,6 +89,31 @@ func (z *Scalar) Equal(x *Scalar) bool {
 	return subtle.ConstantTimeCompare(x[:], z[:]) == 1
 }
 
+// IsZero tells whether z is the number 0, in constant time. A
+// non-canonical encoding of 0 (such as L) is not considered zero;
+// reduce it first if that matters.
+func (z *Scalar) IsZero() bool {
+	return z.Equal(&Zero)
+}
+
+// LessThan tells whether z < x, treating both as unreduced 256-bit
+// little-endian integers. It runs in constant time.
+func (z *Scalar) LessThan(x *Scalar) bool {
+	// Compute z-x byte by byte, keeping only the borrow.
+	var borrow uint32
+	for i := 0; i < len(z); i++ {
+		d := uint32(z[i]) - uint32(x[i]) - borrow
+		borrow = d >> 31
+	}
+	return borrow == 1
+}
+
+// IsCanonical tells whether z is fully reduced, i.e. z < L. It runs
+// in constant time.
+func (z *Scalar) IsCanonical() bool {
+	return edwards25519.ScIsCanonical((*[32]byte)(z))
+}
+
 // Prune performs the pruning operation in-place.
 func (z *Scalar) Prune() {
 	z[0] &= 248
@@ -103,6 +128,12 @@ func (z *Scalar) Reduce(x *[64]byte) *Scalar {
 	return z
 }
 
+// Wipe overwrites z with zeroes. Call it (typically deferred) on
+// scalars holding secrets once they are no longer needed.
+func (z *Scalar) Wipe() {
+	edwards25519.Wipe(z[:])
+}
+
 func (s *Scalar) String() string {
 	return hex.EncodeToString(s[:])
 }
@@ -0,0 +1,63 @@
+package ecmath
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestScalarCompare(t *testing.T) {
+	lPlus1 := L
+	lPlus1[0]++
+
+	cases := []struct {
+		s         Scalar
+		zero      bool
+		canonical bool
+	}{
+		{Zero, true, true},
+		{One, false, true},
+		{NegOne, false, true},
+		{L, false, false},
+		{lPlus1, false, false},
+		{Scalar{31: 0xff}, false, false},
+	}
+	for _, c := range cases {
+		if got := c.s.IsZero(); got != c.zero {
+			t.Errorf("%x.IsZero() = %v, want %v", c.s[:], got, c.zero)
+		}
+		if got := c.s.IsCanonical(); got != c.canonical {
+			t.Errorf("%x.IsCanonical() = %v, want %v", c.s[:], got, c.canonical)
+		}
+	}
+}
+
+func TestScalarLessThan(t *testing.T) {
+	toBig := func(s *Scalar) *big.Int {
+		var be [32]byte
+		for i := range s {
+			be[31-i] = s[i]
+		}
+		return new(big.Int).SetBytes(be[:])
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		var a, b Scalar
+		r.Read(a[:])
+		if i%2 == 0 {
+			// Share a prefix, to exercise the borrow chain.
+			b = a
+			b[r.Intn(32)] = byte(r.Intn(256))
+		} else {
+			r.Read(b[:])
+		}
+		want := toBig(&a).Cmp(toBig(&b)) < 0
+		if got := a.LessThan(&b); got != want {
+			t.Errorf("%x < %x: got %v, want %v", a[:], b[:], got, want)
+		}
+	}
+	if One.LessThan(&One) {
+		t.Error("1 < 1")
+	}
+}
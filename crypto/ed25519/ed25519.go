@@ -47,6 +47,12 @@ func (priv PrivateKey) Public() crypto.PublicKey {
 	return PublicKey(publicKey)
 }
 
+// Wipe overwrites priv with zeroes, on a best-effort basis. Priv is
+// unusable afterward.
+func (priv PrivateKey) Wipe() {
+	edwards25519.Wipe(priv)
+}
+
 // Sign signs the given message with priv.
 // Ed25519 performs two passes over messages to be signed and therefore cannot
 // handle pre-hashed messages. Thus opts.HashFunc() must return zero to
@@ -75,12 +81,14 @@ func GenerateKey(rand io.Reader) (publicKey PublicKey, privateKey PrivateKey, er
 	}
 
 	digest := sha512.Sum512(privateKey[:32])
+	defer edwards25519.Wipe(digest[:])
 	digest[0] &= 248
 	digest[31] &= 127
 	digest[31] |= 64
 
 	var A edwards25519.ExtendedGroupElement
 	var hBytes [32]byte
+	defer edwards25519.Wipe(hBytes[:])
 	copy(hBytes[:], digest[:])
 	edwards25519.GeScalarMultBase(&A, &hBytes)
 	var publicKeyBytes [32]byte
@@ -104,6 +112,9 @@ func Sign(privateKey PrivateKey, message []byte) []byte {
 
 	var digest1, messageDigest, hramDigest [64]byte
 	var expandedSecretKey [32]byte
+	defer edwards25519.Wipe(digest1[:])
+	defer edwards25519.Wipe(messageDigest[:])
+	defer edwards25519.Wipe(expandedSecretKey[:])
 	h.Sum(digest1[:0])
 	copy(expandedSecretKey[:], digest1[:])
 	expandedSecretKey[0] &= 248
@@ -116,6 +127,7 @@ func Sign(privateKey PrivateKey, message []byte) []byte {
 	h.Sum(messageDigest[:0])
 
 	var messageDigestReduced [32]byte
+	defer edwards25519.Wipe(messageDigestReduced[:])
 	edwards25519.ScReduce(&messageDigestReduced, &messageDigest)
 	var R edwards25519.ExtendedGroupElement
 	edwards25519.GeScalarMultBase(&R, &messageDigestReduced)
@@ -143,6 +155,10 @@ func Sign(privateKey PrivateKey, message []byte) []byte {
 
 // Verify reports whether sig is a valid signature of message by publicKey. It
 // will panic if len(publicKey) is not PublicKeySize.
+//
+// On amd64 and arm64 (unless built with the purego tag), signatures
+// are checked with the standard library's assembly-accelerated
+// implementation where that gives the same answer.
 func Verify(publicKey PublicKey, message, sig []byte) bool {
 	if l := len(publicKey); l != PublicKeySize {
 		panic("ed25519: bad public key length: " + strconv.Itoa(l))
@@ -152,7 +168,29 @@ func Verify(publicKey PublicKey, message, sig []byte) bool {
 		return false
 	}
 
+	var s [32]byte
+	copy(s[:], sig[32:])
+	if haveAccel && edwards25519.ScIsCanonical(&s) {
+		return verifyAccel(publicKey, message, sig)
+	}
+	return verifyRef10(publicKey, message, sig)
+}
+
+// verifyRef10 is the portable implementation of Verify, after the
+// length checks.
+func verifyRef10(publicKey PublicKey, message, sig []byte) bool {
 	var A edwards25519.ExtendedGroupElement
+	if !decodeNegated(&A, publicKey) {
+		return false
+	}
+	var Ai [8]edwards25519.CachedGroupElement
+	edwards25519.GePrecomputeVartime(&Ai, &A)
+	return verifyPrecomputed(&Ai, publicKey, message, sig)
+}
+
+// decodeNegated sets A to the negation of the point encoded by
+// publicKey, reporting whether the encoding was valid.
+func decodeNegated(A *edwards25519.ExtendedGroupElement, publicKey PublicKey) bool {
 	var publicKeyBytes [32]byte
 	copy(publicKeyBytes[:], publicKey)
 	if !A.FromBytes(&publicKeyBytes) {
@@ -160,7 +198,12 @@ func Verify(publicKey PublicKey, message, sig []byte) bool {
 	}
 	edwards25519.FeNeg(&A.X, &A.X)
 	edwards25519.FeNeg(&A.T, &A.T)
+	return true
+}
 
+// verifyPrecomputed finishes verifyRef10 given the table of
+// multiples of -A.
+func verifyPrecomputed(Ai *[8]edwards25519.CachedGroupElement, publicKey PublicKey, message, sig []byte) bool {
 	h := sha512.New()
 	h.Write(sig[:32])
 	h.Write(publicKey[:])
@@ -174,7 +217,7 @@ func Verify(publicKey PublicKey, message, sig []byte) bool {
 	var R edwards25519.ProjectiveGroupElement
 	var b [32]byte
 	copy(b[:], sig[32:])
-	edwards25519.GeDoubleScalarMultVartime(&R, &hReduced, &A, &b)
+	edwards25519.GeDoubleScalarMultPrecomputed(&R, &hReduced, Ai, &b)
 
 	var checkR [32]byte
 	R.ToBytes(&checkR)
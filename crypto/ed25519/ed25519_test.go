@@ -182,3 +182,123 @@ func BenchmarkVerification(b *testing.B) {
 		Verify(pub, message, signature)
 	}
 }
+
+func TestWipe(t *testing.T) {
+	_, priv, _ := GenerateKey(rand.Reader)
+	priv.Wipe()
+	if !bytes.Equal(priv, make([]byte, PrivateKeySize)) {
+		t.Errorf("private key not wiped: %x", []byte(priv))
+	}
+}
+
+// TestVerifyAccel checks that Verify, which may use the standard
+// library's accelerated verifier, and a VerifierKey's comb table
+// agree with the ref10 code on valid, corrupted, and non-canonical
+// signatures.
+func TestVerifyAccel(t *testing.T) {
+	l := [32]byte{
+		0xed, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58,
+		0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10,
+	}
+	for i := 0; i < 50; i++ {
+		pub, priv, _ := GenerateKey(rand.Reader)
+		msg := []byte{byte(i)}
+		sig := Sign(priv, msg)
+
+		variants := [][]byte{sig}
+		for _, pos := range []int{0, 31, 32, 62} {
+			bad := append([]byte{}, sig...)
+			bad[pos] ^= 1
+			variants = append(variants, bad)
+		}
+
+		// S+L is a non-canonical encoding of the same S whenever it
+		// still fits in 253 bits.
+		nc := append([]byte{}, sig...)
+		var carry uint16
+		for j := 0; j < 32; j++ {
+			sum := uint16(nc[32+j]) + uint16(l[j]) + carry
+			nc[32+j], carry = byte(sum), sum>>8
+		}
+		if nc[63]&224 == 0 {
+			variants = append(variants, nc)
+		}
+
+		vk, err := NewVerifierKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vk.once.Do(vk.buildComb)
+		for j, v := range variants {
+			want := verifyRef10(pub, msg, v)
+			if got := Verify(pub, msg, v); got != want {
+				t.Errorf("key %d variant %d: Verify = %v, ref10 = %v", i, j, got, want)
+			}
+			if got := vk.verifyComb(msg, v); got != want {
+				t.Errorf("key %d variant %d: comb = %v, ref10 = %v", i, j, got, want)
+			}
+		}
+	}
+}
+
+func TestVerifierKey(t *testing.T) {
+	pub, priv, _ := GenerateKey(rand.Reader)
+	vk, err := NewVerifierKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		msg := []byte{byte(i)}
+		sig := Sign(priv, msg)
+		if !vk.Verify(msg, sig) {
+			t.Errorf("message %d: valid signature rejected", i)
+		}
+		sig[i] ^= 1
+		if vk.Verify(msg, sig) {
+			t.Errorf("message %d: corrupted signature accepted", i)
+		}
+		if got, want := verifyPrecomputed(&vk.table, pub, msg, sig), verifyRef10(pub, msg, sig); got != want {
+			t.Errorf("message %d: precomputed = %v, ref10 = %v", i, got, want)
+		}
+	}
+
+	// y = 2 is not the y-coordinate of any point.
+	if _, err := NewVerifierKey(PublicKey(append([]byte{2}, make([]byte, 31)...))); err != ErrInvalidPublicKey {
+		t.Errorf("NewVerifierKey(invalid) error = %v, want ErrInvalidPublicKey", err)
+	}
+}
+
+func BenchmarkVerifierKey(b *testing.B) {
+	var zero zeroReader
+	pub, priv, err := GenerateKey(zero)
+	if err != nil {
+		b.Fatal(err)
+	}
+	message := []byte("Hello, world!")
+	signature := Sign(priv, message)
+	vk, err := NewVerifierKey(pub)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i <= combAfter; i++ {
+		vk.Verify(message, signature) // the last builds the comb table
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		vk.Verify(message, signature)
+	}
+}
+
+func BenchmarkNewVerifierKey(b *testing.B) {
+	var zero zeroReader
+	pub, _, err := GenerateKey(zero)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < b.N; i++ {
+		vk, _ := NewVerifierKey(pub)
+		vk.once.Do(vk.buildComb)
+	}
+}
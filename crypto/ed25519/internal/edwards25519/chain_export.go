@@ -9,3 +9,21 @@ var (
 	// subtracting group elements.
 	GeSub = geSub
 )
+
+// order is the subgroup order L, little-endian.
+var order = [32]byte{
+	0xed, 0xd3, 0xf5, 0x5c, 0x1a, 0x63, 0x12, 0x58,
+	0xd6, 0x9c, 0xf7, 0xa2, 0xde, 0xf9, 0xde, 0x14,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10,
+}
+
+// ScIsCanonical reports whether s < L, in constant time.
+func ScIsCanonical(s *[32]byte) bool {
+	var borrow uint32
+	for i := range s {
+		d := uint32(s[i]) - uint32(order[i]) - borrow
+		borrow = d >> 31
+	}
+	return borrow == 1
+}
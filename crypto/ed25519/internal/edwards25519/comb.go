@@ -0,0 +1,236 @@
+package edwards25519
+
+import "sync"
+
+// The comb method multiplies a point by a scalar with additions
+// alone, looking each radix-16 digit of the scalar up in a table of
+// the point's multiples, where the sliding-window method of
+// GeDoubleScalarMultVartime doubles once per bit. It is how
+// GeScalarMultBase uses its fixed table of the base point; a
+// CombTable extends it to any point. Its arithmetic is in radix
+// 2^51 (see fe51), with these counterparts of the group element
+// representations above.
+
+type extended51 struct{ X, Y, Z, T fe51 }
+type completed51 struct{ X, Y, Z, T fe51 }
+type projective51 struct{ X, Y, Z fe51 }
+type cached51 struct{ yPlusX, yMinusX, Z, T2d fe51 }
+type preComputed51 struct{ yPlusX, yMinusX, xy2d fe51 }
+
+var d2x51 fe51
+
+func init() {
+	fe51FromFe(&d2x51, &d2)
+}
+
+func (p *extended51) zero() {
+	*p = extended51{}
+	p.Y[0], p.Z[0] = 1, 1
+}
+
+func (p *extended51) toCached(r *cached51) {
+	fe51Add(&r.yPlusX, &p.Y, &p.X)
+	fe51Sub(&r.yMinusX, &p.Y, &p.X)
+	r.Z = p.Z
+	fe51Mul(&r.T2d, &p.T, &d2x51)
+}
+
+func (p *projective51) double(r *completed51) {
+	var t0 fe51
+	fe51Mul(&r.X, &p.X, &p.X)
+	fe51Mul(&r.Z, &p.Y, &p.Y)
+	fe51Mul(&r.T, &p.Z, &p.Z)
+	fe51Add(&r.T, &r.T, &r.T)
+	fe51Add(&r.Y, &p.X, &p.Y)
+	fe51Mul(&t0, &r.Y, &r.Y)
+	fe51Add(&r.Y, &r.Z, &r.X)
+	fe51Sub(&r.Z, &r.Z, &r.X)
+	fe51Sub(&r.X, &t0, &r.Y)
+	fe51Sub(&r.T, &r.T, &r.Z)
+}
+
+func (p *completed51) toProjective(r *projective51) {
+	fe51Mul(&r.X, &p.X, &p.T)
+	fe51Mul(&r.Y, &p.Y, &p.Z)
+	fe51Mul(&r.Z, &p.Z, &p.T)
+}
+
+func (p *completed51) toExtended(r *extended51) {
+	fe51Mul(&r.X, &p.X, &p.T)
+	fe51Mul(&r.Y, &p.Y, &p.Z)
+	fe51Mul(&r.Z, &p.Z, &p.T)
+	fe51Mul(&r.T, &p.X, &p.Y)
+}
+
+// add51 sets r = p + q, or p - q if neg is set.
+func add51(r *completed51, p *extended51, q *cached51, neg bool) {
+	var t0 fe51
+	yPlusX, yMinusX := &q.yPlusX, &q.yMinusX
+	if neg {
+		yPlusX, yMinusX = yMinusX, yPlusX
+	}
+	fe51Add(&r.X, &p.Y, &p.X)
+	fe51Sub(&r.Y, &p.Y, &p.X)
+	fe51Mul(&r.Z, &r.X, yPlusX)
+	fe51Mul(&r.Y, &r.Y, yMinusX)
+	fe51Mul(&r.T, &q.T2d, &p.T)
+	fe51Mul(&r.X, &p.Z, &q.Z)
+	fe51Add(&t0, &r.X, &r.X)
+	fe51Sub(&r.X, &r.Z, &r.Y)
+	fe51Add(&r.Y, &r.Z, &r.Y)
+	if neg {
+		fe51Sub(&r.Z, &t0, &r.T)
+		fe51Add(&r.T, &t0, &r.T)
+	} else {
+		fe51Add(&r.Z, &t0, &r.T)
+		fe51Sub(&r.T, &t0, &r.T)
+	}
+}
+
+// mixedAdd51 is add51 for a point with Z = 1.
+func mixedAdd51(r *completed51, p *extended51, q *preComputed51, neg bool) {
+	var t0 fe51
+	yPlusX, yMinusX := &q.yPlusX, &q.yMinusX
+	if neg {
+		yPlusX, yMinusX = yMinusX, yPlusX
+	}
+	fe51Add(&r.X, &p.Y, &p.X)
+	fe51Sub(&r.Y, &p.Y, &p.X)
+	fe51Mul(&r.Z, &r.X, yPlusX)
+	fe51Mul(&r.Y, &r.Y, yMinusX)
+	fe51Mul(&r.T, &q.xy2d, &p.T)
+	fe51Add(&t0, &p.Z, &p.Z)
+	fe51Sub(&r.X, &r.Z, &r.Y)
+	fe51Add(&r.Y, &r.Z, &r.Y)
+	if neg {
+		fe51Sub(&r.Z, &t0, &r.T)
+		fe51Add(&r.T, &t0, &r.T)
+	} else {
+		fe51Add(&r.Z, &t0, &r.T)
+		fe51Sub(&r.T, &t0, &r.T)
+	}
+}
+
+// CombTable holds the multiples of a point A used by
+// GeDoubleScalarMultComb: entry [i][j] is (j+1)·256^i·A. Building it
+// costs about as much as two scalar multiplications, and it takes
+// 40 KiB, so it pays only for a point multiplied many times.
+type CombTable [32][8]cached51
+
+// GeComputeCombTable fills t with the multiples of A.
+func GeComputeCombTable(t *CombTable, A *ExtendedGroupElement) {
+	var (
+		c completed51
+		u extended51
+		s projective51
+		P extended51 // 256^i·A
+	)
+	fe51FromFe(&P.X, &A.X)
+	fe51FromFe(&P.Y, &A.Y)
+	fe51FromFe(&P.Z, &A.Z)
+	fe51FromFe(&P.T, &A.T)
+	for i := range t {
+		P.toCached(&t[i][0])
+		s = projective51{P.X, P.Y, P.Z}
+		s.double(&c)
+		c.toExtended(&u)
+		u.toCached(&t[i][1])
+		for j := 2; j < 8; j++ {
+			add51(&c, &u, &t[i][0], false)
+			c.toExtended(&u)
+			u.toCached(&t[i][j])
+		}
+
+		s = projective51{P.X, P.Y, P.Z}
+		for k := 0; k < 7; k++ {
+			s.double(&c)
+			c.toProjective(&s)
+		}
+		s.double(&c)
+		c.toExtended(&P)
+	}
+}
+
+var (
+	base51     *[32][8]preComputed51 // base, in radix 2^51
+	base51Once sync.Once
+)
+
+func initBase51() {
+	base51 = new([32][8]preComputed51)
+	for i := range base {
+		for j := range base[i] {
+			b, b51 := &base[i][j], &base51[i][j]
+			fe51FromFe(&b51.yPlusX, &b.yPlusX)
+			fe51FromFe(&b51.yMinusX, &b.yMinusX)
+			fe51FromFe(&b51.xy2d, &b.xy2d)
+		}
+	}
+}
+
+// GeDoubleScalarMultComb sets out to the encoding of a*A + b*B,
+// the point GeDoubleScalarMultVartime computes, given the comb table
+// of A. Both a and b must be less than 2^255. It is not
+// constant-time.
+//
+// It takes 128 additions and 4 doublings, where
+// GeDoubleScalarMultVartime takes about 85 additions and 253
+// doublings.
+func GeDoubleScalarMultComb(out *[32]byte, a *[32]byte, At *CombTable, b *[32]byte) {
+	base51Once.Do(initBase51)
+
+	var (
+		ea, eb [64]int8
+		c      completed51
+		h      extended51
+		s      projective51
+	)
+	signedRadix16(&ea, a)
+	signedRadix16(&eb, b)
+
+	add := func(i int) {
+		if d := ea[i]; d > 0 {
+			add51(&c, &h, &At[i/2][d-1], false)
+			c.toExtended(&h)
+		} else if d < 0 {
+			add51(&c, &h, &At[i/2][-d-1], true)
+			c.toExtended(&h)
+		}
+		if d := eb[i]; d > 0 {
+			mixedAdd51(&c, &h, &base51[i/2][d-1], false)
+			c.toExtended(&h)
+		} else if d < 0 {
+			mixedAdd51(&c, &h, &base51[i/2][-d-1], true)
+			c.toExtended(&h)
+		}
+	}
+
+	h.zero()
+	for i := 1; i < 64; i += 2 {
+		add(i)
+	}
+
+	s = projective51{h.X, h.Y, h.Z}
+	for k := 0; k < 3; k++ {
+		s.double(&c)
+		c.toProjective(&s)
+	}
+	s.double(&c)
+	c.toExtended(&h)
+
+	for i := 0; i < 64; i += 2 {
+		add(i)
+	}
+	h.toBytes(out)
+}
+
+func (p *extended51) toBytes(s *[32]byte) {
+	var recip, x, y fe51
+	fe51Invert(&recip, &p.Z)
+	fe51Mul(&x, &p.X, &recip)
+	fe51Mul(&y, &p.Y, &recip)
+	var xb [32]byte
+	fe51ToBytes(&xb, &x)
+	fe51ToBytes(s, &y)
+	s[31] ^= (xb[0] & 1) << 7
+}
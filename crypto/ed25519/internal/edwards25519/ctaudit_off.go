@@ -0,0 +1,9 @@
+//go:build !ctaudit
+// +build !ctaudit
+
+package edwards25519
+
+// ConstantTimeAudit reports whether this package was built with the
+// ctaudit tag, which routes scalar multiplication through a
+// table-free constant-time ladder.
+const ConstantTimeAudit = false
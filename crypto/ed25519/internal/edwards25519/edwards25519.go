@@ -876,14 +876,16 @@ func slide(r *[256]int8, a *[32]byte) {
 // and b = b[0]+256*b[1]+...+256^31 b[31].
 // B is the Ed25519 base point (x,4/5) with x positive.
 func GeDoubleScalarMultVartime(r *ProjectiveGroupElement, a *[32]byte, A *ExtendedGroupElement, b *[32]byte) {
-	var aSlide, bSlide [256]int8
-	var Ai [8]CachedGroupElement // A,3A,5A,7A,9A,11A,13A,15A
+	var Ai [8]CachedGroupElement
+	GePrecomputeVartime(&Ai, A)
+	GeDoubleScalarMultPrecomputed(r, a, &Ai, b)
+}
+
+// GePrecomputeVartime fills Ai with the odd multiples A, 3A, 5A, ...,
+// 15A used by GeDoubleScalarMultPrecomputed.
+func GePrecomputeVartime(Ai *[8]CachedGroupElement, A *ExtendedGroupElement) {
 	var t CompletedGroupElement
 	var u, A2 ExtendedGroupElement
-	var i int
-
-	slide(&aSlide, a)
-	slide(&bSlide, b)
 
 	A.ToCached(&Ai[0])
 	A.Double(&t)
@@ -894,6 +896,19 @@ func GeDoubleScalarMultVartime(r *ProjectiveGroupElement, a *[32]byte, A *Extend
 		t.ToExtended(&u)
 		u.ToCached(&Ai[i+1])
 	}
+}
+
+// GeDoubleScalarMultPrecomputed is GeDoubleScalarMultVartime with
+// the table of multiples of A computed in advance by
+// GePrecomputeVartime.
+func GeDoubleScalarMultPrecomputed(r *ProjectiveGroupElement, a *[32]byte, Ai *[8]CachedGroupElement, b *[32]byte) {
+	var aSlide, bSlide [256]int8
+	var t CompletedGroupElement
+	var u ExtendedGroupElement
+	var i int
+
+	slide(&aSlide, a)
+	slide(&bSlide, b)
 
 	r.Zero()
 
@@ -966,9 +981,20 @@ func selectPoint(t *PreComputedGroupElement, pos int32, b int32) {
 //
 // Preconditions:
 //   a[31] <= 127
+//
+// When built with the ctaudit tag, this uses GeScalarMultLadder
+// instead of the precomputed table.
 func GeScalarMultBase(h *ExtendedGroupElement, a *[32]byte) {
-	var e [64]int8
+	if ConstantTimeAudit {
+		GeScalarMultLadder(h, a, &basePoint)
+		return
+	}
+	geScalarMultBaseTable(h, a)
+}
 
+// signedRadix16 sets e to the digits of a in radix 16, each between
+// -8 and 8, least significant first. a[31] must be at most 127.
+func signedRadix16(e *[64]int8, a *[32]byte) {
 	for i, v := range a {
 		e[2*i] = int8(v & 15)
 		e[2*i+1] = int8((v >> 4) & 15)
@@ -984,6 +1010,11 @@ func GeScalarMultBase(h *ExtendedGroupElement, a *[32]byte) {
 	}
 	e[63] += carry
 	// each e[i] is between -8 and 8.
+}
+
+func geScalarMultBaseTable(h *ExtendedGroupElement, a *[32]byte) {
+	var e [64]int8
+	signedRadix16(&e, a)
 
 	h.Zero()
 	var t PreComputedGroupElement
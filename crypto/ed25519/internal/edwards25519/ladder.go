@@ -0,0 +1,53 @@
+package edwards25519
+
+// basePoint is the ed25519 base point, for use by the ladder.
+var basePoint ExtendedGroupElement
+
+func init() {
+	geScalarMultBaseTable(&basePoint, &[32]byte{1})
+}
+
+// GeScalarMultLadder computes h = a*P with a Montgomery ladder: one
+// addition and one doubling for every bit of a, with the operands
+// chosen by constant-time swaps rather than by branches or table
+// lookups. It is several times slower than GeScalarMultBase's
+// table-based method, but neither its running time nor its memory
+// access pattern depends on a. H and P may be the same pointer.
+func GeScalarMultLadder(h *ExtendedGroupElement, a *[32]byte, P *ExtendedGroupElement) {
+	var (
+		r0, r1 ExtendedGroupElement
+		c      CachedGroupElement
+		t      CompletedGroupElement
+	)
+	r0.Zero()
+	r1 = *P
+	for i := 255; i >= 0; i-- {
+		b := int32(a[i/8]>>uint(i%8)) & 1
+		geCSwap(&r0, &r1, b)
+		r1.ToCached(&c)
+		geAdd(&t, &r0, &c)
+		t.ToExtended(&r1)
+		r0.Double(&t)
+		t.ToExtended(&r0)
+		geCSwap(&r0, &r1, b)
+	}
+	*h = r0
+}
+
+// geCSwap swaps p and q if b is 1 and leaves them alone if b is 0,
+// in constant time.
+func geCSwap(p, q *ExtendedGroupElement, b int32) {
+	feCSwap(&p.X, &q.X, b)
+	feCSwap(&p.Y, &q.Y, b)
+	feCSwap(&p.Z, &q.Z, b)
+	feCSwap(&p.T, &q.T, b)
+}
+
+func feCSwap(f, g *FieldElement, b int32) {
+	mask := -b
+	for i := range f {
+		x := mask & (f[i] ^ g[i])
+		f[i] ^= x
+		g[i] ^= x
+	}
+}
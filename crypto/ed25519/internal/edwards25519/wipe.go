@@ -0,0 +1,14 @@
+package edwards25519
+
+import "runtime"
+
+// Wipe overwrites b with zeroes. It is a best-effort measure for
+// shortening the lifetime of secret material in memory: it cannot
+// reach copies made by the runtime (e.g. when a stack grows) or held
+// inside hash states.
+func Wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+	runtime.KeepAlive(b)
+}
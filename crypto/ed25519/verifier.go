@@ -0,0 +1,107 @@
+package ed25519
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"i10r.io/crypto/ed25519/internal/edwards25519"
+)
+
+// ErrInvalidPublicKey is returned by NewVerifierKey when its input is
+// not the encoding of a curve point.
+var ErrInvalidPublicKey = errors.New("ed25519: invalid public key")
+
+// VerifierKey is a public key expanded for repeated verification:
+// the point is decompressed once rather than on every call, and, once
+// the key has verified a few signatures, a comb table of the point's
+// multiples (see edwards25519.CombTable) replaces the doublings of
+// verification with table lookups. It is intended for keys that
+// verify many signatures, such as block signers' keys.
+//
+// With the table, verification takes about 60% of the time of the
+// package-level Verify, even where that uses the assembly-accelerated
+// standard library (see BenchmarkVerifierKey). Building the table
+// costs about as much as two and a half verifications, and it takes
+// 40 KiB, so keys that verify only a signature or two do without.
+//
+// A VerifierKey is safe for concurrent use.
+type VerifierKey struct {
+	pub   PublicKey
+	table [8]edwards25519.CachedGroupElement // -A, -3A, ..., -15A
+
+	uses int32 // signatures verified, up to combAfter
+	once sync.Once
+	comb *edwards25519.CombTable // of -A; set by once
+}
+
+// combAfter is the number of verifications after which a VerifierKey
+// builds its comb table.
+const combAfter = 4
+
+// NewVerifierKey expands pub. It returns ErrInvalidPublicKey if pub
+// does not decode to a point, in which case no signature could
+// verify under it.
+func NewVerifierKey(pub PublicKey) (*VerifierKey, error) {
+	if len(pub) != PublicKeySize {
+		return nil, ErrInvalidPublicKey
+	}
+	vk := &VerifierKey{pub: append(PublicKey(nil), pub...)}
+	var A edwards25519.ExtendedGroupElement
+	if !decodeNegated(&A, pub) {
+		return nil, ErrInvalidPublicKey
+	}
+	edwards25519.GePrecomputeVartime(&vk.table, &A)
+	return vk, nil
+}
+
+// PublicKey returns the key that vk was made from.
+func (vk *VerifierKey) PublicKey() PublicKey {
+	return vk.pub
+}
+
+// Verify reports whether sig is a valid signature of message by vk.
+// It gives the same result as the package-level Verify.
+func (vk *VerifierKey) Verify(message, sig []byte) bool {
+	if len(sig) != SignatureSize || sig[63]&224 != 0 {
+		return false
+	}
+	if atomic.LoadInt32(&vk.uses) >= combAfter || atomic.AddInt32(&vk.uses, 1) > combAfter {
+		vk.once.Do(vk.buildComb)
+		return vk.verifyComb(message, sig)
+	}
+	var s [32]byte
+	copy(s[:], sig[32:])
+	if haveAccel && edwards25519.ScIsCanonical(&s) {
+		return verifyAccel(vk.pub, message, sig)
+	}
+	return verifyPrecomputed(&vk.table, vk.pub, message, sig)
+}
+
+func (vk *VerifierKey) buildComb() {
+	var A edwards25519.ExtendedGroupElement
+	decodeNegated(&A, vk.pub) // succeeded in NewVerifierKey
+	comb := new(edwards25519.CombTable)
+	edwards25519.GeComputeCombTable(comb, &A)
+	vk.comb = comb
+}
+
+// verifyComb is verifyPrecomputed using vk's comb table.
+func (vk *VerifierKey) verifyComb(message, sig []byte) bool {
+	h := sha512.New()
+	h.Write(sig[:32])
+	h.Write(vk.pub)
+	h.Write(message)
+	var digest [64]byte
+	h.Sum(digest[:0])
+
+	var hReduced [32]byte
+	edwards25519.ScReduce(&hReduced, &digest)
+
+	var b, checkR [32]byte
+	copy(b[:], sig[32:])
+	edwards25519.GeDoubleScalarMultComb(&checkR, &hReduced, vk.comb, &b)
+	return subtle.ConstantTimeCompare(sig[:32], checkR[:]) == 1
+}
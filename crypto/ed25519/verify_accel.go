@@ -0,0 +1,22 @@
+//go:build (amd64 || arm64) && !purego
+// +build amd64 arm64
+// +build !purego
+
+package ed25519
+
+import stded25519 "crypto/ed25519"
+
+// haveAccel is true on platforms where the standard library's
+// edwards25519 field arithmetic is assembly-accelerated.
+const haveAccel = true
+
+// verifyAccel verifies sig with the standard library. It must only be
+// called with a signature whose S component is canonical (< L);
+// for those, the standard library and the ref10 code below accept
+// exactly the same signatures. (The standard library rejects
+// non-canonical S outright, which the ref10 code historically did not,
+// so such signatures are left to the ref10 code to keep validation
+// results unchanged.)
+func verifyAccel(publicKey PublicKey, message, sig []byte) bool {
+	return stded25519.Verify(stded25519.PublicKey(publicKey), message, sig)
+}
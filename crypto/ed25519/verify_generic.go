@@ -0,0 +1,10 @@
+//go:build !(amd64 || arm64) || purego
+// +build !amd64,!arm64 purego
+
+package ed25519
+
+const haveAccel = false
+
+func verifyAccel(publicKey PublicKey, message, sig []byte) bool {
+	panic("ed25519: no accelerated verifier")
+}
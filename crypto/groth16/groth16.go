@@ -0,0 +1,150 @@
+// Package groth16 verifies Groth16 zk-SNARK proofs over BLS12-381.
+//
+// A proof (A, B, C) is valid for public inputs x₁…xₙ under the
+// verifying key (α, β, γ, δ, IC₀…ICₙ) if
+//
+//	e(A, B) = e(α, β)·e(IC₀ + Σ xᵢ·ICᵢ, γ)·e(C, δ)
+//
+// Points use the compressed encodings of package bls.
+package groth16
+
+import (
+	"math/big"
+
+	"i10r.io/crypto/bls"
+	"i10r.io/errors"
+)
+
+// ProofSize is the length of an encoded proof: A, B, and C.
+const ProofSize = bls.G1Size + bls.G2Size + bls.G1Size
+
+// ScalarSize is the length of an encoded public input.
+const ScalarSize = 32
+
+// ErrEncoding is returned for a malformed verifying key, proof, or
+// public input.
+var ErrEncoding = errors.New("invalid groth16 encoding")
+
+// VerifyingKey is a Groth16 verifying key.
+type VerifyingKey struct {
+	Alpha              *bls.G1Point
+	Beta, Gamma, Delta *bls.G2Point
+	IC                 []*bls.G1Point // one more than the number of public inputs
+}
+
+// Proof is a Groth16 proof.
+type Proof struct {
+	A *bls.G1Point
+	B *bls.G2Point
+	C *bls.G1Point
+}
+
+// Inputs returns the number of public inputs vk expects.
+func (vk *VerifyingKey) Inputs() int {
+	return len(vk.IC) - 1
+}
+
+// Bytes encodes vk as α, β, γ, δ, then each ICᵢ.
+func (vk *VerifyingKey) Bytes() []byte {
+	b := vk.Alpha.Bytes()
+	b = append(b, vk.Beta.Bytes()...)
+	b = append(b, vk.Gamma.Bytes()...)
+	b = append(b, vk.Delta.Bytes()...)
+	for _, ic := range vk.IC {
+		b = append(b, ic.Bytes()...)
+	}
+	return b
+}
+
+// ParseVerifyingKey decodes a verifying key encoded by Bytes.
+func ParseVerifyingKey(b []byte) (*VerifyingKey, error) {
+	const fixed = bls.G1Size + 3*bls.G2Size
+	if len(b) < fixed+bls.G1Size || (len(b)-fixed)%bls.G1Size != 0 {
+		return nil, errors.WithDetailf(ErrEncoding, "verifying key is %d bytes", len(b))
+	}
+	var (
+		vk  VerifyingKey
+		err error
+	)
+	if vk.Alpha, err = bls.ParseG1(b[:bls.G1Size]); err != nil {
+		return nil, errors.WithDetail(ErrEncoding, "alpha")
+	}
+	b = b[bls.G1Size:]
+	for _, pt := range []**bls.G2Point{&vk.Beta, &vk.Gamma, &vk.Delta} {
+		if *pt, err = bls.ParseG2(b[:bls.G2Size]); err != nil {
+			return nil, errors.WithDetail(ErrEncoding, "beta, gamma, or delta")
+		}
+		b = b[bls.G2Size:]
+	}
+	for len(b) > 0 {
+		ic, err := bls.ParseG1(b[:bls.G1Size])
+		if err != nil {
+			return nil, errors.WithDetailf(ErrEncoding, "IC %d", len(vk.IC))
+		}
+		vk.IC = append(vk.IC, ic)
+		b = b[bls.G1Size:]
+	}
+	return &vk, nil
+}
+
+// Bytes encodes proof as A, B, then C.
+func (proof *Proof) Bytes() []byte {
+	b := proof.A.Bytes()
+	b = append(b, proof.B.Bytes()...)
+	return append(b, proof.C.Bytes()...)
+}
+
+// ParseProof decodes a proof encoded by Bytes.
+func ParseProof(b []byte) (*Proof, error) {
+	if len(b) != ProofSize {
+		return nil, errors.WithDetailf(ErrEncoding, "proof is %d bytes", len(b))
+	}
+	var (
+		proof Proof
+		err   error
+	)
+	if proof.A, err = bls.ParseG1(b[:bls.G1Size]); err != nil {
+		return nil, errors.WithDetail(ErrEncoding, "A")
+	}
+	if proof.B, err = bls.ParseG2(b[bls.G1Size : bls.G1Size+bls.G2Size]); err != nil {
+		return nil, errors.WithDetail(ErrEncoding, "B")
+	}
+	if proof.C, err = bls.ParseG1(b[bls.G1Size+bls.G2Size:]); err != nil {
+		return nil, errors.WithDetail(ErrEncoding, "C")
+	}
+	return &proof, nil
+}
+
+// ParseScalar decodes a 32-byte big-endian public input, which must
+// be less than bls.Order.
+func ParseScalar(b []byte) (*big.Int, error) {
+	if len(b) != ScalarSize {
+		return nil, errors.WithDetailf(ErrEncoding, "input is %d bytes", len(b))
+	}
+	x := new(big.Int).SetBytes(b)
+	if x.Cmp(bls.Order) >= 0 {
+		return nil, errors.WithDetail(ErrEncoding, "input out of range")
+	}
+	return x, nil
+}
+
+// Verify reports whether proof is valid for the public inputs under
+// vk. It returns an error if the number of inputs is wrong or an
+// input is out of range.
+func Verify(vk *VerifyingKey, proof *Proof, inputs []*big.Int) (bool, error) {
+	if len(inputs) != vk.Inputs() {
+		return false, errors.WithDetailf(ErrEncoding, "got %d inputs, want %d", len(inputs), vk.Inputs())
+	}
+	l := vk.IC[0]
+	for i, x := range inputs {
+		if x.Sign() < 0 || x.Cmp(bls.Order) >= 0 {
+			return false, errors.WithDetailf(ErrEncoding, "input %d out of range", i)
+		}
+		l = l.Add(vk.IC[i+1].ScalarMult(x))
+	}
+	ok := bls.PairingCheck(
+		[]*bls.G1Point{proof.A.Neg(), vk.Alpha, l, proof.C},
+		[]*bls.G2Point{proof.B, vk.Beta, vk.Gamma, vk.Delta},
+	)
+	return ok, nil
+}
@@ -0,0 +1,85 @@
+package groth16
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"i10r.io/crypto/bls"
+)
+
+func randScalar(t *testing.T) *big.Int {
+	k, err := rand.Int(rand.Reader, bls.Order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// simulate returns a verifying key and a proof valid for inputs,
+// made with knowledge of the key's trapdoor rather than of any
+// circuit witness.
+func simulate(t *testing.T, inputs []*big.Int) (*VerifyingKey, *Proof) {
+	g1, g2 := bls.G1Generator(), bls.G2Generator()
+	alpha, beta, gamma, delta := randScalar(t), randScalar(t), randScalar(t), randScalar(t)
+	vk := &VerifyingKey{
+		Alpha: g1.ScalarMult(alpha),
+		Beta:  g2.ScalarMult(beta),
+		Gamma: g2.ScalarMult(gamma),
+		Delta: g2.ScalarMult(delta),
+	}
+	ic := []*big.Int{randScalar(t)}
+	vk.IC = append(vk.IC, g1.ScalarMult(ic[0]))
+	for range inputs {
+		k := randScalar(t)
+		ic = append(ic, k)
+		vk.IC = append(vk.IC, g1.ScalarMult(k))
+	}
+	// l = ic₀ + Σ xᵢ·icᵢ; choose a and b, then c = (ab - αβ - lγ)/δ.
+	l := new(big.Int).Set(ic[0])
+	for i, x := range inputs {
+		l.Add(l, new(big.Int).Mul(x, ic[i+1]))
+	}
+	a, b := randScalar(t), randScalar(t)
+	c := new(big.Int).Mul(a, b)
+	c.Sub(c, new(big.Int).Mul(alpha, beta))
+	c.Sub(c, new(big.Int).Mul(l, gamma))
+	c.Mul(c, new(big.Int).ModInverse(delta, bls.Order))
+	c.Mod(c, bls.Order)
+	proof := &Proof{A: g1.ScalarMult(a), B: g2.ScalarMult(b), C: g1.ScalarMult(c)}
+	return vk, proof
+}
+
+func TestVerify(t *testing.T) {
+	inputs := []*big.Int{big.NewInt(42), big.NewInt(7)}
+	vk, proof := simulate(t, inputs)
+
+	vk, err := ParseVerifyingKey(vk.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err = ParseProof(proof.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Verify(vk, proof, inputs); err != nil || !ok {
+		t.Errorf("Verify(valid proof) = %v, %v", ok, err)
+	}
+	if ok, err := Verify(vk, proof, []*big.Int{big.NewInt(42), big.NewInt(8)}); err != nil || ok {
+		t.Errorf("Verify(wrong input) = %v, %v; want false", ok, err)
+	}
+	bad := *proof
+	bad.C = bad.C.Add(bls.G1Generator())
+	if ok, _ := Verify(vk, &bad, inputs); ok {
+		t.Error("Verify accepts a tampered proof")
+	}
+	if _, err := Verify(vk, proof, inputs[:1]); err == nil {
+		t.Error("Verify accepts the wrong number of inputs")
+	}
+	if _, err := ParseProof(proof.Bytes()[1:]); err == nil {
+		t.Error("ParseProof accepts a short proof")
+	}
+	if _, err := ParseScalar(bls.Order.FillBytes(make([]byte, ScalarSize))); err == nil {
+		t.Error("ParseScalar accepts the group order")
+	}
+}
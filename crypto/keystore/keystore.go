@@ -0,0 +1,543 @@
+// Package keystore stores private keys, plain Ed25519 keys and
+// chainkd extended keys, in a file encrypted under a passphrase.
+//
+// The file is JSON. It names its format version and the parameters of
+// the Argon2id derivation of its encryption key from the passphrase,
+// and holds each key sealed with XChaCha20-Poly1305, under a random
+// nonce, with its kind and public key as additional data. The public
+// keys are readable without the passphrase.
+//
+// Keys have names. Rotating a key retires it in favor of a fresh one
+// of the same name; retired keys stay in the store, so that what they
+// signed for stays verifiable and what they control stays
+// spendable, but sign only when asked for by their public keys.
+//
+// Both the wallet of cmd/txvmcli and the block signer of cmd/txvmd
+// sign through the Signer interface, which a Keystore, and a Plain
+// set of unencrypted keys, implement.
+package keystore
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/ed25519/chainkd"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+)
+
+// Version is the version of the file format written.
+const Version = 1
+
+// Errors
+var (
+	ErrPassphrase = errors.New("wrong passphrase")
+	ErrFormat     = errors.New("malformed keystore")
+	ErrVersion    = errors.New("unknown keystore version")
+	ErrNoKey      = errors.New("no such key")
+	ErrExists     = errors.New("key exists")
+	ErrKind       = errors.New("wrong kind of key")
+)
+
+// Kind is a kind of key.
+type Kind string
+
+// Kinds of keys
+const (
+	Ed25519 Kind = "ed25519" // a plain Ed25519 private key
+	ChainKD Kind = "chainkd" // a chainkd extended private key
+)
+
+// Params are the Argon2id parameters for deriving a store's
+// encryption key; see package golang.org/x/crypto/argon2.
+type Params struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"` // in KiB
+	Threads uint8  `json:"threads"`
+}
+
+// DefaultParams are the parameters RFC 9106 recommends for
+// memory-constrained uses.
+var DefaultParams = Params{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// Signer signs messages with named keys.
+type Signer interface {
+	// Sign signs msg with the named key or, if it is an extended
+	// key, the key derived from it along path.
+	Sign(ctx context.Context, name string, path [][]byte, msg []byte) ([]byte, error)
+}
+
+// KeyInfo describes a key in a store.
+type KeyInfo struct {
+	Name      string             `json:"name"`
+	Kind      Kind               `json:"kind"`
+	Public    chainjson.HexBytes `json:"public"` // public key, or xpub
+	CreatedMS uint64             `json:"created_ms"`
+	RetiredMS uint64             `json:"retired_ms,omitempty"`
+}
+
+// file is the JSON form of a store.
+type file struct {
+	Version int                `json:"version"`
+	KDF     kdf                `json:"kdf"`
+	Check   chainjson.HexBytes `json:"check"` // sealed checkValue
+	Keys    []*sealedKey       `json:"keys"`
+}
+
+type kdf struct {
+	Name string             `json:"name"`
+	Salt chainjson.HexBytes `json:"salt"`
+	Params
+}
+
+type sealedKey struct {
+	KeyInfo
+	Sealed chainjson.HexBytes `json:"sealed"` // nonce followed by ciphertext
+}
+
+// checkValue is sealed in each store so that a wrong passphrase is
+// detected even in a store with no keys.
+var checkValue = []byte("txvm keystore")
+
+type key struct {
+	KeyInfo
+	secret []byte
+}
+
+// Keystore is an encrypted store of keys in a file. It is safe for
+// concurrent use.
+type Keystore struct {
+	mu       sync.Mutex
+	filename string
+	kdf      kdf
+	aead     cipher.AEAD
+	keys     []*key
+}
+
+// Create creates a store with no keys in filename, which must not
+// exist, encrypted under passphrase with params, or DefaultParams if
+// params is nil.
+func Create(filename string, passphrase []byte, params *Params) (*Keystore, error) {
+	if _, err := os.Stat(filename); err == nil {
+		return nil, errors.Wrapf(os.ErrExist, "creating keystore %s", filename)
+	}
+	ks := &Keystore{filename: filename}
+	err := ks.setPassphrase(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	return ks, ks.save()
+}
+
+// Open opens the store in filename with passphrase.
+func Open(filename string, passphrase []byte) (*Keystore, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	ks, err := decode(data, passphrase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening keystore %s", filename)
+	}
+	ks.filename = filename
+	return ks, nil
+}
+
+func (ks *Keystore) setPassphrase(passphrase []byte, params *Params) error {
+	if params == nil {
+		params = &DefaultParams
+	}
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return err
+	}
+	k := kdf{Name: "argon2id", Salt: salt, Params: *params}
+	aead, err := k.aead(passphrase)
+	if err != nil {
+		return err
+	}
+	ks.kdf, ks.aead = k, aead
+	return nil
+}
+
+func (k *kdf) aead(passphrase []byte) (cipher.AEAD, error) {
+	if k.Name != "argon2id" {
+		return nil, errors.WithDetailf(ErrFormat, "unknown key derivation function %q", k.Name)
+	}
+	if k.Time < 1 || k.Threads < 1 {
+		return nil, errors.WithDetailf(ErrFormat, "bad argon2id parameters %+v", k.Params)
+	}
+	key := argon2.IDKey(passphrase, k.Salt, k.Time, k.Memory, k.Threads, chacha20poly1305.KeySize)
+	return chacha20poly1305.NewX(key)
+}
+
+func decode(data, passphrase []byte) (*Keystore, error) {
+	var f file
+	err := json.Unmarshal(data, &f)
+	if err != nil {
+		return nil, errors.Sub(ErrFormat, err)
+	}
+	if f.Version != Version {
+		return nil, errors.WithDetailf(ErrVersion, "version %d", f.Version)
+	}
+	aead, err := f.KDF.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := open(aead, f.Check, nil); err != nil {
+		return nil, ErrPassphrase
+	}
+	ks := &Keystore{kdf: f.KDF, aead: aead}
+	for _, sk := range f.Keys {
+		secret, err := open(aead, sk.Sealed, additionalData(sk.Kind, sk.Public))
+		if err != nil {
+			return nil, errors.WithDetailf(ErrFormat, "key %s does not open", sk.Name)
+		}
+		k := &key{KeyInfo: sk.KeyInfo, secret: secret}
+		if pub, err := public(k.Kind, secret); err != nil || string(pub) != string(k.Public) {
+			return nil, errors.WithDetailf(ErrFormat, "key %s does not match its public key", sk.Name)
+		}
+		ks.keys = append(ks.keys, k)
+	}
+	return ks, nil
+}
+
+// encode returns the JSON form of keys, sealed under ks's
+// passphrase. The caller must hold ks.mu.
+func (ks *Keystore) encode(keys []*key) ([]byte, error) {
+	f := file{Version: Version, KDF: ks.kdf, Keys: []*sealedKey{}}
+	var err error
+	f.Check, err = seal(ks.aead, checkValue, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		sealed, err := seal(ks.aead, k.secret, additionalData(k.Kind, k.Public))
+		if err != nil {
+			return nil, err
+		}
+		f.Keys = append(f.Keys, &sealedKey{KeyInfo: k.KeyInfo, Sealed: sealed})
+	}
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// save writes ks to its file, replacing it atomically. The caller
+// must hold ks.mu, or otherwise have the only reference to ks.
+func (ks *Keystore) save() error {
+	data, err := ks.encode(ks.keys)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(ks.filename), 0700)
+	if err != nil {
+		return err
+	}
+	tmp := ks.filename + ".tmp"
+	err = ioutil.WriteFile(tmp, append(data, '\n'), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, ks.filename)
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.WithDetail(ErrFormat, "short sealed value")
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, sealed[:n], sealed[n:], additionalData)
+}
+
+func additionalData(kind Kind, pub []byte) []byte {
+	return append([]byte(kind+" "), pub...)
+}
+
+// public returns the public key, or xpub, of a secret of the given
+// kind.
+func public(kind Kind, secret []byte) ([]byte, error) {
+	switch kind {
+	case Ed25519:
+		if len(secret) != ed25519.PrivateKeySize {
+			return nil, errors.WithDetailf(ErrFormat, "%d-byte ed25519 key", len(secret))
+		}
+		return ed25519.PrivateKey(secret).Public().(ed25519.PublicKey), nil
+	case ChainKD:
+		var xprv chainkd.XPrv
+		if len(secret) != len(xprv) {
+			return nil, errors.WithDetailf(ErrFormat, "%d-byte xprv", len(secret))
+		}
+		copy(xprv[:], secret)
+		return xprv.XPub().Bytes(), nil
+	}
+	return nil, errors.WithDetailf(ErrKind, "unknown kind %q", kind)
+}
+
+func generate(kind Kind) ([]byte, error) {
+	switch kind {
+	case Ed25519:
+		_, prv, err := ed25519.GenerateKey(nil)
+		return prv, err
+	case ChainKD:
+		xprv, err := chainkd.NewXPrv(nil)
+		return xprv.Bytes(), err
+	}
+	return nil, errors.WithDetailf(ErrKind, "unknown kind %q", kind)
+}
+
+// current returns the unretired key named name, or nil. The caller
+// must hold ks.mu.
+func (ks *Keystore) current(name string) *key {
+	for _, k := range ks.keys {
+		if k.Name == name && k.RetiredMS == 0 {
+			return k
+		}
+	}
+	return nil
+}
+
+// add adds a key and saves ks. The caller must hold ks.mu.
+func (ks *Keystore) add(name string, kind Kind, secret []byte) (*KeyInfo, error) {
+	if ks.current(name) != nil {
+		return nil, errors.WithDetailf(ErrExists, "key %s", name)
+	}
+	pub, err := public(kind, secret)
+	if err != nil {
+		return nil, err
+	}
+	k := &key{
+		KeyInfo: KeyInfo{Name: name, Kind: kind, Public: pub, CreatedMS: bc.Millis(time.Now())},
+		secret:  append([]byte(nil), secret...),
+	}
+	ks.keys = append(ks.keys, k)
+	err = ks.save()
+	if err != nil {
+		ks.keys = ks.keys[:len(ks.keys)-1]
+		return nil, err
+	}
+	info := k.KeyInfo
+	return &info, nil
+}
+
+// Generate adds a fresh key of the given kind named name, which must
+// not name another unretired key.
+func (ks *Keystore) Generate(name string, kind Kind) (*KeyInfo, error) {
+	secret, err := generate(kind)
+	if err != nil {
+		return nil, err
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.add(name, kind, secret)
+}
+
+// AddKey adds the Ed25519 key prv named name, which must not name
+// another unretired key.
+func (ks *Keystore) AddKey(name string, prv ed25519.PrivateKey) (*KeyInfo, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.add(name, Ed25519, prv)
+}
+
+// AddXPrv adds the extended key xprv named name, which must not name
+// another unretired key.
+func (ks *Keystore) AddXPrv(name string, xprv chainkd.XPrv) (*KeyInfo, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return ks.add(name, ChainKD, xprv.Bytes())
+}
+
+// Keys returns the keys in ks, retired ones included, in the order
+// they were added.
+func (ks *Keystore) Keys() []KeyInfo {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	var infos []KeyInfo
+	for _, k := range ks.keys {
+		infos = append(infos, k.KeyInfo)
+	}
+	return infos
+}
+
+// PublicKey returns the public key of the named key, the root public
+// key for an extended key.
+func (ks *Keystore) PublicKey(name string) (ed25519.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k := ks.current(name)
+	if k == nil {
+		return nil, errors.WithDetailf(ErrNoKey, "key %s", name)
+	}
+	if k.Kind == ChainKD {
+		return ed25519.PublicKey(k.Public[:ed25519.PublicKeySize]), nil
+	}
+	return ed25519.PublicKey(k.Public), nil
+}
+
+// XPub returns the xpub of the named extended key.
+func (ks *Keystore) XPub(name string) (xpub chainkd.XPub, err error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k := ks.current(name)
+	if k == nil {
+		return xpub, errors.WithDetailf(ErrNoKey, "key %s", name)
+	}
+	if k.Kind != ChainKD {
+		return xpub, errors.WithDetailf(ErrKind, "key %s is %s", name, k.Kind)
+	}
+	copy(xpub[:], k.Public)
+	return xpub, nil
+}
+
+// Sign signs msg with the named key or, if it is an extended key,
+// the key derived from it along path. Name may also be the hex
+// public key, or xpub, of a key, retired or not.
+func (ks *Keystore) Sign(_ context.Context, name string, path [][]byte, msg []byte) ([]byte, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	k := ks.current(name)
+	if k == nil {
+		for _, kk := range ks.keys {
+			if hex.EncodeToString(kk.Public) == name {
+				k = kk
+				break
+			}
+		}
+	}
+	if k == nil {
+		return nil, errors.WithDetailf(ErrNoKey, "key %s", name)
+	}
+	return signWith(k.Kind, k.secret, path, msg)
+}
+
+func signWith(kind Kind, secret []byte, path [][]byte, msg []byte) ([]byte, error) {
+	if kind == ChainKD {
+		var xprv chainkd.XPrv
+		copy(xprv[:], secret)
+		return xprv.Derive(path).Sign(msg), nil
+	}
+	if len(path) > 0 {
+		return nil, errors.WithDetail(ErrKind, "derivation path for a plain key")
+	}
+	return ed25519.Sign(ed25519.PrivateKey(secret), msg), nil
+}
+
+// Rotate retires the named key and adds a fresh one of the same kind
+// and name in its place, returning the new key.
+func (ks *Keystore) Rotate(name string) (*KeyInfo, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	old := ks.current(name)
+	if old == nil {
+		return nil, errors.WithDetailf(ErrNoKey, "key %s", name)
+	}
+	secret, err := generate(old.Kind)
+	if err != nil {
+		return nil, err
+	}
+	old.RetiredMS = bc.Millis(time.Now())
+	info, err := ks.add(name, old.Kind, secret)
+	if err != nil {
+		old.RetiredMS = 0
+	}
+	return info, err
+}
+
+// ChangePassphrase re-encrypts ks under passphrase, with params, or
+// DefaultParams if params is nil, and a fresh salt.
+func (ks *Keystore) ChangePassphrase(passphrase []byte, params *Params) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	oldKDF, oldAEAD := ks.kdf, ks.aead
+	err := ks.setPassphrase(passphrase, params)
+	if err == nil {
+		err = ks.save()
+	}
+	if err != nil {
+		ks.kdf, ks.aead = oldKDF, oldAEAD
+	}
+	return err
+}
+
+// Export returns the named key, alone, in the keystore format,
+// encrypted under passphrase with params, or DefaultParams if params
+// is nil, for Import into another store.
+func (ks *Keystore) Export(name string, passphrase []byte, params *Params) ([]byte, error) {
+	ks.mu.Lock()
+	k := ks.current(name)
+	ks.mu.Unlock()
+	if k == nil {
+		return nil, errors.WithDetailf(ErrNoKey, "key %s", name)
+	}
+	exp := new(Keystore)
+	err := exp.setPassphrase(passphrase, params)
+	if err != nil {
+		return nil, err
+	}
+	return exp.encode([]*key{k})
+}
+
+// Import adds to ks the keys of data, an export or a whole store
+// file, opened with passphrase. None of its unretired keys may share
+// a name with an unretired key of ks.
+func (ks *Keystore) Import(data, passphrase []byte) error {
+	imp, err := decode(data, passphrase)
+	if err != nil {
+		return err
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for _, k := range imp.keys {
+		if k.RetiredMS == 0 && ks.current(k.Name) != nil {
+			return errors.WithDetailf(ErrExists, "key %s", k.Name)
+		}
+	}
+	n := len(ks.keys)
+	ks.keys = append(ks.keys, imp.keys...)
+	err = ks.save()
+	if err != nil {
+		ks.keys = ks.keys[:n]
+	}
+	return err
+}
+
+// Plain is a Signer for unencrypted keys held in memory, such as
+// keys kept in plain files from before they were moved into a
+// Keystore.
+type Plain struct {
+	Keys  map[string]ed25519.PrivateKey
+	XPrvs map[string]chainkd.XPrv
+}
+
+// Sign implements Signer.
+func (p *Plain) Sign(_ context.Context, name string, path [][]byte, msg []byte) ([]byte, error) {
+	if xprv, ok := p.XPrvs[name]; ok {
+		return signWith(ChainKD, xprv[:], path, msg)
+	}
+	if prv, ok := p.Keys[name]; ok {
+		return signWith(Ed25519, prv, path, msg)
+	}
+	return nil, errors.WithDetailf(ErrNoKey, "key %s", name)
+}
@@ -0,0 +1,189 @@
+package keystore
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/ed25519/chainkd"
+	"i10r.io/errors"
+	"i10r.io/testutil"
+)
+
+// testParams keep the tests fast.
+var testParams = &Params{Time: 1, Memory: 64, Threads: 1}
+
+func TestKeystore(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "keystore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "keys.json")
+
+	ks, err := Create(filename, []byte("secret"), testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Create(filename, []byte("secret"), testParams); !os.IsExist(errors.Root(err)) {
+		t.Errorf("Create over an existing store: got error %v, want it to exist", err)
+	}
+	_, err = ks.Generate("block", Ed25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ks.AddXPrv("wallet", testutil.TestXPrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Generate("block", ChainKD); errors.Root(err) != ErrExists {
+		t.Errorf("Generate(block) again: got error %v, want %v", err, ErrExists)
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), hex.EncodeToString(testutil.TestXPrv.Bytes())) {
+		t.Error("store file contains the xprv in the clear")
+	}
+
+	if _, err := Open(filename, []byte("wrong")); errors.Root(err) != ErrPassphrase {
+		t.Errorf("Open with the wrong passphrase: got error %v, want %v", err, ErrPassphrase)
+	}
+	ks, err = Open(filename, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := []byte("message")
+	blockPub, err := ks.PublicKey("block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := ks.Sign(ctx, "block", nil, msg)
+	if err != nil || !ed25519.Verify(blockPub, msg, sig) {
+		t.Errorf("Sign(block) = %x, %v; want a valid signature", sig, err)
+	}
+	if _, err := ks.Sign(ctx, "block", [][]byte{{1}}, msg); errors.Root(err) != ErrKind {
+		t.Errorf("Sign(block) with a path: got error %v, want %v", err, ErrKind)
+	}
+	path := [][]byte{{1}, {2}}
+	sig, err = ks.Sign(ctx, "wallet", path, msg)
+	if err != nil || !testutil.TestXPub.Derive(path).Verify(msg, sig) {
+		t.Errorf("Sign(wallet) = %x, %v; want a valid signature", sig, err)
+	}
+	if xpub, err := ks.XPub("wallet"); err != nil || xpub != testutil.TestXPub {
+		t.Errorf("XPub(wallet) = %x, %v; want %x", xpub.Bytes(), err, testutil.TestXPub.Bytes())
+	}
+	if _, err := ks.XPub("block"); errors.Root(err) != ErrKind {
+		t.Errorf("XPub(block): got error %v, want %v", err, ErrKind)
+	}
+	if _, err := ks.Sign(ctx, "nope", nil, msg); errors.Root(err) != ErrNoKey {
+		t.Errorf("Sign(nope): got error %v, want %v", err, ErrNoKey)
+	}
+
+	// Rotation retires the old key, which still signs by its public
+	// key.
+	info, err := ks.Rotate("block")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newPub, _ := ks.PublicKey("block"); string(newPub) == string(blockPub) || string(newPub) != string(info.Public) {
+		t.Errorf("after Rotate, block key %x, want new key %x", newPub, info.Public)
+	}
+	sig, err = ks.Sign(ctx, hex.EncodeToString(blockPub), nil, msg)
+	if err != nil || !ed25519.Verify(blockPub, msg, sig) {
+		t.Errorf("Sign(retired key) = %x, %v; want a valid signature", sig, err)
+	}
+	if keys := ks.Keys(); len(keys) != 3 || keys[0].RetiredMS == 0 || keys[2].RetiredMS != 0 {
+		t.Errorf("keys after rotation = %+v", keys)
+	}
+
+	err = ks.ChangePassphrase([]byte("new secret"), testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(filename, []byte("secret")); errors.Root(err) != ErrPassphrase {
+		t.Errorf("Open with the old passphrase: got error %v, want %v", err, ErrPassphrase)
+	}
+	ks, err = Open(filename, []byte("new secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := ks.Keys(); len(keys) != 3 {
+		t.Errorf("reopened store has %d keys, want 3", len(keys))
+	}
+
+	exp, err := ks.Export("wallet", []byte("transfer"), testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks2, err := Create(filepath.Join(dir, "other.json"), []byte("other"), testParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ks2.Import(exp, []byte("wrong")); errors.Root(err) != ErrPassphrase {
+		t.Errorf("Import with the wrong passphrase: got error %v, want %v", err, ErrPassphrase)
+	}
+	err = ks2.Import(exp, []byte("transfer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if xpub, err := ks2.XPub("wallet"); err != nil || xpub != testutil.TestXPub {
+		t.Errorf("imported XPub(wallet) = %x, %v; want %x", xpub.Bytes(), err, testutil.TestXPub.Bytes())
+	}
+	if err := ks2.Import(exp, []byte("transfer")); errors.Root(err) != ErrExists {
+		t.Errorf("Import again: got error %v, want %v", err, ErrExists)
+	}
+
+	// A key whose ciphertext is moved to another key's entry fails to
+	// open.
+	data, err = ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f struct{ Keys []map[string]interface{} }
+	err = json.Unmarshal(data, &f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := strings.Replace(string(data), f.Keys[0]["sealed"].(string), f.Keys[2]["sealed"].(string), 1)
+	if _, err := decode([]byte(swapped), []byte("new secret")); errors.Root(err) != ErrFormat {
+		t.Errorf("decode with swapped ciphertexts: got error %v, want %v", err, ErrFormat)
+	}
+	if _, err := decode([]byte(strings.Replace(string(data), `"version": 1`, `"version": 2`, 1)), []byte("new secret")); errors.Root(err) != ErrVersion {
+		t.Errorf("decode version 2: got error %v, want %v", err, ErrVersion)
+	}
+}
+
+func TestPlain(t *testing.T) {
+	ctx := context.Background()
+	_, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s Signer = &Plain{
+		Keys:  map[string]ed25519.PrivateKey{"block": prv},
+		XPrvs: map[string]chainkd.XPrv{"wallet": testutil.TestXPrv},
+	}
+	msg := []byte("message")
+	sig, err := s.Sign(ctx, "block", nil, msg)
+	if err != nil || !ed25519.Verify(prv.Public().(ed25519.PublicKey), msg, sig) {
+		t.Errorf("Sign(block) = %x, %v; want a valid signature", sig, err)
+	}
+	sig, err = s.Sign(ctx, "wallet", [][]byte{{3}}, msg)
+	if err != nil || !testutil.TestXPub.Derive([][]byte{{3}}).Verify(msg, sig) {
+		t.Errorf("Sign(wallet) = %x, %v; want a valid signature", sig, err)
+	}
+	if _, err := s.Sign(ctx, "nope", nil, msg); errors.Root(err) != ErrNoKey {
+		t.Errorf("Sign(nope): got error %v, want %v", err, ErrNoKey)
+	}
+}
@@ -0,0 +1,50 @@
+// Package pedersen implements Pedersen commitments to integer values
+// on the ed25519 curve.
+//
+// A commitment to value v with blinding factor r is the point
+//
+//	C = v·B + r·B2
+//
+// where B is the ed25519 base point and B2 is a second generator
+// whose discrete logarithm with respect to B is unknown. Revealing v
+// and r opens the commitment.
+package pedersen
+
+import (
+	"i10r.io/crypto/ed25519/ecmath"
+	"i10r.io/crypto/sha3"
+)
+
+// B2 is the blinding generator. It is derived by hashing the
+// encoding of B with SHA3-256 until the result decodes as a point,
+// then multiplying by the cofactor, so that nobody knows its discrete
+// logarithm.
+var B2 ecmath.Point
+
+func init() {
+	var b ecmath.Point
+	b.ScMulBase(&ecmath.One)
+	h := b.Encode()
+	for {
+		h = sha3.Sum256(h[:])
+		var p ecmath.Point
+		if _, ok := p.Decode(h); ok {
+			B2.ClearCofactor(&p)
+			if !B2.IsIdentity() {
+				return
+			}
+		}
+	}
+}
+
+// Commit returns the commitment to v with blinding factor r.
+func Commit(v, r *ecmath.Scalar) *ecmath.Point {
+	var c ecmath.Point
+	return c.ScMulAdd(&B2, r, v)
+}
+
+// Verify tells whether v and r open the commitment c. It runs in
+// variable time; the opening is public once revealed.
+func Verify(c *ecmath.Point, v, r *ecmath.Scalar) bool {
+	return Commit(v, r).ConstTimeEqual(c)
+}
@@ -0,0 +1,47 @@
+package pedersen
+
+import (
+	"testing"
+
+	"i10r.io/crypto/ed25519/ecmath"
+)
+
+func TestVerify(t *testing.T) {
+	var v, r ecmath.Scalar
+	v.SetUint64(1000)
+	r.SetUint64(123456789)
+	c := Commit(&v, &r)
+	if !Verify(c, &v, &r) {
+		t.Error("failed to verify a correct opening")
+	}
+
+	var v2 ecmath.Scalar
+	v2.SetUint64(1001)
+	if Verify(c, &v2, &r) {
+		t.Error("verified the wrong value")
+	}
+	if Verify(c, &r, &v) {
+		t.Error("verified with the generators swapped")
+	}
+
+	// Commitments are additively homomorphic.
+	var sumV, sumR ecmath.Scalar
+	sumV.Add(&v, &v2)
+	sumR.Add(&r, &r)
+	var sum ecmath.Point
+	sum.Add(c, Commit(&v2, &r))
+	if !Verify(&sum, &sumV, &sumR) {
+		t.Error("sum of commitments does not open to sum of values")
+	}
+}
+
+func TestB2(t *testing.T) {
+	if B2.HasSmallOrder() {
+		t.Error("B2 has small order")
+	}
+	var b ecmath.Point
+	b.ScMulBase(&ecmath.One)
+	if b.ConstTimeEqual(&B2) {
+		t.Error("B2 equals B")
+	}
+}
@@ -0,0 +1,103 @@
+package secp256k1
+
+import "math/big"
+
+// jacobian is a point in Jacobian coordinates: (X/Z², Y/Z³), or the
+// point at infinity if Z is zero.
+type jacobian struct {
+	x, y, z *big.Int
+}
+
+func toJacobian(pub *PublicKey) *jacobian {
+	return &jacobian{new(big.Int).Set(pub.X), new(big.Int).Set(pub.Y), big.NewInt(1)}
+}
+
+func infinity() *jacobian {
+	return &jacobian{new(big.Int), new(big.Int), new(big.Int)}
+}
+
+func (j *jacobian) affine() (x, y *big.Int, ok bool) {
+	if j.z.Sign() == 0 {
+		return nil, nil, false
+	}
+	zinv := new(big.Int).ModInverse(j.z, p)
+	zinv2 := new(big.Int).Mul(zinv, zinv)
+	x = new(big.Int).Mul(j.x, zinv2)
+	x.Mod(x, p)
+	y = new(big.Int).Mul(j.y, zinv2.Mul(zinv2, zinv))
+	y.Mod(y, p)
+	return x, y, true
+}
+
+func mulMod(a, b *big.Int) *big.Int {
+	r := new(big.Int).Mul(a, b)
+	return r.Mod(r, p)
+}
+
+func subMod(a, b *big.Int) *big.Int {
+	r := new(big.Int).Sub(a, b)
+	return r.Mod(r, p)
+}
+
+// double uses the dbl-2009-l formulas for a = 0.
+func double(j *jacobian) *jacobian {
+	if j.z.Sign() == 0 || j.y.Sign() == 0 {
+		return infinity()
+	}
+	a := mulMod(j.x, j.x)
+	b := mulMod(j.y, j.y)
+	c := mulMod(b, b)
+	d := new(big.Int).Add(j.x, b)
+	d = subMod(subMod(mulMod(d, d), a), c)
+	d = mulMod(d, big.NewInt(2))
+	e := mulMod(a, big.NewInt(3))
+	f := mulMod(e, e)
+	x3 := subMod(f, mulMod(d, big.NewInt(2)))
+	y3 := subMod(mulMod(e, subMod(d, x3)), mulMod(c, big.NewInt(8)))
+	z3 := mulMod(mulMod(j.y, j.z), big.NewInt(2))
+	return &jacobian{x3, y3, z3}
+}
+
+// add uses the add-1998-cmo-2 formulas, falling back to double for equal
+// points.
+func add(a, b *jacobian) *jacobian {
+	if a.z.Sign() == 0 {
+		return b
+	}
+	if b.z.Sign() == 0 {
+		return a
+	}
+	z1z1 := mulMod(a.z, a.z)
+	z2z2 := mulMod(b.z, b.z)
+	u1 := mulMod(a.x, z2z2)
+	u2 := mulMod(b.x, z1z1)
+	s1 := mulMod(mulMod(a.y, b.z), z2z2)
+	s2 := mulMod(mulMod(b.y, a.z), z1z1)
+	h := subMod(u2, u1)
+	r := subMod(s2, s1)
+	if h.Sign() == 0 {
+		if r.Sign() == 0 {
+			return double(a)
+		}
+		return infinity()
+	}
+	hh := mulMod(h, h)
+	hhh := mulMod(h, hh)
+	v := mulMod(u1, hh)
+	x3 := subMod(subMod(mulMod(r, r), hhh), mulMod(v, big.NewInt(2)))
+	y3 := subMod(mulMod(r, subMod(v, x3)), mulMod(s1, hhh))
+	z3 := mulMod(mulMod(a.z, b.z), h)
+	return &jacobian{x3, y3, z3}
+}
+
+func scalarMult(pub *PublicKey, k *big.Int) *jacobian {
+	base := toJacobian(pub)
+	acc := infinity()
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		acc = double(acc)
+		if k.Bit(i) == 1 {
+			acc = add(acc, base)
+		}
+	}
+	return acc
+}
@@ -0,0 +1,290 @@
+// Package secp256k1 implements ECDSA verification and public key
+// recovery over the secp256k1 curve, as used by Bitcoin and Ethereum.
+//
+// It exists so that txvm contracts can check statements signed by
+// keys from those systems. It is written for clarity with math/big
+// and is neither fast nor constant-time; Sign is provided for tests
+// and tooling and should not be used with valuable keys.
+package secp256k1
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+
+	"i10r.io/errors"
+)
+
+// Sizes of encoded keys and signatures.
+const (
+	CompressedSize   = 33 // 0x02 or 0x03, then X
+	UncompressedSize = 65 // 0x04, then X and Y
+	SignatureSize    = 64 // R and S, big-endian
+	RecoverableSize  = 65 // R, S, and a recovery ID
+	DigestSize       = 32
+)
+
+var (
+	// ErrPublicKey is returned for a malformed or off-curve public
+	// key.
+	ErrPublicKey = errors.New("invalid secp256k1 public key")
+
+	// ErrRecover is returned when no public key can be recovered
+	// from a signature.
+	ErrRecover = errors.New("cannot recover secp256k1 public key")
+)
+
+var (
+	p     = fromHex("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f")
+	n     = fromHex("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
+	halfN = new(big.Int).Rsh(n, 1)
+	gx    = fromHex("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	gy    = fromHex("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8")
+	seven = big.NewInt(7)
+	g     = &PublicKey{X: gx, Y: gy}
+)
+
+func fromHex(s string) *big.Int {
+	x, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		panic("secp256k1: bad constant " + s)
+	}
+	return x
+}
+
+// PublicKey is a point on the curve, in affine coordinates.
+type PublicKey struct {
+	X, Y *big.Int
+}
+
+// PrivateKey is a secret scalar and its public key.
+type PrivateKey struct {
+	PublicKey
+	D *big.Int
+}
+
+// ParsePublicKey decodes a public key in compressed or uncompressed
+// SEC 1 form and checks that it is on the curve.
+func ParsePublicKey(b []byte) (*PublicKey, error) {
+	switch {
+	case len(b) == CompressedSize && (b[0] == 2 || b[0] == 3):
+		x := new(big.Int).SetBytes(b[1:])
+		y := liftX(x, b[0] == 3)
+		if y == nil {
+			return nil, ErrPublicKey
+		}
+		return &PublicKey{X: x, Y: y}, nil
+	case len(b) == UncompressedSize && b[0] == 4:
+		pub := &PublicKey{
+			X: new(big.Int).SetBytes(b[1:33]),
+			Y: new(big.Int).SetBytes(b[33:]),
+		}
+		if !pub.onCurve() {
+			return nil, ErrPublicKey
+		}
+		return pub, nil
+	}
+	return nil, ErrPublicKey
+}
+
+// Compressed returns the 33-byte compressed encoding of pub.
+func (pub *PublicKey) Compressed() []byte {
+	b := make([]byte, CompressedSize)
+	b[0] = 2 + byte(pub.Y.Bit(0))
+	pub.X.FillBytes(b[1:])
+	return b
+}
+
+// Uncompressed returns the 65-byte uncompressed encoding of pub.
+func (pub *PublicKey) Uncompressed() []byte {
+	b := make([]byte, UncompressedSize)
+	b[0] = 4
+	pub.X.FillBytes(b[1:33])
+	pub.Y.FillBytes(b[33:])
+	return b
+}
+
+func (pub *PublicKey) onCurve() bool {
+	if pub.X.Sign() < 0 || pub.X.Cmp(p) >= 0 || pub.Y.Sign() < 0 || pub.Y.Cmp(p) >= 0 {
+		return false
+	}
+	lhs := new(big.Int).Mul(pub.Y, pub.Y)
+	lhs.Mod(lhs, p)
+	return lhs.Cmp(rhs(pub.X)) == 0
+}
+
+// rhs returns x³ + 7 mod p.
+func rhs(x *big.Int) *big.Int {
+	r := new(big.Int).Mul(x, x)
+	r.Mul(r, x)
+	r.Add(r, seven)
+	return r.Mod(r, p)
+}
+
+// liftX returns the y coordinate, with the given parity, of the point
+// with x coordinate x, or nil if there is none.
+func liftX(x *big.Int, odd bool) *big.Int {
+	if x.Cmp(p) >= 0 {
+		return nil
+	}
+	a := rhs(x)
+	// p ≡ 3 mod 4, so a square root of a is a^((p+1)/4).
+	e := new(big.Int).Add(p, big.NewInt(1))
+	e.Rsh(e, 2)
+	y := new(big.Int).Exp(a, e, p)
+	if new(big.Int).Mod(new(big.Int).Mul(y, y), p).Cmp(a) != 0 {
+		return nil
+	}
+	if (y.Bit(0) == 1) != odd {
+		y.Sub(p, y)
+	}
+	return y
+}
+
+// hashToInt converts a digest to an integer as ECDSA specifies,
+// keeping its leftmost 256 bits.
+func hashToInt(digest []byte) *big.Int {
+	if len(digest) > DigestSize {
+		digest = digest[:DigestSize]
+	}
+	return new(big.Int).SetBytes(digest)
+}
+
+// Verify reports whether sig, the 64-byte concatenation of R and S,
+// is a valid ECDSA signature by pub of digest. Both low and high S
+// values are accepted.
+func Verify(pub *PublicKey, digest, sig []byte) bool {
+	if len(sig) != SignatureSize {
+		return false
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:])
+	if r.Sign() == 0 || r.Cmp(n) >= 0 || s.Sign() == 0 || s.Cmp(n) >= 0 {
+		return false
+	}
+	w := new(big.Int).ModInverse(s, n)
+	u1 := new(big.Int).Mul(hashToInt(digest), w)
+	u1.Mod(u1, n)
+	u2 := new(big.Int).Mul(r, w)
+	u2.Mod(u2, n)
+	pt := add(scalarMult(g, u1), scalarMult(pub, u2))
+	x, _, ok := pt.affine()
+	if !ok {
+		return false
+	}
+	return x.Mod(x, n).Cmp(r) == 0
+}
+
+// Recover returns the public key that produced sig, the 65-byte
+// concatenation of R, S, and a recovery ID, over digest, if there is
+// one. The recovery ID may be 0 to 3, or 27 to 30 as in Ethereum.
+func Recover(digest, sig []byte) (*PublicKey, error) {
+	if len(sig) != RecoverableSize {
+		return nil, ErrRecover
+	}
+	id := sig[64]
+	if id >= 27 {
+		id -= 27
+	}
+	if id > 3 {
+		return nil, ErrRecover
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	if r.Sign() == 0 || r.Cmp(n) >= 0 || s.Sign() == 0 || s.Cmp(n) >= 0 {
+		return nil, ErrRecover
+	}
+	x := new(big.Int).Set(r)
+	if id&2 != 0 {
+		x.Add(x, n)
+	}
+	y := liftX(x, id&1 != 0)
+	if y == nil {
+		return nil, ErrRecover
+	}
+	// Q = r⁻¹(sR - eG)
+	rinv := new(big.Int).ModInverse(r, n)
+	u1 := new(big.Int).Neg(hashToInt(digest))
+	u1.Mul(u1, rinv)
+	u1.Mod(u1, n)
+	u2 := new(big.Int).Mul(s, rinv)
+	u2.Mod(u2, n)
+	pt := add(scalarMult(g, u1), scalarMult(&PublicKey{X: x, Y: y}, u2))
+	qx, qy, ok := pt.affine()
+	if !ok {
+		return nil, ErrRecover
+	}
+	return &PublicKey{X: qx, Y: qy}, nil
+}
+
+// GenerateKey returns a new private key using randomness from r, or
+// from crypto/rand if r is nil.
+func GenerateKey(r io.Reader) (*PrivateKey, error) {
+	d, err := randScalar(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewPrivateKey(d), nil
+}
+
+// NewPrivateKey returns the private key with secret d, which must be
+// in [1, n-1].
+func NewPrivateKey(d *big.Int) *PrivateKey {
+	x, y, _ := scalarMult(g, d).affine()
+	return &PrivateKey{PublicKey: PublicKey{X: x, Y: y}, D: new(big.Int).Set(d)}
+}
+
+// Sign signs digest with priv, using randomness from r (or
+// crypto/rand if r is nil). It returns a 65-byte recoverable
+// signature with a low S value; its first 64 bytes are a signature
+// for Verify.
+func Sign(r io.Reader, priv *PrivateKey, digest []byte) ([]byte, error) {
+	e := hashToInt(digest)
+	for {
+		k, err := randScalar(r)
+		if err != nil {
+			return nil, err
+		}
+		rx, ry, _ := scalarMult(g, k).affine()
+		rr := new(big.Int).Mod(rx, n)
+		if rr.Sign() == 0 {
+			continue
+		}
+		s := new(big.Int).Mul(rr, priv.D)
+		s.Add(s, e)
+		s.Mul(s, new(big.Int).ModInverse(k, n))
+		s.Mod(s, n)
+		if s.Sign() == 0 {
+			continue
+		}
+		id := byte(ry.Bit(0))
+		if rx.Cmp(n) >= 0 {
+			id |= 2
+		}
+		if s.Cmp(halfN) > 0 {
+			s.Sub(n, s)
+			id ^= 1
+		}
+		sig := make([]byte, RecoverableSize)
+		rr.FillBytes(sig[:32])
+		s.FillBytes(sig[32:64])
+		sig[64] = id
+		return sig, nil
+	}
+}
+
+func randScalar(r io.Reader) (*big.Int, error) {
+	if r == nil {
+		r = rand.Reader
+	}
+	var b [32]byte
+	for {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			return nil, errors.Wrap(err, "reading randomness")
+		}
+		k := new(big.Int).SetBytes(b[:])
+		if k.Sign() > 0 && k.Cmp(n) < 0 {
+			return k, nil
+		}
+	}
+}
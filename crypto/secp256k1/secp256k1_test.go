@@ -0,0 +1,162 @@
+package secp256k1
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func TestGenerator(t *testing.T) {
+	priv := NewPrivateKey(big.NewInt(1))
+	want := "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	if got := hex.EncodeToString(priv.Compressed()); got != want {
+		t.Errorf("1·G = %s, want %s", got, want)
+	}
+	// 3·G, from the SEC test vectors.
+	priv = NewPrivateKey(big.NewInt(3))
+	want = "02f9308a019258c31049344f85f89d5229b531c845836f99b08601f113bce036f9"
+	if got := hex.EncodeToString(priv.Compressed()); got != want {
+		t.Errorf("3·G = %s, want %s", got, want)
+	}
+	// n·G is the point at infinity.
+	if _, _, ok := scalarMult(g, n).affine(); ok {
+		t.Error("n·G is not the point at infinity")
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	priv, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, enc := range [][]byte{priv.Compressed(), priv.Uncompressed()} {
+		pub, err := ParsePublicKey(enc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pub.X.Cmp(priv.X) != 0 || pub.Y.Cmp(priv.Y) != 0 {
+			t.Errorf("ParsePublicKey(%x) = (%x, %x), want (%x, %x)", enc, pub.X, pub.Y, priv.X, priv.Y)
+		}
+	}
+	bad := priv.Uncompressed()
+	bad[64] ^= 1
+	if _, err := ParsePublicKey(bad); err != ErrPublicKey {
+		t.Errorf("ParsePublicKey(off-curve) error = %v, want ErrPublicKey", err)
+	}
+	if _, err := ParsePublicKey(priv.Compressed()[:32]); err != ErrPublicKey {
+		t.Errorf("ParsePublicKey(short) error = %v, want ErrPublicKey", err)
+	}
+}
+
+func TestSignVerifyRecover(t *testing.T) {
+	digest := bytes.Repeat([]byte{0xab}, DigestSize)
+	for i := 0; i < 8; i++ {
+		priv, err := GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := Sign(nil, priv, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Verify(&priv.PublicKey, digest, sig[:64]) {
+			t.Fatal("valid signature does not verify")
+		}
+		if new(big.Int).SetBytes(sig[32:64]).Cmp(halfN) > 0 {
+			t.Error("Sign produced a high S value")
+		}
+		other := append([]byte{}, digest...)
+		other[0] ^= 1
+		if Verify(&priv.PublicKey, other, sig[:64]) {
+			t.Error("signature verifies for the wrong digest")
+		}
+		pub, err := Recover(digest, sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(pub.Compressed(), priv.Compressed()) {
+			t.Errorf("Recover = %x, want %x", pub.Compressed(), priv.Compressed())
+		}
+		sig[64] += 27
+		if pub, err := Recover(digest, sig); err != nil || !bytes.Equal(pub.Compressed(), priv.Compressed()) {
+			t.Errorf("Recover with Ethereum-style ID = %v, %v", pub, err)
+		}
+	}
+}
+
+func TestVerifyVector(t *testing.T) {
+	// A signature over SHA-256("sample") by the private key of RFC
+	// 6979 section A.2.5, with the nonce from that section, computed
+	// with an independent affine-coordinate implementation.
+	pub, _ := hex.DecodeString("032c8c31fc9f990c6b55e3865a184a4ce50e09481f2eaeb3e60ec1cea13a6ae645")
+	sig, _ := hex.DecodeString("432310e32cb80eb6503a26ce83cc165c783b870845fb8aad6d970889fcd7a6c8530128b6b81c548874a6305d93ed071ca6e05074d85863d4056ce89b02bfab6900")
+	digest, _ := hex.DecodeString("af2bdbe1aa9b6ec1e2ade1d694f41fc71a831d0268e9891562113d8a62add1bf")
+
+	key, err := ParsePublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Verify(key, digest, sig[:64]) {
+		t.Error("Verify rejects the signature")
+	}
+	got, err := Recover(digest, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Compressed(), pub) {
+		t.Errorf("Recover = %x, want %x", got.Compressed(), pub)
+	}
+}
+
+func BenchmarkSign(b *testing.B) {
+	digest := bytes.Repeat([]byte{0xab}, DigestSize)
+	priv, err := GenerateKey(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := Sign(nil, priv, digest)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	digest := bytes.Repeat([]byte{0xab}, DigestSize)
+	priv, err := GenerateKey(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sig, err := Sign(nil, priv, digest)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !Verify(&priv.PublicKey, digest, sig[:64]) {
+			b.Fatal("valid signature does not verify")
+		}
+	}
+}
+
+func BenchmarkRecover(b *testing.B) {
+	digest := bytes.Repeat([]byte{0xab}, DigestSize)
+	priv, err := GenerateKey(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	sig, err := Sign(nil, priv, digest)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := Recover(digest, sig)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
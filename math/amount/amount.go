@@ -0,0 +1,181 @@
+// Package amount converts between the integer base units in which
+// txvm counts value and the decimal notation people read.
+//
+// An asset with d decimal places has 10^d base units per whole unit,
+// so 1234 base units of an asset with 2 decimals is "12.34". All
+// arithmetic is done in int64 with overflow checks; nothing here
+// rounds.
+package amount
+
+import (
+	"strconv"
+	"strings"
+
+	"i10r.io/errors"
+	"i10r.io/math/checked"
+)
+
+// MaxDecimals is the largest supported number of decimal places. A
+// whole unit of an asset with more would not fit in an int64.
+const MaxDecimals = 18
+
+var (
+	// ErrSyntax is returned by Parse for a string that is not a
+	// decimal number.
+	ErrSyntax = errors.New("invalid amount syntax")
+
+	// ErrPrecision is returned by Parse for an amount with more
+	// fractional digits than its asset allows, and for a number of
+	// decimals outside [0, MaxDecimals].
+	ErrPrecision = errors.New("too many decimal places")
+)
+
+var pow10 [MaxDecimals + 1]int64
+
+func init() {
+	pow10[0] = 1
+	for i := 1; i <= MaxDecimals; i++ {
+		pow10[i] = pow10[i-1] * 10
+	}
+}
+
+// Scale returns the number of base units in one whole unit of an
+// asset with the given number of decimals.
+func Scale(decimals int) (int64, error) {
+	if decimals < 0 || decimals > MaxDecimals {
+		return 0, errors.WithDetailf(ErrPrecision, "%d decimals", decimals)
+	}
+	return pow10[decimals], nil
+}
+
+// Format returns units in decimal notation with exactly decimals
+// fractional digits. It panics if decimals is out of range.
+func Format(units int64, decimals int) string {
+	scale, err := Scale(decimals)
+	if err != nil {
+		panic(err)
+	}
+	var b strings.Builder
+	// Work with non-positive values so that math.MinInt64 needs no
+	// special case.
+	n := units
+	if n < 0 {
+		b.WriteByte('-')
+	} else {
+		n = -n
+	}
+	whole, frac := -(n / scale), -(n % scale)
+	b.WriteString(strconv.FormatUint(uint64(whole), 10))
+	if decimals > 0 {
+		b.WriteByte('.')
+		f := strconv.FormatUint(uint64(frac), 10)
+		b.WriteString(strings.Repeat("0", decimals-len(f)))
+		b.WriteString(f)
+	}
+	return b.String()
+}
+
+// FormatTrim is like Format but omits trailing fractional zeros, and
+// the decimal point if nothing follows it.
+func FormatTrim(units int64, decimals int) string {
+	s := Format(units, decimals)
+	if decimals == 0 {
+		return s
+	}
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// Parse converts a decimal string such as "-12.34" into base units
+// of an asset with the given number of decimals. The string may have
+// fewer fractional digits than decimals, but not more.
+func Parse(s string, decimals int) (int64, error) {
+	scale, err := Scale(decimals)
+	if err != nil {
+		return 0, err
+	}
+	str := s
+	neg := strings.HasPrefix(s, "-")
+	if neg || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	whole, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	if (whole == "" && frac == "") || !digits(whole) || !digits(frac) {
+		return 0, errors.WithDetailf(ErrSyntax, "%q", str)
+	}
+	if len(frac) > decimals {
+		return 0, errors.WithDetailf(ErrPrecision, "%q has more than %d", str, decimals)
+	}
+
+	// Accumulate negatively, for the same reason as in Format.
+	var w int64
+	for _, c := range whole {
+		var ok bool
+		w, ok = checked.MulInt64(w, 10)
+		if ok {
+			w, ok = checked.SubInt64(w, int64(c-'0'))
+		}
+		if !ok {
+			return 0, errors.WithDetailf(checked.ErrOverflow, "%q", str)
+		}
+	}
+	var f int64
+	for _, c := range frac {
+		f = f*10 - int64(c-'0')
+	}
+	f *= pow10[decimals-len(frac)]
+	units, ok := checked.MulInt64(w, scale)
+	if ok {
+		units, ok = checked.AddInt64(units, f)
+	}
+	if ok && !neg {
+		units, ok = checked.NegateInt64(units)
+	}
+	if !ok {
+		return 0, errors.WithDetailf(checked.ErrOverflow, "%q", str)
+	}
+	return units, nil
+}
+
+// Add returns a + b, or checked.ErrOverflow.
+func Add(a, b int64) (int64, error) {
+	sum, ok := checked.AddInt64(a, b)
+	if !ok {
+		return 0, checked.ErrOverflow
+	}
+	return sum, nil
+}
+
+// Sub returns a - b, or checked.ErrOverflow.
+func Sub(a, b int64) (int64, error) {
+	diff, ok := checked.SubInt64(a, b)
+	if !ok {
+		return 0, checked.ErrOverflow
+	}
+	return diff, nil
+}
+
+// Sum returns the total of units, or checked.ErrOverflow.
+func Sum(units ...int64) (int64, error) {
+	var total int64
+	for _, u := range units {
+		var ok bool
+		total, ok = checked.AddInt64(total, u)
+		if !ok {
+			return 0, checked.ErrOverflow
+		}
+	}
+	return total, nil
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
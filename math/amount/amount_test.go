@@ -0,0 +1,96 @@
+package amount
+
+import (
+	"math"
+	"testing"
+
+	"i10r.io/errors"
+	"i10r.io/math/checked"
+)
+
+func TestFormat(t *testing.T) {
+	cases := []struct {
+		units    int64
+		decimals int
+		want     string
+		trim     string
+	}{
+		{0, 0, "0", "0"},
+		{0, 2, "0.00", "0"},
+		{1234, 2, "12.34", "12.34"},
+		{1230, 3, "1.230", "1.23"},
+		{-5, 3, "-0.005", "-0.005"},
+		{100, 2, "1.00", "1"},
+		{7, 0, "7", "7"},
+		{math.MaxInt64, 18, "9.223372036854775807", "9.223372036854775807"},
+		{math.MinInt64, 0, "-9223372036854775808", "-9223372036854775808"},
+		{math.MinInt64, 4, "-922337203685477.5808", "-922337203685477.5808"},
+	}
+	for _, c := range cases {
+		if got := Format(c.units, c.decimals); got != c.want {
+			t.Errorf("Format(%d, %d) = %q, want %q", c.units, c.decimals, got, c.want)
+		}
+		if got := FormatTrim(c.units, c.decimals); got != c.trim {
+			t.Errorf("FormatTrim(%d, %d) = %q, want %q", c.units, c.decimals, got, c.trim)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		s        string
+		decimals int
+		want     int64
+		wantErr  error
+	}{
+		{"12.34", 2, 1234, nil},
+		{"12.3", 2, 1230, nil},
+		{"12", 2, 1200, nil},
+		{".5", 1, 5, nil},
+		{"5.", 1, 50, nil},
+		{"+1", 0, 1, nil},
+		{"-0.005", 3, -5, nil},
+		{"9.223372036854775807", 18, math.MaxInt64, nil},
+		{"-9223372036854775808", 0, math.MinInt64, nil},
+		{"9223372036854775808", 0, 0, checked.ErrOverflow},
+		{"922337203685477.5808", 4, 0, checked.ErrOverflow},
+		{"99999999999999999999999", 0, 0, checked.ErrOverflow},
+		{"1.234", 2, 0, ErrPrecision},
+		{"1", 19, 0, ErrPrecision},
+		{"", 2, 0, ErrSyntax},
+		{".", 2, 0, ErrSyntax},
+		{"1.2.3", 2, 0, ErrSyntax},
+		{"1e3", 2, 0, ErrSyntax},
+		{"--1", 2, 0, ErrSyntax},
+	}
+	for _, c := range cases {
+		got, err := Parse(c.s, c.decimals)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("Parse(%q, %d) error = %v, want %v", c.s, c.decimals, err, c.wantErr)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("Parse(%q, %d) = %d, want %d", c.s, c.decimals, got, c.want)
+		}
+		if err == nil {
+			if back, _ := Parse(Format(got, c.decimals), c.decimals); back != got {
+				t.Errorf("Parse(Format(%d, %d)) = %d", got, c.decimals, back)
+			}
+		}
+	}
+}
+
+func TestSum(t *testing.T) {
+	got, err := Sum(1, 2, 3)
+	if err != nil || got != 6 {
+		t.Errorf("Sum(1, 2, 3) = %d, %v, want 6", got, err)
+	}
+	_, err = Sum(math.MaxInt64, 1)
+	if err != checked.ErrOverflow {
+		t.Errorf("Sum overflow error = %v, want %v", err, checked.ErrOverflow)
+	}
+	_, err = Sub(math.MinInt64, 1)
+	if err != checked.ErrOverflow {
+		t.Errorf("Sub overflow error = %v, want %v", err, checked.ErrOverflow)
+	}
+}
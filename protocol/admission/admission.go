@@ -0,0 +1,438 @@
+// Package admission screens and schedules transaction submissions
+// before they reach a mempool, so that a flood of invalid
+// transactions cannot starve validation of honest ones.
+//
+// A Gate, through which each submission passes, does three things:
+//
+//   - it rate-limits each source, with a token bucket per source and
+//     a more generous one for each peer;
+//   - it screens each submission with cheap structural checks (size,
+//     runlimit, instruction encoding) before the far costlier work of
+//     running it;
+//   - it queues the submissions that pass for a fixed number of
+//     validation workers, serving peers first and then sources in
+//     order of how few of their recent submissions failed, so that a
+//     source sending invalid transactions waits behind honest ones,
+//     and is the first dropped when the queue is full.
+//
+// An operator can also ban a source, refusing its submissions
+// outright, and add or remove peers while the Gate runs. A Gate's
+// Reputation, its peers, bans, and counts of failures, can be saved
+// and restored, so that a restarted node keeps them.
+package admission
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"math"
+	"runtime"
+	"sync"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/mempool"
+	"i10r.io/protocol/txvm/op"
+)
+
+var (
+	// ErrRateLimited is returned for a submission over its source's
+	// rate limit.
+	ErrRateLimited = errors.New("submission rate limit exceeded")
+
+	// ErrBusy is returned for a submission for which the queue has
+	// no room, or which a better-placed one has displaced.
+	ErrBusy = errors.New("submission queue full")
+
+	// ErrMalformed is returned for a submission failing a
+	// structural check.
+	ErrMalformed = errors.New("malformed transaction")
+
+	// ErrBanned is returned for a submission from a banned source.
+	ErrBanned = errors.New("source banned")
+)
+
+// penaltyHalfLife is how long it takes a source's count of failed
+// submissions to halve.
+const penaltyHalfLife = time.Minute
+
+// Limit is a token-bucket rate limit: Rate submissions per second on
+// average, in bursts of up to Burst.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Config configures a Gate. Zero fields take the values of
+// DefaultConfig.
+type Config struct {
+	// Source limits each source, Peer each peer.
+	Source, Peer Limit
+
+	// MaxSize and MaxRunlimit, if positive, bound the program size
+	// and runlimit of submissions. Use the mempool policy's, if any,
+	// so that transactions the pool would refuse are refused before
+	// they run.
+	MaxSize     int
+	MaxRunlimit int64
+
+	// Workers is the number of submissions validated at once.
+	Workers int
+
+	// QueueLen is the number of submissions that may wait for a
+	// worker.
+	QueueLen int
+}
+
+// DefaultConfig is the configuration of a Gate with none given.
+var DefaultConfig = Config{
+	Source:   Limit{Rate: 10, Burst: 20},
+	Peer:     Limit{Rate: 200, Burst: 400},
+	Workers:  runtime.GOMAXPROCS(0),
+	QueueLen: 1024,
+}
+
+// Submission is a transaction submitted for validation.
+type Submission struct {
+	// Source identifies the submitter, such as by its IP address.
+	Source string
+
+	// Peer is true for a source that is a peer node relaying
+	// transactions, with the peer rate limit and first place in the
+	// queue. A source added with SetPeer is a peer regardless.
+	Peer bool
+
+	Version  int64
+	Runlimit int64
+	Program  []byte
+}
+
+// Stats are counts of a Gate's submissions.
+type Stats struct {
+	Accepted    int64 `json:"accepted"`     // validated
+	Failed      int64 `json:"failed"`       // failed validation
+	RateLimited int64 `json:"rate_limited"` // refused with ErrRateLimited
+	Screened    int64 `json:"screened"`     // refused by the structural checks
+	Banned      int64 `json:"banned"`       // refused with ErrBanned
+	Dropped     int64 `json:"dropped"`      // refused or displaced with ErrBusy
+	Queued      int   `json:"queued"`       // waiting now
+}
+
+// Gate admits submissions. It is safe for concurrent use.
+type Gate struct {
+	// Now returns the current time. If it is nil, time.Now is
+	// used.
+	Now func() time.Time
+
+	cfg Config
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	closed  bool
+	sources map[string]*source
+	peers   map[string]bool
+	bans    map[string]time.Time // source -> end of ban
+	pruneAt int
+	queue   queue
+	seq     uint64
+	stats   Stats
+}
+
+// source is the rate and penalty state of one source.
+type source struct {
+	tokens   float64
+	failures float64 // decaying count of failed submissions
+	last     time.Time
+}
+
+// item is a queued submission.
+type item struct {
+	sub      *Submission
+	validate func() error
+	failures int
+	peer     bool
+	seq      uint64
+	index    int // in the queue, or -1 once out of it
+	done     chan error
+}
+
+// New returns a Gate with the given configuration and starts its
+// workers. Close stops them.
+func New(cfg Config) *Gate {
+	if cfg.Source.Rate <= 0 {
+		cfg.Source = DefaultConfig.Source
+	}
+	if cfg.Peer.Rate <= 0 {
+		cfg.Peer = DefaultConfig.Peer
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultConfig.Workers
+	}
+	if cfg.QueueLen <= 0 {
+		cfg.QueueLen = DefaultConfig.QueueLen
+	}
+	g := &Gate{
+		cfg:     cfg,
+		sources: make(map[string]*source),
+		peers:   make(map[string]bool),
+		bans:    make(map[string]time.Time),
+		pruneAt: 1024,
+	}
+	g.cond = sync.NewCond(&g.mu)
+	for i := 0; i < cfg.Workers; i++ {
+		go g.work()
+	}
+	return g
+}
+
+func (g *Gate) now() time.Time {
+	if g.Now != nil {
+		return g.Now()
+	}
+	return time.Now()
+}
+
+// Submit admits sub and, once a worker is free, calls validate, which
+// validates the transaction and adds it to the mempool, and returns
+// its error. Before that it returns ErrRateLimited, a screening
+// error, or ErrBusy for a submission it refuses, and ctx's error if
+// ctx is done while the submission waits.
+func (g *Gate) Submit(ctx context.Context, sub *Submission, validate func() error) error {
+	err := g.Screen(sub)
+	if err != nil {
+		g.mu.Lock()
+		g.stats.Screened++
+		g.mu.Unlock()
+		return err
+	}
+
+	g.mu.Lock()
+	if g.closed {
+		g.mu.Unlock()
+		return errors.WithDetail(ErrBusy, "closed")
+	}
+	now := g.now()
+	if until, ok := g.bans[sub.Source]; ok {
+		if now.Before(until) {
+			g.stats.Banned++
+			g.mu.Unlock()
+			return errors.WithDetailf(ErrBanned, "source %s until %s", sub.Source, until.Format(time.RFC3339))
+		}
+		delete(g.bans, sub.Source)
+	}
+	src := g.source(sub, now)
+	if src.tokens < 1 {
+		g.stats.RateLimited++
+		g.mu.Unlock()
+		return errors.WithDetailf(ErrRateLimited, "source %s", sub.Source)
+	}
+	src.tokens--
+	it := &item{
+		sub:      sub,
+		validate: validate,
+		failures: int(src.failures),
+		peer:     sub.Peer || g.peers[sub.Source],
+		seq:      g.seq,
+		done:     make(chan error, 1),
+	}
+	g.seq++
+	if len(g.queue) >= g.cfg.QueueLen {
+		worst := g.queue.worst()
+		if !g.queue.less(it, worst) {
+			g.stats.Dropped++
+			g.mu.Unlock()
+			return errors.WithDetailf(ErrBusy, "%d queued", len(g.queue))
+		}
+		heap.Remove(&g.queue, worst.index)
+		g.stats.Dropped++
+		worst.done <- errors.WithDetail(ErrBusy, "displaced")
+	}
+	heap.Push(&g.queue, it)
+	g.cond.Signal()
+	g.mu.Unlock()
+
+	select {
+	case err := <-it.done:
+		return err
+	case <-ctx.Done():
+		g.mu.Lock()
+		if it.index >= 0 {
+			heap.Remove(&g.queue, it.index)
+		} // else a worker has it, and its result goes unread
+		g.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Screen makes a Gate's structural checks of sub, returning an error
+// for a submission that cannot be a valid transaction: an empty or
+// oversized program, a bad runlimit, a program that does not decode
+// into instructions, or one with no finalize instruction anywhere in
+// it, even in the code of a contract it pushes as data.
+func (g *Gate) Screen(sub *Submission) error {
+	prog := sub.Program
+	if len(prog) == 0 {
+		return errors.WithDetail(ErrMalformed, "empty program")
+	}
+	if g.cfg.MaxSize > 0 && len(prog) > g.cfg.MaxSize {
+		return errors.WithDetailf(mempool.ErrTooLarge, "%d bytes, max %d", len(prog), g.cfg.MaxSize)
+	}
+	if sub.Runlimit <= 0 {
+		return errors.WithDetailf(ErrMalformed, "runlimit %d", sub.Runlimit)
+	}
+	if g.cfg.MaxRunlimit > 0 && sub.Runlimit > g.cfg.MaxRunlimit {
+		return errors.WithDetailf(mempool.ErrRunlimit, "runlimit %d, max %d", sub.Runlimit, g.cfg.MaxRunlimit)
+	}
+	for pc := 0; pc < len(prog); {
+		_, _, n, err := op.DecodeInst(prog[pc:])
+		if err != nil {
+			return errors.WithDetailf(ErrMalformed, "at %d: %s", pc, err)
+		}
+		pc += int(n)
+	}
+	if bytes.IndexByte(prog, op.Finalize) < 0 {
+		return errors.WithDetail(ErrMalformed, "program does not finalize")
+	}
+	return nil
+}
+
+// source returns the state of sub's source, with its tokens and
+// failures brought up to now. The caller must hold g.mu.
+func (g *Gate) source(sub *Submission, now time.Time) *source {
+	limit := g.cfg.Source
+	if sub.Peer || g.peers[sub.Source] {
+		limit = g.cfg.Peer
+	}
+	src, ok := g.sources[sub.Source]
+	if !ok {
+		if len(g.sources) >= g.pruneAt {
+			g.prune(now)
+		}
+		src = &source{tokens: float64(limit.Burst), last: now}
+		g.sources[sub.Source] = src
+		return src
+	}
+	if elapsed := now.Sub(src.last); elapsed > 0 {
+		src.tokens = math.Min(float64(limit.Burst), src.tokens+limit.Rate*elapsed.Seconds())
+		src.failures *= math.Exp2(-float64(elapsed) / float64(penaltyHalfLife))
+		src.last = now
+	}
+	return src
+}
+
+// prune forgets the sources that have been idle long enough to
+// refill their buckets and forgive their failures. The caller must
+// hold g.mu.
+func (g *Gate) prune(now time.Time) {
+	idle := 10 * penaltyHalfLife
+	if refill := time.Duration(float64(g.cfg.Source.Burst) / g.cfg.Source.Rate * float64(time.Second)); refill > idle {
+		idle = refill
+	}
+	for key, src := range g.sources {
+		if now.Sub(src.last) > idle {
+			delete(g.sources, key)
+		}
+	}
+	if g.pruneAt < 2*len(g.sources) {
+		g.pruneAt = 2 * len(g.sources)
+	}
+}
+
+func (g *Gate) work() {
+	for {
+		g.mu.Lock()
+		for len(g.queue) == 0 && !g.closed {
+			g.cond.Wait()
+		}
+		if g.closed {
+			g.mu.Unlock()
+			return
+		}
+		it := heap.Pop(&g.queue).(*item)
+		g.mu.Unlock()
+
+		err := it.validate()
+
+		g.mu.Lock()
+		if err != nil {
+			g.stats.Failed++
+			if src, ok := g.sources[it.sub.Source]; ok {
+				src.failures++
+			}
+		} else {
+			g.stats.Accepted++
+		}
+		g.mu.Unlock()
+		it.done <- err
+	}
+}
+
+// Stats returns counts of g's submissions.
+func (g *Gate) Stats() Stats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st := g.stats
+	st.Queued = len(g.queue)
+	return st
+}
+
+// Close stops g's workers. The submissions still queued fail with
+// ErrBusy.
+func (g *Gate) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+	for _, it := range g.queue {
+		it.index = -1
+		it.done <- errors.WithDetail(ErrBusy, "closed")
+	}
+	g.queue = nil
+	g.cond.Broadcast()
+}
+
+// queue is a heap of items, best first.
+type queue []*item
+
+func (q queue) less(a, b *item) bool {
+	if a.peer != b.peer {
+		return a.peer
+	}
+	if a.failures != b.failures {
+		return a.failures < b.failures
+	}
+	return a.seq < b.seq
+}
+
+// worst returns the item that would be served last.
+func (q queue) worst() *item {
+	var w *item
+	for _, it := range q {
+		if w == nil || q.less(w, it) {
+			w = it
+		}
+	}
+	return w
+}
+
+func (q queue) Len() int           { return len(q) }
+func (q queue) Less(i, j int) bool { return q.less(q[i], q[j]) }
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *queue) Push(x interface{}) {
+	it := x.(*item)
+	it.index = len(*q)
+	*q = append(*q, it)
+}
+
+func (q *queue) Pop() interface{} {
+	old := *q
+	it := old[len(old)-1]
+	it.index = -1
+	*q = old[:len(old)-1]
+	return it
+}
@@ -0,0 +1,256 @@
+package admission
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/mempool"
+	"i10r.io/protocol/txvm/op"
+)
+
+var prog = []byte{op.Nonce, op.Finalize}
+
+func TestScreen(t *testing.T) {
+	g := New(Config{MaxSize: 10, MaxRunlimit: 1000})
+	defer g.Close()
+	cases := []struct {
+		prog     []byte
+		runlimit int64
+		want     error
+	}{
+		{prog, 100, nil},
+		{nil, 100, ErrMalformed},
+		{make([]byte, 11), 100, mempool.ErrTooLarge},
+		{prog, 0, ErrMalformed},
+		{prog, 1001, mempool.ErrRunlimit},
+		{[]byte{op.MinPushdata + 5, 1}, 100, ErrMalformed},
+		{[]byte{op.Nonce, op.Drop}, 100, ErrMalformed},
+		{[]byte{op.MinPushdata + 1, op.Finalize, op.Exec}, 100, nil}, // finalize in pushed code
+	}
+	for i, c := range cases {
+		err := g.Screen(&Submission{Source: "a", Program: c.prog, Runlimit: c.runlimit})
+		if errors.Root(err) != c.want {
+			t.Errorf("case %d: Screen(%x, %d) = %v, want %v", i, c.prog, c.runlimit, err, c.want)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	g := New(Config{Source: Limit{Rate: 1, Burst: 2}, Peer: Limit{Rate: 1, Burst: 3}})
+	defer g.Close()
+	g.Now = func() time.Time { return now }
+	ok := func() error { return nil }
+
+	submit := func(source string, peer bool) error {
+		return g.Submit(ctx, &Submission{Source: source, Peer: peer, Program: prog, Runlimit: 100}, ok)
+	}
+	for i := 0; i < 2; i++ {
+		if err := submit("a", false); err != nil {
+			t.Fatalf("submission %d: %v", i, err)
+		}
+	}
+	if err := submit("a", false); errors.Root(err) != ErrRateLimited {
+		t.Errorf("third submission: got error %v, want %v", err, ErrRateLimited)
+	}
+	if err := submit("b", false); err != nil {
+		t.Errorf("another source: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := submit("peer", true); err != nil {
+			t.Errorf("peer submission %d: %v", i, err)
+		}
+	}
+	if err := submit("peer", true); errors.Root(err) != ErrRateLimited {
+		t.Errorf("fourth peer submission: got error %v, want %v", err, ErrRateLimited)
+	}
+
+	now = now.Add(time.Second)
+	if err := submit("a", false); err != nil {
+		t.Errorf("after a second: %v", err)
+	}
+	if err := submit("a", false); errors.Root(err) != ErrRateLimited {
+		t.Errorf("again after a second: got error %v, want %v", err, ErrRateLimited)
+	}
+	if st := g.Stats(); st.Accepted != 7 || st.RateLimited != 3 {
+		t.Errorf("stats %+v, want 7 accepted and 3 rate limited", st)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	ctx := context.Background()
+	g := New(Config{Source: Limit{Rate: 100, Burst: 100}, Workers: 1, QueueLen: 3})
+	defer g.Close()
+	sub := func(source string, peer bool) *Submission {
+		return &Submission{Source: source, Peer: peer, Program: prog, Runlimit: 100}
+	}
+
+	// Source bad has a history of invalid transactions.
+	for i := 0; i < 3; i++ {
+		err := g.Submit(ctx, sub("bad", false), func() error { return fmt.Errorf("invalid") })
+		if err == nil {
+			t.Fatal("want error")
+		}
+	}
+
+	// Occupy the only worker.
+	started, release := make(chan struct{}), make(chan struct{})
+	go g.Submit(ctx, sub("good", false), func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+		errs  = make(map[string]error)
+	)
+	enqueue := func(name string, s *Submission) {
+		n := g.Stats().Queued
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := g.Submit(ctx, s, func() error {
+				mu.Lock()
+				order = append(order, name)
+				mu.Unlock()
+				return nil
+			})
+			mu.Lock()
+			errs[name] = err
+			mu.Unlock()
+		}()
+		for g.Stats().Queued == n && g.Stats().Dropped == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	enqueue("bad1", sub("bad", false))
+	enqueue("bad2", sub("bad", false))
+	enqueue("good", sub("good", false))
+	enqueue("peer", sub("peer", true)) // displaces bad2
+	close(release)
+	wg.Wait()
+
+	want := []string{"peer", "good", "bad1"}
+	if fmt.Sprint(order) != fmt.Sprint(want) {
+		t.Errorf("validation order %v, want %v", order, want)
+	}
+	if errors.Root(errs["bad2"]) != ErrBusy {
+		t.Errorf("displaced submission: got error %v, want %v", errs["bad2"], ErrBusy)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	g := New(Config{Workers: 1})
+	defer g.Close()
+	sub := &Submission{Source: "a", Program: prog, Runlimit: 100}
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go g.Submit(context.Background(), sub, func() error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	called := false
+	err := g.Submit(ctx, sub, func() error {
+		called = true
+		return nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := g.Stats().Queued; n != 0 || called {
+		t.Errorf("after cancel, %d queued, validated %v; want none", n, called)
+	}
+}
+
+func TestReputation(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	g := New(Config{Source: Limit{Rate: 1, Burst: 1}, Peer: Limit{Rate: 1, Burst: 3}})
+	defer g.Close()
+	g.Now = func() time.Time { return now }
+	submit := func(g *Gate, source string, validate func() error) error {
+		return g.Submit(ctx, &Submission{Source: source, Program: prog, Runlimit: 100}, validate)
+	}
+	ok := func() error { return nil }
+
+	g.Ban("bad", now.Add(time.Hour))
+	if err := submit(g, "bad", ok); errors.Root(err) != ErrBanned {
+		t.Errorf("banned source: got error %v, want %v", err, ErrBanned)
+	}
+	g.SetPeer("peer", true)
+	for i := 0; i < 3; i++ {
+		if err := submit(g, "peer", ok); err != nil {
+			t.Errorf("peer submission %d: %v", i, err)
+		}
+	}
+	if err := submit(g, "flaky", func() error { return fmt.Errorf("invalid") }); err == nil {
+		t.Fatal("want error")
+	}
+
+	dir, err := ioutil.TempDir("", "admission")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "peers.json")
+	if err := g.Reputation().Save(name); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadReputation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Peers) != 1 || r.Peers[0] != "peer" || len(r.Sources) != 2 {
+		t.Fatalf("loaded reputation %+v, want peer and 2 sources", r)
+	}
+	if s := r.Sources[0]; s.Source != "bad" || s.BannedUntil == nil || !s.BannedUntil.Equal(now.Add(time.Hour)) {
+		t.Errorf("source 0 = %+v, want bad banned for an hour", s)
+	}
+	if s := r.Sources[1]; s.Source != "flaky" || s.Failures < 0.99 || s.BannedUntil != nil {
+		t.Errorf("source 1 = %+v, want flaky with a failure", s)
+	}
+
+	// A restarted gate keeps the ban and the peer.
+	g2 := New(Config{Source: Limit{Rate: 1, Burst: 1}, Peer: Limit{Rate: 1, Burst: 3}})
+	defer g2.Close()
+	g2.Now = g.Now
+	g2.Restore(r)
+	if err := submit(g2, "bad", ok); errors.Root(err) != ErrBanned {
+		t.Errorf("restored ban: got error %v, want %v", err, ErrBanned)
+	}
+	for i := 0; i < 3; i++ {
+		if err := submit(g2, "peer", ok); err != nil {
+			t.Errorf("restored peer submission %d: %v", i, err)
+		}
+	}
+	g2.SetPeer("peer", false)
+	now = now.Add(time.Hour)
+	if err := submit(g2, "bad", ok); err != nil {
+		t.Errorf("after ban: %v", err)
+	}
+	if r := g2.Reputation(); len(r.Peers) != 0 || len(r.Sources) != 0 {
+		t.Errorf("reputation after an hour %+v, want none", r)
+	}
+
+	if r, err := LoadReputation(filepath.Join(dir, "none.json")); err != nil || len(r.Peers) != 0 {
+		t.Errorf("LoadReputation(missing) = %+v, %v", r, err)
+	}
+}
@@ -0,0 +1,169 @@
+package admission
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"i10r.io/errors"
+)
+
+// SetPeer makes source a peer, or, if peer is false, an ordinary
+// source again. A source is a peer for the Gate if SetPeer made it
+// one or its submissions say so (see Submission.Peer).
+func (g *Gate) SetPeer(source string, peer bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if peer {
+		g.peers[source] = true
+	} else {
+		delete(g.peers, source)
+	}
+}
+
+// Ban refuses the submissions of source, with ErrBanned, until the
+// given time. A zero time lifts a ban.
+func (g *Gate) Ban(source string, until time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if until.IsZero() {
+		delete(g.bans, source)
+	} else {
+		g.bans[source] = until
+	}
+}
+
+// Reputation is what a Gate knows of its sources that outlasts a
+// restart: the peers added with SetPeer, the bans in force, and each
+// source's count of recent failed submissions.
+type Reputation struct {
+	Peers   []string     `json:"peers"`
+	Sources []SourceInfo `json:"sources"`
+}
+
+// SourceInfo describes a source that is banned or whose submissions
+// have recently failed.
+type SourceInfo struct {
+	Source string `json:"source"`
+
+	// Failures is the count of the source's failed submissions,
+	// halving every minute.
+	Failures float64 `json:"failures,omitempty"`
+
+	// BannedUntil is the end of the source's ban, if it is banned.
+	BannedUntil *time.Time `json:"banned_until,omitempty"`
+}
+
+// Reputation returns g's reputation, with its peers and sources in
+// order.
+func (g *Gate) Reputation() *Reputation {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	r := &Reputation{Peers: []string{}, Sources: []SourceInfo{}}
+	for p := range g.peers {
+		r.Peers = append(r.Peers, p)
+	}
+	sort.Strings(r.Peers)
+	infos := make(map[string]*SourceInfo)
+	for key, src := range g.sources {
+		f := src.failures * math.Exp2(-float64(now.Sub(src.last))/float64(penaltyHalfLife))
+		if f >= 0.01 {
+			infos[key] = &SourceInfo{Source: key, Failures: f}
+		}
+	}
+	for key, until := range g.bans {
+		if !now.Before(until) {
+			delete(g.bans, key)
+			continue
+		}
+		info := infos[key]
+		if info == nil {
+			info = &SourceInfo{Source: key}
+			infos[key] = info
+		}
+		until := until
+		info.BannedUntil = &until
+	}
+	for _, info := range infos {
+		r.Sources = append(r.Sources, *info)
+	}
+	sort.Slice(r.Sources, func(i, j int) bool { return r.Sources[i].Source < r.Sources[j].Source })
+	return r
+}
+
+// Restore adds the peers, bans, and failures in r to g. Failures
+// resume decaying from now; the time g was not running does not
+// count.
+func (g *Gate) Restore(r *Reputation) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	for _, p := range r.Peers {
+		g.peers[p] = true
+	}
+	for _, info := range r.Sources {
+		if info.BannedUntil != nil && now.Before(*info.BannedUntil) {
+			g.bans[info.Source] = *info.BannedUntil
+		}
+		if info.Failures > 0 {
+			src, ok := g.sources[info.Source]
+			if !ok {
+				limit := g.cfg.Source
+				if g.peers[info.Source] {
+					limit = g.cfg.Peer
+				}
+				src = &source{tokens: float64(limit.Burst), last: now}
+				g.sources[info.Source] = src
+			}
+			src.failures += info.Failures
+		}
+	}
+}
+
+// LoadReputation reads a Reputation from the JSON file name, as
+// written by Save. If the file does not exist, it returns an empty
+// Reputation.
+func LoadReputation(name string) (*Reputation, error) {
+	bits, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return new(Reputation), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	r := new(Reputation)
+	err = json.Unmarshal(bits, r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", name)
+	}
+	return r, nil
+}
+
+// Save writes r to the file name, replacing it atomically.
+func (r *Reputation) Save(name string) error {
+	bits, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(bits, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
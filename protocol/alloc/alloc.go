@@ -0,0 +1,179 @@
+// Package alloc implements allocations: outputs created by a
+// blockchain's initial block.
+//
+// An issuance commits to the ID of the blockchain it is valid on,
+// which is the hash of the initial block, so the initial block cannot
+// contain one. Instead, an initial block of version
+// bc.CommitmentsVersion or later may carry, in its header's
+// commitments area, a list of allocations. Applying the block creates,
+// for each, an output holding the allocated amount, locked with the
+// standard pay-to-multisig contract to the allocation's keys, as for
+// fee outputs (see package fee). No other block may carry
+// allocations.
+package alloc
+
+import (
+	"encoding/binary"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/validation"
+)
+
+// Commitment is the name of the header commitment holding an initial
+// block's allocations.
+const Commitment = "alloc"
+
+// ErrAlloc is returned for a malformed alloc commitment, or one in a
+// block other than the initial block.
+var ErrAlloc = errors.New("malformed allocations")
+
+func init() {
+	validation.RegisterCommitment(Commitment, func(b *bc.UnsignedBlock, value []byte) error {
+		if b.Height != 1 {
+			return errors.WithDetailf(ErrAlloc, "block height %d", b.Height)
+		}
+		_, err := Parse(value)
+		return err
+	})
+}
+
+// An Allocation is an amount of an asset allocated to a quorum of
+// keys.
+type Allocation struct {
+	AssetID bc.Hash
+	Amount  int64
+	Quorum  int
+	Pubkeys []ed25519.PublicKey
+}
+
+// Value returns the encoding of allocs for a header commitment: the
+// number of allocations as a uvarint, then for each its asset ID, its
+// amount, quorum, and number of keys as uvarints, and its
+// concatenated public keys.
+func Value(allocs []Allocation) []byte {
+	buf := make([]byte, 0, binary.MaxVarintLen64)
+	buf = appendUvarint(buf, uint64(len(allocs)))
+	for _, a := range allocs {
+		buf = append(buf, a.AssetID.Bytes()...)
+		buf = appendUvarint(buf, uint64(a.Amount))
+		buf = appendUvarint(buf, uint64(a.Quorum))
+		buf = appendUvarint(buf, uint64(len(a.Pubkeys)))
+		for _, pk := range a.Pubkeys {
+			buf = append(buf, pk...)
+		}
+	}
+	return buf
+}
+
+func appendUvarint(buf []byte, n uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], n)]...)
+}
+
+// Parse parses the value of an alloc commitment.
+func Parse(value []byte) ([]Allocation, error) {
+	uvarint := func(what string) (uint64, error) {
+		n, size := binary.Uvarint(value)
+		if size <= 0 {
+			return 0, errors.WithDetailf(ErrAlloc, "bad %s", what)
+		}
+		value = value[size:]
+		return n, nil
+	}
+	count, err := uvarint("count")
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(len(value)) {
+		return nil, errors.WithDetailf(ErrAlloc, "%d allocations in %d bytes", count, len(value))
+	}
+	allocs := make([]Allocation, 0, count)
+	for i := uint64(0); i < count; i++ {
+		if len(value) < 32 {
+			return nil, errors.WithDetailf(ErrAlloc, "allocation %d: short asset ID", i)
+		}
+		var a Allocation
+		a.AssetID = bc.HashFromBytes(value[:32])
+		value = value[32:]
+		amount, err := uvarint("amount")
+		if err != nil {
+			return nil, err
+		}
+		quorum, err := uvarint("quorum")
+		if err != nil {
+			return nil, err
+		}
+		npubs, err := uvarint("key count")
+		if err != nil {
+			return nil, err
+		}
+		if npubs > uint64(len(value)/ed25519.PublicKeySize) {
+			return nil, errors.WithDetailf(ErrAlloc, "allocation %d: %d keys in %d bytes", i, npubs, len(value))
+		}
+		for j := uint64(0); j < npubs; j++ {
+			a.Pubkeys = append(a.Pubkeys, ed25519.PublicKey(value[:ed25519.PublicKeySize]))
+			value = value[ed25519.PublicKeySize:]
+		}
+		if amount < 1 || amount > 1<<63-1 {
+			return nil, errors.WithDetailf(ErrAlloc, "allocation %d: amount %d", i, amount)
+		}
+		if quorum < 1 || quorum > npubs {
+			return nil, errors.WithDetailf(ErrAlloc, "allocation %d: quorum %d of %d", i, quorum, npubs)
+		}
+		a.Amount = int64(amount)
+		a.Quorum = int(quorum)
+		allocs = append(allocs, a)
+	}
+	if len(value) > 0 {
+		return nil, errors.WithDetailf(ErrAlloc, "%d extra bytes", len(value))
+	}
+	return allocs, nil
+}
+
+// An Output is an output created by applying an initial block.
+type Output struct {
+	ID     bc.Hash
+	Anchor []byte
+	Allocation
+}
+
+// Outputs returns the outputs that applying b creates, in the order
+// of its allocations. It returns none for a block with no alloc
+// commitment.
+func Outputs(b *bc.UnsignedBlock) ([]Output, error) {
+	if b.Version < bc.CommitmentsVersion {
+		return nil, nil
+	}
+	value, ok := b.Commitment(Commitment)
+	if !ok {
+		return nil, nil
+	}
+	if b.Height != 1 {
+		return nil, errors.WithDetailf(ErrAlloc, "block height %d", b.Height)
+	}
+	allocs, err := Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return BlockOutputs(allocs), nil
+}
+
+// BlockOutputs returns the outputs for an initial block with the
+// given allocations. The anchor of each is derived from its position
+// in allocs, so that equal allocations make distinct outputs.
+func BlockOutputs(allocs []Allocation) []Output {
+	outs := make([]Output, 0, len(allocs))
+	for i, a := range allocs {
+		anchor := txvm.VMHash("AllocAnchor", txvm.Encode(txvm.Int(i)))
+		outs = append(outs, Output{
+			ID:         standard.MultisigOutputID(a.Quorum, a.Pubkeys, a.Amount, a.AssetID, anchor[:], standard.PayToMultisigSeed2[:]),
+			Anchor:     anchor[:],
+			Allocation: a,
+		})
+	}
+	return outs
+}
@@ -0,0 +1,64 @@
+package alloc
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/testutil"
+)
+
+func TestValue(t *testing.T) {
+	pubs := []ed25519.PublicKey{testutil.TestPub, ed25519.PublicKey(bytes.Repeat([]byte{1}, ed25519.PublicKeySize))}
+	allocs := []Allocation{
+		{AssetID: bc.NewHash([32]byte{1}), Amount: 1 << 40, Quorum: 1, Pubkeys: pubs},
+		{AssetID: bc.NewHash([32]byte{2}), Amount: 7, Quorum: 2, Pubkeys: pubs},
+	}
+	got, err := Parse(Value(allocs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, allocs) {
+		t.Errorf("Parse(Value(%+v)) = %+v", allocs, got)
+	}
+
+	bad := [][]Allocation{
+		{{Amount: 0, Quorum: 1, Pubkeys: pubs}},
+		{{Amount: 1, Quorum: 0, Pubkeys: pubs}},
+		{{Amount: 1, Quorum: 1}},
+		{{Amount: 1, Quorum: 3, Pubkeys: pubs}},
+	}
+	for i, allocs := range bad {
+		if _, err := Parse(Value(allocs)); errors.Root(err) != ErrAlloc {
+			t.Errorf("case %d: got error %v, want %v", i, err, ErrAlloc)
+		}
+	}
+	v := Value(allocs)
+	for _, v := range [][]byte{nil, v[:len(v)-1], append(v, 0), {0xff, 0xff, 0x0f}} {
+		if _, err := Parse(v); errors.Root(err) != ErrAlloc {
+			t.Errorf("Parse(%x): got error %v, want %v", v, err, ErrAlloc)
+		}
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	allocs := []Allocation{{Amount: 1, Quorum: 1, Pubkeys: []ed25519.PublicKey{testutil.TestPub}}}
+	h := &bc.BlockHeader{Version: bc.CommitmentsVersion, Height: 1}
+	h.SetCommitments([]bc.Commitment{{Name: Commitment, Value: Value(allocs)}})
+	b := &bc.UnsignedBlock{BlockHeader: h}
+	outs, err := Outputs(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outs) != 1 || outs[0].ID != BlockOutputs(allocs)[0].ID {
+		t.Errorf("Outputs = %+v", outs)
+	}
+
+	h.Height = 2
+	if _, err := Outputs(b); errors.Root(err) != ErrAlloc {
+		t.Errorf("allocations at height 2: got error %v, want %v", err, ErrAlloc)
+	}
+}
@@ -0,0 +1,312 @@
+// Package analytics aggregates statistics on how a blockchain's
+// transactions use the VM: how often each opcode is executed, which
+// contracts are spent and created, the activity of each asset, and
+// the distribution of the runlimit transactions use.
+//
+// They are meant to inform changes to the VM's cost tables, by
+// showing which instructions real transactions lean on, and to let
+// operators see at a glance what a chain is used for. A Collector
+// takes each block as it is committed and keeps totals and the
+// statistics of recent blocks.
+//
+// Counting opcodes means running each transaction's program again,
+// which costs about as much as validating it, so collecting is
+// optional for a node.
+package analytics
+
+import (
+	"math/bits"
+	"strconv"
+	"sync"
+
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/contracts"
+	"i10r.io/protocol/txbuilder/txresult"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+)
+
+// Stats are statistics on the transactions of some blocks.
+type Stats struct {
+	Blocks int64 `json:"blocks"`
+	Txs    int64 `json:"txs"`
+
+	// Opcodes maps the name of each opcode executed to the number of
+	// times it was. Small integers are named by their values, and
+	// all pushdata instructions are counted as "pushdata".
+	Opcodes map[string]int64 `json:"opcodes"`
+
+	// Contracts maps the name of each contract spent or created (see
+	// contracts.Name) to its usage.
+	Contracts map[string]*ContractStats `json:"contracts"`
+
+	// Assets maps each asset issued, retired, or held in an output
+	// to its activity. Values that the transaction log does not
+	// reveal, as with non-standard contracts, are not counted.
+	Assets map[bc.Hash]*AssetStats `json:"assets"`
+
+	// Runlimit is the distribution of the runlimit used by each
+	// transaction.
+	Runlimit Distribution `json:"runlimit"`
+}
+
+// ContractStats count the uses of a contract.
+type ContractStats struct {
+	Inputs  int64 `json:"inputs"`  // contracts spent
+	Outputs int64 `json:"outputs"` // contracts created
+}
+
+// AssetStats describe the activity of an asset.
+type AssetStats struct {
+	Issued  uint64 `json:"issued"`  // amount issued
+	Retired uint64 `json:"retired"` // amount retired
+	Outputs int64  `json:"outputs"` // outputs holding the asset
+	Volume  uint64 `json:"volume"`  // amount held in those outputs
+}
+
+// Distribution summarizes a set of non-negative numbers.
+type Distribution struct {
+	Count int64 `json:"count"`
+	Min   int64 `json:"min"`
+	Max   int64 `json:"max"`
+	Sum   int64 `json:"sum"`
+
+	// Buckets[0] counts the zeroes, and Buckets[i], for i > 0, the
+	// numbers at least 2^(i-1) and less than 2^i. It is no longer
+	// than it must be to hold the largest.
+	Buckets []int64 `json:"buckets"`
+}
+
+// Add adds v to d.
+func (d *Distribution) Add(v int64) {
+	if d.Count == 0 || v < d.Min {
+		d.Min = v
+	}
+	if v > d.Max {
+		d.Max = v
+	}
+	d.Count++
+	d.Sum += v
+	i := bits.Len64(uint64(v))
+	for len(d.Buckets) <= i {
+		d.Buckets = append(d.Buckets, 0)
+	}
+	d.Buckets[i]++
+}
+
+func (d *Distribution) merge(o *Distribution) {
+	if o.Count == 0 {
+		return
+	}
+	if d.Count == 0 || o.Min < d.Min {
+		d.Min = o.Min
+	}
+	if o.Max > d.Max {
+		d.Max = o.Max
+	}
+	d.Count += o.Count
+	d.Sum += o.Sum
+	for len(d.Buckets) < len(o.Buckets) {
+		d.Buckets = append(d.Buckets, 0)
+	}
+	for i, n := range o.Buckets {
+		d.Buckets[i] += n
+	}
+}
+
+// BlockStats are the statistics of one block.
+type BlockStats struct {
+	Height      uint64 `json:"height"`
+	TimestampMS uint64 `json:"timestamp_ms"`
+	Stats
+}
+
+// Block returns the statistics of b. Opts, such as txvm.WithPlugins,
+// are passed to the VM rerunning each transaction.
+func Block(b *bc.UnsignedBlock, opts ...txvm.Option) (*BlockStats, error) {
+	var network bc.Hash
+	if b.Version >= bc.NetworkVersion {
+		var err error
+		network, err = b.Network()
+		if err != nil {
+			return nil, err
+		}
+	}
+	bs := &BlockStats{Height: b.Height, TimestampMS: b.TimestampMs, Stats: newStats()}
+	bs.Blocks = 1
+	var opcodes [256]int64
+	count := txvm.BeforeStep(func(vm *txvm.VM) { opcodes[vm.OpCode()]++ })
+	opts = append([]txvm.Option{count, bc.NetworkOption(network), txvm.WithProgramCache(bc.ProgramCache)}, opts...)
+	for _, tx := range b.Transactions {
+		_, err := txvm.Validate(tx.Program, tx.Version, tx.Runlimit, opts...)
+		if err != nil {
+			return nil, err
+		}
+		bs.addTx(tx)
+	}
+	for code, n := range opcodes {
+		if n > 0 {
+			bs.Opcodes[opName(byte(code))] += n
+		}
+	}
+	return bs, nil
+}
+
+func newStats() Stats {
+	return Stats{
+		Opcodes:   make(map[string]int64),
+		Contracts: make(map[string]*ContractStats),
+		Assets:    make(map[bc.Hash]*AssetStats),
+	}
+}
+
+// addTx adds all but the opcodes of tx to s.
+func (s *Stats) addTx(tx *bc.Tx) {
+	s.Txs++
+	s.Runlimit.Add(tx.RunlimitUsed)
+	for _, in := range tx.Inputs {
+		s.contract(in.Seed).Inputs++
+	}
+	for _, out := range tx.Outputs {
+		s.contract(out.Seed).Outputs++
+	}
+	res := txresult.New(tx)
+	for _, iss := range res.Issuances {
+		if v := iss.Value; v != nil {
+			s.asset(v.AssetID).Issued += v.Amount
+		}
+	}
+	for _, ret := range res.Retirements {
+		if v := ret.Value; v != nil {
+			s.asset(v.AssetID).Retired += v.Amount
+		}
+	}
+	for _, out := range res.Outputs {
+		if v := out.Value; v != nil {
+			a := s.asset(v.AssetID)
+			a.Outputs++
+			a.Volume += v.Amount
+		}
+	}
+}
+
+func (s *Stats) contract(seed bc.Hash) *ContractStats {
+	name := contracts.Name(seed.Bytes())
+	c := s.Contracts[name]
+	if c == nil {
+		c = new(ContractStats)
+		s.Contracts[name] = c
+	}
+	return c
+}
+
+func (s *Stats) asset(id bc.Hash) *AssetStats {
+	a := s.Assets[id]
+	if a == nil {
+		a = new(AssetStats)
+		s.Assets[id] = a
+	}
+	return a
+}
+
+// merge adds o to s.
+func (s *Stats) merge(o *Stats) {
+	s.Blocks += o.Blocks
+	s.Txs += o.Txs
+	for name, n := range o.Opcodes {
+		s.Opcodes[name] += n
+	}
+	for name, oc := range o.Contracts {
+		c := s.Contracts[name]
+		if c == nil {
+			c = new(ContractStats)
+			s.Contracts[name] = c
+		}
+		c.Inputs += oc.Inputs
+		c.Outputs += oc.Outputs
+	}
+	for id, oa := range o.Assets {
+		a := s.asset(id)
+		a.Issued += oa.Issued
+		a.Retired += oa.Retired
+		a.Outputs += oa.Outputs
+		a.Volume += oa.Volume
+	}
+	s.Runlimit.merge(&o.Runlimit)
+}
+
+// copy returns a deep copy of s.
+func (s *Stats) copy() Stats {
+	c := newStats()
+	c.merge(s)
+	return c
+}
+
+func opName(opcode byte) string {
+	switch {
+	case op.IsSmallIntOp(opcode):
+		return strconv.Itoa(int(opcode - op.MinSmallInt))
+	case op.IsPushdataOp(opcode):
+		return "pushdata"
+	}
+	return op.Name(opcode)
+}
+
+// Collector accumulates the statistics of the blocks of a chain. It
+// is safe for concurrent use.
+type Collector struct {
+	keep int
+
+	mu     sync.Mutex
+	total  Stats
+	recent []*BlockStats // oldest first
+}
+
+// NewCollector returns a Collector keeping the statistics of the
+// last keep blocks added, as well as their totals.
+func NewCollector(keep int) *Collector {
+	return &Collector{keep: keep, total: newStats()}
+}
+
+// Add adds the statistics of b, which is normally the block just
+// committed, to c. Opts are passed to Block.
+func (c *Collector) Add(b *bc.UnsignedBlock, opts ...txvm.Option) error {
+	bs, err := Block(b, opts...)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total.merge(&bs.Stats)
+	if c.keep > 0 {
+		if len(c.recent) == c.keep {
+			c.recent = append(c.recent[:0], c.recent[1:]...)
+		}
+		c.recent = append(c.recent, bs)
+	}
+	return nil
+}
+
+// Total returns the totals of the blocks added to c.
+func (c *Collector) Total() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total.copy()
+}
+
+// Recent returns the statistics of the last n blocks added to c, or
+// as many of them as c keeps, oldest first, and their totals.
+func (c *Collector) Recent(n int) ([]*BlockStats, Stats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n > len(c.recent) || n < 0 {
+		n = len(c.recent)
+	}
+	blocks := make([]*BlockStats, n)
+	sum := newStats()
+	for i, bs := range c.recent[len(c.recent)-n:] {
+		blocks[i] = &BlockStats{Height: bs.Height, TimestampMS: bs.TimestampMS, Stats: bs.copy()}
+		sum.merge(&bs.Stats)
+	}
+	return blocks, sum
+}
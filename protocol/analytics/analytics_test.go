@@ -0,0 +1,64 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/txgen"
+)
+
+func TestCollector(t *testing.T) {
+	c := prottest.NewChain(t)
+	g := txgen.New(1)
+	col := NewCollector(2)
+
+	var txs int64
+	for i := 0; i < 3; i++ {
+		batch, err := g.Txs(c.State(), time.Now(), 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := prottest.MakeBlock(t, c, batch)
+		if err := col.Add(b.UnsignedBlock); err != nil {
+			t.Fatal(err)
+		}
+		txs += int64(len(batch))
+	}
+
+	total := col.Total()
+	if total.Blocks != 3 || total.Txs != txs {
+		t.Errorf("totals of %d blocks, %d txs; want 3, %d", total.Blocks, total.Txs, txs)
+	}
+	if n := total.Opcodes["finalize"]; n != txs {
+		t.Errorf("finalize executed %d times, want %d", n, txs)
+	}
+	if total.Runlimit.Count != txs || total.Runlimit.Min <= 0 || total.Runlimit.Max < total.Runlimit.Min {
+		t.Errorf("runlimit distribution %+v", total.Runlimit)
+	}
+	var buckets int64
+	for _, n := range total.Runlimit.Buckets {
+		buckets += n
+	}
+	if buckets != txs {
+		t.Errorf("runlimit buckets hold %d txs, want %d", buckets, txs)
+	}
+	var issued uint64
+	for _, a := range total.Assets {
+		issued += a.Issued
+	}
+	if issued == 0 {
+		t.Error("no issuance counted")
+	}
+	if len(total.Contracts) == 0 {
+		t.Error("no contract usage counted")
+	}
+
+	recent, sum := col.Recent(5)
+	if len(recent) != 2 || recent[0].Height+1 != recent[1].Height || sum.Blocks != 2 {
+		t.Errorf("Recent(5) = %d blocks, total of %d; want the last 2", len(recent), sum.Blocks)
+	}
+	if sum.Txs != recent[0].Txs+recent[1].Txs {
+		t.Errorf("recent total of %d txs, want %d", sum.Txs, recent[0].Txs+recent[1].Txs)
+	}
+}
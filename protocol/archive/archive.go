@@ -0,0 +1,265 @@
+/*
+Package archive reads and writes raw blockchain archives.
+
+An archive is a deterministic stream of blocks, suitable for backups,
+replication, and reproducible test fixtures. It begins with a short
+header (a magic string and a format version) followed by one record
+per block. Each record is:
+
+	uvarint(N) || BLOCK || SHA3-256(BLOCK)
+
+where BLOCK is the N-byte serialization of the block as produced by
+bc.Block.Bytes.
+
+Blocks in an archive must be contiguous: each block's height is one
+more than the previous block's, and its PreviousBlockId is the hash of
+the previous block's header. A Reader checks this, along with each
+record's checksum, as it goes.
+*/
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+
+	"i10r.io/crypto/sha3"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+)
+
+// Version is the archive format version written by Writer.
+const Version = 1
+
+// MaxRecordSize is the largest block serialization a Reader will
+// accept.
+const MaxRecordSize = 256 << 20
+
+var magic = []byte("txvmchain")
+
+var (
+	// ErrFormat is returned when an archive's header is malformed or
+	// has an unsupported version.
+	ErrFormat = errors.New("invalid archive format")
+
+	// ErrChecksum is returned when a record's checksum does not match
+	// its contents.
+	ErrChecksum = errors.New("archive record checksum mismatch")
+
+	// ErrDiscontinuous is returned when a block does not follow the
+	// previous block in the archive.
+	ErrDiscontinuous = errors.New("discontinuous blocks in archive")
+
+	// ErrRecordSize is returned when a record exceeds MaxRecordSize.
+	ErrRecordSize = errors.New("archive record too large")
+)
+
+// Writer writes blocks to an archive.
+type Writer struct {
+	w       io.Writer
+	started bool
+	prev    *bc.BlockHeader
+}
+
+// NewWriter returns a Writer that writes an archive to w.
+// The archive header is written with the first block.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteBlock appends b to the archive. It must follow the previously
+// written block, if any.
+func (w *Writer) WriteBlock(b *bc.Block) error {
+	err := checkContinuity(w.prev, b.BlockHeader)
+	if err != nil {
+		return err
+	}
+	if !w.started {
+		err = w.writeHeader()
+		if err != nil {
+			return err
+		}
+	}
+	bits, err := b.Bytes()
+	if err != nil {
+		return errors.Wrapf(err, "serializing block %d", b.Height)
+	}
+
+	var buf bytes.Buffer
+	var lenbuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenbuf[:], uint64(len(bits)))
+	buf.Write(lenbuf[:n])
+	buf.Write(bits)
+	sum := sha3.Sum256(bits)
+	buf.Write(sum[:])
+
+	_, err = w.w.Write(buf.Bytes())
+	if err != nil {
+		return errors.Wrapf(err, "writing block %d", b.Height)
+	}
+	w.prev = b.BlockHeader
+	return nil
+}
+
+func (w *Writer) writeHeader() error {
+	hdr := append(append([]byte{}, magic...), Version)
+	_, err := w.w.Write(hdr)
+	if err != nil {
+		return errors.Wrap(err, "writing archive header")
+	}
+	w.started = true
+	return nil
+}
+
+// Close finishes the archive. An archive with no blocks still gets a
+// header, so that it can be distinguished from an empty file.
+func (w *Writer) Close() error {
+	if w.started {
+		return nil
+	}
+	return w.writeHeader()
+}
+
+// Reader reads blocks from an archive.
+type Reader struct {
+	r       *bufio.Reader
+	started bool
+	prev    *bc.BlockHeader
+}
+
+// NewReader returns a Reader that reads an archive from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// ReadBlock reads the next block from the archive. It returns io.EOF
+// (unwrapped) when there are no more blocks.
+func (r *Reader) ReadBlock() (*bc.Block, error) {
+	if !r.started {
+		err := r.readHeader()
+		if err != nil {
+			return nil, err
+		}
+	}
+	n, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "reading record length")
+	}
+	if n > MaxRecordSize {
+		return nil, errors.WithDetailf(ErrRecordSize, "record size %d", n)
+	}
+	rec := make([]byte, n+32)
+	_, err = io.ReadFull(r.r, rec)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading record")
+	}
+	bits, sum := rec[:n], rec[n:]
+	if want := sha3.Sum256(bits); !bytes.Equal(sum, want[:]) {
+		return nil, errors.WithDetailf(ErrChecksum, "got %x, want %x", sum, want[:])
+	}
+
+	b := new(bc.Block)
+	err = b.FromBytes(bits)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing block")
+	}
+	err = checkContinuity(r.prev, b.BlockHeader)
+	if err != nil {
+		return nil, err
+	}
+	r.prev = b.BlockHeader
+	return b, nil
+}
+
+func (r *Reader) readHeader() error {
+	hdr := make([]byte, len(magic)+1)
+	_, err := io.ReadFull(r.r, hdr)
+	if err != nil {
+		return errors.WithDetailf(ErrFormat, "reading archive header: %s", err)
+	}
+	if !bytes.Equal(hdr[:len(magic)], magic) {
+		return errors.WithDetail(ErrFormat, "bad magic")
+	}
+	if v := hdr[len(magic)]; v != Version {
+		return errors.WithDetailf(ErrFormat, "unsupported version %d", v)
+	}
+	r.started = true
+	return nil
+}
+
+func checkContinuity(prev, bh *bc.BlockHeader) error {
+	if bh == nil {
+		return errors.WithDetail(ErrDiscontinuous, "missing block header")
+	}
+	if prev == nil {
+		return nil
+	}
+	if bh.Height != prev.Height+1 {
+		return errors.WithDetailf(ErrDiscontinuous, "height %d follows height %d", bh.Height, prev.Height)
+	}
+	if bh.PreviousBlockId == nil || *bh.PreviousBlockId != prev.Hash() {
+		return errors.WithDetailf(ErrDiscontinuous, "block %d does not refer to block %d", bh.Height, prev.Height)
+	}
+	return nil
+}
+
+// BlockGetter is the subset of protocol.Store needed by Export.
+type BlockGetter interface {
+	GetBlock(context.Context, uint64) (*bc.Block, error)
+}
+
+// Export writes the blocks at heights from through to (inclusive)
+// to w as a complete archive.
+func Export(ctx context.Context, w io.Writer, g BlockGetter, from, to uint64) error {
+	if from == 0 || from > to {
+		return errors.New("invalid height range")
+	}
+	aw := NewWriter(w)
+	for h := from; h <= to; h++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		b, err := g.GetBlock(ctx, h)
+		if err != nil {
+			return errors.Wrapf(err, "getting block %d", h)
+		}
+		err = aw.WriteBlock(b)
+		if err != nil {
+			return err
+		}
+	}
+	return aw.Close()
+}
+
+// Import reads every block from the archive in r, calling f on each
+// in order. It stops at the first error from the archive or from f.
+// It returns the number of blocks successfully passed to f.
+//
+// A typical f is the CommitBlock method of a protocol.Chain, which
+// applies each block and checks its state commitments.
+func Import(ctx context.Context, r io.Reader, f func(context.Context, *bc.Block) error) (int, error) {
+	ar := NewReader(r)
+	var n int
+	for {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		b, err := ar.ReadBlock()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, errors.Wrapf(err, "reading block %d of archive", n)
+		}
+		err = f(ctx, b)
+		if err != nil {
+			return n, errors.Wrapf(err, "importing block %d", b.Height)
+		}
+		n++
+	}
+}
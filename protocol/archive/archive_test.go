@@ -0,0 +1,138 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/bc/bctest"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/prottest/memstore"
+	"i10r.io/testutil"
+)
+
+func newTestArchive(t *testing.T) (*memstore.MemStore, []byte) {
+	store := memstore.New()
+	c := prottest.NewChain(t, prottest.WithStore(store))
+	b1 := prottest.Initial(t, c)
+	for i := 0; i < 3; i++ {
+		tx := bctest.EmptyTx(t, b1.Hash(), time.Now().Add(time.Hour))
+		prottest.MakeBlock(t, c, []*bc.Tx{tx})
+	}
+
+	var buf bytes.Buffer
+	err := Export(context.Background(), &buf, store, 1, c.Height())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	return store, buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, arch := newTestArchive(t)
+
+	var again bytes.Buffer
+	err := Export(ctx, &again, store, 1, uint64(len(store.Blocks)))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !bytes.Equal(arch, again.Bytes()) {
+		t.Error("exporting the same blocks twice produced different archives")
+	}
+
+	var c *protocol.Chain
+	n, err := Import(ctx, bytes.NewReader(arch), func(ctx context.Context, b *bc.Block) error {
+		if c == nil {
+			var err error
+			c, err = protocol.NewChain(ctx, b, memstore.New(), nil)
+			if err != nil {
+				return err
+			}
+		}
+		return c.CommitBlock(ctx, b)
+	})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if n != len(store.Blocks) {
+		t.Errorf("imported %d blocks, want %d", n, len(store.Blocks))
+	}
+	want := store.Blocks[uint64(n)].ContractsRoot.Byte32()
+	if got := c.State().ContractsTree.RootHash(); got != want {
+		t.Errorf("imported contracts root %x, want %x", got[:], want[:])
+	}
+}
+
+func TestCorruption(t *testing.T) {
+	_, arch := newTestArchive(t)
+
+	cases := []struct {
+		name    string
+		mutate  func([]byte) []byte
+		wantErr error
+	}{{
+		name:    "bad magic",
+		mutate:  func(b []byte) []byte { b[0] ^= 1; return b },
+		wantErr: ErrFormat,
+	}, {
+		name:    "bad version",
+		mutate:  func(b []byte) []byte { b[len(magic)] = Version + 1; return b },
+		wantErr: ErrFormat,
+	}, {
+		name:    "bad checksum",
+		mutate:  func(b []byte) []byte { b[len(b)-1] ^= 1; return b },
+		wantErr: ErrChecksum,
+	}, {
+		name:    "truncated",
+		mutate:  func(b []byte) []byte { return b[:len(b)-1] },
+		wantErr: io.ErrUnexpectedEOF,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := c.mutate(append([]byte{}, arch...))
+			_, err := Import(context.Background(), bytes.NewReader(b), func(context.Context, *bc.Block) error { return nil })
+			if errors.Root(err) != c.wantErr {
+				t.Errorf("got error %v, want %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscontinuous(t *testing.T) {
+	store, _ := newTestArchive(t)
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	err := w.WriteBlock(store.Blocks[1])
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = w.WriteBlock(store.Blocks[3])
+	if errors.Root(err) != ErrDiscontinuous {
+		t.Errorf("got error %v, want ErrDiscontinuous", err)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	err := NewWriter(&buf).Close()
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	n, err := Import(context.Background(), &buf, func(context.Context, *bc.Block) error { return nil })
+	if err != nil || n != 0 {
+		t.Errorf("Import(empty archive) = %d, %v; want 0, nil", n, err)
+	}
+
+	_, err = Import(context.Background(), new(bytes.Buffer), func(context.Context, *bc.Block) error { return nil })
+	if errors.Root(err) != ErrFormat {
+		t.Errorf("Import(empty file) error = %v, want ErrFormat", err)
+	}
+}
@@ -0,0 +1,169 @@
+// Package assets computes and verifies asset IDs and keeps a
+// registry of the metadata that describes them.
+//
+// An asset ID commits on chain to the contract that issues the asset
+// and to its tag, but says nothing people can read. Metadata (a name,
+// a number of decimal places, the issuer's URL) lives off chain in a
+// Document signed by the asset's issuance keys, so a holder of the
+// document can check that it speaks for the asset without trusting
+// whoever served it.
+package assets
+
+import (
+	"i10r.io/crypto/ed25519"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+)
+
+var (
+	// ErrAssetID is returned when a definition does not produce the
+	// asset ID it is paired with.
+	ErrAssetID = errors.New("definition does not match asset ID")
+
+	// ErrQuorum is returned for a document without enough valid
+	// signatures from its definition's keys.
+	ErrQuorum = errors.New("not enough valid signatures")
+
+	// ErrVersion is returned for a definition naming an unknown
+	// version of the standard issuance contract.
+	ErrVersion = errors.New("unknown issuance contract version")
+)
+
+// Definition is the on-chain identity of an asset issued with the
+// standard asset-issuance contract: the contract version, the keys
+// that authorize issuance, and the asset tag.
+type Definition struct {
+	Version int                 `json:"contract_version"`
+	Quorum  int                 `json:"quorum"`
+	Pubkeys []ed25519.PublicKey `json:"pubkeys"`
+	Tag     chainjson.HexBytes  `json:"asset_tag"`
+}
+
+// ID returns the asset ID of def.
+func (def *Definition) ID() (bc.Hash, error) {
+	if _, ok := standard.AssetContractSeed[def.Version]; !ok {
+		return bc.Hash{}, errors.WithDetailf(ErrVersion, "version %d", def.Version)
+	}
+	return bc.NewHash(standard.AssetID(def.Version, def.Quorum, def.Pubkeys, def.Tag)), nil
+}
+
+// Verify checks that def is the definition of the asset with the
+// given ID.
+func (def *Definition) Verify(assetID bc.Hash) error {
+	id, err := def.ID()
+	if err != nil {
+		return err
+	}
+	if id != assetID {
+		return errors.WithDetailf(ErrAssetID, "definition gives %x, want %x", id.Bytes(), assetID.Bytes())
+	}
+	return nil
+}
+
+// ProgramAssetID returns the ID of the asset issued by a contract
+// with the given initial program and tag. It is for issuance
+// contracts other than the standard one; for those, use
+// Definition.ID.
+func ProgramAssetID(prog, tag []byte) bc.Hash {
+	seed := txvm.ContractSeed(prog)
+	return bc.NewHash(txvm.AssetID(seed[:], tag))
+}
+
+// Metadata is the human-readable description of an asset.
+type Metadata struct {
+	Name      string `json:"name"`
+	Decimals  int    `json:"decimals"`
+	IssuerURL string `json:"issuer_url"`
+}
+
+// Document is an asset's metadata signed by its issuance keys.
+// Signatures is parallel to Definition.Pubkeys; an empty entry is a
+// key that did not sign.
+type Document struct {
+	AssetID    bc.Hash              `json:"asset_id"`
+	Definition Definition           `json:"definition"`
+	Metadata   Metadata             `json:"metadata"`
+	Signatures []chainjson.HexBytes `json:"signatures"`
+}
+
+// NewDocument returns an unsigned document describing the asset
+// defined by def.
+func NewDocument(def Definition, md Metadata) (*Document, error) {
+	id, err := def.ID()
+	if err != nil {
+		return nil, err
+	}
+	return &Document{
+		AssetID:    id,
+		Definition: def,
+		Metadata:   md,
+		Signatures: make([]chainjson.HexBytes, len(def.Pubkeys)),
+	}, nil
+}
+
+// SigningMessage returns the message the issuance keys sign. It
+// covers the asset ID and the metadata, encoded as a txvm tuple.
+func (doc *Document) SigningMessage() []byte {
+	tup := txvm.Tuple{
+		txvm.Bytes(doc.AssetID.Bytes()),
+		txvm.Bytes(doc.Metadata.Name),
+		txvm.Int(doc.Metadata.Decimals),
+		txvm.Bytes(doc.Metadata.IssuerURL),
+	}
+	h := txvm.VMHash("AssetMetadata", txvm.Encode(tup))
+	return h[:]
+}
+
+// Sign adds a signature with prv, which must be the private key for
+// one of the definition's pubkeys.
+func (doc *Document) Sign(prv ed25519.PrivateKey) error {
+	pub := prv.Public().(ed25519.PublicKey)
+	for i, pk := range doc.Definition.Pubkeys {
+		if string(pk) == string(pub) {
+			if len(doc.Signatures) != len(doc.Definition.Pubkeys) {
+				sigs := make([]chainjson.HexBytes, len(doc.Definition.Pubkeys))
+				copy(sigs, doc.Signatures)
+				doc.Signatures = sigs
+			}
+			doc.Signatures[i] = ed25519.Sign(prv, doc.SigningMessage())
+			return nil
+		}
+	}
+	return errors.New("key is not one of the asset's issuance keys")
+}
+
+// Verify checks that doc's definition matches its asset ID and that
+// it carries a quorum of valid signatures from the definition's
+// keys. A document always needs at least one signature, even for an
+// asset whose quorum is zero.
+func (doc *Document) Verify() error {
+	err := doc.Definition.Verify(doc.AssetID)
+	if err != nil {
+		return err
+	}
+	if len(doc.Signatures) > len(doc.Definition.Pubkeys) {
+		return errors.WithDetailf(ErrQuorum, "%d signatures for %d keys", len(doc.Signatures), len(doc.Definition.Pubkeys))
+	}
+	msg := doc.SigningMessage()
+	var n int
+	for i, sig := range doc.Signatures {
+		if len(sig) == 0 {
+			continue
+		}
+		pub := doc.Definition.Pubkeys[i]
+		if len(pub) != ed25519.PublicKeySize {
+			return errors.WithDetailf(ErrQuorum, "key %d is %d bytes", i, len(pub))
+		}
+		if !ed25519.Verify(pub, msg, sig) {
+			return errors.WithDetailf(ErrQuorum, "bad signature for key %d", i)
+		}
+		n++
+	}
+	if n == 0 || n < doc.Definition.Quorum {
+		return errors.WithDetailf(ErrQuorum, "%d of %d", n, doc.Definition.Quorum)
+	}
+	return nil
+}
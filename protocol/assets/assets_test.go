@@ -0,0 +1,159 @@
+package assets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/standard"
+)
+
+func testDoc(t *testing.T) (*Document, []ed25519.PrivateKey) {
+	var (
+		pubs []ed25519.PublicKey
+		prvs []ed25519.PrivateKey
+	)
+	for i := 0; i < 3; i++ {
+		pub, prv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, pub)
+		prvs = append(prvs, prv)
+	}
+	def := Definition{Version: 2, Quorum: 2, Pubkeys: pubs, Tag: []byte("gold")}
+	doc, err := NewDocument(def, Metadata{Name: "Gold", Decimals: 3, IssuerURL: "https://example.com/gold"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc, prvs
+}
+
+func TestDefinition(t *testing.T) {
+	doc, _ := testDoc(t)
+	def := doc.Definition
+	want := bc.NewHash(standard.AssetID(2, 2, def.Pubkeys, def.Tag))
+	if doc.AssetID != want {
+		t.Errorf("asset ID = %x, want %x", doc.AssetID.Bytes(), want.Bytes())
+	}
+	if err := def.Verify(want); err != nil {
+		t.Error(err)
+	}
+	def.Tag = []byte("silver")
+	if err := def.Verify(want); errors.Root(err) != ErrAssetID {
+		t.Errorf("Verify with wrong tag: got %v, want %v", err, ErrAssetID)
+	}
+	def.Version = 9
+	if _, err := def.ID(); errors.Root(err) != ErrVersion {
+		t.Errorf("ID with bad version: got %v, want %v", err, ErrVersion)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	doc, prvs := testDoc(t)
+	if err := doc.Verify(); errors.Root(err) != ErrQuorum {
+		t.Errorf("unsigned: got %v, want %v", err, ErrQuorum)
+	}
+	doc.Sign(prvs[0])
+	if err := doc.Verify(); errors.Root(err) != ErrQuorum {
+		t.Errorf("1 of 2: got %v, want %v", err, ErrQuorum)
+	}
+	doc.Sign(prvs[2])
+	if err := doc.Verify(); err != nil {
+		t.Errorf("2 of 2: %v", err)
+	}
+
+	// The document survives a JSON round trip.
+	b, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Document
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Verify(); err != nil {
+		t.Errorf("after JSON round trip: %v", err)
+	}
+
+	got.Metadata.Decimals = 0
+	if err := got.Verify(); errors.Root(err) != ErrQuorum {
+		t.Errorf("altered metadata: got %v, want %v", err, ErrQuorum)
+	}
+
+	_, other, _ := ed25519.GenerateKey(nil)
+	if err := doc.Sign(other); err == nil {
+		t.Error("Sign with foreign key: got no error")
+	}
+
+	// A malformed key makes the document invalid rather than
+	// panicking.
+	doc.Definition.Pubkeys[1] = doc.Definition.Pubkeys[1][:31]
+	doc.AssetID, _ = doc.Definition.ID()
+	doc.Signatures[1] = doc.Signatures[0]
+	if err := doc.Verify(); errors.Root(err) != ErrQuorum {
+		t.Errorf("short key: got %v, want %v", err, ErrQuorum)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	doc, prvs := testDoc(t)
+	doc.Sign(prvs[0])
+	doc.Sign(prvs[1])
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != fmt.Sprintf("/assets/%x", doc.AssetID.Bytes()) {
+			http.NotFound(w, req)
+			return
+		}
+		json.NewEncoder(w).Encode(doc)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	r := &Registry{Resolver: &HTTPResolver{BaseURL: srv.URL + "/assets/"}}
+	if _, ok := r.Lookup(doc.AssetID); ok {
+		t.Fatal("empty registry has document")
+	}
+	got, err := r.Resolve(ctx, doc.AssetID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Metadata != doc.Metadata {
+		t.Errorf("resolved metadata %+v, want %+v", got.Metadata, doc.Metadata)
+	}
+	if _, ok := r.Lookup(doc.AssetID); !ok {
+		t.Error("resolved document not added to registry")
+	}
+
+	if got := r.FormatAmount(doc.AssetID, 12345); got != "12.345" {
+		t.Errorf("FormatAmount = %q, want 12.345", got)
+	}
+	if got, err := r.ParseAmount(doc.AssetID, "1.5"); err != nil || got != 1500 {
+		t.Errorf("ParseAmount(1.5) = %d, %v, want 1500", got, err)
+	}
+	if got := r.FormatAmount(bc.HashFromBytes([]byte{1}), 12345); got != "12345" {
+		t.Errorf("FormatAmount for unknown asset = %q, want 12345", got)
+	}
+
+	_, err = r.Resolve(ctx, bc.HashFromBytes([]byte{1}))
+	if errors.Root(err) != ErrNotFound {
+		t.Errorf("unknown asset: got %v, want %v", err, ErrNotFound)
+	}
+
+	// A resolver serving a document for the wrong asset is caught.
+	r2 := &Registry{Resolver: ResolverFunc(func(context.Context, bc.Hash) (*Document, error) {
+		return doc, nil
+	})}
+	_, err = r2.Resolve(ctx, bc.HashFromBytes([]byte{1}))
+	if errors.Root(err) != ErrAssetID {
+		t.Errorf("mismatched document: got %v, want %v", err, ErrAssetID)
+	}
+}
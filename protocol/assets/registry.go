@@ -0,0 +1,165 @@
+package assets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"i10r.io/errors"
+	"i10r.io/math/amount"
+	"i10r.io/protocol/bc"
+)
+
+// ErrNotFound is returned by Registry.Resolve for an asset with no
+// known metadata.
+var ErrNotFound = errors.New("asset metadata not found")
+
+// A Resolver fetches the metadata document for an asset from
+// somewhere off chain. It returns ErrNotFound (possibly wrapped) if
+// there is none.
+type Resolver interface {
+	Resolve(ctx context.Context, assetID bc.Hash) (*Document, error)
+}
+
+// ResolverFunc is a function usable as a Resolver.
+type ResolverFunc func(ctx context.Context, assetID bc.Hash) (*Document, error)
+
+// Resolve implements Resolver.
+func (f ResolverFunc) Resolve(ctx context.Context, assetID bc.Hash) (*Document, error) {
+	return f(ctx, assetID)
+}
+
+// Registry is a local map from asset ID to verified metadata
+// documents. Documents not yet in the registry are fetched from its
+// Resolver, if it has one.
+//
+// It is safe for concurrent use.
+type Registry struct {
+	Resolver Resolver
+
+	mu   sync.Mutex
+	docs map[bc.Hash]*Document
+}
+
+// Add verifies doc and adds it to r, replacing any earlier document
+// for the same asset.
+func (r *Registry) Add(doc *Document) error {
+	err := doc.Verify()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.docs == nil {
+		r.docs = make(map[bc.Hash]*Document)
+	}
+	r.docs[doc.AssetID] = doc
+	return nil
+}
+
+// Lookup returns the document for assetID if r has one. It does not
+// consult the Resolver.
+func (r *Registry) Lookup(assetID bc.Hash) (*Document, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	doc, ok := r.docs[assetID]
+	return doc, ok
+}
+
+// Resolve returns the document for assetID, fetching it from the
+// Resolver if r doesn't already have it. A fetched document is
+// verified, and checked to be for assetID, before it is added.
+func (r *Registry) Resolve(ctx context.Context, assetID bc.Hash) (*Document, error) {
+	if doc, ok := r.Lookup(assetID); ok {
+		return doc, nil
+	}
+	if r.Resolver == nil {
+		return nil, errors.WithDetailf(ErrNotFound, "asset %x", assetID.Bytes())
+	}
+	doc, err := r.Resolver.Resolve(ctx, assetID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolving asset %x", assetID.Bytes())
+	}
+	if doc.AssetID != assetID {
+		return nil, errors.WithDetailf(ErrAssetID, "resolver returned document for %x", doc.AssetID.Bytes())
+	}
+	err = r.Add(doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "resolving asset %x", assetID.Bytes())
+	}
+	return doc, nil
+}
+
+// Assets returns the IDs of the assets in r, in no particular order.
+func (r *Registry) Assets() []bc.Hash {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]bc.Hash, 0, len(r.docs))
+	for id := range r.docs {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Decimals returns the number of decimal places of the asset with
+// the given ID, if r has its metadata and the number is in range.
+func (r *Registry) Decimals(assetID bc.Hash) (int, bool) {
+	doc, ok := r.Lookup(assetID)
+	if !ok || doc.Metadata.Decimals < 0 || doc.Metadata.Decimals > amount.MaxDecimals {
+		return 0, false
+	}
+	return doc.Metadata.Decimals, true
+}
+
+// FormatAmount returns units of the given asset in decimal notation
+// at the asset's precision. For an asset r knows nothing about, it
+// returns the plain base-unit integer.
+func (r *Registry) FormatAmount(assetID bc.Hash, units int64) string {
+	d, _ := r.Decimals(assetID)
+	return amount.Format(units, d)
+}
+
+// ParseAmount is the inverse of FormatAmount.
+func (r *Registry) ParseAmount(assetID bc.Hash, s string) (int64, error) {
+	d, _ := r.Decimals(assetID)
+	return amount.Parse(s, d)
+}
+
+// HTTPResolver fetches documents as JSON from BaseURL followed by
+// the hex asset ID.
+type HTTPResolver struct {
+	BaseURL string
+	Client  *http.Client // if nil, http.DefaultClient is used
+}
+
+// Resolve implements Resolver.
+func (h *HTTPResolver) Resolve(ctx context.Context, assetID bc.Hash) (*Document, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s%x", h.BaseURL, assetID.Bytes()), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("fetching %s: %s", req.URL, resp.Status)
+	}
+	doc := new(Document)
+	err = json.NewDecoder(resp.Body).Decode(doc)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", req.URL)
+	}
+	return doc, nil
+}
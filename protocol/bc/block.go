@@ -1,6 +1,7 @@
 package bc
 
 import (
+	"context"
 	"database/sql/driver"
 	"encoding/hex"
 
@@ -30,8 +31,9 @@ var ErrTooFewSignatures = errors.New("too few block signatures")
 
 // SignBlock produces a SignedBlock from a Block. It invokes its
 // callback once for each position in [0..N) where N is the number of
-// pubkeys in the previous block's NextPredicate, until a quorum of
-// signatures is obtained.
+// pubkeys in the previous block's NextPredicate, until signatures
+// meeting the predicate's quorum, or for a weighted predicate its
+// threshold (see WeightedPredicateVersion), are obtained.
 //
 // Any callback returning an error will cause SignBlock to return with an
 // error. A callback may also return (nil, nil), causing it to be
@@ -50,15 +52,15 @@ func SignBlock(b *UnsignedBlock, prev *BlockHeader, f func(int) (interface{}, er
 	if pred == nil {
 		return nil, errors.New("no next predicate in previous blockheader")
 	}
-	if pred.Version != 1 {
-		return nil, errors.New("unknown predicate version")
+	signers, q, err := pred.Signers()
+	if err != nil {
+		return nil, err
 	}
-	sb.Arguments = make([]interface{}, len(pred.Pubkeys))
-	q := pred.Quorum
+	sb.Arguments = make([]interface{}, len(signers))
 	if q > 0 && f == nil {
 		return nil, errors.New("no signature function provided")
 	}
-	for i := 0; q > 0 && i < len(pred.Pubkeys); i++ {
+	for i := 0; q > 0 && i < len(signers); i++ {
 		arg, err := f(i)
 		if err != nil {
 			return nil, errors.Wrapf(err, "getting signature %d for block %d", i, b.Height)
@@ -69,7 +71,7 @@ func SignBlock(b *UnsignedBlock, prev *BlockHeader, f func(int) (interface{}, er
 		if sig, ok := arg.([]byte); ok {
 			if len(sig) > 0 {
 				sb.Arguments[i] = sig
-				q--
+				q -= signers[i].Weight
 			}
 			continue
 		}
@@ -121,20 +123,62 @@ func (b *Block) Value() (driver.Value, error) {
 	return b.Bytes()
 }
 
+// ProgramCache holds the decoded programs of the contracts called in
+// the transactions of blocks decoded by FromBytes. Most are one of a
+// few standard contracts.
+var ProgramCache = txvm.NewProgramCache(16 << 20)
+
 // FromBytes parses a Block from a byte slice, by unmarshaling and
 // converting a RawBlock protobuf.
+//
+// The transactions in b refer to bits rather than copying from it,
+// so bits must not be modified while b is in use. Use Copy to obtain
+// a Block independent of bits.
 func (b *Block) FromBytes(bits []byte) error {
-	var rb RawBlock
-	err := proto.Unmarshal(bits, &rb)
+	return b.FromBytesContext(context.Background(), bits)
+}
+
+// FromBytesContext is like FromBytes, but stops validating the
+// block's transactions, returning an error whose root is
+// txvm.ErrCanceled, if ctx is done first. It also stops early if any
+// transaction is invalid. Opts, such as txvm.WithPlugins, are passed
+// to the VM running each transaction.
+func (b *Block) FromBytesContext(ctx context.Context, bits []byte, opts ...txvm.Option) error {
+	rb, err := DecodeRawBlock(bits)
 	if err != nil {
 		return err
 	}
+	block, err := rb.Block(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	b.UnsignedBlock = block.UnsignedBlock
+	b.Arguments = append(b.Arguments, block.Arguments...)
+	return nil
+}
+
+// Block runs the programs of rb's transactions, in parallel, and
+// returns the resulting Block. It stops, as FromBytesContext does,
+// if ctx is done or any transaction is invalid. Opts are passed to
+// each transaction's VM after the block's own.
+func (rb *RawBlock) Block(ctx context.Context, opts ...txvm.Option) (*Block, error) {
+	var (
+		network Hash
+		err     error
+	)
+	if rb.Header.Version >= NetworkVersion {
+		network, err = rb.Header.Network()
+		if err != nil {
+			return nil, err
+		}
+	}
 	txs := make([]*Tx, len(rb.Transactions))
-	var eg errgroup.Group
+	eg, ctx := errgroup.WithContext(ctx)
+	opts = append([]txvm.Option{txvm.Context(ctx), NetworkOption(network), txvm.WithProgramCache(ProgramCache)}, opts...)
 	for i := range rb.Transactions {
 		i := i
 		eg.Go(func() error {
-			tx, err := NewTx(rb.Transactions[i].Program, rb.Transactions[i].Version, rb.Transactions[i].Runlimit)
+			tx, err := NewTx(rb.Transactions[i].Program, rb.Transactions[i].Version, rb.Transactions[i].Runlimit, opts...)
 			if err != nil {
 				return err
 			}
@@ -147,23 +191,33 @@ func (b *Block) FromBytes(bits []byte) error {
 	}
 	err = eg.Wait()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	b.UnsignedBlock = &UnsignedBlock{
-		BlockHeader:  rb.Header,
-		Transactions: txs,
+	b := &Block{
+		UnsignedBlock: &UnsignedBlock{
+			BlockHeader:  rb.Header,
+			Transactions: txs,
+		},
+		Arguments: rb.BlockArguments(),
 	}
+	return b, nil
+}
+
+// BlockArguments returns rb's arguments in the form of
+// Block.Arguments.
+func (rb *RawBlock) BlockArguments() []interface{} {
+	var args []interface{}
 	for _, arg := range rb.Arguments {
 		switch arg.Type {
 		case DataType_BYTES:
-			b.Arguments = append(b.Arguments, arg.Bytes)
+			args = append(args, arg.Bytes)
 		case DataType_INT:
-			b.Arguments = append(b.Arguments, arg.Int)
+			args = append(args, arg.Int)
 		case DataType_TUPLE:
-			b.Arguments = append(b.Arguments, arg.Tuple)
+			args = append(args, arg.Tuple)
 		}
 	}
-	return nil
+	return args
 }
 
 // Bytes encodes the Block as a byte slice, by converting it to a
@@ -180,6 +234,11 @@ func (b *Block) Bytes() ([]byte, error) {
 	var args []*DataItem
 	for _, arg := range b.Arguments {
 		switch a := arg.(type) {
+		case nil:
+			// A position SignBlock left unsigned. Keep it, as an
+			// empty signature, so the arguments still line up with
+			// the predicate's keys.
+			args = append(args, &DataItem{Type: DataType_BYTES})
 		case []byte:
 			args = append(args, &DataItem{Type: DataType_BYTES, Bytes: a})
 		case int64:
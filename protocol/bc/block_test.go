@@ -2,12 +2,14 @@ package bc
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"strings"
 	"testing"
 
 	"i10r.io/crypto/ed25519"
 	"i10r.io/errors"
+	"i10r.io/protocol/txvm"
 	"i10r.io/protocol/txvm/asm"
 	"i10r.io/protocol/txvm/txvmtest"
 	"i10r.io/testutil"
@@ -99,6 +101,80 @@ func TestBlockBytes(t *testing.T) {
 	}
 }
 
+func TestBlockBorrow(t *testing.T) {
+	bits := append([]byte{}, testBlockBytes...)
+	b := new(Block)
+	err := b.FromBytes(bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := b.Copy()
+	if !testutil.DeepEqual(c, b) {
+		t.Fatalf("Copy() = %v, want %v", c, b)
+	}
+
+	prog := b.Transactions[0].Program
+	if &prog[0] != &bits[bytes.Index(bits, prog)] {
+		t.Error("decoded program does not refer to the input buffer")
+	}
+
+	for i := range bits {
+		bits[i] = 0
+	}
+	if !testutil.DeepEqual(c, testBlock()) {
+		t.Error("Copy() refers to the input buffer")
+	}
+}
+
+func TestBlockFromBytesContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := new(Block).FromBytesContext(ctx, testBlockBytes)
+	if errors.Root(err) != txvm.ErrCanceled {
+		t.Errorf("got error %v, want %v", err, txvm.ErrCanceled)
+	}
+}
+
+func TestRawBlockPlugins(t *testing.T) {
+	one, err := txvm.NewPlugins(txvm.PluginOp{
+		Selector: txvm.MinPluginSelector,
+		Name:     "one",
+		Func:     func([]txvm.Data) ([]txvm.Data, error) { return []txvm.Data{txvm.Int(1)}, nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := asm.Assemble("65536 ext verify 'id' 10 nonce finalize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb := &RawBlock{
+		Header:       &BlockHeader{Version: 3, Height: 2},
+		Transactions: []*RawTx{{Version: txvm.ExtOpsVersion, Runlimit: 10000, Program: prog}},
+	}
+	_, err = rb.Block(context.Background())
+	if errors.Root(err) != txvm.ErrExt {
+		t.Errorf("without plugins: got error %v, want %v", err, txvm.ErrExt)
+	}
+	b, err := rb.Block(context.Background(), txvm.WithPlugins(one))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Transactions) != 1 || !b.Transactions[0].Finalized {
+		t.Errorf("with plugins: got %v, want one finalized transaction", b.Transactions)
+	}
+}
+
+func BenchmarkBlockFromBytes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		err := new(Block).FromBytes(testBlockBytes)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func TestBlockMarshal(t *testing.T) {
 	block := testBlock()
 
@@ -265,6 +341,21 @@ func TestSignBlock(t *testing.T) {
 					t.Errorf("got %s, want %s", showSigs(b.Arguments), showSigs(tc.wantsigs))
 				}
 			}
+			if err == nil {
+				// The unsigned positions survive serialization.
+				bits, err := b.Bytes()
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got Block
+				err = got.FromBytes(bits)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got.Arguments) != 3 {
+					t.Errorf("after round trip, %d arguments, want 3", len(got.Arguments))
+				}
+			}
 		})
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"testing"
 
+	"i10r.io/errors"
 	"i10r.io/testutil"
 )
 
@@ -48,6 +49,63 @@ func TestBlockHeaderHash(t *testing.T) {
 	}
 }
 
+func TestCommitments(t *testing.T) {
+	bh := &BlockHeader{Version: CommitmentsVersion, NextPredicate: &Predicate{Version: 1}}
+	version, cs, err := bh.Commitments()
+	if err != nil || version != CommitmentsAreaVersion || len(cs) != 0 {
+		t.Errorf("empty header: got %d, %v, %v", version, cs, err)
+	}
+	h0 := bh.Hash()
+
+	bh.SetCommitments([]Commitment{{"fees", []byte{2}}, {"checkpoint", []byte{1}}})
+	if bh.Hash() == h0 {
+		t.Error("commitments do not change the block ID")
+	}
+	_, cs, err = bh.Commitments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Commitment{{"checkpoint", []byte{1}}, {"fees", []byte{2}}}
+	if !testutil.DeepEqual(cs, want) {
+		t.Errorf("commitments = %v, want %v", cs, want)
+	}
+	if v, ok := bh.Commitment("fees"); !ok || !bytes.Equal(v, []byte{2}) {
+		t.Errorf("Commitment(fees) = %x, %v", v, ok)
+	}
+	if _, ok := bh.Commitment("other"); ok {
+		t.Error("Commitment(other) found")
+	}
+
+	// An area version from the future parses, with no commitments.
+	bh.ExtraFields[0].Tuple[0].Int = CommitmentsAreaVersion + 1
+	bh.ExtraFields[0].Tuple = append(bh.ExtraFields[0].Tuple, &DataItem{Type: DataType_BYTES})
+	version, cs, err = bh.Commitments()
+	if err != nil || version != CommitmentsAreaVersion+1 || cs != nil {
+		t.Errorf("future area: got %d, %v, %v", version, cs, err)
+	}
+
+	bad := []func(*BlockHeader){
+		func(bh *BlockHeader) { bh.ExtraFields = append(bh.ExtraFields, bh.ExtraFields[0]) },
+		func(bh *BlockHeader) { bh.ExtraFields[0] = &DataItem{Type: DataType_INT} },
+		func(bh *BlockHeader) { bh.ExtraFields[0].Tuple[1].Tuple[0].Tuple[1].Type = DataType_INT },
+		func(bh *BlockHeader) {
+			entries := bh.ExtraFields[0].Tuple[1].Tuple
+			entries[0], entries[1] = entries[1], entries[0]
+		},
+		func(bh *BlockHeader) {
+			entries := bh.ExtraFields[0].Tuple[1].Tuple
+			entries[1] = entries[0]
+		},
+	}
+	for i, f := range bad {
+		bh.SetCommitments(want)
+		f(bh)
+		if _, _, err := bh.Commitments(); errors.Root(err) != ErrCommitments {
+			t.Errorf("case %d: got error %v, want %v", i, err, ErrCommitments)
+		}
+	}
+}
+
 func TestBlockHeaderScanValue(t *testing.T) {
 	filledBytes := mustDecodeHex("080310011a0909000000000000000120e80728d0860330013a0909000000000000000242090900000000000000034a090900000000000000045246080110011a4000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000")
 	val, err := filledBlock.Value()
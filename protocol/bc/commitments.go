@@ -0,0 +1,116 @@
+package bc
+
+import (
+	"sort"
+
+	"i10r.io/errors"
+)
+
+// CommitmentsVersion is the first block version whose header may
+// carry a commitments area.
+//
+// The area is the header's single extra field, a tuple
+//
+//	{areaVersion, {{name1, value1}, {name2, value2}, ...}}
+//
+// of an int and a tuple of named commitments: pairs of byte strings,
+// sorted by name with no name repeated. The block ID commits to the
+// area along with the rest of the header, so a new kind of
+// commitment can be added to blocks without a change to the header
+// format. Validators check the commitments they know and ignore the
+// rest, and ignore the contents of an area with a version they don't
+// know, so such an addition is a soft fork.
+const CommitmentsVersion = 4
+
+// CommitmentsAreaVersion is the version of the commitments area
+// described at CommitmentsVersion.
+const CommitmentsAreaVersion = 1
+
+// ErrCommitments is returned for a block header whose extra fields
+// are not a well-formed commitments area.
+var ErrCommitments = errors.New("malformed header commitments")
+
+// A Commitment is a named value in a block header's commitments
+// area.
+type Commitment struct {
+	Name  string
+	Value []byte
+}
+
+// SetCommitments replaces bh's extra fields with a commitments area
+// holding cs, or with nothing if cs is empty. It sorts cs by name.
+func (bh *BlockHeader) SetCommitments(cs []Commitment) {
+	if len(cs) == 0 {
+		bh.ExtraFields = nil
+		return
+	}
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].Name < cs[j].Name })
+	entries := &DataItem{Type: DataType_TUPLE}
+	for _, c := range cs {
+		entries.Tuple = append(entries.Tuple, &DataItem{
+			Type: DataType_TUPLE,
+			Tuple: []*DataItem{
+				{Type: DataType_BYTES, Bytes: []byte(c.Name)},
+				{Type: DataType_BYTES, Bytes: c.Value},
+			},
+		})
+	}
+	bh.ExtraFields = []*DataItem{{
+		Type: DataType_TUPLE,
+		Tuple: []*DataItem{
+			{Type: DataType_INT, Int: CommitmentsAreaVersion},
+			entries,
+		},
+	}}
+}
+
+// Commitments parses bh's commitments area. It returns the area's
+// version and, if that is CommitmentsAreaVersion, its commitments. A
+// header with no extra fields has an empty area of the current
+// version.
+func (bh *BlockHeader) Commitments() (int64, []Commitment, error) {
+	if len(bh.ExtraFields) == 0 {
+		return CommitmentsAreaVersion, nil, nil
+	}
+	if len(bh.ExtraFields) > 1 {
+		return 0, nil, errors.WithDetailf(ErrCommitments, "%d extra fields", len(bh.ExtraFields))
+	}
+	area := bh.ExtraFields[0]
+	if area.Type != DataType_TUPLE || len(area.Tuple) < 1 || area.Tuple[0].Type != DataType_INT {
+		return 0, nil, errors.WithDetail(ErrCommitments, "area is not a versioned tuple")
+	}
+	version := area.Tuple[0].Int
+	if version != CommitmentsAreaVersion {
+		return version, nil, nil
+	}
+	if len(area.Tuple) != 2 || area.Tuple[1].Type != DataType_TUPLE {
+		return 0, nil, errors.WithDetail(ErrCommitments, "area is not a tuple of commitments")
+	}
+	var cs []Commitment
+	for i, entry := range area.Tuple[1].Tuple {
+		if entry.Type != DataType_TUPLE || len(entry.Tuple) != 2 || entry.Tuple[0].Type != DataType_BYTES || entry.Tuple[1].Type != DataType_BYTES {
+			return 0, nil, errors.WithDetailf(ErrCommitments, "entry %d is not a name-value pair", i)
+		}
+		c := Commitment{Name: string(entry.Tuple[0].Bytes), Value: entry.Tuple[1].Bytes}
+		if i > 0 && c.Name <= cs[i-1].Name {
+			return 0, nil, errors.WithDetailf(ErrCommitments, "entry %d (%q) out of order", i, c.Name)
+		}
+		cs = append(cs, c)
+	}
+	return version, cs, nil
+}
+
+// Commitment returns the value of the named commitment in bh's
+// commitments area, if it has one.
+func (bh *BlockHeader) Commitment(name string) ([]byte, bool) {
+	_, cs, err := bh.Commitments()
+	if err != nil {
+		return nil, false
+	}
+	for _, c := range cs {
+		if c.Name == name {
+			return c.Value, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,318 @@
+package bc
+
+import (
+	"encoding/binary"
+
+	"github.com/golang/protobuf/proto"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/txvm"
+)
+
+var (
+	errTruncated = errors.New("truncated protobuf field")
+	errWireType  = errors.New("invalid protobuf wire type")
+)
+
+// The wire types of the fields of RawTx and RawBlock, by field
+// number, for nextField. -1 is for an unknown field.
+var (
+	rawTxWires    = []int{-1, proto.WireVarint, proto.WireVarint, proto.WireBytes}
+	rawBlockWires = []int{-1, proto.WireBytes, proto.WireBytes, proto.WireBytes}
+)
+
+// DecodeRawBlock is like proto.Unmarshal into a RawBlock, except that
+// the transaction programs refer to bits rather than being copied
+// out of it. The header and arguments are small and are decoded by
+// package proto as usual.
+//
+// It accepts exactly the encodings proto.Unmarshal does, and decodes
+// them to the same values, however odd: fields out of order or
+// repeated, unknown fields and groups, non-minimal varints.
+// Otherwise a block's transactions, and so its ID, could differ
+// between this and other implementations.
+//
+// Unlike Block.FromBytes, it does not run the transactions'
+// programs. That is left to RawBlock.Block, so that a caller can
+// look at the header first.
+func DecodeRawBlock(bits []byte) (*RawBlock, error) {
+	var (
+		rb   RawBlock
+		rest []byte // the encoding of every field but transactions
+	)
+	for len(bits) > 0 {
+		f, n, err := nextField(bits, rawBlockWires)
+		if err != nil {
+			return nil, err
+		}
+		if f.num == 2 {
+			tx, err := decodeRawTx(f.val)
+			if err != nil {
+				return nil, errors.Wrapf(err, "transaction %d", len(rb.Transactions))
+			}
+			rb.Transactions = append(rb.Transactions, tx)
+		} else {
+			rest = append(rest, bits[:n]...)
+		}
+		bits = bits[n:]
+	}
+	txs := rb.Transactions
+	err := proto.Unmarshal(rest, &rb)
+	if err != nil {
+		return nil, err
+	}
+	rb.Transactions = txs
+	return &rb, nil
+}
+
+func decodeRawTx(bits []byte) (*RawTx, error) {
+	tx := new(RawTx)
+	for len(bits) > 0 {
+		f, n, err := nextField(bits, rawTxWires)
+		if err != nil {
+			return nil, err
+		}
+		switch f.num {
+		case 1:
+			tx.Version = int64(f.x)
+		case 2:
+			tx.Runlimit = int64(f.x)
+		case 3:
+			tx.Program = f.val[:len(f.val):len(f.val)]
+		}
+		bits = bits[n:]
+	}
+	return tx, nil
+}
+
+// field is a protobuf field parsed by nextField.
+type field struct {
+	num  uint64
+	wire int
+	x    uint64 // the value of a varint or fixed-size field
+	val  []byte // the value of a length-delimited field
+}
+
+// nextField parses the protobuf field at the start of bits, in a
+// message whose fields have the wire types in wires, indexed by
+// field number, and returns it and the length of its encoding.
+//
+// It follows package proto in every detail that could change the
+// outcome of decoding. In particular, a field with the start-group
+// wire type is decoded as if it had its expected one, if it is a
+// known field, and skipped, to its end-group tag, if not.
+func nextField(bits []byte, wires []int) (f field, n int, err error) {
+	key, n := decodeVarint(bits)
+	if n == 0 {
+		return f, 0, errTruncated
+	}
+	f.num, f.wire = key>>3, int(key&7)
+	if f.num == 0 {
+		return f, 0, errors.WithDetail(errWireType, "field number 0")
+	}
+	if f.wire == proto.WireEndGroup {
+		return f, 0, errors.WithDetailf(errWireType, "end group for field %d outside a group", f.num)
+	}
+	if f.num < uint64(len(wires)) && wires[f.num] >= 0 {
+		if f.wire == proto.WireStartGroup {
+			f.wire = wires[f.num]
+		} else if f.wire != wires[f.num] {
+			return f, 0, errors.WithDetailf(errWireType, "field %d has wire type %d, want %d", f.num, f.wire, wires[f.num])
+		}
+	}
+	m, err := skipValue(bits[n:], f.wire)
+	if err != nil {
+		return f, 0, err
+	}
+	switch f.wire {
+	case proto.WireVarint:
+		f.x, _ = decodeVarint(bits[n:])
+	case proto.WireFixed64:
+		f.x = binary.LittleEndian.Uint64(bits[n:])
+	case proto.WireFixed32:
+		f.x = uint64(binary.LittleEndian.Uint32(bits[n:]))
+	case proto.WireBytes:
+		l, k := decodeVarint(bits[n:])
+		f.val = bits[n+k : n+k+int(l)]
+	}
+	return f, n + m, nil
+}
+
+// skipValue returns the length of the value, of the given wire type,
+// at the start of bits. A group is skipped to its matching end-group
+// tag; as in package proto, the field numbers in the group are not
+// checked.
+func skipValue(bits []byte, wire int) (int, error) {
+	var (
+		n     int
+		depth int // of groups
+	)
+	for {
+		switch wire {
+		case proto.WireVarint:
+			_, m := decodeVarint(bits[n:])
+			if m == 0 {
+				return 0, errTruncated
+			}
+			n += m
+		case proto.WireFixed64, proto.WireFixed32:
+			m := 8
+			if wire == proto.WireFixed32 {
+				m = 4
+			}
+			if len(bits)-n < m {
+				return 0, errTruncated
+			}
+			n += m
+		case proto.WireBytes:
+			l, m := decodeVarint(bits[n:])
+			if m == 0 || l > uint64(len(bits)-n-m) {
+				return 0, errTruncated
+			}
+			n += m + int(l)
+		case proto.WireStartGroup:
+			depth++
+		case proto.WireEndGroup:
+			depth--
+		default:
+			return 0, errors.WithDetailf(errWireType, "wire type %d", wire)
+		}
+		if depth == 0 {
+			return n, nil
+		}
+		key, m := decodeVarint(bits[n:])
+		if m == 0 {
+			return 0, errTruncated
+		}
+		n += m
+		wire = int(key & 7)
+	}
+}
+
+// decodeVarint decodes the varint at the start of bits, returning it
+// and its length, or a length of 0 if it is truncated or longer than
+// 10 bytes. As in package proto, and unlike binary.Uvarint, bits of
+// a 10th byte beyond the 64th bit of the value are ignored.
+func decodeVarint(bits []byte) (x uint64, n int) {
+	for shift := uint(0); shift < 64; shift += 7 {
+		if n >= len(bits) {
+			return 0, 0
+		}
+		b := bits[n]
+		n++
+		x |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return x, n
+		}
+	}
+	return 0, 0
+}
+
+// Copy returns a copy of tx that shares no memory with it.
+//
+// A Tx decoded by NewTx or Block.FromBytes refers to the buffer it
+// was decoded from: its program, and every byte string in its log and
+// in its parsed inputs, outputs, issuances, and retirements. That
+// buffer must not change while tx is in use. A caller that needs to
+// reuse the buffer, or to keep a small part of a large block alive
+// after the rest is discarded, should keep a Copy instead.
+func (tx *Tx) Copy() *Tx {
+	c := *tx
+	c.Program = copyBytes(tx.Program)
+	c.Anchor = copyBytes(tx.Anchor)
+	if tx.Log != nil {
+		c.Log = make([]txvm.Tuple, len(tx.Log))
+		for i, tup := range tx.Log {
+			c.Log[i] = copyData(tup).(txvm.Tuple)
+		}
+	}
+	c.EntryRunlimit = append([]int64(nil), tx.EntryRunlimit...)
+	c.Contracts = append([]Contract(nil), tx.Contracts...)
+	c.Timeranges = append([]Timerange(nil), tx.Timeranges...)
+	c.Nonces = append([]Nonce(nil), tx.Nonces...)
+	if tx.Inputs != nil {
+		c.Inputs = make([]Input, len(tx.Inputs))
+		for i, in := range tx.Inputs {
+			in.Program = copyBytes(in.Program)
+			in.Stack = copyStack(in.Stack)
+			c.Inputs[i] = in
+		}
+	}
+	if tx.Outputs != nil {
+		c.Outputs = make([]Output, len(tx.Outputs))
+		for i, out := range tx.Outputs {
+			out.Program = copyBytes(out.Program)
+			out.Stack = copyStack(out.Stack)
+			c.Outputs[i] = out
+		}
+	}
+	if tx.Issuances != nil {
+		c.Issuances = make([]Issuance, len(tx.Issuances))
+		for i, iss := range tx.Issuances {
+			iss.Anchor = copyBytes(iss.Anchor)
+			c.Issuances[i] = iss
+		}
+	}
+	if tx.Retirements != nil {
+		c.Retirements = make([]Retirement, len(tx.Retirements))
+		for i, ret := range tx.Retirements {
+			ret.Anchor = copyBytes(ret.Anchor)
+			c.Retirements[i] = ret
+		}
+	}
+	return &c
+}
+
+// Copy returns a copy of b whose transactions share no memory with
+// the buffer b was decoded from. See Tx.Copy.
+func (b *Block) Copy() *Block {
+	c := &Block{Arguments: make([]interface{}, len(b.Arguments))}
+	for i, arg := range b.Arguments {
+		if a, ok := arg.([]byte); ok {
+			arg = copyBytes(a)
+		}
+		c.Arguments[i] = arg
+	}
+	if b.UnsignedBlock != nil {
+		c.UnsignedBlock = &UnsignedBlock{
+			BlockHeader:  b.BlockHeader,
+			Transactions: make([]*Tx, len(b.Transactions)),
+		}
+		for i, tx := range b.Transactions {
+			c.Transactions[i] = tx.Copy()
+		}
+	}
+	return c
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
+
+func copyStack(stack []txvm.Data) []txvm.Data {
+	if stack == nil {
+		return nil
+	}
+	c := make([]txvm.Data, len(stack))
+	for i, d := range stack {
+		c[i] = copyData(d)
+	}
+	return c
+}
+
+func copyData(d txvm.Data) txvm.Data {
+	switch d := d.(type) {
+	case txvm.Bytes:
+		return txvm.Bytes(copyBytes(d))
+	case txvm.Tuple:
+		c := make(txvm.Tuple, len(d))
+		for i, item := range d {
+			c[i] = copyData(item)
+		}
+		return c
+	}
+	return d
+}
@@ -0,0 +1,77 @@
+package bc
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// decodeCases are encodings, some of them odd or invalid, on which
+// DecodeRawBlock must agree with proto.Unmarshal.
+var decodeCases = [][]byte{
+	testBlockBytes,
+	nil,
+	blockTx(),
+	blockTx(0x08, 0x03, 0x10, 0x05, 0x1a, 0x02, 0xaa, 0xbb),
+	blockTx(0x1a, 0x00),                   // empty program
+	blockTx(0x08, 0x03, 0x08, 0x04),       // repeated field
+	blockTx(0x1a, 0x01, 0xaa, 0x08, 0x03), // out of order
+	blockTx(0x08, 0x83, 0x80, 0x00),       // non-minimal varint
+	blockTx(0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f), // 10th byte > 1
+	blockTx(0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01),
+	blockTx(0x08),
+	blockTx(0x1a, 0x05, 0xaa),
+	blockTx(0x1b, 0x02, 0xaa, 0xbb), // start group on a known field
+	blockTx(0x0b, 0x07),
+	blockTx(0x0a, 0x01, 0x00), // wrong wire type
+	blockTx(0x0d, 0x00, 0x00, 0x00, 0x00),
+	blockTx(0x19, 0, 0, 0, 0, 0, 0, 0, 0),
+	blockTx(0x23, 0x08, 0x01, 0x24, 0x08, 0x03),       // unknown group
+	blockTx(0x23, 0x2b, 0x32, 0x01, 0x00, 0x2c, 0x24), // nested groups
+	blockTx(0x23, 0x0c),                               // mismatched end group
+	blockTx(0x23, 0x08, 0x01),                         // unterminated group
+	blockTx(0x23, 0x0e),
+	blockTx(0x0c),       // end group outside a group
+	blockTx(0x02, 0x00), // field number 0
+	blockTx(0x0e),
+	blockTx(0x25, 0, 0, 0, 0, 0x29, 0, 0, 0, 0, 0, 0, 0, 0, 0x30, 0x01),
+	{0x13, 0x02, 0x08, 0x03},             // start group for a transaction
+	{0x10, 0x01},                         // transaction as a varint
+	{0x2b, 0x12, 0x00, 0x2c, 0x12, 0x00}, // unknown group in a block
+	{0x0b, 0x00},                         // start group for the header
+	{0x1a, 0x00, 0x1a, 0x02, 0x08, 0x01},
+	{0x12, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01},
+}
+
+func blockTx(tx ...byte) []byte {
+	return append([]byte{0x12, byte(len(tx))}, tx...)
+}
+
+func TestDecodeRawBlockProto(t *testing.T) {
+	for _, c := range decodeCases {
+		checkDecodeRawBlock(t, c)
+	}
+}
+
+func FuzzDecodeRawBlock(f *testing.F) {
+	for _, c := range decodeCases {
+		f.Add(c)
+	}
+	f.Fuzz(checkDecodeRawBlock)
+}
+
+func checkDecodeRawBlock(t *testing.T, bits []byte) {
+	got, err := DecodeRawBlock(bits)
+	want := new(RawBlock)
+	wantErr := proto.Unmarshal(bits, want)
+	if (err == nil) != (wantErr == nil) {
+		t.Fatalf("DecodeRawBlock(%x) error = %v, proto.Unmarshal error = %v", bits, err, wantErr)
+	}
+	if err != nil {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeRawBlock(%x) = %v, proto.Unmarshal gives %v", bits, got, want)
+	}
+}
@@ -4,7 +4,9 @@ blockheaders, and blocks.
 
 The transaction structure defined here is the _output_ of a TxVM
 transaction program. It's created with NewTx, which runs that program
-to populate the data structure.
+to populate the data structure. PreviewTx runs the program only as
+far as its finalize instruction, which yields the transaction ID and
+entries at a fraction of the cost of validation.
 
 This package also defines a 32-byte Hash type as a protocol buffer
 message.
@@ -0,0 +1,42 @@
+package bc
+
+import (
+	"i10r.io/errors"
+	"i10r.io/protocol/txvm"
+)
+
+// NetworkVersion is the first block version bound to a network. The
+// header of such a block carries a NetworkCommitment whose value is
+// the ID of the blockchain's initial block, and each of its
+// transactions has version txvm.NetworkVersion or later, so that its
+// ID commits to that blockchain too. Blocks of earlier versions may
+// not contain such transactions: with no network in the header,
+// their IDs could not be computed.
+const NetworkVersion = 5
+
+// NetworkCommitment is the name of the header commitment naming a
+// block's blockchain.
+const NetworkCommitment = "network"
+
+// ErrNetwork is returned for a block of NetworkVersion or later
+// without a well-formed network commitment.
+var ErrNetwork = errors.New("missing or malformed network commitment")
+
+// Network returns the blockchain ID committed to by bh, which must be
+// of version NetworkVersion or later.
+func (bh *BlockHeader) Network() (Hash, error) {
+	v, ok := bh.Commitment(NetworkCommitment)
+	if !ok {
+		return Hash{}, ErrNetwork
+	}
+	if len(v) != 32 {
+		return Hash{}, errors.WithDetailf(ErrNetwork, "length %d", len(v))
+	}
+	return HashFromBytes(v), nil
+}
+
+// NetworkOption returns the txvm option computing transaction IDs for
+// the blockchain with the given initial block ID.
+func NetworkOption(initialBlockID Hash) txvm.Option {
+	return txvm.Network(initialBlockID.Byte32())
+}
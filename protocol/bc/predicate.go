@@ -0,0 +1,92 @@
+package bc
+
+import (
+	"i10r.io/errors"
+	"i10r.io/math/checked"
+)
+
+// WeightedPredicateVersion is the version of weighted block
+// predicates. Where a version 1 predicate requires the signatures of
+// Quorum of its Pubkeys, a weighted predicate gives each key a
+// weight, and requires signatures by keys whose weights total at
+// least a threshold. Its fields are in OtherFields: the threshold,
+// an int, followed by a tuple {pubkey, weight} for each key. The
+// block ID commits to them like any other predicate fields.
+//
+// Nodes that don't know this version cannot validate the block
+// after one committing to a weighted predicate, so the block
+// signers must not switch to one until every node can.
+const WeightedPredicateVersion = 2
+
+// ErrPredicate is returned for a block predicate of unknown version
+// or with malformed fields.
+var ErrPredicate = errors.New("invalid block predicate")
+
+// Signer is a block-signing key and the weight of its signature.
+type Signer struct {
+	Pubkey []byte
+	Weight int64
+}
+
+// NewWeightedPredicate returns a weighted predicate requiring the
+// signatures of signers whose weights total at least threshold.
+func NewWeightedPredicate(threshold int64, signers []Signer) *Predicate {
+	p := &Predicate{
+		Version:     WeightedPredicateVersion,
+		OtherFields: []*DataItem{{Type: DataType_INT, Int: threshold}},
+	}
+	for _, s := range signers {
+		p.OtherFields = append(p.OtherFields, &DataItem{
+			Type: DataType_TUPLE,
+			Tuple: []*DataItem{
+				{Type: DataType_BYTES, Bytes: s.Pubkey},
+				{Type: DataType_INT, Int: s.Weight},
+			},
+		})
+	}
+	return p
+}
+
+// Signers returns the keys of p, which must be a version 1 or
+// weighted predicate, with their weights, and the total weight of
+// signatures p requires. The keys of a version 1 predicate each have
+// weight 1, and the total is its quorum.
+func (p *Predicate) Signers() (signers []Signer, threshold int64, err error) {
+	switch p.Version {
+	case 1:
+		if p.Quorum < 0 || int(p.Quorum) > len(p.Pubkeys) {
+			return nil, 0, errors.WithDetailf(ErrPredicate, "predicate quorum %d, pubkeys %d", p.Quorum, len(p.Pubkeys))
+		}
+		for _, pk := range p.Pubkeys {
+			signers = append(signers, Signer{Pubkey: pk, Weight: 1})
+		}
+		return signers, int64(p.Quorum), nil
+
+	case WeightedPredicateVersion:
+		if len(p.OtherFields) == 0 || p.OtherFields[0].Type != DataType_INT {
+			return nil, 0, errors.WithDetail(ErrPredicate, "weighted predicate has no threshold")
+		}
+		threshold = p.OtherFields[0].Int
+		var total int64
+		for i, item := range p.OtherFields[1:] {
+			if item.Type != DataType_TUPLE || len(item.Tuple) != 2 || item.Tuple[0].Type != DataType_BYTES || item.Tuple[1].Type != DataType_INT {
+				return nil, 0, errors.WithDetailf(ErrPredicate, "weighted predicate signer %d is not a {pubkey, weight} pair", i)
+			}
+			s := Signer{Pubkey: item.Tuple[0].Bytes, Weight: item.Tuple[1].Int}
+			if s.Weight < 1 {
+				return nil, 0, errors.WithDetailf(ErrPredicate, "weighted predicate signer %d has weight %d", i, s.Weight)
+			}
+			var ok bool
+			total, ok = checked.AddInt64(total, s.Weight)
+			if !ok {
+				return nil, 0, errors.WithDetail(ErrPredicate, "weighted predicate total weight overflows")
+			}
+			signers = append(signers, s)
+		}
+		if threshold < 0 || threshold > total {
+			return nil, 0, errors.WithDetailf(ErrPredicate, "predicate threshold %d, total weight %d", threshold, total)
+		}
+		return signers, threshold, nil
+	}
+	return nil, 0, errors.WithDetailf(ErrPredicate, "predicate version %d", p.Version)
+}
@@ -0,0 +1,87 @@
+package bc
+
+import (
+	"testing"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/testutil"
+)
+
+func TestWeightedPredicate(t *testing.T) {
+	var (
+		signers []Signer
+		prvs    []ed25519.PrivateKey
+	)
+	for _, w := range []int64{3, 1, 1} {
+		pub, prv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signers = append(signers, Signer{Pubkey: pub, Weight: w})
+		prvs = append(prvs, prv)
+	}
+	pred := NewWeightedPredicate(3, signers)
+	got, threshold, err := pred.Signers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if threshold != 3 || !testutil.DeepEqual(got, signers) {
+		t.Errorf("Signers() = %v, %d, want %v, 3", got, threshold, signers)
+	}
+
+	prev := &BlockHeader{Height: 1, NextPredicate: pred}
+	h := &BlockHeader{Height: 2, NextPredicate: pred}
+	if h.Hash() == (&BlockHeader{Height: 2, NextPredicate: NewWeightedPredicate(2, signers)}).Hash() {
+		t.Error("block ID does not commit to the threshold")
+	}
+	b := &UnsignedBlock{BlockHeader: h}
+
+	cases := []struct {
+		sign    []bool
+		wantN   int
+		wantErr error
+	}{
+		{[]bool{true, true, true}, 1, nil}, // the heavy key alone meets the threshold
+		{[]bool{false, true, true}, 0, ErrTooFewSignatures},
+		{[]bool{false, true, false}, 0, ErrTooFewSignatures},
+	}
+	for i, c := range cases {
+		sb, err := SignBlock(b, prev, func(idx int) (interface{}, error) {
+			if !c.sign[idx] {
+				return nil, nil
+			}
+			return ed25519.Sign(prvs[idx], h.Hash().Bytes()), nil
+		})
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var n int
+		for _, arg := range sb.Arguments {
+			if arg != nil {
+				n++
+			}
+		}
+		if n != c.wantN {
+			t.Errorf("case %d: %d signatures, want %d", i, n, c.wantN)
+		}
+	}
+
+	for i, p := range []*Predicate{
+		{Version: 3},
+		{Version: WeightedPredicateVersion},
+		NewWeightedPredicate(6, signers),
+		NewWeightedPredicate(-1, signers),
+		NewWeightedPredicate(1, []Signer{{Pubkey: signers[0].Pubkey, Weight: 0}}),
+		NewWeightedPredicate(1, []Signer{{Weight: 1 << 62}, {Weight: 1 << 62}}),
+		{Version: 1, Quorum: 2, Pubkeys: [][]byte{signers[0].Pubkey}},
+	} {
+		if _, _, err := p.Signers(); errors.Root(err) != ErrPredicate {
+			t.Errorf("case %d: got error %v, want %v", i, err, ErrPredicate)
+		}
+	}
+}
@@ -18,6 +18,16 @@ type Tx struct {
 	ID        Hash
 	Log       []txvm.Tuple
 
+	// RunlimitUsed is the runlimit the program consumed. The rest of
+	// Runlimit, which the transaction pays for but does not use, is
+	// its refund.
+	RunlimitUsed int64
+
+	// EntryRunlimit is the runlimit attributed to each entry in Log,
+	// such as the cost of an input's contract, signature checks
+	// included. See txvm.Validate.
+	EntryRunlimit []int64
+
 	// Used in protocol validation and state updates
 	Contracts  []Contract
 	Timeranges []Timerange
@@ -118,6 +128,10 @@ func NewCommitmentsTx(tx *Tx) *CommitmentsTx {
 
 // NewTx runs the given txvm program through an instance of the txvm
 // virtual machine, populating a new Tx object with its side effects.
+//
+// The Tx refers to prog, and byte strings in its log and parsed
+// entries may be slices of it, so prog must not be modified while
+// the Tx is in use. See Tx.Copy.
 func NewTx(prog []byte, version, runlimit int64, option ...txvm.Option) (*Tx, error) {
 	tx := &Tx{
 		RawTx: RawTx{
@@ -133,10 +147,36 @@ func NewTx(prog []byte, version, runlimit int64, option ...txvm.Option) (*Tx, er
 		if vm.Finalized {
 			tx.ID = NewHash(vm.TxID)
 		}
+		tx.RunlimitUsed = runlimit - vm.Runlimit()
+		tx.EntryRunlimit = vm.EntryRunlimit
 	}
 	return tx, errors.Wrap(err)
 }
 
+// Refund returns the part of tx's runlimit that its program did not
+// use.
+func (tx *Tx) Refund() int64 {
+	return tx.Runlimit - tx.RunlimitUsed
+}
+
+// PreviewTx is like NewTx, but runs prog only as far as its finalize
+// instruction. It computes the transaction's ID, log and entries
+// without the signature checks and other predicates that follow
+// finalize, which are most of the cost of validation, and without
+// checking that prog leaves no residue. It returns an error with root
+// txvm.ErrUnfinalized if prog does not finalize.
+//
+// PreviewTx does not validate the transaction: one that it accepts
+// may still fail NewTx. It is for nodes that relay transactions and
+// leave validation to the block that includes them.
+func PreviewTx(prog []byte, version, runlimit int64, option ...txvm.Option) (*Tx, error) {
+	tx, err := NewTx(prog, version, runlimit, append(option, txvm.StopAfterFinalize)...)
+	if err == nil && !tx.Finalized {
+		err = errors.Wrap(txvm.ErrUnfinalized)
+	}
+	return tx, err
+}
+
 func (tx *Tx) stackHook(vm *txvm.VM) {
 	switch vm.OpCode() {
 	case op.Output:
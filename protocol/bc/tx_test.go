@@ -3,11 +3,14 @@ package bc
 import (
 	"bytes"
 	"encoding/hex"
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
 
+	"i10r.io/errors"
 	"i10r.io/protocol/txvm"
 	"i10r.io/protocol/txvm/asm"
 	"i10r.io/protocol/txvm/op"
@@ -176,6 +179,8 @@ func TestNewTx(t *testing.T) {
 			}
 
 			c.want.Log = tx.Log
+			c.want.RunlimitUsed = tx.RunlimitUsed // see TestEntryRunlimit
+			c.want.EntryRunlimit = tx.EntryRunlimit
 
 			if !reflect.DeepEqual(tx, c.want) {
 				t.Errorf("NewTx\n\tgot:  %s\n\twant: %s\n", spew.Sdump(tx), spew.Sdump(c.want))
@@ -184,6 +189,50 @@ func TestNewTx(t *testing.T) {
 	}
 }
 
+func TestPreviewTx(t *testing.T) {
+	prog, err := asm.Assemble(txvmtest.SimplePayment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := NewTx(prog, 3, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := PreviewTx(prog, 3, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != want.ID || !bytes.Equal(got.Anchor, want.Anchor) || !reflect.DeepEqual(got.Contracts, want.Contracts) {
+		t.Errorf("got id %x, anchor %x, contracts %v; want %x, %x, %v", got.ID.Bytes(), got.Anchor, got.Contracts, want.ID.Bytes(), want.Anchor, want.Contracts)
+	}
+
+	// A bad signature, checked after finalize, is not noticed.
+	src := txvmtest.SimplePayment
+	src = src[:strings.LastIndex(src, "x'")] + fmt.Sprintf("x'%x' put call", make([]byte, 64))
+	prog, err = asm.Assemble(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTx(prog, 3, 100000); err == nil {
+		t.Error("NewTx accepted a bad signature")
+	}
+	got, err = PreviewTx(prog, 3, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != want.ID {
+		t.Errorf("bad signature: got id %x, want %x", got.ID.Bytes(), want.ID.Bytes())
+	}
+
+	prog, err = asm.Assemble("1 drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PreviewTx(prog, 3, 100000); errors.Root(err) != txvm.ErrUnfinalized {
+		t.Errorf("unfinalized: got error %v, want %v", err, txvm.ErrUnfinalized)
+	}
+}
+
 func TestWitnessHash(t *testing.T) {
 	raw, err := asm.Assemble(`"blockchainidblockchainidblockcha" 1000 nonce finalize`)
 	if err != nil {
@@ -205,3 +254,37 @@ func TestWitnessHash(t *testing.T) {
 		t.Errorf("Tx.WriteWitnessCommitmentTo yields %x, want %x", b.Bytes(), want)
 	}
 }
+
+func TestEntryRunlimit(t *testing.T) {
+	prog, err := asm.Assemble(txvmtest.SimplePayment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := NewTx(prog, 3, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.RunlimitUsed <= 0 || tx.Refund() != tx.Runlimit-tx.RunlimitUsed {
+		t.Errorf("got runlimit used %d, refund %d", tx.RunlimitUsed, tx.Refund())
+	}
+	if len(tx.EntryRunlimit) != len(tx.Log) {
+		t.Fatalf("got %d entry runlimits for %d log entries", len(tx.EntryRunlimit), len(tx.Log))
+	}
+	var sum int64
+	for _, n := range tx.EntryRunlimit {
+		sum += n
+	}
+	if sum > tx.RunlimitUsed {
+		t.Errorf("entry runlimits sum to %d, more than the %d used", sum, tx.RunlimitUsed)
+	}
+
+	// The input's cost includes its signature check, after finalize.
+	preview, err := PreviewTx(prog, 3, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := tx.Inputs[0].LogPos
+	if got, pre := tx.EntryRunlimit[in], preview.EntryRunlimit[in]; got <= pre {
+		t.Errorf("input runlimit %d, want more than %d before finalize", got, pre)
+	}
+}
@@ -0,0 +1,188 @@
+// Package beacon derives shared pseudo-random values for contracts
+// that need them, such as lotteries and committee assignments, so
+// that each need not invent its own scheme.
+//
+// There are two sources.
+//
+// FromHeaders hashes a window of consecutive block headers. It
+// needs no extra parties, but block signers influence the result:
+// a signer can try different transaction sets and timestamps before
+// signing, or withhold a block it does not like, and the signer of
+// the last block in the window has the most influence. It is
+// suitable only where the value at stake is small compared with the
+// value of producing a block, and only with a window spanning
+// blocks from independent signers.
+//
+// A round of a beacon committee is better. Each member signs the
+// round's label with a BLS key; the aggregate of all members'
+// signatures is unique for the label, so no member, nor anyone
+// else, can choose among several valid outputs. A member can still
+// withhold its signature, stalling the round, and a committee that
+// colludes can learn the value early. Members must prove possession
+// of their keys (see bls.PrivateKey.ProvePossession) before the
+// committee is formed. Contracts check a round with VerifyRoundProg.
+//
+// Either source yields a Value, and Draw (or DrawProg, in a
+// contract) maps a Value to an index.
+package beacon
+
+import (
+	"math/big"
+
+	"i10r.io/crypto/bls"
+	"i10r.io/crypto/sha3"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+var (
+	// ErrWindow is returned by FromHeaders for headers that are not
+	// consecutive.
+	ErrWindow = errors.New("headers are not a consecutive window")
+
+	// ErrRound is returned for an invalid committee round signature.
+	ErrRound = errors.New("invalid beacon round signature")
+)
+
+// Value is a beacon output.
+type Value [32]byte
+
+// FromHeaders returns the beacon value of a window of consecutive
+// block headers, in ascending height order. See the package
+// documentation for its limitations.
+func FromHeaders(headers []*bc.BlockHeader) (Value, error) {
+	if len(headers) == 0 {
+		return Value{}, errors.WithDetail(ErrWindow, "no headers")
+	}
+	ids := make(txvm.Tuple, 0, len(headers))
+	for i, h := range headers {
+		id := h.Hash()
+		if i > 0 {
+			prev := headers[i-1]
+			if h.Height != prev.Height+1 || h.PreviousBlockId == nil || *h.PreviousBlockId != prev.Hash() {
+				return Value{}, errors.WithDetailf(ErrWindow, "block %d does not follow block %d", h.Height, prev.Height)
+			}
+		}
+		ids = append(ids, txvm.Bytes(id.Bytes()))
+	}
+	last := headers[len(headers)-1].Height
+	return Value(txvm.VMHash("BeaconHeaders", txvm.Encode(txvm.Tuple{txvm.Int(int64(last)), ids}))), nil
+}
+
+// RoundMessage returns the message committee members sign for the
+// round with the given label.
+func RoundMessage(label []byte) []byte {
+	return append([]byte("BeaconRound"), label...)
+}
+
+// Sign returns a committee member's signature for the round with
+// the given label. The round's signature is the aggregate, with
+// bls.AggregateSignatures, of every member's.
+func Sign(prv *bls.PrivateKey, label []byte) *bls.Signature {
+	return prv.Sign(RoundMessage(label))
+}
+
+// VerifyRound checks that sig is the aggregate signature of all of
+// pubkeys for the round with the given label and returns the
+// round's value.
+func VerifyRound(pubkeys []*bls.PublicKey, label []byte, sig *bls.Signature) (Value, error) {
+	if len(pubkeys) == 0 || !bls.FastAggregateVerify(pubkeys, RoundMessage(label), sig) {
+		return Value{}, errors.WithDetailf(ErrRound, "round %q", label)
+	}
+	return roundValue(sig.Bytes()), nil
+}
+
+func roundValue(sig []byte) Value {
+	return sha3.Sum256(append([]byte("BeaconVRF"), sig...))
+}
+
+// Draw maps v to an index in [0, n). The bias from reducing a
+// 256-bit value mod n is negligible for any n that fits in an
+// int64. Draw panics if n is not positive.
+func (v Value) Draw(n int64) int64 {
+	if n <= 0 {
+		panic("beacon: Draw with non-positive n")
+	}
+	r := new(big.Int).SetBytes(v[:])
+	return r.Mod(r, big.NewInt(n)).Int64()
+}
+
+// verifyRoundSrc expects the contract stack
+// [... {p1,...,p_n} label sig] and leaves [... value], failing
+// unless sig is the aggregate of all the p_i's signatures of the
+// round with the given label.
+const verifyRoundSrc = `
+	                       # [{p} label sig]
+	2 roll                 # [label sig {p}]
+	'BeaconRound' 3 roll   # [sig {p} 'BeaconRound' label]
+	cat swap               # [sig msg {p}]
+	2 peek                 # [sig msg {p} sig]
+	checkblsagg verify     # [sig]
+	'BeaconVRF' swap cat   # ['BeaconVRF'||sig]
+	sha3                   # [value]
+`
+
+// VerifyRoundProg is txvm bytecode that a contract can run to
+// consume a committee round. It expects the contract stack
+// [... {p1,...,p_n} label sig], with the committee's BLS public
+// keys, and leaves the round's value, [... value], failing unless
+// sig is valid. Its checkblsagg requires transaction version
+// txvm.ExtOpsVersion or later.
+var VerifyRoundProg = mustAssemble(verifyRoundSrc)
+
+// drawSrc expects the contract stack [... value nbig], with n in
+// big-endian form, and leaves [... i], the int form of value mod n.
+// It finds i by counting, so it costs runlimit in proportion to i.
+const drawSrc = `
+	                             # [value nbig]
+	bigmod                       # [r]
+	0 ''                         # [r i ibig], i = 0..r
+	$loop
+	    dup 3 peek eq            # [r i ibig (ibig==r)]
+	    jumpif:$done             # [r i ibig]
+	    x'01' bigadd             # [r i ibig+1]
+	    swap 1 add swap          # [r i+1 ibig+1]
+	    jump:$loop
+	$done                        # [r i r]
+	drop swap drop               # [i]
+`
+
+var drawProg = mustAssemble(drawSrc)
+
+// DrawProg returns txvm bytecode that a contract can run to do
+// what Draw does. It expects [... value] on the contract stack and
+// leaves [... i] with i in [0, n). Because it counts up to i, it
+// costs runlimit in proportion to n; it suits choices among at most
+// a few thousand. Its bigint operations require transaction version
+// txvm.ExtOpsVersion or later. DrawProg panics if n is not
+// positive.
+func DrawProg(n int64) []byte {
+	if n <= 0 {
+		panic("beacon: DrawProg with non-positive n")
+	}
+	var b txvmutil.Builder
+	b.PushdataBytes(big.NewInt(n).Bytes())
+	b.Concat(drawProg)
+	return b.Build()
+}
+
+// PushCommittee writes bytecode to b that pushes the tuple of
+// pubkeys that VerifyRoundProg expects.
+func PushCommittee(b *txvmutil.Builder, pubkeys []*bls.PublicKey) {
+	b.Tuple(func(tup *txvmutil.TupleBuilder) {
+		for _, pk := range pubkeys {
+			tup.PushdataBytes(pk.Bytes())
+		}
+	})
+}
+
+func mustAssemble(src string) []byte {
+	prog, err := asm.Assemble(src)
+	if err != nil {
+		panic(err)
+	}
+	return prog
+}
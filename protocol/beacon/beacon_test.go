@@ -0,0 +1,103 @@
+package beacon
+
+import (
+	"testing"
+
+	"i10r.io/crypto/bls"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+func TestFromHeaders(t *testing.T) {
+	c := prottest.NewChain(t)
+	var headers []*bc.BlockHeader
+	for i := 0; i < 4; i++ {
+		headers = append(headers, prottest.MakeBlock(t, c, nil).BlockHeader)
+	}
+	v1, err := FromHeaders(headers[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := FromHeaders(headers[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1 == v2 {
+		t.Error("different windows have the same value")
+	}
+	_, err = FromHeaders([]*bc.BlockHeader{headers[0], headers[2]})
+	if errors.Root(err) != ErrWindow {
+		t.Errorf("window with a gap: got %v, want %v", err, ErrWindow)
+	}
+}
+
+func TestRound(t *testing.T) {
+	var (
+		pubs []*bls.PublicKey
+		sigs []*bls.Signature
+	)
+	label := []byte("lottery 7 draw")
+	for i := 0; i < 3; i++ {
+		prv, err := bls.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, prv.Public())
+		sigs = append(sigs, Sign(prv, label))
+	}
+	sig := bls.AggregateSignatures(sigs...)
+	v, err := VerifyRound(pubs, label, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyRound(pubs, label, bls.AggregateSignatures(sigs[:2]...)); errors.Root(err) != ErrRound {
+		t.Errorf("2 of 3 members: got %v, want %v", err, ErrRound)
+	}
+	if _, err := VerifyRound(pubs, []byte("lottery 8 draw"), sig); errors.Root(err) != ErrRound {
+		t.Errorf("other round: got %v, want %v", err, ErrRound)
+	}
+
+	const n = 5
+	draw := func(sig *bls.Signature) (*txvm.VM, error) {
+		var b txvmutil.Builder
+		PushCommittee(&b, pubs)
+		b.PushdataBytes(label)
+		b.PushdataBytes(sig.Bytes())
+		b.Concat(VerifyRoundProg)
+		b.Concat(DrawProg(n))
+		fin, err := asm.Assemble("log 'id' 10 nonce finalize")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Concat(fin)
+		return txvm.Validate(b.Build(), txvm.ExtOpsVersion, 10000000)
+	}
+	vm, err := draw(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := vm.Log[0][2].(txvm.Int)
+	if want := v.Draw(n); int64(got) != want {
+		t.Errorf("contract drew %d, Draw gives %d", got, want)
+	}
+	if _, err := draw(sigs[0]); err == nil {
+		t.Error("contract accepted one member's signature")
+	}
+}
+
+func TestDraw(t *testing.T) {
+	counts := make([]int, 4)
+	for i := 0; i < 400; i++ {
+		v := Value(txvm.VMHash("test", []byte{byte(i), byte(i >> 8)}))
+		counts[v.Draw(4)]++
+	}
+	for i, c := range counts {
+		if c < 60 {
+			t.Errorf("index %d drawn %d times of 400", i, c)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package protocol_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/bc/bctest"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txgen"
+	"i10r.io/protocol/validation"
+	"i10r.io/testutil"
+)
+
+// BenchmarkBlock measures the stages of accepting a block of 100
+// random transactions: decoding it, which runs each transaction's
+// program, validating it against the previous block, and applying it
+// to the state.
+func BenchmarkBlock(b *testing.B) {
+	ctx := context.Background()
+	c := prottest.NewChain(b)
+	prottest.Initial(b, c)
+	g := txgen.New(1)
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		txs, err := g.Txs(c.State(), now, 50)
+		if err != nil {
+			testutil.FatalErr(b, err)
+		}
+		prottest.MakeBlock(b, c, txs)
+	}
+	prev := c.State()
+	txs, err := g.Txs(prev, now, 100)
+	if err != nil {
+		testutil.FatalErr(b, err)
+	}
+	ub, _, err := c.GenerateBlock(ctx, prev.TimestampMS()+1, bctest.WithCommitments(txs))
+	if err != nil {
+		testutil.FatalErr(b, err)
+	}
+	blk, err := bc.SignBlock(ub, prev.Header, nil)
+	if err != nil {
+		testutil.FatalErr(b, err)
+	}
+	bits, err := blk.Bytes()
+	if err != nil {
+		testutil.FatalErr(b, err)
+	}
+
+	b.Run("decode", func(b *testing.B) {
+		b.SetBytes(int64(len(bits)))
+		for i := 0; i < b.N; i++ {
+			var got bc.Block
+			err := got.FromBytes(bits)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("validate", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			err := validation.Block(ub, prev.Header)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("apply", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			st := state.Copy(prev)
+			err := st.ApplyBlock(ub)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -10,8 +10,10 @@ import (
 	"i10r.io/errors"
 	"i10r.io/log"
 	"i10r.io/protocol/bc"
+	"i10r.io/protocol/checkpoint"
 	"i10r.io/protocol/patricia"
 	"i10r.io/protocol/state"
+	"i10r.io/protocol/txvm"
 )
 
 var (
@@ -50,11 +52,77 @@ func (c *Chain) GenerateBlock(ctx context.Context, timestampMS uint64, txs []*bc
 	return c.bb.Build()
 }
 
+// BlockBuilder returns the builder used by GenerateBlock, so that its
+// settings may be adjusted. It must not be changed while a block is
+// being generated.
+func (c *Chain) BlockBuilder() *BlockBuilder {
+	return c.bb
+}
+
+// SetFinality makes c refuse blocks that contradict the checkpoints
+// in f. It must be called before any block is committed to c
+// concurrently.
+func (c *Chain) SetFinality(f *checkpoint.Finality) {
+	c.finality = f
+}
+
+// Finality returns the Finality set with SetFinality, or nil.
+func (c *Chain) Finality() *checkpoint.Finality {
+	return c.finality
+}
+
+// SetPlugins makes the plugin operations in p available to the
+// programs of c's transactions (see txvm.WithPlugins). If c's store
+// has a SetPlugins method, as a filestore.Store does, it is passed p
+// too, so that the blocks it returns are decoded with them. It must
+// be called before c is used concurrently.
+func (c *Chain) SetPlugins(p *txvm.Plugins) {
+	c.plugins = p
+	if s, ok := c.store.(interface{ SetPlugins(*txvm.Plugins) }); ok {
+		s.SetPlugins(p)
+	}
+}
+
+// Plugins returns the Plugins set with SetPlugins, or nil.
+func (c *Chain) Plugins() *txvm.Plugins {
+	return c.plugins
+}
+
+// AddCheckpoint adds cp to the Finality set with SetFinality. If c
+// already has a block at the checkpoint's height, it must be the
+// checkpointed block; otherwise c has committed to a fork that the
+// checkpoint signers have rejected, and AddCheckpoint returns
+// checkpoint.ErrFinalized.
+func (c *Chain) AddCheckpoint(ctx context.Context, cp *checkpoint.Checkpoint) error {
+	if c.finality == nil {
+		return errors.New("chain has no finality layer")
+	}
+	err := cp.Verify(&c.finality.Params, c.InitialBlockHash)
+	if err != nil {
+		return err
+	}
+	if cp.Height() <= c.Height() {
+		b, err := c.GetBlock(ctx, cp.Height())
+		if err != nil {
+			return errors.Wrapf(err, "getting block %d for checkpoint", cp.Height())
+		}
+		if b.Hash() != cp.BlockID() {
+			return errors.WithDetailf(checkpoint.ErrFinalized, "have block %x at height %d, checkpoint has %x", b.Hash().Bytes(), cp.Height(), cp.BlockID().Bytes())
+		}
+	}
+	return c.finality.Add(cp)
+}
+
 // CommitAppliedBlock takes a block, commits it to persistent storage and
 // sets c's state. Unlike CommitBlock, it accepts an already applied
-// snapshot. CommitAppliedBlock is idempotent.
+// snapshot, but it checks the block against c as CommitBlock does.
+// CommitAppliedBlock is idempotent.
 func (c *Chain) CommitAppliedBlock(ctx context.Context, block *bc.Block, snapshot *state.Snapshot) error {
-	err := c.store.SaveBlock(ctx, block)
+	err := c.checkBlock(ctx, block)
+	if err != nil {
+		return err
+	}
+	err = c.store.SaveBlock(ctx, block)
 	if err != nil {
 		return errors.Wrap(err, "storing block")
 	}
@@ -67,14 +135,20 @@ func (c *Chain) CommitAppliedBlock(ctx context.Context, block *bc.Block, snapsho
 	if block.Height <= curState.Height() {
 		return nil
 	}
+	c.countSlots(ctx, block.BlockHeader, curState.Header)
 	return c.finalizeCommitState(ctx, snapshot)
 }
 
 // CommitBlock takes a block, commits it to persistent storage and applies
 // it to c. CommitBlock is idempotent. A duplicate call with a previously
-// committed block will succeed.
+// committed block will succeed. A new block must satisfy c's
+// TimePolicy; see SetTimePolicy.
 func (c *Chain) CommitBlock(ctx context.Context, block *bc.Block) error {
-	err := c.store.SaveBlock(ctx, block)
+	err := c.checkBlock(ctx, block)
+	if err != nil {
+		return err
+	}
+	err = c.store.SaveBlock(ctx, block)
 	if err != nil {
 		return errors.Wrap(err, "storing block")
 	}
@@ -99,10 +173,40 @@ func (c *Chain) CommitBlock(ctx context.Context, block *bc.Block) error {
 	if block.NoncesRoot.Byte32() != snapshot.NonceTree.RootHash() {
 		return ErrBadNoncesRoot
 	}
+	c.countSlots(ctx, block.BlockHeader, curSnapshot.Header)
 	return c.finalizeCommitState(ctx, snapshot)
 }
 
+// checkBlock checks block against c: its network, its timestamp
+// against c's clock, and c's checkpoints. The block's own validity,
+// including its slot's leader, is checked by package validation.
+func (c *Chain) checkBlock(ctx context.Context, block *bc.Block) error {
+	if block.Version >= bc.NetworkVersion {
+		network, err := block.Network()
+		if err != nil {
+			return err
+		}
+		if network != c.InitialBlockHash {
+			return errors.WithDetailf(bc.ErrNetwork, "block for network %x", network.Bytes())
+		}
+	}
+	if block.Height > c.Height() {
+		err := c.checkTime(ctx, block.BlockHeader)
+		if err != nil {
+			return err
+		}
+	}
+	if c.finality != nil {
+		return c.finality.CheckBlock(block.BlockHeader)
+	}
+	return nil
+}
+
 func (c *Chain) finalizeCommitState(ctx context.Context, snapshot *state.Snapshot) error {
+	// Other goroutines read c's state without holding any lock
+	// while the next block is applied to a copy of it.
+	snapshot.Freeze()
+
 	// Save the blockchain state tree snapshot to persistent storage
 	// if we haven't done it recently.
 	lastQueuedHeight := atomic.LoadUint64(&c.lastQueuedSnapshotHeight)
@@ -11,11 +11,18 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
 	"i10r.io/protocol/bc"
 	"i10r.io/protocol/bc/bctest"
 	"i10r.io/protocol/patricia"
 	"i10r.io/protocol/prottest/memstore"
+	"i10r.io/protocol/rent"
 	"i10r.io/protocol/state"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/validation"
 	"i10r.io/testutil"
 )
 
@@ -161,6 +168,158 @@ func TestGenerateBlock(t *testing.T) {
 	}
 }
 
+func TestGenerateBlockCommitments(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(233400000, 0)
+	c, _ := newTestChain(t, now)
+	bb := c.BlockBuilder()
+	bb.Commitments = map[string]CommitmentFunc{
+		"txcount": func(b *bc.UnsignedBlock, _ *state.Snapshot) ([]byte, error) {
+			return []byte{byte(len(b.Transactions))}, nil
+		},
+	}
+
+	got, _, err := c.GenerateBlock(ctx, bc.Millis(now)+1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.ExtraFields) != 0 {
+		t.Errorf("version 3 block has %d extra fields", len(got.ExtraFields))
+	}
+
+	bb.Version = bc.CommitmentsVersion
+	got, snapshot, err := c.GenerateBlock(ctx, bc.Millis(now)+1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, ok := got.Commitment("txcount")
+	if !ok || !reflect.DeepEqual(v, []byte{0}) {
+		t.Errorf("txcount commitment = %x, %v, want 00, true", v, ok)
+	}
+	if snapshot.Header.Hash() != got.Hash() {
+		t.Error("snapshot header does not include commitments")
+	}
+
+	bb.Commitments[rent.Commitment] = func(*bc.UnsignedBlock, *state.Snapshot) ([]byte, error) {
+		return []byte{0}, nil
+	}
+	_, _, err = c.GenerateBlock(ctx, bc.Millis(now)+1, nil)
+	if errors.Root(err) != bc.ErrCommitments {
+		t.Errorf("built-in commitment name: got error %v, want %v", err, bc.ErrCommitments)
+	}
+}
+
+func TestGenerateBlockNextPredicate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(233400000, 0)
+	c, _ := newTestChain(t, now)
+	bb := c.BlockBuilder()
+
+	got, _, err := c.GenerateBlock(ctx, bc.Millis(now)+1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.NextPredicate, c.State().Header.NextPredicate) {
+		t.Errorf("next predicate %v, want the previous block's", got.NextPredicate)
+	}
+
+	pred := bc.NewWeightedPredicate(2, []bc.Signer{{Pubkey: make([]byte, ed25519.PublicKeySize), Weight: 2}})
+	bb.NextPredicate = pred
+	got, _, err = c.GenerateBlock(ctx, bc.Millis(now)+1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.NextPredicate != pred {
+		t.Errorf("next predicate %v, want %v", got.NextPredicate, pred)
+	}
+
+	bb.NextPredicate = bc.NewWeightedPredicate(3, nil)
+	if _, _, err := c.GenerateBlock(ctx, bc.Millis(now)+1, nil); errors.Root(err) != bc.ErrPredicate {
+		t.Errorf("invalid next predicate: got error %v, want %v", err, bc.ErrPredicate)
+	}
+}
+
+func TestNetworkVersion(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c, _ := newTestChain(t, now)
+	other, _ := newTestChain(t, now.Add(-time.Hour))
+
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubs := []ed25519.PublicKey{pub}
+	issue := func(network bool, nonce byte) *bc.Tx {
+		tpl := txbuilder.NewTemplate(now.Add(time.Hour), nil)
+		if network {
+			tpl.SetNetwork(c.InitialBlockHash)
+		}
+		tpl.AddIssuance(2, c.InitialBlockHash.Bytes(), nil, 1, [][]byte{pub}, nil, pubs, 1, nil, []byte{nonce})
+		assetID := bc.NewHash(standard.AssetID(2, 1, pubs, nil))
+		tpl.AddOutput(1, pubs, 1, assetID, nil, nil)
+		err := tpl.Sign(ctx, func(_ context.Context, msg, _ []byte, _ [][]byte) ([]byte, error) {
+			return ed25519.Sign(prv, msg), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx, err := tpl.Tx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	tx3, tx5 := issue(false, 3), issue(true, 5)
+	if tx5.Version != txvm.NetworkVersion {
+		t.Fatalf("network-bound tx has version %d", tx5.Version)
+	}
+
+	c.BlockBuilder().Version = bc.NetworkVersion
+	prev := c.State().Header
+	ub, _, err := c.GenerateBlock(ctx, bc.Millis(now)+1, []*bc.CommitmentsTx{bc.NewCommitmentsTx(tx3), bc.NewCommitmentsTx(tx5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ub.Transactions) != 1 || ub.Transactions[0] != tx5 {
+		t.Fatalf("block has %d txs, want only the network-bound one", len(ub.Transactions))
+	}
+	if network, err := ub.Network(); err != nil || network != c.InitialBlockHash {
+		t.Errorf("network commitment %x, %v, want %x", network.Bytes(), err, c.InitialBlockHash.Bytes())
+	}
+
+	sb, err := bc.SignBlock(ub, prev, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bits, err := sb.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := new(bc.Block)
+	err = got.FromBytes(bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Transactions[0].ID != tx5.ID {
+		t.Error("decoded transaction ID differs")
+	}
+	err = validation.Block(got.UnsignedBlock, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.CommitBlock(ctx, got); errors.Root(err) != bc.ErrNetwork {
+		t.Errorf("committing to another network: got %v, want %v", err, bc.ErrNetwork)
+	}
+	if err := other.CommitAppliedBlock(ctx, got, nil); errors.Root(err) != bc.ErrNetwork {
+		t.Errorf("committing applied block to another network: got %v, want %v", err, bc.ErrNetwork)
+	}
+	err = c.CommitBlock(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestCommitBlockIdempotence(t *testing.T) {
 	const numOfBlocks = 10
 	const concurrency = 5
@@ -0,0 +1,329 @@
+// Package blockfilter implements compact block filters, after
+// Bitcoin's BIP 158, for light clients.
+//
+// A block's filter is a Golomb-coded set of the items its
+// transactions touch (see Items): a probabilistic summary in which a
+// light client can look for the public keys, asset IDs, and contract
+// IDs it cares about, fetching the full block only on a match. False
+// positives happen at a rate of about one in M per item sought; false
+// negatives do not happen.
+//
+// A block of version bc.CommitmentsVersion or later may commit to its
+// filter in its header's commitments area. Validators then check that
+// the commitment matches the block's transactions, so that a client
+// with a valid header can trust the filter a node gives it (see
+// Check).
+package blockfilter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"sort"
+
+	"i10r.io/crypto/sha3pool"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/validation"
+)
+
+// Commitment is the name of the header commitment holding the hash of
+// a block's filter.
+const Commitment = "filter"
+
+// The parameters of the Golomb-coded set, as in BIP 158: each item
+// hashes to a number in [0, N*M), for a set of N items, and the
+// differences between the sorted numbers are Golomb-Rice coded with
+// P-bit remainders.
+const (
+	P = 19
+	M = 784931
+)
+
+var (
+	// ErrFilter is returned for a malformed filter or one that does
+	// not match its commitment.
+	ErrFilter = errors.New("invalid block filter")
+
+	// ErrNoFilter is returned by Check for a block that does not
+	// commit to a filter.
+	ErrNoFilter = errors.New("block has no filter commitment")
+)
+
+func init() {
+	validation.RegisterCommitment(Commitment, func(b *bc.UnsignedBlock, value []byte) error {
+		if !bytes.Equal(value, Value(Build(b))) {
+			return errors.WithDetail(ErrFilter, "commitment does not match the block's transactions")
+		}
+		return nil
+	})
+}
+
+// Items returns the filter items of tx: for each output, its
+// contract seed, and for a standard pay-to-multisig output, its
+// public keys and asset ID; the ID of each contract it spends; the
+// asset ID of each issuance and retirement; and the data of each
+// log-typed entry. Data other than a string is encoded as by
+// txvm.Encode.
+func Items(tx *bc.Tx) [][]byte {
+	var items [][]byte
+	for _, out := range tx.Outputs {
+		items = append(items, out.Seed.Bytes())
+		pubkeys, assetID, ok := multisig(out)
+		if !ok {
+			continue
+		}
+		items = append(items, pubkeys...)
+		items = append(items, assetID)
+	}
+	for _, in := range tx.Inputs {
+		items = append(items, in.ID.Bytes())
+	}
+	for _, iss := range tx.Issuances {
+		items = append(items, iss.AssetID.Bytes())
+	}
+	for _, ret := range tx.Retirements {
+		items = append(items, ret.AssetID.Bytes())
+	}
+	for _, entry := range tx.Log {
+		if len(entry) != 3 {
+			continue
+		}
+		if code, ok := entry[0].(txvm.Bytes); !ok || len(code) != 1 || code[0] != txvm.LogCode {
+			continue
+		}
+		if data, ok := entry[2].(txvm.Bytes); ok {
+			items = append(items, data)
+		} else {
+			items = append(items, txvm.Encode(entry[2]))
+		}
+	}
+	return items
+}
+
+// multisig returns the public keys and asset ID of a standard
+// pay-to-multisig output (see txresult).
+func multisig(out bc.Output) (pubkeys [][]byte, assetID []byte, ok bool) {
+	if seed := out.Seed.Byte32(); seed != standard.PayToMultisigSeed1 && seed != standard.PayToMultisigSeed2 {
+		return nil, nil, false
+	}
+	if len(out.Stack) < 2 {
+		return nil, nil, false
+	}
+	val, ok := out.Stack[len(out.Stack)-1].(txvm.Tuple)
+	if !ok || len(val) < 3 {
+		return nil, nil, false
+	}
+	asset, ok := val[2].(txvm.Bytes)
+	if !ok {
+		return nil, nil, false
+	}
+	sig, ok := out.Stack[len(out.Stack)-2].(txvm.Tuple)
+	if !ok || len(sig) < 2 {
+		return nil, nil, false
+	}
+	keys, ok := sig[1].(txvm.Tuple)
+	if !ok {
+		return nil, nil, false
+	}
+	for _, k := range keys {
+		if pub, ok := k.(txvm.Bytes); ok {
+			pubkeys = append(pubkeys, pub)
+		}
+	}
+	return pubkeys, asset, true
+}
+
+// Key returns the key with which the items of the block with header
+// bh are hashed: its transactions root. (It cannot be the block ID,
+// which commits to the filter.)
+func Key(bh *bc.BlockHeader) [32]byte {
+	return bh.TransactionsRoot.Byte32()
+}
+
+// Build returns the filter of block b: the number of distinct items
+// of its transactions, as a uvarint, followed by the Golomb-coded
+// set.
+func Build(b *bc.UnsignedBlock) []byte {
+	var items [][]byte
+	for _, tx := range b.Transactions {
+		items = append(items, Items(tx)...)
+	}
+	return Encode(Key(b.BlockHeader), items)
+}
+
+// Encode returns the Golomb-coded set of items, hashed with key.
+// Duplicate items count once.
+func Encode(key [32]byte, items [][]byte) []byte {
+	seen := make(map[string]bool)
+	var distinct [][]byte
+	for _, item := range items {
+		if !seen[string(item)] {
+			seen[string(item)] = true
+			distinct = append(distinct, item)
+		}
+	}
+	n := uint64(len(distinct))
+	values := make([]uint64, 0, n)
+	for _, item := range distinct {
+		values = append(values, hashToRange(key, item, n*M))
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	var buf [binary.MaxVarintLen64]byte
+	w := bitWriter{b: append([]byte(nil), buf[:binary.PutUvarint(buf[:], n)]...)}
+	var last uint64
+	for _, v := range values {
+		d := v - last
+		last = v
+		for q := d >> P; q > 0; q-- {
+			w.writeBit(1)
+		}
+		w.writeBit(0)
+		w.writeBits(d, P)
+	}
+	return w.b
+}
+
+// Value returns the value of the commitment to a filter: its SHA3-256
+// hash.
+func Value(filter []byte) []byte {
+	var h [32]byte
+	sha3pool.Sum256(h[:], filter)
+	return h[:]
+}
+
+// Check checks filter against the commitment in the header bh, as a
+// light client does with a filter from an untrusted node. It returns
+// ErrNoFilter if the block does not commit to a filter.
+func Check(bh *bc.BlockHeader, filter []byte) error {
+	if bh.Version < bc.CommitmentsVersion {
+		return ErrNoFilter
+	}
+	value, ok := bh.Commitment(Commitment)
+	if !ok {
+		return ErrNoFilter
+	}
+	if !bytes.Equal(value, Value(filter)) {
+		return errors.WithDetail(ErrFilter, "filter does not match the header's commitment")
+	}
+	return nil
+}
+
+// Match reports whether any of items is in filter, hashed with key
+// (see Key).
+func Match(filter []byte, key [32]byte, items [][]byte) (bool, error) {
+	n, k := binary.Uvarint(filter)
+	if k <= 0 {
+		return false, errors.WithDetail(ErrFilter, "bad item count")
+	}
+	if n == 0 || len(items) == 0 {
+		return false, nil
+	}
+	if n > uint64(len(filter))*8 {
+		return false, errors.WithDetailf(ErrFilter, "%d items in %d bytes", n, len(filter))
+	}
+	want := make([]uint64, 0, len(items))
+	for _, item := range items {
+		want = append(want, hashToRange(key, item, n*M))
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i] < want[j] })
+
+	r := bitReader{b: filter[k:]}
+	var v uint64
+	for i := uint64(0); i < n; i++ {
+		var q uint64
+		for {
+			bit, err := r.readBit()
+			if err != nil {
+				return false, errors.WithDetail(ErrFilter, "truncated")
+			}
+			if bit == 0 {
+				break
+			}
+			q++
+		}
+		rem, err := r.readBits(P)
+		if err != nil {
+			return false, errors.WithDetail(ErrFilter, "truncated")
+		}
+		v += q<<P | rem
+		for len(want) > 0 && want[0] < v {
+			want = want[1:]
+		}
+		if len(want) == 0 {
+			return false, nil
+		}
+		if want[0] == v {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hashToRange maps item, hashed with key, to a number in [0, f), as
+// a fraction of f: the first 8 bytes of its SHA3-256 hash, times f,
+// divided by 2^64.
+func hashToRange(key [32]byte, item []byte, f uint64) uint64 {
+	var buf [8]byte
+	h := sha3pool.Get256()
+	h.Write(key[:])
+	h.Write(item)
+	io.ReadFull(h, buf[:])
+	sha3pool.Put256(h)
+	hi, _ := bits.Mul64(binary.BigEndian.Uint64(buf[:]), f)
+	return hi
+}
+
+// bitWriter and bitReader write and read bits, most significant
+// first.
+type bitWriter struct {
+	b    []byte
+	nbit uint // bits used in the last byte, 0 meaning 8
+}
+
+func (w *bitWriter) writeBit(bit uint64) {
+	if w.nbit == 0 {
+		w.b = append(w.b, 0)
+	}
+	w.b[len(w.b)-1] |= byte(bit) << (7 - w.nbit)
+	w.nbit = (w.nbit + 1) % 8
+}
+
+func (w *bitWriter) writeBits(x uint64, n uint) {
+	for i := n; i > 0; i-- {
+		w.writeBit(x >> (i - 1) & 1)
+	}
+}
+
+type bitReader struct {
+	b    []byte
+	nbit uint // bits read from b[0]
+}
+
+func (r *bitReader) readBit() (uint64, error) {
+	if len(r.b) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	bit := uint64(r.b[0] >> (7 - r.nbit) & 1)
+	r.nbit++
+	if r.nbit == 8 {
+		r.b, r.nbit = r.b[1:], 0
+	}
+	return bit, nil
+}
+
+func (r *bitReader) readBits(n uint) (uint64, error) {
+	var x uint64
+	for ; n > 0; n-- {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		x = x<<1 | bit
+	}
+	return x, nil
+}
@@ -0,0 +1,123 @@
+package blockfilter_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/blockfilter"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/validation"
+)
+
+func TestEncode(t *testing.T) {
+	key := [32]byte{1}
+	var items [][]byte
+	for i := 0; i < 1000; i++ {
+		items = append(items, []byte(fmt.Sprintf("item %d", i)))
+	}
+	filter := blockfilter.Encode(key, items)
+	if len(filter) > 1000*(blockfilter.P+3)/8 {
+		t.Errorf("filter of 1000 items is %d bytes", len(filter))
+	}
+	for _, item := range items {
+		ok, err := blockfilter.Match(filter, key, [][]byte{item})
+		if err != nil || !ok {
+			t.Fatalf("Match(%q) = %v, %v, want true", item, ok, err)
+		}
+	}
+
+	var falsePositives int
+	for i := 0; i < 10000; i++ {
+		ok, err := blockfilter.Match(filter, key, [][]byte{[]byte(fmt.Sprintf("other %d", i))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			falsePositives++
+		}
+	}
+	if falsePositives > 2 {
+		t.Errorf("%d false positives in 10000", falsePositives)
+	}
+
+	empty := blockfilter.Encode(key, nil)
+	if ok, err := blockfilter.Match(empty, key, items); ok || err != nil {
+		t.Errorf("Match(empty filter) = %v, %v", ok, err)
+	}
+	if _, err := blockfilter.Match(filter[:3], key, items); errors.Root(err) != blockfilter.ErrFilter {
+		t.Errorf("Match(truncated filter): got error %v, want %v", err, blockfilter.ErrFilter)
+	}
+}
+
+func TestBlock(t *testing.T) {
+	c := prottest.NewChain(t)
+	bb := c.BlockBuilder()
+	bb.Version = bc.CommitmentsVersion
+	bb.Commitments = map[string]protocol.CommitmentFunc{
+		blockfilter.Commitment: func(b *bc.UnsignedBlock, _ *state.Snapshot) ([]byte, error) {
+			return blockfilter.Value(blockfilter.Build(b)), nil
+		},
+	}
+
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubs := []ed25519.PublicKey{pub}
+	tag := []byte("gold")
+	assetID := bc.NewHash(standard.AssetID(2, 1, pubs, tag))
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.AddIssuance(2, c.InitialBlockHash.Bytes(), tag, 1, [][]byte{pub}, nil, pubs, 10, nil, nil)
+	tpl.AddOutput(1, pubs, 10, assetID, nil, nil)
+	err = tpl.Sign(context.Background(), func(_ context.Context, msg, _ []byte, _ [][]byte) ([]byte, error) {
+		return ed25519.Sign(prv, msg), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := tpl.Tx()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prev := c.State()
+	b := prottest.MakeBlock(t, c, []*bc.Tx{tx})
+	filter := blockfilter.Build(b.UnsignedBlock)
+	if err := blockfilter.Check(b.BlockHeader, filter); err != nil {
+		t.Fatal(err)
+	}
+	key := blockfilter.Key(b.BlockHeader)
+	for _, item := range [][]byte{pub, assetID.Bytes()} {
+		if ok, err := blockfilter.Match(filter, key, [][]byte{item}); err != nil || !ok {
+			t.Errorf("Match(%x) = %v, %v, want true", item, ok, err)
+		}
+	}
+	other, _, _ := ed25519.GenerateKey(nil)
+	if ok, _ := blockfilter.Match(filter, key, [][]byte{other}); ok {
+		t.Errorf("block filter matches an unrelated key")
+	}
+
+	if err := blockfilter.Check(b.BlockHeader, blockfilter.Encode(key, nil)); errors.Root(err) != blockfilter.ErrFilter {
+		t.Errorf("Check(wrong filter): got error %v, want %v", err, blockfilter.ErrFilter)
+	}
+	if err := blockfilter.Check(prev.Header, filter); errors.Root(err) != blockfilter.ErrNoFilter {
+		t.Errorf("Check(block without filter): got error %v, want %v", err, blockfilter.ErrNoFilter)
+	}
+
+	// A block committing to the wrong filter is invalid.
+	h := *b.BlockHeader
+	h.SetCommitments([]bc.Commitment{{Name: blockfilter.Commitment, Value: blockfilter.Value(nil)}})
+	err = validation.Block(&bc.UnsignedBlock{BlockHeader: &h, Transactions: b.Transactions}, prev.Header)
+	if errors.Root(err) != blockfilter.ErrFilter {
+		t.Errorf("validating a block with the wrong filter: got error %v, want %v", err, blockfilter.ErrFilter)
+	}
+}
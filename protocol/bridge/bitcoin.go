@@ -0,0 +1,130 @@
+package bridge
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+
+	"i10r.io/errors"
+)
+
+// ErrWork is returned for a Bitcoin header without enough proof of
+// work.
+var ErrWork = errors.New("insufficient proof of work")
+
+// headerLen is the length of a serialized Bitcoin block header.
+const headerLen = 80
+
+// Bitcoin is a Backend for Bitcoin and blockchains with Bitcoin's
+// header and transaction-tree formats. Headers are the 80-byte
+// serialized form; hashes, including Parent and transaction IDs,
+// are in internal byte order (the reverse of the usual display
+// order).
+//
+// It does not follow difficulty adjustments. Instead, each header
+// must meet a target no easier than MaxBits, which should be set
+// near the chain's current difficulty, and the chain must extend
+// the trusted header Parent. Federations advance Parent as the
+// external chain grows.
+type Bitcoin struct {
+	// Parent is the hash of the trusted header that the first
+	// header of every proof must follow.
+	Parent [32]byte
+
+	// MaxBits is the easiest target, in compact form, that a header
+	// may claim.
+	MaxBits uint32
+}
+
+// Chain implements Backend.
+func (c *Bitcoin) Chain(headers [][]byte) ([][]byte, error) {
+	maxTarget := compactToBig(c.MaxBits)
+	if maxTarget.Sign() <= 0 {
+		return nil, errors.WithDetailf(ErrWork, "invalid MaxBits %08x", c.MaxBits)
+	}
+	prev := c.Parent
+	var roots [][]byte
+	for i, h := range headers {
+		if len(h) != headerLen {
+			return nil, errors.WithDetailf(ErrHeaders, "header %d is %d bytes", i, len(h))
+		}
+		if !bytes.Equal(h[4:36], prev[:]) {
+			return nil, errors.WithDetailf(ErrHeaders, "header %d does not follow its parent", i)
+		}
+		target := compactToBig(binary.LittleEndian.Uint32(h[72:76]))
+		if target.Sign() <= 0 || target.Cmp(maxTarget) > 0 {
+			return nil, errors.WithDetailf(ErrWork, "header %d target too easy", i)
+		}
+		prev = doubleSHA256(h)
+		if hashToBig(prev).Cmp(target) > 0 {
+			return nil, errors.WithDetailf(ErrWork, "header %d hash above target", i)
+		}
+		roots = append(roots, h[36:68])
+	}
+	return roots, nil
+}
+
+// Included implements Backend. Tx is a serialized transaction
+// without witness data, so that its hash is its ID.
+func (c *Bitcoin) Included(root, tx []byte, path [][]byte, index uint64) ([]byte, error) {
+	// A 64-byte transaction could pass for an interior node of the
+	// tree, so Bitcoin proofs cannot safely prove one.
+	if len(tx) == 64 {
+		return nil, errors.WithDetail(ErrInclusion, "64-byte transaction")
+	}
+	if len(path) < 64 && index>>uint(len(path)) != 0 {
+		return nil, errors.WithDetailf(ErrInclusion, "index %d too large for path of %d", index, len(path))
+	}
+	id := doubleSHA256(tx)
+	h := id
+	for i, sib := range path {
+		if len(sib) != 32 {
+			return nil, errors.WithDetailf(ErrInclusion, "path entry %d is %d bytes", i, len(sib))
+		}
+		var buf []byte
+		if index&1 == 0 {
+			buf = append(append(buf, h[:]...), sib...)
+		} else {
+			buf = append(append(buf, sib...), h[:]...)
+		}
+		h = doubleSHA256(buf)
+		index >>= 1
+	}
+	if !bytes.Equal(h[:], root) {
+		return nil, errors.WithDetail(ErrInclusion, "path does not lead to root")
+	}
+	return id[:], nil
+}
+
+func doubleSHA256(b []byte) [32]byte {
+	h := sha256.Sum256(b)
+	return sha256.Sum256(h[:])
+}
+
+// hashToBig interprets a hash in internal byte order as the
+// little-endian number Bitcoin compares with its target.
+func hashToBig(h [32]byte) *big.Int {
+	var be [32]byte
+	for i := range h {
+		be[i] = h[31-i]
+	}
+	return new(big.Int).SetBytes(be[:])
+}
+
+// compactToBig decodes Bitcoin's compact target encoding. It
+// returns a non-positive number for a negative encoding.
+func compactToBig(bits uint32) *big.Int {
+	mantissa := int64(bits & 0x007fffff)
+	exp := uint(bits >> 24)
+	n := big.NewInt(mantissa)
+	if exp <= 3 {
+		n.Rsh(n, 8*(3-exp))
+	} else {
+		n.Lsh(n, 8*(exp-3))
+	}
+	if bits&0x00800000 != 0 {
+		n.Neg(n)
+	}
+	return n
+}
@@ -0,0 +1,95 @@
+// Package bridge verifies simplified-payment-verification (SPV)
+// proofs of payments on other blockchains, for federated two-way
+// pegs.
+//
+// In a federated peg, value on this blockchain is held in the
+// standard bridge-reserve contract (see
+// standard.BridgeReserveProg) for a federation of keys. A user who
+// pays the federation on the other blockchain presents a Proof of
+// the payment; each federation member checks it with Verify and,
+// if it is valid and not already redeemed, signs a
+// standard.BridgeReleaseMessage naming the payment ID that Verify
+// returns. A quorum of those signatures releases the pegged value
+// to the user.
+//
+// Each other blockchain has a Backend that knows its header and
+// transaction-tree formats. Bitcoin implements one for Bitcoin and
+// chains with Bitcoin's header format.
+package bridge
+
+import (
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+)
+
+var (
+	// ErrHeaders is returned for a proof whose headers are not a
+	// valid chain.
+	ErrHeaders = errors.New("invalid header chain")
+
+	// ErrInclusion is returned for a proof whose transaction is not
+	// in the block it names.
+	ErrInclusion = errors.New("transaction not in block")
+
+	// ErrConfirmations is returned for a proof with too few
+	// headers after the block containing its transaction.
+	ErrConfirmations = errors.New("not enough confirmations")
+)
+
+// Backend verifies headers and transaction-inclusion proofs for one
+// external blockchain.
+type Backend interface {
+	// Chain checks that headers, in order, form a valid chain of
+	// block headers and returns the transaction-tree root each
+	// commits to.
+	Chain(headers [][]byte) (roots [][]byte, err error)
+
+	// Included checks that path proves tx is at position index in
+	// the transaction tree with the given root and returns the ID
+	// of tx.
+	Included(root, tx []byte, path [][]byte, index uint64) (id []byte, err error)
+}
+
+// Proof is an SPV proof that a transaction is in a block on another
+// blockchain, buried under the blocks after it.
+type Proof struct {
+	// Headers are consecutive block headers, encoded as the
+	// backend expects.
+	Headers []chainjson.HexBytes `json:"headers"`
+
+	// Block is the position in Headers of the block containing Tx.
+	Block int `json:"block"`
+
+	// Tx is the transaction, encoded as the backend expects.
+	Tx chainjson.HexBytes `json:"tx"`
+
+	// Path and Index locate Tx in the block's transaction tree.
+	Path  []chainjson.HexBytes `json:"path"`
+	Index uint64               `json:"index"`
+}
+
+// Verify checks p with backend and returns the ID of the proven
+// transaction. The block containing it must have at least
+// confirmations headers from it to the end of p.Headers, counting
+// its own.
+func Verify(backend Backend, p *Proof, confirmations int) ([]byte, error) {
+	if p.Block < 0 || p.Block >= len(p.Headers) {
+		return nil, errors.WithDetailf(ErrHeaders, "block %d of %d headers", p.Block, len(p.Headers))
+	}
+	if n := len(p.Headers) - p.Block; n < confirmations {
+		return nil, errors.WithDetailf(ErrConfirmations, "%d of %d", n, confirmations)
+	}
+	headers := make([][]byte, len(p.Headers))
+	for i, h := range p.Headers {
+		headers[i] = h
+	}
+	roots, err := backend.Chain(headers)
+	if err != nil {
+		return nil, err
+	}
+	path := make([][]byte, len(p.Path))
+	for i, h := range p.Path {
+		path[i] = h
+	}
+	return backend.Included(roots[p.Block], p.Tx, path, p.Index)
+}
@@ -0,0 +1,236 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+
+	"i10r.io/crypto/ed25519"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+// regtestBits is the easiest target of Bitcoin's regression-test
+// network, which any header meets with a few tries.
+const regtestBits = 0x207fffff
+
+func TestGenesis(t *testing.T) {
+	header := mustDecodeHex("0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c")
+	coinbase := mustDecodeHex("01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000")
+	p := &Proof{Headers: []chainjson.HexBytes{header}, Tx: coinbase}
+	id, err := Verify(&Bitcoin{MaxBits: 0x1d00ffff}, p, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "3ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a"
+	if got := hex.EncodeToString(id); got != want {
+		t.Errorf("genesis coinbase ID %s, want %s", got, want)
+	}
+	_, err = Verify(&Bitcoin{MaxBits: 0x1c00ffff}, p, 1)
+	if errors.Root(err) != ErrWork {
+		t.Errorf("harder MaxBits: got %v, want %v", err, ErrWork)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	parent := [32]byte{1}
+	txs := [][]byte{[]byte("tx0"), []byte("tx1"), []byte("tx2")}
+	root, path := testTree(txs, 2)
+	headers := mineHeaders(parent, root, 3)
+	backend := &Bitcoin{Parent: parent, MaxBits: regtestBits}
+	proof := func() *Proof {
+		return &Proof{Headers: headers, Tx: txs[2], Path: path, Index: 2}
+	}
+
+	id, err := Verify(backend, proof(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := doubleSHA256(txs[2]); !bytes.Equal(id, want[:]) {
+		t.Errorf("got ID %x, want %x", id, want[:])
+	}
+
+	cases := []struct {
+		name    string
+		mod     func(*Proof)
+		b       *Bitcoin
+		conf    int
+		wantErr error
+	}{
+		{"too few confirmations", func(*Proof) {}, backend, 4, ErrConfirmations},
+		{"other parent", func(*Proof) {}, &Bitcoin{MaxBits: regtestBits}, 1, ErrHeaders},
+		{"gap", func(p *Proof) { p.Headers = []chainjson.HexBytes{headers[0], headers[2]} }, backend, 1, ErrHeaders},
+		{"wrong tx", func(p *Proof) { p.Tx = txs[1] }, backend, 1, ErrInclusion},
+		{"wrong index", func(p *Proof) { p.Index = 1 }, backend, 1, ErrInclusion},
+		{"index past path", func(p *Proof) { p.Index = 6 }, backend, 1, ErrInclusion},
+		{"block out of range", func(p *Proof) { p.Block = 3 }, backend, 1, ErrHeaders},
+	}
+	for _, c := range cases {
+		p := proof()
+		c.mod(p)
+		_, err := Verify(c.b, p, c.conf)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.wantErr)
+		}
+	}
+}
+
+func TestRelease(t *testing.T) {
+	fedPubs, fedPrvs := testKeys(t, 3)
+	userPubs, _ := testKeys(t, 1)
+	assetID := bc.NewHash([32]byte{2})
+	anchor := []byte("anchor")
+	paymentID := bytes.Repeat([]byte{3}, 32)
+
+	release := func(amount int64, signers ...int) (*txvm.VM, error) {
+		r := &standard.Release{
+			PaymentID: paymentID,
+			Amount:    amount,
+			Quorum:    1,
+			Pubkeys:   userPubs,
+			Sigs:      make([][]byte, len(fedPubs)),
+		}
+		msg := standard.BridgeReleaseMessage(anchor, paymentID, amount, r.Quorum, r.Pubkeys)
+		for _, i := range signers {
+			r.Sigs[i] = ed25519.Sign(fedPrvs[i], msg)
+		}
+		var b txvmutil.Builder
+		standard.ReleaseBridgeReserve(&b, 2, fedPubs, 100, assetID, anchor, r)
+		fin, err := asm.Assemble("'id' 10 nonce finalize")
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Concat(fin)
+		return txvm.Validate(b.Build(), 3, 100000)
+	}
+
+	vm, err := release(60, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	split1 := txvm.VMHash("Split1", anchor)
+	split2 := txvm.VMHash("Split2", anchor)
+	wantOutputs := []bc.Hash{
+		standard.MultisigOutputID(1, userPubs, 60, assetID, split2[:], standard.PayToMultisigSeed2[:]),
+		standard.BridgeReserveOutputID(2, fedPubs, 40, assetID, split1[:]),
+	}
+	if got := outputs(vm); !equalHashes(got, wantOutputs) {
+		t.Errorf("partial release outputs %x, want %x", got, wantOutputs)
+	}
+
+	vm, err = release(100, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOutputs = []bc.Hash{standard.MultisigOutputID(1, userPubs, 100, assetID, split2[:], standard.PayToMultisigSeed2[:])}
+	if got := outputs(vm); !equalHashes(got, wantOutputs) {
+		t.Errorf("full release outputs %x, want %x", got, wantOutputs)
+	}
+
+	if _, err := release(60, 0); err == nil {
+		t.Error("release with 1 of 2 signatures succeeded")
+	}
+	if _, err := release(101, 0, 1); err == nil {
+		t.Error("release of more than the reserve succeeded")
+	}
+}
+
+// testTree returns the Bitcoin transaction-tree root of txs and the
+// path to txs[index].
+func testTree(txs [][]byte, index int) ([]byte, []chainjson.HexBytes) {
+	var level [][32]byte
+	for _, tx := range txs {
+		level = append(level, doubleSHA256(tx))
+	}
+	var path []chainjson.HexBytes
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		sib := level[index^1]
+		path = append(path, sib[:])
+		var next [][32]byte
+		for i := 0; i < len(level); i += 2 {
+			next = append(next, doubleSHA256(append(level[i][:], level[i+1][:]...)))
+		}
+		level, index = next, index/2
+	}
+	return level[0][:], path
+}
+
+// mineHeaders returns n headers at regtestBits following parent,
+// the first committing to root.
+func mineHeaders(parent [32]byte, root []byte, n int) []chainjson.HexBytes {
+	var headers []chainjson.HexBytes
+	for i := 0; i < n; i++ {
+		h := make([]byte, headerLen)
+		binary.LittleEndian.PutUint32(h[0:4], 1)
+		copy(h[4:36], parent[:])
+		if i == 0 {
+			copy(h[36:68], root)
+		}
+		binary.LittleEndian.PutUint32(h[72:76], regtestBits)
+		target := compactToBig(regtestBits)
+		for nonce := uint32(0); ; nonce++ {
+			binary.LittleEndian.PutUint32(h[76:80], nonce)
+			if hashToBig(doubleSHA256(h)).Cmp(target) <= 0 {
+				break
+			}
+		}
+		headers = append(headers, h)
+		parent = doubleSHA256(h)
+	}
+	return headers
+}
+
+func testKeys(t *testing.T, n int) ([]ed25519.PublicKey, []ed25519.PrivateKey) {
+	var (
+		pubs []ed25519.PublicKey
+		prvs []ed25519.PrivateKey
+	)
+	for i := 0; i < n; i++ {
+		pub, prv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, pub)
+		prvs = append(prvs, prv)
+	}
+	return pubs, prvs
+}
+
+func outputs(vm *txvm.VM) []bc.Hash {
+	var ids []bc.Hash
+	for _, item := range vm.Log {
+		if item[0].(txvm.Bytes)[0] == txvm.OutputCode {
+			ids = append(ids, bc.HashFromBytes(item[2].(txvm.Bytes)))
+		}
+	}
+	return ids
+}
+
+func equalHashes(a, b []bc.Hash) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
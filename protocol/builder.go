@@ -7,8 +7,12 @@ import (
 	"i10r.io/errors"
 	"i10r.io/math/checked"
 	"i10r.io/protocol/bc"
+	"i10r.io/protocol/chainparams"
+	"i10r.io/protocol/fee"
 	"i10r.io/protocol/merkle"
+	"i10r.io/protocol/rent"
 	"i10r.io/protocol/state"
+	"i10r.io/protocol/txvm"
 )
 
 // Some defaults.
@@ -18,14 +22,61 @@ const (
 	maxBlockTxs    = 10000
 )
 
+// builtinCommitments are the names of the commitments that Build
+// makes itself, which BlockBuilder.Commitments may not use.
+var builtinCommitments = []string{fee.Commitment, rent.Commitment, chainparams.Commitment, bc.NetworkCommitment}
+
+// A CommitmentFunc computes the value of a named commitment for the
+// header of block b, given the state after b's transactions.
+type CommitmentFunc func(b *bc.UnsignedBlock, snapshot *state.Snapshot) ([]byte, error)
+
 type BlockBuilder struct {
 	Version        uint64
 	MaxNonceWindow time.Duration
 	MaxBlockWindow int64
 	MaxBlockTxs    int
 
+	// Commitments maps names to functions computing the header
+	// commitments of built blocks. It is used only when Version is
+	// at least bc.CommitmentsVersion. It must not use the names of
+	// the commitments the builder makes itself (see builtinCommitments).
+	Commitments map[string]CommitmentFunc
+
+	// FeeClaim, if set, names the keys that get the fees paid in
+	// built blocks. It is used only when Version is at least
+	// bc.CommitmentsVersion.
+	FeeClaim *fee.Claim
+
+	// RentLifetime, if set, makes the outputs added in built blocks
+	// expire that long after the block's timestamp (see package
+	// rent). It is used only when Version is at least
+	// bc.CommitmentsVersion, and only until a block with rent is
+	// built: the blocks after that have the same rent as the
+	// previous block.
+	RentLifetime time.Duration
+
+	// Params, if set, are the limits of built blocks (see package
+	// chainparams). Like RentLifetime, it is used only when Version
+	// is at least bc.CommitmentsVersion, and only until a block with
+	// parameters is built: the blocks after that have the previous
+	// block's parameters, or those of an update approved in them.
+	Params *chainparams.Params
+
+	// NextPredicate, if set, is the predicate that built blocks
+	// commit to for the signatures of the blocks after them, such as
+	// a weighted predicate (see bc.NewWeightedPredicate) replacing
+	// the one the signers have used so far. Unset, built blocks keep
+	// the previous block's.
+	NextPredicate *bc.Predicate
+
 	snapshot    *state.Snapshot
+	rent        uint64              // the rent lifetime of the block being built, in ms
+	params      *chainparams.Params // the parameters of the block being built
+	updated     bool                // whether the block approves a parameter update
+	bytes       int64
+	fees        map[bc.Hash]int64 // the fees paid in the block, if it has a fee claim
 	txs         []*bc.CommitmentsTx
+	txRoot      merkle.Accumulator
 	timestampMS uint64
 	runlimit    int64
 }
@@ -43,11 +94,32 @@ func (bb *BlockBuilder) Start(snapshot *state.Snapshot, timestampMS uint64) erro
 	if timestampMS <= snapshot.Header.TimestampMs {
 		return fmt.Errorf("timestamp %d is not greater than prevblock timestamp %d", timestampMS, snapshot.Header.TimestampMs)
 	}
+	lifetime, err := rent.Lifetime(snapshot.Header)
+	if err != nil {
+		return err
+	}
+	if lifetime == 0 && bb.Version >= bc.CommitmentsVersion {
+		lifetime = bc.DurationMillis(bb.RentLifetime)
+	}
+	params, err := chainparams.Of(snapshot.Header)
+	if err != nil {
+		return err
+	}
+	if params == nil && bb.Version >= bc.CommitmentsVersion && bb.Params != nil {
+		p := *bb.Params
+		params = &p
+	}
 	bb.snapshot = state.Copy(snapshot)
 	bb.snapshot.PruneNonces(timestampMS)
+	bb.rent = lifetime
+	bb.params = params
+	bb.updated = false
 	bb.timestampMS = timestampMS
 	bb.txs = nil
+	bb.txRoot = merkle.Accumulator{}
 	bb.runlimit = 0
+	bb.bytes = 0
+	bb.fees = nil
 	return nil
 }
 
@@ -73,12 +145,22 @@ var (
 	// ErrTxLongNonce happens when trying to add a transaction with a
 	// nonce whose expiration is more than MaxNonceWindow in the future.
 	ErrTxLongNonce = errors.New("transaction nonce expires too far in the future")
+
+	// ErrTxVersion happens when trying to add a transaction whose
+	// version the block's version does not allow: only blocks of
+	// version bc.NetworkVersion or later may contain transactions of
+	// version txvm.NetworkVersion or later, and they may contain only
+	// those.
+	ErrTxVersion = errors.New("transaction version not allowed in block")
 )
 
 func (bb *BlockBuilder) AddTx(tx *bc.CommitmentsTx) error {
 	if len(bb.txs) >= bb.MaxBlockTxs {
 		return ErrBlockFull
 	}
+	if (bb.Version >= bc.NetworkVersion) != (tx.Tx.Version >= txvm.NetworkVersion) {
+		return errors.WithDetailf(ErrTxVersion, "block version %d, transaction version %d", bb.Version, tx.Tx.Version)
+	}
 	err := bb.checkTransactionTime(tx.Tx, bb.timestampMS)
 	if err != nil {
 		return err
@@ -87,47 +169,117 @@ func (bb *BlockBuilder) AddTx(tx *bc.CommitmentsTx) error {
 	if !ok {
 		return ErrBlockRunlimit
 	}
-	err = bb.snapshot.ApplyTx(tx)
+	bytes := bb.bytes + chainparams.TxBytes(tx.Tx)
+	params := bb.params
+	update := chainparams.Approved(tx.Tx, bb.snapshot.Header, bb.snapshot.InitialBlockID)
+	if update != nil {
+		if bb.updated {
+			return errors.WithDetail(chainparams.ErrUpdate, "block already approves an update")
+		}
+		// A new block interval applies to this block, whose timestamp
+		// must then start one of its slots. If it does not, the update
+		// waits for a block whose timestamp does.
+		err = update.Params.CheckTimestamp(bb.timestampMS)
+		if err != nil {
+			return err
+		}
+		params = &update.Params
+	}
+	if params != nil {
+		err = params.Allows(len(bb.txs)+1, bytes, runlimit)
+		if err != nil {
+			return err
+		}
+	}
+	fees := bb.fees
+	if bb.Version >= bc.CommitmentsVersion && bb.FeeClaim != nil {
+		fees, err = fee.AddTotals(bb.fees, tx.Tx)
+		if err != nil {
+			return err
+		}
+	}
+	err = bb.snapshot.ApplyTxRent(tx, bb.timestampMS, bb.rent)
 	if err != nil {
 		return err
 	}
 
+	if update != nil {
+		bb.params = params
+		bb.updated = true
+	}
 	bb.runlimit = runlimit
+	bb.bytes = bytes
+	bb.fees = fees
 	bb.txs = append(bb.txs, tx)
+	bb.txRoot.Add(tx.WitnessCommitment)
 
 	return nil
 }
 
 func (bb *BlockBuilder) Build() (*bc.UnsignedBlock, *state.Snapshot, error) {
+	if bb.Version >= bc.CommitmentsVersion {
+		for _, name := range builtinCommitments {
+			if _, ok := bb.Commitments[name]; ok {
+				return nil, nil, errors.WithDetailf(bc.ErrCommitments, "commitment %q is built in", name)
+			}
+		}
+	}
+
 	prev := bb.snapshot.Header
 	refsCount := bb.MaxBlockWindow
 	if prev.RefsCount < refsCount {
 		refsCount = prev.RefsCount + 1
 	}
 
-	var (
-		txs = make([]*bc.Tx, 0, len(bb.txs))
-		wcs = make([][]byte, 0, len(bb.txs))
-	)
+	txs := make([]*bc.Tx, 0, len(bb.txs))
 	for _, tx := range bb.txs {
 		txs = append(txs, tx.Tx)
-		wcs = append(wcs, tx.WitnessCommitment)
+	}
+
+	nextPredicate := prev.NextPredicate
+	if bb.NextPredicate != nil {
+		if _, _, err := bb.NextPredicate.Signers(); err != nil {
+			return nil, nil, errors.Wrap(err, "next predicate")
+		}
+		nextPredicate = bb.NextPredicate
+	}
+
+	prevID := prev.Hash()
+	var cs []bc.Commitment
+	if bb.Version >= bc.CommitmentsVersion && bb.FeeClaim != nil {
+		outs, err := fee.BlockOutputs(prevID, txs, bb.FeeClaim)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = bb.snapshot.AddFeeOutputs(outs)
+		if err != nil {
+			return nil, nil, err
+		}
+		cs = append(cs, bc.Commitment{Name: fee.Commitment, Value: bb.FeeClaim.Value()})
+	}
+	if bb.rent > 0 {
+		cs = append(cs, bc.Commitment{Name: rent.Commitment, Value: rent.Value(bb.rent)})
+	}
+	if bb.params != nil {
+		cs = append(cs, bc.Commitment{Name: chainparams.Commitment, Value: chainparams.Value(bb.params)})
+	}
+	if bb.Version >= bc.NetworkVersion {
+		cs = append(cs, bc.Commitment{Name: bc.NetworkCommitment, Value: bb.snapshot.InitialBlockID.Bytes()})
 	}
 
 	var (
-		txRoot        = bc.NewHash(merkle.Root(wcs))
+		txRoot        = bc.NewHash(bb.txRoot.Root())
 		contractsRoot = bc.NewHash(bb.snapshot.ContractsTree.RootHash())
 		nonceRoot     = bc.NewHash(bb.snapshot.NonceTree.RootHash())
 	)
 
-	prevID := prev.Hash()
 	h := &bc.BlockHeader{
 		Version:          bb.Version,
 		Height:           prev.Height + 1,
 		PreviousBlockId:  &prevID,
 		TimestampMs:      bb.timestampMS,
 		RefsCount:        refsCount,
-		NextPredicate:    prev.NextPredicate,
+		NextPredicate:    nextPredicate,
 		Runlimit:         bb.runlimit,
 		TransactionsRoot: &txRoot,
 		ContractsRoot:    &contractsRoot,
@@ -137,6 +289,16 @@ func (bb *BlockBuilder) Build() (*bc.UnsignedBlock, *state.Snapshot, error) {
 		BlockHeader:  h,
 		Transactions: txs,
 	}
+	if bb.Version >= bc.CommitmentsVersion {
+		for name, f := range bb.Commitments {
+			value, err := f(b, bb.snapshot)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "computing commitment %q", name)
+			}
+			cs = append(cs, bc.Commitment{Name: name, Value: value})
+		}
+		h.SetCommitments(cs)
+	}
 	err := bb.snapshot.ApplyBlockHeader(h)
 	if err != nil {
 		return nil, nil, err
@@ -147,6 +309,10 @@ func (bb *BlockBuilder) Build() (*bc.UnsignedBlock, *state.Snapshot, error) {
 	bb.txs = nil
 	bb.timestampMS = 0
 	bb.runlimit = 0
+	bb.rent = 0
+	bb.params = nil
+	bb.bytes = 0
+	bb.fees = nil
 
 	return b, snapshot, nil
 }
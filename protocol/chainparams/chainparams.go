@@ -0,0 +1,383 @@
+// Package chainparams implements block limits that are chain
+// parameters: committed in block headers and changed only by the
+// block signers' agreement.
+//
+// A block of version bc.CommitmentsVersion or later may carry, in its
+// header's commitments area, a params commitment giving its maximum
+// size, runlimit, and number of transactions. Validation checks the
+// block against them. Like the rent commitment (see package rent),
+// it acts as a network flag: once a block carries it, every later
+// block must carry it too, with the same parameters.
+//
+// The parameters change only through an Update, a typed log entry
+// (see package logdata) holding the new parameters and the
+// signatures of a quorum of the keys that sign blocks. The block
+// containing a transaction that logs an approved update carries the
+// new parameters, and is checked against them. Each update has the
+// next sequence number, so an approval cannot be replayed.
+//
+// The parameters may also set a block interval, dividing time into
+// slots assigned round-robin to the block signers (see Slot and
+// CheckSlot), for permissioned networks that want predictable block
+// times and to notice a signer that stops making blocks.
+package chainparams
+
+import (
+	"encoding/binary"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/logdata"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/validation"
+)
+
+// Commitment is the name of the header commitment holding a block's
+// parameters.
+const Commitment = "params"
+
+// UpdateType is the type tag of an Update log entry.
+const UpdateType = "params-update"
+
+var (
+	// ErrParams is returned for a malformed params commitment.
+	ErrParams = errors.New("malformed chain parameters")
+
+	// ErrLimit is returned for a block that exceeds its parameters'
+	// limits.
+	ErrLimit = errors.New("block exceeds chain parameter limit")
+
+	// ErrUpdate is returned for a block whose parameters differ from
+	// the previous block's without an approved update, or that
+	// approves more than one update.
+	ErrUpdate = errors.New("unapproved chain parameter change")
+
+	// ErrQuorum is returned for an update without enough valid
+	// signatures.
+	ErrQuorum = errors.New("not enough valid parameter update signatures")
+
+	// ErrSlot is returned for a block, under parameters with a block
+	// interval, whose timestamp is not the start of a slot or that
+	// is not signed by its slot's leader.
+	ErrSlot = errors.New("block outside its slot")
+)
+
+func init() {
+	validation.RegisterCommitment(Commitment, func(b *bc.UnsignedBlock, value []byte) error {
+		p, err := Parse(value)
+		if err != nil {
+			return err
+		}
+		return p.Check(b)
+	})
+	validation.RegisterCommitmentSig(Commitment, func(b *bc.Block, pred *bc.Predicate, value []byte) error {
+		return CheckSlot(b, pred)
+	})
+	logdata.Register(&logdata.Schema{
+		Type:   UpdateType,
+		Doc:    "a chain parameter update: {seq, max block bytes, max block runlimit, max block txs, [block interval ms,] {signature, ...}}",
+		Decode: decodeUpdate,
+	})
+}
+
+// Params are the limits on a block. A zero limit is no limit. Seq
+// counts the updates that led to them.
+type Params struct {
+	Seq              uint64 `json:"seq"`
+	MaxBlockBytes    int64  `json:"max_block_bytes"`
+	MaxBlockRunlimit int64  `json:"max_block_runlimit"`
+	MaxBlockTxs      int64  `json:"max_block_txs"`
+
+	// BlockIntervalMS, if not zero, is the length of a block slot
+	// in milliseconds (see Slot).
+	BlockIntervalMS int64 `json:"block_interval_ms,omitempty"`
+}
+
+// Value returns the encoding of p for a header commitment: Seq and
+// the limits, 8 bytes each, little-endian, followed by the block
+// interval in the same form if it is not zero.
+func Value(p *Params) []byte {
+	n := 32
+	if p.BlockIntervalMS != 0 {
+		n = 40
+	}
+	buf := make([]byte, n)
+	binary.LittleEndian.PutUint64(buf[0:], p.Seq)
+	binary.LittleEndian.PutUint64(buf[8:], uint64(p.MaxBlockBytes))
+	binary.LittleEndian.PutUint64(buf[16:], uint64(p.MaxBlockRunlimit))
+	binary.LittleEndian.PutUint64(buf[24:], uint64(p.MaxBlockTxs))
+	if n == 40 {
+		binary.LittleEndian.PutUint64(buf[32:], uint64(p.BlockIntervalMS))
+	}
+	return buf
+}
+
+// Parse parses the value of a params commitment.
+func Parse(value []byte) (*Params, error) {
+	if len(value) != 32 && len(value) != 40 {
+		return nil, errors.WithDetailf(ErrParams, "length %d", len(value))
+	}
+	p := &Params{
+		Seq:              binary.LittleEndian.Uint64(value[0:]),
+		MaxBlockBytes:    int64(binary.LittleEndian.Uint64(value[8:])),
+		MaxBlockRunlimit: int64(binary.LittleEndian.Uint64(value[16:])),
+		MaxBlockTxs:      int64(binary.LittleEndian.Uint64(value[24:])),
+	}
+	if len(value) == 40 {
+		p.BlockIntervalMS = int64(binary.LittleEndian.Uint64(value[32:]))
+		if p.BlockIntervalMS == 0 {
+			return nil, errors.WithDetail(ErrParams, "zero block interval encoded")
+		}
+	}
+	if p.MaxBlockBytes < 0 || p.MaxBlockRunlimit < 0 || p.MaxBlockTxs < 0 || p.BlockIntervalMS < 0 {
+		return nil, errors.WithDetail(ErrParams, "negative limit")
+	}
+	return p, nil
+}
+
+// Of returns the parameters of the block with header bh, or nil if
+// the block has none.
+func Of(bh *bc.BlockHeader) (*Params, error) {
+	if bh.Version < bc.CommitmentsVersion {
+		return nil, nil
+	}
+	value, ok := bh.Commitment(Commitment)
+	if !ok {
+		return nil, nil
+	}
+	return Parse(value)
+}
+
+// TxBytes returns the size of tx as block limits count it: the
+// length of its program, which is nearly all of its encoding.
+func TxBytes(tx *bc.Tx) int64 {
+	return int64(len(tx.Program))
+}
+
+// Allows returns ErrLimit if a block with the given number of
+// transactions, total size, and total runlimit exceeds p's limits.
+func (p *Params) Allows(txs int, bytes, runlimit int64) error {
+	switch {
+	case p.MaxBlockTxs > 0 && int64(txs) > p.MaxBlockTxs:
+		return errors.WithDetailf(ErrLimit, "%d transactions, limit %d", txs, p.MaxBlockTxs)
+	case p.MaxBlockBytes > 0 && bytes > p.MaxBlockBytes:
+		return errors.WithDetailf(ErrLimit, "%d bytes, limit %d", bytes, p.MaxBlockBytes)
+	case p.MaxBlockRunlimit > 0 && runlimit > p.MaxBlockRunlimit:
+		return errors.WithDetailf(ErrLimit, "runlimit %d, limit %d", runlimit, p.MaxBlockRunlimit)
+	}
+	return nil
+}
+
+// Check returns ErrLimit if b exceeds p's limits, and ErrSlot if p
+// has a block interval and b's timestamp is not the start of a slot.
+func (p *Params) Check(b *bc.UnsignedBlock) error {
+	err := p.CheckTimestamp(b.TimestampMs)
+	if err != nil {
+		return err
+	}
+	var bytes int64
+	for _, tx := range b.Transactions {
+		bytes += TxBytes(tx)
+	}
+	return p.Allows(len(b.Transactions), bytes, b.Runlimit)
+}
+
+// CheckTimestamp returns ErrSlot if p has a block interval and
+// timestampMS is not the start of a slot.
+func (p *Params) CheckTimestamp(timestampMS uint64) error {
+	if p.BlockIntervalMS > 0 && timestampMS%uint64(p.BlockIntervalMS) != 0 {
+		return errors.WithDetailf(ErrSlot, "timestamp %d, block interval %d", timestampMS, p.BlockIntervalMS)
+	}
+	return nil
+}
+
+// Update is an approval, by the block signers, of new parameters.
+// Signatures is parallel to the pubkeys of the predicate of the
+// block before the one it is in; an empty entry is a key that did
+// not sign.
+type Update struct {
+	Params     Params
+	Signatures [][]byte
+}
+
+// Type implements logdata.Value.
+func (u *Update) Type() string { return UpdateType }
+
+// Fields implements logdata.Value.
+func (u *Update) Fields() txvm.Tuple {
+	sigs := make(txvm.Tuple, 0, len(u.Signatures))
+	for _, sig := range u.Signatures {
+		sigs = append(sigs, txvm.Bytes(sig))
+	}
+	return append(u.ints(), sigs)
+}
+
+// ints returns u's parameters as the ints of its log entry and
+// signed message, omitting the block interval if it is zero, as
+// updates before block intervals did.
+func (u *Update) ints() txvm.Tuple {
+	tup := txvm.Tuple{
+		txvm.Int(u.Params.Seq),
+		txvm.Int(u.Params.MaxBlockBytes),
+		txvm.Int(u.Params.MaxBlockRunlimit),
+		txvm.Int(u.Params.MaxBlockTxs),
+	}
+	if u.Params.BlockIntervalMS != 0 {
+		tup = append(tup, txvm.Int(u.Params.BlockIntervalMS))
+	}
+	return tup
+}
+
+func decodeUpdate(fields txvm.Tuple) (logdata.Value, error) {
+	if len(fields) != 5 && len(fields) != 6 {
+		return nil, errors.WithDetailf(logdata.ErrFields, "params update has %d fields", len(fields))
+	}
+	var ints [5]int64
+	for i := 0; i < len(fields)-1; i++ {
+		n, ok := fields[i].(txvm.Int)
+		if !ok || n < 0 {
+			return nil, errors.WithDetailf(logdata.ErrFields, "params update field %d is not a nonnegative int", i)
+		}
+		ints[i] = int64(n)
+	}
+	if len(fields) == 6 && ints[4] == 0 {
+		return nil, errors.WithDetail(logdata.ErrFields, "params update has a zero block interval")
+	}
+	sigs, ok := fields[len(fields)-1].(txvm.Tuple)
+	if !ok {
+		return nil, errors.WithDetail(logdata.ErrFields, "params update signatures are not a tuple")
+	}
+	u := &Update{Params: Params{
+		Seq:              uint64(ints[0]),
+		MaxBlockBytes:    ints[1],
+		MaxBlockRunlimit: ints[2],
+		MaxBlockTxs:      ints[3],
+		BlockIntervalMS:  ints[4],
+	}}
+	for _, item := range sigs {
+		sig, ok := item.(txvm.Bytes)
+		if !ok {
+			return nil, errors.WithDetail(logdata.ErrFields, "params update signature is not a string")
+		}
+		u.Signatures = append(u.Signatures, sig)
+	}
+	return u, nil
+}
+
+// Message returns the message the block signers sign to approve u
+// on the blockchain with the given initial block.
+func (u *Update) Message(initialBlockID bc.Hash) []byte {
+	tup := append(txvm.Tuple{txvm.Bytes(initialBlockID.Bytes())}, u.ints()...)
+	h := txvm.VMHash("ChainParams", txvm.Encode(tup))
+	return h[:]
+}
+
+// Sign adds a signature with prv, which must be the private key for
+// one of pred's pubkeys.
+func (u *Update) Sign(pred *bc.Predicate, initialBlockID bc.Hash, prv ed25519.PrivateKey) error {
+	signers, _, err := pred.Signers()
+	if err != nil {
+		return err
+	}
+	pub := prv.Public().(ed25519.PublicKey)
+	for i, s := range signers {
+		if string(s.Pubkey) == string(pub) {
+			if len(u.Signatures) < len(signers) {
+				sigs := make([][]byte, len(signers))
+				copy(sigs, u.Signatures)
+				u.Signatures = sigs
+			}
+			u.Signatures[i] = ed25519.Sign(prv, u.Message(initialBlockID))
+			return nil
+		}
+	}
+	return errors.New("key is not a block-signing key")
+}
+
+// Verify checks that u carries a quorum of valid signatures by
+// pred's keys or, for a weighted predicate, signatures by keys
+// whose weights total at least its threshold.
+func (u *Update) Verify(pred *bc.Predicate, initialBlockID bc.Hash) error {
+	signers, threshold, err := pred.Signers()
+	if err != nil {
+		return errors.Sub(ErrQuorum, err)
+	}
+	if len(u.Signatures) > len(signers) {
+		return errors.WithDetailf(ErrQuorum, "%d signatures for %d keys", len(u.Signatures), len(signers))
+	}
+	msg := u.Message(initialBlockID)
+	var weight int64
+	for i, sig := range u.Signatures {
+		if len(sig) == 0 {
+			continue
+		}
+		if !ed25519.Verify(signers[i].Pubkey, msg, sig) {
+			return errors.WithDetailf(ErrQuorum, "bad signature for key %d", i)
+		}
+		weight += signers[i].Weight
+	}
+	if weight < threshold {
+		return errors.WithDetailf(ErrQuorum, "weight %d of %d", weight, threshold)
+	}
+	return nil
+}
+
+// Approved returns the update logged in tx that the signers of the
+// block after prev approve, if any: one with the sequence number
+// following prev's parameters and a quorum of signatures by prev's
+// next-block predicate. Other updates are ordinary log entries.
+func Approved(tx *bc.Tx, prev *bc.BlockHeader, initialBlockID bc.Hash) *Update {
+	var seq uint64
+	if p, err := Of(prev); err == nil && p != nil {
+		seq = p.Seq
+	}
+	for _, e := range logdata.Entries(tx) {
+		if e.Type != UpdateType {
+			continue
+		}
+		v, err := logdata.Decode(e)
+		if err != nil {
+			continue
+		}
+		u := v.(*Update)
+		if u.Params.Seq == seq+1 && u.Verify(prev.NextPredicate, initialBlockID) == nil {
+			return u
+		}
+	}
+	return nil
+}
+
+// Next checks the parameters of b, which follows the block with
+// header prev, against prev's: they must be the same, unless b
+// approves an update, in which case they must be the update's. A
+// block after one without parameters may introduce any.
+func Next(b *bc.UnsignedBlock, prev *bc.BlockHeader, initialBlockID bc.Hash) error {
+	prevParams, err := Of(prev)
+	if err != nil {
+		return errors.Wrap(err, "parsing previous block parameters")
+	}
+	params, err := Of(b.BlockHeader)
+	if err != nil {
+		return err
+	}
+	want := prevParams
+	var approved int
+	for _, tx := range b.Transactions {
+		if u := Approved(tx, prev, initialBlockID); u != nil {
+			want = &u.Params
+			approved++
+		}
+	}
+	switch {
+	case approved > 1:
+		return errors.WithDetailf(ErrUpdate, "%d approved updates", approved)
+	case want == nil:
+		return nil
+	case params == nil:
+		return errors.WithDetail(ErrUpdate, "block has no parameters")
+	case *params != *want:
+		return errors.WithDetailf(ErrUpdate, "block has %+v, want %+v", *params, *want)
+	}
+	return nil
+}
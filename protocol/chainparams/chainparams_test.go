@@ -0,0 +1,199 @@
+package chainparams_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/chainparams"
+	"i10r.io/protocol/logdata"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/validation"
+)
+
+func TestValue(t *testing.T) {
+	p := &chainparams.Params{Seq: 3, MaxBlockBytes: 1 << 20, MaxBlockRunlimit: 1 << 40, MaxBlockTxs: 1000}
+	got, err := chainparams.Parse(chainparams.Value(p))
+	if err != nil || *got != *p {
+		t.Errorf("Parse(Value(%+v)) = %+v, %v", p, got, err)
+	}
+	neg := chainparams.Value(&chainparams.Params{MaxBlockTxs: -1})
+	for _, v := range [][]byte{nil, neg, append(chainparams.Value(p), 0)} {
+		if _, err := chainparams.Parse(v); errors.Root(err) != chainparams.ErrParams {
+			t.Errorf("Parse(%x): got error %v, want %v", v, err, chainparams.ErrParams)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	c := prottest.NewChain(t)
+	bb := c.BlockBuilder()
+	bb.Version = bc.CommitmentsVersion
+	bb.Params = &chainparams.Params{MaxBlockTxs: 2}
+
+	// The builder leaves out what does not fit.
+	b := prottest.MakeBlock(t, c, []*bc.Tx{logTx(t, c.InitialBlockHash, 1, nil), logTx(t, c.InitialBlockHash, 2, nil), logTx(t, c.InitialBlockHash, 3, nil)})
+	if len(b.Transactions) != 2 {
+		t.Fatalf("block has %d transactions, want 2", len(b.Transactions))
+	}
+	if p, err := chainparams.Of(b.BlockHeader); err != nil || p == nil || *p != *bb.Params {
+		t.Fatalf("block parameters %+v, %v, want %+v", p, err, bb.Params)
+	}
+
+	// A validator rejects a block that exceeds them.
+	big := *b.UnsignedBlock
+	big.Transactions = append(big.Transactions, logTx(t, c.InitialBlockHash, 3, nil))
+	big.Runlimit += big.Transactions[2].Runlimit
+	root := bc.TxMerkleRoot(big.Transactions)
+	h := *b.BlockHeader
+	h.TransactionsRoot = &root
+	big.BlockHeader = &h
+	if err := validation.BlockOnly(&big); errors.Root(err) != chainparams.ErrLimit {
+		t.Errorf("validating a block over the limit: got %v, want %v", err, chainparams.ErrLimit)
+	}
+
+	// The next block has the same parameters, even if the builder's
+	// change.
+	bb.Params = &chainparams.Params{MaxBlockTxs: 100}
+	b = prottest.MakeBlock(t, c, []*bc.Tx{logTx(t, c.InitialBlockHash, 4, nil), logTx(t, c.InitialBlockHash, 5, nil), logTx(t, c.InitialBlockHash, 6, nil)})
+	if p, _ := chainparams.Of(b.BlockHeader); len(b.Transactions) != 2 || p == nil || p.MaxBlockTxs != 2 {
+		t.Errorf("next block: %d transactions, parameters %+v", len(b.Transactions), p)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	c := prottest.NewChain(t)
+	bb := c.BlockBuilder()
+	bb.Version = bc.CommitmentsVersion
+	bb.Params = &chainparams.Params{MaxBlockTxs: 1}
+	prottest.MakeBlock(t, c, nil)
+
+	// The chain's block predicate needs no signatures, so any update
+	// with the next sequence number is approved.
+	u := &chainparams.Update{Params: chainparams.Params{Seq: 1, MaxBlockTxs: 3}}
+	prev := c.State()
+	update := logTx(t, c.InitialBlockHash, 1, u)
+	b := prottest.MakeBlock(t, c, []*bc.Tx{update, logTx(t, c.InitialBlockHash, 2, nil), logTx(t, c.InitialBlockHash, 3, nil)})
+	if p, _ := chainparams.Of(b.BlockHeader); len(b.Transactions) != 3 || p == nil || *p != u.Params {
+		t.Fatalf("block with update: %d transactions, parameters %+v", len(b.Transactions), p)
+	}
+	snapshot := state.Copy(prev)
+	if err := snapshot.ApplyBlock(b.UnsignedBlock); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without the update, the change is not approved.
+	noUpdate := &bc.UnsignedBlock{BlockHeader: b.BlockHeader, Transactions: b.Transactions[1:]}
+	snapshot = state.Copy(prev)
+	if err := snapshot.ApplyBlock(noUpdate); errors.Root(err) != chainparams.ErrUpdate {
+		t.Errorf("applying a block changing parameters without an update: got %v, want %v", err, chainparams.ErrUpdate)
+	}
+
+	// An update cannot be replayed.
+	if chainparams.Approved(update, b.BlockHeader, c.InitialBlockHash) != nil {
+		t.Error("update approved again")
+	}
+}
+
+func TestUpdateIntervalTimestamp(t *testing.T) {
+	c := prottest.NewChain(t)
+	bb := c.BlockBuilder()
+	bb.Version = bc.CommitmentsVersion
+	u := &chainparams.Update{Params: chainparams.Params{Seq: 1, BlockIntervalMS: 1000}}
+	tx := bc.NewCommitmentsTx(logTx(t, c.InitialBlockHash, 1, u))
+	slot := (c.State().TimestampMS()/1000 + 1) * 1000
+
+	// The new interval applies to the block approving it, so the
+	// block's timestamp must start one of its slots.
+	if err := bb.Start(c.State(), slot+1); err != nil {
+		t.Fatal(err)
+	}
+	if err := bb.AddTx(tx); errors.Root(err) != chainparams.ErrSlot {
+		t.Errorf("update off the slot boundary: got %v, want %v", err, chainparams.ErrSlot)
+	}
+	if err := bb.Start(c.State(), slot); err != nil {
+		t.Fatal(err)
+	}
+	if err := bb.AddTx(tx); err != nil {
+		t.Fatal(err)
+	}
+	ub, _, err := bb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := chainparams.Of(ub.BlockHeader); p == nil || *p != u.Params {
+		t.Errorf("block has parameters %+v, want %+v", p, u.Params)
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pred := &bc.Predicate{Version: 1, Quorum: 1, Pubkeys: [][]byte{pub}}
+	prev := &bc.BlockHeader{Version: bc.CommitmentsVersion, NextPredicate: pred}
+	initialBlockID := bc.NewHash([32]byte{1})
+
+	u := &chainparams.Update{Params: chainparams.Params{Seq: 1, MaxBlockBytes: 1000}}
+	if chainparams.Approved(logTx(t, initialBlockID, 1, u), prev, initialBlockID) != nil {
+		t.Error("unsigned update approved")
+	}
+	if err := u.Sign(pred, initialBlockID, prv); err != nil {
+		t.Fatal(err)
+	}
+	if got := chainparams.Approved(logTx(t, initialBlockID, 1, u), prev, initialBlockID); got == nil || got.Params != u.Params {
+		t.Errorf("signed update: approved %+v", got)
+	}
+	if err := u.Verify(pred, bc.NewHash([32]byte{2})); errors.Root(err) != chainparams.ErrQuorum {
+		t.Errorf("update for another blockchain: got %v, want %v", err, chainparams.ErrQuorum)
+	}
+
+	pub2, prv2, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weighted := bc.NewWeightedPredicate(2, []bc.Signer{{Pubkey: pub, Weight: 1}, {Pubkey: pub2, Weight: 2}})
+	u = &chainparams.Update{Params: chainparams.Params{Seq: 1, MaxBlockBytes: 1000}}
+	if err := u.Sign(weighted, initialBlockID, prv); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Verify(weighted, initialBlockID); errors.Root(err) != chainparams.ErrQuorum {
+		t.Errorf("weight 1 of 2: got %v, want %v", err, chainparams.ErrQuorum)
+	}
+	if err := u.Sign(weighted, initialBlockID, prv2); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Verify(weighted, initialBlockID); err != nil {
+		t.Errorf("weight 3 of 2: %v", err)
+	}
+}
+
+// logTx returns a transaction with a unique nonce that logs u, if it
+// is not nil.
+func logTx(t *testing.T, blockchainID bc.Hash, n int, u *chainparams.Update) *bc.Tx {
+	var src string
+	if u != nil {
+		data, err := asm.Disassemble(txvm.Encode(logdata.New(u).Tuple()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		src = data + " log "
+	}
+	exp := bc.Millis(time.Now().Add(time.Minute)) + uint64(n)
+	prog, err := asm.Assemble(fmt.Sprintf("%sx'%x' %d nonce finalize", src, blockchainID.Bytes(), exp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := bc.NewTx(prog, 3, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
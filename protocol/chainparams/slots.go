@@ -0,0 +1,110 @@
+package chainparams
+
+import (
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+)
+
+// Slot returns the number of the slot containing timestampMS, under
+// parameters with a block interval: slot n runs from n intervals
+// after the Unix epoch until n+1 intervals after it. A block's
+// timestamp must be the start of a slot, so a chain has at most one
+// block per slot, and the leader of the slot (see Leader) must sign
+// it.
+func (p *Params) Slot(timestampMS uint64) uint64 {
+	return timestampMS / uint64(p.BlockIntervalMS)
+}
+
+// SlotStart returns the timestamp at which slot begins.
+func (p *Params) SlotStart(slot uint64) uint64 {
+	return slot * uint64(p.BlockIntervalMS)
+}
+
+// Leader returns the index, among the signers of pred (see
+// bc.Predicate.Signers), of the signer that leads slot. Slots are
+// assigned to the signers round-robin, in order.
+func Leader(pred *bc.Predicate, slot uint64) (int, error) {
+	signers, _, err := pred.Signers()
+	if err != nil {
+		return 0, err
+	}
+	if len(signers) == 0 {
+		return 0, errors.WithDetail(ErrSlot, "no block signers to lead slots")
+	}
+	return int(slot % uint64(len(signers))), nil
+}
+
+// NextSlot returns the start of the first slot after afterMS led by
+// the signer at index signer of pred, or, if signer is negative, of
+// the first slot after afterMS.
+func (p *Params) NextSlot(pred *bc.Predicate, signer int, afterMS uint64) (uint64, error) {
+	slot := p.Slot(afterMS) + 1
+	if signer < 0 {
+		return p.SlotStart(slot), nil
+	}
+	signers, _, err := pred.Signers()
+	if err != nil {
+		return 0, err
+	}
+	n := uint64(len(signers))
+	if uint64(signer) >= n {
+		return 0, errors.WithDetailf(ErrSlot, "signer %d of %d", signer, n)
+	}
+	slot += (uint64(signer) + n - slot%n) % n
+	return p.SlotStart(slot), nil
+}
+
+// CheckSlot returns ErrSlot if b's parameters have a block interval
+// and b lacks the signature of its slot's leader among the signers
+// of pred, the previous block's next-block predicate. The signatures
+// themselves are checked by validation.BlockSig, which calls
+// CheckSlot once they are.
+func CheckSlot(b *bc.Block, pred *bc.Predicate) error {
+	p, err := Of(b.BlockHeader)
+	if err != nil || p == nil || p.BlockIntervalMS == 0 {
+		return err
+	}
+	slot := p.Slot(b.TimestampMs)
+	leader, err := Leader(pred, slot)
+	if err != nil {
+		return err
+	}
+	if leader < len(b.Arguments) {
+		if sig, ok := b.Arguments[leader].([]byte); ok && len(sig) > 0 {
+			return nil
+		}
+	}
+	return errors.WithDetailf(ErrSlot, "block %d in slot %d lacks the signature of its leader, signer %d", b.Height, slot, leader)
+}
+
+// MissedSlots returns, for each signer of prev's next-block
+// predicate, the number of slots it led between prev and b, in which
+// no block was made. It returns nil if b's parameters have no block
+// interval, and counts no slots missed if prev's have a different
+// one or none.
+func MissedSlots(b, prev *bc.BlockHeader) ([]int64, error) {
+	p, err := Of(b)
+	if err != nil || p == nil || p.BlockIntervalMS == 0 {
+		return nil, err
+	}
+	signers, _, err := prev.NextPredicate.Signers()
+	if err != nil || len(signers) == 0 {
+		return nil, err
+	}
+	first, last := p.Slot(prev.TimestampMs)+1, p.Slot(b.TimestampMs)
+	if pp, _ := Of(prev); pp == nil || pp.BlockIntervalMS != p.BlockIntervalMS || last <= first {
+		return make([]int64, len(signers)), nil
+	}
+	var (
+		n      = uint64(len(signers))
+		k      = last - first
+		missed = make([]int64, n)
+	)
+	for i := range missed {
+		missed[i] = int64(k / n)
+	}
+	for j := uint64(0); j < k%n; j++ {
+		missed[(first+j)%n]++
+	}
+	return missed, nil
+}
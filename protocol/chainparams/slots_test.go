@@ -0,0 +1,115 @@
+package chainparams_test
+
+import (
+	"reflect"
+	"testing"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/chainparams"
+	"i10r.io/protocol/logdata"
+	"i10r.io/protocol/txvm"
+)
+
+func slotHeader(p *chainparams.Params, ts uint64, pred *bc.Predicate) *bc.BlockHeader {
+	h := &bc.BlockHeader{Version: bc.CommitmentsVersion, TimestampMs: ts, NextPredicate: pred}
+	h.SetCommitments([]bc.Commitment{{Name: chainparams.Commitment, Value: chainparams.Value(p)}})
+	return h
+}
+
+func TestSlots(t *testing.T) {
+	p := &chainparams.Params{MaxBlockTxs: 10, BlockIntervalMS: 1000}
+	got, err := chainparams.Parse(chainparams.Value(p))
+	if err != nil || *got != *p {
+		t.Fatalf("Parse(Value(%+v)) = %+v, %v", p, got, err)
+	}
+	zero := append(chainparams.Value(&chainparams.Params{}), make([]byte, 8)...)
+	if _, err := chainparams.Parse(zero); errors.Root(err) != chainparams.ErrParams {
+		t.Errorf("Parse(zero interval): got error %v, want %v", err, chainparams.ErrParams)
+	}
+
+	pred := &bc.Predicate{Version: 1, Quorum: 1, Pubkeys: [][]byte{{1}, {2}, {3}}}
+	if s := p.Slot(5999); s != 5 {
+		t.Errorf("Slot(5999) = %d, want 5", s)
+	}
+	if l, err := chainparams.Leader(pred, 5); err != nil || l != 2 {
+		t.Errorf("Leader(5) = %d, %v, want 2", l, err)
+	}
+	for _, c := range []struct {
+		signer      int
+		after, want uint64
+	}{
+		{-1, 5000, 6000},
+		{-1, 5999, 6000},
+		{0, 5000, 6000},
+		{1, 5000, 7000},
+		{2, 5000, 8000},
+		{2, 4999, 5000},
+	} {
+		if got, err := p.NextSlot(pred, c.signer, c.after); err != nil || got != c.want {
+			t.Errorf("NextSlot(%d, %d) = %d, %v, want %d", c.signer, c.after, got, err, c.want)
+		}
+	}
+
+	// A block must start a slot.
+	b := &bc.UnsignedBlock{BlockHeader: slotHeader(p, 6000, pred)}
+	if err := p.Check(b); err != nil {
+		t.Error(err)
+	}
+	b.TimestampMs = 6001
+	if err := p.Check(b); errors.Root(err) != chainparams.ErrSlot {
+		t.Errorf("timestamp within a slot: got error %v, want %v", err, chainparams.ErrSlot)
+	}
+
+	// A block must be signed by its slot's leader.
+	prev := slotHeader(p, 2000, pred)
+	sb := &bc.Block{UnsignedBlock: &bc.UnsignedBlock{BlockHeader: slotHeader(p, 6000, pred)}}
+	sb.Arguments = []interface{}{[]byte{1}, nil, nil}
+	if err := chainparams.CheckSlot(sb, prev.NextPredicate); err != nil {
+		t.Errorf("block signed by the leader of slot 6: %v", err)
+	}
+	sb.Arguments = []interface{}{nil, []byte{1}, nil}
+	if err := chainparams.CheckSlot(sb, prev.NextPredicate); errors.Root(err) != chainparams.ErrSlot {
+		t.Errorf("block not signed by its leader: got error %v, want %v", err, chainparams.ErrSlot)
+	}
+
+	// Slots 3, 4, and 5 passed without blocks.
+	missed, err := chainparams.MissedSlots(sb.BlockHeader, prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1, 1, 1}; !reflect.DeepEqual(missed, want) {
+		t.Errorf("MissedSlots = %v, want %v", missed, want)
+	}
+	missed, _ = chainparams.MissedSlots(slotHeader(p, 8000, pred), sb.BlockHeader)
+	if want := []int64{0, 1, 0}; !reflect.DeepEqual(missed, want) {
+		t.Errorf("MissedSlots = %v, want %v", missed, want)
+	}
+	missed, _ = chainparams.MissedSlots(slotHeader(p, 7000, pred), sb.BlockHeader)
+	if want := []int64{0, 0, 0}; !reflect.DeepEqual(missed, want) {
+		t.Errorf("MissedSlots = %v, want %v", missed, want)
+	}
+}
+
+func TestUpdateInterval(t *testing.T) {
+	u := &chainparams.Update{Params: chainparams.Params{Seq: 1, BlockIntervalMS: 500}, Signatures: [][]byte{{1}}}
+	v, err := logdata.Decode(logdata.New(u))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := v.(*chainparams.Update); got.Params != u.Params {
+		t.Errorf("decoded %+v, want %+v", got.Params, u.Params)
+	}
+	id := bc.NewHash([32]byte{1})
+	plain := &chainparams.Update{Params: chainparams.Params{Seq: 1}}
+	if string(u.Message(id)) == string(plain.Message(id)) {
+		t.Error("update message does not cover the block interval")
+	}
+	if len(plain.Fields()) != 5 {
+		t.Errorf("update without interval has %d fields, want 5", len(plain.Fields()))
+	}
+	bad := append(plain.Fields()[:4:4], txvm.Int(0), txvm.Tuple{})
+	if _, err := logdata.Decode(&logdata.Entry{Type: chainparams.UpdateType, Fields: bad}); err == nil {
+		t.Error("decoded an update with a zero interval")
+	}
+}
@@ -0,0 +1,276 @@
+// Package checkpoint implements an optional finality layer.
+//
+// Every Params.Interval blocks, a quorum of checkpoint signers,
+// which need not be the block signers, signs the header of the block
+// at that height. Once a Checkpoint carries a quorum of signatures,
+// its block is final: a Chain with a Finality refuses any block that
+// contradicts it, and a node whose own chain contradicts a new
+// checkpoint reports ErrFinalized instead of accepting it.
+//
+// A light client that trusts a network's checkpoint keys can start
+// from the latest checkpoint instead of the initial block, checking
+// only the block signatures from there on; see Sync.
+package checkpoint
+
+import (
+	"sync"
+
+	"i10r.io/crypto/ed25519"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/validation"
+)
+
+var (
+	// ErrQuorum is returned for a checkpoint without enough valid
+	// signatures.
+	ErrQuorum = errors.New("not enough valid checkpoint signatures")
+
+	// ErrHeight is returned for a checkpoint at a height that is
+	// not a multiple of the checkpoint interval.
+	ErrHeight = errors.New("checkpoint at wrong height")
+
+	// ErrNetwork is returned for a checkpoint from another
+	// blockchain.
+	ErrNetwork = errors.New("checkpoint for another blockchain")
+
+	// ErrFinalized is returned for a block that contradicts a
+	// finalized checkpoint.
+	ErrFinalized = errors.New("block conflicts with finalized checkpoint")
+
+	// ErrChain is returned by Sync for blocks that do not follow
+	// from the checkpoint.
+	ErrChain = errors.New("blocks do not extend checkpoint")
+
+	// ErrParams is returned for checkpoint parameters whose quorum
+	// is not between one and the number of keys, or whose keys are
+	// malformed.
+	ErrParams = errors.New("invalid checkpoint parameters")
+)
+
+// Params configures checkpoints for a network. A zero Interval
+// disables them.
+type Params struct {
+	Interval uint64              `json:"interval"`
+	Quorum   int                 `json:"quorum"`
+	Pubkeys  []ed25519.PublicKey `json:"pubkeys"`
+}
+
+// Checkpoint is a block header signed by checkpoint signers.
+// Signatures is parallel to Params.Pubkeys; an empty entry is a key
+// that did not sign.
+type Checkpoint struct {
+	InitialBlockID bc.Hash              `json:"initial_block_id"`
+	Header         *bc.BlockHeader      `json:"header"`
+	Signatures     []chainjson.HexBytes `json:"signatures"`
+}
+
+// New returns an unsigned checkpoint for the block with the given
+// header on the blockchain with the given initial block.
+func New(initialBlockID bc.Hash, header *bc.BlockHeader) *Checkpoint {
+	return &Checkpoint{InitialBlockID: initialBlockID, Header: header}
+}
+
+// Height returns the height of the checkpointed block.
+func (cp *Checkpoint) Height() uint64 {
+	return cp.Header.Height
+}
+
+// BlockID returns the ID of the checkpointed block.
+func (cp *Checkpoint) BlockID() bc.Hash {
+	return cp.Header.Hash()
+}
+
+// SigningMessage returns the message checkpoint signers sign. It
+// covers the blockchain, the height, and the block ID.
+func (cp *Checkpoint) SigningMessage() []byte {
+	h := txvm.VMHash("Checkpoint", txvm.Encode(txvm.Tuple{
+		txvm.Bytes(cp.InitialBlockID.Bytes()),
+		txvm.Int(int64(cp.Height())),
+		txvm.Bytes(cp.BlockID().Bytes()),
+	}))
+	return h[:]
+}
+
+// Sign adds a signature with prv, which must be the private key for
+// one of params' pubkeys.
+func (cp *Checkpoint) Sign(params *Params, prv ed25519.PrivateKey) error {
+	pub := prv.Public().(ed25519.PublicKey)
+	if !params.HasKey(pub) {
+		return errors.New("key is not a checkpoint key")
+	}
+	return cp.AddSignature(params, pub, ed25519.Sign(prv, cp.SigningMessage()))
+}
+
+// AddSignature adds sig, a signature on cp's signing message by pub,
+// which must be one of params' pubkeys. The signature is not checked
+// until Verify.
+func (cp *Checkpoint) AddSignature(params *Params, pub ed25519.PublicKey, sig []byte) error {
+	for i, pk := range params.Pubkeys {
+		if string(pk) == string(pub) {
+			if len(cp.Signatures) != len(params.Pubkeys) {
+				sigs := make([]chainjson.HexBytes, len(params.Pubkeys))
+				copy(sigs, cp.Signatures)
+				cp.Signatures = sigs
+			}
+			cp.Signatures[i] = sig
+			return nil
+		}
+	}
+	return errors.New("key is not a checkpoint key")
+}
+
+// Check returns ErrParams if p enables checkpoints but has a quorum
+// of less than one or more than its number of keys, or a malformed
+// key. A checkpoint with no signatures must never be final.
+func (p *Params) Check() error {
+	if p.Interval == 0 {
+		return nil
+	}
+	if p.Quorum < 1 || p.Quorum > len(p.Pubkeys) {
+		return errors.WithDetailf(ErrParams, "quorum %d of %d keys", p.Quorum, len(p.Pubkeys))
+	}
+	for i, pk := range p.Pubkeys {
+		if len(pk) != ed25519.PublicKeySize {
+			return errors.WithDetailf(ErrParams, "key %d is %d bytes", i, len(pk))
+		}
+	}
+	return nil
+}
+
+// HasKey reports whether pub is one of p's pubkeys.
+func (p *Params) HasKey(pub ed25519.PublicKey) bool {
+	for _, pk := range p.Pubkeys {
+		if string(pk) == string(pub) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks that cp is a checkpoint, at one of params' heights,
+// of the blockchain with the given initial block, and that it
+// carries a quorum of valid signatures. It returns ErrParams for
+// params that fail Check.
+func (cp *Checkpoint) Verify(params *Params, initialBlockID bc.Hash) error {
+	if err := params.Check(); err != nil {
+		return err
+	}
+	if cp.InitialBlockID != initialBlockID {
+		return errors.WithDetailf(ErrNetwork, "initial block %x", cp.InitialBlockID.Bytes())
+	}
+	if cp.Header == nil {
+		return errors.WithDetail(ErrHeight, "no header")
+	}
+	if params.Interval == 0 || cp.Height()%params.Interval != 0 {
+		return errors.WithDetailf(ErrHeight, "height %d, interval %d", cp.Height(), params.Interval)
+	}
+	if len(cp.Signatures) > len(params.Pubkeys) {
+		return errors.WithDetailf(ErrQuorum, "%d signatures for %d keys", len(cp.Signatures), len(params.Pubkeys))
+	}
+	msg := cp.SigningMessage()
+	var n int
+	for i, sig := range cp.Signatures {
+		if len(sig) == 0 {
+			continue
+		}
+		if !ed25519.Verify(params.Pubkeys[i], msg, sig) {
+			return errors.WithDetailf(ErrQuorum, "bad signature for key %d", i)
+		}
+		n++
+	}
+	if n < params.Quorum {
+		return errors.WithDetailf(ErrQuorum, "%d of %d", n, params.Quorum)
+	}
+	return nil
+}
+
+// Finality keeps the latest finalized checkpoint of a blockchain.
+// It is safe for concurrent use.
+type Finality struct {
+	Params         Params
+	InitialBlockID bc.Hash
+
+	mu     sync.Mutex
+	latest *Checkpoint
+}
+
+// NewFinality returns a Finality, with no checkpoint yet, for the
+// blockchain with the given initial block. It returns ErrParams for
+// params that fail Check.
+func NewFinality(params Params, initialBlockID bc.Hash) (*Finality, error) {
+	if err := params.Check(); err != nil {
+		return nil, err
+	}
+	return &Finality{Params: params, InitialBlockID: initialBlockID}, nil
+}
+
+// Add verifies cp and makes it the latest checkpoint, unless f
+// already has one at the same or a greater height. A checkpoint at
+// the latest height for a different block is ErrFinalized: the
+// checkpoint signers have signed two forks.
+func (f *Finality) Add(cp *Checkpoint) error {
+	err := cp.Verify(&f.Params, f.InitialBlockID)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.latest != nil && cp.Height() <= f.latest.Height() {
+		if cp.Height() == f.latest.Height() && cp.BlockID() != f.latest.BlockID() {
+			return errors.WithDetailf(ErrFinalized, "two checkpoints at height %d", cp.Height())
+		}
+		return nil
+	}
+	f.latest = cp
+	return nil
+}
+
+// Latest returns the latest checkpoint, or nil if there is none.
+func (f *Finality) Latest() *Checkpoint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.latest
+}
+
+// CheckBlock returns ErrFinalized if the block with the given header
+// is at the height of the latest checkpoint but is not the
+// checkpointed block.
+func (f *Finality) CheckBlock(h *bc.BlockHeader) error {
+	cp := f.Latest()
+	if cp == nil || h.Height != cp.Height() {
+		return nil
+	}
+	if id := h.Hash(); id != cp.BlockID() {
+		return errors.WithDetailf(ErrFinalized, "block %x at height %d, checkpoint has %x", id.Bytes(), h.Height, cp.BlockID().Bytes())
+	}
+	return nil
+}
+
+// Sync checks that blocks, in order, follow from the checkpointed
+// block: each is at the next height, names the one before it as its
+// previous block, and is signed according to the previous block's
+// NextPredicate. Only the headers and signatures of the blocks are
+// checked, not their transactions. It returns the header of the last
+// block, or of the checkpoint if blocks is empty.
+//
+// The caller must have verified cp.
+func Sync(cp *Checkpoint, blocks []*bc.Block) (*bc.BlockHeader, error) {
+	prev := cp.Header
+	for _, b := range blocks {
+		if b.Height != prev.Height+1 {
+			return nil, errors.WithDetailf(ErrChain, "block at height %d follows height %d", b.Height, prev.Height)
+		}
+		if b.PreviousBlockId == nil || *b.PreviousBlockId != prev.Hash() {
+			return nil, errors.WithDetailf(ErrChain, "block %d does not follow the previous block", b.Height)
+		}
+		err := validation.BlockSig(b, prev.NextPredicate)
+		if err != nil {
+			return nil, errors.Wrapf(err, "block %d", b.Height)
+		}
+		prev = b.BlockHeader
+	}
+	return prev, nil
+}
@@ -0,0 +1,138 @@
+package checkpoint_test
+
+import (
+	"context"
+	"testing"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/checkpoint"
+	"i10r.io/protocol/prottest"
+)
+
+func testParams(t *testing.T) (checkpoint.Params, []ed25519.PrivateKey) {
+	params := checkpoint.Params{Interval: 2, Quorum: 2}
+	var prvs []ed25519.PrivateKey
+	for i := 0; i < 3; i++ {
+		pub, prv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params.Pubkeys = append(params.Pubkeys, pub)
+		prvs = append(prvs, prv)
+	}
+	return params, prvs
+}
+
+func TestVerify(t *testing.T) {
+	c := prottest.NewChain(t)
+	b2 := prottest.MakeBlock(t, c, nil)
+	b3 := prottest.MakeBlock(t, c, nil)
+	b4 := prottest.MakeBlock(t, c, nil)
+	params, prvs := testParams(t)
+
+	cp := checkpoint.New(c.InitialBlockHash, b2.BlockHeader)
+	cp.Sign(&params, prvs[0])
+	if err := cp.Verify(&params, c.InitialBlockHash); errors.Root(err) != checkpoint.ErrQuorum {
+		t.Errorf("1 of 2: got %v, want %v", err, checkpoint.ErrQuorum)
+	}
+	cp.Sign(&params, prvs[2])
+	if err := cp.Verify(&params, c.InitialBlockHash); err != nil {
+		t.Errorf("2 of 2: %v", err)
+	}
+	if err := cp.Verify(&params, b2.Hash()); errors.Root(err) != checkpoint.ErrNetwork {
+		t.Errorf("other network: got %v, want %v", err, checkpoint.ErrNetwork)
+	}
+	odd := params
+	odd.Interval = 3
+	if err := cp.Verify(&odd, c.InitialBlockHash); errors.Root(err) != checkpoint.ErrHeight {
+		t.Errorf("interval 3: got %v, want %v", err, checkpoint.ErrHeight)
+	}
+	_, other, _ := ed25519.GenerateKey(nil)
+	if err := cp.Sign(&params, other); err == nil {
+		t.Error("Sign with foreign key: got no error")
+	}
+
+	bad := []checkpoint.Params{
+		{Interval: 2, Quorum: 0, Pubkeys: params.Pubkeys},
+		{Interval: 2, Quorum: 4, Pubkeys: params.Pubkeys},
+		{Interval: 2, Quorum: 1, Pubkeys: []ed25519.PublicKey{params.Pubkeys[0][:31]}},
+	}
+	for i, p := range bad {
+		if err := p.Check(); errors.Root(err) != checkpoint.ErrParams {
+			t.Errorf("bad params %d: Check got %v, want %v", i, err, checkpoint.ErrParams)
+		}
+		if _, err := checkpoint.NewFinality(p, c.InitialBlockHash); errors.Root(err) != checkpoint.ErrParams {
+			t.Errorf("bad params %d: NewFinality got %v, want %v", i, err, checkpoint.ErrParams)
+		}
+		unsigned := checkpoint.New(c.InitialBlockHash, b2.BlockHeader)
+		if err := unsigned.Verify(&p, c.InitialBlockHash); errors.Root(err) != checkpoint.ErrParams {
+			t.Errorf("bad params %d: Verify got %v, want %v", i, err, checkpoint.ErrParams)
+		}
+	}
+
+	h, err := checkpoint.Sync(cp, []*bc.Block{b3, b4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Hash() != b4.Hash() {
+		t.Errorf("Sync ended at height %d, want 4", h.Height)
+	}
+	if _, err := checkpoint.Sync(cp, []*bc.Block{b4}); errors.Root(err) != checkpoint.ErrChain {
+		t.Errorf("Sync with gap: got %v, want %v", err, checkpoint.ErrChain)
+	}
+}
+
+func TestFinality(t *testing.T) {
+	ctx := context.Background()
+	c := prottest.NewChain(t)
+	b2 := prottest.MakeBlock(t, c, nil)
+	params, prvs := testParams(t)
+	f, err := checkpoint.NewFinality(params, c.InitialBlockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetFinality(f)
+
+	sign := func(h *bc.BlockHeader) *checkpoint.Checkpoint {
+		cp := checkpoint.New(c.InitialBlockHash, h)
+		cp.Sign(&params, prvs[0])
+		cp.Sign(&params, prvs[1])
+		return cp
+	}
+	if err := c.AddCheckpoint(ctx, sign(b2.BlockHeader)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A checkpoint of a different block at height 2 contradicts the
+	// chain.
+	fork := *b2.BlockHeader
+	fork.TimestampMs++
+	if err := c.AddCheckpoint(ctx, sign(&fork)); errors.Root(err) != checkpoint.ErrFinalized {
+		t.Errorf("checkpoint of fork: got %v, want %v", err, checkpoint.ErrFinalized)
+	}
+
+	// A checkpoint ahead of the chain makes it refuse a different
+	// block at that height.
+	prottest.MakeBlock(t, c, nil)
+	ub, snap, err := c.GenerateBlock(ctx, c.State().TimestampMS()+1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fork = *ub.BlockHeader
+	fork.TimestampMs++
+	if err := c.AddCheckpoint(ctx, sign(&fork)); err != nil {
+		t.Fatal(err)
+	}
+	b4, err := bc.SignBlock(ub, c.State().Header, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CommitAppliedBlock(ctx, b4, snap); errors.Root(err) != checkpoint.ErrFinalized {
+		t.Errorf("committing block contradicting checkpoint: got %v, want %v", err, checkpoint.ErrFinalized)
+	}
+	if c.Height() != 3 {
+		t.Errorf("height %d after refused block, want 3", c.Height())
+	}
+}
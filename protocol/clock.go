@@ -0,0 +1,152 @@
+package protocol
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/log"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/chainparams"
+)
+
+// ErrFutureBlock is returned by CommitBlock for a block whose
+// timestamp is further ahead of the chain's clock than its
+// TimePolicy allows.
+var ErrFutureBlock = errors.New("block timestamp too far in the future")
+
+// Clock tells the time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock is the Clock that reads the system's time.
+var SystemClock Clock = systemClock{}
+
+// ManualClock is a Clock that reads the time it was last set to, for
+// tests. It is safe for concurrent use.
+type ManualClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+// NewManualClock returns a ManualClock set to t.
+func NewManualClock(t time.Time) *ManualClock {
+	return &ManualClock{t: t}
+}
+
+// Now returns the time c is set to.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+// Set sets c to t.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	c.t = t
+	c.mu.Unlock()
+}
+
+// Advance moves c forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+// TimePolicy bounds the timestamps of the blocks a Chain accepts
+// from elsewhere by its own clock. Block timestamps are compared only
+// to each other in consensus, so these checks are local: they keep a
+// node from following a block signer whose clock runs ahead, and
+// warn the operator of a node whose clock runs behind.
+type TimePolicy struct {
+	// Clock is the chain's clock. If it is nil, SystemClock is used.
+	Clock Clock
+
+	// MaxFutureDrift is how far ahead of Clock a block's timestamp
+	// may be. Zero means any distance.
+	MaxFutureDrift time.Duration
+
+	// SkewWarning is how far ahead of Clock a block's timestamp, or
+	// the previous block's timestamp when making a block, may be
+	// before the chain logs a warning that its clock may be skewed
+	// (and should be checked against NTP). Zero means no warnings.
+	SkewWarning time.Duration
+}
+
+// SetTimePolicy sets the clock c uses and how it bounds block
+// timestamps. The default is SystemClock with no bounds.
+func (c *Chain) SetTimePolicy(p TimePolicy) {
+	c.timePolicy = p
+}
+
+// Now returns the time by c's clock.
+func (c *Chain) Now() time.Time {
+	if c.timePolicy.Clock == nil {
+		return SystemClock.Now()
+	}
+	return c.timePolicy.Clock.Now()
+}
+
+// NextTimestampMS returns the timestamp for a block made now on top
+// of the current state: the time by c's clock, or, if the previous
+// block's timestamp is not before that, one millisecond after it.
+// Under chain parameters with a block interval, it is instead the
+// start of the first slot at or after that time (see
+// chainparams.Params.Slot), which may be later than now.
+func (c *Chain) NextTimestampMS(ctx context.Context) uint64 {
+	s := c.State()
+	prevMS := s.TimestampMS()
+	nowMS := bc.Millis(c.Now())
+	ts := nowMS
+	if nowMS <= prevMS {
+		c.warnSkew(ctx, s.Height(), prevMS, nowMS)
+		ts = prevMS + 1
+	}
+	if s.Header == nil {
+		return ts
+	}
+	p, err := chainparams.Of(s.Header)
+	if err == nil && p == nil && c.bb.Version >= bc.CommitmentsVersion {
+		p = c.bb.Params // the parameters the next block introduces
+	}
+	if p != nil && p.BlockIntervalMS > 0 {
+		ts, _ = p.NextSlot(nil, -1, ts-1)
+	}
+	return ts
+}
+
+// checkTime checks the timestamp of a block from elsewhere against
+// c's clock.
+func (c *Chain) checkTime(ctx context.Context, h *bc.BlockHeader) error {
+	nowMS := bc.Millis(c.Now())
+	if h.TimestampMs <= nowMS {
+		return nil
+	}
+	ahead := time.Duration(h.TimestampMs-nowMS) * time.Millisecond
+	if max := c.timePolicy.MaxFutureDrift; max > 0 && ahead > max {
+		return errors.WithDetailf(ErrFutureBlock, "block %d is %s ahead of the clock, more than %s", h.Height, ahead, max)
+	}
+	c.warnSkew(ctx, h.Height, h.TimestampMs, nowMS)
+	return nil
+}
+
+// warnSkew logs a warning if the block at the given height, with
+// timestamp blockMS, is too far ahead of the clock's time nowMS.
+func (c *Chain) warnSkew(ctx context.Context, height, blockMS, nowMS uint64) {
+	warn := c.timePolicy.SkewWarning
+	if warn <= 0 || blockMS <= nowMS {
+		return
+	}
+	if ahead := time.Duration(blockMS-nowMS) * time.Millisecond; ahead > warn {
+		log.Printkv(ctx, "event", "clock-skew", "height", height, "ahead", ahead.String(),
+			"warning", "block is ahead of the local clock; check the clock's synchronization")
+	}
+}
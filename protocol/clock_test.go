@@ -0,0 +1,56 @@
+package protocol
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/prottest/memstore"
+	"i10r.io/protocol/state"
+	"i10r.io/testutil"
+)
+
+func TestTimePolicy(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c, b1 := newTestChain(t, now)
+	ub, _, err := c.GenerateBlock(ctx, bc.Millis(now.Add(2*time.Minute)), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	b2, err := bc.SignBlock(ub, b1.BlockHeader, nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	follower, err := NewChain(ctx, b1, memstore.New(), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	snapshot := state.Empty()
+	snapshot.ApplyBlock(b1.UnsignedBlock)
+	follower.setState(snapshot)
+	clock := NewManualClock(now)
+	follower.SetTimePolicy(TimePolicy{Clock: clock, MaxFutureDrift: time.Minute})
+
+	err = follower.CommitBlock(ctx, b2)
+	if errors.Root(err) != ErrFutureBlock {
+		t.Fatalf("CommitBlock 2 minutes early: got %v, want %v", err, ErrFutureBlock)
+	}
+	clock.Advance(90 * time.Second)
+	err = follower.CommitBlock(ctx, b2)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// A clock behind the latest block makes the next block follow it.
+	if got, want := follower.NextTimestampMS(ctx), b2.TimestampMs+1; got != want {
+		t.Errorf("NextTimestampMS behind the latest block = %d, want %d", got, want)
+	}
+	clock.Advance(time.Minute)
+	if got, want := follower.NextTimestampMS(ctx), bc.Millis(clock.Now()); got != want {
+		t.Errorf("NextTimestampMS = %d, want %d", got, want)
+	}
+}
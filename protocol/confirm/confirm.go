@@ -0,0 +1,341 @@
+/*
+Package confirm tracks the confirmation of transactions: which block
+each one is in, how deep under the tip that block is, and when it
+becomes final.
+
+A Tracker reads blocks from a Source in order, as an events.Stream
+does, and reports an Update for each watched transaction at every
+block: Confirmed, with the depth of the transaction's block, from the
+block that includes it on. If the source replaces blocks the tracker
+has read, as a node does that a client switches to after its old
+one committed to an abandoned fork, the tracker rolls back to the
+last block the two agree on and reports each watched transaction in
+a dropped block as Unconfirmed again. It is confirmed anew if the
+replacement blocks include it.
+
+If the source has a checkpoint.Finality (as a *protocol.Chain does
+after SetFinality), the tracker reports a watched transaction as
+Final once a finalized checkpoint covers its block, and stops
+watching it. A final block is never rolled back: a source that
+contradicts one gives checkpoint.ErrFinalized. So the tracker
+forgets the blocks before the latest final one, and the
+transactions in them it was not watching.
+*/
+package confirm
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/checkpoint"
+)
+
+// Status is the confirmation status of a transaction.
+type Status int
+
+const (
+	// Unconfirmed means no block read includes the transaction, or
+	// the block that did was replaced.
+	Unconfirmed Status = iota
+
+	// Confirmed means a block includes the transaction.
+	Confirmed
+
+	// Final means a finalized checkpoint covers the block that
+	// includes the transaction.
+	Final
+)
+
+var statusNames = []string{"unconfirmed", "confirmed", "final"}
+
+func (s Status) String() string {
+	if s < 0 || int(s) >= len(statusNames) {
+		return "unknown"
+	}
+	return statusNames[s]
+}
+
+// MarshalText satisfies the TextMarshaler interface.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Update reports the status of a watched transaction. Height and
+// BlockID are those of the block that includes it, and Depth is the
+// number of blocks from that one to the tip, counting both, so the
+// tip's transactions have depth 1. They are zero for an Unconfirmed
+// update.
+type Update struct {
+	TxID    bc.Hash `json:"tx_id"`
+	Status  Status  `json:"status"`
+	Height  uint64  `json:"height,omitempty"`
+	BlockID bc.Hash `json:"block_id"`
+	Depth   uint64  `json:"depth,omitempty"`
+}
+
+// Source is where a Tracker reads blocks. A *protocol.Chain is a
+// Source, as is any events.Source.
+type Source interface {
+	Height() uint64
+	GetBlock(ctx context.Context, height uint64) (*bc.Block, error)
+	BlockWaiter(height uint64) <-chan struct{}
+}
+
+// finalitySource is a Source that knows the finalized checkpoints.
+type finalitySource interface {
+	Finality() *checkpoint.Finality
+}
+
+// block is what a Tracker remembers of a block it has read.
+type block struct {
+	id  bc.Hash
+	txs []bc.Hash
+}
+
+// txBlock is the height and ID of the block that includes a
+// transaction.
+type txBlock struct {
+	height uint64
+	id     bc.Hash
+}
+
+// Tracker tracks the confirmation of the transactions it watches.
+// It is not safe for concurrent use.
+type Tracker struct {
+	src   Source
+	base  uint64  // the height before the first block in chain
+	final uint64  // the latest final height, or the starting height
+	chain []block // the blocks read, from height base+1
+
+	// blocks holds the block of each tx in chain and of each
+	// watched tx in a block dropped from it.
+	blocks  map[bc.Hash]txBlock
+	watch   map[bc.Hash]bool
+	pending []Update
+}
+
+// New returns a Tracker that reads the blocks of src after the given
+// height. It trusts the blocks up to that height, so it cannot see
+// them replaced, and it does not find watched transactions in them.
+func New(src Source, after uint64) *Tracker {
+	return &Tracker{
+		src:    src,
+		base:   after,
+		final:  after,
+		blocks: make(map[bc.Hash]txBlock),
+		watch:  make(map[bc.Hash]bool),
+	}
+}
+
+// Height returns the height of the last block t has read.
+func (t *Tracker) Height() uint64 {
+	return t.base + uint64(len(t.chain))
+}
+
+// Watch makes t report updates for the transaction with the given
+// ID. If a block already read includes it, the next Read reports it
+// at once.
+func (t *Tracker) Watch(txID bc.Hash) {
+	t.watch[txID] = true
+	if b, ok := t.blocks[txID]; ok {
+		t.pending = append(t.pending, t.update(txID, b))
+		if b.height <= t.final {
+			delete(t.watch, txID)
+		}
+	}
+}
+
+// Unwatch cancels Watch for txID.
+func (t *Tracker) Unwatch(txID bc.Hash) {
+	delete(t.watch, txID)
+	if b, ok := t.blocks[txID]; ok && b.height <= t.base {
+		delete(t.blocks, txID)
+	}
+}
+
+// Status returns the status of the transaction with the given ID,
+// and the height of its block if it is confirmed. Of transactions
+// in blocks before the latest final one, it knows only those that
+// were watched when the tracker read that final block.
+func (t *Tracker) Status(txID bc.Hash) (Status, uint64) {
+	b, ok := t.blocks[txID]
+	switch {
+	case !ok:
+		return Unconfirmed, 0
+	case b.height <= t.final:
+		return Final, b.height
+	}
+	return Confirmed, b.height
+}
+
+// Read reads updates into ups, waiting until at least one is
+// available or ctx is done, and returns the number read. It reads
+// more than one only if they are available without waiting.
+func (t *Tracker) Read(ctx context.Context, ups []Update) (int, error) {
+	var n int
+	for n < len(ups) {
+		if len(t.pending) == 0 {
+			if t.Height() >= t.src.Height() && n > 0 {
+				break
+			}
+			err := t.next(ctx)
+			if err != nil {
+				return n, err
+			}
+			continue
+		}
+		ups[n] = t.pending[0]
+		t.pending = t.pending[1:]
+		n++
+	}
+	return n, nil
+}
+
+// next reads the next block, waiting for it if necessary. If the
+// block does not follow the last one read, it rolls back instead.
+func (t *Tracker) next(ctx context.Context) error {
+	h := t.Height() + 1
+	if h > t.src.Height() {
+		select {
+		case <-t.src.BlockWaiter(h):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	b, err := t.src.GetBlock(ctx, h)
+	if err != nil {
+		return errors.Wrapf(err, "getting block %d", h)
+	}
+	if len(t.chain) > 0 && (b.PreviousBlockId == nil || *b.PreviousBlockId != t.chain[len(t.chain)-1].id) {
+		return t.rollback(ctx)
+	}
+
+	blk := block{id: b.Hash()}
+	for _, tx := range b.Transactions {
+		blk.txs = append(blk.txs, tx.ID)
+		t.blocks[tx.ID] = txBlock{height: h, id: blk.id}
+	}
+	t.chain = append(t.chain, blk)
+	return t.checkFinality(ctx)
+}
+
+// checkFinality advances the final height to the source's latest
+// checkpoint, if t has read its block, then reports every watched
+// transaction and prunes the blocks before the final one.
+func (t *Tracker) checkFinality(ctx context.Context) error {
+	cp := t.latest()
+	if cp != nil && cp.Height() > t.final && cp.Height() <= t.Height() {
+		if t.block(cp.Height()).id != cp.BlockID() {
+			// The source has moved to the checkpointed fork.
+			h := t.Height()
+			err := t.rollback(ctx)
+			if err == nil && t.Height() == h {
+				err = errors.WithDetailf(checkpoint.ErrFinalized, "source has block %d but checkpoint has another", cp.Height())
+			}
+			return err
+		}
+		t.final = cp.Height()
+	}
+	var ups []Update
+	for id := range t.watch {
+		if b, ok := t.blocks[id]; ok {
+			ups = append(ups, t.update(id, b))
+		}
+	}
+	sort.Slice(ups, func(i, j int) bool {
+		if ups[i].Height != ups[j].Height {
+			return ups[i].Height < ups[j].Height
+		}
+		return bytes.Compare(ups[i].TxID.Bytes(), ups[j].TxID.Bytes()) < 0
+	})
+	t.prune()
+	for _, u := range ups {
+		if u.Status == Final {
+			delete(t.watch, u.TxID)
+		}
+	}
+	t.pending = append(t.pending, ups...)
+	return nil
+}
+
+// prune drops the blocks before the final one, which can never be
+// rolled back, and the unwatched transactions in them. It keeps the
+// final block, so that a source that replaces it is still noticed.
+func (t *Tracker) prune() {
+	if t.final <= t.base+1 {
+		return
+	}
+	n := int(t.final - t.base - 1)
+	for i := range t.chain[:n] {
+		for _, id := range t.chain[i].txs {
+			if !t.watch[id] {
+				delete(t.blocks, id)
+			}
+		}
+		t.chain[i] = block{}
+	}
+	t.chain = t.chain[n:]
+	t.base += uint64(n)
+}
+
+// rollback drops the blocks, from the tip down, that the source no
+// longer has, reporting the watched transactions in them as
+// Unconfirmed.
+func (t *Tracker) rollback(ctx context.Context) error {
+	for t.Height() > t.base {
+		h := t.Height()
+		if h <= t.src.Height() {
+			b, err := t.src.GetBlock(ctx, h)
+			if err != nil {
+				return errors.Wrapf(err, "getting block %d", h)
+			}
+			if b.Hash() == t.block(h).id {
+				return nil
+			}
+		}
+		if h <= t.final {
+			return errors.WithDetailf(checkpoint.ErrFinalized, "source replaced final block %d", h)
+		}
+		for _, id := range t.block(h).txs {
+			delete(t.blocks, id)
+			if t.watch[id] {
+				t.pending = append(t.pending, Update{TxID: id, Status: Unconfirmed})
+			}
+		}
+		t.chain = t.chain[:len(t.chain)-1]
+	}
+	return nil
+}
+
+// latest returns the source's latest checkpoint, or nil.
+func (t *Tracker) latest() *checkpoint.Checkpoint {
+	if fs, ok := t.src.(finalitySource); ok {
+		if f := fs.Finality(); f != nil {
+			return f.Latest()
+		}
+	}
+	return nil
+}
+
+func (t *Tracker) block(height uint64) *block {
+	return &t.chain[height-t.base-1]
+}
+
+// update returns the update for the transaction with the given ID,
+// which is in block b.
+func (t *Tracker) update(txID bc.Hash, b txBlock) Update {
+	u := Update{
+		TxID:    txID,
+		Status:  Confirmed,
+		Height:  b.height,
+		BlockID: b.id,
+		Depth:   t.Height() - b.height + 1,
+	}
+	if b.height <= t.final {
+		u.Status = Final
+	}
+	return u
+}
@@ -0,0 +1,257 @@
+package confirm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/checkpoint"
+	"i10r.io/testutil"
+)
+
+// testSource is a Source whose blocks can be replaced.
+type testSource struct {
+	blocks   []*bc.Block
+	finality *checkpoint.Finality
+}
+
+func (s *testSource) Height() uint64 {
+	return uint64(len(s.blocks))
+}
+
+func (s *testSource) GetBlock(_ context.Context, height uint64) (*bc.Block, error) {
+	if height == 0 || height > s.Height() {
+		return nil, errors.New("no such block")
+	}
+	return s.blocks[height-1], nil
+}
+
+func (s *testSource) BlockWaiter(height uint64) <-chan struct{} {
+	c := make(chan struct{})
+	if height <= s.Height() {
+		close(c)
+	}
+	return c
+}
+
+func (s *testSource) Finality() *checkpoint.Finality {
+	return s.finality
+}
+
+// add adds a block, with a transaction for each of txids, after the
+// first height blocks of s, replacing any after them. Blocks made
+// with different forks differ.
+func (s *testSource) add(height uint64, fork byte, txids ...bc.Hash) {
+	h := &bc.BlockHeader{Height: height + 1, TimestampMs: uint64(fork), NextPredicate: new(bc.Predicate)}
+	if height > 0 {
+		prev := s.blocks[height-1].Hash()
+		h.PreviousBlockId = &prev
+	}
+	var txs []*bc.Tx
+	for _, id := range txids {
+		txs = append(txs, &bc.Tx{ID: id})
+	}
+	s.blocks = append(s.blocks[:height], &bc.Block{UnsignedBlock: &bc.UnsignedBlock{BlockHeader: h, Transactions: txs}})
+}
+
+// readAll reads t's updates until it waits for a block.
+func readAll(t *testing.T, tr *Tracker) []Update {
+	var ups []Update
+	buf := make([]Update, 5)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		n, err := tr.Read(ctx, buf)
+		cancel()
+		ups = append(ups, buf[:n]...)
+		if err == context.DeadlineExceeded {
+			return ups
+		}
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+}
+
+// summary gives the status and depth of each update, as in
+// "confirmed 2".
+func summary(ups []Update) []string {
+	var s []string
+	for _, u := range ups {
+		s = append(s, fmt.Sprintf("%s %d", u.Status, u.Depth))
+	}
+	return s
+}
+
+func TestReorg(t *testing.T) {
+	tx1, tx2 := bc.NewHash([32]byte{1}), bc.NewHash([32]byte{2})
+	src := new(testSource)
+	src.add(0, 0)
+	src.add(1, 0, tx1)
+	src.add(2, 0, tx2)
+
+	tr := New(src, 1)
+	tr.Watch(tx1)
+	tr.Watch(tx2)
+	got := summary(readAll(t, tr))
+	want := []string{"confirmed 1", "confirmed 2", "confirmed 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// Replace blocks 2 and 3 with a longer fork that has tx2 but not
+	// tx1.
+	src.add(1, 1)
+	src.add(2, 1, tx2)
+	src.add(3, 1)
+	ups := readAll(t, tr)
+	got = summary(ups)
+	want = []string{"unconfirmed 0", "unconfirmed 0", "confirmed 1", "confirmed 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("after reorg: got %v, want %v", got, want)
+	}
+	if ups[2].TxID != tx2 || ups[2].Height != 3 || ups[2].BlockID != src.blocks[2].Hash() {
+		t.Errorf("after reorg: tx2 update %+v", ups[2])
+	}
+	if s, _ := tr.Status(tx1); s != Unconfirmed {
+		t.Errorf("tx1 status %s, want unconfirmed", s)
+	}
+	if s, h := tr.Status(tx2); s != Confirmed || h != 3 {
+		t.Errorf("tx2 status %s at %d, want confirmed at 3", s, h)
+	}
+}
+
+func TestFinality(t *testing.T) {
+	tx1, tx2 := bc.NewHash([32]byte{1}), bc.NewHash([32]byte{2})
+	src := new(testSource)
+	src.add(0, 0)
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := checkpoint.Params{Interval: 2, Quorum: 1, Pubkeys: []ed25519.PublicKey{pub}}
+	src.finality, err = checkpoint.NewFinality(params, src.blocks[0].Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.add(1, 0, tx1)
+	src.add(2, 0, tx2)
+
+	tr := New(src, 0)
+	tr.Watch(tx1)
+	tr.Watch(tx2)
+	readAll(t, tr)
+
+	cp := checkpoint.New(src.blocks[0].Hash(), src.blocks[1].BlockHeader)
+	if err := cp.Sign(&params, prv); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.finality.Add(cp); err != nil {
+		t.Fatal(err)
+	}
+	src.add(3, 0)
+	got := summary(readAll(t, tr))
+	want := []string{"final 3", "confirmed 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// tx1 is no longer watched, and its block cannot be replaced.
+	src.add(1, 1)
+	src.add(2, 1)
+	src.add(3, 1)
+	src.add(4, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = tr.Read(ctx, make([]Update, 10))
+	if errors.Root(err) != checkpoint.ErrFinalized {
+		t.Errorf("replacing a final block: got %v, want %v", err, checkpoint.ErrFinalized)
+	}
+
+	// The rollback stopped at the final block, dropping tx2's block.
+	// Watching a final transaction reports it at once.
+	tr.Watch(tx1)
+	ups := make([]Update, 2)
+	if _, err := tr.Read(ctx, ups); err != nil || ups[0].TxID != tx2 || ups[0].Status != Unconfirmed || ups[1].TxID != tx1 || ups[1].Status != Final {
+		t.Errorf("after rollback: got %+v, %v", ups, err)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	src := new(testSource)
+	src.add(0, 0)
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := checkpoint.Params{Interval: 10, Quorum: 1, Pubkeys: []ed25519.PublicKey{pub}}
+	src.finality, err = checkpoint.NewFinality(params, src.blocks[0].Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txs []bc.Hash
+	for h := uint64(1); h < 12; h++ {
+		tx := bc.NewHash([32]byte{byte(h)})
+		txs = append(txs, tx)
+		src.add(h, 0, tx)
+	}
+
+	tr := New(src, 0)
+	tr.Watch(txs[2]) // in block 4
+	readAll(t, tr)
+	if len(tr.chain) != 12 || len(tr.blocks) != 11 {
+		t.Fatalf("before finality: %d blocks, %d txs, want 12 and 11", len(tr.chain), len(tr.blocks))
+	}
+
+	cp := checkpoint.New(src.blocks[0].Hash(), src.blocks[9].BlockHeader)
+	if err := cp.Sign(&params, prv); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.finality.Add(cp); err != nil {
+		t.Fatal(err)
+	}
+	src.add(12, 0)
+	got := summary(readAll(t, tr))
+	want := []string{"final 10"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// Blocks 1 to 9 are dropped, with the transactions in them but
+	// the watched one. Block 10 is final.
+	if len(tr.chain) != 4 || len(tr.blocks) != 4 {
+		t.Errorf("after finality: %d blocks, %d txs, want 4 and 4", len(tr.chain), len(tr.blocks))
+	}
+	if s, h := tr.Status(txs[2]); s != Final || h != 4 {
+		t.Errorf("watched tx status %s at %d, want final at 4", s, h)
+	}
+	if s, _ := tr.Status(txs[3]); s != Unconfirmed {
+		t.Errorf("dropped tx status %s, want unconfirmed", s)
+	}
+	if s, h := tr.Status(txs[8]); s != Final || h != 10 {
+		t.Errorf("tx in final block status %s at %d, want final at 10", s, h)
+	}
+	tr.Watch(txs[2])
+	ups := make([]Update, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := tr.Read(ctx, ups); err != nil || ups[0].Status != Final || ups[0].BlockID != src.blocks[3].Hash() || ups[0].Depth != 10 {
+		t.Errorf("watching again: got %+v, %v", ups[0], err)
+	}
+	tr.Unwatch(txs[2])
+	if s, _ := tr.Status(txs[2]); s != Unconfirmed {
+		t.Errorf("unwatched dropped tx status %s, want unconfirmed", s)
+	}
+
+	// Replacing the final block is still noticed.
+	for h := uint64(9); h < 14; h++ {
+		src.add(h, 1)
+	}
+	if _, err := tr.Read(ctx, ups); errors.Root(err) != checkpoint.ErrFinalized {
+		t.Errorf("replacing the final block: got %v, want %v", err, checkpoint.ErrFinalized)
+	}
+}
@@ -0,0 +1,269 @@
+// Package consensus fingerprints the consensus rules compiled into a
+// build, so that nodes running divergent builds can notice before
+// they disagree about a block.
+//
+// The fingerprint covers the opcode table, the results and runlimit
+// costs of a fixed set of txvm programs exercising the instruction
+// set, the encodings and hashes of a sample transaction and block,
+// and the protocol version constants. Two builds with the same
+// fingerprint may still differ in rules the probes do not reach, but
+// a build that changes any of those things, by accident or not,
+// gets a different one.
+package consensus
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/patricia"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/op"
+)
+
+// ErrMismatch is returned by Check for a fingerprint other than this
+// build's.
+var ErrMismatch = errors.New("consensus version mismatch")
+
+// probeRunlimit is the runlimit each probe runs with. It covers the
+// most expensive probe, checkgroth16's.
+const probeRunlimit = 10000000
+
+// probeNetwork is the blockchain each probe runs for, to which the
+// IDs of transactions of version txvm.NetworkVersion or later commit.
+var probeNetwork = bc.HashFromBytes(bytes.Repeat([]byte{2}, 32))
+
+// probe is a txvm program run to fingerprint the VM's behavior.
+type probe struct {
+	version int64
+	src     string
+}
+
+// probes exercise each family of instructions. Each must succeed.
+var probes = []probe{
+	{3, "1 2 add 3 mul 4 div 5 mod 2 gt not 1 and 0 or verify -7 neg drop"},
+	{3, "1 2 3 2 roll 1 bury 3 reverse depth drop drop drop drop 5 dup eq verify 4 5 1 peek drop drop drop"},
+	{3, "'abc' dup sha3 drop dup sha256 drop 'f' vmhash drop"},
+	{3, "'abc' 'def' cat 1 4 slice len 3 eq verify 'abc' 'abc' eq verify"},
+	{3, "{1, 'a', {2}} dup encode len drop dup len 3 eq verify 2 field untuple drop drop"},
+	{3, "x'01' bitnot x'03' bitand x'05' bitor x'06' bitxor drop"},
+	{3, "[1 2 add drop] exec [self drop caller drop contractprogram drop 'inner' log] contract seed drop call"},
+	{3, "0 100 timerange 'memo' log 0 peeklog drop 'id' 10 nonce splitzero merge amount drop assetid drop anchor drop finalize txid drop"},
+	{txvm.ExtOpsVersion, "x'01' x'02' bigadd x'03' bigcmp 0 eq verify x'05' x'02' bigmul x'03' bigmod drop x'02' x'0a' x'07' bigexpmod drop inputcount drop outputcount drop loglen drop stacklen drop"},
+	{txvm.ExtOpsVersion, fmt.Sprintf("100 x'%s' x'%s' checkcommitment verify -100 x'%[1]s' x'%[2]s' checkcommitment not verify", probeBlinding, probeCommitment)},
+	{txvm.ExtOpsVersion, fmt.Sprintf("x'%s' x'%s' x'%s' 1 checksig verify x'%[1]s' x'%[4]s' recoversecp256k1 x'%[2]s' eq verify", probeMessage, probeSecp256k1Pub, probeSecp256k1Sig[:128], probeSecp256k1Sig)},
+	{txvm.ExtOpsVersion, fmt.Sprintf("'consensus' x'%s' x'%s' 2 checksig verify 'consensus' {x'%[1]s'} x'%[2]s' checkblsagg verify", probeBLSPub, probeBLSSig)},
+	{txvm.ExtOpsVersion, fmt.Sprintf("x'%s' x'%s' {x'%064x'} checkgroth16 verify", probeGroth16Key, probeGroth16Proof, 5)},
+	{txvm.NetworkVersion, "'id' 10 nonce finalize"},
+}
+
+// Fixed inputs of the probes of the cryptographic instructions: a
+// Pedersen commitment to 100 with the blinding factor 12345, encoded
+// as probeBlinding; a secp256k1 key and recoverable signature of
+// probeMessage; a BLS key and signature of "consensus"; and a
+// Groth16 verifying key and a proof for the public input 5.
+const (
+	probeBlinding     = "3930000000000000000000000000000000000000000000000000000000000000"
+	probeMessage      = "0707070707070707070707070707070707070707070707070707070707070707"
+	probeCommitment   = "cbcce34af43a4188452ab08e6d7404d78ac0d1ff17e361572bbf262e0ec256db"
+	probeSecp256k1Pub = "025cbdf0646e5db4eaa398f365f2ea7a0e3d419b7e0330e39ce92bddedcac4f9bc"
+	probeSecp256k1Sig = "71cad82a372e078b1e8f2d32cc9f325a7425718bdf2f7288f3809105649dfae940fc9e3802ef8c7abef1bd6f569b185205597157da9a71178b3e0b677b8f131701"
+	probeBLSPub       = "aa1a1c26055a329817a5759d877a2795f9499b97d6056edde0eea39512f24e8bc874b4471f0501127abb1ea0d9f68ac1"
+	probeBLSSig       = "b3d243fdd20d9a13da97a48ea9b3598e27add7dbc067134d24ce6a8ad96dffaded9b4cdc87edd139452b2907194acf0f" +
+		"121796e0be0c8aa4cd7c94eede99b2b7103dedbf59a14efad55ac72a53418fb7b8278c73c3ffaf7e8817cbfef9a05301"
+	probeGroth16Key = "97f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb" +
+		"93e02b6052719f607dacd3a088274f65596bd0d09920b61ab5da61bbdc7f5049334cf11213945d57e5ac7d055d042b7e" +
+		"024aa2b2f08f0a91260805272dc51051c6e47ad4fa403b02b4510b647ae3d1770bac0326a805bbefd48056c8c121bdb8" +
+		"93e02b6052719f607dacd3a088274f65596bd0d09920b61ab5da61bbdc7f5049334cf11213945d57e5ac7d055d042b7e" +
+		"024aa2b2f08f0a91260805272dc51051c6e47ad4fa403b02b4510b647ae3d1770bac0326a805bbefd48056c8c121bdb8" +
+		"aa4edef9c1ed7f729f520e47730a124fd70662a904ba1074728114d1031e1572c6c886f6b57ec72a6178288c47c33577" +
+		"1638533957d540a9d2370f17cc7ed5863bc0b995b8825e0ee1ea1e1e4d00dbae81f14b0bf3611b78c952aacab827a053" +
+		"97f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb" +
+		"97f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb"
+	probeGroth16Proof = "99cdf3807146e68e041314ca93e1fee0991224ec2a74beb2866816fd0826ce7b6263ee31e953a86d1b72cc2215a57793" +
+		"93e02b6052719f607dacd3a088274f65596bd0d09920b61ab5da61bbdc7f5049334cf11213945d57e5ac7d055d042b7e" +
+		"024aa2b2f08f0a91260805272dc51051c6e47ad4fa403b02b4510b647ae3d1770bac0326a805bbefd48056c8c121bdb8" +
+		"97f1d3a73197d7942695638c4fa9ac0fc3688c4f9774b905a14e3a3f171bac586c55e83ff97a1aeffb3af00adb22c6bb"
+)
+
+var (
+	once    sync.Once
+	version bc.Hash
+	parts   map[string]bc.Hash
+)
+
+// Version returns the fingerprint of this build's consensus rules.
+func Version() bc.Hash {
+	once.Do(compute)
+	return version
+}
+
+// Components returns the fingerprints of the parts that make up
+// Version, by name, to help find where two builds differ.
+func Components() map[string]bc.Hash {
+	once.Do(compute)
+	res := make(map[string]bc.Hash, len(parts))
+	for k, v := range parts {
+		res[k] = v
+	}
+	return res
+}
+
+// Check returns ErrMismatch, with details, if remote is not this
+// build's fingerprint.
+func Check(remote bc.Hash) error {
+	return CheckWith(nil, remote)
+}
+
+// VersionWith returns the fingerprint of this build's consensus
+// rules extended with the plugin operations p (see txvm.Plugins). With
+// nil p it is Version.
+func VersionWith(p *txvm.Plugins) bc.Hash {
+	if p == nil {
+		return Version()
+	}
+	h := p.Hash()
+	return fingerprint(txvm.Tuple{txvm.Bytes(Version().Bytes()), txvm.Bytes(h[:])})
+}
+
+// CheckWith is Check for a chain run with the plugin operations p.
+func CheckWith(p *txvm.Plugins, remote bc.Hash) error {
+	if local := VersionWith(p); remote != local {
+		return errors.WithDetailf(ErrMismatch, "local %x, remote %x", local.Bytes(), remote.Bytes())
+	}
+	return nil
+}
+
+func compute() {
+	parts = map[string]bc.Hash{
+		"opcodes":   fingerprint(opcodes()),
+		"execution": fingerprint(execution()),
+		"encoding":  fingerprint(encoding()),
+		"versions":  fingerprint(versions()),
+	}
+	var all txvm.Tuple
+	for _, name := range []string{"opcodes", "execution", "encoding", "versions"} {
+		all = append(all, txvm.Bytes(name), txvm.Bytes(parts[name].Bytes()))
+	}
+	version = fingerprint(all)
+}
+
+func fingerprint(t txvm.Tuple) bc.Hash {
+	return bc.NewHash(txvm.VMHash("ConsensusVersion", txvm.Encode(t)))
+}
+
+// opcodes describes the opcode table: the name of each opcode.
+func opcodes() txvm.Tuple {
+	var t txvm.Tuple
+	for i := 0; i < op.MinPushdata; i++ {
+		t = append(t, txvm.Bytes(op.Name(byte(i))))
+	}
+	return t
+}
+
+// execution describes the outcome of each probe: the runlimit it
+// used and its log.
+func execution() txvm.Tuple {
+	var t txvm.Tuple
+	for _, p := range probes {
+		vm, used, err := runProbe(p)
+		if err != nil {
+			// A probe that fails in this build is itself a
+			// divergence; record that rather than panic.
+			t = append(t, txvm.Bytes("fail"))
+			continue
+		}
+		var log txvm.Tuple
+		for _, entry := range vm.Log {
+			log = append(log, entry)
+		}
+		t = append(t, txvm.Tuple{txvm.Int(used), log, txvm.Bytes(vm.TxID[:])})
+	}
+	return t
+}
+
+func runProbe(p probe) (vm *txvm.VM, used int64, err error) {
+	prog, err := asm.Assemble(p.src)
+	if err != nil {
+		return nil, 0, err
+	}
+	var left int64
+	vm, err = txvm.Validate(prog, p.version, probeRunlimit, txvm.GetRunlimit(&left), bc.NetworkOption(probeNetwork))
+	return vm, probeRunlimit - left, err
+}
+
+// encoding describes the serialization and hashing of a sample
+// transaction and block and of the commitment trees.
+func encoding() txvm.Tuple {
+	t := txvm.Tuple{
+		txvm.Bytes(txvm.Encode(txvm.Tuple{txvm.Int(-1), txvm.Int(1 << 40), txvm.Bytes("x"), txvm.Tuple{txvm.Tuple{}}})),
+	}
+
+	// A deterministic key, so that the signature in the sample
+	// transaction is fixed.
+	pub, prv, err := ed25519.GenerateKey(bytes.NewReader(make([]byte, 32)))
+	if err != nil {
+		panic(err)
+	}
+	msg := []byte("consensus")
+	src := fmt.Sprintf("'consensus' x'%x' x'%x' 0 checksig verify 'id' 10 nonce finalize", []byte(pub), ed25519.Sign(prv, msg))
+	prog, err := asm.Assemble(src)
+	if err != nil {
+		panic(err)
+	}
+	tx, err := bc.NewTx(prog, 3, probeRunlimit)
+	if err != nil {
+		t = append(t, txvm.Bytes("fail"))
+		return t
+	}
+	txroot := bc.TxMerkleRoot([]*bc.Tx{tx})
+	prev := bc.HashFromBytes(bytes.Repeat([]byte{1}, 32))
+	b := &bc.Block{
+		UnsignedBlock: &bc.UnsignedBlock{
+			BlockHeader: &bc.BlockHeader{
+				Version:          3,
+				Height:           2,
+				PreviousBlockId:  &prev,
+				TimestampMs:      5,
+				Runlimit:         probeRunlimit,
+				TransactionsRoot: &txroot,
+				ContractsRoot:    &prev,
+				NoncesRoot:       &prev,
+				NextPredicate:    &bc.Predicate{Version: 1, Quorum: 1, Pubkeys: [][]byte{pub}},
+			},
+			Transactions: []*bc.Tx{tx},
+		},
+	}
+	bits, err := b.Bytes()
+	if err != nil {
+		panic(err)
+	}
+	hash := b.Hash()
+	t = append(t, txvm.Bytes(tx.ID.Bytes()), txvm.Bytes(bits), txvm.Bytes(hash.Bytes()))
+
+	tree := new(patricia.Tree)
+	for _, item := range []string{"a", "b", "c"} {
+		h := txvm.VMHash("item", []byte(item))
+		tree.Insert(h[:])
+	}
+	root := tree.RootHash()
+	return append(t, txvm.Bytes(root[:]))
+}
+
+// versions lists the protocol version constants and the range of
+// plugin selectors.
+func versions() txvm.Tuple {
+	return txvm.Tuple{
+		txvm.Int(txvm.ExtOpsVersion),
+		txvm.Int(txvm.MinPluginSelector),
+		txvm.Int(txvm.MaxPluginSelector),
+		txvm.Int(txvm.NetworkVersion),
+		txvm.Int(bc.NetworkVersion),
+		txvm.Int(bc.CommitmentsVersion),
+		txvm.Int(bc.CommitmentsAreaVersion),
+	}
+}
@@ -0,0 +1,76 @@
+package consensus
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+)
+
+func TestProbes(t *testing.T) {
+	for _, p := range probes {
+		if _, _, err := runProbe(p); err != nil {
+			t.Errorf("probe %q: %v", p.src, err)
+		}
+	}
+	if enc := encoding(); len(enc) != 5 {
+		t.Errorf("sample transaction failed: %v", enc)
+	}
+	for name, h := range Components() {
+		if h == fingerprint(nil) {
+			t.Errorf("component %s is empty", name)
+		}
+	}
+}
+
+// TestVersion ensures the consensus rules do not change without the
+// change being noticed. If this test fails, the build no longer
+// agrees with earlier builds about some block or transaction. Unless
+// that is intended, and coordinated with a protocol upgrade, the
+// change must be undone.
+func TestVersion(t *testing.T) {
+	want := bc.HashFromBytes(mustDecodeHex("1ff63876bc5ebfe3ae335fc90923225629e39304a1d4e105ab121598e903af33"))
+	if got := Version(); got != want {
+		t.Errorf("Version() = %x, want %x", got.Bytes(), want.Bytes())
+		if enc := encoding(); len(enc) != 5 {
+			t.Errorf("sample transaction failed: %v", enc)
+		}
+		for name, h := range Components() {
+			t.Logf("%s: %x", name, h.Bytes())
+		}
+	}
+	if err := Check(want); err != nil {
+		t.Error(err)
+	}
+	if err := Check(bc.Hash{}); errors.Root(err) != ErrMismatch {
+		t.Errorf("Check(zero) = %v, want %v", err, ErrMismatch)
+	}
+
+	p, err := txvm.NewPlugins(txvm.PluginOp{
+		Selector: txvm.MinPluginSelector,
+		Name:     "nop",
+		Func:     func([]txvm.Data) ([]txvm.Data, error) { return nil, nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VersionWith(nil) != want {
+		t.Error("VersionWith(nil) differs from Version")
+	}
+	if err := CheckWith(p, want); errors.Root(err) != ErrMismatch {
+		t.Errorf("CheckWith(plugins, Version()) = %v, want %v", err, ErrMismatch)
+	}
+	if err := CheckWith(p, VersionWith(p)); err != nil {
+		t.Error(err)
+	}
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,436 @@
+/*
+Package abi defines a small interface definition language for
+contracts, and generates Go bindings from it, so that application
+code calling a contract is type-checked.
+
+An interface file describes one contract: the clauses it can be
+called with, the arguments each expects, and the typed log entries
+(see package logdata) it emits. For example:
+
+	// The standard escrow contract.
+	contract Escrow "standard escrow"
+	seed "e1497e5e821afab437adbe98610685e0d31fea2dff15451ad5230dfcc69dd4a3"
+
+	type Party {quorum int, pubkeys [bytes]}
+	type Terms {buyer Party, seller Party, arbiter Party, deadline int}
+
+	clause Fund(refdata bytes, v value, terms Terms)
+	clause Step(sigs ...bytes, action int)
+
+	log Settled "escrow.settled" (action int, amount int)
+
+Arguments are listed from the bottom of the argument stack. Their
+types are
+
+	int       an int, an int64 in Go
+	bytes     a string, a []byte in Go
+	string    a string, a string in Go
+	hash      a 32-byte string, a bc.Hash in Go
+	value     a value, which the caller supplies
+	T         a tuple of the fields of the type T, declared with "type"
+	[T]       a tuple of any number of Ts
+	...T      any number of Ts, each a separate argument
+
+where value and ...T are allowed only as clause arguments. Comments
+run from "//" to the end of the line.
+
+Generate writes Go bindings for an interface: for each type a struct,
+for each clause a function writing the bytecode that puts its
+arguments on the argument stack, and for each log entry a struct
+implementing logdata.Value, registered with logdata so tools can
+decode it. Command abigen runs it on an interface file.
+*/
+package abi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+	"text/scanner"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/contracts"
+)
+
+// ErrSyntax is returned by Parse for an interface file that is
+// malformed or inconsistent.
+var ErrSyntax = errors.New("interface syntax error")
+
+// Kind is the kind of a Type.
+type Kind int
+
+// The kinds of types.
+const (
+	Int Kind = iota
+	Bytes
+	String
+	Hash
+	Value
+	Struct
+	List
+	Variadic
+)
+
+var kindNames = map[string]Kind{
+	"int":    Int,
+	"bytes":  Bytes,
+	"string": String,
+	"hash":   Hash,
+	"value":  Value,
+}
+
+// Type is the type of an argument or field.
+type Type struct {
+	Kind Kind
+
+	// Name is the name of a Struct type.
+	Name string
+
+	// Elem is the element type of a List or Variadic type.
+	Elem *Type
+}
+
+// String returns t as written in an interface file.
+func (t *Type) String() string {
+	switch t.Kind {
+	case Struct:
+		return t.Name
+	case List:
+		return "[" + t.Elem.String() + "]"
+	case Variadic:
+		return "..." + t.Elem.String()
+	}
+	for name, k := range kindNames {
+		if k == t.Kind {
+			return name
+		}
+	}
+	return "invalid"
+}
+
+// Field is a named argument or field.
+type Field struct {
+	Name string
+	Type *Type
+}
+
+// Arg returns f as a contracts.Arg.
+func (f *Field) Arg() contracts.Arg {
+	return contracts.Arg{Name: f.Name, Type: f.Type.String()}
+}
+
+// TypeDecl declares a tuple type.
+type TypeDecl struct {
+	Name   string
+	Fields []*Field
+}
+
+// Clause is a way of calling the contract.
+type Clause struct {
+	Name string
+
+	// Args are the clause's arguments, from the bottom of the
+	// argument stack.
+	Args []*Field
+}
+
+// Log is a typed log entry the contract emits.
+type Log struct {
+	Name string
+
+	// Type is the entry's type tag (see logdata.Entry).
+	Type   string
+	Fields []*Field
+}
+
+// Interface is the parsed form of an interface file.
+type Interface struct {
+	Name string
+
+	// Title is the contract's human-readable name. It defaults to
+	// Name.
+	Title string
+
+	// Seed is the contract's seed, or zero if the file does not
+	// give it.
+	Seed bc.Hash
+
+	Types   []*TypeDecl
+	Clauses []*Clause
+	Logs    []*Log
+}
+
+// Contract returns the contracts registry entry for the interface,
+// whose arguments are those of its first clause, which creates the
+// contract.
+func (iface *Interface) Contract() *contracts.Contract {
+	c := &contracts.Contract{Name: iface.Title, Seed: iface.Seed}
+	if len(iface.Clauses) > 0 {
+		for _, a := range iface.Clauses[0].Args {
+			c.Args = append(c.Args, a.Arg())
+		}
+	}
+	return c
+}
+
+func (iface *Interface) typeDecl(name string) *TypeDecl {
+	for _, t := range iface.Types {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Parse parses the interface file in r. The name is used in error
+// messages.
+func Parse(r io.Reader, name string) (iface *Interface, err error) {
+	p := &parser{iface: new(Interface)}
+	p.s.Init(r)
+	p.s.Filename = name
+	p.s.Mode = scanner.ScanIdents | scanner.ScanInts | scanner.ScanStrings | scanner.ScanComments | scanner.SkipComments
+	p.s.Error = func(s *scanner.Scanner, msg string) {
+		p.errorf("%s", msg)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(syntaxError)
+			if !ok {
+				panic(r)
+			}
+			iface, err = nil, errors.WithDetail(ErrSyntax, string(e))
+		}
+	}()
+	p.next()
+	p.parse()
+	p.check()
+	return p.iface, nil
+}
+
+type syntaxError string
+
+type parser struct {
+	s     scanner.Scanner
+	tok   rune
+	iface *Interface
+}
+
+func (p *parser) errorf(format string, args ...interface{}) {
+	panic(syntaxError(p.s.Position.String() + ": " + fmt.Sprintf(format, args...)))
+}
+
+func (p *parser) next() {
+	p.tok = p.s.Scan()
+}
+
+func (p *parser) expect(tok rune) {
+	if p.tok != tok {
+		p.errorf("got %s, want %s", p.s.TokenText(), scanner.TokenString(tok))
+	}
+	p.next()
+}
+
+func (p *parser) ident() string {
+	if p.tok != scanner.Ident {
+		p.errorf("got %s, want identifier", p.s.TokenText())
+	}
+	name := p.s.TokenText()
+	if token.Lookup(name).IsKeyword() {
+		p.errorf("%s is a Go keyword", name)
+	}
+	p.next()
+	return name
+}
+
+func (p *parser) str() string {
+	if p.tok != scanner.String {
+		p.errorf("got %s, want string", p.s.TokenText())
+	}
+	s, err := strconv.Unquote(p.s.TokenText())
+	if err != nil {
+		p.errorf("%s", err)
+	}
+	p.next()
+	return s
+}
+
+func (p *parser) keyword() string {
+	if p.tok != scanner.Ident {
+		p.errorf("got %s, want declaration", p.s.TokenText())
+	}
+	kw := p.s.TokenText()
+	p.next()
+	return kw
+}
+
+func (p *parser) parse() {
+	iface := p.iface
+	if p.keyword() != "contract" {
+		p.errorf("interface must begin with contract declaration")
+	}
+	iface.Name = p.ident()
+	iface.Title = iface.Name
+	if p.tok == scanner.String {
+		iface.Title = p.str()
+	}
+	for p.tok != scanner.EOF {
+		switch kw := p.keyword(); kw {
+		case "seed":
+			seed, err := hex.DecodeString(p.str())
+			if err != nil || len(seed) != 32 {
+				p.errorf("seed is not 32 bytes in hex")
+			}
+			iface.Seed = bc.HashFromBytes(seed)
+		case "type":
+			t := &TypeDecl{Name: p.ident()}
+			t.Fields = p.fields('{', '}')
+			iface.Types = append(iface.Types, t)
+		case "clause":
+			c := &Clause{Name: p.ident()}
+			c.Args = p.fields('(', ')')
+			iface.Clauses = append(iface.Clauses, c)
+		case "log":
+			l := &Log{Name: p.ident(), Type: p.str()}
+			l.Fields = p.fields('(', ')')
+			iface.Logs = append(iface.Logs, l)
+		default:
+			p.errorf("unknown declaration %s", kw)
+		}
+	}
+}
+
+func (p *parser) fields(open, close rune) []*Field {
+	var fields []*Field
+	p.expect(open)
+	for p.tok != close {
+		if len(fields) > 0 {
+			p.expect(',')
+		}
+		f := &Field{Name: p.ident()}
+		f.Type = p.typ()
+		fields = append(fields, f)
+	}
+	p.next()
+	return fields
+}
+
+func (p *parser) typ() *Type {
+	switch p.tok {
+	case '[':
+		p.next()
+		t := &Type{Kind: List, Elem: p.typ()}
+		p.expect(']')
+		return t
+	case '.':
+		for i := 0; i < 3; i++ {
+			p.expect('.')
+		}
+		return &Type{Kind: Variadic, Elem: p.typ()}
+	}
+	name := p.ident()
+	if k, ok := kindNames[name]; ok {
+		return &Type{Kind: k}
+	}
+	return &Type{Kind: Struct, Name: name}
+}
+
+// reserved are the names that generated clause functions use, which
+// their arguments cannot.
+var reserved = map[string]bool{"b": true, "e": true, "op": true, "txvm": true}
+
+// check checks that names are unique and types are defined and used
+// where they are allowed.
+func (p *parser) check() {
+	iface := p.iface
+	names := map[string]bool{iface.Name: true}
+	unique := func(what, name string) {
+		if names[name] {
+			p.errorf("%s %s: name already declared", what, name)
+		}
+		names[name] = true
+	}
+	for _, t := range iface.Types {
+		unique("type", t.Name)
+	}
+	for _, c := range iface.Clauses {
+		unique("clause", iface.Name+c.Name)
+	}
+	types := make(map[string]bool)
+	for _, l := range iface.Logs {
+		unique("log", l.Name)
+		if types[l.Type] {
+			p.errorf("log %s: type tag %q already declared", l.Name, l.Type)
+		}
+		types[l.Type] = true
+	}
+
+	for _, t := range iface.Types {
+		p.checkFields("type "+t.Name, t.Fields, false)
+	}
+	for _, c := range iface.Clauses {
+		p.checkFields("clause "+c.Name, c.Args, true)
+		for _, a := range c.Args {
+			if reserved[a.Name] {
+				p.errorf("clause %s: argument name %s is reserved", c.Name, a.Name)
+			}
+		}
+	}
+	for _, l := range iface.Logs {
+		p.checkFields("log "+l.Name, l.Fields, false)
+		for _, f := range l.Fields {
+			if n := strings.ToLower(f.Name); n == "type" || n == "fields" {
+				p.errorf("log %s: field name %s is reserved", l.Name, f.Name)
+			}
+		}
+	}
+	for _, t := range iface.Types {
+		p.checkCycle(t, nil)
+	}
+}
+
+func (p *parser) checkFields(what string, fields []*Field, isClause bool) {
+	names := make(map[string]bool)
+	for _, f := range fields {
+		if names[strings.ToLower(f.Name)] {
+			p.errorf("%s: duplicate field %s", what, f.Name)
+		}
+		names[strings.ToLower(f.Name)] = true
+		p.checkType(what, f.Type, isClause)
+	}
+}
+
+func (p *parser) checkType(what string, t *Type, top bool) {
+	switch t.Kind {
+	case Value, Variadic:
+		if !top {
+			p.errorf("%s: %s is allowed only as a clause argument", what, t)
+		}
+	case Struct:
+		if p.iface.typeDecl(t.Name) == nil {
+			p.errorf("%s: undefined type %s", what, t.Name)
+		}
+	}
+	if t.Elem != nil {
+		p.checkType(what, t.Elem, false)
+	}
+}
+
+// checkCycle reports a type that contains itself other than through
+// a list.
+func (p *parser) checkCycle(t *TypeDecl, path []string) {
+	for _, name := range path {
+		if name == t.Name {
+			p.errorf("type %s contains itself", t.Name)
+		}
+	}
+	path = append(path, t.Name)
+	for _, f := range t.Fields {
+		if f.Type.Kind == Struct {
+			p.checkCycle(p.iface.typeDecl(f.Type.Name), path)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package abi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/contracts"
+)
+
+// TestGenerate checks that the bindings in package example are up to
+// date.
+func TestGenerate(t *testing.T) {
+	f, err := os.Open("example/escrow.abi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	iface, err := Parse(f, "escrow.abi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Generate(iface, "example", "escrow.abi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("example/escrow_abi.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("example/escrow_abi.go is out of date; run go generate in example")
+	}
+
+	wantArgs := []contracts.Arg{{Name: "refdata", Type: "bytes"}, {Name: "v", Type: "value"}, {Name: "terms", Type: "Terms"}}
+	c := iface.Contract()
+	if c.Name != "standard escrow" || c.Seed != iface.Seed || !reflect.DeepEqual(c.Args, wantArgs) {
+		t.Errorf("Contract() = %+v, want args %v", c, wantArgs)
+	}
+}
+
+func TestParse(t *testing.T) {
+	iface, err := Parse(strings.NewReader(`
+		contract C // no title
+		type T {a [[int]], b hash}
+		clause X(v value, w value, ts ...T, s string)
+		log L "c.l" ()
+	`), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if iface.Title != "C" || !iface.Seed.IsZero() {
+		t.Errorf("got title %q, seed %x", iface.Title, iface.Seed.Bytes())
+	}
+	var types []string
+	for _, a := range iface.Clauses[0].Args {
+		types = append(types, a.Type.String())
+	}
+	for _, f := range iface.Types[0].Fields {
+		types = append(types, f.Type.String())
+	}
+	want := []string{"value", "value", "...T", "string", "[[int]]", "hash"}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("got types %v, want %v", types, want)
+	}
+	_, err = Generate(iface, "c", "test")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []string{
+		``,
+		`type T {}`,
+		`contract C seed "00"`,
+		`contract C clause X(a int`,
+		`contract C clause X(a int b int)`,
+		`contract C clause X(a U)`,
+		`contract C type T {a value}`,
+		`contract C type T {a ...int}`,
+		`contract C log L "l" (a [value])`,
+		`contract C log L "l" (type int)`,
+		`contract C clause X(b int)`,
+		`contract C clause X(func int)`,
+		`contract C clause X(a int, A bytes)`,
+		`contract C type T {t T}`,
+		`contract C type T {u U} type U {t T}`,
+		`contract C type T {} type T {}`,
+		`contract C log L "l" () log M "l" ()`,
+		`contract C frob`,
+		`contract C clause X(a int) "unterminated`,
+	}
+	for _, c := range cases {
+		_, err := Parse(strings.NewReader(c), "test")
+		if errors.Root(err) != ErrSyntax {
+			t.Errorf("Parse(%q): got error %v, want %v", c, err, ErrSyntax)
+		}
+	}
+}
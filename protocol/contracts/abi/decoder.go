@@ -0,0 +1,118 @@
+package abi
+
+import (
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/logdata"
+	"i10r.io/protocol/txvm"
+)
+
+// Decoder reads the items of a tuple in order, for generated
+// bindings. It records the first error, after which its methods
+// return zero values. Decoders for nested tuples share it.
+type Decoder struct {
+	t   txvm.Tuple
+	i   int
+	err *error
+}
+
+// NewDecoder returns a Decoder reading t.
+func NewDecoder(t txvm.Tuple) *Decoder {
+	return &Decoder{t: t, err: new(error)}
+}
+
+// Err returns the first error d or a decoder for a nested tuple
+// encountered. Its root is logdata.ErrFields.
+func (d *Decoder) Err() error {
+	return *d.err
+}
+
+func (d *Decoder) fail(format string, args ...interface{}) {
+	if *d.err == nil {
+		*d.err = errors.WithDetailf(logdata.ErrFields, format, args...)
+	}
+}
+
+func (d *Decoder) item(want string) txvm.Data {
+	if *d.err != nil {
+		return nil
+	}
+	if d.i >= len(d.t) {
+		d.fail("missing %s at item %d", want, d.i)
+		return nil
+	}
+	item := d.t[d.i]
+	d.i++
+	return item
+}
+
+// More tells whether d has items left to read and no error.
+func (d *Decoder) More() bool {
+	return *d.err == nil && d.i < len(d.t)
+}
+
+// End records an error if d has items left to read.
+func (d *Decoder) End() {
+	if d.More() {
+		d.fail("%d extra items", len(d.t)-d.i)
+	}
+}
+
+// Int reads an int.
+func (d *Decoder) Int() int64 {
+	item := d.item("int")
+	if item == nil {
+		return 0
+	}
+	n, ok := item.(txvm.Int)
+	if !ok {
+		d.fail("item %d is not an int", d.i-1)
+	}
+	return int64(n)
+}
+
+// Bytes reads a string.
+func (d *Decoder) Bytes() []byte {
+	item := d.item("string")
+	if item == nil {
+		return nil
+	}
+	b, ok := item.(txvm.Bytes)
+	if !ok {
+		d.fail("item %d is not a string", d.i-1)
+	}
+	return b
+}
+
+// Text reads a string as a Go string.
+func (d *Decoder) Text() string {
+	return string(d.Bytes())
+}
+
+// Hash reads a 32-byte string.
+func (d *Decoder) Hash() bc.Hash {
+	b := d.Bytes()
+	if *d.err != nil {
+		return bc.Hash{}
+	}
+	if len(b) != 32 {
+		d.fail("item %d is not 32 bytes", d.i-1)
+		return bc.Hash{}
+	}
+	return bc.HashFromBytes(b)
+}
+
+// Tuple reads a tuple and returns a decoder for its items.
+func (d *Decoder) Tuple() *Decoder {
+	sub := &Decoder{err: d.err}
+	item := d.item("tuple")
+	if item == nil {
+		return sub
+	}
+	t, ok := item.(txvm.Tuple)
+	if !ok {
+		d.fail("item %d is not a tuple", d.i-1)
+	}
+	sub.t = t
+	return sub
+}
@@ -0,0 +1,6 @@
+// Package example holds Go bindings generated from an example
+// interface file, escrow.abi, describing the standard escrow
+// contract.
+package example
+
+//go:generate go run ../../../../cmd/abigen/main.go escrow.abi
@@ -0,0 +1,172 @@
+// Code generated by abigen from escrow.abi. DO NOT EDIT.
+
+package example
+
+import (
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/contracts/abi"
+	"i10r.io/protocol/logdata"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+// EscrowSeed is the seed of the standard escrow contract.
+var EscrowSeed = bc.NewHash([32]byte{0xe1, 0x49, 0x7e, 0x5e, 0x82, 0x1a, 0xfa, 0xb4, 0x37, 0xad, 0xbe, 0x98, 0x61, 0x06, 0x85, 0xe0, 0xd3, 0x1f, 0xea, 0x2d, 0xff, 0x15, 0x45, 0x1a, 0xd5, 0x23, 0x0d, 0xfc, 0xc6, 0x9d, 0xd4, 0xa3})
+
+// Party is the {quorum int, pubkeys [bytes]} type of the standard escrow contract.
+type Party struct {
+	Quorum  int64
+	Pubkeys [][]byte
+}
+
+func (x *Party) tuple() txvm.Tuple {
+	return txvm.Tuple{
+		txvm.Int(x.Quorum),
+		encodeEscrowBytesList(x.Pubkeys),
+	}
+}
+
+func (x *Party) decode(d *abi.Decoder) {
+	x.Quorum = d.Int()
+	x.Pubkeys = decodeEscrowBytesList(d.Tuple())
+	d.End()
+}
+
+// Terms is the {buyer Party, seller Party, arbiter Party, deadline int} type of the standard escrow contract.
+type Terms struct {
+	Buyer    Party
+	Seller   Party
+	Arbiter  Party
+	Deadline int64
+}
+
+func (x *Terms) tuple() txvm.Tuple {
+	return txvm.Tuple{
+		x.Buyer.tuple(),
+		x.Seller.tuple(),
+		x.Arbiter.tuple(),
+		txvm.Int(x.Deadline),
+	}
+}
+
+func (x *Terms) decode(d *abi.Decoder) {
+	x.Buyer.decode(d.Tuple())
+	x.Seller.decode(d.Tuple())
+	x.Arbiter.decode(d.Tuple())
+	x.Deadline = d.Int()
+	d.End()
+}
+
+// EscrowFund writes txvm bytecode to b that puts the arguments of
+// the Fund clause of the standard escrow contract on the argument stack.
+// The caller must leave the value v on top of the contract stack.
+func EscrowFund(b *txvmutil.Builder, refdata []byte, terms *Terms) {
+	b.Concat(txvm.Encode(txvm.Bytes(refdata))).Op(op.Put)
+	b.Op(op.Put) // v
+	b.Concat(txvm.Encode(terms.tuple())).Op(op.Put)
+}
+
+// EscrowStep writes txvm bytecode to b that puts the arguments of
+// the Step clause of the standard escrow contract on the argument stack.
+func EscrowStep(b *txvmutil.Builder, sigs [][]byte, action int64) {
+	for _, e := range sigs {
+		b.Concat(txvm.Encode(txvm.Bytes(e))).Op(op.Put)
+	}
+	b.Concat(txvm.Encode(txvm.Int(action))).Op(op.Put)
+}
+
+// Type tags of the log entries of the standard escrow contract.
+const (
+	SettledType = "escrow.settled"
+)
+
+func init() {
+	logdata.Register(&logdata.Schema{
+		Type: SettledType,
+		Doc:  "standard escrow Settled: {action int, amount int, anchor hash, parties [Party]}",
+		Decode: func(fields txvm.Tuple) (logdata.Value, error) {
+			x, err := DecodeSettled(fields)
+			if err != nil {
+				return nil, err
+			}
+			return x, nil
+		},
+	})
+}
+
+// Settled is the {action int, amount int, anchor hash, parties [Party]} log entry of the standard escrow contract.
+type Settled struct {
+	Action  int64
+	Amount  int64
+	Anchor  bc.Hash
+	Parties []Party
+}
+
+func (x *Settled) tuple() txvm.Tuple {
+	return txvm.Tuple{
+		txvm.Int(x.Action),
+		txvm.Int(x.Amount),
+		txvm.Bytes(x.Anchor.Bytes()),
+		encodeEscrowPartyList(x.Parties),
+	}
+}
+
+func (x *Settled) decode(d *abi.Decoder) {
+	x.Action = d.Int()
+	x.Amount = d.Int()
+	x.Anchor = d.Hash()
+	x.Parties = decodeEscrowPartyList(d.Tuple())
+	d.End()
+}
+
+// Type implements logdata.Value.
+func (x *Settled) Type() string { return SettledType }
+
+// Fields implements logdata.Value.
+func (x *Settled) Fields() txvm.Tuple { return x.tuple() }
+
+// DecodeSettled decodes the fields of a Settled log entry.
+func DecodeSettled(fields txvm.Tuple) (*Settled, error) {
+	x := new(Settled)
+	d := abi.NewDecoder(fields)
+	x.decode(d)
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func encodeEscrowBytesList(l [][]byte) txvm.Tuple {
+	t := make(txvm.Tuple, 0, len(l))
+	for _, e := range l {
+		t = append(t, txvm.Bytes(e))
+	}
+	return t
+}
+
+func decodeEscrowBytesList(d *abi.Decoder) [][]byte {
+	var l [][]byte
+	for d.More() {
+		l = append(l, d.Bytes())
+	}
+	return l
+}
+
+func encodeEscrowPartyList(l []Party) txvm.Tuple {
+	t := make(txvm.Tuple, 0, len(l))
+	for _, e := range l {
+		t = append(t, e.tuple())
+	}
+	return t
+}
+
+func decodeEscrowPartyList(d *abi.Decoder) []Party {
+	var l []Party
+	for d.More() {
+		var e Party
+		e.decode(d.Tuple())
+		l = append(l, e)
+	}
+	return l
+}
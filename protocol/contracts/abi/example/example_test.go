@@ -0,0 +1,88 @@
+package example
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/logdata"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+	"i10r.io/protocol/txvm/txvmutil"
+	"i10r.io/testutil"
+)
+
+// TestStandard checks the bindings against the standard escrow
+// contract's own builders.
+func TestStandard(t *testing.T) {
+	if EscrowSeed != bc.NewHash(standard.EscrowSeed) {
+		t.Errorf("EscrowSeed is %x, want %x", EscrowSeed.Bytes(), standard.EscrowSeed[:])
+	}
+
+	pubkeys := []ed25519.PublicKey{testutil.TestPub}
+	st := &standard.EscrowTerms{
+		BuyerQuorum:    1,
+		BuyerPubkeys:   pubkeys,
+		SellerQuorum:   1,
+		SellerPubkeys:  pubkeys,
+		ArbiterQuorum:  0,
+		ArbiterPubkeys: nil,
+		DeadlineMS:     1000,
+	}
+	party := Party{Quorum: 1, Pubkeys: [][]byte{testutil.TestPub}}
+	terms := &Terms{Buyer: party, Seller: party, Arbiter: Party{}, Deadline: 1000}
+
+	var want, got, tail txvmutil.Builder
+	standard.FundEscrow(&want, st)
+	tail.PushdataBytes(standard.EscrowProg).Op(op.Contract).Op(op.Call)
+	EscrowFund(&got, []byte("refdata"), terms)
+	wantProg := bytes.TrimSuffix(want.Build(), tail.Build())
+	if !bytes.HasSuffix(got.Build(), wantProg) {
+		t.Errorf("EscrowFund wrote %x, want it to end with %x", got.Build(), wantProg)
+	}
+
+	sigs := [][]byte{[]byte("sig"), nil}
+	want, got = txvmutil.Builder{}, txvmutil.Builder{}
+	standard.SpendEscrow(&want, st, 1, bc.Hash{}, nil, false, standard.EscrowRelease, sigs)
+	EscrowStep(&got, sigs, int64(standard.EscrowRelease))
+	if !bytes.HasPrefix(want.Build(), got.Build()) {
+		t.Errorf("EscrowStep wrote %x, want a prefix of %x", got.Build(), want.Build())
+	}
+}
+
+func TestLog(t *testing.T) {
+	v := &Settled{
+		Action:  3,
+		Amount:  100,
+		Anchor:  bc.NewHash([32]byte{1}),
+		Parties: []Party{{Quorum: 1, Pubkeys: [][]byte{{2}}}, {}},
+	}
+	e, err := logdata.Parse(txvm.Bytes(logdata.New(v).Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := logdata.Decode(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, v) {
+		t.Errorf("got %#v, want %#v", got, v)
+	}
+
+	bad := []txvm.Tuple{
+		{},
+		{txvm.Int(3), txvm.Int(100), txvm.Bytes("short"), txvm.Tuple{}},
+		{txvm.Int(3), txvm.Int(100), txvm.Bytes(v.Anchor.Bytes()), txvm.Tuple{txvm.Int(1)}},
+		append(v.Fields(), txvm.Int(0)),
+	}
+	for _, fields := range bad {
+		_, err := DecodeSettled(fields)
+		if errors.Root(err) != logdata.ErrFields {
+			t.Errorf("DecodeSettled(%s): got error %v, want %v", fields, err, logdata.ErrFields)
+		}
+	}
+}
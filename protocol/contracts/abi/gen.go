@@ -0,0 +1,315 @@
+package abi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"i10r.io/errors"
+)
+
+// Generate returns Go source for package pkg with bindings for
+// iface, which was parsed from the file src.
+func Generate(iface *Interface, pkg, src string) ([]byte, error) {
+	g := &generator{
+		iface:   iface,
+		imports: make(map[string]bool),
+		helpers: make(map[string]bool),
+	}
+	g.generate()
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by abigen from %s. DO NOT EDIT.\n\n", src)
+	fmt.Fprintf(&out, "package %s\n\n", pkg)
+	var imports []string
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	if len(imports) > 0 {
+		fmt.Fprintln(&out, "import (")
+		for _, imp := range imports {
+			fmt.Fprintf(&out, "%q\n", imp)
+		}
+		fmt.Fprintln(&out, ")")
+	}
+	out.Write(g.buf.Bytes())
+	out.Write(g.helperBuf.Bytes())
+	src2, err := format.Source(out.Bytes())
+	return src2, errors.Wrap(err, "formatting generated code")
+}
+
+type generator struct {
+	iface *Interface
+	buf   bytes.Buffer
+
+	imports map[string]bool
+
+	// helpers holds the names of the list encoding and decoding
+	// functions already written to helperBuf.
+	helpers   map[string]bool
+	helperBuf bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) use(imp string) {
+	g.imports[imp] = true
+}
+
+func (g *generator) generate() {
+	iface := g.iface
+	if !iface.Seed.IsZero() {
+		g.use("i10r.io/protocol/bc")
+		g.printf("// %sSeed is the seed of the %s contract.\n", iface.Name, iface.Title)
+		g.printf("var %sSeed = bc.NewHash([32]byte{", iface.Name)
+		for i, b := range iface.Seed.Bytes() {
+			if i > 0 {
+				g.printf(", ")
+			}
+			g.printf("0x%02x", b)
+		}
+		g.printf("})\n\n")
+	}
+	for _, t := range iface.Types {
+		g.printf("// %s is the %s type of the %s contract.\n", t.Name, fieldList(t.Fields), iface.Title)
+		g.structType(t.Name, t.Fields)
+	}
+	for _, c := range iface.Clauses {
+		g.clause(c)
+	}
+	if len(iface.Logs) > 0 {
+		g.logs()
+	}
+}
+
+func (g *generator) structType(name string, fields []*Field) {
+	g.use("i10r.io/protocol/txvm")
+	g.use("i10r.io/protocol/contracts/abi")
+	g.printf("type %s struct {\n", name)
+	for _, f := range fields {
+		g.printf("%s %s\n", exported(f.Name), g.goType(f.Type))
+	}
+	g.printf("}\n\n")
+
+	g.printf("func (x *%s) tuple() txvm.Tuple {\n", name)
+	g.printf("return txvm.Tuple{\n")
+	for _, f := range fields {
+		g.printf("%s,\n", g.encode("x."+exported(f.Name), f.Type))
+	}
+	g.printf("}\n}\n\n")
+
+	g.printf("func (x *%s) decode(d *abi.Decoder) {\n", name)
+	for _, f := range fields {
+		g.printf("%s\n", g.decode("x."+exported(f.Name), f.Type, "d"))
+	}
+	g.printf("d.End()\n}\n\n")
+}
+
+func (g *generator) clause(c *Clause) {
+	g.use("i10r.io/protocol/txvm")
+	g.use("i10r.io/protocol/txvm/op")
+	g.use("i10r.io/protocol/txvm/txvmutil")
+
+	var (
+		params []string
+		values []string
+	)
+	for _, a := range c.Args {
+		switch a.Type.Kind {
+		case Value:
+			values = append(values, a.Name)
+		case Struct:
+			params = append(params, a.Name+" *"+a.Type.Name)
+		default:
+			params = append(params, a.Name+" "+g.goType(a.Type))
+		}
+	}
+
+	g.printf("// %s%s writes txvm bytecode to b that puts the arguments of\n", g.iface.Name, c.Name)
+	g.printf("// the %s clause of the %s contract on the argument stack.\n", c.Name, g.iface.Title)
+	switch len(values) {
+	case 0:
+	case 1:
+		g.printf("// The caller must leave the value %s on top of the contract stack.\n", values[0])
+	default:
+		for i, j := 0, len(values)-1; i < j; i, j = i+1, j-1 {
+			values[i], values[j] = values[j], values[i]
+		}
+		g.printf("// The caller must leave the values %s on top of the contract\n", strings.Join(values, ", "))
+		g.printf("// stack, the last on top.\n")
+	}
+	g.printf("func %s%s(%s) {\n", g.iface.Name, c.Name, strings.Join(append([]string{"b *txvmutil.Builder"}, params...), ", "))
+	for _, a := range c.Args {
+		switch a.Type.Kind {
+		case Value:
+			g.printf("b.Op(op.Put) // %s\n", a.Name)
+		case Variadic:
+			g.printf("for _, e := range %s {\n", a.Name)
+			g.printf("b.Concat(txvm.Encode(%s)).Op(op.Put)\n", g.encode("e", a.Type.Elem))
+			g.printf("}\n")
+		default:
+			g.printf("b.Concat(txvm.Encode(%s)).Op(op.Put)\n", g.encode(a.Name, a.Type))
+		}
+	}
+	g.printf("}\n\n")
+}
+
+func (g *generator) logs() {
+	g.use("i10r.io/protocol/logdata")
+	g.printf("// Type tags of the log entries of the %s contract.\n", g.iface.Title)
+	g.printf("const (\n")
+	for _, l := range g.iface.Logs {
+		g.printf("%sType = %q\n", l.Name, l.Type)
+	}
+	g.printf(")\n\n")
+
+	g.printf("func init() {\n")
+	for _, l := range g.iface.Logs {
+		g.printf("logdata.Register(&logdata.Schema{\n")
+		g.printf("Type: %sType,\n", l.Name)
+		g.printf("Doc: %q,\n", g.iface.Title+" "+l.Name+": "+fieldList(l.Fields))
+		g.printf("Decode: func(fields txvm.Tuple) (logdata.Value, error) {\n")
+		g.printf("x, err := Decode%s(fields)\n", l.Name)
+		g.printf("if err != nil {\nreturn nil, err\n}\n")
+		g.printf("return x, nil\n")
+		g.printf("},\n")
+		g.printf("})\n")
+	}
+	g.printf("}\n\n")
+
+	for _, l := range g.iface.Logs {
+		g.printf("// %s is the %s log entry of the %s contract.\n", l.Name, fieldList(l.Fields), g.iface.Title)
+		g.structType(l.Name, l.Fields)
+
+		g.printf("// Type implements logdata.Value.\n")
+		g.printf("func (x *%s) Type() string { return %sType }\n\n", l.Name, l.Name)
+		g.printf("// Fields implements logdata.Value.\n")
+		g.printf("func (x *%s) Fields() txvm.Tuple { return x.tuple() }\n\n", l.Name)
+
+		g.printf("// Decode%s decodes the fields of a %s log entry.\n", l.Name, l.Name)
+		g.printf("func Decode%s(fields txvm.Tuple) (*%s, error) {\n", l.Name, l.Name)
+		g.printf("x := new(%s)\n", l.Name)
+		g.printf("d := abi.NewDecoder(fields)\n")
+		g.printf("x.decode(d)\n")
+		g.printf("if err := d.Err(); err != nil {\nreturn nil, err\n}\n")
+		g.printf("return x, nil\n")
+		g.printf("}\n\n")
+	}
+}
+
+func (g *generator) goType(t *Type) string {
+	switch t.Kind {
+	case Int:
+		return "int64"
+	case Bytes:
+		return "[]byte"
+	case String:
+		return "string"
+	case Hash:
+		g.use("i10r.io/protocol/bc")
+		return "bc.Hash"
+	case Struct:
+		return t.Name
+	case List, Variadic:
+		return "[]" + g.goType(t.Elem)
+	}
+	panic("abi: no Go type for " + t.String())
+}
+
+// encode returns an expression for the txvm.Data encoding x, of
+// type t.
+func (g *generator) encode(x string, t *Type) string {
+	switch t.Kind {
+	case Int:
+		return "txvm.Int(" + x + ")"
+	case Bytes, String:
+		return "txvm.Bytes(" + x + ")"
+	case Hash:
+		return "txvm.Bytes(" + x + ".Bytes())"
+	case Struct:
+		return x + ".tuple()"
+	case List:
+		name := "encode" + g.iface.Name + mangle(t)
+		if !g.helpers[name] {
+			g.helpers[name] = true
+			elem := g.encode("e", t.Elem)
+			fmt.Fprintf(&g.helperBuf, "func %s(l %s) txvm.Tuple {\n", name, g.goType(t))
+			fmt.Fprintf(&g.helperBuf, "t := make(txvm.Tuple, 0, len(l))\n")
+			fmt.Fprintf(&g.helperBuf, "for _, e := range l {\nt = append(t, %s)\n}\n", elem)
+			fmt.Fprintf(&g.helperBuf, "return t\n}\n\n")
+		}
+		return name + "(" + x + ")"
+	}
+	panic("abi: cannot encode " + t.String())
+}
+
+// decode returns a statement that sets x, of type t, to an item read
+// from the decoder d.
+func (g *generator) decode(x string, t *Type, d string) string {
+	if t.Kind == Struct {
+		return x + ".decode(" + d + ".Tuple())"
+	}
+	return x + " = " + g.decodeExpr(t, d)
+}
+
+// decodeExpr returns an expression for an item, of type t other than
+// Struct, read from the decoder d.
+func (g *generator) decodeExpr(t *Type, d string) string {
+	switch t.Kind {
+	case Int:
+		return d + ".Int()"
+	case Bytes:
+		return d + ".Bytes()"
+	case String:
+		return d + ".Text()"
+	case Hash:
+		return d + ".Hash()"
+	case List:
+		name := "decode" + g.iface.Name + mangle(t)
+		if !g.helpers[name] {
+			g.helpers[name] = true
+			var loop string
+			if t.Elem.Kind == Struct {
+				loop = fmt.Sprintf("var e %s\ne.decode(d.Tuple())\nl = append(l, e)\n", t.Elem.Name)
+			} else {
+				loop = fmt.Sprintf("l = append(l, %s)\n", g.decodeExpr(t.Elem, "d"))
+			}
+			fmt.Fprintf(&g.helperBuf, "func %s(d *abi.Decoder) %s {\n", name, g.goType(t))
+			fmt.Fprintf(&g.helperBuf, "var l %s\n", g.goType(t))
+			fmt.Fprintf(&g.helperBuf, "for d.More() {\n%s}\n", loop)
+			fmt.Fprintf(&g.helperBuf, "return l\n}\n\n")
+		}
+		return name + "(" + d + ".Tuple())"
+	}
+	panic("abi: cannot decode " + t.String())
+}
+
+// mangle returns a name for t usable in a Go identifier.
+func mangle(t *Type) string {
+	switch t.Kind {
+	case Struct:
+		return t.Name
+	case List:
+		return mangle(t.Elem) + "List"
+	}
+	return exported(t.String())
+}
+
+func exported(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
+
+// fieldList returns fields as written in an interface file, in
+// braces.
+func fieldList(fields []*Field) string {
+	var s []string
+	for _, f := range fields {
+		s = append(s, f.Name+" "+f.Type.String())
+	}
+	return "{" + strings.Join(s, ", ") + "}"
+}
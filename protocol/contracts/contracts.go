@@ -0,0 +1,172 @@
+/*
+Package contracts is a registry of known contracts, by seed, so that
+tools can show "standard pay-to-multisig v2 (2-of-3)" where they
+would otherwise show an opaque hash.
+
+Each Contract names a seed and describes the argument stack its
+program expects and the contract stack it keeps while waiting as an
+output. Package standard registers the standard contracts. Other
+contracts are registered with Register, or loaded from a JSON file
+(see Load) such as the one named by the environment variable
+TXVMCONTRACTS, which the command-line tools read.
+*/
+package contracts
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+)
+
+// EnvFile is the environment variable naming a file for LoadEnv.
+const EnvFile = "TXVMCONTRACTS"
+
+// Contract describes a known contract.
+type Contract struct {
+	Name string  `json:"name"`
+	Seed bc.Hash `json:"seed"`
+
+	// Program is the contract's initial program, whose hash is the
+	// seed. Loaded contracts may give it instead of Seed.
+	Program chainjson.HexBytes `json:"program,omitempty"`
+
+	// Args describes the argument stack the program expects when
+	// the contract is created, from the bottom.
+	Args []Arg `json:"args,omitempty"`
+
+	// Stack describes the contract stack of an output of the
+	// contract, from the bottom.
+	Stack []Arg `json:"stack,omitempty"`
+
+	// Label, if set, returns a short description of a contract
+	// with the given stack, in the form of bc.Output.Stack, such as
+	// "2-of-3". It returns "" for a stack it does not understand.
+	Label func(stack []txvm.Data) string `json:"-"`
+}
+
+// Arg describes an item on a stack.
+type Arg struct {
+	Name string `json:"name"`
+
+	// Type is one of "int", "bytes", "tuple", or "value", or a
+	// description of a tuple's layout such as "{quorum, {pubkey}}".
+	Type string `json:"type"`
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[bc.Hash]*Contract)
+)
+
+// Register adds c to the registry. It panics if a contract with the
+// same seed is already registered.
+func Register(c *Contract) {
+	if !register(c) {
+		panic("contracts: seed " + hex.EncodeToString(c.Seed.Bytes()) + " registered twice")
+	}
+}
+
+func register(c *Contract) bool {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[c.Seed]; ok {
+		return false
+	}
+	registry[c.Seed] = c
+	return true
+}
+
+// Lookup returns the contract registered for seed.
+func Lookup(seed bc.Hash) (*Contract, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, ok := registry[seed]
+	return c, ok
+}
+
+// All returns the registered contracts, ordered by name.
+func All() []*Contract {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var all []*Contract
+	for _, c := range registry {
+		all = append(all, c)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Name returns the name of the contract with the given seed, or the
+// seed in hex if it is not registered.
+func Name(seed []byte) string {
+	if c, ok := Lookup(bc.HashFromBytes(seed)); ok {
+		return c.Name
+	}
+	return hex.EncodeToString(seed)
+}
+
+// Label returns the name of the contract with the given seed, along
+// with its label for stack if it has one, as in
+// "standard pay-to-multisig v2 (2-of-3)". It returns the seed in hex
+// if the contract is not registered.
+func Label(seed []byte, stack []txvm.Data) string {
+	c, ok := Lookup(bc.HashFromBytes(seed))
+	if !ok {
+		return hex.EncodeToString(seed)
+	}
+	if c.Label != nil {
+		if l := c.Label(stack); l != "" {
+			return c.Name + " (" + l + ")"
+		}
+	}
+	return c.Name
+}
+
+// Load registers the contracts in r, a JSON array of Contract
+// objects. Each gives either its seed or its program.
+func Load(r io.Reader) error {
+	var cs []*Contract
+	err := json.NewDecoder(r).Decode(&cs)
+	if err != nil {
+		return errors.Wrap(err, "decoding contracts")
+	}
+	for _, c := range cs {
+		if len(c.Program) > 0 {
+			seed := bc.NewHash(txvm.ContractSeed(c.Program))
+			if !c.Seed.IsZero() && c.Seed != seed {
+				return errors.Wrapf(errors.New("seed does not match program"), "contract %s", c.Name)
+			}
+			c.Seed = seed
+		}
+		if c.Seed.IsZero() {
+			return errors.Wrapf(errors.New("no seed or program"), "contract %s", c.Name)
+		}
+		if !register(c) {
+			return errors.Wrapf(errors.New("seed already registered"), "contract %s", c.Name)
+		}
+	}
+	return nil
+}
+
+// LoadEnv loads the file named by the environment variable EnvFile,
+// if it is set.
+func LoadEnv() error {
+	name := os.Getenv(EnvFile)
+	if name == "" {
+		return nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return errors.Wrap(Load(f), name)
+}
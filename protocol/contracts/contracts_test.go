@@ -0,0 +1,85 @@
+package contracts
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+)
+
+func TestLabel(t *testing.T) {
+	seed := bc.NewHash([32]byte{1, 2, 3})
+	Register(&Contract{
+		Name: "test label",
+		Seed: seed,
+		Label: func(stack []txvm.Data) string {
+			if len(stack) == 0 {
+				return ""
+			}
+			return "full"
+		},
+	})
+	unknown := bc.NewHash([32]byte{9})
+	cases := []struct {
+		seed  bc.Hash
+		stack []txvm.Data
+		want  string
+	}{
+		{seed, nil, "test label"},
+		{seed, []txvm.Data{txvm.Int(1)}, "test label (full)"},
+		{unknown, nil, hex.EncodeToString(unknown.Bytes())},
+	}
+	for _, c := range cases {
+		got := Label(c.seed.Bytes(), c.stack)
+		if got != c.want {
+			t.Errorf("Label(%x, %v) = %q, want %q", c.seed.Bytes(), c.stack, got, c.want)
+		}
+	}
+	if got := Name(seed.Bytes()); got != "test label" {
+		t.Errorf("Name(%x) = %q, want %q", seed.Bytes(), got, "test label")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	prog := []byte{0x01, 0x02}
+	seed := bc.NewHash(txvm.ContractSeed(prog))
+	err := Load(strings.NewReader(`[
+		{"name": "loaded by program", "program": "0102", "args": [{"name": "x", "type": "int"}]},
+		{"name": "loaded by seed", "seed": "` + hex.EncodeToString(make([]byte, 31)) + `01"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, ok := Lookup(seed)
+	if !ok || c.Name != "loaded by program" || len(c.Args) != 1 {
+		t.Errorf("Lookup(%x) = %+v, %v", seed.Bytes(), c, ok)
+	}
+	if got := Name(append(make([]byte, 31), 1)); got != "loaded by seed" {
+		t.Errorf("got name %q, want %q", got, "loaded by seed")
+	}
+
+	bad := []string{
+		`{}`,
+		`[{"name": "no seed"}]`,
+		`[{"name": "mismatch", "program": "0102", "seed": "` + hex.EncodeToString(make([]byte, 31)) + `ff"}]`,
+		`[{"name": "duplicate", "program": "0102"}]`,
+	}
+	for _, s := range bad {
+		if err := Load(strings.NewReader(s)); err == nil {
+			t.Errorf("Load(%s): got no error", s)
+		}
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	c := &Contract{Name: "twice", Seed: bc.NewHash([32]byte{7})}
+	Register(c)
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a seed twice did not panic")
+		}
+	}()
+	Register(c)
+}
@@ -0,0 +1,544 @@
+package ivy
+
+import (
+	"fmt"
+	"strings"
+)
+
+type compiler struct {
+	src   string
+	decls map[string]*contractDecl
+	done  map[string]*compiled
+
+	// compiling holds the contracts being compiled, to catch a
+	// contract that locks values in itself.
+	compiling []string
+}
+
+// compiled is a contract's assembly, with its marks.
+type compiled struct {
+	asm   string
+	marks []mark
+}
+
+// emitter accumulates assembly and its marks.
+type emitter struct {
+	buf   strings.Builder
+	marks []mark
+}
+
+// emit writes the assembly words in s, which come from the Ivy
+// source at pos.
+func (e *emitter) emit(pos Pos, s string) {
+	e.marks = append(e.marks, mark{asm: e.buf.Len(), pos: pos})
+	e.buf.WriteString(s)
+	e.buf.WriteByte(' ')
+}
+
+// embed writes the assembly of k, quoted as a program.
+func (e *emitter) embed(k *compiled) {
+	e.buf.WriteString("[")
+	off := e.buf.Len()
+	for _, m := range k.marks {
+		e.marks = append(e.marks, mark{asm: off + m.asm, pos: m.pos})
+	}
+	e.buf.WriteString(k.asm)
+	e.buf.WriteString("] ")
+}
+
+// comment ends the line with a comment quoting the line of source at
+// pos.
+func (e *emitter) comment(src string, pos Pos) {
+	line := src[strings.LastIndexByte(src[:pos.Offset], '\n')+1:]
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fmt.Fprintf(&e.buf, "# %s\n", strings.TrimSpace(line))
+}
+
+func (e *emitter) compiled() *compiled {
+	return &compiled{asm: e.buf.String(), marks: e.marks}
+}
+
+// compile compiles d, and the contracts it locks values in, if they
+// are not already compiled.
+func (c *compiler) compile(d *contractDecl) (k *compiled, err error) {
+	if k, ok := c.done[d.name]; ok {
+		return k, nil
+	}
+	for _, name := range c.compiling {
+		if name == d.name {
+			return nil, errorf(d.pos, "contract %s locks values in itself", d.name)
+		}
+	}
+	c.compiling = append(c.compiling, d.name)
+	defer func() { c.compiling = c.compiling[:len(c.compiling)-1] }()
+
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			k, err = nil, e
+		}
+	}()
+
+	cc := &contractCompiler{
+		compiler: c,
+		decl:     d,
+		types:    map[string]Type{d.value: Value},
+		used:     make(map[string]bool),
+	}
+	for _, p := range d.params {
+		cc.declare(p)
+	}
+	spend := cc.spend()
+	for _, p := range d.params {
+		if !cc.used[p.name] {
+			panic(errorf(p.pos, "contract parameter %s is not used", p.name))
+		}
+	}
+
+	// The program that locks the value takes the parameters and
+	// the value from the argument stack to the contract stack, in
+	// the same order, and outputs the contract with spend as its
+	// program.
+	var e emitter
+	n := len(d.params) + 1
+	e.emit(d.pos, strings.TrimSpace(strings.Repeat("get ", n)))
+	if n > 1 {
+		e.emit(d.pos, fmt.Sprintf("%d reverse", n))
+	}
+	e.comment(c.src, d.pos)
+	e.embed(spend)
+	e.emit(d.pos, "output")
+	e.comment(c.src, d.pos)
+	k = e.compiled()
+	c.done[d.name] = k
+	return k, nil
+}
+
+type contractCompiler struct {
+	*compiler
+	decl  *contractDecl
+	types map[string]Type
+	used  map[string]bool
+}
+
+func (cc *contractCompiler) declare(p *param) {
+	if _, ok := cc.types[p.name]; ok {
+		panic(errorf(p.pos, "%s already declared", p.name))
+	}
+	cc.types[p.name] = p.typ
+}
+
+// spend returns the spend program of the contract, which dispatches
+// to its clauses.
+func (cc *contractCompiler) spend() *compiled {
+	var e emitter
+	d := cc.decl
+	base := []string{}
+	for _, p := range d.params {
+		base = append(base, p.name)
+	}
+	base = append(base, d.value)
+
+	names := make(map[string]bool)
+	for _, cl := range d.clauses {
+		if names[cl.name] {
+			panic(errorf(cl.pos, "clause %s already declared", cl.name))
+		}
+		names[cl.name] = true
+	}
+
+	if len(d.clauses) == 1 {
+		cc.clause(&e, d.clauses[0], base)
+		return e.compiled()
+	}
+
+	e.emit(d.pos, "get")
+	for i := 1; i < len(d.clauses); i++ {
+		e.emit(d.clauses[i].pos, fmt.Sprintf("dup %d eq jumpif:$clause%d", i, i))
+	}
+	e.emit(d.pos, "0 eq verify")
+	e.comment(cc.src, d.pos)
+	for i, cl := range d.clauses {
+		if i > 0 {
+			e.emit(cl.pos, fmt.Sprintf("$clause%d drop", i))
+			e.comment(cc.src, cl.pos)
+		}
+		cc.clause(&e, cl, base)
+		if i < len(d.clauses)-1 {
+			e.emit(cl.pos, "jump:$done")
+			e.comment(cc.src, cl.pos)
+		}
+	}
+	e.emit(d.pos, "$done")
+	e.buf.WriteByte('\n')
+	return e.compiled()
+}
+
+// clauseCompiler compiles a clause, tracking the contract stack.
+type clauseCompiler struct {
+	*contractCompiler
+	e      *emitter
+	clause *clauseDecl
+
+	// stack names the items on the contract stack, from the bottom.
+	// Temporaries are "".
+	stack []string
+
+	// unlocked is the position of the statement that unlocked or
+	// locked the value, if one has.
+	unlocked *Pos
+
+	used map[string]bool
+}
+
+func (cc *contractCompiler) clause(e *emitter, cl *clauseDecl, base []string) {
+	k := &clauseCompiler{
+		contractCompiler: cc,
+		e:                e,
+		clause:           cl,
+		stack:            append([]string(nil), base...),
+		used:             make(map[string]bool),
+	}
+	types := cc.types
+	cc.types = make(map[string]Type)
+	for name, t := range types {
+		cc.types[name] = t
+	}
+	defer func() { cc.types = types }()
+	for _, p := range cl.params {
+		cc.declare(p)
+	}
+
+	// Take the arguments from the argument stack, the last first.
+	for i := len(cl.params) - 1; i >= 0; i-- {
+		k.emit(cl.params[i].pos, "get")
+		k.push(cl.params[i].name)
+	}
+	if len(cl.params) > 0 {
+		k.e.comment(cc.src, cl.pos)
+	}
+
+	for _, s := range cl.body {
+		k.stmt(s)
+		k.e.comment(cc.src, s.stmtPos())
+	}
+
+	if k.unlocked == nil {
+		panic(errorf(cl.pos, "clause %s does not unlock or lock %s", cl.name, cc.decl.value))
+	}
+	for _, p := range cl.params {
+		if !k.used[p.name] {
+			panic(errorf(p.pos, "clause parameter %s is not used", p.name))
+		}
+	}
+	for name := range k.used {
+		cc.used[name] = true
+	}
+	if len(k.stack) > 0 {
+		k.emit(cl.pos, strings.TrimSpace(strings.Repeat("drop ", len(k.stack))))
+		k.e.comment(cc.src, cl.pos)
+	}
+}
+
+func (k *clauseCompiler) emit(pos Pos, s string) {
+	k.e.emit(pos, s)
+}
+
+func (k *clauseCompiler) push(name string) {
+	k.stack = append(k.stack, name)
+}
+
+func (k *clauseCompiler) pop(n int) {
+	k.stack = k.stack[:len(k.stack)-n]
+}
+
+// depth returns the depth of the named item on the stack, where the
+// top is 0.
+func (k *clauseCompiler) depth(name string) int {
+	for i := len(k.stack) - 1; i >= 0; i-- {
+		if k.stack[i] == name {
+			return len(k.stack) - 1 - i
+		}
+	}
+	panic(fmt.Sprintf("ivy: %s is not on the stack", name))
+}
+
+// roll moves the named item to the top of the stack.
+func (k *clauseCompiler) roll(pos Pos, name string) {
+	d := k.depth(name)
+	if d > 0 {
+		k.emit(pos, fmt.Sprintf("%d roll", d))
+		i := len(k.stack) - 1 - d
+		k.stack = append(append(k.stack[:i:i], k.stack[i+1:]...), name)
+	}
+}
+
+// value checks that name is the contract's value and still locked.
+func (k *clauseCompiler) value(pos Pos, name string) {
+	if name != k.decl.value {
+		panic(errorf(pos, "%s is not the contract's value", name))
+	}
+	if k.unlocked != nil {
+		panic(errorf(pos, "%s was already unlocked or locked at %s", name, *k.unlocked))
+	}
+}
+
+func (k *clauseCompiler) stmt(s stmt) {
+	switch s := s.(type) {
+	case *requireStmt:
+		if call, ok := s.expr.(*callExpr); ok && (call.fn == "after" || call.fn == "before") {
+			k.timeRange(call)
+			return
+		}
+		k.expect(s.expr, Boolean)
+		k.emit(s.pos, "verify")
+		k.pop(1)
+
+	case *unlockStmt:
+		k.value(s.pos, s.value)
+		k.roll(s.pos, s.value)
+		k.emit(s.pos, "put")
+		k.pop(1)
+		k.unlocked = &s.pos
+
+	case *lockStmt:
+		k.value(s.pos, s.value)
+		d, ok := k.decls[s.contract]
+		if !ok {
+			panic(errorf(s.pos, "unknown contract %s", s.contract))
+		}
+		if len(s.args) != len(d.params) {
+			panic(errorf(s.pos, "contract %s takes %d arguments, not %d", d.name, len(d.params), len(s.args)))
+		}
+		target, err := k.compiler.compile(d)
+		if err != nil {
+			panic(err)
+		}
+		for i, arg := range s.args {
+			t := k.expr(arg)
+			if !assignable(d.params[i].typ, t) {
+				panic(errorf(arg.exprPos(), "argument %s of %s is %s, not %s", d.params[i].name, d.name, t, d.params[i].typ))
+			}
+			k.emit(s.pos, "put")
+			k.pop(1)
+		}
+		k.roll(s.pos, s.value)
+		k.emit(s.pos, "put")
+		k.pop(1)
+		k.e.embed(target)
+		k.emit(s.pos, "contract call")
+		k.unlocked = &s.pos
+	}
+}
+
+// timeRange compiles a call of after or before in a require
+// statement.
+func (k *clauseCompiler) timeRange(call *callExpr) {
+	if len(call.args) != 1 {
+		panic(errorf(call.pos, "%s takes 1 argument", call.fn))
+	}
+	if call.fn == "before" {
+		k.emit(call.pos, "0")
+		k.push("")
+	}
+	k.expect(call.args[0], Time)
+	if call.fn == "after" {
+		k.emit(call.pos, "0")
+		k.push("")
+	}
+	k.emit(call.pos, "timerange")
+	k.pop(2)
+}
+
+// assignable tells whether an expression of type from can be passed
+// as a parameter of type to.
+func assignable(to, from Type) bool {
+	return to == from || (to == String && from.isBytes()) || (to == Time && from == Integer)
+}
+
+// expect compiles x, which must have type t.
+func (k *clauseCompiler) expect(x expr, t Type) {
+	got := k.expr(x)
+	if !assignable(t, got) {
+		panic(errorf(x.exprPos(), "expression is %s, not %s", got, t))
+	}
+}
+
+// expr compiles x, which pushes its value on the stack, and returns
+// its type.
+func (k *clauseCompiler) expr(x expr) Type {
+	switch x := x.(type) {
+	case *intLit:
+		k.emit(x.pos, fmt.Sprintf("%d", x.n))
+		k.push("")
+		return Integer
+
+	case *bytesLit:
+		k.emit(x.pos, fmt.Sprintf("x'%x'", x.b))
+		k.push("")
+		return String
+
+	case *boolLit:
+		if x.b {
+			k.emit(x.pos, "1")
+		} else {
+			k.emit(x.pos, "0")
+		}
+		k.push("")
+		return Boolean
+
+	case *varRef:
+		t, ok := k.types[x.name]
+		if !ok {
+			panic(errorf(x.pos, "undefined: %s", x.name))
+		}
+		if t == Value {
+			panic(errorf(x.pos, "value %s can only be unlocked, locked, or passed to amount, assetID, or anchor", x.name))
+		}
+		k.used[x.name] = true
+		k.emit(x.pos, fmt.Sprintf("%d peek", k.depth(x.name)))
+		k.push("")
+		return t
+
+	case *unaryExpr:
+		want, op := Boolean, "not"
+		if x.op == "-" {
+			want, op = Integer, "neg"
+		}
+		k.expect(x.x, want)
+		k.emit(x.pos, op)
+		return want
+
+	case *binaryExpr:
+		return k.binary(x)
+
+	case *callExpr:
+		return k.call(x)
+	}
+	panic(fmt.Sprintf("ivy: unknown expression %T", x))
+}
+
+var binaryOps = map[string]string{
+	"||": "or",
+	"&&": "and",
+	"==": "eq",
+	"!=": "eq not",
+	"<":  "lt",
+	"<=": "le",
+	">":  "gt",
+	">=": "ge",
+	"+":  "add",
+	"-":  "sub",
+	"*":  "mul",
+	"/":  "div",
+	"%":  "mod",
+}
+
+func (k *clauseCompiler) binary(x *binaryExpr) Type {
+	tx := k.expr(x.x)
+	ty := k.expr(x.y)
+	k.emit(x.pos, binaryOps[x.op])
+	k.pop(1)
+
+	mismatch := func() {
+		panic(errorf(x.pos, "invalid operation: %s %s %s", tx, x.op, ty))
+	}
+	switch x.op {
+	case "||", "&&":
+		if tx != Boolean || ty != Boolean {
+			mismatch()
+		}
+		return Boolean
+	case "==", "!=":
+		if tx != ty && !(tx.isBytes() && ty.isBytes()) {
+			mismatch()
+		}
+		return Boolean
+	case "<", "<=", ">", ">=":
+		if tx != ty || (tx != Integer && tx != Time) {
+			mismatch()
+		}
+		return Boolean
+	case "+", "-":
+		if tx == Time && ty == Integer {
+			return Time
+		}
+		if x.op == "-" && tx == Time && ty == Time {
+			return Integer
+		}
+	}
+	if tx != Integer || ty != Integer {
+		mismatch()
+	}
+	return Integer
+}
+
+// builtins are the functions other than the value functions, with
+// their parameter types, result type, and assembly.
+var builtins = map[string]struct {
+	params []Type
+	result Type
+	asm    string
+}{
+	"checkSig": {[]Type{PublicKey, String, Signature}, Boolean, "0 checksig"},
+	"sha3":     {[]Type{String}, Hash, "sha3"},
+	"sha256":   {[]Type{String}, Hash, "sha256"},
+	"concat":   {[]Type{String, String}, String, "cat"},
+	"size":     {[]Type{String}, Integer, "len"},
+}
+
+var valueFuncs = map[string]struct {
+	result Type
+	asm    string
+}{
+	"amount":  {Integer, "amount"},
+	"assetID": {Hash, "assetid"},
+	"anchor":  {Hash, "anchor"},
+}
+
+func (k *clauseCompiler) call(x *callExpr) Type {
+	if f, ok := valueFuncs[x.fn]; ok {
+		if len(x.args) != 1 {
+			panic(errorf(x.pos, "%s takes 1 argument", x.fn))
+		}
+		v, ok := x.args[0].(*varRef)
+		if !ok {
+			panic(errorf(x.args[0].exprPos(), "argument of %s must be the contract's value", x.fn))
+		}
+		k.value(v.pos, v.name)
+		k.roll(x.pos, v.name)
+		k.emit(x.pos, f.asm)
+		k.push("")
+		return f.result
+	}
+	if x.fn == "after" || x.fn == "before" {
+		panic(errorf(x.pos, "%s can only be required", x.fn))
+	}
+	f, ok := builtins[x.fn]
+	if !ok {
+		panic(errorf(x.pos, "unknown function %s", x.fn))
+	}
+	if len(x.args) != len(f.params) {
+		panic(errorf(x.pos, "%s takes %d arguments, not %d", x.fn, len(f.params), len(x.args)))
+	}
+	order := []int{}
+	for i := range x.args {
+		order = append(order, i)
+	}
+	if x.fn == "checkSig" {
+		// checksig takes the message first.
+		order = []int{1, 0, 2}
+	}
+	for _, i := range order {
+		k.expect(x.args[i], f.params[i])
+	}
+	k.emit(x.pos, f.asm)
+	k.pop(len(x.args))
+	k.push("")
+	return f.result
+}
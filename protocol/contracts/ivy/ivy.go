@@ -0,0 +1,288 @@
+/*
+Package ivy compiles Ivy, a small declarative language for
+contracts, to txvm.
+
+An Ivy contract locks a value, with some parameters, until one of
+its clauses is satisfied:
+
+	contract KeyLock(owner: PublicKey) locks funds {
+		clause spend(sig: Signature) {
+			require checkSig(owner, anchor(funds), sig)
+			unlock funds
+		}
+	}
+
+	contract Deposit(owner: PublicKey, backup: PublicKey, deadline: Time) locks funds {
+		clause withdraw(sig: Signature) {
+			require checkSig(owner, anchor(funds), sig)
+			unlock funds
+		}
+		clause recover() {
+			require after(deadline)
+			lock funds with KeyLock(backup)
+		}
+	}
+
+A clause is a list of statements:
+
+	require expr              fail unless expr, a Boolean, is true
+	require after(t)          fail unless the transaction is no earlier than t
+	require before(t)         fail unless the transaction is no later than t
+	unlock value              put the value on the argument stack, for the caller
+	lock value with C(args)   lock the value in contract C, declared in the same source
+
+Each clause must unlock or lock the contract's value exactly once,
+and must use each of its parameters; each of the contract's
+parameters must be used by some clause. The compiler rejects a
+contract that breaks these rules, or whose expressions do not
+type-check, before it can strand a value.
+
+The types are Integer, Boolean, String, Hash, PublicKey, Signature,
+and Time, an Integer of milliseconds. String, Hash, PublicKey, and
+Signature are all strings of bytes, and compare equal as such.
+Expressions are literals (123, -4, true, 'text', x'0a0b'),
+parameters, the operators
+
+	||  &&  ==  !=  <  <=  >  >=  +  -  *  /  %  !
+
+with their usual precedence, and calls of the functions
+
+	checkSig(PublicKey, String, Signature) Boolean
+	sha3(String) Hash
+	sha256(String) Hash
+	concat(String, String) String
+	size(String) Integer
+	amount(value) Integer
+	assetID(value) Hash
+	anchor(value) Hash
+
+where value is the name of the contract's locked value.
+
+A compiled contract's program (see Contract.Program), called with
+the argument stack [... param1 ... paramN value], locks the value.
+Its output's contract stack is the parameters and the value, in that
+order. To spend it, put the clause's arguments and then, if the
+contract has more than one clause, the clause's index, on the
+argument stack, and call the input.
+
+The compiler emits txvm assembly (Contract.Asm), and relates its
+bytecode back to the Ivy source with the assembler's source map (see
+Contract.Pos).
+*/
+package ivy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/contracts/abi"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+)
+
+// Error is an error in Ivy source.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+func errorf(pos Pos, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Type is an Ivy type.
+type Type string
+
+// The Ivy types.
+const (
+	Integer   Type = "Integer"
+	Boolean   Type = "Boolean"
+	String    Type = "String"
+	Hash      Type = "Hash"
+	PublicKey Type = "PublicKey"
+	Signature Type = "Signature"
+	Time      Type = "Time"
+
+	// Value is the type of a contract's locked value, which cannot
+	// be a parameter.
+	Value Type = "Value"
+)
+
+var typeNames = map[string]Type{
+	"Integer":   Integer,
+	"Boolean":   Boolean,
+	"String":    String,
+	"Hash":      Hash,
+	"PublicKey": PublicKey,
+	"Signature": Signature,
+	"Time":      Time,
+}
+
+func (t Type) isBytes() bool {
+	return t == String || t == Hash || t == PublicKey || t == Signature
+}
+
+// Param is a parameter of a contract or clause.
+type Param struct {
+	Name string
+	Type Type
+}
+
+// Clause is a clause of a compiled contract.
+type Clause struct {
+	Name   string
+	Params []Param
+}
+
+// Contract is a compiled contract.
+type Contract struct {
+	Name    string
+	Params  []Param
+	Value   string
+	Clauses []Clause
+
+	// Asm is the txvm assembly source of Program.
+	Asm string
+
+	// Program locks a value in the contract, and Seed is its
+	// contract seed.
+	Program []byte
+	Seed    bc.Hash
+
+	// SpendProgram is the program of the contract's outputs, which
+	// checks a clause and disposes of the value.
+	SpendProgram []byte
+
+	// Map is the source map of Asm. Map.Quoted[0] is that of
+	// SpendProgram.
+	Map *asm.SourceMap
+
+	marks []mark
+}
+
+// A mark records that the assembly at byte offset asm of
+// Contract.Asm comes from the Ivy source at pos.
+type mark struct {
+	asm int
+	pos Pos
+}
+
+// Pos returns the position in the Ivy source of the instruction at
+// pc in the program of m, which is c.Map or one of the source maps
+// quoted within it.
+func (c *Contract) Pos(m *asm.SourceMap, pc int64) (Pos, bool) {
+	off, ok := m.Pos[pc]
+	if !ok {
+		return Pos{}, false
+	}
+	i := sort.Search(len(c.marks), func(i int) bool { return c.marks[i].asm > off })
+	if i == 0 {
+		return Pos{}, false
+	}
+	return c.marks[i-1].pos, true
+}
+
+// Interface returns the interface of c (see package abi). Its first
+// clause, Lock, locks a value in the contract, and each other clause
+// is one of c's, with its name capitalized, taking as its last
+// argument the clause's index if c has more than one.
+func (c *Contract) Interface() *abi.Interface {
+	iface := &abi.Interface{Name: c.Name, Title: c.Name, Seed: c.Seed}
+	lock := &abi.Clause{Name: "Lock", Args: abiFields(c.Params)}
+	lock.Args = append(lock.Args, &abi.Field{Name: c.Value, Type: &abi.Type{Kind: abi.Value}})
+	iface.Clauses = append(iface.Clauses, lock)
+	for _, cl := range c.Clauses {
+		ac := &abi.Clause{Name: exported(cl.Name), Args: abiFields(cl.Params)}
+		if len(c.Clauses) > 1 {
+			ac.Args = append(ac.Args, &abi.Field{Name: "clause", Type: &abi.Type{Kind: abi.Int}})
+		}
+		iface.Clauses = append(iface.Clauses, ac)
+	}
+	return iface
+}
+
+func abiFields(params []Param) []*abi.Field {
+	var fields []*abi.Field
+	for _, p := range params {
+		kind := abi.Bytes
+		switch p.Type {
+		case Integer, Boolean, Time:
+			kind = abi.Int
+		case Hash:
+			kind = abi.Hash
+		}
+		fields = append(fields, &abi.Field{Name: p.Name, Type: &abi.Type{Kind: kind}})
+	}
+	return fields
+}
+
+// Compile compiles the contracts in src.
+func Compile(src string) ([]*Contract, error) {
+	decls, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	c := &compiler{
+		src:   src,
+		decls: make(map[string]*contractDecl),
+		done:  make(map[string]*compiled),
+	}
+	for _, d := range decls {
+		if _, ok := c.decls[d.name]; ok {
+			return nil, errorf(d.pos, "contract %s already declared", d.name)
+		}
+		c.decls[d.name] = d
+	}
+	var contracts []*Contract
+	for _, d := range decls {
+		k, err := c.compile(d)
+		if err != nil {
+			return nil, err
+		}
+		con, err := k.contract(d)
+		if err != nil {
+			return nil, err
+		}
+		contracts = append(contracts, con)
+	}
+	return contracts, nil
+}
+
+func (k *compiled) contract(d *contractDecl) (*Contract, error) {
+	m, err := asm.AssembleSourceMap(k.asm)
+	if err != nil {
+		// The compiler's output should always assemble.
+		return nil, errorf(d.pos, "assembling contract %s: %s", d.name, err)
+	}
+	c := &Contract{
+		Name:         d.name,
+		Value:        d.value,
+		Asm:          k.asm,
+		Program:      m.Prog,
+		Seed:         bc.NewHash(txvm.ContractSeed(m.Prog)),
+		SpendProgram: m.Quoted[0].Prog,
+		Map:          m,
+		marks:        k.marks,
+	}
+	for _, p := range d.params {
+		c.Params = append(c.Params, Param{Name: p.name, Type: p.typ})
+	}
+	for _, cl := range d.clauses {
+		clause := Clause{Name: cl.name}
+		for _, p := range cl.params {
+			clause.Params = append(clause.Params, Param{Name: p.name, Type: p.typ})
+		}
+		c.Clauses = append(c.Clauses, clause)
+	}
+	return c, nil
+}
+
+func exported(name string) string {
+	return strings.ToUpper(name[:1]) + name[1:]
+}
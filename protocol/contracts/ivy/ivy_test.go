@@ -0,0 +1,237 @@
+package ivy
+
+import (
+	"strings"
+	"testing"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+const depositSrc = `
+contract KeyLock(owner: PublicKey) locks funds {
+	clause spend(sig: Signature) {
+		require checkSig(owner, anchor(funds), sig)
+		unlock funds
+	}
+}
+
+// Deposit can be withdrawn by its owner, or after the deadline,
+// recovered by the backup key.
+contract Deposit(owner: PublicKey, backup: PublicKey, deadline: Time) locks funds {
+	clause withdraw(sig: Signature) {
+		require checkSig(owner, anchor(funds), sig)
+		require amount(funds) > 0 && size(sig) == 64
+		unlock funds
+	}
+	clause recover() {
+		require after(deadline)
+		lock funds with KeyLock(backup)
+	}
+}
+`
+
+func mustAssemble(src string) []byte {
+	prog, err := asm.Assemble(src)
+	if err != nil {
+		panic(err)
+	}
+	return prog
+}
+
+func TestDeposit(t *testing.T) {
+	cs, err := Compile(depositSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyLock, deposit := cs[0], cs[1]
+
+	ownerPub, ownerPrv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backupPub, backupPrv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assetID := bc.HashFromBytes([]byte("assetID"))
+	anchor := []byte("anchor")
+	value := txvm.Tuple{txvm.Bytes{txvm.ValueCode}, txvm.Int(100), txvm.Bytes(assetID.Bytes()), txvm.Bytes(anchor)}
+	params := []txvm.Data{txvm.Bytes(ownerPub), txvm.Bytes(backupPub), txvm.Int(5000)}
+
+	run := func(build func(*txvmutil.Builder)) (*txvm.VM, error) {
+		var b txvmutil.Builder
+		build(&b)
+		b.Concat(mustAssemble("'id' 10 nonce finalize"))
+		return txvm.Validate(b.Build(), 3, 100000)
+	}
+
+	vm, err := run(func(b *txvmutil.Builder) {
+		b.Concat(mustAssemble("'' put"))
+		standard.SpendMultisig(b, 0, nil, 100, assetID, anchor, standard.PayToMultisigSeed2[:])
+		b.Concat(mustAssemble("get get"))
+		for _, p := range params {
+			b.Concat(txvm.Encode(p)).Concat(mustAssemble("put"))
+		}
+		b.Concat(mustAssemble("put"))
+		b.PushdataBytes(deposit.Program).Concat(mustAssemble("contract call '' put call"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := snapshot(deposit, value, params...)
+	if got := outputs(vm); len(got) != 1 || got[0] != snapshotID(snap) {
+		t.Fatalf("fund: outputs %x, want [%x]", got, snapshotID(snap))
+	}
+
+	spend := func(selector int64, args ...txvm.Data) (*txvm.VM, error) {
+		return run(func(b *txvmutil.Builder) {
+			for _, a := range args {
+				b.Concat(txvm.Encode(a)).Concat(mustAssemble("put"))
+			}
+			b.PushdataInt64(selector).Concat(mustAssemble("put"))
+			b.Concat(txvm.Encode(snap)).Concat(mustAssemble("input call get retire"))
+		})
+	}
+
+	sig := ed25519.Sign(ownerPrv, anchor)
+	if _, err := spend(0, txvm.Bytes(sig)); err != nil {
+		t.Errorf("withdraw: %v", err)
+	}
+	if _, err := spend(0, txvm.Bytes(ed25519.Sign(backupPrv, anchor))); err == nil {
+		t.Error("withdraw with the backup key succeeded")
+	}
+	if _, err := spend(2); err == nil {
+		t.Error("spend with clause index 2 succeeded")
+	}
+
+	vm, err = run(func(b *txvmutil.Builder) {
+		b.PushdataInt64(1).Concat(mustAssemble("put"))
+		b.Concat(txvm.Encode(snap)).Concat(mustAssemble("input call"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := snapshotID(snapshot(keyLock, value, txvm.Bytes(backupPub)))
+	if got := outputs(vm); len(got) != 1 || got[0] != want {
+		t.Errorf("recover: outputs %x, want [%x]", got, want)
+	}
+	var ranges [][2]int64
+	for _, item := range vm.Log {
+		if item[0].(txvm.Bytes)[0] == txvm.TimerangeCode && bc.HashFromBytes(item[1].(txvm.Bytes)) == deposit.Seed {
+			ranges = append(ranges, [2]int64{int64(item[2].(txvm.Int)), int64(item[3].(txvm.Int))})
+		}
+	}
+	if len(ranges) != 1 || ranges[0] != [2]int64{5000, 0} {
+		t.Errorf("recover: time ranges %v, want [[5000 0]]", ranges)
+	}
+}
+
+// snapshot returns the snapshot of an output of c with the given
+// parameters and value.
+func snapshot(c *Contract, value txvm.Tuple, params ...txvm.Data) txvm.Tuple {
+	snap := txvm.Tuple{txvm.Bytes{txvm.ContractCode}, txvm.Bytes(c.Seed.Bytes()), txvm.Bytes(c.SpendProgram)}
+	for _, p := range params {
+		code := txvm.BytesCode
+		if _, ok := p.(txvm.Int); ok {
+			code = txvm.IntCode
+		}
+		snap = append(snap, txvm.Tuple{txvm.Bytes{code}, p})
+	}
+	return append(snap, value)
+}
+
+func snapshotID(snap txvm.Tuple) bc.Hash {
+	return bc.NewHash(txvm.VMHash("SnapshotID", txvm.Encode(snap)))
+}
+
+func outputs(vm *txvm.VM) []bc.Hash {
+	var ids []bc.Hash
+	for _, item := range vm.Log {
+		if item[0].(txvm.Bytes)[0] == txvm.OutputCode {
+			ids = append(ids, bc.HashFromBytes(item[2].(txvm.Bytes)))
+		}
+	}
+	return ids
+}
+
+func TestSourceMap(t *testing.T) {
+	cs, err := Compile(depositSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposit := cs[1]
+	m := deposit.Map.Quoted[0]
+	lines := make(map[int]bool)
+	for pc := range m.Pos {
+		pos, ok := deposit.Pos(m, pc)
+		if !ok {
+			t.Fatalf("no position for pc %d", pc)
+		}
+		lines[pos.Line] = true
+	}
+	for _, line := range []int{13, 14, 15, 18, 19} {
+		if !lines[line] {
+			t.Errorf("no instruction from line %d", line)
+		}
+	}
+	if !strings.Contains(deposit.Asm, "# require after(deadline)") {
+		t.Errorf("assembly does not quote its source:\n%s", deposit.Asm)
+	}
+
+	iface := deposit.Interface()
+	var names []string
+	for _, c := range iface.Clauses {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, " "); got != "Lock Withdraw Recover" {
+		t.Errorf("interface clauses %s, want Lock Withdraw Recover", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cases := []struct {
+		src, want string
+	}{
+		{`contract C() locks v {}`, "has no clauses"},
+		{`contract C() locks v { clause c() {} }`, "does not unlock or lock v"},
+		{`contract C() locks v { clause c() { unlock v unlock v } }`, "already unlocked"},
+		{`contract C() locks v { clause c() { require amount(v) > 0 unlock v require amount(v) > 0 } }`, "already unlocked"},
+		{`contract C(x: Integer) locks v { clause c() { unlock v } }`, "contract parameter x is not used"},
+		{`contract C() locks v { clause c(x: Integer) { unlock v } }`, "clause parameter x is not used"},
+		{`contract C(x: Integer) locks v { clause c(x: Integer) { require x == 1 unlock v } }`, "x already declared"},
+		{`contract C() locks v { clause c(v: Integer) { unlock v } }`, "v already declared"},
+		{`contract C() locks v { clause c() { require 1 unlock v } }`, "not Boolean"},
+		{`contract C(k: PublicKey) locks v { clause c() { require k + 1 > 0 unlock v } }`, "invalid operation"},
+		{`contract C(k: PublicKey) locks v { clause c() { require k == 1 unlock v } }`, "invalid operation"},
+		{`contract C() locks v { clause c() { require after(1) == true unlock v } }`, "can only be required"},
+		{`contract C() locks v { clause c() { require v == v unlock v } }`, "can only be unlocked"},
+		{`contract C() locks v { clause c() { require nope() unlock v } }`, "unknown function"},
+		{`contract C() locks v { clause c() { require sha3() == x'' unlock v } }`, "takes 1 arguments"},
+		{`contract C() locks v { clause c() { lock v with D() } }`, "unknown contract D"},
+		{`contract C() locks v { clause c() { lock v with C() } }`, "locks values in itself"},
+		{`contract C() locks v { clause c() { lock v with D(1) } } contract D(k: PublicKey) locks w { clause d() { require size(k) > 0 unlock w } }`, "not PublicKey"},
+		{`contract C() locks v { clause c() { unlock v } clause c() { unlock v } }`, "clause c already declared"},
+		{`contract C() locks v { clause c() { unlock v } } contract C() locks v { clause c() { unlock v } }`, "contract C already declared"},
+		{`contract C(x: Float) locks v { clause c() { unlock v } }`, "unknown type Float"},
+		{`contract C() locks v { clause c() { unlock w } }`, "w is not the contract's value"},
+		{`contract C() locks v { clause c() { require 'abc == x'' unlock v } }`, "unterminated string"},
+		{`contract C() locks v { clause c() { require 1 = 1 unlock v } }`, "unexpected character"},
+		{`contract C() locks v { clause c() { require 99999999999999999999 > 0 unlock v } }`, "out of range"},
+		{`contract C() locks { }`, "want identifier"},
+	}
+	for _, c := range cases {
+		_, err := Compile(c.src)
+		if err == nil {
+			t.Errorf("Compile(%s): got no error, want %q", c.src, c.want)
+			continue
+		}
+		if _, ok := err.(*Error); !ok || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("Compile(%s): got error %v, want %q", c.src, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,552 @@
+package ivy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Pos is a position in Ivy source.
+type Pos struct {
+	Offset    int // byte offset, from 0
+	Line, Col int // from 1
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+type (
+	contractDecl struct {
+		pos     Pos
+		name    string
+		params  []*param
+		value   string
+		clauses []*clauseDecl
+	}
+
+	clauseDecl struct {
+		pos    Pos
+		name   string
+		params []*param
+		body   []stmt
+	}
+
+	param struct {
+		pos  Pos
+		name string
+		typ  Type
+	}
+)
+
+type (
+	stmt interface {
+		stmtPos() Pos
+	}
+
+	// requireStmt is "require expr".
+	requireStmt struct {
+		pos  Pos
+		expr expr
+	}
+
+	// unlockStmt is "unlock value".
+	unlockStmt struct {
+		pos   Pos
+		value string
+	}
+
+	// lockStmt is "lock value with contract(args)".
+	lockStmt struct {
+		pos      Pos
+		value    string
+		contract string
+		args     []expr
+	}
+)
+
+func (s *requireStmt) stmtPos() Pos { return s.pos }
+func (s *unlockStmt) stmtPos() Pos  { return s.pos }
+func (s *lockStmt) stmtPos() Pos    { return s.pos }
+
+type (
+	expr interface {
+		exprPos() Pos
+	}
+
+	intLit struct {
+		pos Pos
+		n   int64
+	}
+
+	bytesLit struct {
+		pos Pos
+		b   []byte
+	}
+
+	boolLit struct {
+		pos Pos
+		b   bool
+	}
+
+	varRef struct {
+		pos  Pos
+		name string
+	}
+
+	unaryExpr struct {
+		pos Pos
+		op  string
+		x   expr
+	}
+
+	binaryExpr struct {
+		pos  Pos
+		op   string
+		x, y expr
+	}
+
+	callExpr struct {
+		pos  Pos
+		fn   string
+		args []expr
+	}
+)
+
+func (e *intLit) exprPos() Pos     { return e.pos }
+func (e *bytesLit) exprPos() Pos   { return e.pos }
+func (e *boolLit) exprPos() Pos    { return e.pos }
+func (e *varRef) exprPos() Pos     { return e.pos }
+func (e *unaryExpr) exprPos() Pos  { return e.pos }
+func (e *binaryExpr) exprPos() Pos { return e.pos }
+func (e *callExpr) exprPos() Pos   { return e.pos }
+
+// Token kinds.
+const (
+	tokEOF = iota
+	tokIdent
+	tokInt
+	tokBytes
+	tokOp
+)
+
+type token struct {
+	kind int
+	pos  Pos
+	text string // the identifier or operator, or the literal source
+	b    []byte // the value of a tokBytes
+}
+
+type lexer struct {
+	src  string
+	pos  Pos
+	toks []token
+}
+
+// operators lists the operators and punctuation, longer ones first.
+var operators = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"<", ">", "+", "-", "*", "/", "%", "!",
+	"(", ")", "{", "}", ",", ":",
+}
+
+func lex(src string) ([]token, error) {
+	l := &lexer{src: src, pos: Pos{Line: 1, Col: 1}}
+	for {
+		l.skipSpace()
+		if l.pos.Offset >= len(src) {
+			l.toks = append(l.toks, token{kind: tokEOF, pos: l.pos})
+			return l.toks, nil
+		}
+		err := l.token()
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (l *lexer) rest() string {
+	return l.src[l.pos.Offset:]
+}
+
+func (l *lexer) advance(n int) {
+	for _, r := range l.src[l.pos.Offset : l.pos.Offset+n] {
+		if r == '\n' {
+			l.pos.Line++
+			l.pos.Col = 1
+		} else {
+			l.pos.Col++
+		}
+	}
+	l.pos.Offset += n
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos.Offset < len(l.src) {
+		rest := l.rest()
+		if strings.HasPrefix(rest, "//") {
+			n := strings.IndexByte(rest, '\n')
+			if n < 0 {
+				n = len(rest)
+			}
+			l.advance(n)
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(rest)
+		if !unicode.IsSpace(r) {
+			return
+		}
+		l.advance(size)
+	}
+}
+
+func (l *lexer) token() error {
+	rest := l.rest()
+	pos := l.pos
+	r, _ := utf8.DecodeRuneInString(rest)
+	switch {
+	case strings.HasPrefix(rest, "x'") || strings.HasPrefix(rest, `x"`):
+		s, n, err := quoted(rest[1:])
+		if err != nil {
+			return errorf(pos, "%s", err)
+		}
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return errorf(pos, "bad hex string")
+		}
+		l.toks = append(l.toks, token{kind: tokBytes, pos: pos, text: rest[:n+1], b: b})
+		l.advance(n + 1)
+
+	case r == '\'' || r == '"':
+		s, n, err := quoted(rest)
+		if err != nil {
+			return errorf(pos, "%s", err)
+		}
+		l.toks = append(l.toks, token{kind: tokBytes, pos: pos, text: rest[:n], b: []byte(s)})
+		l.advance(n)
+
+	case unicode.IsLetter(r) || r == '_':
+		n := strings.IndexFunc(rest, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+		})
+		if n < 0 {
+			n = len(rest)
+		}
+		l.toks = append(l.toks, token{kind: tokIdent, pos: pos, text: rest[:n]})
+		l.advance(n)
+
+	case unicode.IsDigit(r):
+		n := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsDigit(r) })
+		if n < 0 {
+			n = len(rest)
+		}
+		l.toks = append(l.toks, token{kind: tokInt, pos: pos, text: rest[:n]})
+		l.advance(n)
+
+	default:
+		for _, op := range operators {
+			if strings.HasPrefix(rest, op) {
+				l.toks = append(l.toks, token{kind: tokOp, pos: pos, text: op})
+				l.advance(len(op))
+				return nil
+			}
+		}
+		return errorf(pos, "unexpected character %q", r)
+	}
+	return nil
+}
+
+// quoted returns the contents of the quoted string at the start of
+// s, with escapes as in Go, and its length in s.
+func quoted(s string) (string, int, error) {
+	q := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '\n':
+			return "", 0, fmt.Errorf("newline in string")
+		case q:
+			body := s[1:i]
+			if q == '\'' {
+				// Unquote wants double quotes.
+				body = strings.Replace(body, `"`, `\"`, -1)
+				body = strings.Replace(body, `\'`, `'`, -1)
+			}
+			u, err := strconv.Unquote(`"` + body + `"`)
+			if err != nil {
+				return "", 0, fmt.Errorf("bad string %s", s[:i+1])
+			}
+			return u, i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func parse(src string) (contracts []*contractDecl, err error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			contracts, err = nil, e
+		}
+	}()
+	for p.tok().kind != tokEOF {
+		contracts = append(contracts, p.contract())
+	}
+	return contracts, nil
+}
+
+func (p *parser) tok() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) errorf(format string, args ...interface{}) {
+	panic(errorf(p.tok().pos, format, args...))
+}
+
+func (p *parser) is(text string) bool {
+	t := p.tok()
+	return (t.kind == tokOp || t.kind == tokIdent) && t.text == text
+}
+
+func (p *parser) expect(text string) Pos {
+	if !p.is(text) {
+		p.errorf("got %s, want %s", p.describe(), text)
+	}
+	return p.next().pos
+}
+
+func (p *parser) describe() string {
+	t := p.tok()
+	if t.kind == tokEOF {
+		return "end of input"
+	}
+	return strconv.Quote(t.text)
+}
+
+func (p *parser) ident() (string, Pos) {
+	t := p.tok()
+	if t.kind != tokIdent || keywords[t.text] {
+		p.errorf("got %s, want identifier", p.describe())
+	}
+	p.next()
+	return t.text, t.pos
+}
+
+var keywords = map[string]bool{
+	"contract": true,
+	"clause":   true,
+	"locks":    true,
+	"require":  true,
+	"unlock":   true,
+	"lock":     true,
+	"with":     true,
+	"true":     true,
+	"false":    true,
+}
+
+// contract parses
+//
+//	contract Name(params) locks value { clauses }
+func (p *parser) contract() *contractDecl {
+	c := &contractDecl{pos: p.expect("contract")}
+	c.name, _ = p.ident()
+	c.params = p.params()
+	p.expect("locks")
+	c.value, _ = p.ident()
+	p.expect("{")
+	for !p.is("}") {
+		c.clauses = append(c.clauses, p.clause())
+	}
+	p.next()
+	if len(c.clauses) == 0 {
+		panic(errorf(c.pos, "contract %s has no clauses", c.name))
+	}
+	return c
+}
+
+func (p *parser) params() []*param {
+	var params []*param
+	p.expect("(")
+	for !p.is(")") {
+		if len(params) > 0 {
+			p.expect(",")
+		}
+		prm := new(param)
+		prm.name, prm.pos = p.ident()
+		p.expect(":")
+		name, pos := p.ident()
+		typ, ok := typeNames[name]
+		if !ok {
+			panic(errorf(pos, "unknown type %s", name))
+		}
+		prm.typ = typ
+		params = append(params, prm)
+	}
+	p.next()
+	return params
+}
+
+// clause parses
+//
+//	clause name(params) { statements }
+func (p *parser) clause() *clauseDecl {
+	c := &clauseDecl{pos: p.expect("clause")}
+	c.name, _ = p.ident()
+	c.params = p.params()
+	p.expect("{")
+	for !p.is("}") {
+		c.body = append(c.body, p.stmt())
+	}
+	p.next()
+	return c
+}
+
+func (p *parser) stmt() stmt {
+	switch {
+	case p.is("require"):
+		s := &requireStmt{pos: p.next().pos}
+		s.expr = p.expr()
+		return s
+	case p.is("unlock"):
+		s := &unlockStmt{pos: p.next().pos}
+		s.value, _ = p.ident()
+		return s
+	case p.is("lock"):
+		s := &lockStmt{pos: p.next().pos}
+		s.value, _ = p.ident()
+		p.expect("with")
+		s.contract, _ = p.ident()
+		s.args = p.args()
+		return s
+	}
+	p.errorf("got %s, want statement", p.describe())
+	return nil
+}
+
+func (p *parser) args() []expr {
+	var args []expr
+	p.expect("(")
+	for !p.is(")") {
+		if len(args) > 0 {
+			p.expect(",")
+		}
+		args = append(args, p.expr())
+	}
+	p.next()
+	return args
+}
+
+// Binary operators by precedence, lowest first.
+var precedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) expr() expr {
+	return p.binary(0)
+}
+
+func (p *parser) binary(level int) expr {
+	if level == len(precedence) {
+		return p.unary()
+	}
+	x := p.binary(level + 1)
+	for {
+		t := p.tok()
+		if t.kind != tokOp || !contains(precedence[level], t.text) {
+			return x
+		}
+		p.next()
+		y := p.binary(level + 1)
+		x = &binaryExpr{pos: t.pos, op: t.text, x: x, y: y}
+	}
+}
+
+func contains(ops []string, op string) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) unary() expr {
+	if p.is("!") || p.is("-") {
+		t := p.next()
+		if t.text == "-" && p.tok().kind == tokInt {
+			lit := p.intLit()
+			lit.pos = t.pos
+			lit.n = -lit.n
+			return lit
+		}
+		return &unaryExpr{pos: t.pos, op: t.text, x: p.unary()}
+	}
+	return p.primary()
+}
+
+func (p *parser) intLit() *intLit {
+	t := p.next()
+	n, err := strconv.ParseInt(t.text, 10, 64)
+	if err != nil {
+		panic(errorf(t.pos, "integer %s out of range", t.text))
+	}
+	return &intLit{pos: t.pos, n: n}
+}
+
+func (p *parser) primary() expr {
+	t := p.tok()
+	switch t.kind {
+	case tokInt:
+		return p.intLit()
+	case tokBytes:
+		p.next()
+		return &bytesLit{pos: t.pos, b: t.b}
+	case tokIdent:
+		if t.text == "true" || t.text == "false" {
+			p.next()
+			return &boolLit{pos: t.pos, b: t.text == "true"}
+		}
+		name, pos := p.ident()
+		if p.is("(") {
+			return &callExpr{pos: pos, fn: name, args: p.args()}
+		}
+		return &varRef{pos: pos, name: name}
+	}
+	if p.is("(") {
+		p.next()
+		x := p.expr()
+		p.expect(")")
+		return x
+	}
+	p.errorf("got %s, want expression", p.describe())
+	return nil
+}
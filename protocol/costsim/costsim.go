@@ -0,0 +1,307 @@
+// Package costsim simulates a change to the VM's costs against the
+// transactions of an existing blockchain, such as one exported with
+// package archive, before the change is made.
+//
+// A Table reprices instructions by opcode. Each historical
+// transaction is run again, the runlimit each of its instructions
+// consumed is measured and repriced, and the Report shows which
+// transactions would newly exceed the runlimit they declared, and so
+// fail, and how the runlimit used by each block would shift,
+// including which blocks would exceed their parameters' block
+// runlimit (see chainparams.Params).
+//
+// The simulation takes each instruction's path through a program as
+// given. A program that reads its remaining runlimit could take a
+// different one under the new costs; its repricing is approximate.
+package costsim
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/archive"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/chainparams"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+)
+
+// Price is the new cost of an instruction: Scale times its present
+// cost, rounded up, plus Add. A Scale of 0 means 1. The present cost
+// includes the unit every instruction pays, and, for an instruction
+// such as call that runs a program, excludes the cost of that
+// program's instructions, which are priced by their own opcodes.
+type Price struct {
+	Scale float64 `json:"scale"`
+	Add   int64   `json:"add"`
+}
+
+func (p Price) cost(old int64) int64 {
+	scale := p.Scale
+	if scale == 0 {
+		scale = 1
+	}
+	return int64(math.Ceil(float64(old)*scale)) + p.Add
+}
+
+// Table maps opcode names to their new prices. All pushdata
+// instructions are named "pushdata", and all small-integer
+// instructions "smallint". Instructions not listed keep their
+// present costs.
+type Table map[string]Price
+
+// ErrTable is returned for a Table naming an unknown opcode or
+// giving a negative scale.
+var ErrTable = errors.New("invalid cost table")
+
+// Check returns ErrTable if t is not valid.
+func (t Table) Check() error {
+	for name, p := range t {
+		if _, ok := op.Code(name); !ok && name != "pushdata" && name != "smallint" {
+			return errors.WithDetailf(ErrTable, "unknown opcode %q", name)
+		}
+		if p.Scale < 0 {
+			return errors.WithDetailf(ErrTable, "%s: negative scale %g", name, p.Scale)
+		}
+	}
+	return nil
+}
+
+func opName(opcode byte) string {
+	switch {
+	case op.IsSmallIntOp(opcode):
+		return "smallint"
+	case op.IsPushdataOp(opcode):
+		return "pushdata"
+	}
+	return op.Name(opcode)
+}
+
+// TxResult compares a transaction's costs.
+type TxResult struct {
+	Height   uint64  `json:"height"` // of the block containing the transaction
+	ID       bc.Hash `json:"id"`
+	Runlimit int64   `json:"runlimit"` // as declared
+	OldCost  int64   `json:"old_cost"` // runlimit used
+	NewCost  int64   `json:"new_cost"` // runlimit used under the new costs
+}
+
+// Exceeds reports whether the transaction's new cost exceeds its
+// runlimit, so that it would fail.
+func (r *TxResult) Exceeds() bool {
+	return r.NewCost > r.Runlimit
+}
+
+// BlockResult compares the costs of a block's transactions.
+type BlockResult struct {
+	Height  uint64 `json:"height"`
+	Txs     int    `json:"txs"`
+	OldCost int64  `json:"old_cost"`
+	NewCost int64  `json:"new_cost"`
+
+	// MaxRunlimit is the block's limit on the total runlimit of its
+	// transactions, 0 if none.
+	MaxRunlimit int64 `json:"max_runlimit,omitempty"`
+
+	// Exceeding lists the transactions that would exceed their
+	// runlimits.
+	Exceeding []*TxResult `json:"exceeding,omitempty"`
+}
+
+// OverLimit reports whether the block would exceed its limit on
+// runlimit, if its transactions declared just what they used under
+// the new costs, without already doing so under the old.
+func (r *BlockResult) OverLimit() bool {
+	return r.MaxRunlimit > 0 && r.NewCost > r.MaxRunlimit && r.OldCost <= r.MaxRunlimit
+}
+
+// Report summarizes a simulation.
+type Report struct {
+	Blocks  int64 `json:"blocks"`
+	Txs     int64 `json:"txs"`
+	OldCost int64 `json:"old_cost"`
+	NewCost int64 `json:"new_cost"`
+
+	// Exceeding lists the transactions that would newly exceed their
+	// runlimits.
+	Exceeding []*TxResult `json:"exceeding"`
+
+	// OverLimit lists the heights of the blocks that would newly
+	// exceed their limits on runlimit (see BlockResult.OverLimit).
+	OverLimit []uint64 `json:"over_limit"`
+
+	// Shift is the distribution, over blocks, of the change in
+	// their cost, in percent.
+	Shift Shift `json:"shift"`
+}
+
+// Shift summarizes the changes in the costs of blocks.
+type Shift struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"` // of the blocks with transactions
+}
+
+// Simulator reprices the transactions of a sequence of blocks,
+// accumulating a Report.
+type Simulator struct {
+	table  Table
+	report Report
+	shifts int64 // blocks counted in report.Shift
+}
+
+// New returns a Simulator repricing by t.
+func New(t Table) (*Simulator, error) {
+	if err := t.Check(); err != nil {
+		return nil, err
+	}
+	return &Simulator{table: t}, nil
+}
+
+// Report returns the report of the blocks simulated so far.
+func (s *Simulator) Report() *Report {
+	r := s.report
+	return &r
+}
+
+// Block reprices the transactions of b, adding them to s's report,
+// and returns their results.
+func (s *Simulator) Block(b *bc.Block) (*BlockResult, error) {
+	var network bc.Hash
+	if b.Version >= bc.NetworkVersion {
+		var err error
+		network, err = b.Network()
+		if err != nil {
+			return nil, err
+		}
+	}
+	res := &BlockResult{Height: b.Height, Txs: len(b.Transactions)}
+	params, err := chainparams.Of(b.BlockHeader)
+	if err != nil {
+		return nil, err
+	}
+	if params != nil {
+		res.MaxRunlimit = params.MaxBlockRunlimit
+	}
+	for _, tx := range b.Transactions {
+		newCost, err := s.reprice(tx, network)
+		if err != nil {
+			return nil, errors.Wrapf(err, "block %d tx %x", b.Height, tx.ID.Bytes())
+		}
+		txr := &TxResult{Height: b.Height, ID: tx.ID, Runlimit: tx.Runlimit, OldCost: tx.RunlimitUsed, NewCost: newCost}
+		res.OldCost += txr.OldCost
+		res.NewCost += txr.NewCost
+		if txr.Exceeds() {
+			res.Exceeding = append(res.Exceeding, txr)
+		}
+	}
+
+	r := &s.report
+	r.Blocks++
+	r.Txs += int64(res.Txs)
+	r.OldCost += res.OldCost
+	r.NewCost += res.NewCost
+	r.Exceeding = append(r.Exceeding, res.Exceeding...)
+	if res.OverLimit() {
+		r.OverLimit = append(r.OverLimit, res.Height)
+	}
+	if res.OldCost > 0 {
+		pct := 100 * float64(res.NewCost-res.OldCost) / float64(res.OldCost)
+		if s.shifts == 0 || pct < r.Shift.Min {
+			r.Shift.Min = pct
+		}
+		if s.shifts == 0 || pct > r.Shift.Max {
+			r.Shift.Max = pct
+		}
+		s.shifts++
+		r.Shift.Mean += (pct - r.Shift.Mean) / float64(s.shifts)
+	}
+	return res, nil
+}
+
+// frame is an instruction being executed, possibly running others.
+type frame struct {
+	opcode   byte
+	start    int64 // runlimit before it
+	children int64 // runlimit consumed by the instructions it ran
+}
+
+// reprice runs tx's program again and returns its cost under s's
+// table.
+func (s *Simulator) reprice(tx *bc.Tx, network bc.Hash) (int64, error) {
+	var (
+		stack   []frame
+		steps   int64 // runlimit consumed by instructions, old costs
+		newCost int64
+	)
+	before := txvm.BeforeStep(func(vm *txvm.VM) {
+		stack = append(stack, frame{opcode: vm.OpCode(), start: vm.Runlimit()})
+	})
+	after := txvm.AfterStep(func(vm *txvm.VM) {
+		f := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		total := f.start - vm.Runlimit()
+		if len(stack) > 0 {
+			stack[len(stack)-1].children += total
+		} else {
+			steps += total
+		}
+		self := total - f.children
+		if p, ok := s.table[opName(f.opcode)]; ok {
+			newCost += p.cost(self)
+		} else {
+			newCost += self
+		}
+	})
+	_, err := txvm.Validate(tx.Program, tx.Version, tx.Runlimit, before, after, bc.NetworkOption(network), txvm.WithProgramCache(bc.ProgramCache))
+	if err != nil {
+		return 0, err
+	}
+	// Runlimit charged outside any instruction keeps its cost.
+	return newCost + tx.RunlimitUsed - steps, nil
+}
+
+// Archive reprices, by t, the transactions of every block in the
+// archive read from rd (see package archive), and returns the
+// report. If reading a block fails, the report of the blocks before
+// it is returned along with the error.
+func Archive(ctx context.Context, rd io.Reader, t Table) (*Report, error) {
+	s, err := New(t)
+	if err != nil {
+		return nil, err
+	}
+	_, err = archive.Import(ctx, rd, func(_ context.Context, b *bc.Block) error {
+		_, err := s.Block(b)
+		return err
+	})
+	return s.Report(), err
+}
+
+// WriteText writes a summary of r to w.
+func (r *Report) WriteText(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "%d blocks, %d transactions\n", r.Blocks, r.Txs)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "runlimit used: %d, repriced %d", r.OldCost, r.NewCost)
+	if r.OldCost > 0 {
+		fmt.Fprintf(w, " (%+.2f%%)", 100*float64(r.NewCost-r.OldCost)/float64(r.OldCost))
+	}
+	fmt.Fprintf(w, "\nper-block change: min %+.2f%%, mean %+.2f%%, max %+.2f%%\n", r.Shift.Min, r.Shift.Mean, r.Shift.Max)
+	fmt.Fprintf(w, "%d transactions would exceed their runlimits\n", len(r.Exceeding))
+	for _, tx := range r.Exceeding {
+		fmt.Fprintf(w, "  block %d tx %x: runlimit %d, cost %d, repriced %d\n", tx.Height, tx.ID.Bytes(), tx.Runlimit, tx.OldCost, tx.NewCost)
+	}
+	_, err = fmt.Fprintf(w, "%d blocks would exceed their runlimit limits", len(r.OverLimit))
+	if err != nil {
+		return err
+	}
+	if len(r.OverLimit) > 0 {
+		fmt.Fprintf(w, ": %v", r.OverLimit)
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
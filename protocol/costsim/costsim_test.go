@@ -0,0 +1,86 @@
+package costsim
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/archive"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/txgen"
+)
+
+func TestSimulate(t *testing.T) {
+	c := prottest.NewChain(t)
+	g := txgen.New(1)
+
+	var buf bytes.Buffer
+	w := archive.NewWriter(&buf)
+	if err := w.WriteBlock(prottest.Initial(t, c)); err != nil {
+		t.Fatal(err)
+	}
+	var txs int64
+	for i := 0; i < 3; i++ {
+		batch, err := g.Txs(c.State(), time.Now(), 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteBlock(prottest.MakeBlock(t, c, batch)); err != nil {
+			t.Fatal(err)
+		}
+		txs += int64(len(batch))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Unchanged costs reproduce the runlimit used.
+	r, err := Archive(ctx, bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Blocks != 4 || r.Txs != txs || r.OldCost == 0 || r.NewCost != r.OldCost || len(r.Exceeding) != 0 {
+		t.Fatalf("unchanged costs: report %+v", r)
+	}
+
+	// Each finalize costs 1000 more.
+	r, err = Archive(ctx, bytes.NewReader(buf.Bytes()), Table{"finalize": {Add: 1000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := r.OldCost + 1000*txs; r.NewCost != want {
+		t.Errorf("finalize +1000: new cost %d, want %d", r.NewCost, want)
+	}
+	if r.Shift.Min <= 0 || r.Shift.Max < r.Shift.Mean {
+		t.Errorf("finalize +1000: shift %+v", r.Shift)
+	}
+
+	// Far costlier signature checks exceed the runlimits.
+	r, err = Archive(ctx, bytes.NewReader(buf.Bytes()), Table{"checksig": {Scale: 1000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(r.Exceeding)) != txs {
+		t.Errorf("checksig x1000: %d transactions exceed their runlimits, want %d", len(r.Exceeding), txs)
+	}
+	for _, tx := range r.Exceeding {
+		if !tx.Exceeds() || tx.NewCost <= tx.OldCost {
+			t.Errorf("tx %x: %+v", tx.ID.Bytes(), tx)
+		}
+	}
+	var out bytes.Buffer
+	if err := r.WriteText(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "transactions would exceed their runlimits") {
+		t.Errorf("report text:\n%s", out.String())
+	}
+
+	if _, err := New(Table{"nosuchop": {}}); errors.Root(err) != ErrTable {
+		t.Errorf("unknown opcode: got error %v, want %v", err, ErrTable)
+	}
+}
@@ -0,0 +1,257 @@
+// Package devnet runs self-contained blockchains for development and
+// integration testing.
+//
+// A Net is a single-generator chain whose keys are all derived from a
+// seed, so that a network set up from the same Config twice produces
+// the same blocks, byte for byte. Its genesis is two blocks: the
+// initial block, and a funding block issuing each of the configured
+// allocations. (The allocations can't go in the initial block itself,
+// since an issuance commits to the ID of the blockchain it is valid
+// on, which is the hash of the initial block.)
+//
+// Block timestamps advance by exactly Config.Interval per block,
+// however much real time passes between them. Tests call MakeBlock
+// to step the chain; Run makes blocks on a real-time ticker instead.
+package devnet
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/log"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/mempool"
+	"i10r.io/protocol/prottest/memstore"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/standard"
+)
+
+const (
+	// DefaultInterval is the block interval used when
+	// Config.Interval is zero.
+	DefaultInterval = time.Second
+
+	issuanceVersion = 2
+
+	// txTTL is how many block intervals a transaction made by a
+	// Net remains valid for.
+	txTTL = 100
+)
+
+// DefaultStart is the initial block timestamp used when Config.Start
+// is zero.
+var DefaultStart = time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// An Allocation is an amount of an asset issued to a key in the
+// funding block.
+type Allocation struct {
+	Tag    []byte // of an asset issued by the Net's issuer key
+	Amount int64
+	Pubkey ed25519.PublicKey
+}
+
+// Config describes a Net.
+type Config struct {
+	Seed        []byte        // determines the block-signing and issuer keys
+	Start       time.Time     // timestamp of the initial block
+	Interval    time.Duration // between block timestamps
+	Allocations []Allocation
+	MaxPoolTxs  int // see mempool.New
+}
+
+// Net is a running development network.
+//
+// It is safe for concurrent use.
+type Net struct {
+	Chain *protocol.Chain
+
+	// BlockKey signs blocks. IssuerKey is the sole key of the
+	// standard issuance contract for every asset the Net issues.
+	BlockKey  ed25519.PrivateKey
+	IssuerKey ed25519.PrivateKey
+
+	interval time.Duration
+
+	mu     sync.Mutex // serializes block production and issuance nonces
+	pool   *mempool.Pool
+	nonces uint64
+}
+
+// Key returns the private key derived from seed for the given
+// purpose.
+func Key(seed []byte, purpose string) ed25519.PrivateKey {
+	h := sha512.Sum512(append([]byte("devnet "+purpose+" "), seed...))
+	_, prv, err := ed25519.GenerateKey(bytes.NewReader(h[:]))
+	if err != nil {
+		panic(err) // can't happen: the reader has 64 bytes
+	}
+	return prv
+}
+
+// New starts a Net in store, which must be empty. If store is nil,
+// the Net is kept in memory. It commits the initial block and the
+// funding block before returning.
+func New(ctx context.Context, cfg Config, store protocol.Store) (*Net, error) {
+	if store == nil {
+		store = memstore.New()
+	}
+	height, err := store.Height(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if height > 0 {
+		return nil, errors.New("devnet store is not empty")
+	}
+	if cfg.Start.IsZero() {
+		cfg.Start = DefaultStart
+	}
+	n := &Net{
+		BlockKey:  Key(cfg.Seed, "block"),
+		IssuerKey: Key(cfg.Seed, "issuer"),
+		interval:  cfg.Interval,
+	}
+	if n.interval == 0 {
+		n.interval = DefaultInterval
+	}
+
+	blockPub := n.BlockKey.Public().(ed25519.PublicKey)
+	b1, err := protocol.NewInitialBlock([]ed25519.PublicKey{blockPub}, 1, cfg.Start)
+	if err != nil {
+		return nil, err
+	}
+	n.Chain, err = protocol.NewChain(ctx, b1, store, nil)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := state.Empty()
+	err = snapshot.ApplyBlock(b1.UnsignedBlock)
+	if err == nil {
+		err = n.Chain.CommitAppliedBlock(ctx, b1, snapshot)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "committing initial block")
+	}
+	n.pool = mempool.New(n.Chain.State(), cfg.MaxPoolTxs)
+
+	for i, a := range cfg.Allocations {
+		tx, err := n.Issue(a.Tag, a.Amount, a.Pubkey)
+		if err == nil {
+			err = n.Submit(tx)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "allocation %d", i)
+		}
+	}
+	_, err = n.MakeBlock(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "making funding block")
+	}
+	return n, nil
+}
+
+// Interval returns the difference between consecutive block
+// timestamps.
+func (n *Net) Interval() time.Duration {
+	return n.interval
+}
+
+// Now returns the timestamp the next block will have.
+func (n *Net) Now() time.Time {
+	return bc.FromMillis(n.Chain.State().TimestampMS()).Add(n.interval)
+}
+
+// AssetID returns the ID of the asset the Net issues with tag.
+func (n *Net) AssetID(tag []byte) bc.Hash {
+	return bc.NewHash(standard.AssetID(issuanceVersion, 1, n.issuerPubkeys(), tag))
+}
+
+func (n *Net) issuerPubkeys() []ed25519.PublicKey {
+	return []ed25519.PublicKey{n.IssuerKey.Public().(ed25519.PublicKey)}
+}
+
+// Issue returns a transaction issuing amount units of the asset with
+// the given tag to pubkey. The transaction is valid for the next
+// hundred blocks. Issue does not submit it.
+func (n *Net) Issue(tag []byte, amount int64, pubkey ed25519.PublicKey) (*bc.Tx, error) {
+	n.mu.Lock()
+	nonce := make([]byte, 8)
+	binary.LittleEndian.PutUint64(nonce, n.nonces)
+	n.nonces++
+	n.mu.Unlock()
+
+	now := n.Now()
+	tpl := txbuilder.NewTemplate(now.Add(txTTL*n.interval), nil)
+	tpl.RestrictMinTime(now.Add(-n.interval))
+	issuers := n.issuerPubkeys()
+	tpl.AddIssuance(issuanceVersion, n.Chain.InitialBlockHash.Bytes(), tag, 1, [][]byte{issuers[0]}, nil, issuers, amount, nil, nonce)
+	tpl.AddOutput(1, []ed25519.PublicKey{pubkey}, amount, n.AssetID(tag), nil, nil)
+	err := tpl.Sign(context.Background(), func(_ context.Context, msg, _ []byte, _ [][]byte) ([]byte, error) {
+		return ed25519.Sign(n.IssuerKey, msg), nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "signing")
+	}
+	return tpl.Tx()
+}
+
+// Submit adds tx to the pool of transactions for the next block.
+func (n *Net) Submit(tx *bc.Tx) error {
+	return n.pool.Add(tx)
+}
+
+// Pending returns the number of transactions waiting for a block.
+func (n *Net) Pending() int {
+	return n.pool.Len()
+}
+
+// MakeBlock makes, signs, and commits a block containing the pending
+// transactions, timestamped one interval after the previous block.
+func (n *Net) MakeBlock(ctx context.Context) (*bc.Block, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	prev := n.Chain.State()
+	ub, snapshot, err := n.Chain.GenerateBlock(ctx, bc.Millis(n.Now()), n.pool.Pending())
+	if err != nil {
+		return nil, errors.Wrap(err, "generating block")
+	}
+	hash := ub.BlockHeader.Hash().Bytes()
+	b, err := bc.SignBlock(ub, prev.Header, func(int) (interface{}, error) {
+		return ed25519.Sign(n.BlockKey, hash), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = n.Chain.CommitAppliedBlock(ctx, b, snapshot)
+	if err != nil {
+		return nil, errors.Wrap(err, "committing block")
+	}
+	n.pool.Update(n.Chain.State(), b)
+	return b, nil
+}
+
+// Run makes a block once per interval of real time until ctx is
+// done.
+func (n *Net) Run(ctx context.Context) {
+	ticker := time.NewTicker(n.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		_, err := n.MakeBlock(ctx)
+		if err != nil {
+			log.Error(ctx, err, "making devnet block")
+		}
+	}
+}
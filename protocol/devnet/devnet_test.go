@@ -0,0 +1,201 @@
+package devnet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/txresult"
+)
+
+func testConfig(t *testing.T) (Config, ed25519.PublicKey) {
+	pub, _, err := ed25519.GenerateKey(bytes.NewReader(make([]byte, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Config{
+		Seed:     []byte("test"),
+		Interval: 5 * time.Second,
+		Allocations: []Allocation{
+			{Tag: []byte("gold"), Amount: 1000, Pubkey: pub},
+			{Tag: []byte("silver"), Amount: 50, Pubkey: pub},
+		},
+	}, pub
+}
+
+func TestGenesis(t *testing.T) {
+	ctx := context.Background()
+	cfg, pub := testConfig(t)
+	n, err := New(ctx, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := n.Chain.Height(); h != 2 {
+		t.Fatalf("height after New = %d, want 2", h)
+	}
+	b2, err := n.Chain.GetBlock(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := bc.FromMillis(b2.TimestampMs), DefaultStart.Add(cfg.Interval); !got.Equal(want) {
+		t.Errorf("funding block time = %s, want %s", got, want)
+	}
+	res := txresult.Results(b2.Transactions)
+	if len(res) != len(cfg.Allocations) {
+		t.Fatalf("funding block has %d txs, want %d", len(res), len(cfg.Allocations))
+	}
+	for i, a := range cfg.Allocations {
+		outs := res[i].Outputs
+		if len(outs) != 1 || outs[0].Value == nil {
+			t.Fatalf("allocation %d: got %d outputs", i, len(outs))
+		}
+		out := outs[0]
+		if out.Value.AssetID != n.AssetID(a.Tag) || int64(out.Value.Amount) != a.Amount {
+			t.Errorf("allocation %d: got %d of %x, want %d of %x", i, out.Value.Amount, out.Value.AssetID.Bytes(), a.Amount, n.AssetID(a.Tag).Bytes())
+		}
+		if len(out.Pubkeys) != 1 || !bytes.Equal(out.Pubkeys[0], pub) {
+			t.Errorf("allocation %d: paid to %x, want %x", i, out.Pubkeys, pub)
+		}
+	}
+
+	// The same config gives the same chain.
+	n2, err := New(ctx, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Chain.State().Header.Hash() != n2.Chain.State().Header.Hash() {
+		t.Error("two nets from the same config differ")
+	}
+	cfg.Seed = []byte("other")
+	n3, err := New(ctx, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Chain.InitialBlockHash == n3.Chain.InitialBlockHash {
+		t.Error("nets with different seeds have the same initial block")
+	}
+}
+
+func TestMakeBlock(t *testing.T) {
+	ctx := context.Background()
+	cfg, pub := testConfig(t)
+	n, err := New(ctx, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		want := n.Now()
+		b, err := n.MakeBlock(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := bc.FromMillis(b.TimestampMs); !got.Equal(want) {
+			t.Errorf("block %d time = %s, want %s", b.Height, got, want)
+		}
+	}
+
+	tx, err := n.Issue([]byte("gold"), 5, pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Submit(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := n.MakeBlock(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Transactions) != 1 || b.Transactions[0].ID != tx.ID {
+		t.Errorf("block %d does not contain the submitted tx", b.Height)
+	}
+	if n.Pending() != 0 {
+		t.Errorf("%d txs still pending", n.Pending())
+	}
+}
+
+func TestFaucet(t *testing.T) {
+	ctx := context.Background()
+	cfg, _ := testConfig(t)
+	n, err := New(ctx, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	f := &Faucet{
+		Net:       n,
+		Tags:      [][]byte{[]byte("gold")},
+		MaxAmount: 100,
+		Limit:     2,
+		Window:    time.Minute,
+		Now:       func() time.Time { return now },
+	}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	pubs := make([]ed25519.PublicKey, 3)
+	for i := range pubs {
+		pubs[i], _, _ = ed25519.GenerateKey(nil)
+	}
+	drip := func(pub ed25519.PublicKey, tag string, amount int64) int {
+		body := fmt.Sprintf(`{"pubkey":"%x","asset_tag":"%x","amount":%d}`, pub, tag, amount)
+		resp, err := http.Post(srv.URL, "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			var dr dripResponse
+			if err := json.NewDecoder(resp.Body).Decode(&dr); err != nil {
+				t.Fatal(err)
+			}
+			if dr.AssetID != n.AssetID([]byte(tag)) {
+				t.Errorf("asset ID %x, want %x", dr.AssetID.Bytes(), n.AssetID([]byte(tag)).Bytes())
+			}
+		}
+		return resp.StatusCode
+	}
+
+	cases := []struct {
+		pub    ed25519.PublicKey
+		tag    string
+		amount int64
+		want   int
+	}{
+		{pubs[0], "gold", 10, http.StatusOK},
+		{pubs[0], "gold", 101, http.StatusBadRequest},
+		{pubs[0], "silver", 10, http.StatusBadRequest},
+		{pubs[0], "gold", 10, http.StatusOK},
+		{pubs[0], "gold", 10, http.StatusTooManyRequests}, // recipient limit
+		{pubs[1], "gold", 10, http.StatusTooManyRequests}, // client limit
+	}
+	for i, c := range cases {
+		if got := drip(c.pub, c.tag, c.amount); got != c.want {
+			t.Errorf("case %d: status %d, want %d", i, got, c.want)
+		}
+	}
+	if n.Pending() != 2 {
+		t.Errorf("%d txs pending, want 2", n.Pending())
+	}
+
+	now = now.Add(time.Minute)
+	if got := drip(pubs[2], "gold", 10); got != http.StatusOK {
+		t.Errorf("after window: status %d, want %d", got, http.StatusOK)
+	}
+
+	_, err = f.Drip("x", pubs[2], []byte("gold"), 0)
+	if errors.Root(err) != ErrAmount {
+		t.Errorf("zero amount: got %v, want %v", err, ErrAmount)
+	}
+	if _, err := n.MakeBlock(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
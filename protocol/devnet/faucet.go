@@ -0,0 +1,171 @@
+package devnet
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+)
+
+var (
+	// ErrRateLimited is returned by Faucet.Drip for a recipient or
+	// client that has had its limit of drips in the current window.
+	ErrRateLimited = errors.New("faucet rate limit exceeded")
+
+	// ErrAmount is returned by Faucet.Drip for an amount that is not
+	// positive or exceeds the faucet's maximum.
+	ErrAmount = errors.New("bad faucet amount")
+
+	// ErrTag is returned by Faucet.Drip for an asset the faucet does
+	// not dispense.
+	ErrTag = errors.New("faucet does not dispense that asset")
+)
+
+// Faucet issues small amounts of a Net's assets on request. Its
+// ServeHTTP method implements the faucet endpoint:
+//
+//	POST {"pubkey":"HEX","asset_tag":"HEX","amount":N}
+//
+// It responds with {"tx_id":"HEX","asset_id":"HEX"} and submits the
+// issuance to the Net, to go in its next block. A response with
+// status 429 means the request was rate limited.
+type Faucet struct {
+	Net *Net
+
+	// Tags lists the tags of the assets the faucet dispenses. If
+	// it is empty, it dispenses any asset.
+	Tags [][]byte
+
+	// MaxAmount is the most a single drip may ask for.
+	MaxAmount int64
+
+	// Limit is how many drips each recipient key, and each client
+	// address, may have per Window.
+	Limit  int
+	Window time.Duration
+
+	// Now returns the current time, for rate limiting. If it is
+	// nil, time.Now is used.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	history map[string][]time.Time // keyed by recipient or client
+}
+
+// Drip issues amount units of the asset with the given tag to
+// pubkey, on behalf of the client with the given address, and
+// submits the transaction.
+func (f *Faucet) Drip(client string, pubkey ed25519.PublicKey, tag []byte, amount int64) (*bc.Tx, error) {
+	if amount <= 0 || amount > f.MaxAmount {
+		return nil, errors.WithDetailf(ErrAmount, "amount %d, max %d", amount, f.MaxAmount)
+	}
+	if len(pubkey) != ed25519.PublicKeySize {
+		return nil, errors.New("bad pubkey")
+	}
+	if !f.dispenses(tag) {
+		return nil, errors.WithDetailf(ErrTag, "tag %x", tag)
+	}
+	err := f.admit("pubkey "+string(pubkey), "client "+client)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := f.Net.Issue(tag, amount, pubkey)
+	if err != nil {
+		return nil, err
+	}
+	return tx, f.Net.Submit(tx)
+}
+
+func (f *Faucet) dispenses(tag []byte) bool {
+	if len(f.Tags) == 0 {
+		return true
+	}
+	for _, t := range f.Tags {
+		if string(t) == string(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// admit records a drip for each of keys, or returns ErrRateLimited
+// without recording anything if any of them is at its limit.
+func (f *Faucet) admit(keys ...string) error {
+	now := time.Now()
+	if f.Now != nil {
+		now = f.Now()
+	}
+	cutoff := now.Add(-f.Window)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.history == nil {
+		f.history = make(map[string][]time.Time)
+	}
+	for _, k := range keys {
+		recent := f.history[k][:0]
+		for _, t := range f.history[k] {
+			if t.After(cutoff) {
+				recent = append(recent, t)
+			}
+		}
+		if len(recent) == 0 {
+			delete(f.history, k)
+		} else {
+			f.history[k] = recent
+		}
+		if len(recent) >= f.Limit {
+			return errors.WithDetailf(ErrRateLimited, "%d drips per %s", f.Limit, f.Window)
+		}
+	}
+	for _, k := range keys {
+		f.history[k] = append(f.history[k], now)
+	}
+	return nil
+}
+
+type dripRequest struct {
+	Pubkey   chainjson.HexBytes `json:"pubkey"`
+	AssetTag chainjson.HexBytes `json:"asset_tag"`
+	Amount   int64              `json:"amount"`
+}
+
+type dripResponse struct {
+	TxID    bc.Hash `json:"tx_id"`
+	AssetID bc.Hash `json:"asset_id"`
+}
+
+// ServeHTTP implements http.Handler.
+func (f *Faucet) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var dr dripRequest
+	err := json.NewDecoder(req.Body).Decode(&dr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	client, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		client = req.RemoteAddr
+	}
+	tx, err := f.Drip(client, ed25519.PublicKey(dr.Pubkey), dr.AssetTag, dr.Amount)
+	if errors.Root(err) == ErrRateLimited {
+		http.Error(w, errors.Detail(err), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dripResponse{TxID: tx.ID, AssetID: f.Net.AssetID(dr.AssetTag)})
+}
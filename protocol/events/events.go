@@ -0,0 +1,380 @@
+/*
+Package events turns the blockchain into a stream of events that a
+downstream service can follow, stop, and resume without missing any.
+
+Each committed block produces, in order: a Block event, then for
+each of its transactions a Tx event followed by an Output event for
+each of the transaction's outputs that the stream's Filter matches.
+Filtering on the server keeps a service watching many addresses
+from having to read every transaction.
+Since blocks never change once committed, neither do their events,
+and each event has a fixed position, its Cursor.
+
+A client persists the cursor of the last event it has finished
+processing. When it restarts, it opens a new Stream after that
+cursor, and receives every event from there on: first those of
+blocks already committed, read back from the chain's store, and then
+those of new blocks as they arrive. Delivery is at least once: an
+event processed before a crash whose cursor was not yet persisted is
+delivered again, so processing must tolerate repeats.
+*/
+package events
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/contracts"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txbuilder/txresult"
+)
+
+// ErrCursor is returned for a cursor that is malformed, or that names
+// a block other than the one the chain has at its height.
+var ErrCursor = errors.New("invalid event cursor")
+
+// Type tells what an Event reports.
+type Type int
+
+const (
+	// Block reports a committed block.
+	Block Type = iota
+
+	// Tx reports a transaction confirmed in a block.
+	Tx
+
+	// Output reports an output, created by a confirmed transaction,
+	// that the stream's Filter matches.
+	Output
+)
+
+var typeNames = []string{"block", "tx", "output"}
+
+func (t Type) String() string {
+	if t < 0 || int(t) >= len(typeNames) {
+		return "unknown"
+	}
+	return typeNames[t]
+}
+
+// MarshalText satisfies the TextMarshaler interface.
+func (t Type) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Cursor is the position of an event: the Index'th event of the
+// block at Height, whose ID is BlockID. Indexes count every output,
+// matched or not, so a cursor means the same thing to streams with
+// any filter. A stream checks that the chain's block at Height is
+// BlockID, unless BlockID is zero. The zero Cursor is the position
+// before the first event of the chain.
+type Cursor struct {
+	Height  uint64
+	Index   int
+	BlockID bc.Hash
+}
+
+// String returns c in the form HEIGHT-INDEX-BLOCKID, which
+// ParseCursor parses.
+func (c Cursor) String() string {
+	return fmt.Sprintf("%d-%d-%x", c.Height, c.Index, c.BlockID.Bytes())
+}
+
+// ParseCursor parses a cursor in the form returned by Cursor.String.
+// The empty string is the zero Cursor.
+func ParseCursor(s string) (Cursor, error) {
+	var c Cursor
+	if s == "" {
+		return c, nil
+	}
+	parts := strings.Split(s, "-")
+	if len(parts) != 3 {
+		return c, errors.WithDetailf(ErrCursor, "cursor %q", s)
+	}
+	var err error
+	c.Height, err = strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return c, errors.Sub(ErrCursor, err)
+	}
+	c.Index, err = strconv.Atoi(parts[1])
+	if err != nil || c.Index < 0 {
+		return c, errors.WithDetailf(ErrCursor, "index %q", parts[1])
+	}
+	err = c.BlockID.UnmarshalText([]byte(parts[2]))
+	if err != nil {
+		return c, errors.Sub(ErrCursor, err)
+	}
+	return c, nil
+}
+
+// MarshalText satisfies the TextMarshaler interface.
+func (c Cursor) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText satisfies the TextUnmarshaler interface.
+func (c *Cursor) UnmarshalText(b []byte) error {
+	var err error
+	*c, err = ParseCursor(string(b))
+	return err
+}
+
+// before tells whether c comes before d.
+func (c Cursor) before(d Cursor) bool {
+	return c.Height < d.Height || (c.Height == d.Height && c.Index < d.Index)
+}
+
+// Event is an item in a Stream.
+type Event struct {
+	Type        Type    `json:"type"`
+	Cursor      Cursor  `json:"cursor"`
+	Height      uint64  `json:"height"`
+	BlockID     bc.Hash `json:"block_id"`
+	TimestampMS uint64  `json:"timestamp_ms"`
+
+	// TxID is the transaction of a Tx or Output event.
+	TxID *bc.Hash `json:"tx_id,omitempty"`
+
+	// Output is the output of an Output event.
+	Output *OutputInfo `json:"output,omitempty"`
+}
+
+// OutputInfo describes the output of an Output event, as far as its
+// transaction's log tells (see txresult.Output). Contract names the
+// output's contract (see contracts.Label). Amount and AssetID are
+// zero for an output whose value is not logged.
+type OutputInfo struct {
+	ID       bc.Hash              `json:"id"`
+	Contract string               `json:"contract"`
+	AssetID  bc.Hash              `json:"asset_id"`
+	Amount   uint64               `json:"amount"`
+	Quorum   int                  `json:"quorum"`
+	Pubkeys  []chainjson.HexBytes `json:"pubkeys"`
+	RefData  chainjson.HexBytes   `json:"reference_data"`
+}
+
+// Source is where a Stream reads blocks. A *protocol.Chain is a
+// Source.
+type Source interface {
+	Height() uint64
+	GetBlock(ctx context.Context, height uint64) (*bc.Block, error)
+	BlockWaiter(height uint64) <-chan struct{}
+}
+
+// Filter selects the outputs a Stream reports. An output matches
+// if, for each list in the filter that is not empty, it matches one
+// entry of the list. So the empty Filter matches every output.
+type Filter struct {
+	// AssetIDs match outputs of those assets.
+	AssetIDs []bc.Hash `json:"asset_ids"`
+
+	// Predicates match standard outputs by the hashes of their
+	// predicates (see standard.PredicateHash), and Pubkeys match
+	// standard outputs to any set of keys including one of them.
+	Predicates []bc.Hash            `json:"predicates"`
+	Pubkeys    []chainjson.HexBytes `json:"pubkeys"`
+
+	// TagPrefixes match outputs whose reference data or token tags,
+	// or whose transaction's tags, begin with one of them.
+	TagPrefixes []chainjson.HexBytes `json:"tag_prefixes"`
+}
+
+// matcher is a Filter prepared for matching many outputs.
+type matcher struct {
+	assets, predicates map[bc.Hash]bool
+	pubkeys            map[string]bool
+	prefixes           [][]byte
+}
+
+func newMatcher(f *Filter) *matcher {
+	m := &matcher{
+		assets:     hashSet(f.AssetIDs),
+		predicates: hashSet(f.Predicates),
+		pubkeys:    make(map[string]bool),
+	}
+	for _, pk := range f.Pubkeys {
+		m.pubkeys[string(pk)] = true
+	}
+	for _, p := range f.TagPrefixes {
+		m.prefixes = append(m.prefixes, p)
+	}
+	return m
+}
+
+func hashSet(hashes []bc.Hash) map[bc.Hash]bool {
+	set := make(map[bc.Hash]bool)
+	for _, h := range hashes {
+		set[h] = true
+	}
+	return set
+}
+
+// match tells whether out, in the transaction with result res,
+// matches m.
+func (m *matcher) match(res *txresult.Result, out *txresult.Output) bool {
+	if len(m.assets) > 0 && (out.Value == nil || !m.assets[out.Value.AssetID]) {
+		return false
+	}
+	if len(m.predicates) > 0 && (len(out.Pubkeys) == 0 || !m.predicates[bc.NewHash(standard.PredicateHash(out.Quorum, out.Pubkeys))]) {
+		return false
+	}
+	if len(m.pubkeys) > 0 {
+		var found bool
+		for _, pk := range out.Pubkeys {
+			if m.pubkeys[string(pk)] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(m.prefixes) > 0 && !m.hasPrefix(out.RefData) && !m.hasPrefix(out.TokenTags) && !m.hasPrefix(res.Tags) {
+		return false
+	}
+	return true
+}
+
+func (m *matcher) hasPrefix(b []byte) bool {
+	for _, p := range m.prefixes {
+		if bytes.HasPrefix(b, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stream is the stream of events after a cursor. It is not safe
+// for concurrent use.
+type Stream struct {
+	src   Source
+	m     *matcher
+	after Cursor
+
+	height  uint64  // the last block read
+	pending []Event // events of that block not yet read
+}
+
+// New returns the stream of events in src after cursor after. The
+// stream's Output events are those of outputs that f matches; if f
+// is nil, there are none.
+func New(src Source, after Cursor, f *Filter) *Stream {
+	height := after.Height
+	if height > 0 {
+		height-- // reread the cursor's block to check its ID
+	}
+	s := &Stream{src: src, after: after, height: height}
+	if f != nil {
+		s.m = newMatcher(f)
+	}
+	return s
+}
+
+// Read reads events into evs, waiting until at least one is
+// available or ctx is done, and returns the number read. It reads
+// more than one only if they are available without waiting.
+func (s *Stream) Read(ctx context.Context, evs []Event) (int, error) {
+	var n int
+	for n < len(evs) {
+		if len(s.pending) == 0 {
+			if s.height >= s.src.Height() && n > 0 {
+				break
+			}
+			err := s.next(ctx)
+			if err != nil {
+				return n, err
+			}
+			continue
+		}
+		evs[n] = s.pending[0]
+		s.pending = s.pending[1:]
+		n++
+	}
+	return n, nil
+}
+
+// next reads the events of the next block into s.pending, waiting
+// for the block if necessary.
+func (s *Stream) next(ctx context.Context) error {
+	h := s.height + 1
+	if h > s.src.Height() {
+		select {
+		case <-s.src.BlockWaiter(h):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	b, err := s.src.GetBlock(ctx, h)
+	if err != nil {
+		return errors.Wrapf(err, "getting block %d", h)
+	}
+	evs := blockEvents(b, s.m)
+	if h == s.after.Height && !s.after.BlockID.IsZero() {
+		if evs[0].BlockID != s.after.BlockID {
+			return errors.WithDetailf(ErrCursor, "block %d is %x, not %x", h, evs[0].BlockID.Bytes(), s.after.BlockID.Bytes())
+		}
+	}
+	for len(evs) > 0 && !s.after.before(evs[0].Cursor) {
+		evs = evs[1:]
+	}
+	s.pending = evs
+	s.height = h
+	return nil
+}
+
+// blockEvents returns the events of b.
+func blockEvents(b *bc.Block, m *matcher) []Event {
+	id := b.Hash()
+	ev := func(typ Type) Event {
+		return Event{Type: typ, Height: b.Height, BlockID: id, TimestampMS: b.TimestampMs}
+	}
+	var index int
+	add := func(evs []Event, e Event) []Event {
+		e.Cursor = Cursor{Height: b.Height, Index: index, BlockID: id}
+		index++
+		return append(evs, e)
+	}
+	evs := add(nil, ev(Block))
+	for _, tx := range b.Transactions {
+		txid := tx.ID
+		e := ev(Tx)
+		e.TxID = &txid
+		evs = add(evs, e)
+		if m == nil {
+			index += len(tx.Outputs)
+			continue
+		}
+		res := txresult.New(tx)
+		for i, out := range res.Outputs {
+			if !m.match(res, out) {
+				index++
+				continue
+			}
+			e := ev(Output)
+			e.TxID = &txid
+			e.Output = outputInfo(out)
+			e.Output.Contract = contracts.Label(tx.Outputs[i].Seed.Bytes(), tx.Outputs[i].Stack)
+			evs = add(evs, e)
+		}
+	}
+	return evs
+}
+
+func outputInfo(out *txresult.Output) *OutputInfo {
+	info := &OutputInfo{ID: out.OutputID, Quorum: out.Quorum, RefData: out.RefData}
+	if out.Value != nil {
+		info.AssetID = out.Value.AssetID
+		info.Amount = out.Value.Amount
+	}
+	for _, pk := range out.Pubkeys {
+		info.Pubkeys = append(info.Pubkeys, chainjson.HexBytes(pk))
+	}
+	return info
+}
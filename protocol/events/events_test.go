@@ -0,0 +1,256 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txbuilder/txresult"
+	"i10r.io/protocol/txgen"
+	"i10r.io/testutil"
+)
+
+var all = new(Filter)
+
+func newTestChain(t *testing.T, blocks int) (*protocol.Chain, *txgen.Generator) {
+	c := prottest.NewChain(t)
+	g := txgen.New(1)
+	for i := 0; i < blocks; i++ {
+		makeBlock(t, c, g)
+	}
+	return c, g
+}
+
+func makeBlock(t *testing.T, c *protocol.Chain, g *txgen.Generator) {
+	txs, err := g.Txs(c.State(), time.Now(), 3)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	prottest.MakeBlock(t, c, txs)
+}
+
+// readAll reads s's events until it waits for a block.
+func readAll(t *testing.T, s *Stream) []Event {
+	var evs []Event
+	buf := make([]Event, 5)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		n, err := s.Read(ctx, buf)
+		cancel()
+		evs = append(evs, buf[:n]...)
+		if err == context.DeadlineExceeded {
+			return evs
+		}
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	c, _ := newTestChain(t, 4)
+
+	evs := readAll(t, New(c, Cursor{}, all))
+	var blocks, txs, outputs int
+	for i, ev := range evs {
+		if i > 0 && !evs[i-1].Cursor.before(ev.Cursor) {
+			t.Fatalf("event %d at %s does not follow %s", i, ev.Cursor, evs[i-1].Cursor)
+		}
+		switch ev.Type {
+		case Block:
+			blocks++
+		case Tx:
+			txs++
+		case Output:
+			outputs++
+		}
+	}
+	if blocks != int(c.Height()) || txs == 0 || outputs == 0 {
+		t.Errorf("got %d blocks, %d txs, %d outputs for a chain of %d blocks", blocks, txs, outputs, c.Height())
+	}
+
+	// Resuming after each event yields the rest.
+	for i := range evs {
+		got := readAll(t, New(c, evs[i].Cursor, all))
+		if len(got) != len(evs[i+1:]) || (len(got) > 0 && !reflect.DeepEqual(got, evs[i+1:])) {
+			t.Fatalf("after event %d at %s, got %d events, want %d", i, evs[i].Cursor, len(got), len(evs)-i-1)
+		}
+	}
+
+	// A stream without a filter skips outputs, but its cursors are
+	// the same.
+	for _, ev := range readAll(t, New(c, Cursor{}, nil)) {
+		if ev.Type == Output {
+			t.Fatalf("unfiltered stream got output event at %s", ev.Cursor)
+		}
+		var found bool
+		for _, want := range evs {
+			if reflect.DeepEqual(ev, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("unfiltered stream got %+v, not in filtered stream", ev)
+		}
+	}
+}
+
+func TestStreamWait(t *testing.T) {
+	ctx := context.Background()
+	c, g := newTestChain(t, 2)
+	evs := readAll(t, New(c, Cursor{}, nil))
+	s := New(c, evs[len(evs)-1].Cursor, nil)
+	next := c.Height() + 1
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]Event, 1)
+		n, err := s.Read(ctx, buf)
+		if err != nil || n != 1 {
+			t.Errorf("got %d events, error %v", n, err)
+			return
+		}
+		if buf[0].Type != Block || buf[0].Height != next {
+			t.Errorf("got %s event at height %d, want block %d", buf[0].Type, buf[0].Height, next)
+		}
+	}()
+	makeBlock(t, c, g)
+	<-done
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	s = New(c, Cursor{Height: c.Height(), Index: 1 << 20}, nil)
+	_, err := s.Read(ctx, make([]Event, 1))
+	if err != context.DeadlineExceeded {
+		t.Errorf("got error %v reading past the end, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	c, _ := newTestChain(t, 2)
+	evs := readAll(t, New(c, Cursor{}, nil))
+
+	cur := evs[len(evs)-1].Cursor
+	got, err := ParseCursor(cur.String())
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if got != cur {
+		t.Errorf("ParseCursor(%q) = %v, want %v", cur.String(), got, cur)
+	}
+	for _, bad := range []string{"1-2", "x-0-00", "1--1-00", "1-0-zz"} {
+		if _, err := ParseCursor(bad); errors.Root(err) != ErrCursor {
+			t.Errorf("ParseCursor(%q): got error %v, want %v", bad, err, ErrCursor)
+		}
+	}
+
+	// A cursor naming another block at its height is rejected.
+	cur.BlockID = evs[0].BlockID
+	_, err = New(c, cur, nil).Read(context.Background(), make([]Event, 1))
+	if errors.Root(err) != ErrCursor {
+		t.Errorf("got error %v, want %v", err, ErrCursor)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	c, _ := newTestChain(t, 4)
+	outputs := make(map[bc.Hash]*OutputInfo)
+	for _, ev := range readAll(t, New(c, Cursor{}, all)) {
+		if ev.Type == Output {
+			outputs[ev.Output.ID] = ev.Output
+		}
+	}
+	var out *OutputInfo
+	for _, o := range outputs {
+		out = o
+		break
+	}
+	var pubkeys []ed25519.PublicKey
+	for _, pk := range out.Pubkeys {
+		pubkeys = append(pubkeys, ed25519.PublicKey(pk))
+	}
+	pred := bc.NewHash(standard.PredicateHash(out.Quorum, pubkeys))
+
+	cases := []struct {
+		name   string
+		filter *Filter
+		want   func(*OutputInfo) bool
+	}{{
+		"asset",
+		&Filter{AssetIDs: []bc.Hash{out.AssetID}},
+		func(o *OutputInfo) bool { return o.AssetID == out.AssetID },
+	}, {
+		"predicate",
+		&Filter{Predicates: []bc.Hash{pred}},
+		func(o *OutputInfo) bool { return reflect.DeepEqual(o.Pubkeys, out.Pubkeys) && o.Quorum == out.Quorum },
+	}, {
+		"pubkey and asset",
+		&Filter{Pubkeys: out.Pubkeys[:1], AssetIDs: []bc.Hash{out.AssetID}},
+		func(o *OutputInfo) bool {
+			if o.AssetID != out.AssetID {
+				return false
+			}
+			for _, pk := range o.Pubkeys {
+				if bytes.Equal(pk, out.Pubkeys[0]) {
+					return true
+				}
+			}
+			return false
+		},
+	}, {
+		"tag prefix",
+		&Filter{TagPrefixes: []chainjson.HexBytes{[]byte("no such tag")}},
+		func(*OutputInfo) bool { return false },
+	}}
+	for _, tc := range cases {
+		got := make(map[bc.Hash]bool)
+		for _, ev := range readAll(t, New(c, Cursor{}, tc.filter)) {
+			if ev.Type == Output {
+				got[ev.Output.ID] = true
+			}
+		}
+		var n int
+		for id, o := range outputs {
+			if tc.want(o) {
+				n++
+				if !got[id] {
+					t.Errorf("%s: filter missed output %x", tc.name, id.Bytes())
+				}
+			}
+		}
+		if len(got) != n {
+			t.Errorf("%s: got %d outputs, want %d", tc.name, len(got), n)
+		}
+	}
+}
+
+func TestMatchTags(t *testing.T) {
+	m := newMatcher(&Filter{TagPrefixes: []chainjson.HexBytes{[]byte("inv-"), []byte("ord-")}})
+	cases := []struct {
+		out  *txresult.Output
+		tags string
+		want bool
+	}{
+		{&txresult.Output{RefData: []byte("ord-1234")}, "", true},
+		{&txresult.Output{TokenTags: []byte("inv-9")}, "", true},
+		{&txresult.Output{RefData: []byte("x")}, "inv-7", true},
+		{&txresult.Output{RefData: []byte("x-ord-")}, "", false},
+		{&txresult.Output{}, "", false},
+	}
+	for _, c := range cases {
+		got := m.match(&txresult.Result{Tags: []byte(c.tags)}, c.out)
+		if got != c.want {
+			t.Errorf("match(tags %q, %+v) = %v, want %v", c.tags, c.out, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Package fee implements transaction fees.
+//
+// A transaction pays a fee by retiring value with a log entry marking
+// the retirement as a fee: a log-typed entry, from the same contract
+// and immediately after the retirement, whose data is Marker. The
+// standard retirement contract produces exactly that when its
+// reference data is Marker (see txbuilder.Template.AddFee).
+//
+// A block of version bc.CommitmentsVersion or later may carry, in its
+// header's commitments area, a Claim naming the keys entitled to its
+// fees. For each asset in which the block's transactions pay fees,
+// applying the block then creates one output holding the total,
+// locked with the standard pay-to-multisig contract to the claim's
+// keys. Typically the block's proposer names its own key. Fees in a
+// block with no claim are simply retired.
+package fee
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/math/checked"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/validation"
+)
+
+// Marker is the log data that marks the retirement before it as a
+// fee.
+var Marker = []byte("fee")
+
+// Commitment is the name of the header commitment holding a block's
+// fee claim.
+const Commitment = "fees"
+
+// ErrClaim is returned for a malformed fee claim.
+var ErrClaim = errors.New("malformed fee claim")
+
+func init() {
+	validation.RegisterCommitment(Commitment, func(_ *bc.UnsignedBlock, value []byte) error {
+		_, err := ParseClaim(value)
+		return err
+	})
+}
+
+// Paid returns the retirements in tx that are fees.
+func Paid(tx *bc.Tx) []bc.Retirement {
+	var fees []bc.Retirement
+	for _, ret := range tx.Retirements {
+		i := ret.LogPos
+		if i+1 >= len(tx.Log) {
+			continue
+		}
+		next := tx.Log[i+1]
+		if len(next) != 3 {
+			continue
+		}
+		if code, ok := next[0].(txvm.Bytes); !ok || len(code) != 1 || code[0] != txvm.LogCode {
+			continue
+		}
+		if cid, ok := next[1].(txvm.Bytes); !ok || !bytes.Equal(cid, tx.Log[i][1].(txvm.Bytes)) {
+			continue // logged by a different contract
+		}
+		if data, ok := next[2].(txvm.Bytes); ok && bytes.Equal(data, Marker) {
+			fees = append(fees, ret)
+		}
+	}
+	return fees
+}
+
+// Amount returns the total fee tx pays in the given asset. It
+// returns false if the total overflows.
+func Amount(tx *bc.Tx, assetID bc.Hash) (int64, bool) {
+	var total int64
+	for _, ret := range Paid(tx) {
+		if ret.AssetID != assetID {
+			continue
+		}
+		var ok bool
+		total, ok = checked.AddInt64(total, ret.Amount)
+		if !ok {
+			return 0, false
+		}
+	}
+	return total, true
+}
+
+// Rate returns the fee tx pays in the given asset per unit of its
+// runlimit.
+func Rate(tx *bc.Tx, assetID bc.Hash) float64 {
+	amount, ok := Amount(tx, assetID)
+	if !ok || tx.Runlimit <= 0 {
+		return 0
+	}
+	return float64(amount) / float64(tx.Runlimit)
+}
+
+// AddTotals returns a copy of totals, which maps asset IDs to the
+// fees paid in them, with the fees tx pays added. It returns
+// checked.ErrOverflow if a total overflows. A block whose fees
+// overflow cannot be built or applied, so a proposer keeping the
+// totals of the transactions it has chosen can use AddTotals to turn
+// away the one that would overflow them.
+func AddTotals(totals map[bc.Hash]int64, tx *bc.Tx) (map[bc.Hash]int64, error) {
+	sums := make(map[bc.Hash]int64, len(totals)+1)
+	for assetID, amount := range totals {
+		sums[assetID] = amount
+	}
+	for _, ret := range Paid(tx) {
+		sum, ok := checked.AddInt64(sums[ret.AssetID], ret.Amount)
+		if !ok {
+			return nil, errors.WithDetailf(checked.ErrOverflow, "fees in asset %x", ret.AssetID.Bytes())
+		}
+		sums[ret.AssetID] = sum
+	}
+	return sums, nil
+}
+
+// Claim is a quorum of keys entitled to a block's fees.
+type Claim struct {
+	Quorum  int
+	Pubkeys []ed25519.PublicKey
+}
+
+// Value returns the encoding of c for a header commitment: the
+// quorum as a uvarint, then the concatenated public keys.
+func (c *Claim) Value() []byte {
+	buf := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(c.Pubkeys)*ed25519.PublicKeySize)
+	buf = buf[:binary.PutUvarint(buf, uint64(c.Quorum))]
+	for _, pk := range c.Pubkeys {
+		buf = append(buf, pk...)
+	}
+	return buf
+}
+
+// ParseClaim parses the value of a fee claim commitment.
+func ParseClaim(value []byte) (*Claim, error) {
+	q, n := binary.Uvarint(value)
+	if n <= 0 {
+		return nil, errors.WithDetail(ErrClaim, "bad quorum")
+	}
+	value = value[n:]
+	if len(value) == 0 || len(value)%ed25519.PublicKeySize != 0 {
+		return nil, errors.WithDetailf(ErrClaim, "%d bytes of pubkeys", len(value))
+	}
+	c := new(Claim)
+	for len(value) > 0 {
+		c.Pubkeys = append(c.Pubkeys, ed25519.PublicKey(value[:ed25519.PublicKeySize]))
+		value = value[ed25519.PublicKeySize:]
+	}
+	if q < 1 || q > uint64(len(c.Pubkeys)) {
+		return nil, errors.WithDetailf(ErrClaim, "quorum %d of %d", q, len(c.Pubkeys))
+	}
+	c.Quorum = int(q)
+	return c, nil
+}
+
+// An Output is a fee output created by applying a block.
+type Output struct {
+	ID      bc.Hash
+	AssetID bc.Hash
+	Amount  int64
+	Anchor  []byte
+	Claim   *Claim
+}
+
+// Outputs returns the fee outputs that applying b creates, in asset
+// ID order. It returns none for a block with no fee claim.
+func Outputs(b *bc.UnsignedBlock) ([]Output, error) {
+	if b.Version < bc.CommitmentsVersion {
+		return nil, nil
+	}
+	value, ok := b.Commitment(Commitment)
+	if !ok {
+		return nil, nil
+	}
+	claim, err := ParseClaim(value)
+	if err != nil {
+		return nil, err
+	}
+	var prevID bc.Hash
+	if b.PreviousBlockId != nil {
+		prevID = *b.PreviousBlockId
+	}
+	return BlockOutputs(prevID, b.Transactions, claim)
+}
+
+// BlockOutputs returns the fee outputs for a block with the given
+// previous block ID, transactions, and fee claim.
+func BlockOutputs(prevID bc.Hash, txs []*bc.Tx, claim *Claim) ([]Output, error) {
+	var totals map[bc.Hash]int64
+	for _, tx := range txs {
+		var err error
+		totals, err = AddTotals(totals, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var outs []Output
+	for assetID, amount := range totals {
+		if amount == 0 {
+			continue
+		}
+		anchor := txvm.VMHash("FeeAnchor", txvm.Encode(txvm.Tuple{
+			txvm.Bytes(prevID.Bytes()),
+			txvm.Bytes(assetID.Bytes()),
+		}))
+		outs = append(outs, Output{
+			ID:      standard.MultisigOutputID(claim.Quorum, claim.Pubkeys, amount, assetID, anchor[:], standard.PayToMultisigSeed2[:]),
+			AssetID: assetID,
+			Amount:  amount,
+			Anchor:  anchor[:],
+			Claim:   claim,
+		})
+	}
+	sort.Slice(outs, func(i, j int) bool {
+		return bytes.Compare(outs[i].AssetID.Bytes(), outs[j].AssetID.Bytes()) < 0
+	})
+	return outs, nil
+}
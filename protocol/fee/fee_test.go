@@ -0,0 +1,211 @@
+package fee_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/math/checked"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/fee"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/validation"
+)
+
+type keys struct {
+	pub ed25519.PublicKey
+	prv ed25519.PrivateKey
+}
+
+func newKeys(t *testing.T) keys {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys{pub, prv}
+}
+
+func (k keys) sign(_ context.Context, msg, _ []byte, _ [][]byte) ([]byte, error) {
+	return ed25519.Sign(k.prv, msg), nil
+}
+
+// issueWithFee issues amount of an asset controlled by k to k,
+// paying feeAmount of it as a fee and retiring another retire units.
+func issueWithFee(t *testing.T, c *protocol.Chain, k keys, amount, feeAmount, retire int64) (*bc.Tx, bc.Hash) {
+	pubs := []ed25519.PublicKey{k.pub}
+	tag := []byte("gold")
+	assetID := bc.NewHash(standard.AssetID(2, 1, pubs, tag))
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.AddIssuance(2, c.InitialBlockHash.Bytes(), tag, 1, [][]byte{k.pub}, nil, pubs, amount, nil, []byte(t.Name()))
+	tpl.AddOutput(1, pubs, amount-feeAmount-retire, assetID, nil, nil)
+	if feeAmount > 0 {
+		tpl.AddFee(feeAmount, assetID)
+	}
+	if retire > 0 {
+		tpl.AddRetirement(retire, assetID, []byte("not a fee"))
+	}
+	err := tpl.Sign(context.Background(), k.sign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := tpl.Tx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx, assetID
+}
+
+func TestPaid(t *testing.T) {
+	c := prottest.NewChain(t)
+	k := newKeys(t)
+	tx, assetID := issueWithFee(t, c, k, 100, 7, 3)
+
+	paid := fee.Paid(tx)
+	if len(paid) != 1 || paid[0].Amount != 7 || paid[0].AssetID != assetID {
+		t.Fatalf("Paid = %+v, want one fee of 7", paid)
+	}
+	if amt, ok := fee.Amount(tx, assetID); !ok || amt != 7 {
+		t.Errorf("Amount = %d, %v, want 7, true", amt, ok)
+	}
+	if amt, _ := fee.Amount(tx, bc.Hash{}); amt != 0 {
+		t.Errorf("Amount in other asset = %d, want 0", amt)
+	}
+	if got, want := fee.Rate(tx, assetID), 7/float64(tx.Runlimit); got != want {
+		t.Errorf("Rate = %g, want %g", got, want)
+	}
+}
+
+func TestFeeOverflow(t *testing.T) {
+	c := prottest.NewChain(t)
+	user, proposer := newKeys(t), newKeys(t)
+	bb := c.BlockBuilder()
+	bb.Version = bc.CommitmentsVersion
+	bb.FeeClaim = &fee.Claim{Quorum: 1, Pubkeys: []ed25519.PublicKey{proposer.pub}}
+
+	tx1, _ := issueWithFee(t, c, user, math.MaxInt64, math.MaxInt64-1, 0)
+	tx2, _ := issueWithFee(t, c, user, math.MaxInt64, math.MaxInt64-2, 0)
+	totals, err := fee.AddTotals(nil, tx1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fee.AddTotals(totals, tx2); errors.Root(err) != checked.ErrOverflow {
+		t.Errorf("AddTotals: got error %v, want %v", err, checked.ErrOverflow)
+	}
+
+	err = bb.Start(c.State(), c.State().TimestampMS()+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bb.AddTx(bc.NewCommitmentsTx(tx1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := bb.AddTx(bc.NewCommitmentsTx(tx2)); errors.Root(err) != checked.ErrOverflow {
+		t.Errorf("AddTx: got error %v, want %v", err, checked.ErrOverflow)
+	}
+	b, _, err := bb.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Transactions) != 1 {
+		t.Errorf("block has %d txs, want 1", len(b.Transactions))
+	}
+}
+
+func TestClaim(t *testing.T) {
+	k1, k2 := newKeys(t), newKeys(t)
+	c := &fee.Claim{Quorum: 1, Pubkeys: []ed25519.PublicKey{k1.pub, k2.pub}}
+	got, err := fee.ParseClaim(c.Value())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Quorum != 1 || len(got.Pubkeys) != 2 || string(got.Pubkeys[1]) != string(k2.pub) {
+		t.Errorf("ParseClaim(Value()) = %+v, want %+v", got, c)
+	}
+
+	bad := []*fee.Claim{
+		{Quorum: 0, Pubkeys: c.Pubkeys},
+		{Quorum: 3, Pubkeys: c.Pubkeys},
+		{Quorum: 1},
+	}
+	for i, c := range bad {
+		if _, err := fee.ParseClaim(c.Value()); errors.Root(err) != fee.ErrClaim {
+			t.Errorf("case %d: got error %v, want %v", i, err, fee.ErrClaim)
+		}
+	}
+	if _, err := fee.ParseClaim(append(c.Value(), 0)); errors.Root(err) != fee.ErrClaim {
+		t.Errorf("trailing byte: got error %v, want %v", err, fee.ErrClaim)
+	}
+}
+
+func TestFeeOutput(t *testing.T) {
+	c := prottest.NewChain(t)
+	user, proposer := newKeys(t), newKeys(t)
+	bb := c.BlockBuilder()
+	bb.Version = bc.CommitmentsVersion
+	bb.FeeClaim = &fee.Claim{Quorum: 1, Pubkeys: []ed25519.PublicKey{proposer.pub}}
+
+	tx1, assetID := issueWithFee(t, c, user, 100, 5, 0)
+	tx2, _ := issueWithFee(t, c, user, 50, 2, 1)
+	prev := c.State()
+	b := prottest.MakeBlock(t, c, []*bc.Tx{tx1, tx2})
+	if len(b.Transactions) != 2 {
+		t.Fatalf("block has %d txs, want 2", len(b.Transactions))
+	}
+
+	outs, err := fee.Outputs(b.UnsignedBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outs) != 1 || outs[0].Amount != 7 || outs[0].AssetID != assetID {
+		t.Fatalf("fee outputs = %+v, want one of 7", outs)
+	}
+	if !c.State().ContractsTree.Contains(outs[0].ID.Bytes()) {
+		t.Fatal("fee output not in state")
+	}
+
+	// A validator applying the block gets the same state.
+	err = validation.Block(b.UnsignedBlock, prev.Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := state.Copy(prev)
+	err = snapshot.ApplyBlock(b.UnsignedBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.ContractsTree.RootHash() != b.ContractsRoot.Byte32() {
+		t.Error("applying the block gives a different contracts root")
+	}
+
+	// The proposer can spend the fee output.
+	out := outs[0]
+	pubs := []ed25519.PublicKey{proposer.pub}
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.AddInput(1, [][]byte{proposer.pub}, nil, pubs, out.Amount, out.AssetID, out.Anchor, nil, 2)
+	tpl.AddOutput(1, pubs, out.Amount, out.AssetID, nil, nil)
+	err = tpl.Sign(context.Background(), proposer.sign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend, err := tpl.Tx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spend.Inputs[0].ID != out.ID {
+		t.Fatalf("spending %x, want fee output %x", spend.Inputs[0].ID.Bytes(), out.ID.Bytes())
+	}
+	b = prottest.MakeBlock(t, c, []*bc.Tx{spend})
+	if len(b.Transactions) != 1 {
+		t.Fatal("fee output spend not included in block")
+	}
+	if c.State().ContractsTree.Contains(out.ID.Bytes()) {
+		t.Error("fee output still in state after spend")
+	}
+}
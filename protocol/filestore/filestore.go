@@ -0,0 +1,478 @@
+// Package filestore is a protocol.Store implementation that keeps
+// blockchain data in a directory on disk.
+//
+// Each block is a file named HEIGHT.block in the blocks
+// subdirectory, holding the block's protobuf encoding (the same
+// format the block command writes). Each state snapshot is a file
+// named HEIGHT.snapshot in the snapshots subdirectory. (A store made
+// before snapshots were kept by height has just one, in a file named
+// snapshot.) Files are written to temporary names and renamed into
+// place, so a crash leaves either the old or the new contents, never
+// a partial file.
+//
+// It is meant for development nodes and tools. It keeps no index
+// beyond the file names and is not safe for use by more than one
+// process at a time.
+//
+// Open refuses a store whose files are not consistent with one
+// another, as after a disk error or a hand edit, rather than serve a
+// corrupted state. Repair rolls such a store back to where it is
+// consistent.
+//
+// Snapshots accumulate as they are saved, with only the latest in
+// use. Collect removes the older ones.
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txvm"
+)
+
+var (
+	// ErrGap is returned by SaveBlock for a block that does not
+	// immediately follow the latest one.
+	ErrGap = errors.New("block does not follow latest block")
+
+	// ErrCorrupt is returned by Open for a store whose contents are
+	// not consistent.
+	ErrCorrupt = errors.New("store is corrupt")
+)
+
+// Store satisfies the protocol.Store interface.
+type Store struct {
+	dir string
+
+	opened  time.Time
+	plugins *txvm.Plugins
+
+	mu     sync.Mutex
+	height uint64
+}
+
+// Open opens the store in dir, creating the directory if necessary.
+//
+// It checks that the store is consistent: that its blocks, from the
+// initial block to the latest, are all present and readable and each
+// follows the one before it, and that its latest snapshot is the
+// state as of one of them, with that block's roots. If not, it returns an error
+// whose root is ErrCorrupt; see Repair.
+func Open(dir string) (*Store, error) {
+	s, _, err := open(dir)
+	if err != nil {
+		return nil, err
+	}
+	d, err := s.check()
+	if err != nil {
+		return nil, err
+	}
+	if d != nil {
+		return nil, errors.WithDetailf(ErrCorrupt, "%s: %s", dir, d.reason)
+	}
+	return s, nil
+}
+
+// Repair opens the store in dir as Open does, but rather than refuse
+// a store that is not consistent, it rolls the store back to the
+// latest block up to which it is: it removes the blocks after that
+// one, and the snapshots later than the latest that is the state as
+// of a block that remains. (The state is then brought up to date
+// from the remaining blocks by protocol.Chain.Recover.) Removed files are moved to the corrupt
+// subdirectory of dir, not deleted, and their names are returned.
+//
+// If the initial block itself is bad, the store is left empty.
+func Repair(dir string) (*Store, []string, error) {
+	s, heights, err := open(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	d, err := s.check()
+	if err != nil || d == nil {
+		return s, nil, err
+	}
+
+	var bad []string
+	for _, h := range heights {
+		if h > d.height {
+			bad = append(bad, filepath.Join("blocks", fmt.Sprintf("%d.block", h)))
+		}
+	}
+	bad = append(d.snapshots, bad...)
+	err = os.MkdirAll(filepath.Join(dir, "corrupt"), 0700)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "creating corrupt directory")
+	}
+	var moved []string
+	// Remove the latest blocks first, so that an interrupted repair
+	// leaves no gap.
+	for i := len(bad) - 1; i >= 0; i-- {
+		name := bad[i]
+		err = os.Rename(filepath.Join(dir, name), filepath.Join(dir, "corrupt", filepath.Base(name)))
+		if err != nil {
+			return nil, moved, errors.Wrapf(err, "removing %s", name)
+		}
+		moved = append(moved, name)
+	}
+	s.height = d.height
+	return s, moved, nil
+}
+
+// open opens the store in dir without checking it. It also returns
+// the heights of the block files it finds, in order.
+func open(dir string) (*Store, []uint64, error) {
+	err := os.MkdirAll(filepath.Join(dir, "blocks"), 0700)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "creating store directory")
+	}
+	err = os.MkdirAll(filepath.Join(dir, "snapshots"), 0700)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "creating store directory")
+	}
+	s := &Store{dir: dir, opened: time.Now()}
+	heights, err := s.heights("blocks", ".block")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(heights) > 0 {
+		s.height = heights[len(heights)-1]
+	}
+	return s, heights, nil
+}
+
+// heights returns the heights of the files in subdirectory sub of
+// s's directory named HEIGHT followed by suffix, in order.
+func (s *Store) heights(sub, suffix string) ([]uint64, error) {
+	names, err := ioutil.ReadDir(filepath.Join(s.dir, sub))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading store directory")
+	}
+	var heights []uint64
+	for _, fi := range names {
+		h, ok := parseName(fi.Name(), suffix)
+		if ok {
+			heights = append(heights, h)
+		}
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights, nil
+}
+
+// snapshots returns the names of s's snapshot files, relative to
+// its directory, latest first.
+func (s *Store) snapshots() ([]string, error) {
+	heights, err := s.heights("snapshots", ".snapshot")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for i := len(heights) - 1; i >= 0; i-- {
+		names = append(names, snapshotName(heights[i]))
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, "snapshot")); err == nil {
+		names = append(names, "snapshot")
+	}
+	return names, nil
+}
+
+// damage describes where a store stops being consistent.
+type damage struct {
+	height    uint64   // the latest block up to which the store is consistent
+	snapshots []string // the snapshots later than the latest good one
+	reason    string
+}
+
+// check checks s, returning nil if it is consistent. It reads
+// only the blocks' headers, not running their transactions.
+func (s *Store) check() (*damage, error) {
+	var (
+		headers []*bc.BlockHeader
+		d       *damage
+	)
+	for h := uint64(1); h <= s.height; h++ {
+		bits, err := ioutil.ReadFile(s.blockPath(h))
+		if os.IsNotExist(err) {
+			d = &damage{reason: fmt.Sprintf("missing block %d of %d", h, s.height)}
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading block %d", h)
+		}
+		rb, err := bc.DecodeRawBlock(bits)
+		if err != nil || rb.Header == nil {
+			d = &damage{reason: fmt.Sprintf("block %d is unreadable", h)}
+			break
+		}
+		hdr := rb.Header
+		if hdr.Height != h {
+			d = &damage{reason: fmt.Sprintf("block %d has height %d", h, hdr.Height)}
+			break
+		}
+		if h > 1 {
+			if prev := headers[h-2]; hdr.PreviousBlockId == nil || *hdr.PreviousBlockId != prev.Hash() {
+				d = &damage{reason: fmt.Sprintf("block %d does not follow block %d", h, h-1)}
+				break
+			}
+		}
+		headers = append(headers, hdr)
+	}
+	good := uint64(len(headers))
+	if d != nil {
+		d.height = good
+	}
+
+	// The latest snapshot is the one in use. Look back from it for
+	// a good one, to which Repair can roll back.
+	names, err := s.snapshots()
+	if err != nil {
+		return nil, err
+	}
+	var bad []string
+	for _, name := range names {
+		bits, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", name)
+		}
+		reason := checkSnapshot(bits, headers)
+		if reason == "" {
+			break
+		}
+		if len(bad) == 0 {
+			if d == nil {
+				d = &damage{height: good}
+			} else {
+				d.reason += "; "
+			}
+			d.reason += name + ": " + reason
+		}
+		bad = append(bad, name)
+	}
+	if d != nil {
+		d.snapshots = bad
+	}
+	return d, nil
+}
+
+// checkSnapshot checks the encoded snapshot in bits against headers,
+// the headers of the good blocks, returning the reason it is bad, or
+// "" if it is good.
+func checkSnapshot(bits []byte, headers []*bc.BlockHeader) string {
+	snapshot := state.Empty()
+	switch err := snapshot.FromBytes(bits); {
+	case err != nil || snapshot.Header == nil,
+		snapshot.Height() == 0, snapshot.Header.NextPredicate == nil:
+		return "unreadable"
+	case snapshot.Height() > uint64(len(headers)):
+		return fmt.Sprintf("height %d is past the last good block, %d", snapshot.Height(), len(headers))
+	case snapshot.Header.Hash() != headers[snapshot.Height()-1].Hash():
+		return fmt.Sprintf("header differs from block %d", snapshot.Height())
+	case !rootsMatch(snapshot, headers[snapshot.Height()-1]):
+		return fmt.Sprintf("roots differ from block %d", snapshot.Height())
+	}
+	return ""
+}
+
+func rootsMatch(snapshot *state.Snapshot, hdr *bc.BlockHeader) bool {
+	return hdr.ContractsRoot != nil && hdr.ContractsRoot.Byte32() == snapshot.ContractsTree.RootHash() &&
+		hdr.NoncesRoot != nil && hdr.NoncesRoot.Byte32() == snapshot.NonceTree.RootHash()
+}
+
+// Dir returns the directory s was opened in.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Height satisfies the protocol.Store interface.
+func (s *Store) Height(context.Context) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.height, nil
+}
+
+// GetBlock satisfies the protocol.Store interface.
+func (s *Store) GetBlock(ctx context.Context, height uint64) (*bc.Block, error) {
+	bits, err := s.BlockBytes(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	b := new(bc.Block)
+	err = b.FromBytesContext(ctx, bits, txvm.WithPlugins(s.plugins))
+	return b, errors.Wrapf(err, "decoding block %d", height)
+}
+
+// SetPlugins makes GetBlock decode blocks with the plugin operations
+// in p (see txvm.WithPlugins). It must be called before s is used
+// concurrently; see protocol.Chain.SetPlugins.
+func (s *Store) SetPlugins(p *txvm.Plugins) {
+	s.plugins = p
+}
+
+// BlockBytes returns the encoding of the block at the given height,
+// as stored, without decoding it. (See package pipeline.)
+func (s *Store) BlockBytes(ctx context.Context, height uint64) ([]byte, error) {
+	bits, err := ioutil.ReadFile(s.blockPath(height))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("filestore: no block at height %d", height)
+	}
+	return bits, err
+}
+
+// SaveBlock satisfies the protocol.Store interface. Saving a block
+// identical to one already stored is a no-op.
+func (s *Store) SaveBlock(ctx context.Context, b *bc.Block) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if b.Height <= s.height {
+		existing, err := s.GetBlock(ctx, b.Height)
+		if err != nil {
+			return err
+		}
+		if existing.Hash() != b.Hash() {
+			return fmt.Errorf("already have a block at height %d", b.Height)
+		}
+		return nil
+	}
+	if b.Height != s.height+1 {
+		return errors.WithDetailf(ErrGap, "height %d, latest %d", b.Height, s.height)
+	}
+	bits, err := b.Bytes()
+	if err != nil {
+		return errors.Wrap(err, "encoding block")
+	}
+	err = writeFile(s.blockPath(b.Height), bits)
+	if err != nil {
+		return errors.Wrapf(err, "writing block %d", b.Height)
+	}
+	s.height = b.Height
+	return nil
+}
+
+// FinalizeHeight satisfies the protocol.Store interface.
+func (s *Store) FinalizeHeight(context.Context, uint64) error { return nil }
+
+// SaveSnapshot satisfies the protocol.Store interface.
+func (s *Store) SaveSnapshot(ctx context.Context, snapshot *state.Snapshot) error {
+	bits, err := snapshot.Bytes()
+	if err != nil {
+		return err
+	}
+	err = writeFile(filepath.Join(s.dir, snapshotName(snapshot.Height())), bits)
+	return errors.Wrap(err, "writing snapshot")
+}
+
+// LatestSnapshot satisfies the protocol.Store interface. It returns
+// an empty snapshot if none has been saved.
+func (s *Store) LatestSnapshot(context.Context) (*state.Snapshot, error) {
+	names, err := s.snapshots()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return state.Empty(), nil
+	}
+	bits, err := ioutil.ReadFile(filepath.Join(s.dir, names[0]))
+	if err != nil {
+		return nil, err
+	}
+	snapshot := state.Empty()
+	err = snapshot.FromBytes(bits)
+	return snapshot, errors.Wrap(err, "decoding snapshot")
+}
+
+// Collect removes the files s no longer needs: the snapshots older
+// than the latest keep of them (at least 1), and the temporary files
+// of writes interrupted before s was opened. It returns the names
+// of the files it removed.
+//
+// Collect is meant to run in the background, while blocks are
+// committed. It waits for pause after each removal, so that it does
+// not compete with them for the disk, and stops if ctx is done.
+func (s *Store) Collect(ctx context.Context, keep int, pause time.Duration) ([]string, error) {
+	names, err := s.snapshots()
+	if err != nil {
+		return nil, err
+	}
+	if keep < 1 {
+		keep = 1
+	}
+	var stale []string
+	if len(names) > keep {
+		stale = names[keep:]
+	}
+	for _, sub := range []string{".", "blocks", "snapshots"} {
+		infos, err := ioutil.ReadDir(filepath.Join(s.dir, sub))
+		if err != nil {
+			return nil, errors.Wrap(err, "reading store directory")
+		}
+		for _, fi := range infos {
+			// A temporary file modified since s was opened may belong
+			// to a write in progress.
+			if strings.HasSuffix(fi.Name(), ".tmp") && fi.ModTime().Before(s.opened) {
+				stale = append(stale, filepath.Join(sub, fi.Name()))
+			}
+		}
+	}
+
+	var removed []string
+	for _, name := range stale {
+		err := os.Remove(filepath.Join(s.dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return removed, errors.Wrapf(err, "removing %s", name)
+		}
+		removed = append(removed, name)
+		select {
+		case <-ctx.Done():
+			return removed, ctx.Err()
+		case <-time.After(pause):
+		}
+	}
+	return removed, nil
+}
+
+func (s *Store) blockPath(height uint64) string {
+	return filepath.Join(s.dir, "blocks", fmt.Sprintf("%d.block", height))
+}
+
+func snapshotName(height uint64) string {
+	return filepath.Join("snapshots", fmt.Sprintf("%d.snapshot", height))
+}
+
+func parseName(name, suffix string) (uint64, bool) {
+	if !strings.HasSuffix(name, suffix) {
+		return 0, false
+	}
+	h, err := strconv.ParseUint(strings.TrimSuffix(name, suffix), 10, 64)
+	return h, err == nil && h > 0
+}
+
+func writeFile(name string, bits []byte) error {
+	tmp := name + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(bits)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, name)
+}
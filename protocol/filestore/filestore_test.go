@@ -0,0 +1,330 @@
+package filestore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"i10r.io/errors"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/state"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := prottest.NewChain(t, prottest.WithStore(s))
+	b2 := prottest.MakeBlock(t, c, nil)
+	prottest.MakeBlock(t, c, nil)
+	err = s.SaveSnapshot(ctx, c.State())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b4 := prottest.MakeBlock(t, c, nil)
+
+	if err := s.SaveBlock(ctx, b2); err != nil {
+		t.Errorf("saving block 2 again: %v", err)
+	}
+	hdr := *b4.BlockHeader
+	hdr.Height = 6
+	b6 := &bc.Block{UnsignedBlock: &bc.UnsignedBlock{BlockHeader: &hdr}}
+	if err := s.SaveBlock(ctx, b6); errors.Root(err) != ErrGap {
+		t.Errorf("saving block 6 at height 4: got %v, want %v", err, ErrGap)
+	}
+
+	// Reopen and recover.
+	s2, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h, _ := s2.Height(ctx); h != 4 {
+		t.Fatalf("reopened height = %d, want 4", h)
+	}
+	got, err := s2.GetBlock(ctx, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hash() != b4.Hash() {
+		t.Errorf("block 4 hash = %x, want %x", got.Hash().Bytes(), b4.Hash().Bytes())
+	}
+	snap, err := s2.LatestSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Height() != 3 {
+		t.Errorf("snapshot height = %d, want 3", snap.Height())
+	}
+
+	b1, err := s2.GetBlock(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := protocol.NewChain(ctx, b1, s2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovered, err := c2.Recover(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovered.Height() != 4 || recovered.ContractsTree.RootHash() != c.State().ContractsTree.RootHash() {
+		t.Errorf("recovered state at height %d differs from original", recovered.Height())
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	ctx := context.Background()
+
+	// newStore makes a store of 5 blocks with a snapshot at block 3.
+	newStore := func(t *testing.T) string {
+		dir, err := ioutil.TempDir("", "filestore")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := prottest.NewChain(t, prottest.WithStore(s))
+		prottest.MakeBlock(t, c, nil)
+		prottest.MakeBlock(t, c, nil)
+		err = s.SaveSnapshot(ctx, c.State())
+		if err != nil {
+			t.Fatal(err)
+		}
+		prottest.MakeBlock(t, c, nil)
+		prottest.MakeBlock(t, c, nil)
+		return dir
+	}
+	block := func(dir string, h int) string {
+		return filepath.Join(dir, "blocks", strconv.Itoa(h)+".block")
+	}
+	// editSnapshot rewrites the snapshot at block 3 after changing it
+	// with f.
+	editSnapshot := func(dir string, f func(s *state.Snapshot)) error {
+		name := filepath.Join(dir, "snapshots", "3.snapshot")
+		bits, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		var s state.Snapshot
+		err = s.FromBytes(bits)
+		if err != nil {
+			return err
+		}
+		f(&s)
+		bits, err = s.Bytes()
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(name, bits, 0600)
+	}
+
+	cases := []struct {
+		name       string
+		corrupt    func(dir string) error
+		wantHeight uint64
+		wantMoved  []string
+	}{{
+		name:       "truncated block",
+		corrupt:    func(dir string) error { return ioutil.WriteFile(block(dir, 4), []byte{0x0a, 0xff}, 0600) },
+		wantHeight: 3,
+		wantMoved:  []string{"blocks/5.block", "blocks/4.block"},
+	}, {
+		name:       "missing block",
+		corrupt:    func(dir string) error { return os.Remove(block(dir, 5)) },
+		wantHeight: 4, // indistinguishable from a store of 4 blocks
+	}, {
+		name:       "gap",
+		corrupt:    func(dir string) error { return os.Remove(block(dir, 4)) },
+		wantHeight: 3,
+		wantMoved:  []string{"blocks/5.block"},
+	}, {
+		name: "swapped blocks",
+		corrupt: func(dir string) error {
+			err := os.Rename(block(dir, 2), filepath.Join(dir, "tmp"))
+			if err == nil {
+				err = os.Rename(block(dir, 3), block(dir, 2))
+			}
+			if err == nil {
+				err = os.Rename(filepath.Join(dir, "tmp"), block(dir, 3))
+			}
+			return err
+		},
+		wantHeight: 1,
+		wantMoved:  []string{"blocks/5.block", "blocks/4.block", "blocks/3.block", "blocks/2.block", "snapshots/3.snapshot"},
+	}, {
+		name: "wrong snapshot",
+		corrupt: func(dir string) error {
+			return editSnapshot(dir, func(s *state.Snapshot) {
+				s.ContractsTree.Insert(make([]byte, 32))
+			})
+		},
+		wantHeight: 5,
+		wantMoved:  []string{"snapshots/3.snapshot"},
+	}, {
+		name: "snapshot at height 0",
+		corrupt: func(dir string) error {
+			return editSnapshot(dir, func(s *state.Snapshot) {
+				h := *s.Header
+				h.Height = 0
+				s.Header = &h
+			})
+		},
+		wantHeight: 5,
+		wantMoved:  []string{"snapshots/3.snapshot"},
+	}, {
+		name: "snapshot without next predicate",
+		corrupt: func(dir string) error {
+			return editSnapshot(dir, func(s *state.Snapshot) {
+				h := *s.Header
+				h.NextPredicate = nil
+				s.Header = &h
+			})
+		},
+		wantHeight: 5,
+		wantMoved:  []string{"snapshots/3.snapshot"},
+	}}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := newStore(t)
+			defer os.RemoveAll(dir)
+			err := c.corrupt(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Open(dir)
+			if c.wantMoved == nil {
+				if err != nil {
+					t.Fatalf("opening: %v", err)
+				}
+			} else if errors.Root(err) != ErrCorrupt {
+				t.Fatalf("opening: got error %v, want %v", err, ErrCorrupt)
+			}
+
+			s, moved, err := Repair(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want []string
+			for _, name := range c.wantMoved {
+				want = append(want, filepath.FromSlash(name))
+			}
+			if !reflect.DeepEqual(moved, want) {
+				t.Errorf("repair moved %v, want %v", moved, want)
+			}
+			if h, _ := s.Height(ctx); h != c.wantHeight {
+				t.Errorf("repaired height = %d, want %d", h, c.wantHeight)
+			}
+			if _, err := Open(dir); err != nil {
+				t.Errorf("opening repaired store: %v", err)
+			}
+
+			b1, err := s.GetBlock(ctx, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			chain, err := protocol.NewChain(ctx, b1, s, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recovered, err := chain.Recover(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if recovered.Height() != c.wantHeight {
+				t.Errorf("recovered state at height %d, want %d", recovered.Height(), c.wantHeight)
+			}
+		})
+	}
+}
+
+func TestCollect(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "filestore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := prottest.NewChain(t, prottest.WithStore(s))
+	for i := 0; i < 4; i++ {
+		prottest.MakeBlock(t, c, nil)
+		err = s.SaveSnapshot(ctx, c.State())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A snapshot in the old single-file layout, and temporary files
+	// left by a crash.
+	bits, err := ioutil.ReadFile(filepath.Join(dir, "snapshots", "2.snapshot"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"snapshot", "snapshot.tmp"} {
+		err = ioutil.WriteFile(filepath.Join(dir, name), bits, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A corrupt latest snapshot rolls the store back to the one
+	// before it.
+	err = ioutil.WriteFile(filepath.Join(dir, "snapshots", "5.snapshot"), []byte{0xff}, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, moved, err := Repair(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join("snapshots", "5.snapshot")}; !reflect.DeepEqual(moved, want) {
+		t.Errorf("repair moved %v, want %v", moved, want)
+	}
+	if snap, _ := s.LatestSnapshot(ctx); snap.Height() != 4 {
+		t.Errorf("latest snapshot at height %d, want 4", snap.Height())
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, "blocks", "6.block.tmp"), bits, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := s.Collect(ctx, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join("snapshots", "2.snapshot"),
+		"snapshot",
+		"snapshot.tmp",
+	}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("collected %v, want %v", removed, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "blocks", "6.block.tmp")); err != nil {
+		t.Error("collected a temporary file written since the store was opened")
+	}
+	if snap, _ := s.LatestSnapshot(ctx); snap.Height() != 4 {
+		t.Errorf("after collecting, latest snapshot at height %d, want 4", snap.Height())
+	}
+}
@@ -0,0 +1,186 @@
+// Package genesis builds the initial blocks of new blockchains from
+// declarative parameters.
+//
+// A Config names the keys that sign blocks, the chain parameters the
+// blockchain starts with (see package chainparams), and any
+// allocations of assets the blockchain starts with (see package
+// alloc). Build checks it and returns the initial block, along with
+// the state snapshot that applying the block produces, ready to pass
+// to protocol.Chain.CommitAppliedBlock.
+//
+// Blocks after an initial block of version bc.CommitmentsVersion,
+// which parameters and allocations require, must be of that version
+// or later too; set protocol.BlockBuilder.Version accordingly.
+package genesis
+
+import (
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/math/checked"
+	"i10r.io/protocol"
+	"i10r.io/protocol/alloc"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/chainparams"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/validation"
+)
+
+// ErrConfig is returned for an inconsistent Config.
+var ErrConfig = errors.New("invalid genesis configuration")
+
+// Config describes a blockchain's initial block.
+type Config struct {
+	// Timestamp is the initial block's timestamp.
+	Timestamp time.Time
+
+	// Quorum of Pubkeys must sign each block after the initial
+	// block. Quorum may be zero only if there are no Pubkeys, for a
+	// blockchain whose blocks need no signatures.
+	Quorum  int
+	Pubkeys []ed25519.PublicKey
+
+	// Params, if set, are the limits on the initial block's
+	// successors until the block signers approve an update. Seq must
+	// be zero.
+	Params *chainparams.Params
+
+	// Allocations are outputs the initial block creates.
+	Allocations []alloc.Allocation
+
+	// Version is the initial block's version. Zero means 3, or
+	// bc.CommitmentsVersion if Params or Allocations are set. It must
+	// be before bc.NetworkVersion: an initial block cannot commit to
+	// its own ID.
+	Version uint64
+}
+
+func (cfg *Config) version() uint64 {
+	switch {
+	case cfg.Version != 0:
+		return cfg.Version
+	case cfg.Params != nil || len(cfg.Allocations) > 0:
+		return bc.CommitmentsVersion
+	}
+	return 3
+}
+
+// Check checks that cfg is consistent.
+func (cfg *Config) Check() error {
+	if cfg.Timestamp.IsZero() || bc.Millis(cfg.Timestamp) == 0 {
+		return errors.WithDetail(ErrConfig, "no timestamp")
+	}
+	if err := checkKeys(cfg.Quorum, cfg.Pubkeys); err != nil {
+		return errors.WithDetail(err, "block signers")
+	}
+	if len(cfg.Pubkeys) > 0 && cfg.Quorum == 0 {
+		return errors.WithDetail(ErrConfig, "block signers: zero quorum")
+	}
+
+	v := cfg.version()
+	switch {
+	case v < 3:
+		return errors.WithDetailf(ErrConfig, "version %d", v)
+	case v >= bc.NetworkVersion:
+		return errors.WithDetailf(ErrConfig, "version %d: an initial block cannot name its network", v)
+	case v < bc.CommitmentsVersion && (cfg.Params != nil || len(cfg.Allocations) > 0):
+		return errors.WithDetailf(ErrConfig, "version %d: parameters and allocations need version %d", v, bc.CommitmentsVersion)
+	}
+
+	if p := cfg.Params; p != nil {
+		if p.Seq != 0 {
+			return errors.WithDetailf(ErrConfig, "parameters sequence number %d", p.Seq)
+		}
+		if _, err := chainparams.Parse(chainparams.Value(p)); err != nil {
+			return errors.Sub(ErrConfig, err)
+		}
+	}
+
+	totals := make(map[bc.Hash]int64)
+	for i, a := range cfg.Allocations {
+		if a.Amount < 1 {
+			return errors.WithDetailf(ErrConfig, "allocation %d: amount %d", i, a.Amount)
+		}
+		if a.Quorum < 1 {
+			return errors.WithDetailf(ErrConfig, "allocation %d: quorum %d", i, a.Quorum)
+		}
+		if err := checkKeys(a.Quorum, a.Pubkeys); err != nil {
+			return errors.WithDetailf(err, "allocation %d", i)
+		}
+		total, ok := checked.AddInt64(totals[a.AssetID], a.Amount)
+		if !ok {
+			return errors.WithDetailf(ErrConfig, "allocations of asset %x overflow", a.AssetID.Bytes())
+		}
+		totals[a.AssetID] = total
+	}
+	return nil
+}
+
+// checkKeys checks that quorum is at most the number of pubkeys, and
+// that they are distinct public keys.
+func checkKeys(quorum int, pubkeys []ed25519.PublicKey) error {
+	if quorum < 0 || quorum > len(pubkeys) {
+		return errors.WithDetailf(ErrConfig, "quorum %d of %d", quorum, len(pubkeys))
+	}
+	seen := make(map[string]bool)
+	for _, pk := range pubkeys {
+		if len(pk) != ed25519.PublicKeySize {
+			return errors.WithDetailf(ErrConfig, "public key length %d", len(pk))
+		}
+		if seen[string(pk)] {
+			return errors.WithDetailf(ErrConfig, "duplicate public key %x", []byte(pk))
+		}
+		seen[string(pk)] = true
+	}
+	return nil
+}
+
+// Build returns the initial block described by cfg and the state
+// after it.
+func Build(cfg *Config) (*bc.Block, *state.Snapshot, error) {
+	err := cfg.Check()
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := protocol.NewInitialBlock(cfg.Pubkeys, cfg.Quorum, cfg.Timestamp)
+	if err != nil {
+		return nil, nil, err
+	}
+	b.Version = cfg.version()
+
+	var cs []bc.Commitment
+	if cfg.Params != nil {
+		cs = append(cs, bc.Commitment{Name: chainparams.Commitment, Value: chainparams.Value(cfg.Params)})
+	}
+	if len(cfg.Allocations) > 0 {
+		cs = append(cs, bc.Commitment{Name: alloc.Commitment, Value: alloc.Value(cfg.Allocations)})
+	}
+	b.SetCommitments(cs)
+
+	// The contracts root covers the allocations, and the block ID
+	// covers the root, so the state is computed twice: once to fill
+	// in the root, and once, to check the block, from scratch.
+	outs := state.Empty()
+	err = outs.AddAllocations(alloc.BlockOutputs(cfg.Allocations))
+	if err != nil {
+		return nil, nil, errors.Sub(ErrConfig, err)
+	}
+	contractsRoot := bc.NewHash(outs.ContractsTree.RootHash())
+	b.ContractsRoot = &contractsRoot
+
+	err = validation.BlockOnly(b.UnsignedBlock)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "validating initial block")
+	}
+	snapshot := state.Empty()
+	err = snapshot.ApplyBlock(b.UnsignedBlock)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "applying initial block")
+	}
+	if snapshot.ContractsTree.RootHash() != contractsRoot.Byte32() {
+		return nil, nil, errors.Wrap(protocol.ErrBadContractsRoot, "applying initial block")
+	}
+	snapshot.Freeze()
+	return b, snapshot, nil
+}
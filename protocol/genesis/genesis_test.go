@@ -0,0 +1,159 @@
+package genesis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol"
+	"i10r.io/protocol/alloc"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/chainparams"
+	"i10r.io/protocol/genesis"
+	"i10r.io/protocol/prottest/memstore"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txbuilder"
+)
+
+func TestBuild(t *testing.T) {
+	ctx := context.Background()
+	blockPub, blockPrv := newKey(t)
+	userPub, userPrv := newKey(t)
+	assetID := bc.NewHash([32]byte{1})
+	start := time.Now().Add(-time.Minute)
+	cfg := &genesis.Config{
+		Timestamp: start,
+		Quorum:    1,
+		Pubkeys:   []ed25519.PublicKey{blockPub},
+		Params:    &chainparams.Params{MaxBlockTxs: 10},
+		Allocations: []alloc.Allocation{
+			{AssetID: assetID, Amount: 100, Quorum: 1, Pubkeys: []ed25519.PublicKey{userPub}},
+			{AssetID: assetID, Amount: 100, Quorum: 1, Pubkeys: []ed25519.PublicKey{userPub}},
+		},
+	}
+	b1, snapshot, err := genesis.Build(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b1.Version != bc.CommitmentsVersion {
+		t.Errorf("initial block version %d, want %d", b1.Version, bc.CommitmentsVersion)
+	}
+	if p, err := chainparams.Of(b1.BlockHeader); err != nil || p == nil || *p != *cfg.Params {
+		t.Errorf("initial block parameters %+v, %v, want %+v", p, err, cfg.Params)
+	}
+	outs := alloc.BlockOutputs(cfg.Allocations)
+	if outs[0].ID == outs[1].ID {
+		t.Fatal("equal allocations have the same output ID")
+	}
+
+	// Replaying the initial block gives the same state.
+	replay := state.Empty()
+	err = replay.ApplyBlock(b1.UnsignedBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.ContractsTree.RootHash() != snapshot.ContractsTree.RootHash() || replay.InitialBlockID != b1.Hash() {
+		t.Error("replaying the initial block gives a different state")
+	}
+
+	c, err := protocol.NewChain(ctx, b1, memstore.New(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.CommitAppliedBlock(ctx, b1, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.BlockBuilder().Version = b1.Version
+
+	// The allocation's keys can spend it.
+	out := outs[0]
+	userPubs := []ed25519.PublicKey{userPub}
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.AddInput(1, [][]byte{userPub}, nil, userPubs, out.Amount, out.AssetID, out.Anchor, nil, 2)
+	tpl.AddOutput(1, userPubs, out.Amount, out.AssetID, nil, nil)
+	err = tpl.Sign(ctx, func(_ context.Context, msg, _ []byte, _ [][]byte) ([]byte, error) {
+		return ed25519.Sign(userPrv, msg), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spend, err := tpl.Tx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spend.Inputs[0].ID != out.ID {
+		t.Fatalf("spending %x, want allocation %x", spend.Inputs[0].ID.Bytes(), out.ID.Bytes())
+	}
+	ub, _, err := c.GenerateBlock(ctx, bc.Millis(time.Now()), []*bc.CommitmentsTx{bc.NewCommitmentsTx(spend)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ub.Transactions) != 1 {
+		t.Fatal("allocation spend not included in block")
+	}
+	if p, _ := chainparams.Of(ub.BlockHeader); p == nil || *p != *cfg.Params {
+		t.Errorf("second block parameters %+v, want %+v", p, cfg.Params)
+	}
+	b2, err := bc.SignBlock(ub, b1.BlockHeader, func(int) (interface{}, error) {
+		return ed25519.Sign(blockPrv, ub.Hash().Bytes()), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.CommitBlock(ctx, b2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.State().ContractsTree.Contains(out.ID.Bytes()) {
+		t.Error("allocation still in state after spend")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	pub, _ := newKey(t)
+	pubs := []ed25519.PublicKey{pub}
+	now := time.Now()
+	a := alloc.Allocation{Amount: 1, Quorum: 1, Pubkeys: pubs}
+	cases := []genesis.Config{
+		{},
+		{Timestamp: now, Quorum: 2, Pubkeys: pubs},
+		{Timestamp: now, Quorum: 0, Pubkeys: pubs},
+		{Timestamp: now, Quorum: 2, Pubkeys: []ed25519.PublicKey{pub, pub}},
+		{Timestamp: now, Pubkeys: []ed25519.PublicKey{pub[:31]}, Quorum: 1},
+		{Timestamp: now, Version: bc.NetworkVersion},
+		{Timestamp: now, Version: 3, Allocations: []alloc.Allocation{a}},
+		{Timestamp: now, Params: &chainparams.Params{Seq: 1}},
+		{Timestamp: now, Params: &chainparams.Params{MaxBlockBytes: -1}},
+		{Timestamp: now, Allocations: []alloc.Allocation{{Amount: 0, Quorum: 1, Pubkeys: pubs}}},
+		{Timestamp: now, Allocations: []alloc.Allocation{{Amount: 1, Quorum: 0, Pubkeys: pubs}}},
+		{Timestamp: now, Allocations: []alloc.Allocation{a, {Amount: 1<<63 - 1, Quorum: 1, Pubkeys: pubs}}},
+	}
+	for i, cfg := range cases {
+		if _, _, err := genesis.Build(&cfg); errors.Root(err) != genesis.ErrConfig {
+			t.Errorf("case %d: got error %v, want %v", i, err, genesis.ErrConfig)
+		}
+	}
+
+	b1, _, err := genesis.Build(&genesis.Config{Timestamp: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := protocol.NewInitialBlock(nil, 0, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b1.Hash() != want.Hash() {
+		t.Error("a bare configuration gives a different block than protocol.NewInitialBlock")
+	}
+}
+
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, prv
+}
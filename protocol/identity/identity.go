@@ -0,0 +1,247 @@
+// Package identity implements long-lived identities made of ed25519
+// keys that rotate.
+//
+// An Identity is named by its root key, the key it started with. To
+// rotate to a new key, the holder of the current key signs a Cert
+// designating the new key from a start time on, optionally until an
+// end time, after which the identity has no valid key until a later
+// certificate names one. An Identity carries its chain of
+// certificates, and resolves to a single current key at any time, so
+// a verifier who knows only the root key can check a signature made
+// by a key several rotations later. A key once rotated away from is
+// no longer the identity's key: a signature by it counts only if it
+// was made, by the verifier's reckoning, before the rotation.
+//
+// Certificates are checked with the old key's signature alone; there
+// is no key recovery. Losing the current private key loses the
+// identity.
+package identity
+
+import (
+	"encoding/binary"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+)
+
+var (
+	// ErrCert is returned for a malformed certificate, or one whose
+	// signature is not by the key it rotates from.
+	ErrCert = errors.New("invalid key rotation certificate")
+
+	// ErrChain is returned for certificates that do not form a
+	// chain from an identity's root key.
+	ErrChain = errors.New("broken key rotation chain")
+
+	// ErrNoKey is returned when an identity has no valid key at the
+	// time in question: its latest certificate then in effect has
+	// expired.
+	ErrNoKey = errors.New("identity has no valid key")
+
+	// ErrSignature is returned for a signature that is not by an
+	// identity's key at the time in question.
+	ErrSignature = errors.New("invalid identity signature")
+
+	// ErrKey is returned for a key that is not an identity's key at
+	// the time in question.
+	ErrKey = errors.New("not the identity's key")
+)
+
+// certLen is the length of a certificate's binary encoding.
+const certLen = 2*ed25519.PublicKeySize + 16 + ed25519.SignatureSize
+
+// Cert designates New as the key of the identity whose key was Old,
+// from NotBeforeMS until NotAfterMS (0 for no end), in Unix
+// milliseconds. Old signs it.
+type Cert struct {
+	Old         ed25519.PublicKey  `json:"old"`
+	New         ed25519.PublicKey  `json:"new"`
+	NotBeforeMS uint64             `json:"not_before_ms"`
+	NotAfterMS  uint64             `json:"not_after_ms"`
+	Signature   chainjson.HexBytes `json:"signature"`
+}
+
+// NewCert returns a certificate, signed with old, designating newKey
+// from notBefore until notAfter, which is no end if zero.
+func NewCert(old ed25519.PrivateKey, newKey ed25519.PublicKey, notBefore, notAfter time.Time) *Cert {
+	c := &Cert{
+		Old:         old.Public().(ed25519.PublicKey),
+		New:         newKey,
+		NotBeforeMS: bc.Millis(notBefore),
+	}
+	if !notAfter.IsZero() {
+		c.NotAfterMS = bc.Millis(notAfter)
+	}
+	c.Signature = ed25519.Sign(old, c.SigningMessage())
+	return c
+}
+
+// SigningMessage returns the message the old key signs. It covers
+// every field but the signature, encoded as a txvm tuple.
+func (c *Cert) SigningMessage() []byte {
+	h := txvm.VMHash("KeyRotation", txvm.Encode(txvm.Tuple{
+		txvm.Bytes(c.Old),
+		txvm.Bytes(c.New),
+		txvm.Int(c.NotBeforeMS),
+		txvm.Int(c.NotAfterMS),
+	}))
+	return h[:]
+}
+
+// Verify checks that c is well formed and signed by its old key.
+func (c *Cert) Verify() error {
+	switch {
+	case len(c.Old) != ed25519.PublicKeySize || len(c.New) != ed25519.PublicKeySize:
+		return errors.WithDetail(ErrCert, "bad key length")
+	case string(c.Old) == string(c.New):
+		return errors.WithDetail(ErrCert, "rotates to the same key")
+	case c.NotAfterMS != 0 && c.NotAfterMS < c.NotBeforeMS:
+		return errors.WithDetailf(ErrCert, "ends at %d, before it starts at %d", c.NotAfterMS, c.NotBeforeMS)
+	case !ed25519.Verify(c.Old, c.SigningMessage(), c.Signature):
+		return errors.WithDetail(ErrCert, "bad signature")
+	}
+	return nil
+}
+
+// Bytes returns the binary encoding of c: the old and new keys, the
+// start and end times as 8-byte little-endian integers, and the
+// signature.
+func (c *Cert) Bytes() []byte {
+	buf := make([]byte, 0, certLen)
+	buf = append(buf, c.Old...)
+	buf = append(buf, c.New...)
+	var t [16]byte
+	binary.LittleEndian.PutUint64(t[:8], c.NotBeforeMS)
+	binary.LittleEndian.PutUint64(t[8:], c.NotAfterMS)
+	buf = append(buf, t[:]...)
+	return append(buf, c.Signature...)
+}
+
+// ParseCerts parses the concatenated binary encodings of
+// certificates. It does not verify them.
+func ParseCerts(b []byte) ([]*Cert, error) {
+	if len(b)%certLen != 0 {
+		return nil, errors.WithDetailf(ErrCert, "%d bytes of certificates", len(b))
+	}
+	var certs []*Cert
+	for ; len(b) > 0; b = b[certLen:] {
+		const k = ed25519.PublicKeySize
+		certs = append(certs, &Cert{
+			Old:         ed25519.PublicKey(b[:k]),
+			New:         ed25519.PublicKey(b[k : 2*k]),
+			NotBeforeMS: binary.LittleEndian.Uint64(b[2*k:]),
+			NotAfterMS:  binary.LittleEndian.Uint64(b[2*k+8:]),
+			Signature:   b[2*k+16 : certLen],
+		})
+	}
+	return certs, nil
+}
+
+// Identity is a root key and the certificates rotating it, in order.
+type Identity struct {
+	Root  ed25519.PublicKey `json:"root"`
+	Certs []*Cert           `json:"certs,omitempty"`
+}
+
+// Check checks that id's certificates are valid and form a chain:
+// each rotates from the key the previous one designates, starting
+// from the root key, no earlier than the previous one starts.
+func (id *Identity) Check() error {
+	if len(id.Root) != ed25519.PublicKeySize {
+		return errors.WithDetailf(ErrChain, "root key length %d", len(id.Root))
+	}
+	key := id.Root
+	var start uint64
+	for i, c := range id.Certs {
+		if err := c.Verify(); err != nil {
+			return errors.WithDetailf(err, "certificate %d", i)
+		}
+		if string(c.Old) != string(key) {
+			return errors.WithDetailf(ErrChain, "certificate %d rotates from %x, not %x", i, []byte(c.Old), []byte(key))
+		}
+		if c.NotBeforeMS < start {
+			return errors.WithDetailf(ErrChain, "certificate %d starts at %d, before the previous one at %d", i, c.NotBeforeMS, start)
+		}
+		key, start = c.New, c.NotBeforeMS
+	}
+	return nil
+}
+
+// Key returns id's key at time t: the key designated by the last
+// certificate started by t, or the root key if none has.
+func (id *Identity) Key(t time.Time) (ed25519.PublicKey, error) {
+	if err := id.Check(); err != nil {
+		return nil, err
+	}
+	ms := bc.Millis(t)
+	key := id.Root
+	var cur *Cert
+	for _, c := range id.Certs {
+		if c.NotBeforeMS > ms {
+			break
+		}
+		key, cur = c.New, c
+	}
+	if cur != nil && cur.NotAfterMS != 0 && ms > cur.NotAfterMS {
+		return nil, errors.WithDetailf(ErrNoKey, "key %x expired at %d", []byte(key), cur.NotAfterMS)
+	}
+	return key, nil
+}
+
+// Current returns id's key now.
+func (id *Identity) Current() (ed25519.PublicKey, error) {
+	return id.Key(time.Now())
+}
+
+// Verify checks that sig is a signature of msg by id's key at time
+// t, typically when the message was signed or, for a message that
+// must be fresh, now.
+func (id *Identity) Verify(msg, sig []byte, t time.Time) error {
+	key, err := id.Key(t)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, msg, sig) {
+		return errors.WithDetailf(ErrSignature, "not by key %x", []byte(key))
+	}
+	return nil
+}
+
+// CheckKey returns ErrKey if pub is not id's key at time t.
+func (id *Identity) CheckKey(pub ed25519.PublicKey, t time.Time) error {
+	key, err := id.Key(t)
+	if err != nil {
+		return err
+	}
+	if string(key) != string(pub) {
+		return errors.WithDetailf(ErrKey, "key %x, identity has %x", []byte(pub), []byte(key))
+	}
+	return nil
+}
+
+// Rotate adds a certificate, signed with prv, which must be the
+// private key of id's last designated key, rotating to newKey from
+// notBefore until notAfter (no end if zero).
+func (id *Identity) Rotate(prv ed25519.PrivateKey, newKey ed25519.PublicKey, notBefore, notAfter time.Time) error {
+	last := id.Root
+	if n := len(id.Certs); n > 0 {
+		last = id.Certs[n-1].New
+	}
+	if string(prv.Public().(ed25519.PublicKey)) != string(last) {
+		return errors.WithDetail(ErrChain, "signing key is not the identity's last key")
+	}
+	c := NewCert(prv, newKey, notBefore, notAfter)
+	if err := c.Verify(); err != nil {
+		return err
+	}
+	id.Certs = append(id.Certs, c)
+	if err := id.Check(); err != nil {
+		id.Certs = id.Certs[:len(id.Certs)-1]
+		return err
+	}
+	return nil
+}
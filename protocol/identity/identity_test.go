@@ -0,0 +1,135 @@
+package identity
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+)
+
+func TestRotate(t *testing.T) {
+	rootPub, rootPrv := newKey(t)
+	pub2, prv2 := newKey(t)
+	pub3, _ := newKey(t)
+	t0 := time.Unix(1500000000, 0)
+	t1, t2, t3 := t0.Add(time.Hour), t0.Add(2*time.Hour), t0.Add(3*time.Hour)
+
+	id := &Identity{Root: rootPub}
+	err := id.Rotate(rootPrv, pub2, t1, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = id.Rotate(prv2, pub3, t2, t3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := id.Rotate(rootPrv, pub3, t2, time.Time{}); errors.Root(err) != ErrChain {
+		t.Errorf("rotating with a superseded key: got error %v, want %v", err, ErrChain)
+	}
+	if len(id.Certs) != 2 {
+		t.Fatalf("got %d certificates, want 2", len(id.Certs))
+	}
+
+	cases := []struct {
+		at   time.Time
+		want ed25519.PublicKey
+		err  error
+	}{
+		{t0, rootPub, nil},
+		{t1, pub2, nil},
+		{t1.Add(time.Minute), pub2, nil},
+		{t2, pub3, nil},
+		{t3, pub3, nil},
+		{t3.Add(time.Millisecond), nil, ErrNoKey},
+	}
+	for _, c := range cases {
+		got, err := id.Key(c.at)
+		if errors.Root(err) != c.err {
+			t.Errorf("Key(%s): got error %v, want %v", c.at, err, c.err)
+			continue
+		}
+		if string(got) != string(c.want) {
+			t.Errorf("Key(%s) = %x, want %x", c.at, []byte(got), []byte(c.want))
+		}
+	}
+
+	msg := []byte("hello")
+	if err := id.Verify(msg, ed25519.Sign(prv2, msg), t1); err != nil {
+		t.Error(err)
+	}
+	if err := id.Verify(msg, ed25519.Sign(rootPrv, msg), t1); errors.Root(err) != ErrSignature {
+		t.Errorf("signature by rotated-away key: got error %v, want %v", err, ErrSignature)
+	}
+	if err := id.Verify(msg, ed25519.Sign(rootPrv, msg), t0); err != nil {
+		t.Errorf("signature by root key before rotation: %v", err)
+	}
+	if err := id.CheckKey(pub2, t2); errors.Root(err) != ErrKey {
+		t.Errorf("CheckKey(pub2, t2): got error %v, want %v", err, ErrKey)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	rootPub, rootPrv := newKey(t)
+	pub2, prv2 := newKey(t)
+	pub3, _ := newKey(t)
+	now := time.Now()
+
+	good := NewCert(rootPrv, pub2, now, time.Time{})
+	forged := NewCert(prv2, pub3, now, time.Time{})
+	forged.Old = rootPub
+	early := NewCert(prv2, pub3, now.Add(-time.Hour), time.Time{})
+	backwards := NewCert(rootPrv, pub2, now, now.Add(-time.Hour))
+
+	cases := []struct {
+		certs []*Cert
+		err   error
+	}{
+		{[]*Cert{good}, nil},
+		{[]*Cert{NewCert(prv2, pub3, now, time.Time{})}, ErrChain},
+		{[]*Cert{forged}, ErrCert},
+		{[]*Cert{good, early}, ErrChain},
+		{[]*Cert{backwards}, ErrCert},
+		{[]*Cert{NewCert(rootPrv, rootPub, now, time.Time{})}, ErrCert},
+	}
+	for i, c := range cases {
+		id := &Identity{Root: rootPub, Certs: c.certs}
+		if err := id.Check(); errors.Root(err) != c.err {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+		}
+	}
+}
+
+func TestParseCerts(t *testing.T) {
+	_, prv1 := newKey(t)
+	pub2, prv2 := newKey(t)
+	pub3, _ := newKey(t)
+	now := time.Unix(1500000000, 0)
+	certs := []*Cert{
+		NewCert(prv1, pub2, now, time.Time{}),
+		NewCert(prv2, pub3, now.Add(time.Hour), now.Add(2*time.Hour)),
+	}
+	var b []byte
+	for _, c := range certs {
+		b = append(b, c.Bytes()...)
+	}
+	got, err := ParseCerts(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, certs) {
+		t.Errorf("ParseCerts = %+v, want %+v", got, certs)
+	}
+	if _, err := ParseCerts(b[1:]); errors.Root(err) != ErrCert {
+		t.Errorf("ParseCerts(short): got error %v, want %v", err, ErrCert)
+	}
+}
+
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, prv
+}
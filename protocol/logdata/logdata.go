@@ -0,0 +1,252 @@
+/*
+Package logdata defines a convention for application data in the
+transaction log, so that indexers can parse what different
+applications log the same way.
+
+A typed entry is a type tag and a tuple of fields. In the log it is
+the tuple
+
+	{'data', TYPE, {FIELD, ...}}
+
+logged directly by a contract, or that tuple's canonical encoding,
+the txvm program that pushes it (see txvm.Encode), in a byte string
+of the log, such as the transaction tags of a txbuilder.Template or
+the reference data of an output. Parse accepts either form; Entries
+finds every entry logged in a transaction.
+
+The types themselves are in a registry. Each registered Schema
+converts between an entry's fields and a Go value. This package
+registers the well-known types Memo, InvoiceRef, and Attestation;
+applications register their own with Register.
+*/
+package logdata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+)
+
+// Marker is the first element of a typed entry.
+var Marker = []byte("data")
+
+var (
+	// ErrNotEntry is returned by Parse for log data that is not a
+	// typed entry.
+	ErrNotEntry = errors.New("not a typed log entry")
+
+	// ErrUnknownType is returned by Decode for an entry whose type
+	// is not registered.
+	ErrUnknownType = errors.New("unknown log entry type")
+
+	// ErrFields is returned for an entry whose fields do not match
+	// its type's schema.
+	ErrFields = errors.New("malformed log entry fields")
+)
+
+// Entry is a typed log entry.
+type Entry struct {
+	Type   string
+	Fields txvm.Tuple
+}
+
+// Tuple returns the log data for e.
+func (e *Entry) Tuple() txvm.Tuple {
+	return txvm.Tuple{txvm.Bytes(Marker), txvm.Bytes(e.Type), e.Fields}
+}
+
+// Bytes returns the canonical encoding of e, for a byte string in
+// the log.
+func (e *Entry) Bytes() []byte {
+	return txvm.Encode(e.Tuple())
+}
+
+// Parse parses the typed entry in data, which is the data of a log
+// entry: either the entry's tuple or its encoding. If data is not an
+// entry, the error's root is ErrNotEntry.
+func Parse(data txvm.Data) (*Entry, error) {
+	if b, ok := data.(txvm.Bytes); ok {
+		if len(b) < len(Marker)+1 || !bytes.Equal(b[1:1+len(Marker)], Marker) {
+			// Not an encoding that begins by pushing Marker. This
+			// quickly sets aside most byte strings.
+			return nil, errors.Wrap(ErrNotEntry)
+		}
+		var err error
+		data, err = decode(b)
+		if err != nil {
+			return nil, errors.Sub(ErrNotEntry, err)
+		}
+	}
+	t, ok := data.(txvm.Tuple)
+	if !ok || len(t) != 3 {
+		return nil, errors.Wrap(ErrNotEntry)
+	}
+	if marker, ok := t[0].(txvm.Bytes); !ok || !bytes.Equal(marker, Marker) {
+		return nil, errors.Wrap(ErrNotEntry)
+	}
+	typ, ok := t[1].(txvm.Bytes)
+	if !ok {
+		return nil, errors.WithDetail(ErrNotEntry, "type is not a string")
+	}
+	fields, ok := t[2].(txvm.Tuple)
+	if !ok {
+		return nil, errors.WithDetail(ErrNotEntry, "fields are not a tuple")
+	}
+	return &Entry{Type: string(typ), Fields: fields}, nil
+}
+
+// Entries returns the typed entries logged in tx, in order, in
+// either form. Entries in the reference data of tx's contracts are
+// not logged on their own; parse those from a txresult.Result.
+func Entries(tx *bc.Tx) []*Entry {
+	var entries []*Entry
+	for _, item := range tx.Log {
+		if len(item) < 3 {
+			continue
+		}
+		if code, ok := item[0].(txvm.Bytes); !ok || len(code) != 1 || code[0] != txvm.LogCode {
+			continue
+		}
+		if e, err := Parse(item[2]); err == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// decode decodes the data pushed by prog, which must be the
+// canonical encoding of a single data item.
+func decode(prog []byte) (txvm.Data, error) {
+	var stack []txvm.Data
+	for rest := prog; len(rest) > 0; {
+		opcode, data, n, err := op.DecodeInst(rest)
+		if err != nil {
+			return nil, err
+		}
+		rest = rest[n:]
+		switch {
+		case opcode == op.MinPushdata:
+			stack = append(stack, txvm.Bytes(data))
+		case op.IsSmallIntOp(opcode):
+			stack = append(stack, txvm.Int(opcode-op.MinSmallInt))
+		case opcode == op.Int:
+			if len(stack) == 0 {
+				return nil, errors.New("int with empty stack")
+			}
+			b, ok := stack[len(stack)-1].(txvm.Bytes)
+			if !ok {
+				return nil, errors.New("int of a non-string")
+			}
+			v, m := binary.Uvarint(b)
+			if m != len(b) {
+				return nil, errors.New("bad varint")
+			}
+			stack[len(stack)-1] = txvm.Int(v)
+		case opcode == op.Tuple:
+			if len(stack) == 0 {
+				return nil, errors.New("tuple with empty stack")
+			}
+			l, ok := stack[len(stack)-1].(txvm.Int)
+			if !ok || l < 0 || int(l) > len(stack)-1 {
+				return nil, errors.New("bad tuple length")
+			}
+			stack = stack[:len(stack)-1]
+			t := make(txvm.Tuple, l)
+			copy(t, stack[len(stack)-int(l):])
+			stack = append(stack[:len(stack)-int(l)], t)
+		default:
+			return nil, fmt.Errorf("unexpected %s", op.Name(opcode))
+		}
+	}
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("encoding pushes %d items", len(stack))
+	}
+	// The encoding of a value is unique; anything else, such as a
+	// small integer pushed as a string, is not canonical.
+	if !bytes.Equal(txvm.Encode(stack[0]), prog) {
+		return nil, errors.New("noncanonical encoding")
+	}
+	return stack[0], nil
+}
+
+// A Value is a Go value of a registered type.
+type Value interface {
+	// Type returns the value's type tag.
+	Type() string
+
+	// Fields returns the value's fields.
+	Fields() txvm.Tuple
+}
+
+// New returns the typed entry for v.
+func New(v Value) *Entry {
+	return &Entry{Type: v.Type(), Fields: v.Fields()}
+}
+
+// A Schema describes a registered type.
+type Schema struct {
+	// Type is the type tag.
+	Type string
+
+	// Doc describes the type and its fields, for tools that list
+	// the registry.
+	Doc string
+
+	// Decode returns the value an entry of this type with the given
+	// fields holds. An error for malformed fields should have root
+	// ErrFields.
+	Decode func(fields txvm.Tuple) (Value, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Schema)
+)
+
+// Register adds s to the registry. It panics if s.Type is already
+// registered.
+func Register(s *Schema) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[s.Type]; ok {
+		panic("logdata: type " + s.Type + " registered twice")
+	}
+	registry[s.Type] = s
+}
+
+// Lookup returns the schema registered for the type tag typ.
+func Lookup(typ string) (*Schema, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	s, ok := registry[typ]
+	return s, ok
+}
+
+// Types returns the registered type tags, in order.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var types []string
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Decode returns the value e holds, using the schema registered for
+// its type. If there is none, the error's root is ErrUnknownType.
+func Decode(e *Entry) (Value, error) {
+	s, ok := Lookup(e.Type)
+	if !ok {
+		return nil, errors.WithDetailf(ErrUnknownType, "type %q", e.Type)
+	}
+	return s.Decode(e.Fields)
+}
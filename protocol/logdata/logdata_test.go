@@ -0,0 +1,110 @@
+package logdata
+
+import (
+	"reflect"
+	"testing"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+)
+
+func TestRoundTrip(t *testing.T) {
+	values := []Value{
+		&Memo{Text: "thanks for the widgets"},
+		&InvoiceRef{Payee: []byte{1, 2, 3}, Hash: bc.NewHash([32]byte{4})},
+		&Attestation{Topic: "BTC/USD", Value: -5, ExpMS: 1 << 40, Signatures: [][]byte{{5}, {}, {6}}},
+	}
+	for _, v := range values {
+		e := New(v)
+		for _, data := range []txvm.Data{e.Tuple(), txvm.Bytes(e.Bytes())} {
+			got, err := Parse(data)
+			if err != nil {
+				t.Fatalf("Parse(%s): %v", data, err)
+			}
+			dec, err := Decode(got)
+			if err != nil {
+				t.Fatalf("Decode(%s): %v", data, err)
+			}
+			if !reflect.DeepEqual(dec, v) {
+				t.Errorf("got %#v, want %#v", dec, v)
+			}
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	memo := New(&Memo{Text: "x"}).Bytes()
+	cases := []struct {
+		name string
+		data txvm.Data
+	}{
+		{"int", txvm.Int(7)},
+		{"empty", txvm.Bytes(nil)},
+		{"plain tags", txvm.Bytes("order 1234")},
+		{"other marker", txvm.Tuple{txvm.Bytes("fee"), txvm.Bytes("memo"), txvm.Tuple{}}},
+		{"short tuple", txvm.Tuple{txvm.Bytes(Marker), txvm.Bytes("memo")}},
+		{"fields not a tuple", txvm.Tuple{txvm.Bytes(Marker), txvm.Bytes("memo"), txvm.Int(1)}},
+		{"truncated", txvm.Bytes(memo[:len(memo)-1])},
+		{"trailing", txvm.Bytes(append(append([]byte{}, memo...), memo...))},
+		{"noncanonical", txvm.Bytes(noncanonical())},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.data)
+		if errors.Root(err) != ErrNotEntry {
+			t.Errorf("%s: got error %v, want %v", c.name, err, ErrNotEntry)
+		}
+	}
+}
+
+// noncanonical returns an encoding of the memo "x" that pushes the
+// length of its fields as a varint.
+func noncanonical() []byte {
+	var prog []byte
+	prog = append(prog, txvm.Encode(txvm.Bytes(Marker))...)
+	prog = append(prog, txvm.Encode(txvm.Bytes(MemoType))...)
+	prog = append(prog, txvm.Encode(txvm.Bytes("x"))...)
+	prog = append(prog, txvm.Encode(txvm.Bytes{1})...)
+	prog = append(prog, op.Int, op.Tuple)
+	prog = append(prog, txvm.Encode(txvm.Int(3))...)
+	return append(prog, op.Tuple)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	_, err := Decode(&Entry{Type: "no such type"})
+	if errors.Root(err) != ErrUnknownType {
+		t.Errorf("got error %v, want %v", err, ErrUnknownType)
+	}
+	_, err = Decode(&Entry{Type: MemoType, Fields: txvm.Tuple{txvm.Int(1)}})
+	if errors.Root(err) != ErrFields {
+		t.Errorf("got error %v, want %v", err, ErrFields)
+	}
+}
+
+func TestEntries(t *testing.T) {
+	memo := New(&Memo{Text: "hello"})
+	ref := New(&InvoiceRef{Payee: []byte{1}, Hash: bc.NewHash([32]byte{2})})
+	tx := &bc.Tx{
+		Log: []txvm.Tuple{
+			{txvm.Bytes{txvm.LogCode}, txvm.Bytes{0}, memo.Tuple()},
+			{txvm.Bytes{txvm.OutputCode}, txvm.Bytes{0}, memo.Tuple()},
+			{txvm.Bytes{txvm.LogCode}, txvm.Bytes{0}, txvm.Bytes("plain")},
+			{txvm.Bytes{txvm.LogCode}, txvm.Bytes{0}, txvm.Bytes(ref.Bytes())},
+		},
+	}
+	got := Entries(tx)
+	want := []*Entry{memo, ref}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a type twice did not panic")
+		}
+	}()
+	Register(&Schema{Type: MemoType})
+}
@@ -0,0 +1,141 @@
+package logdata
+
+import (
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+)
+
+// Type tags of the well-known types.
+const (
+	MemoType        = "memo"
+	InvoiceRefType  = "invoice"
+	AttestationType = "attestation"
+)
+
+func init() {
+	Register(&Schema{
+		Type:   MemoType,
+		Doc:    "free text: {text}",
+		Decode: decodeMemo,
+	})
+	Register(&Schema{
+		Type:   InvoiceRefType,
+		Doc:    "a payment of an invoice: {payee pubkey, invoice hash}",
+		Decode: decodeInvoiceRef,
+	})
+	Register(&Schema{
+		Type:   AttestationType,
+		Doc:    "an oracle attestation: {topic, value, expiration ms, {signature, ...}}",
+		Decode: decodeAttestation,
+	})
+}
+
+// Memo is free text, such as a note to the payee.
+type Memo struct {
+	Text string
+}
+
+// Type implements Value.
+func (m *Memo) Type() string { return MemoType }
+
+// Fields implements Value.
+func (m *Memo) Fields() txvm.Tuple {
+	return txvm.Tuple{txvm.Bytes(m.Text)}
+}
+
+func decodeMemo(fields txvm.Tuple) (Value, error) {
+	if len(fields) != 1 {
+		return nil, errors.WithDetailf(ErrFields, "memo has %d fields", len(fields))
+	}
+	text, ok := fields[0].(txvm.Bytes)
+	if !ok {
+		return nil, errors.WithDetail(ErrFields, "memo text is not a string")
+	}
+	return &Memo{Text: string(text)}, nil
+}
+
+// InvoiceRef marks a transaction as paying an invoice. Hash is the
+// invoice's signing message (see invoice.Invoice.Ref), which covers
+// everything the invoice requests, and Payee the key that signed it.
+type InvoiceRef struct {
+	Payee []byte
+	Hash  bc.Hash
+}
+
+// Type implements Value.
+func (r *InvoiceRef) Type() string { return InvoiceRefType }
+
+// Fields implements Value.
+func (r *InvoiceRef) Fields() txvm.Tuple {
+	return txvm.Tuple{txvm.Bytes(r.Payee), txvm.Bytes(r.Hash.Bytes())}
+}
+
+func decodeInvoiceRef(fields txvm.Tuple) (Value, error) {
+	if len(fields) != 2 {
+		return nil, errors.WithDetailf(ErrFields, "invoice ref has %d fields", len(fields))
+	}
+	payee, ok := fields[0].(txvm.Bytes)
+	if !ok {
+		return nil, errors.WithDetail(ErrFields, "invoice payee is not a string")
+	}
+	hash, ok := fields[1].(txvm.Bytes)
+	if !ok || len(hash) != 32 {
+		return nil, errors.WithDetail(ErrFields, "invoice hash is not 32 bytes")
+	}
+	return &InvoiceRef{Payee: payee, Hash: bc.HashFromBytes(hash)}, nil
+}
+
+// Attestation records an oracle's claim that Topic had Value, usable
+// until ExpMS, with the signatures that make it (see
+// oracle.Attestation.LogData). An indexer can check the signatures
+// against the oracle's keys.
+type Attestation struct {
+	Topic      string
+	Value      int64
+	ExpMS      uint64
+	Signatures [][]byte
+}
+
+// Type implements Value.
+func (a *Attestation) Type() string { return AttestationType }
+
+// Fields implements Value.
+func (a *Attestation) Fields() txvm.Tuple {
+	sigs := make(txvm.Tuple, 0, len(a.Signatures))
+	for _, sig := range a.Signatures {
+		sigs = append(sigs, txvm.Bytes(sig))
+	}
+	return txvm.Tuple{txvm.Bytes(a.Topic), txvm.Int(a.Value), txvm.Int(a.ExpMS), sigs}
+}
+
+func decodeAttestation(fields txvm.Tuple) (Value, error) {
+	if len(fields) != 4 {
+		return nil, errors.WithDetailf(ErrFields, "attestation has %d fields", len(fields))
+	}
+	topic, ok := fields[0].(txvm.Bytes)
+	if !ok {
+		return nil, errors.WithDetail(ErrFields, "attestation topic is not a string")
+	}
+	value, ok := fields[1].(txvm.Int)
+	if !ok {
+		return nil, errors.WithDetail(ErrFields, "attestation value is not an int")
+	}
+	exp, ok := fields[2].(txvm.Int)
+	if !ok {
+		return nil, errors.WithDetail(ErrFields, "attestation expiration is not an int")
+	}
+	sigs, ok := fields[3].(txvm.Tuple)
+	if !ok {
+		return nil, errors.WithDetail(ErrFields, "attestation signatures are not a tuple")
+	}
+	a := &Attestation{Topic: string(topic), Value: int64(value), ExpMS: uint64(exp)}
+	for _, sig := range sigs {
+		b, ok := sig.(txvm.Bytes)
+		if !ok {
+			return nil, errors.WithDetail(ErrFields, "attestation signature is not a string")
+		}
+		a.Signatures = append(a.Signatures, b)
+	}
+	return a, nil
+}
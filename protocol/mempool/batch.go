@@ -0,0 +1,108 @@
+package mempool
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txvm"
+)
+
+// batchProgramCacheSize is the size of the program cache shared by
+// the transactions of a batch.
+const batchProgramCacheSize = 4 << 20
+
+// TxResult is the outcome of validating one transaction of a batch.
+type TxResult struct {
+	// Tx is the transaction, if its program ran. It is set even
+	// when Err is not nil, unless the program itself failed.
+	Tx *bc.Tx
+
+	Err error
+}
+
+// ValidateTxBatch validates the transactions raw, such as those
+// pending before a restart, together, and returns their results, in
+// the same order. It runs their programs in parallel, sharing the
+// decoded contract programs (see txvm.ProgramCache) and the
+// decompressed public keys and verified signatures (see
+// txvm.SigCache) among them, then applies each transaction that ran
+// to a copy of snapshot, in order, so that a transaction may spend
+// the outputs of earlier ones. The errors are those Add would
+// return, but for policy and capacity; snapshot is unchanged.
+//
+// The options opts are passed to each VM, after the shared caches,
+// so that a caller can choose the network (see bc.NetworkOption) or
+// a longer-lived program cache.
+func ValidateTxBatch(ctx context.Context, raw []*bc.RawTx, snapshot *state.Snapshot, opts ...txvm.Option) []TxResult {
+	results := runBatch(ctx, raw, opts)
+	view := state.Copy(snapshot)
+	for i := range results {
+		r := &results[i]
+		if r.Err != nil {
+			continue
+		}
+		r.Err = applyTx(view, bc.NewCommitmentsTx(r.Tx), view.TimestampMS())
+	}
+	return results
+}
+
+// AddBatch adds the transactions raw to the pool, in order, as Add
+// does, and returns their results. Their programs are run as by
+// ValidateTxBatch, with the plugins set with SetPlugins.
+func (p *Pool) AddBatch(ctx context.Context, raw []*bc.RawTx, opts ...txvm.Option) []TxResult {
+	p.mu.Lock()
+	plugins := p.plugins
+	p.mu.Unlock()
+	results := runBatch(ctx, raw, append([]txvm.Option{txvm.WithPlugins(plugins)}, opts...))
+	for i := range results {
+		r := &results[i]
+		if r.Err != nil {
+			continue
+		}
+		r.Err = p.Add(r.Tx)
+	}
+	return results
+}
+
+// runBatch runs the programs of raw in parallel, with shared caches,
+// and returns the resulting transactions.
+func runBatch(ctx context.Context, raw []*bc.RawTx, opts []txvm.Option) []TxResult {
+	opts = append([]txvm.Option{
+		txvm.Context(ctx),
+		txvm.WithProgramCache(txvm.NewProgramCache(batchProgramCacheSize)),
+		txvm.WithSigCache(txvm.NewSigCache()),
+	}, opts...)
+	opts = opts[:len(opts):len(opts)] // NewTx appends to it
+
+	var (
+		results = make([]TxResult, len(raw))
+		next    = make(chan int)
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				tx, err := bc.NewTx(raw[i].Program, raw[i].Version, raw[i].Runlimit, opts...)
+				if err != nil {
+					results[i].Err = err
+					continue
+				}
+				results[i].Tx = tx
+				if !tx.Finalized {
+					results[i].Err = txvm.ErrUnfinalized
+				}
+			}
+		}()
+	}
+	for i := range raw {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
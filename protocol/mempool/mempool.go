@@ -0,0 +1,469 @@
+// Package mempool holds transactions that have been submitted to a
+// node but not yet included in a block.
+//
+// A Pool checks each transaction against the current blockchain
+// state and the transactions already pending, so it never holds two
+// transactions that spend the same output or use the same nonce. When
+// a new block is committed, Update re-checks what remains and drops
+// whatever the block made invalid, including the block's own
+// transactions. Expire drops transactions whose time ranges have
+// passed by the clock rather than by block timestamps.
+//
+// With a Policy allowing it, a transaction consuming contracts or
+// nonces that pending transactions consume replaces them if it pays
+// a higher fee rate.
+//
+// Subscribers are told when a pending transaction is conflicted, or
+// when a contract or nonce they watch is consumed unexpectedly, so
+// that attempted double spends can be acted on at once.
+//
+// ValidateTxBatch and AddBatch take many transactions at once, such
+// as those pending before a restart, sharing the work their programs
+// have in common. A Log keeps the transactions a pool admits on disk
+// so that they can be restored.
+package mempool
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/fee"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txvm"
+)
+
+// DefaultMaxTxs is the capacity of a Pool created with maxTxs 0.
+const DefaultMaxTxs = 10000
+
+var (
+	// ErrDuplicate is returned by Add for a transaction already in
+	// the pool.
+	ErrDuplicate = errors.New("transaction already in pool")
+
+	// ErrFull is returned by Add when the pool is at capacity.
+	ErrFull = errors.New("mempool is full")
+
+	// ErrConflict is returned by Add for a transaction that cannot be
+	// applied to the current state plus the pending transactions:
+	// it spends a missing or already-spent output, or reuses a nonce.
+	ErrConflict = errors.New("transaction conflicts with state or pending transactions")
+
+	// ErrTooOld is returned by Add for a transaction whose time range
+	// ends before the latest block.
+	ErrTooOld = errors.New("transaction time range has passed")
+
+	// ErrFees is returned by Add for a transaction whose fees, added
+	// to those of the pending transactions, overflow in some asset.
+	// No block could include all of them (see fee.AddTotals).
+	ErrFees = errors.New("transaction fees overflow pending total")
+)
+
+// Pool is a set of pending transactions, in the order they were
+// added. It is safe for concurrent use.
+type Pool struct {
+	maxTxs  int
+	policy  *Policy
+	plugins *txvm.Plugins
+
+	mu   sync.Mutex
+	txs  []*bc.CommitmentsTx
+	byID map[bc.Hash]*bc.CommitmentsTx
+
+	// spender maps each contract and nonce ID consumed by a pending
+	// transaction to that transaction.
+	spender map[bc.Hash]*bc.CommitmentsTx
+	subs    map[*Subscription]bool
+
+	// fees maps asset IDs to the total fees the pending transactions
+	// pay in them.
+	fees map[bc.Hash]int64
+
+	// base is the state the pending transactions apply to, and view
+	// is base with every pending transaction applied.
+	base, view *state.Snapshot
+
+	log *Log // nil if none
+}
+
+// New returns an empty Pool for transactions to be applied to
+// snapshot, holding at most maxTxs transactions (DefaultMaxTxs if
+// maxTxs is 0).
+func New(snapshot *state.Snapshot, maxTxs int) *Pool {
+	if maxTxs == 0 {
+		maxTxs = DefaultMaxTxs
+	}
+	return &Pool{
+		maxTxs:  maxTxs,
+		byID:    make(map[bc.Hash]*bc.CommitmentsTx),
+		spender: make(map[bc.Hash]*bc.CommitmentsTx),
+		base:    state.Copy(snapshot),
+		view:    state.Copy(snapshot),
+	}
+}
+
+// SetPolicy makes Add reject transactions that pol rejects, or, if
+// pol is nil, restores the default of admitting any valid
+// transaction. Transactions already pending are not rechecked.
+func (p *Pool) SetPolicy(pol *Policy) {
+	p.mu.Lock()
+	p.policy = pol
+	p.mu.Unlock()
+}
+
+// SetPlugins makes the plugin operations in p available to the
+// programs that the policy reruns and that AddBatch runs (see
+// txvm.WithPlugins). It should match the chain's (see
+// protocol.Chain.SetPlugins).
+func (p *Pool) SetPlugins(plugins *txvm.Plugins) {
+	p.mu.Lock()
+	p.plugins = plugins
+	p.mu.Unlock()
+}
+
+// vmOptions returns the options for running a program against p's
+// base state. It must be called with p.mu held.
+func (p *Pool) vmOptions() []txvm.Option {
+	return []txvm.Option{bc.NetworkOption(p.base.InitialBlockID), txvm.WithPlugins(p.plugins)}
+}
+
+// Add adds tx to the pool.
+//
+// If tx conflicts with pending transactions and the policy allows
+// replacement, tx replaces them when its fee rate is higher than
+// each of theirs. The replaced transactions, and any pending
+// transactions that depend on them, are removed, and a Replaced
+// event is sent for each.
+func (p *Pool) Add(tx *bc.Tx) error {
+	if !tx.Finalized {
+		return txvm.ErrUnfinalized
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.policy != nil {
+		err := p.policy.Check(tx, p.vmOptions()...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, ok := p.byID[tx.ID]; ok {
+		return ErrDuplicate
+	}
+	if len(p.txs) >= p.maxTxs {
+		return ErrFull
+	}
+	fees, err := addFees(p.fees, tx)
+	if err != nil {
+		return err
+	}
+	ct := bc.NewCommitmentsTx(tx)
+	err = applyTx(p.view, ct, p.view.TimestampMS())
+	if errors.Root(err) == ErrConflict && p.policy != nil && p.policy.Replace {
+		rerr := p.replace(ct)
+		if rerr == nil {
+			return nil
+		}
+		err = errors.WithDetail(err, rerr.Error())
+	}
+	if errors.Root(err) == ErrConflict {
+		p.notify(tx, false)
+	}
+	if err != nil {
+		return err
+	}
+	if p.log != nil {
+		if err := p.log.Append(tx); err != nil {
+			// Undo the application of tx to the view.
+			p.view, _, _ = p.replay(p.base, p.base.TimestampMS(), func(*bc.CommitmentsTx) bool { return false })
+			return err
+		}
+	}
+	p.notify(tx, false)
+	p.txs = append(p.txs, ct)
+	p.byID[tx.ID] = ct
+	p.fees = fees
+	for _, id := range consumed(tx) {
+		p.spender[id] = ct
+	}
+	return nil
+}
+
+// Check reports the error Add would return for tx, without adding
+// it. A transaction that Add would accept only as a replacement is
+// reported as conflicting.
+func (p *Pool) Check(tx *bc.Tx) error {
+	if !tx.Finalized {
+		return txvm.ErrUnfinalized
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.policy != nil {
+		err := p.policy.Check(tx, p.vmOptions()...)
+		if err != nil {
+			return err
+		}
+	}
+	if _, ok := p.byID[tx.ID]; ok {
+		return ErrDuplicate
+	}
+	if len(p.txs) >= p.maxTxs {
+		return ErrFull
+	}
+	if _, err := addFees(p.fees, tx); err != nil {
+		return err
+	}
+	return applyTx(state.Copy(p.view), bc.NewCommitmentsTx(tx), p.view.TimestampMS())
+}
+
+// replace adds tx in place of the pending transactions it conflicts
+// with, if it outbids them all. It must be called with p.mu held.
+func (p *Pool) replace(tx *bc.CommitmentsTx) error {
+	feeAsset := p.policy.FeeAsset
+	rate := fee.Rate(tx.Tx, feeAsset)
+	victims := make(map[bc.Hash]bc.Hash) // pending tx ID -> contested ID
+	for _, id := range consumed(tx.Tx) {
+		other := p.spender[id]
+		if other == nil {
+			continue
+		}
+		if r := fee.Rate(other.Tx, feeAsset); r >= rate {
+			return fmt.Errorf("fee rate %g does not exceed %g of %x", rate, r, other.Tx.ID.Bytes())
+		}
+		victims[other.Tx.ID] = id
+	}
+	if len(victims) == 0 {
+		return fmt.Errorf("no pending transaction to replace")
+	}
+
+	view, keep, dropped := p.replay(p.base, p.base.TimestampMS(), func(ct *bc.CommitmentsTx) bool {
+		_, ok := victims[ct.Tx.ID]
+		return ok
+	})
+	var fees map[bc.Hash]int64
+	for _, ct := range keep {
+		fees, _ = fee.AddTotals(fees, ct.Tx) // a subset of the pending fees cannot overflow
+	}
+	if _, err := addFees(fees, tx.Tx); err != nil {
+		return err
+	}
+	err := applyTx(view, tx, view.TimestampMS())
+	if err != nil {
+		return err
+	}
+	if p.log != nil {
+		err = p.log.Append(tx.Tx)
+		if err != nil {
+			return err
+		}
+	}
+	for _, old := range p.txs {
+		if id, ok := victims[old.Tx.ID]; ok {
+			p.send(Event{Type: Replaced, ID: id, Tx: old.Tx, By: tx.Tx})
+		}
+	}
+	for _, old := range dropped {
+		p.send(Event{Type: Replaced, Tx: old, By: tx.Tx})
+	}
+	p.install(p.base, view, append(keep, tx))
+	p.notify(tx.Tx, false)
+	return nil
+}
+
+// addFees returns fees plus the fees tx pays, or ErrFees.
+func addFees(fees map[bc.Hash]int64, tx *bc.Tx) (map[bc.Hash]int64, error) {
+	sums, err := fee.AddTotals(fees, tx)
+	if err != nil {
+		return nil, errors.WithDetail(ErrFees, errors.Detail(err))
+	}
+	return sums, nil
+}
+
+func applyTx(view *state.Snapshot, tx *bc.CommitmentsTx, nowMS uint64) error {
+	for _, tr := range tx.Tx.Timeranges {
+		if tr.MaxMS > 0 && nowMS > uint64(tr.MaxMS) {
+			return errors.WithDetailf(ErrTooOld, "max time %d, now %d", tr.MaxMS, nowMS)
+		}
+	}
+	err := view.ApplyTx(tx)
+	if err != nil {
+		return errors.WithDetail(ErrConflict, err.Error())
+	}
+	return nil
+}
+
+// replay applies the pending transactions, in order and at time
+// nowMS, to a copy of base, leaving out those for which skip is true.
+// It returns the resulting state, the transactions that applied, and
+// those that did not. It does not change p.
+func (p *Pool) replay(base *state.Snapshot, nowMS uint64, skip func(*bc.CommitmentsTx) bool) (view *state.Snapshot, keep []*bc.CommitmentsTx, dropped []*bc.Tx) {
+	view = state.Copy(base)
+	for _, tx := range p.txs {
+		if skip(tx) {
+			continue
+		}
+		if applyTx(view, tx, nowMS) != nil {
+			dropped = append(dropped, tx.Tx)
+			continue
+		}
+		keep = append(keep, tx)
+	}
+	return view, keep, dropped
+}
+
+// install makes keep, applied to base to give view, the pending
+// transactions.
+func (p *Pool) install(base, view *state.Snapshot, keep []*bc.CommitmentsTx) {
+	if p.log != nil && p.log.Len() > logCompactFactor*len(keep)+logCompactSlack {
+		// If compacting fails, the log still holds a superset of
+		// keep, which is all that restoring it needs.
+		p.log.Rewrite(txsOf(keep))
+	}
+	p.txs = keep
+	p.base = base
+	p.view = view
+	p.byID = make(map[bc.Hash]*bc.CommitmentsTx)
+	p.spender = make(map[bc.Hash]*bc.CommitmentsTx)
+	p.fees = nil
+	for _, tx := range keep {
+		p.byID[tx.Tx.ID] = tx
+		p.fees, _ = fee.AddTotals(p.fees, tx.Tx) // a subset of the pending fees cannot overflow
+		for _, id := range consumed(tx.Tx) {
+			p.spender[id] = tx
+		}
+	}
+}
+
+// A pool's log is compacted when it holds more than
+// logCompactFactor times as many transactions as the pool, plus
+// logCompactSlack.
+const (
+	logCompactFactor = 4
+	logCompactSlack  = 1000
+)
+
+// SetLog makes p record each transaction it admits in l, which it
+// first rewrites to hold just p's pending transactions. With a log,
+// Add fails if it cannot record the transaction. See Log.
+//
+// To restore the transactions l held when it was opened, pass them to
+// AddBatch before calling SetLog.
+func (p *Pool) SetLog(l *Log) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := l.Rewrite(txsOf(p.txs))
+	if err != nil {
+		return err
+	}
+	p.log = l
+	return nil
+}
+
+func txsOf(cts []*bc.CommitmentsTx) []*bc.Tx {
+	txs := make([]*bc.Tx, 0, len(cts))
+	for _, ct := range cts {
+		txs = append(txs, ct.Tx)
+	}
+	return txs
+}
+
+// Pending returns the pending transactions in the order they were
+// added. Applied in that order to the pool's snapshot, all of them
+// are valid.
+func (p *Pool) Pending() []*bc.CommitmentsTx {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*bc.CommitmentsTx(nil), p.txs...)
+}
+
+// Len returns the number of pending transactions.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.txs)
+}
+
+// Get returns the pending transaction with the given ID, or nil.
+func (p *Pool) Get(id bc.Hash) *bc.Tx {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if tx := p.byID[id]; tx != nil {
+		return tx.Tx
+	}
+	return nil
+}
+
+// Update makes snapshot, typically the state after a newly committed
+// block, the pool's base state. Pending transactions are re-applied
+// to it in order; those that no longer apply are removed and
+// returned. A transaction included in the block is removed too, but
+// is not returned. An Expired event is sent for each transaction
+// removed because its time range ends before the block.
+func (p *Pool) Update(snapshot *state.Snapshot, b *bc.Block) (dropped []*bc.Tx) {
+	included := make(map[bc.Hash]bool)
+	if b != nil {
+		for _, tx := range b.Transactions {
+			included[tx.ID] = true
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if b != nil {
+		for _, tx := range b.Transactions {
+			p.notify(tx, true)
+		}
+	}
+
+	base := state.Copy(snapshot)
+	view, keep, dropped := p.replay(base, base.TimestampMS(), func(tx *bc.CommitmentsTx) bool {
+		return included[tx.Tx.ID]
+	})
+	p.notifyExpired(dropped, base.TimestampMS())
+	p.install(base, view, keep)
+	return dropped
+}
+
+// Expire removes the pending transactions whose time ranges end
+// before now, and any that depend on them, and returns them. It sends
+// an Expired event for each. Update does the same using block
+// timestamps; Expire lets a node stop relaying a transaction that
+// has expired before the next block.
+func (p *Pool) Expire(now time.Time) (expired []*bc.Tx) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	nowMS := bc.Millis(now)
+	if ts := p.base.TimestampMS(); nowMS < ts {
+		nowMS = ts
+	}
+	view, keep, expired := p.replay(p.base, nowMS, func(*bc.CommitmentsTx) bool { return false })
+	if len(expired) == 0 {
+		return nil
+	}
+	for _, tx := range expired {
+		p.send(Event{Type: Expired, Tx: tx})
+	}
+	p.install(p.base, view, keep)
+	return expired
+}
+
+// notifyExpired sends an Expired event for each transaction in txs
+// whose time range ends before nowMS.
+func (p *Pool) notifyExpired(txs []*bc.Tx, nowMS uint64) {
+	for _, tx := range txs {
+		for _, tr := range tx.Timeranges {
+			if tr.MaxMS > 0 && nowMS > uint64(tr.MaxMS) {
+				p.send(Event{Type: Expired, Tx: tx})
+				break
+			}
+		}
+	}
+}
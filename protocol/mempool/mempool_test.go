@@ -0,0 +1,660 @@
+package mempool
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/fee"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txbuilder/txresult"
+	"i10r.io/protocol/txgen"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/op"
+)
+
+func TestPool(t *testing.T) {
+	c := prottest.NewChain(t)
+	g := txgen.New(1)
+
+	txs, err := g.Txs(c.State(), time.Now(), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(c.State(), 4)
+	for i, tx := range txs {
+		err := p.Add(tx)
+		if i < 4 && err != nil {
+			t.Fatalf("adding tx %d: %v", i, err)
+		}
+		if i == 4 && err != ErrFull {
+			t.Errorf("adding tx beyond capacity: got %v, want %v", err, ErrFull)
+		}
+	}
+	if err := p.Add(txs[0]); err != ErrDuplicate {
+		t.Errorf("adding duplicate: got %v, want %v", err, ErrDuplicate)
+	}
+	if p.Get(txs[1].ID) != txs[1] {
+		t.Error("Get did not find pending tx")
+	}
+
+	var pending []*bc.Tx
+	for _, tx := range p.Pending() {
+		pending = append(pending, tx.Tx)
+	}
+	if len(pending) != 4 || pending[0] != txs[0] || pending[3] != txs[3] {
+		t.Fatalf("Pending returned %d txs, not in order added", len(pending))
+	}
+
+	// Commit the first two. They leave the pool; the others stay.
+	b := prottest.MakeBlock(t, c, pending[:2])
+	dropped := p.Update(c.State(), b)
+	if len(dropped) != 0 || p.Len() != 2 {
+		t.Errorf("after block: %d dropped, %d pending; want 0, 2", len(dropped), p.Len())
+	}
+
+	// A transaction already in the state conflicts with it.
+	if err := p.Add(txs[0]); errors.Root(err) != ErrConflict {
+		t.Errorf("adding committed tx: got %v, want %v", err, ErrConflict)
+	}
+
+	// Committing the rest without telling Update which block they
+	// were in makes them conflict, so they are dropped.
+	prottest.MakeBlock(t, c, pending[2:])
+	dropped = p.Update(c.State(), nil)
+	if len(dropped) != 2 || p.Len() != 0 {
+		t.Errorf("after unannounced block: %d dropped, %d pending; want 2, 0", len(dropped), p.Len())
+	}
+}
+
+// feeKeys controls the outputs made by the fee tests.
+type feeKeys struct {
+	pubs    []ed25519.PublicKey
+	prv     ed25519.PrivateKey
+	assetID bc.Hash
+}
+
+func newFeeKeys(t *testing.T) *feeKeys {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubs := []ed25519.PublicKey{pub}
+	return &feeKeys{pubs: pubs, prv: prv, assetID: bc.NewHash(standard.AssetID(2, 1, pubs, nil))}
+}
+
+func (k *feeKeys) finish(t *testing.T, tpl *txbuilder.Template) *bc.Tx {
+	err := tpl.Sign(context.Background(), func(_ context.Context, msg, _ []byte, _ [][]byte) ([]byte, error) {
+		return ed25519.Sign(k.prv, msg), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := tpl.Tx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+// issue issues 100 units, paying feeAmount of them as a fee.
+func (k *feeKeys) issue(t *testing.T, c *protocol.Chain, feeAmount int64) *bc.Tx {
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.AddIssuance(2, c.InitialBlockHash.Bytes(), nil, 1, [][]byte{k.pubs[0]}, nil, k.pubs, 100, nil, []byte{byte(feeAmount)})
+	tpl.AddOutput(1, k.pubs, 100-feeAmount, k.assetID, nil, nil)
+	if feeAmount > 0 {
+		tpl.AddFee(feeAmount, k.assetID)
+	}
+	return k.finish(t, tpl)
+}
+
+// issueMax issues math.MaxInt64 units, paying all but one of them as
+// a fee. Transactions with different refs differ.
+func (k *feeKeys) issueMax(t *testing.T, c *protocol.Chain, ref byte) *bc.Tx {
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.AddIssuance(2, c.InitialBlockHash.Bytes(), nil, 1, [][]byte{k.pubs[0]}, nil, k.pubs, math.MaxInt64, nil, []byte{'m', ref})
+	tpl.AddOutput(1, k.pubs, 1, k.assetID, nil, nil)
+	tpl.AddFee(math.MaxInt64-1, k.assetID)
+	return k.finish(t, tpl)
+}
+
+// spend spends the first output of parent, paying feeAmount as a fee.
+func (k *feeKeys) spend(t *testing.T, parent *bc.Tx, feeAmount int64) *bc.Tx {
+	out := txresult.New(parent).Outputs[0]
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.AddInput(1, [][]byte{k.pubs[0]}, nil, k.pubs, int64(out.Value.Amount), k.assetID, out.Value.Anchor, nil, 2)
+	tpl.AddOutput(1, k.pubs, int64(out.Value.Amount)-feeAmount, k.assetID, nil, nil)
+	if feeAmount > 0 {
+		tpl.AddFee(feeAmount, k.assetID)
+	}
+	return k.finish(t, tpl)
+}
+
+func TestPrioritized(t *testing.T) {
+	c := prottest.NewChain(t)
+	pub, prv, _ := ed25519.GenerateKey(nil)
+	pubs := []ed25519.PublicKey{pub}
+	signer := func(_ context.Context, msg, _ []byte, _ [][]byte) ([]byte, error) {
+		return ed25519.Sign(prv, msg), nil
+	}
+	assetID := bc.NewHash(standard.AssetID(2, 1, pubs, nil))
+	finish := func(tpl *txbuilder.Template) *bc.Tx {
+		if err := tpl.Sign(context.Background(), signer); err != nil {
+			t.Fatal(err)
+		}
+		tx, err := tpl.Tx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+	issue := func(feeAmount int64) *bc.Tx {
+		tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+		tpl.AddIssuance(2, c.InitialBlockHash.Bytes(), nil, 1, [][]byte{pub}, nil, pubs, 100, nil, []byte{byte(feeAmount)})
+		tpl.AddOutput(1, pubs, 100-feeAmount, assetID, nil, nil)
+		if feeAmount > 0 {
+			tpl.AddFee(feeAmount, assetID)
+		}
+		return finish(tpl)
+	}
+
+	low, high := issue(0), issue(20)
+
+	// child spends low's output and pays a middling fee.
+	res := txresult.New(low)
+	out := res.Outputs[0]
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.AddInput(1, [][]byte{pub}, nil, pubs, int64(out.Value.Amount), assetID, out.Value.Anchor, nil, 2)
+	tpl.AddOutput(1, pubs, int64(out.Value.Amount)-10, assetID, nil, nil)
+	tpl.AddFee(10, assetID)
+	child := finish(tpl)
+	mid := issue(5)
+
+	p := New(c.State(), 0)
+	for _, tx := range []*bc.Tx{low, child, mid, high} {
+		if err := p.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var got []*bc.Tx
+	for _, tx := range p.Prioritized(assetID) {
+		got = append(got, tx.Tx)
+	}
+	want := []*bc.Tx{high, low, child, mid}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Prioritized order wrong at %d", i)
+		}
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	c := prottest.NewChain(t)
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubs := []ed25519.PublicKey{pub}
+	assetID := bc.NewHash(standard.AssetID(2, 1, pubs, nil))
+	finish := func(tpl *txbuilder.Template) *bc.Tx {
+		err := tpl.Sign(context.Background(), func(_ context.Context, msg, _ []byte, _ [][]byte) ([]byte, error) {
+			return ed25519.Sign(prv, msg), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx, err := tpl.Tx()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tx
+	}
+
+	// tx issues 100 units, paying 10 of them as a fee.
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.AddIssuance(2, c.InitialBlockHash.Bytes(), nil, 1, [][]byte{pub}, nil, pubs, 100, nil, []byte{10})
+	tpl.AddOutput(1, pubs, 90, assetID, nil, nil)
+	tpl.AddFee(10, assetID)
+	tx := finish(tpl)
+	rate := fee.Rate(tx, assetID)
+
+	cases := []struct {
+		pol  Policy
+		want error
+	}{
+		{Policy{}, nil},
+		{Policy{MaxSize: len(tx.Program)}, nil},
+		{Policy{MaxSize: len(tx.Program) - 1}, ErrTooLarge},
+		{Policy{MaxRunlimit: tx.Runlimit - 1}, ErrRunlimit},
+		{Policy{FeeAsset: assetID, MinFeeRate: rate}, nil},
+		{Policy{FeeAsset: assetID, MinFeeRate: rate * 2}, ErrFeeRate},
+		{Policy{FeeAsset: bc.Hash{}, MinFeeRate: rate}, ErrFeeRate},
+		{Policy{DustThreshold: 90}, nil},
+		{Policy{DustThreshold: 91}, ErrDust},
+		{Policy{BannedOps: [][]byte{{op.Issue}}}, ErrBannedOp},
+		{Policy{BannedOps: [][]byte{{op.Dup, op.MinPushdata, op.Bury}}}, ErrBannedOp},
+		{Policy{BannedOps: [][]byte{{op.Issue, op.Issue}}}, nil},
+		{Policy{StrictEncoding: true}, nil},
+	}
+	for i, c := range cases {
+		err := c.pol.Check(tx)
+		if errors.Root(err) != c.want {
+			t.Errorf("case %d: got %v, want %v", i, err, c.want)
+		}
+		if IsPolicy(err) != (c.want != nil) {
+			t.Errorf("case %d: IsPolicy(%v) = %v", i, err, IsPolicy(err))
+		}
+	}
+
+	// The same transaction with a redundantly encoded "0 drop" in
+	// front.
+	prog := append([]byte{0x80 | op.MinSmallInt, 0, op.Drop}, tx.Program...)
+	reenc, err := bc.NewTx(prog, tx.Version, tx.Runlimit+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reenc.ID != tx.ID {
+		t.Fatal("re-encoding changed the transaction ID")
+	}
+	if err := (&Policy{StrictEncoding: true}).Check(reenc); errors.Root(err) != ErrEncoding {
+		t.Errorf("re-encoded: got %v, want %v", err, ErrEncoding)
+	}
+
+	// A network-bound transaction runs again with its network.
+	tpl = txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.SetNetwork(c.InitialBlockHash)
+	tpl.AddIssuance(2, c.InitialBlockHash.Bytes(), nil, 1, [][]byte{pub}, nil, pubs, 100, nil, []byte("v5"))
+	tpl.AddOutput(1, pubs, 100, assetID, nil, nil)
+	tx5 := finish(tpl)
+	strict := &Policy{StrictEncoding: true}
+	if err := strict.Check(tx5); err == nil {
+		t.Error("version 5 without its network: got no error")
+	}
+	if err := strict.Check(tx5, bc.NetworkOption(c.InitialBlockHash)); err != nil {
+		t.Errorf("version 5 with its network: %v", err)
+	}
+
+	p := New(c.State(), 0)
+	p.SetPolicy(strict)
+	if err := p.Add(tx5); err != nil {
+		t.Errorf("Add version 5 with policy: %v", err)
+	}
+	p = New(c.State(), 0)
+	p.SetPolicy(&Policy{DustThreshold: 1000})
+	if err := p.Add(tx); !IsPolicy(err) {
+		t.Errorf("Add with policy: got %v, want policy error", err)
+	}
+	p.SetPolicy(nil)
+	if err := p.Add(tx); err != nil {
+		t.Errorf("Add without policy: %v", err)
+	}
+	if err := p.Add(tx); IsPolicy(err) || errors.Root(err) != ErrDuplicate {
+		t.Errorf("duplicate: got %v, want non-policy %v", err, ErrDuplicate)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	c := prottest.NewChain(t)
+	k := newFeeKeys(t)
+	parent := k.issue(t, c, 0)
+	first, second := k.spend(t, parent, 0), k.spend(t, parent, 1)
+	outID := parent.Outputs[0].ID
+
+	p := New(c.State(), 0)
+	sub := p.Subscribe(10)
+	defer sub.Close()
+	sub.Watch(outID, first.ID)
+	full := p.Subscribe(0)
+
+	for _, tx := range []*bc.Tx{parent, first} {
+		if err := p.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sub.C) != 0 {
+		t.Fatalf("got %d events for expected spends", len(sub.C))
+	}
+
+	err := p.Add(second)
+	if errors.Root(err) != ErrConflict {
+		t.Fatalf("Add(second) = %v, want %v", err, ErrConflict)
+	}
+	want := []Event{
+		{Type: Conflicted, ID: outID, Tx: first, By: second},
+		{Type: Spent, ID: outID, By: second},
+	}
+	for _, w := range want {
+		if got := <-sub.C; got != w {
+			t.Errorf("got %+v, want %+v", got, w)
+		}
+	}
+
+	// second wins in a block, knocking first out of the pool.
+	b := prottest.MakeBlock(t, c, []*bc.Tx{parent, second})
+	if dropped := p.Update(c.State(), b); len(dropped) != 1 || dropped[0] != first {
+		t.Fatalf("dropped %v, want first", dropped)
+	}
+	for _, w := range want {
+		w.InBlock = true
+		if got := <-sub.C; got != w {
+			t.Errorf("got %+v, want %+v", got, w)
+		}
+	}
+
+	if got := full.Missed(); got != 2 {
+		t.Errorf("unbuffered subscription missed %d events, want 2", got)
+	}
+	full.Close()
+	if _, ok := <-full.C; ok {
+		t.Error("closed subscription channel still open")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	c := prottest.NewChain(t)
+	k := newFeeKeys(t)
+	parent := k.issue(t, c, 0)
+	first := k.spend(t, parent, 1)
+	child := k.spend(t, first, 0)
+	second, low := k.spend(t, parent, 5), k.spend(t, parent, 2)
+
+	p := New(c.State(), 0)
+	for _, tx := range []*bc.Tx{parent, first, child} {
+		if err := p.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Add(second); errors.Root(err) != ErrConflict {
+		t.Fatalf("Add without replacement: got %v, want %v", err, ErrConflict)
+	}
+
+	p.SetPolicy(&Policy{FeeAsset: k.assetID, Replace: true})
+	sub := p.Subscribe(10)
+	defer sub.Close()
+	if err := p.Add(second); err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{
+		{Type: Replaced, ID: parent.Outputs[0].ID, Tx: first, By: second},
+		{Type: Replaced, Tx: child, By: second},
+	}
+	for _, w := range want {
+		if got := <-sub.C; got != w {
+			t.Errorf("got %+v, want %+v", got, w)
+		}
+	}
+	if p.Len() != 2 || p.Get(second.ID) == nil || p.Get(first.ID) != nil {
+		t.Errorf("pool after replacement holds %d txs", p.Len())
+	}
+
+	// A lower fee rate does not replace.
+	if err := p.Add(low); errors.Root(err) != ErrConflict {
+		t.Errorf("Add with lower fee: got %v, want %v", err, ErrConflict)
+	}
+	if p.Get(second.ID) == nil {
+		t.Error("second replaced by lower fee")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c := prottest.NewChain(t)
+	k := newFeeKeys(t)
+	parent := k.issue(t, c, 0)
+	first, second := k.spend(t, parent, 1), k.spend(t, parent, 5)
+
+	p := New(c.State(), 0)
+	if err := p.Check(first); errors.Root(err) != ErrConflict {
+		t.Errorf("Check(first) before parent: got %v, want %v", err, ErrConflict)
+	}
+	if err := p.Check(parent); err != nil {
+		t.Fatal(err)
+	}
+	if p.Len() != 0 {
+		t.Fatalf("Check added to the pool")
+	}
+	for _, tx := range []*bc.Tx{parent, first} {
+		if err := p.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Check(first); err != ErrDuplicate {
+		t.Errorf("Check(first) after Add: got %v, want %v", err, ErrDuplicate)
+	}
+	if err := p.Check(second); errors.Root(err) != ErrConflict {
+		t.Errorf("Check(second): got %v, want %v", err, ErrConflict)
+	}
+	if err := p.Add(k.spend(t, first, 0)); err != nil {
+		t.Errorf("Add after Check: %v", err)
+	}
+}
+
+func TestFeeOverflow(t *testing.T) {
+	c := prottest.NewChain(t)
+	k := newFeeKeys(t)
+	first, second := k.issueMax(t, c, 1), k.issueMax(t, c, 2)
+
+	p := New(c.State(), 0)
+	if err := p.Add(first); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Check(second); errors.Root(err) != ErrFees {
+		t.Errorf("Check(second): got %v, want %v", err, ErrFees)
+	}
+	if err := p.Add(second); errors.Root(err) != ErrFees {
+		t.Errorf("Add(second): got %v, want %v", err, ErrFees)
+	}
+
+	// Once first is in a block, second fits.
+	b := prottest.MakeBlock(t, c, []*bc.Tx{first})
+	p.Update(c.State(), b)
+	if err := p.Add(second); err != nil {
+		t.Errorf("Add(second) after block: %v", err)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	c := prottest.NewChain(t)
+	k := newFeeKeys(t)
+	parent := k.issue(t, c, 0)
+	child := k.spend(t, parent, 0)
+
+	p := New(c.State(), 0)
+	for _, tx := range []*bc.Tx{parent, child} {
+		if err := p.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sub := p.Subscribe(10)
+	defer sub.Close()
+
+	if expired := p.Expire(time.Now()); len(expired) != 0 {
+		t.Fatalf("Expire(now) removed %d txs", len(expired))
+	}
+	expired := p.Expire(time.Now().Add(time.Hour))
+	if len(expired) != 2 || p.Len() != 0 {
+		t.Fatalf("Expire(now+1h) removed %d txs, %d left", len(expired), p.Len())
+	}
+	for _, tx := range expired {
+		if got := <-sub.C; got != (Event{Type: Expired, Tx: tx}) {
+			t.Errorf("got %+v, want Expired event for %x", got, tx.ID.Bytes())
+		}
+	}
+	if err := p.Add(parent); err != nil {
+		t.Errorf("re-adding parent: %v", err)
+	}
+}
+
+func TestValidateTxBatch(t *testing.T) {
+	c := prottest.NewChain(t)
+	g := txgen.New(1)
+
+	txs, err := g.Txs(c.State(), time.Now(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := []*bc.RawTx{&txs[0].RawTx, &txs[1].RawTx, &txs[0].RawTx, {Version: 3, Runlimit: 10000, Program: []byte{op.Verify}}, &txs[2].RawTx}
+	net := bc.NetworkOption(c.InitialBlockHash)
+	results := ValidateTxBatch(context.Background(), raw, c.State(), net)
+	if len(results) != len(raw) {
+		t.Fatalf("got %d results, want %d", len(results), len(raw))
+	}
+	for i, want := range map[int]*bc.Tx{0: txs[0], 1: txs[1], 4: txs[2]} {
+		if r := results[i]; r.Err != nil || r.Tx == nil || r.Tx.ID != want.ID {
+			t.Errorf("tx %d: got %v, %v; want %x", i, r.Tx, r.Err, want.ID.Bytes())
+		}
+	}
+	if errors.Root(results[2].Err) != ErrConflict {
+		t.Errorf("repeated tx: got error %v, want %v", results[2].Err, ErrConflict)
+	}
+	if results[3].Err == nil || results[3].Tx != nil {
+		t.Errorf("failing program: got %v, %v; want error", results[3].Tx, results[3].Err)
+	}
+
+	p := New(c.State(), 0)
+	results = p.AddBatch(context.Background(), raw, net)
+	if p.Len() != 3 || errors.Root(results[2].Err) != ErrDuplicate {
+		t.Errorf("AddBatch: %d pending, repeated tx error %v; want 3, %v", p.Len(), results[2].Err, ErrDuplicate)
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	c := prottest.NewChain(t)
+	k := newFeeKeys(t)
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.SetNetwork(c.InitialBlockHash)
+	tpl.AddIssuance(2, c.InitialBlockHash.Bytes(), nil, 1, [][]byte{k.pubs[0]}, nil, k.pubs, 100, nil, nil)
+	tpl.AddOutput(1, k.pubs, 100, k.assetID, nil, nil)
+	tx := k.finish(t, tpl)
+	one, err := txvm.NewPlugins(txvm.PluginOp{
+		Selector: txvm.MinPluginSelector,
+		Name:     "one",
+		Func:     func([]txvm.Data) ([]txvm.Data, error) { return []txvm.Data{txvm.Int(1)}, nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix, err := asm.Assemble("65536 ext verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The plugin operation, which tx's ID does not depend on, in
+	// front.
+	raw := []*bc.RawTx{{
+		Version:  tx.Version,
+		Runlimit: tx.Runlimit + 1000,
+		Program:  append(prefix, tx.Program...),
+	}}
+	net := bc.NetworkOption(c.InitialBlockHash)
+
+	p := New(c.State(), 0)
+	results := p.AddBatch(context.Background(), raw, net)
+	if errors.Root(results[0].Err) != txvm.ErrExt {
+		t.Errorf("without plugins: got error %v, want %v", results[0].Err, txvm.ErrExt)
+	}
+
+	// The policy runs the program again, with the plugins too.
+	p.SetPolicy(&Policy{StrictEncoding: true})
+	p.SetPlugins(one)
+	results = p.AddBatch(context.Background(), raw, net)
+	if results[0].Err != nil {
+		t.Errorf("with plugins: %v", results[0].Err)
+	}
+	if p.Len() != 1 {
+		t.Errorf("got %d pending, want 1", p.Len())
+	}
+}
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mempool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "mempool.log")
+
+	c := prottest.NewChain(t)
+	g := txgen.New(1)
+	txs, err := g.Txs(c.State(), time.Now(), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, restored, err := OpenLog(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 0 {
+		t.Fatalf("new log holds %d transactions", len(restored))
+	}
+	p := New(c.State(), 0)
+	if err := p.SetLog(l); err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range txs {
+		if err := p.Add(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if l.Len() != len(txs) {
+		t.Errorf("log holds %d transactions, want %d", l.Len(), len(txs))
+	}
+	l.Close()
+
+	// A record cut short by a crash is dropped.
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{100, 1, 2, 3})
+	f.Close()
+
+	// The first transaction is committed while the node is down.
+	prottest.MakeBlock(t, c, txs[:1])
+
+	l, restored, err = OpenLog(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if len(restored) != len(txs) {
+		t.Fatalf("restored %d transactions, want %d", len(restored), len(txs))
+	}
+	for i, raw := range restored {
+		if !bytes.Equal(raw.Program, txs[i].Program) || raw.Version != txs[i].Version || raw.Runlimit != txs[i].Runlimit {
+			t.Errorf("restored tx %d differs", i)
+		}
+	}
+	p = New(c.State(), 0)
+	results := p.AddBatch(context.Background(), restored)
+	if errors.Root(results[0].Err) != ErrConflict || p.Len() != len(txs)-1 {
+		t.Errorf("restoring: %d pending, committed tx error %v; want %d, %v", p.Len(), results[0].Err, len(txs)-1, ErrConflict)
+	}
+	if err := p.SetLog(l); err != nil {
+		t.Fatal(err)
+	}
+	if l.Len() != p.Len() {
+		t.Errorf("rewritten log holds %d transactions, want %d", l.Len(), p.Len())
+	}
+	if _, again, err := OpenLog(name); err != nil || len(again) != p.Len() {
+		t.Errorf("reopening rewritten log: %d transactions, %v; want %d", len(again), err, p.Len())
+	}
+
+	if err := ioutil.WriteFile(name, []byte("not a log"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := OpenLog(name); errors.Root(err) != ErrLogFormat {
+		t.Errorf("opening a non-log: got error %v, want %v", err, ErrLogFormat)
+	}
+}
@@ -0,0 +1,179 @@
+package mempool
+
+import "i10r.io/protocol/bc"
+
+// EventType tells what an Event reports.
+type EventType int
+
+const (
+	// Conflicted reports a transaction that competes with a pending
+	// one for a contract or nonce: either it was submitted to Add
+	// and rejected, or it appeared in a block and the pending
+	// transaction was dropped by Update.
+	Conflicted EventType = iota
+
+	// Spent reports that a watched contract or nonce was consumed by
+	// a transaction other than the one the watcher expected.
+	Spent
+
+	// Replaced reports a pending transaction removed in favor of By,
+	// a conflicting transaction paying a higher fee rate. ID is the
+	// contested contract or nonce, or zero for a transaction removed
+	// only because it depended on a replaced one.
+	Replaced
+
+	// Expired reports a pending transaction removed because its time
+	// range had passed.
+	Expired
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Conflicted:
+		return "conflicted"
+	case Spent:
+		return "spent"
+	case Replaced:
+		return "replaced"
+	case Expired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// Event is a notification sent to a Subscription.
+type Event struct {
+	Type EventType
+
+	// ID is the contract or nonce ID in contention.
+	ID bc.Hash
+
+	// Tx is the pending transaction the event is about. It is nil
+	// for a Spent event.
+	Tx *bc.Tx
+
+	// By is the transaction that consumed ID, or tried to. It is nil
+	// for an Expired event.
+	By *bc.Tx
+
+	// InBlock tells whether By is in a committed block. Otherwise it
+	// was submitted to the pool, and for a Conflicted event was
+	// rejected. A transaction seen first in the pool and then in a
+	// block produces an event for each.
+	InBlock bool
+}
+
+// Subscription receives events from a Pool on C. Sends never block:
+// an event that finds C full is discarded and counted in Missed.
+type Subscription struct {
+	C <-chan Event
+
+	c      chan Event
+	pool   *Pool
+	watch  map[bc.Hash]bc.Hash
+	missed int
+	closed bool
+}
+
+// Subscribe returns a Subscription whose channel buffers up to n
+// events. It receives every Conflicted, Replaced, and Expired event,
+// and Spent events for the IDs it watches.
+func (p *Pool) Subscribe(n int) *Subscription {
+	c := make(chan Event, n)
+	s := &Subscription{
+		C:     c,
+		c:     c,
+		pool:  p,
+		watch: make(map[bc.Hash]bc.Hash),
+	}
+	p.mu.Lock()
+	if p.subs == nil {
+		p.subs = make(map[*Subscription]bool)
+	}
+	p.subs[s] = true
+	p.mu.Unlock()
+	return s
+}
+
+// Watch asks for a Spent event when the contract or nonce with the
+// given ID is consumed by any transaction but the one with ID txID,
+// whether in the pool or in a block. A zero txID expects no
+// transaction, so that any spend is reported.
+func (s *Subscription) Watch(id, txID bc.Hash) {
+	s.pool.mu.Lock()
+	s.watch[id] = txID
+	s.pool.mu.Unlock()
+}
+
+// Unwatch cancels Watch for id.
+func (s *Subscription) Unwatch(id bc.Hash) {
+	s.pool.mu.Lock()
+	delete(s.watch, id)
+	s.pool.mu.Unlock()
+}
+
+// Missed returns the number of events discarded because C was full.
+func (s *Subscription) Missed() int {
+	s.pool.mu.Lock()
+	defer s.pool.mu.Unlock()
+	return s.missed
+}
+
+// Close stops the subscription and closes C.
+func (s *Subscription) Close() {
+	s.pool.mu.Lock()
+	defer s.pool.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		delete(s.pool.subs, s)
+		close(s.c)
+	}
+}
+
+// send delivers ev to s. It must be called with s.pool.mu held.
+func (s *Subscription) send(ev Event) {
+	select {
+	case s.c <- ev:
+	default:
+		s.missed++
+	}
+}
+
+// send delivers ev to every subscription. It must be called with
+// p.mu held.
+func (p *Pool) send(ev Event) {
+	for s := range p.subs {
+		s.send(ev)
+	}
+}
+
+// consumed returns the IDs of the contracts and nonces tx uses up.
+func consumed(tx *bc.Tx) []bc.Hash {
+	ids := make([]bc.Hash, 0, len(tx.Inputs)+len(tx.Nonces))
+	for _, in := range tx.Inputs {
+		ids = append(ids, in.ID)
+	}
+	for _, n := range tx.Nonces {
+		ids = append(ids, n.ID)
+	}
+	return ids
+}
+
+// notify sends the events caused by tx. Pending transactions holding
+// a contract or nonce that tx also consumes are reported as
+// conflicted. It must be called with p.mu held.
+func (p *Pool) notify(tx *bc.Tx, inBlock bool) {
+	if len(p.subs) == 0 {
+		return
+	}
+	for _, id := range consumed(tx) {
+		if other := p.spender[id]; other != nil && other.Tx.ID != tx.ID {
+			p.send(Event{Type: Conflicted, ID: id, Tx: other.Tx, By: tx, InBlock: inBlock})
+		}
+		for s := range p.subs {
+			if want, ok := s.watch[id]; ok && want != tx.ID {
+				s.send(Event{Type: Spent, ID: id, By: tx, InBlock: inBlock})
+			}
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package mempool
+
+import (
+	"bytes"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/fee"
+	"i10r.io/protocol/txbuilder/txresult"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+)
+
+// Errors returned by Add for a transaction that a Policy rejects. The
+// transaction may be perfectly valid; these are a node's local
+// choices about what to relay and put in blocks, not consensus rules.
+// IsPolicy distinguishes them from other errors.
+var (
+	ErrFeeRate  = errors.New("policy: fee rate below minimum")
+	ErrTooLarge = errors.New("policy: transaction too large")
+	ErrRunlimit = errors.New("policy: runlimit too high")
+	ErrBannedOp = errors.New("policy: banned opcode sequence")
+	ErrDust     = errors.New("policy: output below dust threshold")
+	ErrEncoding = errors.New("policy: non-canonical encoding")
+)
+
+// IsPolicy reports whether err is one of the errors returned for a
+// transaction rejected by a Policy.
+func IsPolicy(err error) bool {
+	switch errors.Root(err) {
+	case ErrFeeRate, ErrTooLarge, ErrRunlimit, ErrBannedOp, ErrDust, ErrEncoding:
+		return true
+	}
+	return false
+}
+
+// Policy is a set of limits on the transactions a Pool admits. The
+// zero value of each field imposes no limit.
+type Policy struct {
+	// FeeAsset and MinFeeRate require the transaction to pay a fee
+	// in FeeAsset of at least MinFeeRate per unit of runlimit (see
+	// package fee).
+	FeeAsset   bc.Hash
+	MinFeeRate float64
+
+	// MaxSize is the largest program, in bytes, admitted.
+	MaxSize int
+
+	// MaxRunlimit is the largest runlimit admitted.
+	MaxRunlimit int64
+
+	// BannedOps lists opcode sequences the transaction must not
+	// execute. Each is matched against the consecutive
+	// instructions executed, in any contract; op.MinPushdata in a
+	// sequence matches any pushdata or small-integer instruction.
+	BannedOps [][]byte
+
+	// DustThreshold is the smallest amount each output the
+	// transaction creates must hold. Only outputs of the standard
+	// contracts, whose values txresult can parse, are checked.
+	DustThreshold int64
+
+	// StrictEncoding requires the transaction program to pass
+	// validation with txvm.StrictEncoding, so that relays cannot
+	// re-encode it.
+	StrictEncoding bool
+
+	// Replace lets a transaction that conflicts with pending ones
+	// replace them if it pays a higher fee rate in FeeAsset than
+	// each of them.
+	Replace bool
+}
+
+// Check returns an error if pol rejects tx. The options opts are
+// passed to the VM when tx is run again to check BannedOps or
+// StrictEncoding; they must include the network (see
+// bc.NetworkOption) for a transaction of version txvm.NetworkVersion
+// or later, whose signatures cover an ID committing to it.
+func (pol *Policy) Check(tx *bc.Tx, opts ...txvm.Option) error {
+	if pol.MaxSize > 0 && len(tx.Program) > pol.MaxSize {
+		return errors.WithDetailf(ErrTooLarge, "%d bytes, max %d", len(tx.Program), pol.MaxSize)
+	}
+	if pol.MaxRunlimit > 0 && tx.Runlimit > pol.MaxRunlimit {
+		return errors.WithDetailf(ErrRunlimit, "runlimit %d, max %d", tx.Runlimit, pol.MaxRunlimit)
+	}
+	if pol.MinFeeRate > 0 {
+		if rate := fee.Rate(tx, pol.FeeAsset); rate < pol.MinFeeRate {
+			return errors.WithDetailf(ErrFeeRate, "rate %g, min %g", rate, pol.MinFeeRate)
+		}
+	}
+	if pol.DustThreshold > 0 {
+		for _, out := range txresult.New(tx).Outputs {
+			if out.Value != nil && int64(out.Value.Amount) < pol.DustThreshold {
+				return errors.WithDetailf(ErrDust, "output %x holds %d, threshold %d", out.OutputID.Bytes(), out.Value.Amount, pol.DustThreshold)
+			}
+		}
+	}
+	if len(pol.BannedOps) > 0 || pol.StrictEncoding {
+		return pol.rerun(tx, opts)
+	}
+	return nil
+}
+
+// rerun runs tx again to see the instructions it executes and, with
+// StrictEncoding, to check its encoding.
+func (pol *Policy) rerun(tx *bc.Tx, opts []txvm.Option) error {
+	var ops []byte
+	opts = append(opts[:len(opts):len(opts)], txvm.BeforeStep(func(vm *txvm.VM) {
+		ops = append(ops, vm.OpCode())
+	}))
+	if pol.StrictEncoding {
+		opts = append(opts, txvm.StrictEncoding)
+	}
+	_, err := txvm.Validate(tx.Program, tx.Version, tx.Runlimit, opts...)
+	if errors.Root(err) == txvm.ErrEncoding {
+		return errors.WithDetail(ErrEncoding, err.Error())
+	}
+	if err != nil {
+		return errors.Wrap(err, "re-running transaction for policy check")
+	}
+	for _, banned := range pol.BannedOps {
+		if matchOps(ops, banned) {
+			return errors.WithDetailf(ErrBannedOp, "%s", opNames(banned))
+		}
+	}
+	return nil
+}
+
+func matchOps(ops, pattern []byte) bool {
+	if len(pattern) == 0 {
+		return false
+	}
+	for i := 0; i+len(pattern) <= len(ops); i++ {
+		match := true
+		for j, p := range pattern {
+			o := ops[i+j]
+			if p == op.MinPushdata && (op.IsPushdataOp(o) || op.IsSmallIntOp(o)) {
+				continue
+			}
+			if o != p {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func opNames(ops []byte) string {
+	var buf bytes.Buffer
+	for i, o := range ops {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		if o == op.MinPushdata {
+			buf.WriteString("pushdata")
+		} else {
+			buf.WriteString(op.Name(o))
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,59 @@
+package mempool
+
+import (
+	"sort"
+
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/fee"
+)
+
+// Prioritized returns the pending transactions in decreasing order
+// of the fee each pays in feeAsset per unit of runlimit (see package
+// fee), with ties in the order they were added. A transaction
+// spending an output of another pending transaction still comes
+// after it, so that all of them remain valid applied in the returned
+// order; the earlier transaction is moved up as needed.
+func (p *Pool) Prioritized(feeAsset bc.Hash) []*bc.CommitmentsTx {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(p.txs)
+	rates := make([]float64, n)
+	creator := make(map[bc.Hash]int) // output ID -> index of the tx creating it
+	parents := make([][]int, n)
+	for i, tx := range p.txs {
+		rates[i] = fee.Rate(tx.Tx, feeAsset)
+		for _, in := range tx.Tx.Inputs {
+			if j, ok := creator[in.ID]; ok {
+				parents[i] = append(parents[i], j)
+			}
+		}
+		for _, out := range tx.Tx.Outputs {
+			creator[out.ID] = i
+		}
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return rates[order[a]] > rates[order[b]] })
+
+	res := make([]*bc.CommitmentsTx, 0, n)
+	emitted := make([]bool, n)
+	var emit func(int)
+	emit = func(i int) {
+		if emitted[i] {
+			return
+		}
+		emitted[i] = true
+		for _, j := range parents[i] {
+			emit(j)
+		}
+		res = append(res, p.txs[i])
+	}
+	for _, i := range order {
+		emit(i)
+	}
+	return res
+}
@@ -0,0 +1,227 @@
+package mempool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+
+	"i10r.io/crypto/sha3"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+)
+
+// A Log is an append-only file of the transactions admitted to a
+// Pool (see Pool.SetLog), so that a node can restore its pending
+// transactions after a restart instead of dropping them. It begins
+// with a header (a magic string and a format version), followed by
+// one record per transaction:
+//
+//	uvarint(N) || TX || SHA3-256(TX)
+//
+// where TX is the N-byte encoding of the transaction's version,
+// runlimit, and program: varint(version) || varint(runlimit) ||
+// program.
+//
+// Transactions that leave the pool stay in the log until it is
+// compacted, when it grows to several times the size of the pool.
+// Its transactions are therefore to be validated again on restore,
+// against the state the node restarts with, as AddBatch does.
+//
+// A Log is safe for concurrent use.
+type Log struct {
+	name string
+
+	mu      sync.Mutex
+	f       *os.File
+	records int
+	err     error // set when l is closed
+}
+
+// LogVersion is the log format version written by Log.
+const LogVersion = 1
+
+var logMagic = []byte("txvmpool")
+
+// ErrLogFormat is returned by OpenLog for a file that is not a log.
+var ErrLogFormat = errors.New("invalid mempool log format")
+
+// OpenLog opens the log in the file name, creating it if it does not
+// exist, and returns it with the transactions it holds, in the order
+// they were logged. A record cut short or corrupted, as by a crash
+// while it was written, ends the log; it and anything after it are
+// discarded.
+func OpenLog(name string) (*Log, []*bc.RawTx, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	txs, end, err := readLog(f)
+	if err == nil && end == 0 {
+		end, err = f.Write(logHeader())
+	}
+	if err == nil {
+		err = f.Truncate(int64(end))
+	}
+	if err == nil {
+		_, err = f.Seek(int64(end), io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, errors.Wrapf(err, "opening mempool log %s", name)
+	}
+	return &Log{name: name, f: f, records: len(txs)}, txs, nil
+}
+
+func logHeader() []byte {
+	return append(append([]byte{}, logMagic...), LogVersion)
+}
+
+// readLog reads the transactions in the log file f, returning them
+// and the offset just after the last good record, or 0 if f is
+// empty.
+func readLog(f *os.File) (txs []*bc.RawTx, end int, err error) {
+	r := bufio.NewReader(f)
+	hdr := make([]byte, len(logMagic)+1)
+	n, err := io.ReadFull(r, hdr)
+	if n == 0 && err == io.EOF {
+		return nil, 0, nil
+	}
+	if err != nil || !bytes.Equal(hdr, logHeader()) {
+		return nil, 0, errors.WithDetailf(ErrLogFormat, "bad header %x", hdr[:n])
+	}
+	end = len(hdr)
+	for {
+		tx, n, ok := readRecord(r)
+		if !ok {
+			return txs, end, nil
+		}
+		txs = append(txs, tx)
+		end += n
+	}
+}
+
+// maxRecordSize bounds the records readRecord accepts, so that a
+// corrupt length cannot make it allocate without limit.
+const maxRecordSize = 64 << 20
+
+// readRecord reads a record, returning the transaction, the length of
+// the record, and whether it was whole and intact.
+func readRecord(r *bufio.Reader) (*bc.RawTx, int, bool) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil || size > maxRecordSize {
+		return nil, 0, false
+	}
+	rec := make([]byte, size+32)
+	if _, err := io.ReadFull(r, rec); err != nil {
+		return nil, 0, false
+	}
+	bits, sum := rec[:size], rec[size:]
+	if want := sha3.Sum256(bits); !bytes.Equal(sum, want[:]) {
+		return nil, 0, false
+	}
+	version, n := binary.Varint(bits)
+	if n <= 0 {
+		return nil, 0, false
+	}
+	bits = bits[n:]
+	runlimit, n := binary.Varint(bits)
+	if n <= 0 {
+		return nil, 0, false
+	}
+	tx := &bc.RawTx{Version: version, Runlimit: runlimit, Program: bits[n:]}
+	var lenbuf [binary.MaxVarintLen64]byte
+	return tx, binary.PutUvarint(lenbuf[:], size) + len(rec), true
+}
+
+func appendRecord(buf *bytes.Buffer, tx *bc.Tx) {
+	var (
+		body   []byte
+		varbuf [binary.MaxVarintLen64]byte
+	)
+	body = append(body, varbuf[:binary.PutVarint(varbuf[:], tx.Version)]...)
+	body = append(body, varbuf[:binary.PutVarint(varbuf[:], tx.Runlimit)]...)
+	body = append(body, tx.Program...)
+	buf.Write(varbuf[:binary.PutUvarint(varbuf[:], uint64(len(body)))])
+	buf.Write(body)
+	sum := sha3.Sum256(body)
+	buf.Write(sum[:])
+}
+
+// Append adds tx to the end of l, returning once it is on disk.
+func (l *Log) Append(tx *bc.Tx) error {
+	var buf bytes.Buffer
+	appendRecord(&buf, tx)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	_, err := l.f.Write(buf.Bytes())
+	if err == nil {
+		err = l.f.Sync()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "writing mempool log %s", l.name)
+	}
+	l.records++
+	return nil
+}
+
+// Len returns the number of transactions in l.
+func (l *Log) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.records
+}
+
+// Rewrite replaces the contents of l with txs, atomically: if it
+// fails, l is as it was.
+func (l *Log) Rewrite(txs []*bc.Tx) error {
+	var buf bytes.Buffer
+	buf.Write(logHeader())
+	for _, tx := range txs {
+		appendRecord(&buf, tx)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return l.err
+	}
+	tmp := l.name + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "compacting mempool log %s", l.name)
+	}
+	_, err = f.Write(buf.Bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, l.name)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return errors.Wrapf(err, "compacting mempool log %s", l.name)
+	}
+	// f, open for writing at its end, is now the log.
+	l.f.Close()
+	l.f = f
+	l.records = len(txs)
+	return nil
+}
+
+// Close closes l.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		l.err = errors.New("mempool log closed")
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,76 @@
+package merkle
+
+// Accumulator computes a Merkle root incrementally, for callers that
+// see leaves one at a time and would rather not keep them all. After
+// any sequence of calls to Add, Root returns the same value as Root
+// applied to the leaves added so far.
+//
+// It holds the roots of the complete subtrees that make up the tree
+// so far, one per 1 bit in the leaf count, so it uses space
+// logarithmic in the number of leaves.
+//
+// The zero value is an empty Accumulator. It is not safe for
+// concurrent use.
+type Accumulator struct {
+	n      int
+	stack  [][32]byte  // complete subtree roots, largest (leftmost) first
+	latest []AuditHash // path from the latest leaf to the top of its subtree
+}
+
+// Len returns the number of leaves added to a.
+func (a *Accumulator) Len() int {
+	return a.n
+}
+
+// Add appends leaf to the tree.
+func (a *Accumulator) Add(leaf []byte) {
+	var out [1][32]byte
+	SerialHasher{}.HashLeaves(out[:], [][]byte{leaf})
+	node := out[0]
+
+	a.latest = a.latest[:0]
+	// Each trailing 1 bit in the old count is a complete subtree of
+	// the same size as the one being carried, to be merged with it.
+	for m := a.n; m&1 == 1; m >>= 1 {
+		left := a.stack[len(a.stack)-1]
+		a.stack = a.stack[:len(a.stack)-1]
+		a.latest = append(a.latest, AuditHash{Val: left})
+		node = hashInterior(left, node)
+	}
+	a.stack = append(a.stack, node)
+	a.n++
+}
+
+// Root returns the root hash of the tree of leaves added so far.
+func (a *Accumulator) Root() [32]byte {
+	if a.n == 0 {
+		return emptyStringHash
+	}
+	root := a.stack[len(a.stack)-1]
+	for i := len(a.stack) - 2; i >= 0; i-- {
+		root = hashInterior(a.stack[i], root)
+	}
+	return root
+}
+
+// ProveLatest returns the proof for the most recently added leaf, as
+// Proof would for the last item. It returns nil if a is empty.
+func (a *Accumulator) ProveLatest() []AuditHash {
+	if a.n == 0 {
+		return nil
+	}
+	// The latest leaf is always in the rightmost subtree, and every
+	// sibling on its path is to its left.
+	proof := make([]AuditHash, 0, len(a.latest)+len(a.stack)-1)
+	proof = append(proof, a.latest...)
+	for i := len(a.stack) - 2; i >= 0; i-- {
+		proof = append(proof, AuditHash{Val: a.stack[i]})
+	}
+	return proof
+}
+
+func hashInterior(left, right [32]byte) [32]byte {
+	var out [1][32]byte
+	SerialHasher{}.HashInteriors(out[:], [][32]byte{left, right})
+	return out[0]
+}
@@ -0,0 +1,35 @@
+package merkle
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestAccumulator(t *testing.T) {
+	var (
+		a     Accumulator
+		items [][]byte
+	)
+	if got := a.Root(); got != emptyStringHash {
+		t.Errorf("empty root = %x, want %x", got[:], emptyStringHash[:])
+	}
+	for n := 1; n <= 300; n++ {
+		item := []byte(fmt.Sprint(n))
+		items = append(items, item)
+		a.Add(item)
+		if a.Len() != n {
+			t.Fatalf("Len() = %d, want %d", a.Len(), n)
+		}
+		if got, want := a.Root(), Root(items); got != want {
+			t.Errorf("root of %d items = %x, want %x", n, got[:], want[:])
+		}
+		want, err := Proof(items, n-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.ProveLatest(); !reflect.DeepEqual(got, want) {
+			t.Errorf("proof of item %d = %v, want %v", n-1, got, want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package merkle
+
+import (
+	"runtime"
+	"sync"
+
+	"i10r.io/crypto/sha3pool"
+)
+
+// BatchHasher computes the hashes of many tree nodes per call, so
+// that an implementation can spread them across SIMD lanes or CPU
+// cores. Root computes a tree one level at a time, handing each
+// level to a BatchHasher.
+type BatchHasher interface {
+	// HashLeaves sets out[i] to the hash of the leaf leaves[i].
+	// len(out) == len(leaves).
+	HashLeaves(out [][32]byte, leaves [][]byte)
+
+	// HashInteriors sets out[i] to the hash of the interior node
+	// whose children are in[2i] and in[2i+1].
+	// len(in) == 2*len(out).
+	HashInteriors(out, in [][32]byte)
+}
+
+// Hasher is the BatchHasher used by Root. A program may replace it
+// with an accelerated implementation during initialization; it must
+// not be changed while Root may be running.
+var Hasher BatchHasher = ParallelHasher{MinBatch: 256}
+
+// SerialHasher is a BatchHasher that hashes one node at a time.
+type SerialHasher struct{}
+
+// HashLeaves implements BatchHasher.
+func (SerialHasher) HashLeaves(out [][32]byte, leaves [][]byte) {
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	for i, leaf := range leaves {
+		h.Reset()
+		h.Write(leafPrefix)
+		h.Write(leaf)
+		h.Read(out[i][:])
+	}
+}
+
+// HashInteriors implements BatchHasher.
+func (SerialHasher) HashInteriors(out, in [][32]byte) {
+	h := sha3pool.Get256()
+	defer sha3pool.Put256(h)
+	for i := range out {
+		h.Reset()
+		h.Write(interiorPrefix)
+		h.Write(in[2*i][:])
+		h.Write(in[2*i+1][:])
+		h.Read(out[i][:])
+	}
+}
+
+// ParallelHasher is a BatchHasher that divides large batches among
+// goroutines, each hashing its share serially.
+type ParallelHasher struct {
+	// MinBatch is the smallest batch worth dividing. Smaller
+	// batches are hashed on the calling goroutine.
+	MinBatch int
+
+	// Workers is the number of goroutines to use. If it is zero,
+	// runtime.GOMAXPROCS(0) is used.
+	Workers int
+}
+
+// HashLeaves implements BatchHasher.
+func (p ParallelHasher) HashLeaves(out [][32]byte, leaves [][]byte) {
+	p.run(len(out), func(lo, hi int) {
+		SerialHasher{}.HashLeaves(out[lo:hi], leaves[lo:hi])
+	})
+}
+
+// HashInteriors implements BatchHasher.
+func (p ParallelHasher) HashInteriors(out, in [][32]byte) {
+	p.run(len(out), func(lo, hi int) {
+		SerialHasher{}.HashInteriors(out[lo:hi], in[2*lo:2*hi])
+	})
+}
+
+func (p ParallelHasher) run(n int, f func(lo, hi int)) {
+	workers := p.Workers
+	if workers == 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if n < p.MinBatch || workers < 2 {
+		f(0, n)
+		return
+	}
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += chunk {
+		hi := lo + chunk
+		if hi > n {
+			hi = n
+		}
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			f(lo, hi)
+		}(lo, hi)
+	}
+	wg.Wait()
+}
+
+// RootWith computes the same value as Root, using h to hash the
+// nodes of each level of the tree.
+//
+// Working upward from the leaves, each level pairs adjacent nodes; an
+// unpaired last node is carried up unchanged. This yields the same
+// tree as Root's definition, whose left subtree is always the largest
+// complete tree that fits.
+func RootWith(h BatchHasher, items [][]byte) [32]byte {
+	if len(items) == 0 {
+		return emptyStringHash
+	}
+	level := make([][32]byte, len(items))
+	h.HashLeaves(level, items)
+	for len(level) > 1 {
+		pairs := len(level) / 2
+		next := make([][32]byte, pairs, pairs+1)
+		h.HashInteriors(next, level[:2*pairs])
+		if len(level)%2 == 1 {
+			next = append(next, level[len(level)-1])
+		}
+		level = next
+	}
+	return level[0]
+}
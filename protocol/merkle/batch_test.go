@@ -0,0 +1,74 @@
+package merkle
+
+import (
+	"fmt"
+	"testing"
+
+	"i10r.io/crypto/sha3pool"
+)
+
+// rootRecursive is the original definition of Root.
+func rootRecursive(items [][]byte) [32]byte {
+	var root [32]byte
+	switch len(items) {
+	case 0:
+		return emptyStringHash
+	case 1:
+		h := sha3pool.Get256()
+		defer sha3pool.Put256(h)
+		h.Write(leafPrefix)
+		h.Write(items[0])
+		h.Read(root[:])
+	default:
+		k := prevPowerOfTwo(len(items))
+		left := rootRecursive(items[:k])
+		right := rootRecursive(items[k:])
+		h := sha3pool.Get256()
+		defer sha3pool.Put256(h)
+		h.Write(interiorPrefix)
+		h.Write(left[:])
+		h.Write(right[:])
+		h.Read(root[:])
+	}
+	return root
+}
+
+func TestRootWith(t *testing.T) {
+	hashers := []BatchHasher{
+		SerialHasher{},
+		ParallelHasher{MinBatch: 1, Workers: 3},
+	}
+	var items [][]byte
+	for n := 0; n <= 300; n++ {
+		want := rootRecursive(items)
+		for _, h := range hashers {
+			if got := RootWith(h, items); got != want {
+				t.Errorf("%T: root of %d items = %x, want %x", h, n, got[:], want[:])
+			}
+		}
+		items = append(items, []byte(fmt.Sprint(n)))
+	}
+}
+
+func BenchmarkRoot(b *testing.B) {
+	items := make([][]byte, 10000)
+	for i := range items {
+		items[i] = make([]byte, 200)
+		items[i][0], items[i][1] = byte(i), byte(i>>8)
+	}
+	b.Run("recursive", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			rootRecursive(items)
+		}
+	})
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			RootWith(SerialHasher{}, items)
+		}
+	})
+	b.Run("parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			RootWith(Hasher, items)
+		}
+	})
+}
@@ -6,7 +6,6 @@ import (
 	"math"
 
 	"i10r.io/crypto/sha3"
-	"i10r.io/crypto/sha3pool"
 )
 
 var (
@@ -54,35 +53,7 @@ func Proof(items [][]byte, i int) ([]AuditHash, error) {
 // Root creates a merkle tree from a slice of byte slices
 // and returns the root hash of the tree.
 func Root(items [][]byte) [32]byte {
-	switch len(items) {
-	case 0:
-		return emptyStringHash
-
-	case 1:
-		h := sha3pool.Get256()
-		defer sha3pool.Put256(h)
-
-		h.Write(leafPrefix)
-		h.Write(items[0])
-		var root [32]byte
-		h.Read(root[:])
-		return root
-
-	default:
-		k := prevPowerOfTwo(len(items))
-		left := Root(items[:k])
-		right := Root(items[k:])
-
-		h := sha3pool.Get256()
-		defer sha3pool.Put256(h)
-		h.Write(interiorPrefix)
-		h.Write(left[:])
-		h.Write(right[:])
-
-		var root [32]byte
-		h.Read(root[:])
-		return root
-	}
+	return RootWith(Hasher, items)
 }
 
 // prevPowerOfTwo returns the largest power of two that is smaller than a given number.
@@ -0,0 +1,255 @@
+/*
+Package netparams is a registry of networks, by name, bundling the
+settings that must agree with the network a node, wallet, or tool is
+meant for: its initial block ID, its address prefix, its default API
+port, and the version of the transactions its blocks accept.
+
+Selecting a network by name, with a configuration field or command
+flag or else the environment variable TXVMNETWORK, sets all of them
+at once, and lets the programs refuse what belongs to another
+network: a node refuses an initial block or a peer of another
+network, and a wallet an address or a node of another network.
+
+Mainnet, Testnet, and Devnet are registered here. Mainnet and
+Testnet have no initial block fixed in this build; a deployment
+registers its networks, initial block included, with Register, or
+loads them from a JSON file (see Load) such as the one named by the
+environment variable TXVMNETWORKS, which the command-line tools read.
+*/
+package netparams
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/address"
+	"i10r.io/protocol/txvm"
+)
+
+// Environment variables read by the command-line tools.
+const (
+	// EnvNetwork names the selected network (see Select).
+	EnvNetwork = "TXVMNETWORK"
+
+	// EnvFile names a file of networks for LoadEnv.
+	EnvFile = "TXVMNETWORKS"
+)
+
+var (
+	// ErrUnknown is returned for a network name that is not
+	// registered.
+	ErrUnknown = errors.New("unknown network")
+
+	// ErrWrongNetwork is returned for a block, peer, node, or
+	// address belonging to a network other than the selected one.
+	ErrWrongNetwork = errors.New("wrong network")
+)
+
+// Params describe a network.
+type Params struct {
+	Name string `json:"name"`
+
+	// InitialBlockID is the ID of the network's initial block. It
+	// is zero for a network with no fixed initial block, such as a
+	// devnet, each instance of which makes its own.
+	InitialBlockID bc.Hash `json:"initial_block_id"`
+
+	// HRP is the human-readable part of the network's addresses
+	// (see package address).
+	HRP string `json:"hrp"`
+
+	// Port is the default port of a node's HTTP API.
+	Port int `json:"port"`
+
+	// TxVersion is the version of the transactions the network's
+	// blocks accept, which selects the instructions available to
+	// them and what they cost (see txvm.Validate). From
+	// txvm.NetworkVersion, transactions are bound to the network's
+	// initial block (see bc.NetworkVersion).
+	TxVersion int64 `json:"tx_version"`
+}
+
+// The networks registered by this package.
+var (
+	Mainnet = &Params{Name: "mainnet", HRP: address.Mainnet, Port: 1999, TxVersion: txvm.NetworkVersion}
+	Testnet = &Params{Name: "testnet", HRP: address.Testnet, Port: 2999, TxVersion: txvm.NetworkVersion}
+	Devnet  = &Params{Name: "devnet", HRP: address.Devnet, Port: 3999, TxVersion: 3}
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]*Params)
+)
+
+func init() {
+	Register(Mainnet)
+	Register(Testnet)
+	Register(Devnet)
+}
+
+// Register adds p to the registry. It panics if p is invalid or a
+// network with the same name is already registered.
+func Register(p *Params) {
+	if err := p.check(); err != nil {
+		panic("netparams: " + err.Error())
+	}
+	if !register(p) {
+		panic("netparams: network " + p.Name + " registered twice")
+	}
+}
+
+func register(p *Params) bool {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[p.Name]; ok {
+		return false
+	}
+	registry[p.Name] = p
+	return true
+}
+
+func (p *Params) check() error {
+	switch {
+	case p.Name == "":
+		return errors.New("network has no name")
+	case p.HRP == "":
+		return fmt.Errorf("network %s has no address prefix", p.Name)
+	case p.Port <= 0 || p.Port > 65535:
+		return fmt.Errorf("network %s has port %d", p.Name, p.Port)
+	case p.TxVersion < 3 || p.TxVersion > txvm.NetworkVersion:
+		return fmt.Errorf("network %s has transaction version %d", p.Name, p.TxVersion)
+	}
+	return nil
+}
+
+// Lookup returns the network registered with name.
+func Lookup(name string) (*Params, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[name]
+	if !ok {
+		return nil, errors.WithDetail(ErrUnknown, name)
+	}
+	return p, nil
+}
+
+// All returns the registered networks, ordered by name.
+func All() []*Params {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	var all []*Params
+	for _, p := range registry {
+		all = append(all, p)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Select returns the network named name or, if name is empty, the
+// one named by the environment variable EnvNetwork. It returns nil
+// if both are empty: no network is selected, and programs keep
+// their own defaults.
+func Select(name string) (*Params, error) {
+	if name == "" {
+		name = os.Getenv(EnvNetwork)
+	}
+	if name == "" {
+		return nil, nil
+	}
+	return Lookup(name)
+}
+
+// Load registers the networks in r, a JSON array of Params objects.
+func Load(r io.Reader) error {
+	var ps []*Params
+	err := json.NewDecoder(r).Decode(&ps)
+	if err != nil {
+		return errors.Wrap(err, "decoding networks")
+	}
+	for _, p := range ps {
+		if err := p.check(); err != nil {
+			return err
+		}
+		if !register(p) {
+			return errors.Wrapf(errors.New("name already registered"), "network %s", p.Name)
+		}
+	}
+	return nil
+}
+
+// LoadEnv loads the file named by the environment variable EnvFile,
+// if it is set.
+func LoadEnv() error {
+	name := os.Getenv(EnvFile)
+	if name == "" {
+		return nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return errors.Wrap(Load(f), name)
+}
+
+// Listen returns the default address for a node's HTTP API on p.
+func (p *Params) Listen() string {
+	return fmt.Sprintf("127.0.0.1:%d", p.Port)
+}
+
+// NodeURL returns the default URL of a node on p.
+func (p *Params) NodeURL() string {
+	return "http://" + p.Listen()
+}
+
+// BlockVersion returns the least block version that accepts
+// transactions of version p.TxVersion (see validation.BlockOnly).
+func (p *Params) BlockVersion() uint64 {
+	switch {
+	case p.TxVersion >= txvm.NetworkVersion:
+		return bc.NetworkVersion
+	case p.TxVersion > 3:
+		return bc.CommitmentsVersion
+	}
+	return 3
+}
+
+// CheckInitialBlock returns ErrWrongNetwork if p has a fixed initial
+// block and its ID is not id.
+func (p *Params) CheckInitialBlock(id bc.Hash) error {
+	if p.InitialBlockID.IsZero() || p.InitialBlockID == id {
+		return nil
+	}
+	return errors.WithDetailf(ErrWrongNetwork, "initial block %x, %s has %x", id.Bytes(), p.Name, p.InitialBlockID.Bytes())
+}
+
+// CheckName returns ErrWrongNetwork if name, the network a peer or
+// node reports, is not p's.
+func (p *Params) CheckName(name string) error {
+	if name == p.Name {
+		return nil
+	}
+	if name == "" {
+		name = "no network"
+	}
+	return errors.WithDetailf(ErrWrongNetwork, "%s, want %s", name, p.Name)
+}
+
+// ParseAddress parses an address, returning ErrWrongNetwork if it
+// is for another network.
+func (p *Params) ParseAddress(s string) (*address.Address, error) {
+	a, err := address.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if a.HRP != p.HRP {
+		return nil, errors.WithDetailf(ErrWrongNetwork, "address prefix %q, %s has %q", a.HRP, p.Name, p.HRP)
+	}
+	return a, nil
+}
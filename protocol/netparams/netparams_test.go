@@ -0,0 +1,88 @@
+package netparams
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/address"
+	"i10r.io/testutil"
+)
+
+func TestSelect(t *testing.T) {
+	defer os.Setenv(EnvNetwork, os.Getenv(EnvNetwork))
+
+	os.Setenv(EnvNetwork, "")
+	if p, err := Select(""); p != nil || err != nil {
+		t.Errorf(`Select("") with no environment = %v, %v, want nil, nil`, p, err)
+	}
+	os.Setenv(EnvNetwork, "testnet")
+	if p, err := Select(""); p != Testnet || err != nil {
+		t.Errorf(`Select("") = %v, %v, want testnet`, p, err)
+	}
+	if p, err := Select("devnet"); p != Devnet || err != nil {
+		t.Errorf(`Select("devnet") = %v, %v, want devnet`, p, err)
+	}
+	if _, err := Select("moonnet"); errors.Root(err) != ErrUnknown {
+		t.Errorf(`Select("moonnet"): got error %v, want %v`, err, ErrUnknown)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	var id bc.Hash
+	err := id.UnmarshalText([]byte(strings.Repeat("07", 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := `[{"name": "loadnet", "initial_block_id": "` + strings.Repeat("07", 32) + `", "hrp": "ltxvm", "port": 4999, "tx_version": 5}]`
+	err = Load(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Lookup("loadnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.InitialBlockID != id || p.NodeURL() != "http://127.0.0.1:4999" || p.BlockVersion() != bc.NetworkVersion {
+		t.Errorf("loaded %+v", p)
+	}
+	if err := p.CheckInitialBlock(id); err != nil {
+		t.Error(err)
+	}
+	if err := p.CheckInitialBlock(bc.Hash{}); errors.Root(err) != ErrWrongNetwork {
+		t.Errorf("CheckInitialBlock(other): got error %v, want %v", err, ErrWrongNetwork)
+	}
+	if err := Devnet.CheckInitialBlock(id); err != nil {
+		t.Errorf("devnet: %v", err)
+	}
+
+	for _, src := range []string{
+		src,
+		`[{"name": "nohrp", "port": 4999, "tx_version": 3}]`,
+		`[{"name": "badversion", "hrp": "b", "port": 4999, "tx_version": 9}]`,
+	} {
+		if err := Load(strings.NewReader(src)); err == nil {
+			t.Errorf("Load(%s): no error", src)
+		}
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	a := &address.Address{HRP: address.Testnet, Quorum: 1, Pubkeys: []ed25519.PublicKey{testutil.TestPub}}
+	s, err := a.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Testnet.ParseAddress(s); err != nil {
+		t.Error(err)
+	}
+	if _, err := Mainnet.ParseAddress(s); errors.Root(err) != ErrWrongNetwork {
+		t.Errorf("mainnet parsing a testnet address: got error %v, want %v", err, ErrWrongNetwork)
+	}
+	if err := Mainnet.CheckName("testnet"); errors.Root(err) != ErrWrongNetwork {
+		t.Errorf("CheckName: got error %v, want %v", err, ErrWrongNetwork)
+	}
+}
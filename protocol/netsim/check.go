@@ -0,0 +1,109 @@
+package netsim
+
+import (
+	"fmt"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+)
+
+// Errors returned by Check and Settle.
+var (
+	// ErrFork means two nodes committed different blocks at the
+	// same height.
+	ErrFork = errors.New("nodes disagree on a block")
+
+	// ErrDoubleSpend means a chain consumed the same output or
+	// nonce twice.
+	ErrDoubleSpend = errors.New("output or nonce consumed twice")
+
+	// ErrDiverged means the nodes did not converge on the
+	// generator's chain.
+	ErrDiverged = errors.New("nodes did not converge")
+)
+
+// Check checks the invariants that hold however the network
+// behaves: that the nodes agree on every block they have in common,
+// and that no chain consumes an output or a nonce twice, or creates
+// an output twice.
+func (s *Sim) Check() error {
+	longest := s.nodes[0]
+	for _, n := range s.nodes[1:] {
+		if n.Chain.Height() > longest.Chain.Height() {
+			longest = n
+		}
+	}
+	for h := uint64(1); h <= longest.Chain.Height(); h++ {
+		want, err := longest.blockHash(h)
+		if err != nil {
+			return err
+		}
+		for _, n := range s.nodes {
+			if n.Chain.Height() < h {
+				continue
+			}
+			got, err := n.blockHash(h)
+			if err != nil {
+				return err
+			}
+			if got != want {
+				return errors.WithDetailf(ErrFork, "height %d: node %d has %x, node %d has %x", h, n.index, got.Bytes(), longest.index, want.Bytes())
+			}
+		}
+	}
+
+	// The nodes agree, so checking the longest chain checks them
+	// all.
+	var (
+		consumed = make(map[bc.Hash]uint64) // height, by contract or nonce ID
+		created  = make(map[bc.Hash]uint64)
+	)
+	for h := uint64(2); h <= longest.Chain.Height(); h++ {
+		b, err := longest.Chain.GetBlock(s.ctx, h)
+		if err != nil {
+			return err
+		}
+		for _, tx := range b.Transactions {
+			ids := make([]bc.Hash, 0, len(tx.Inputs)+len(tx.Nonces))
+			for _, in := range tx.Inputs {
+				ids = append(ids, in.ID)
+			}
+			for _, nonce := range tx.Nonces {
+				ids = append(ids, nonce.ID)
+			}
+			for _, id := range ids {
+				if prev, ok := consumed[id]; ok {
+					return errors.WithDetailf(ErrDoubleSpend, "%x consumed at heights %d and %d", id.Bytes(), prev, h)
+				}
+				consumed[id] = h
+			}
+			for _, out := range tx.Outputs {
+				if prev, ok := created[out.ID]; ok {
+					return errors.WithDetailf(ErrDoubleSpend, "output %x created at heights %d and %d", out.ID.Bytes(), prev, h)
+				}
+				created[out.ID] = h
+			}
+		}
+	}
+	return nil
+}
+
+// converged reports whether every node has the generator's latest
+// block.
+func (s *Sim) converged() bool {
+	tip := s.nodes[0].Chain.Height()
+	for _, n := range s.nodes[1:] {
+		if n.Chain.Height() != tip {
+			return false
+		}
+	}
+	return s.Check() == nil
+}
+
+func (s *Sim) heights() string {
+	var hs []uint64
+	for _, n := range s.nodes {
+		hs = append(hs, n.Chain.Height())
+	}
+	return fmt.Sprint(hs)
+}
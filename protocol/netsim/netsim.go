@@ -0,0 +1,327 @@
+// Package netsim simulates a network of nodes in a single process,
+// to test the protocol beyond the scope of a unit test: how
+// transactions spread, how blocks are proposed, signed, and
+// followed, and what becomes of them when messages are slow, lost,
+// or cut off by a partition.
+//
+// Each node of a Sim has its own chain and mempool. Node 0 is the
+// generator: once per block interval it builds a block from its
+// pool and proposes it to the signers, nodes 0 through Signers-1,
+// each of which checks it against its own chain and signs it, and it
+// commits and announces the block once a quorum has signed. Every
+// node relays the transactions it admits and the blocks it commits
+// to the others, and fetches the blocks it is missing from the node
+// that announced a later one. A workload of random transactions,
+// from package txgen, goes to random nodes, and since each is made
+// against the chain of the node it goes to, some spend outputs that
+// another node's pending transactions already spend.
+//
+// Time is simulated: a Sim runs events in order of their simulated
+// times and never waits, and everything it picks at random it picks
+// with a generator seeded from Config.Seed, so a run is the same
+// every time. Check tests the invariants that must hold however the
+// network behaves, and Settle the eventual consistency of the nodes
+// once it behaves well again.
+package netsim
+
+import (
+	"container/heap"
+	"context"
+	"math/rand"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txgen"
+)
+
+// Start is the simulated time at which a Sim starts, and the
+// timestamp of its initial block.
+var Start = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Config configures a Sim.
+type Config struct {
+	// Nodes is the number of nodes.
+	Nodes int
+
+	// Signers is the number of nodes, starting at node 0, holding
+	// block-signing keys, and Quorum the number of them that must
+	// sign each block. Zero values mean 1.
+	Signers, Quorum int
+
+	// BlockInterval is how often the generator proposes a block
+	// (default 1s), and TxInterval how often the workload submits a
+	// transaction (if 0, it submits none).
+	BlockInterval time.Duration
+	TxInterval    time.Duration
+
+	// Each message takes between MinLatency and MaxLatency to
+	// arrive, if it is not among the fraction DropRate lost.
+	MinLatency, MaxLatency time.Duration
+	DropRate               float64
+
+	// MaxPoolTxs bounds each node's mempool (see mempool.New).
+	MaxPoolTxs int
+
+	// Seed determines the keys, the workload, and the network's
+	// random choices.
+	Seed int64
+}
+
+// Stats are counts of a Sim's events.
+type Stats struct {
+	Blocks      int // committed by the generator
+	Submitted   int // workload transactions
+	Accepted    int // ...admitted by the node they went to
+	Rejected    int // ...refused, most because of a conflict
+	Sent        int // messages
+	Dropped     int // ...lost to DropRate or a partition
+	Proposals   int // blocks proposed to the signers, including repeats
+	Refusals    int // proposals a signer refused to sign
+	BadMessages int // blocks or transactions a node could not accept
+}
+
+// Sim is a simulated network. It is not safe for concurrent use.
+type Sim struct {
+	cfg   Config
+	ctx   context.Context
+	now   time.Time
+	rand  *rand.Rand
+	gen   *txgen.Generator
+	nodes []*Node
+	keys  []ed25519.PrivateKey // block-signing keys, by signer
+
+	events events
+	seq    uint64
+
+	partition map[int]int // group of each node; nil if none
+	stats     Stats
+}
+
+// New returns a Sim with cfg's nodes, each with the same initial
+// block committed. Its first block comes one interval later.
+func New(ctx context.Context, cfg Config) (*Sim, error) {
+	if cfg.Nodes <= 0 {
+		return nil, errors.New("no nodes")
+	}
+	if cfg.Signers <= 0 {
+		cfg.Signers = 1
+	}
+	if cfg.Quorum <= 0 {
+		cfg.Quorum = 1
+	}
+	if cfg.Signers > cfg.Nodes || cfg.Quorum > cfg.Signers {
+		return nil, errors.New("bad signer configuration")
+	}
+	if cfg.BlockInterval <= 0 {
+		cfg.BlockInterval = time.Second
+	}
+	if cfg.MaxLatency < cfg.MinLatency {
+		cfg.MaxLatency = cfg.MinLatency
+	}
+	s := &Sim{
+		cfg:  cfg,
+		ctx:  ctx,
+		now:  Start,
+		rand: rand.New(rand.NewSource(cfg.Seed)),
+		gen:  txgen.New(cfg.Seed),
+	}
+	var pubs []ed25519.PublicKey
+	for i := 0; i < cfg.Signers; i++ {
+		pub, prv, err := ed25519.GenerateKey(s.rand)
+		if err != nil {
+			return nil, err
+		}
+		pubs = append(pubs, pub)
+		s.keys = append(s.keys, prv)
+	}
+	initial, err := protocol.NewInitialBlock(pubs, cfg.Quorum, Start)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < cfg.Nodes; i++ {
+		n, err := newNode(s, i, initial)
+		if err != nil {
+			return nil, errors.Wrapf(err, "node %d", i)
+		}
+		s.nodes = append(s.nodes, n)
+	}
+
+	s.after(cfg.BlockInterval, s.nodes[0].propose)
+	if cfg.TxInterval > 0 {
+		s.after(cfg.TxInterval, s.submit)
+	}
+	return s, nil
+}
+
+// Now returns the simulated time.
+func (s *Sim) Now() time.Time {
+	return s.now
+}
+
+// Node returns node i.
+func (s *Sim) Node(i int) *Node {
+	return s.nodes[i]
+}
+
+// Nodes returns the number of nodes.
+func (s *Sim) Nodes() int {
+	return len(s.nodes)
+}
+
+// Stats returns counts of s's events so far.
+func (s *Sim) Stats() Stats {
+	return s.stats
+}
+
+// Run runs the events of the next d of simulated time.
+func (s *Sim) Run(d time.Duration) error {
+	end := s.now.Add(d)
+	for len(s.events) > 0 && !s.events[0].at.After(end) {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		ev := heap.Pop(&s.events).(*event)
+		s.now = ev.at
+		ev.f()
+	}
+	s.now = end
+	return nil
+}
+
+// Settle runs s until every node has the generator's latest block
+// and a block has come since, so that each has had the chance to
+// catch up, or until max has passed, returning an error in that
+// case. Call it after Heal to check that the nodes converge.
+func (s *Sim) Settle(max time.Duration) error {
+	end := s.now.Add(max)
+	for s.now.Before(end) {
+		start := s.nodes[0].Chain.Height()
+		err := s.Run(s.cfg.BlockInterval)
+		if err != nil {
+			return err
+		}
+		if s.nodes[0].Chain.Height() > start && s.converged() {
+			return nil
+		}
+	}
+	return errors.WithDetailf(ErrDiverged, "not converged after %s: heights %v", max, s.heights())
+}
+
+// Partition splits the network into the given groups of nodes, with
+// no messages passing between groups, until Heal. A node in no group
+// is cut off from all the others. A message already on its way is
+// lost if it would cross the partition when it arrives.
+func (s *Sim) Partition(groups ...[]int) {
+	s.partition = make(map[int]int)
+	for g, nodes := range groups {
+		for _, n := range nodes {
+			s.partition[n] = g
+		}
+	}
+}
+
+// Heal removes the partition.
+func (s *Sim) Heal() {
+	s.partition = nil
+}
+
+// connected reports whether a message can pass from node a to node
+// b.
+func (s *Sim) connected(a, b int) bool {
+	if s.partition == nil {
+		return true
+	}
+	ga, oka := s.partition[a]
+	gb, okb := s.partition[b]
+	return oka && okb && ga == gb
+}
+
+// send sends msg from node from to node to, to be handled after a
+// random latency, unless it is lost on the way.
+func (s *Sim) send(from, to int, msg interface{}) {
+	s.stats.Sent++
+	if s.cfg.DropRate > 0 && s.rand.Float64() < s.cfg.DropRate {
+		s.stats.Dropped++
+		return
+	}
+	latency := s.cfg.MinLatency
+	if d := s.cfg.MaxLatency - s.cfg.MinLatency; d > 0 {
+		latency += time.Duration(s.rand.Int63n(int64(d)))
+	}
+	s.after(latency, func() {
+		if !s.connected(from, to) {
+			s.stats.Dropped++
+			return
+		}
+		s.nodes[to].handle(from, msg)
+	})
+}
+
+// broadcast sends msg from node from to every other node except
+// skip.
+func (s *Sim) broadcast(from, skip int, msg interface{}) {
+	for to := range s.nodes {
+		if to != from && to != skip {
+			s.send(from, to, msg)
+		}
+	}
+}
+
+// submit submits a workload transaction to a random node, and
+// schedules the next.
+func (s *Sim) submit() {
+	s.after(s.cfg.TxInterval, s.submit)
+	n := s.nodes[s.rand.Intn(len(s.nodes))]
+	tx, err := s.gen.Tx(n.Chain.State(), s.now)
+	if err != nil {
+		return // nothing to spend on this node yet
+	}
+	s.stats.Submitted++
+	if n.addTx(tx, -1) {
+		s.stats.Accepted++
+	} else {
+		s.stats.Rejected++
+	}
+}
+
+// after schedules f to run after d.
+func (s *Sim) after(d time.Duration, f func()) {
+	heap.Push(&s.events, &event{at: s.now.Add(d), seq: s.seq, f: f})
+	s.seq++
+}
+
+type event struct {
+	at  time.Time
+	seq uint64 // breaks ties in order of scheduling
+	f   func()
+}
+
+type events []*event
+
+func (e events) Len() int { return len(e) }
+func (e events) Less(i, j int) bool {
+	if !e[i].at.Equal(e[j].at) {
+		return e[i].at.Before(e[j].at)
+	}
+	return e[i].seq < e[j].seq
+}
+func (e events) Swap(i, j int)       { e[i], e[j] = e[j], e[i] }
+func (e *events) Push(x interface{}) { *e = append(*e, x.(*event)) }
+func (e *events) Pop() interface{} {
+	old := *e
+	ev := old[len(old)-1]
+	*e = old[:len(old)-1]
+	return ev
+}
+
+// blockBytes returns b's serialization, as it goes over the network.
+func blockBytes(b *bc.Block) []byte {
+	bits, err := b.Bytes()
+	if err != nil {
+		panic(err) // can't happen for a block this process built
+	}
+	return bits
+}
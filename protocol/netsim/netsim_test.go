@@ -0,0 +1,96 @@
+package netsim
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"i10r.io/errors"
+)
+
+func TestSim(t *testing.T) {
+	s, err := New(context.Background(), Config{
+		Nodes:         5,
+		Signers:       3,
+		Quorum:        2,
+		BlockInterval: time.Second,
+		TxInterval:    100 * time.Millisecond,
+		MinLatency:    10 * time.Millisecond,
+		MaxLatency:    200 * time.Millisecond,
+		DropRate:      0.02,
+		Seed:          1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Run(20 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Check(); err != nil {
+		t.Fatal(err)
+	}
+	st := s.Stats()
+	if st.Blocks < 15 {
+		t.Errorf("%d blocks in 20s, want about 20", st.Blocks)
+	}
+	if st.Accepted == 0 || st.Rejected == 0 {
+		t.Errorf("stats %+v, want some transactions accepted and some rejected", st)
+	}
+
+	// Cut off a signer and a follower. The generator and the other
+	// signer still make a quorum.
+	s.Partition([]int{0, 2, 4}, []int{1, 3})
+	height := s.Node(1).Chain.Height()
+	err = s.Run(10 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := s.Node(1).Chain.Height(); h != height {
+		t.Errorf("partitioned node 1 advanced from %d to %d", height, h)
+	}
+	if h := s.Node(0).Chain.Height(); h < height+8 {
+		t.Errorf("generator at height %d after 10s of partition, want about %d", h, height+10)
+	}
+	if err := s.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Heal()
+	err = s.Settle(10 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Check(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNoQuorum(t *testing.T) {
+	s, err := New(context.Background(), Config{Nodes: 3, Signers: 3, Quorum: 2, MaxLatency: 50 * time.Millisecond, Seed: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Partition([]int{0}, []int{1, 2})
+	err = s.Run(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := s.Node(0).Chain.Height(); h != 1 {
+		t.Errorf("generator without a quorum at height %d, want 1", h)
+	}
+	if err := s.Settle(time.Second); errors.Root(err) != ErrDiverged {
+		t.Errorf("Settle during partition: got error %v, want %v", err, ErrDiverged)
+	}
+
+	// The signers signed the same block throughout, so it commits
+	// once they hear of it again.
+	s.Heal()
+	err = s.Settle(5 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := s.Stats(); st.Refusals != 0 {
+		t.Errorf("%d refusals, want 0", st.Refusals)
+	}
+}
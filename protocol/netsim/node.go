@@ -0,0 +1,265 @@
+package netsim
+
+import (
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/mempool"
+	"i10r.io/protocol/prottest/memstore"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/validation"
+)
+
+// The messages nodes exchange. Blocks and transactions travel
+// serialized, and each node decodes and checks them itself.
+type (
+	txMsg struct {
+		prog              []byte
+		version, runlimit int64
+	}
+	blockMsg struct {
+		block []byte
+	}
+	getBlocksMsg struct {
+		from uint64 // the first height wanted
+	}
+	proposeMsg struct {
+		block []byte // an unsigned block
+	}
+	signatureMsg struct {
+		hash   bc.Hash
+		signer int
+		sig    []byte
+	}
+)
+
+// Node is a node of a Sim.
+type Node struct {
+	Chain *protocol.Chain
+	Pool  *mempool.Pool
+
+	sim   *Sim
+	index int
+
+	// proposal is the generator's block awaiting signatures, if any.
+	proposal *proposal
+
+	// signed is the hash of the block, by height, a signer node
+	// has signed at each height, so that it signs no other.
+	signed map[uint64]bc.Hash
+}
+
+type proposal struct {
+	block    *bc.UnsignedBlock
+	snapshot *state.Snapshot
+	hash     bc.Hash
+	sigs     map[int][]byte // by signer
+}
+
+func newNode(s *Sim, index int, initial *bc.Block) (*Node, error) {
+	ctx := s.ctx
+	chain, err := protocol.NewChain(ctx, initial, memstore.New(), nil)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := state.Empty()
+	err = snapshot.ApplyBlock(initial.UnsignedBlock)
+	if err == nil {
+		err = chain.CommitAppliedBlock(ctx, initial, snapshot)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "committing initial block")
+	}
+	return &Node{
+		Chain:  chain,
+		Pool:   mempool.New(chain.State(), s.cfg.MaxPoolTxs),
+		sim:    s,
+		index:  index,
+		signed: make(map[uint64]bc.Hash),
+	}, nil
+}
+
+func (n *Node) handle(from int, msg interface{}) {
+	switch msg := msg.(type) {
+	case *txMsg:
+		tx, err := bc.NewTx(msg.prog, msg.version, msg.runlimit, bc.NetworkOption(n.Chain.InitialBlockHash))
+		if err != nil {
+			n.sim.stats.BadMessages++
+			return
+		}
+		n.addTx(tx, from)
+	case *blockMsg:
+		n.receiveBlock(from, msg.block)
+	case *getBlocksMsg:
+		for h := msg.from; h <= n.Chain.Height(); h++ {
+			b, err := n.Chain.GetBlock(n.sim.ctx, h)
+			if err != nil {
+				return
+			}
+			n.sim.send(n.index, from, &blockMsg{block: blockBytes(b)})
+		}
+	case *proposeMsg:
+		n.sign(from, msg.block)
+	case *signatureMsg:
+		n.addSignature(msg)
+	}
+}
+
+// addTx adds tx to n's pool and, if it is new and valid there,
+// relays it to the other nodes but the one it came from. It reports
+// whether the pool admitted it.
+func (n *Node) addTx(tx *bc.Tx, from int) bool {
+	err := n.Pool.Add(tx)
+	if err != nil {
+		return false
+	}
+	n.sim.broadcast(n.index, from, &txMsg{prog: tx.Program, version: tx.Version, runlimit: tx.Runlimit})
+	return true
+}
+
+// receiveBlock commits the block in bits if it is n's next, and
+// relays it, or asks the sender for the blocks before it if n is
+// further behind.
+func (n *Node) receiveBlock(from int, bits []byte) {
+	var b bc.Block
+	err := b.FromBytes(bits)
+	if err != nil {
+		n.sim.stats.BadMessages++
+		return
+	}
+	height := n.Chain.Height()
+	switch {
+	case b.Height <= height:
+		return // already have it (Check finds it if it differs)
+	case b.Height > height+1:
+		n.sim.send(n.index, from, &getBlocksMsg{from: height + 1})
+		return
+	}
+	prev := n.Chain.State()
+	err = validation.Block(b.UnsignedBlock, prev.Header)
+	if err == nil {
+		err = validation.BlockSig(&b, prev.Header.NextPredicate)
+	}
+	if err == nil {
+		err = n.Chain.CommitBlock(n.sim.ctx, &b)
+	}
+	if err != nil {
+		n.sim.stats.BadMessages++
+		return
+	}
+	n.committed(&b)
+	n.sim.broadcast(n.index, from, &blockMsg{block: bits})
+}
+
+func (n *Node) committed(b *bc.Block) {
+	n.Pool.Update(n.Chain.State(), b)
+	for h := range n.signed {
+		if h <= b.Height {
+			delete(n.signed, h)
+		}
+	}
+}
+
+// propose, on the generator, proposes a block to the signers, or
+// proposes again the one still lacking signatures, and schedules
+// the next proposal.
+func (n *Node) propose() {
+	s := n.sim
+	s.after(s.cfg.BlockInterval, n.propose)
+	if n.proposal == nil {
+		ub, snapshot, err := n.Chain.GenerateBlock(s.ctx, bc.Millis(s.now), n.Pool.Pending())
+		if err != nil {
+			return
+		}
+		n.proposal = &proposal{block: ub, snapshot: snapshot, hash: ub.BlockHeader.Hash(), sigs: make(map[int][]byte)}
+	}
+	s.stats.Proposals++
+	b := &bc.Block{UnsignedBlock: n.proposal.block}
+	bits := blockBytes(b)
+	for i := 0; i < s.cfg.Signers; i++ {
+		if _, ok := n.proposal.sigs[i]; ok {
+			continue
+		}
+		if i == n.index {
+			n.sign(n.index, bits)
+		} else {
+			s.send(n.index, i, &proposeMsg{block: bits})
+		}
+	}
+}
+
+// sign, on a signer, signs the proposed block in bits and sends the
+// signature to the generator, if the block extends n's chain and n
+// has signed no other block at its height.
+func (n *Node) sign(from int, bits []byte) {
+	s := n.sim
+	if n.index >= s.cfg.Signers {
+		return
+	}
+	var b bc.Block
+	err := b.FromBytes(bits)
+	if err != nil {
+		s.stats.BadMessages++
+		return
+	}
+	prev := n.Chain.State()
+	if b.Height > prev.Height()+1 {
+		s.send(n.index, from, &getBlocksMsg{from: prev.Height() + 1})
+		return
+	}
+	hash := b.BlockHeader.Hash()
+	if signed, ok := n.signed[b.Height]; ok && signed != hash {
+		s.stats.Refusals++
+		return
+	}
+	if validation.Block(b.UnsignedBlock, prev.Header) != nil {
+		s.stats.Refusals++
+		return
+	}
+	n.signed[b.Height] = hash
+	msg := &signatureMsg{hash: hash, signer: n.index, sig: ed25519.Sign(s.keys[n.index], hash.Bytes())}
+	if from == n.index {
+		n.addSignature(msg)
+	} else {
+		s.send(n.index, from, msg)
+	}
+}
+
+// addSignature, on the generator, adds a signature to the proposal
+// and, once it has a quorum, commits and announces the block.
+func (n *Node) addSignature(msg *signatureMsg) {
+	s := n.sim
+	p := n.proposal
+	if p == nil || msg.hash != p.hash {
+		return // for an earlier proposal
+	}
+	p.sigs[msg.signer] = msg.sig
+	if len(p.sigs) < s.cfg.Quorum {
+		return
+	}
+	prev := n.Chain.State()
+	b, err := bc.SignBlock(p.block, prev.Header, func(i int) (interface{}, error) {
+		return p.sigs[i], nil
+	})
+	if err == nil {
+		err = n.Chain.CommitAppliedBlock(s.ctx, b, p.snapshot)
+	}
+	n.proposal = nil
+	if err != nil {
+		s.stats.BadMessages++
+		return
+	}
+	s.stats.Blocks++
+	n.committed(b)
+	s.broadcast(n.index, -1, &blockMsg{block: blockBytes(b)})
+}
+
+// blockHash returns the hash of the block at height h on n's chain.
+func (n *Node) blockHash(h uint64) (bc.Hash, error) {
+	b, err := n.Chain.GetBlock(n.sim.ctx, h)
+	if err != nil {
+		return bc.Hash{}, err
+	}
+	return b.BlockHeader.Hash(), nil
+}
@@ -0,0 +1,161 @@
+// Package oracle implements signed oracle attestations.
+//
+// An Attestation is a claim, by a set of oracle keys, that a topic
+// (such as "BTC/USD" at some date, or whether a flight landed) had
+// a given integer value. It expires at a set time so that a stale
+// price cannot be used indefinitely. Once a quorum of the oracle's
+// keys has signed it, it can settle the standard oracle-settlement
+// contract (see standard.OracleSettlementProg) on which derivatives
+// and insurance contracts build; see Settle.
+//
+// An oracle whose keys rotate publishes its keys as identities (see
+// package identity); SignersAt gives the keys to attest with, and to
+// write into contracts, at a given time.
+package oracle
+
+import (
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/identity"
+	"i10r.io/protocol/logdata"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+var (
+	// ErrQuorum is returned for an attestation without enough valid
+	// signatures.
+	ErrQuorum = errors.New("not enough valid attestation signatures")
+
+	// ErrExpired is returned for an attestation used after its
+	// expiration time.
+	ErrExpired = errors.New("attestation expired")
+
+	// ErrTopic is returned for an attestation of a topic other than
+	// the one a contract settles on.
+	ErrTopic = errors.New("attestation for another topic")
+)
+
+// Signers is the set of keys that an oracle attests with.
+type Signers struct {
+	Quorum  int                 `json:"quorum"`
+	Pubkeys []ed25519.PublicKey `json:"pubkeys"`
+}
+
+// SignersAt returns the signers made of quorum of the keys of ids at
+// time t.
+func SignersAt(quorum int, ids []*identity.Identity, t time.Time) (*Signers, error) {
+	s := &Signers{Quorum: quorum}
+	for i, id := range ids {
+		pub, err := id.Key(t)
+		if err != nil {
+			return nil, errors.Wrapf(err, "oracle identity %d", i)
+		}
+		s.Pubkeys = append(s.Pubkeys, pub)
+	}
+	return s, nil
+}
+
+// Attestation is an oracle's signed claim that Topic had Value. It
+// may be used until ExpMS. Signatures is parallel to the signers'
+// pubkeys; an empty entry is a key that did not sign.
+type Attestation struct {
+	Topic      string               `json:"topic"`
+	Value      int64                `json:"value"`
+	ExpMS      uint64               `json:"expiration_ms"`
+	Signatures []chainjson.HexBytes `json:"signatures"`
+}
+
+// New returns an unsigned attestation that topic had value, usable
+// until exp.
+func New(topic string, value int64, exp time.Time) *Attestation {
+	return &Attestation{Topic: topic, Value: value, ExpMS: bc.Millis(exp)}
+}
+
+// Message returns the message an oracle's keys sign. It is the
+// message the standard oracle-settlement contract checks.
+func (a *Attestation) Message() []byte {
+	return standard.AttestationMessage([]byte(a.Topic), a.Value, a.ExpMS)
+}
+
+// LogData returns a as a log data entry (see logdata.Attestation).
+func (a *Attestation) LogData() *logdata.Attestation {
+	sigs := make([][]byte, 0, len(a.Signatures))
+	for _, sig := range a.Signatures {
+		sigs = append(sigs, sig)
+	}
+	return &logdata.Attestation{Topic: a.Topic, Value: a.Value, ExpMS: a.ExpMS, Signatures: sigs}
+}
+
+// Sign adds a signature with prv, which must be the private key for
+// one of s's pubkeys.
+func (a *Attestation) Sign(s *Signers, prv ed25519.PrivateKey) error {
+	pub := prv.Public().(ed25519.PublicKey)
+	for i, pk := range s.Pubkeys {
+		if string(pk) == string(pub) {
+			a.pad(len(s.Pubkeys))
+			a.Signatures[i] = ed25519.Sign(prv, a.Message())
+			return nil
+		}
+	}
+	return errors.New("key is not an oracle key")
+}
+
+func (a *Attestation) pad(n int) {
+	if len(a.Signatures) < n {
+		sigs := make([]chainjson.HexBytes, n)
+		copy(sigs, a.Signatures)
+		a.Signatures = sigs
+	}
+}
+
+// Verify checks that a is unexpired at now and carries exactly a
+// quorum of valid signatures from s, as the standard
+// oracle-settlement contract requires.
+func (a *Attestation) Verify(s *Signers, now time.Time) error {
+	if bc.Millis(now) > a.ExpMS {
+		return errors.WithDetailf(ErrExpired, "expired at %d", a.ExpMS)
+	}
+	if len(a.Signatures) > len(s.Pubkeys) {
+		return errors.WithDetailf(ErrQuorum, "%d signatures for %d keys", len(a.Signatures), len(s.Pubkeys))
+	}
+	msg := a.Message()
+	var n int
+	for i, sig := range a.Signatures {
+		if len(sig) == 0 {
+			continue
+		}
+		if !ed25519.Verify(s.Pubkeys[i], msg, sig) {
+			return errors.WithDetailf(ErrQuorum, "bad signature for key %d", i)
+		}
+		n++
+	}
+	if n != s.Quorum {
+		return errors.WithDetailf(ErrQuorum, "%d signatures, quorum %d", n, s.Quorum)
+	}
+	return nil
+}
+
+// Settle writes txvm bytecode to b that settles a value locked in
+// the standard oracle-settlement contract with terms t, using a.
+// The transaction's maximum time must not be later than a.ExpMS.
+func Settle(b *txvmutil.Builder, t *standard.OracleTerms, amount int64, assetID bc.Hash, anchor []byte, a *Attestation) error {
+	if a.Topic != string(t.Topic) {
+		return errors.WithDetailf(ErrTopic, "attestation of %q, terms on %q", a.Topic, t.Topic)
+	}
+	s := &Signers{Quorum: t.OracleQuorum, Pubkeys: t.OraclePubkeys}
+	if len(a.Signatures) > len(s.Pubkeys) {
+		return errors.WithDetailf(ErrQuorum, "%d signatures for %d keys", len(a.Signatures), len(s.Pubkeys))
+	}
+	a.pad(len(s.Pubkeys))
+	sigs := make([][]byte, len(a.Signatures))
+	for i, sig := range a.Signatures {
+		sigs[i] = sig
+	}
+	standard.SettleOracle(b, t, amount, assetID, anchor, a.Value, a.ExpMS, sigs)
+	return nil
+}
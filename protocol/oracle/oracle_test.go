@@ -0,0 +1,224 @@
+package oracle
+
+import (
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/identity"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+func testKeys(t *testing.T, n int) ([]ed25519.PublicKey, []ed25519.PrivateKey) {
+	var (
+		pubs []ed25519.PublicKey
+		prvs []ed25519.PrivateKey
+	)
+	for i := 0; i < n; i++ {
+		pub, prv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, pub)
+		prvs = append(prvs, prv)
+	}
+	return pubs, prvs
+}
+
+func TestVerify(t *testing.T) {
+	pubs, prvs := testKeys(t, 3)
+	s := &Signers{Quorum: 2, Pubkeys: pubs}
+	now := time.Now()
+	a := New("BTC/USD", 65000, now.Add(time.Hour))
+
+	if err := a.Sign(s, prvs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Verify(s, now); errors.Root(err) != ErrQuorum {
+		t.Errorf("1 of 2: got %v, want %v", err, ErrQuorum)
+	}
+	a.Sign(s, prvs[2])
+	if err := a.Verify(s, now); err != nil {
+		t.Errorf("2 of 2: %v", err)
+	}
+	if err := a.Verify(s, now.Add(2*time.Hour)); errors.Root(err) != ErrExpired {
+		t.Errorf("after expiry: got %v, want %v", err, ErrExpired)
+	}
+	a.Sign(s, prvs[0])
+	if err := a.Verify(s, now); errors.Root(err) != ErrQuorum {
+		t.Errorf("3 of 2: got %v, want %v", err, ErrQuorum)
+	}
+	b := *a
+	b.Value++
+	if err := b.Verify(s, now); errors.Root(err) != ErrQuorum {
+		t.Errorf("altered value: got %v, want %v", err, ErrQuorum)
+	}
+	_, other, _ := ed25519.GenerateKey(nil)
+	if err := a.Sign(s, other); err == nil {
+		t.Error("Sign with foreign key: got no error")
+	}
+}
+
+func TestSignersAt(t *testing.T) {
+	pubs, prvs := testKeys(t, 3)
+	now := time.Now()
+	ids := []*identity.Identity{{Root: pubs[0]}, {Root: pubs[1]}}
+	err := ids[1].Rotate(prvs[1], pubs[2], now, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := SignersAt(2, ids, now.Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := New("BTC/USD", 65000, now.Add(time.Hour))
+	a.Sign(s, prvs[0])
+	a.Sign(s, prvs[1])
+	if err := a.Verify(s, now); err != nil {
+		t.Errorf("before rotation: %v", err)
+	}
+	s, err = SignersAt(2, ids, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Verify(s, now); errors.Root(err) != ErrQuorum {
+		t.Errorf("rotated-away key after rotation: got %v, want %v", err, ErrQuorum)
+	}
+	a.Sign(s, prvs[2])
+	if err := a.Verify(s, now); err != nil {
+		t.Errorf("after rotation: %v", err)
+	}
+	ids[0].Certs = ids[1].Certs
+	if _, err := SignersAt(2, ids, now); errors.Root(err) != identity.ErrChain {
+		t.Errorf("broken chain: got %v, want %v", err, identity.ErrChain)
+	}
+}
+
+func TestSettle(t *testing.T) {
+	oraclePubs, oraclePrvs := testKeys(t, 2)
+	abovePubs, _ := testKeys(t, 1)
+	belowPubs, _ := testKeys(t, 1)
+	terms := &standard.OracleTerms{
+		OracleQuorum:  1,
+		OraclePubkeys: oraclePubs,
+		Topic:         []byte("BTC/USD"),
+		Strike:        60000,
+		AboveQuorum:   1,
+		AbovePubkeys:  abovePubs,
+		BelowQuorum:   1,
+		BelowPubkeys:  belowPubs,
+	}
+	s := &Signers{Quorum: terms.OracleQuorum, Pubkeys: terms.OraclePubkeys}
+	assetID := bc.NewHash([32]byte{1})
+	anchor := []byte("anchor")
+	exp := time.Now().Add(time.Hour)
+	fin, err := asm.Assemble("'id' 10 nonce finalize")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	settle := func(a *Attestation) (*txvm.VM, error) {
+		var b txvmutil.Builder
+		err := Settle(&b, terms, 10, assetID, anchor, a)
+		if err != nil {
+			return nil, err
+		}
+		b.Concat(fin)
+		return txvm.Validate(b.Build(), 3, 100000)
+	}
+	cases := []struct {
+		value int64
+		to    []ed25519.PublicKey
+	}{
+		{65000, abovePubs},
+		{60000, abovePubs},
+		{59999, belowPubs},
+	}
+	for _, c := range cases {
+		a := New("BTC/USD", c.value, exp)
+		a.Sign(s, oraclePrvs[1])
+		vm, err := settle(a)
+		if err != nil {
+			t.Fatalf("value %d: %v", c.value, err)
+		}
+		want := standard.MultisigOutputID(1, c.to, 10, assetID, anchor, standard.PayToMultisigSeed2[:])
+		var got []bc.Hash
+		var maxMS int64
+		for _, item := range vm.Log {
+			switch item[0].(txvm.Bytes)[0] {
+			case txvm.OutputCode:
+				got = append(got, bc.HashFromBytes(item[2].(txvm.Bytes)))
+			case txvm.TimerangeCode:
+				if string(item[1].(txvm.Bytes)) != string(standard.OracleSettlementSeed[:]) {
+					continue
+				}
+				maxMS = int64(item[3].(txvm.Int))
+			}
+		}
+		if len(got) != 1 || got[0] != want {
+			t.Errorf("value %d: outputs %x, want [%x]", c.value, got, want)
+		}
+		if maxMS != int64(a.ExpMS) {
+			t.Errorf("value %d: timerange max %d, want %d", c.value, maxMS, a.ExpMS)
+		}
+	}
+
+	a := New("BTC/USD", 65000, exp)
+	if _, err := settle(a); err == nil {
+		t.Error("settled with no signatures")
+	}
+	a.Sign(s, oraclePrvs[0])
+	a.Sign(s, oraclePrvs[1])
+	if _, err := settle(a); err == nil {
+		t.Error("settled with 2 signatures, quorum 1")
+	}
+	a = New("ETH/USD", 65000, exp)
+	a.Sign(s, oraclePrvs[0])
+	if _, err := settle(a); errors.Root(err) != ErrTopic {
+		t.Errorf("other topic: got %v, want %v", err, ErrTopic)
+	}
+}
+
+func TestLock(t *testing.T) {
+	terms := &standard.OracleTerms{Topic: []byte("flight 123 landed"), Strike: 1}
+	assetID := bc.NewHash([32]byte{1})
+
+	// Spend a 0-of-0 multisig output to get a value to lock.
+	var b txvmutil.Builder
+	prog, err := asm.Assemble("'' put")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Concat(prog)
+	standard.SpendMultisig(&b, 0, nil, 10, assetID, []byte("anchor"), standard.PayToMultisigSeed2[:])
+	prog, err = asm.Assemble("get get 'ref' put put")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Concat(prog)
+	standard.LockOracleSettlement(&b, terms)
+	prog, err = asm.Assemble("'' put call 'id' 10 nonce finalize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Concat(prog)
+	vm, err := txvm.Validate(b.Build(), 3, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := standard.OracleSettlementOutputID(terms, 10, assetID, []byte("anchor"))
+	var got []bc.Hash
+	for _, item := range vm.Log {
+		if item[0].(txvm.Bytes)[0] == txvm.OutputCode {
+			got = append(got, bc.HashFromBytes(item[2].(txvm.Bytes)))
+		}
+	}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("outputs %x, want [%x]", got, want)
+	}
+}
@@ -17,13 +17,27 @@
 // which contains the root of the tree, to obtain a new tree
 // with the same contents. The time to make such a copy is
 // independent of the size of the tree.
+//
+// Insert and Delete never change a node reachable from another
+// tree, so one goroutine may change a copy of a tree while others
+// read the original. Reading a tree changes it in just one way:
+// the hashes of its branches are computed when first needed and
+// kept in the nodes, which its copies share. Once RootHash has
+// returned, every node of the tree has its hash, and any number of
+// goroutines may read the tree, and change copies of it, at once.
+//
+// A tree is laid out to be small, since a node's state holds
+// one item for every unspent output and nonce. Each branch is a
+// single allocation holding its two children and its hash. Leaves
+// live inside their parents and hold only their items, with the
+// leaf hash computed when needed, and a branch's key is the part
+// of one of its leaves' items it shares with the rest. Build packs
+// the items of a tree into one array.
 package patricia
 
 import (
 	"bytes"
-	"io"
 
-	"i10r.io/crypto/sha3pool"
 	"i10r.io/errors"
 )
 
@@ -37,6 +51,28 @@ type Tree struct {
 	root *node
 }
 
+// Build returns a tree of the given items. Unlike Insert, which
+// keeps the item it is given, it copies them, all into one array,
+// so that the tree keeps none of the caller's memory and no item
+// costs more than its length. The array lives as long as any of
+// its items is in a tree.
+func Build(items [][]byte) (*Tree, error) {
+	var size int
+	for _, item := range items {
+		size += len(item)
+	}
+	buf := make([]byte, 0, size)
+	t := new(Tree)
+	for _, item := range items {
+		buf = append(buf, item...)
+		err := t.Insert(buf[len(buf)-len(item) : len(buf) : len(buf)])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
 // WalkFunc is the type of the function called for each item
 // visited by Walk. If an error is returned, processing stops.
 type WalkFunc func(item []byte) error
@@ -52,16 +88,16 @@ func Walk(t *Tree, walkFn WalkFunc) error {
 }
 
 func walk(n *node, walkFn WalkFunc) error {
-	if n.isLeaf {
+	if n.isLeaf() {
 		return walkFn(n.key)
 	}
 
-	err := walk(n.children[0], walkFn)
+	err := walk(&n.children[0], walkFn)
 	if err != nil {
 		return err
 	}
 
-	err = walk(n.children[1], walkFn)
+	err = walk(&n.children[1], walkFn)
 	return err
 }
 
@@ -77,18 +113,42 @@ func (t *Tree) Contains(item []byte) bool {
 }
 
 func lookup(n *node, key []byte) *node {
-	if bytes.Equal(n.key, key) && n.keybit == 7 {
-		if !n.isLeaf {
+	keybit := n.keybit()
+	if bytes.Equal(n.key, key) && keybit == 7 {
+		if !n.isLeaf() {
 			return nil
 		}
 		return n
 	}
-	if !hasPrefix(key, n.key, n.keybit) {
+	if n.isLeaf() || !hasPrefix(key, n.key, keybit) {
 		return nil
 	}
 
-	bit := childIdx(key, len(n.key), n.keybit)
-	return lookup(n.children[bit], key)
+	bit := childIdx(key, len(n.key), keybit)
+	return lookup(&n.children[bit], key)
+}
+
+// WithPrefix returns an item in t that begins with prefix, or nil if
+// there is none. If there are several, it returns the least.
+func (t *Tree) WithPrefix(prefix []byte) []byte {
+	n := t.root
+	for n != nil {
+		if n.isLeaf() || bitLen(n) >= 8*len(prefix) {
+			// Every item under n begins with n's key, as much of it
+			// as prefix is long.
+			n = leftmost(n)
+			if bytes.HasPrefix(n.key, prefix) {
+				return n.key
+			}
+			return nil
+		}
+		keybit := n.keybit()
+		if !hasPrefix(prefix, n.key, keybit) {
+			return nil
+		}
+		n = &n.children[childIdx(prefix, len(n.key), keybit)]
+	}
+	return nil
 }
 
 // Insert inserts item into t.
@@ -97,50 +157,46 @@ func lookup(n *node, key []byte) *node {
 // in t or to contain an element in t as a prefix.
 // If item itself is already in t, Insert does nothing
 // (and this is not an error).
+//
+// The tree keeps item, which the caller must not change
+// afterward.
 func (t *Tree) Insert(item []byte) error {
-	var hash [32]byte
-	h := sha3pool.Get256()
-	h.Write(leafPrefix)
-	h.Write(item)
-	io.ReadFull(h, hash[:])
-	sha3pool.Put256(h)
-
 	if t.root == nil {
-		t.root = &node{key: item, keybit: 7, hash: &hash, isLeaf: true}
+		t.root = &node{key: item}
 		return nil
 	}
 
 	var err error
-	t.root, err = insert(t.root, item, &hash)
+	t.root, err = insert(t.root, item)
 	return err
 }
 
-func insert(n *node, key []byte, hash *[32]byte) (*node, error) {
-	if bytes.Equal(n.key, key) && n.keybit == 7 {
-		if !n.isLeaf {
+func insert(n *node, key []byte) (*node, error) {
+	keybit := n.keybit()
+	if bytes.Equal(n.key, key) && keybit == 7 {
+		if !n.isLeaf() {
 			return n, errors.Wrap(errors.New("key provided is a prefix to other keys"))
 		}
 
 		return n, nil
 	}
 
-	if hasPrefix(key, n.key, n.keybit) {
-		if n.isLeaf {
+	if hasPrefix(key, n.key, keybit) {
+		if n.isLeaf() {
 			return n, errors.Wrap(errors.New("key provided is a prefix to other keys"))
 		}
 
-		bit := childIdx(key, len(n.key), n.keybit)
+		bit := childIdx(key, len(n.key), keybit)
 
-		child := n.children[bit]
-		child, err := insert(child, key, hash)
+		child, err := insert(&n.children[bit], key)
 		if err != nil {
 			return n, err
 		}
-		newNode := new(node)
-		*newNode = *n
-		newNode.children[bit] = child // mutation is ok because newNode hasn't escaped yet
-		newNode.hash = nil
-		return newNode, nil
+		b := new(branch)
+		*b = *n.branch
+		b.children[bit] = *child // mutation is ok because b hasn't escaped yet
+		b.hash = [32]byte{}
+		return &node{key: n.key, branch: b}, nil
 	}
 
 	if hasPrefix(n.key, key, 7) {
@@ -148,19 +204,11 @@ func insert(n *node, key []byte, hash *[32]byte) (*node, error) {
 	}
 
 	common, bit := commonPrefix(n.key, key)
-	newNode := &node{
-		key:    key[:common],
-		keybit: bit,
-	}
+	b := new(branch)
 	childBit := childIdx(key, common, bit)
-	newNode.children[childBit] = &node{
-		key:    key,
-		keybit: 7,
-		hash:   hash,
-		isLeaf: true,
-	}
-	newNode.children[1-childBit] = n
-	return newNode, nil
+	b.children[childBit] = node{key: key}
+	b.children[1-childBit] = *n
+	return &node{key: key[:common], branch: b}, nil
 }
 
 // Delete removes item from t, if present.
@@ -171,38 +219,46 @@ func (t *Tree) Delete(item []byte) {
 }
 
 func delete(n *node, key []byte) *node {
-	if bytes.Equal(key, n.key) && n.keybit == 7 {
-		if !n.isLeaf {
+	keybit := n.keybit()
+	if bytes.Equal(key, n.key) && keybit == 7 {
+		if !n.isLeaf() {
 			return n
 		}
 		return nil
 	}
 
-	if !hasPrefix(key, n.key, n.keybit) {
+	if n.isLeaf() || !hasPrefix(key, n.key, keybit) {
 		return n
 	}
 
-	bit := childIdx(key, len(n.key), n.keybit)
-	newChild := delete(n.children[bit], key)
+	bit := childIdx(key, len(n.key), keybit)
+	child := &n.children[bit]
+	newChild := delete(child, key)
 
 	if newChild == nil {
-		return n.children[1-bit]
+		sibling := n.children[1-bit]
+		return &sibling
 	}
 
-	if newChild == n.children[bit] {
+	if newChild == child {
 		return n
 	}
 
-	newNode := new(node)
-	*newNode = *n
-	newNode.key = newChild.key[:len(n.key)] // only use slices of leaf node keys
-	newNode.children[bit] = newChild
-	newNode.hash = nil
+	b := new(branch)
+	*b = *n.branch
+	b.children[bit] = *newChild
+	b.hash = [32]byte{}
 
-	return newNode
+	return &node{
+		key:    newChild.key[:len(n.key)], // only use slices of leaf node keys
+		branch: b,
+	}
 }
 
 // RootHash returns the Merkle root of the tree.
+// It computes and keeps the hashes of the nodes that do not yet
+// have them, so it must not be called concurrently with other
+// reads of a tree whose root hash has not been computed.
 func (t *Tree) RootHash() [32]byte {
 	root := t.root
 	if root == nil {
@@ -270,35 +326,48 @@ func childIdx(key []byte, len int, bit byte) byte {
 	return bitAt(key[len-1], bit)
 }
 
-// node is a leaf or branch node in a tree
+// node is a leaf or branch node in a tree. A leaf's key is its
+// item. A branch's key is the prefix its items share, through bit
+// keybit of its last byte, sliced from one of their keys.
 type node struct {
-	key      []byte
-	keybit   byte
-	hash     *[32]byte
-	isLeaf   bool
-	children [2]*node
+	key []byte
+	*branch
+}
+
+// branch holds a branch node's children and its hash, which is
+// zero until computed.
+type branch struct {
+	hash     [32]byte
+	children [2]node
+}
+
+func (n *node) isLeaf() bool {
+	return n.branch == nil
+}
+
+// keybit returns the index of the last bit of n's key in its last
+// byte. It is not stored: for a branch, it is where the keys of the
+// children part.
+func (n *node) keybit() byte {
+	if n.isLeaf() {
+		return 7
+	}
+	_, bit := commonPrefix(n.children[0].key, n.children[1].key)
+	return bit
 }
 
 // Hash will return the hash for this node.
 func (n *node) Hash() [32]byte {
+	if n.isLeaf() {
+		return leafHash(n.key)
+	}
 	n.calcHash()
-	return *n.hash
+	return n.hash
 }
 
 func (n *node) calcHash() {
-	if n.hash != nil {
+	if n.isLeaf() || n.hash != [32]byte{} {
 		return
 	}
-
-	h := sha3pool.Get256()
-	h.Write(interiorPrefix)
-	for _, c := range n.children {
-		c.calcHash()
-		h.Write(c.hash[:])
-	}
-
-	var hash [32]byte
-	io.ReadFull(h, hash[:])
-	n.hash = &hash
-	sha3pool.Put256(h)
+	n.hash = interiorHash(n.children[0].Hash(), n.children[1].Hash())
 }
@@ -1,11 +1,13 @@
 package patricia
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"log"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
@@ -59,6 +61,118 @@ func BenchmarkInsertsRootHash(b *testing.B) {
 	}
 }
 
+// benchTree returns a tree of n items, hashed as output IDs are.
+func benchTree(b *testing.B, n int) *Tree {
+	tr := new(Tree)
+	for j := uint64(0); j < uint64(n); j++ {
+		err := tr.Insert(benchItem(j))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	tr.RootHash()
+	return tr
+}
+
+func benchItem(j uint64) []byte {
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], j)
+	h := sha3.Sum256(n[:])
+	return h[:]
+}
+
+// BenchmarkUpdate measures the incremental update of a large tree
+// that applying a transaction makes: copying the root, deleting an
+// item, inserting another, and rehashing.
+func BenchmarkUpdate(b *testing.B) {
+	const nodes = 100000
+	base := benchTree(b, nodes)
+	tr := new(Tree)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		*tr = *base
+		tr.Delete(benchItem(uint64(i % nodes)))
+		err := tr.Insert(benchItem(uint64(nodes + i)))
+		if err != nil {
+			b.Fatal(err)
+		}
+		tr.RootHash()
+	}
+}
+
+func BenchmarkContains(b *testing.B) {
+	const nodes = 100000
+	tr := benchTree(b, nodes)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !tr.Contains(benchItem(uint64(i % nodes))) {
+			b.Fatal("item missing")
+		}
+	}
+}
+
+// footprintItem is an item the size of a contract with a rent
+// expiration: an output ID followed by a timestamp.
+func footprintItem(j uint64) []byte {
+	item := make([]byte, 40)
+	copy(item, benchItem(j))
+	binary.BigEndian.PutUint64(item[32:], 1600000000000+j)
+	return item
+}
+
+// heapPerItem returns the heap growth, per item, from calling f.
+func heapPerItem(n int, f func()) float64 {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	f()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	return float64(int64(after.HeapAlloc)-int64(before.HeapAlloc)) / float64(n)
+}
+
+func TestFootprint(t *testing.T) {
+	const n = 50000
+	items := make([][]byte, n)
+	for i := range items {
+		items[i] = footprintItem(uint64(i))
+	}
+
+	// Before the compact layout, an item took 240 bytes.
+	var inserted, built *Tree
+	perItem := heapPerItem(n, func() {
+		inserted = new(Tree)
+		for _, item := range items {
+			err := inserted.Insert(append([]byte(nil), item...))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		inserted.RootHash()
+	})
+	if perItem > 150 {
+		t.Errorf("Insert: %.1f bytes per item, want at most 150", perItem)
+	}
+
+	perItem = heapPerItem(n, func() {
+		var err error
+		built, err = Build(items)
+		if err != nil {
+			t.Fatal(err)
+		}
+		built.RootHash()
+	})
+	if perItem > 140 {
+		t.Errorf("Build: %.1f bytes per item, want at most 140", perItem)
+	}
+	if built.RootHash() != inserted.RootHash() {
+		t.Error("Build and Insert made different trees")
+	}
+
+	runtime.KeepAlive(inserted)
+	runtime.KeepAlive(built)
+}
+
 func TestRootHashBug(t *testing.T) {
 	tr := new(Tree)
 
@@ -102,16 +216,17 @@ func TestLeafVsInternalNodes(t *testing.T) {
 
 	// Force calculation of all the hashes.
 	tr0.RootHash()
-	t.Logf("first child = %x, %t", tr0.root.children[0].hash[:], tr0.root.children[0].isLeaf)
-	t.Logf("second child = %x, %t", tr0.root.children[1].hash[:], tr0.root.children[1].isLeaf)
+	h0, h1 := tr0.root.children[0].Hash(), tr0.root.children[1].Hash()
+	t.Logf("first child = %x, %t", h0[:], tr0.root.children[0].isLeaf())
+	t.Logf("second child = %x, %t", h1[:], tr0.root.children[1].isLeaf())
 
 	// Create a second tree using an internal node from tr1.
 	tr1 := new(Tree)
-	err = tr1.Insert(tr0.root.children[0].hash[:]) // internal node of tr0
+	err = tr1.Insert(h0[:]) // internal node of tr0
 	if err != nil {
 		t.Fatal(err)
 	}
-	err = tr1.Insert(tr0.root.children[1].hash[:]) // sibling leaf node of above node ^
+	err = tr1.Insert(h1[:]) // sibling leaf node of above node ^
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -139,7 +254,7 @@ func TestRootHashInsertQuickCheck(t *testing.T) {
 
 func TestLookup(t *testing.T) {
 	tr := &Tree{
-		root: &node{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+		root: &node{key: bits("11111111")},
 	}
 	got := lookup(tr.root, bits("11111111"))
 	if !testutil.DeepEqual(got, tr.root) {
@@ -148,7 +263,7 @@ func TestLookup(t *testing.T) {
 	}
 
 	tr = &Tree{
-		root: &node{key: bits("11111110"), hash: hashPtr(hashForLeaf(bits("11111110"))), isLeaf: true, keybit: 7},
+		root: &node{key: bits("11111110")},
 	}
 	got = lookup(tr.root, bits("11111111"))
 	if got != nil {
@@ -158,46 +273,49 @@ func TestLookup(t *testing.T) {
 
 	tr = &Tree{
 		root: &node{
-			key:    bits("11110000"),
-			keybit: 3,
-			hash:   hashPtr(hashForNonLeaf(hashForLeaf(bits("11110000")), hashForLeaf(bits("11111111")))),
-			children: [2]*node{
-				{key: bits("11110000"), hash: hashPtr(hashForLeaf(bits("11110000"))), isLeaf: true, keybit: 7},
-				{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+			key: bits("11110000"),
+			branch: &branch{
+				hash: hashForNonLeaf(hashForLeaf(bits("11110000")), hashForLeaf(bits("11111111"))),
+				children: [2]node{
+					{key: bits("11110000")},
+					{key: bits("11111111")},
+				},
 			},
 		},
 	}
 	got = lookup(tr.root, bits("11110000"))
-	if !testutil.DeepEqual(got, tr.root.children[0]) {
+	if !testutil.DeepEqual(got, &tr.root.children[0]) {
 		t.Log("lookup root's first child")
-		t.Fatalf("got:\n%swant:\n%s", prettyNode(got, 0), prettyNode(tr.root.children[0], 0))
+		t.Fatalf("got:\n%swant:\n%s", prettyNode(got, 0), prettyNode(&tr.root.children[0], 0))
 	}
 
 	tr = &Tree{
 		root: &node{
-			key:    bits("11110000"),
-			keybit: 3,
-			hash: hashPtr(hashForNonLeaf(
-				hashForLeaf(bits("11110000")),
-				hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111"))),
-			)),
-			children: [2]*node{
-				{key: bits("11110000"), hash: hashPtr(hashForLeaf(bits("11110000"))), isLeaf: true, keybit: 7},
-				{
-					key:    bits("11111100"),
-					keybit: 5,
-					hash:   hashPtr(hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111")))),
-					children: [2]*node{
-						{key: bits("11111100"), hash: hashPtr(hashForLeaf(bits("11111100"))), isLeaf: true, keybit: 7},
-						{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+			key: bits("11110000"),
+			branch: &branch{
+				hash: hashForNonLeaf(
+					hashForLeaf(bits("11110000")),
+					hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111"))),
+				),
+				children: [2]node{
+					{key: bits("11110000")},
+					{
+						key: bits("11111100"),
+						branch: &branch{
+							hash: hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111"))),
+							children: [2]node{
+								{key: bits("11111100")},
+								{key: bits("11111111")},
+							},
+						},
 					},
 				},
 			},
 		},
 	}
 	got = lookup(tr.root, bits("11111100"))
-	if !testutil.DeepEqual(got, tr.root.children[1].children[0]) {
-		t.Fatalf("got:\n%swant:\n%s", prettyNode(got, 0), prettyNode(tr.root.children[1].children[0], 0))
+	if !testutil.DeepEqual(got, &tr.root.children[1].children[0]) {
+		t.Fatalf("got:\n%swant:\n%s", prettyNode(got, 0), prettyNode(&tr.root.children[1].children[0], 0))
 	}
 }
 
@@ -230,13 +348,38 @@ func TestContains(t *testing.T) {
 	}
 }
 
+func TestWithPrefix(t *testing.T) {
+	tr := new(Tree)
+	if got := tr.WithPrefix(nil); got != nil {
+		t.Errorf("empty tree: got %x, want nil", got)
+	}
+
+	var items [][]byte
+	for i := 0; i < 64; i++ {
+		item := []byte{byte(i * 37), byte(i * 11), byte(i)}
+		items = append(items, item)
+		tr.Insert(item)
+	}
+	for _, prefix := range [][]byte{nil, {0}, {37}, {74, 22}, {74, 23}, {185, 55, 5}, {185, 55, 6}, {185, 55, 5, 0}} {
+		var want []byte
+		for _, item := range items {
+			if bytes.HasPrefix(item, prefix) && (want == nil || bytes.Compare(item, want) < 0) {
+				want = item
+			}
+		}
+		if got := tr.WithPrefix(prefix); !bytes.Equal(got, want) {
+			t.Errorf("WithPrefix(%x) = %x, want %x", prefix, got, want)
+		}
+	}
+}
+
 func TestInsert(t *testing.T) {
 	tr := new(Tree)
 
 	tr.Insert(bits("11111111"))
 	tr.RootHash()
 	want := &Tree{
-		root: &node{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+		root: &node{key: bits("11111111")},
 	}
 	if !testutil.DeepEqual(tr.root, want.root) {
 		log.Printf("want hash? %x", hashForLeaf(bits("11111111")))
@@ -247,7 +390,7 @@ func TestInsert(t *testing.T) {
 	tr.Insert(bits("11111111"))
 	tr.RootHash()
 	want = &Tree{
-		root: &node{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+		root: &node{key: bits("11111111")},
 	}
 	if !testutil.DeepEqual(tr.root, want.root) {
 		t.Log("inserting the same key does not modify the tree")
@@ -258,12 +401,13 @@ func TestInsert(t *testing.T) {
 	tr.RootHash()
 	want = &Tree{
 		root: &node{
-			key:    bits("11110000"),
-			keybit: 3,
-			hash:   hashPtr(hashForNonLeaf(hashForLeaf(bits("11110000")), hashForLeaf(bits("11111111")))),
-			children: [2]*node{
-				{key: bits("11110000"), hash: hashPtr(hashForLeaf(bits("11110000"))), isLeaf: true, keybit: 7},
-				{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+			key: bits("11110000"),
+			branch: &branch{
+				hash: hashForNonLeaf(hashForLeaf(bits("11110000")), hashForLeaf(bits("11111111"))),
+				children: [2]node{
+					{key: bits("11110000")},
+					{key: bits("11111111")},
+				},
 			},
 		},
 	}
@@ -276,21 +420,23 @@ func TestInsert(t *testing.T) {
 	tr.RootHash()
 	want = &Tree{
 		root: &node{
-			key:    bits("11110000"),
-			keybit: 3,
-			hash: hashPtr(hashForNonLeaf(
-				hashForLeaf(bits("11110000")),
-				hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111"))),
-			)),
-			children: [2]*node{
-				{key: bits("11110000"), hash: hashPtr(hashForLeaf(bits("11110000"))), isLeaf: true, keybit: 7},
-				{
-					key:    bits("11111100"),
-					keybit: 5,
-					hash:   hashPtr(hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111")))),
-					children: [2]*node{
-						{key: bits("11111100"), hash: hashPtr(hashForLeaf(bits("11111100"))), isLeaf: true, keybit: 7},
-						{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+			key: bits("11110000"),
+			branch: &branch{
+				hash: hashForNonLeaf(
+					hashForLeaf(bits("11110000")),
+					hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111"))),
+				),
+				children: [2]node{
+					{key: bits("11110000")},
+					{
+						key: bits("11111100"),
+						branch: &branch{
+							hash: hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111"))),
+							children: [2]node{
+								{key: bits("11111100")},
+								{key: bits("11111111")},
+							},
+						},
 					},
 				},
 			},
@@ -304,32 +450,35 @@ func TestInsert(t *testing.T) {
 	tr.RootHash()
 	want = &Tree{
 		root: &node{
-			key:    bits("11110000"),
-			keybit: 3,
-			hash: hashPtr(hashForNonLeaf(
-				hashForLeaf(bits("11110000")),
-				hashForNonLeaf(
-					hashForLeaf(bits("11111100")),
-					hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
-				),
-			)),
-			children: [2]*node{
-				{key: bits("11110000"), hash: hashPtr(hashForLeaf(bits("11110000"))), isLeaf: true, keybit: 7},
-				{
-					key:    bits("11111100"),
-					keybit: 5,
-					hash: hashPtr(hashForNonLeaf(
+			key: bits("11110000"),
+			branch: &branch{
+				hash: hashForNonLeaf(
+					hashForLeaf(bits("11110000")),
+					hashForNonLeaf(
 						hashForLeaf(bits("11111100")),
-						hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))))),
-					children: [2]*node{
-						{key: bits("11111100"), hash: hashPtr(hashForLeaf(bits("11111100"))), isLeaf: true, keybit: 7},
-						{
-							key:    bits("11111110"),
-							keybit: 6,
-							hash:   hashPtr(hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111")))),
-							children: [2]*node{
-								{key: bits("11111110"), hash: hashPtr(hashForLeaf(bits("11111110"))), isLeaf: true, keybit: 7},
-								{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+						hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
+					),
+				),
+				children: [2]node{
+					{key: bits("11110000")},
+					{
+						key: bits("11111100"),
+						branch: &branch{
+							hash: hashForNonLeaf(
+								hashForLeaf(bits("11111100")),
+								hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111")))),
+							children: [2]node{
+								{key: bits("11111100")},
+								{
+									key: bits("11111110"),
+									branch: &branch{
+										hash: hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
+										children: [2]node{
+											{key: bits("11111110")},
+											{key: bits("11111111")},
+										},
+									},
+								},
 							},
 						},
 					},
@@ -346,47 +495,51 @@ func TestInsert(t *testing.T) {
 	tr.RootHash()
 	want = &Tree{
 		root: &node{
-			key:    bits("11110000"),
-			keybit: 3,
-			hash: hashPtr(hashForNonLeaf(
-				hashForLeaf(bits("11110000")),
-				hashForNonLeaf(
-					hashForLeaf(bits("11111011")),
+			key: bits("11110000"),
+			branch: &branch{
+				hash: hashForNonLeaf(
+					hashForLeaf(bits("11110000")),
 					hashForNonLeaf(
-						hashForLeaf(bits("11111100")),
-						hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
-					),
-				),
-			)),
-			children: [2]*node{
-				{key: bits("11110000"), hash: hashPtr(hashForLeaf(bits("11110000"))), isLeaf: true, keybit: 7},
-				{
-					key:    bits("11111011"),
-					keybit: 4,
-					hash: hashPtr(hashForNonLeaf(
 						hashForLeaf(bits("11111011")),
 						hashForNonLeaf(
 							hashForLeaf(bits("11111100")),
 							hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
-						))),
-					children: [2]*node{
-						{key: bits("11111011"), hash: hashPtr(hashForLeaf(bits("11111011"))), isLeaf: true, keybit: 7},
-						{
-							key:    bits("11111100"),
-							keybit: 5,
-							hash: hashPtr(hashForNonLeaf(
-								hashForLeaf(bits("11111100")),
-								hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
-							)),
-							children: [2]*node{
-								{key: bits("11111100"), hash: hashPtr(hashForLeaf(bits("11111100"))), isLeaf: true, keybit: 7},
+						),
+					),
+				),
+				children: [2]node{
+					{key: bits("11110000")},
+					{
+						key: bits("11111011"),
+						branch: &branch{
+							hash: hashForNonLeaf(
+								hashForLeaf(bits("11111011")),
+								hashForNonLeaf(
+									hashForLeaf(bits("11111100")),
+									hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
+								)),
+							children: [2]node{
+								{key: bits("11111011")},
 								{
-									key:    bits("11111110"),
-									keybit: 6,
-									hash:   hashPtr(hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111")))),
-									children: [2]*node{
-										{key: bits("11111110"), hash: hashPtr(hashForLeaf(bits("11111110"))), isLeaf: true, keybit: 7},
-										{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+									key: bits("11111100"),
+									branch: &branch{
+										hash: hashForNonLeaf(
+											hashForLeaf(bits("11111100")),
+											hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
+										),
+										children: [2]node{
+											{key: bits("11111100")},
+											{
+												key: bits("11111110"),
+												branch: &branch{
+													hash: hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
+													children: [2]node{
+														{key: bits("11111110")},
+														{key: bits("11111111")},
+													},
+												},
+											},
+										},
 									},
 								},
 							},
@@ -430,33 +583,36 @@ func TestInsert(t *testing.T) {
 func TestDelete(t *testing.T) {
 	tr := new(Tree)
 	tr.root = &node{
-		key:    bits("11110000"),
-		keybit: 3,
-		hash: hashPtr(hashForNonLeaf(
-			hashForLeaf(bits("11110000")),
-			hashForNonLeaf(
-				hashForLeaf(bits("11111100")),
-				hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
-			),
-		)),
-		children: [2]*node{
-			{key: bits("11110000"), hash: hashPtr(hashForLeaf(bits("11110000"))), isLeaf: true, keybit: 7},
-			{
-				key:    bits("11111100"),
-				keybit: 5,
-				hash: hashPtr(hashForNonLeaf(
+		key: bits("11110000"),
+		branch: &branch{
+			hash: hashForNonLeaf(
+				hashForLeaf(bits("11110000")),
+				hashForNonLeaf(
 					hashForLeaf(bits("11111100")),
 					hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
-				)),
-				children: [2]*node{
-					{key: bits("11111100"), hash: hashPtr(hashForLeaf(bits("11111100"))), isLeaf: true, keybit: 7},
-					{
-						key:    bits("11111110"),
-						keybit: 6,
-						hash:   hashPtr(hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111")))),
-						children: [2]*node{
-							{key: bits("11111110"), hash: hashPtr(hashForLeaf(bits("11111110"))), isLeaf: true, keybit: 7},
-							{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+				),
+			),
+			children: [2]node{
+				{key: bits("11110000")},
+				{
+					key: bits("11111100"),
+					branch: &branch{
+						hash: hashForNonLeaf(
+							hashForLeaf(bits("11111100")),
+							hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
+						),
+						children: [2]node{
+							{key: bits("11111100")},
+							{
+								key: bits("11111110"),
+								branch: &branch{
+									hash: hashForNonLeaf(hashForLeaf(bits("11111110")), hashForLeaf(bits("11111111"))),
+									children: [2]node{
+										{key: bits("11111110")},
+										{key: bits("11111111")},
+									},
+								},
+							},
 						},
 					},
 				},
@@ -468,21 +624,23 @@ func TestDelete(t *testing.T) {
 	tr.RootHash()
 	want := &Tree{
 		root: &node{
-			key:    bits("11111111"),
-			keybit: 3,
-			hash: hashPtr(hashForNonLeaf(
-				hashForLeaf(bits("11110000")),
-				hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111"))),
-			)),
-			children: [2]*node{
-				{key: bits("11110000"), hash: hashPtr(hashForLeaf(bits("11110000"))), isLeaf: true, keybit: 7},
-				{
-					key:    bits("11111111"),
-					keybit: 5,
-					hash:   hashPtr(hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111")))),
-					children: [2]*node{
-						{key: bits("11111100"), hash: hashPtr(hashForLeaf(bits("11111100"))), isLeaf: true, keybit: 7},
-						{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+			key: bits("11111111"),
+			branch: &branch{
+				hash: hashForNonLeaf(
+					hashForLeaf(bits("11110000")),
+					hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111"))),
+				),
+				children: [2]node{
+					{key: bits("11110000")},
+					{
+						key: bits("11111111"),
+						branch: &branch{
+							hash: hashForNonLeaf(hashForLeaf(bits("11111100")), hashForLeaf(bits("11111111"))),
+							children: [2]node{
+								{key: bits("11111100")},
+								{key: bits("11111111")},
+							},
+						},
 					},
 				},
 			},
@@ -496,12 +654,13 @@ func TestDelete(t *testing.T) {
 	tr.RootHash()
 	want = &Tree{
 		root: &node{
-			key:    bits("11111111"),
-			keybit: 3,
-			hash:   hashPtr(hashForNonLeaf(hashForLeaf(bits("11110000")), hashForLeaf(bits("11111111")))),
-			children: [2]*node{
-				{key: bits("11110000"), hash: hashPtr(hashForLeaf(bits("11110000"))), isLeaf: true, keybit: 7},
-				{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+			key: bits("11111111"),
+			branch: &branch{
+				hash: hashForNonLeaf(hashForLeaf(bits("11110000")), hashForLeaf(bits("11111111"))),
+				children: [2]node{
+					{key: bits("11110000")},
+					{key: bits("11111111")},
+				},
 			},
 		},
 	}
@@ -518,7 +677,7 @@ func TestDelete(t *testing.T) {
 	tr.Delete(bits("11110000"))
 	tr.RootHash()
 	want = &Tree{
-		root: &node{key: bits("11111111"), hash: hashPtr(hashForLeaf(bits("11111111"))), isLeaf: true, keybit: 7},
+		root: &node{key: bits("11111111")},
 	}
 	if !testutil.DeepEqual(tr.root, want.root) {
 		t.Fatalf("got:\n%swant:\n%s", pretty(tr), pretty(want))
@@ -534,12 +693,13 @@ func TestDelete(t *testing.T) {
 
 func TestDeletePrefix(t *testing.T) {
 	root := &node{
-		key:    []byte{1, 1},
-		keybit: 7,
-		hash:   hashPtr(hashForNonLeaf(hashForLeaf([]byte{1, 1, 0}), hashForLeaf([]byte{1, 1, 1}))),
-		children: [2]*node{
-			{key: []byte{1, 1, 0}, hash: hashPtr(hashForLeaf([]byte{1, 1, 0})), isLeaf: true, keybit: 7},
-			{key: []byte{1, 1, 1}, hash: hashPtr(hashForLeaf([]byte{1, 1, 1})), isLeaf: true, keybit: 7},
+		key: []byte{1, 1},
+		branch: &branch{
+			hash: hashForNonLeaf(hashForLeaf([]byte{1, 1, 0}), hashForLeaf([]byte{1, 1, 1})),
+			children: [2]node{
+				{key: []byte{1, 1, 0}},
+				{key: []byte{1, 1, 1}},
+			},
 		},
 	}
 
@@ -667,14 +827,14 @@ func prettyNode(n *node, depth int) string {
 		b = 31 * 8
 	}
 	prettyStr += fmt.Sprintf("key=%+v", n.key[b:])
-	if n.hash != nil {
+	if !n.isLeaf() {
 		prettyStr += fmt.Sprintf(" hash=%+v", n.hash)
 	}
 	prettyStr += "\n"
 
-	for _, c := range n.children {
-		if c != nil {
-			prettyStr += prettyNode(c, depth+1)
+	if !n.isLeaf() {
+		for i := range n.children {
+			prettyStr += prettyNode(&n.children[i], depth+1)
 		}
 	}
 
@@ -698,10 +858,6 @@ func hashForNonLeaf(a, b [32]byte) [32]byte {
 	return sha3.Sum256(d)
 }
 
-func hashPtr(h [32]byte) *[32]byte {
-	return &h
-}
-
 func mustDecodeHash(str string) [32]byte {
 	dec, err := hex.DecodeString(str)
 	if err != nil {
@@ -0,0 +1,446 @@
+package patricia
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"i10r.io/crypto/sha3pool"
+	"i10r.io/errors"
+)
+
+// ProofVersion is the version of the proof encoding produced by
+// Proof.Bytes.
+const ProofVersion = 1
+
+// ErrProof is returned for a proof that is malformed or that does not
+// hold.
+var ErrProof = errors.New("invalid patricia proof")
+
+// A Proof shows that an item is, or is not, in the tree with a given
+// root hash. It consists of paths from the root to leaves.
+//
+// An inclusion proof has the path to the item itself. An exclusion
+// proof has the paths to the item's neighbors: the greatest item less
+// than it, Pred, and the least item greater, Succ. Because the leaves
+// of a tree are in increasing order, two leaves next to each other
+// with the item between them show that the item is not in the tree.
+// Pred is nil for an item less than every item in the tree, and Succ
+// for one greater than every item. Both are nil for the empty tree.
+//
+// See the Chain Protocol spec for the encoding of a proof.
+type Proof struct {
+	Item []byte
+
+	Path       *ProofPath // for an inclusion proof
+	Pred, Succ *ProofPath // for an exclusion proof
+}
+
+// A ProofPath is the path from the root of a tree to one of its
+// leaves.
+type ProofPath struct {
+	Item []byte // the leaf
+
+	// Dirs[i] is the child, 0 or 1, the path takes from the node at
+	// depth i, and Siblings[i] is the hash of the other child.
+	Dirs     []byte
+	Siblings [][32]byte
+}
+
+// Included reports whether p is an inclusion proof.
+func (p *Proof) Included() bool {
+	return p.Path != nil
+}
+
+// Prove returns a proof that item is or is not in t.
+func (t *Tree) Prove(item []byte) *Proof {
+	p := &Proof{Item: item}
+	if t.root == nil {
+		return p
+	}
+
+	// Descend as far as item's bits lead.
+	var (
+		n    = t.root
+		path []*node
+		dirs []byte
+	)
+	for !n.isLeaf() && hasPrefix(item, n.key, n.keybit()) && 8*len(item) > bitLen(n) {
+		bit := childIdx(item, len(n.key), n.keybit())
+		path = append(path, n)
+		dirs = append(dirs, bit)
+		n = &n.children[bit]
+	}
+	if n.isLeaf() && bytes.Equal(n.key, item) {
+		p.Path = t.path(item)
+		return p
+	}
+
+	// Item is not in t, and it is less than all the items under n or
+	// greater than all of them. Its other neighbor is in the nearest
+	// subtree to the other side.
+	var pred, succ *node
+	if bytes.Compare(item, leftmost(n).key) < 0 {
+		succ = leftmost(n)
+		for i := len(path) - 1; i >= 0; i-- {
+			if dirs[i] == 1 {
+				pred = rightmost(&path[i].children[0])
+				break
+			}
+		}
+	} else {
+		pred = rightmost(n)
+		for i := len(path) - 1; i >= 0; i-- {
+			if dirs[i] == 0 {
+				succ = leftmost(&path[i].children[1])
+				break
+			}
+		}
+	}
+	if pred != nil {
+		p.Pred = t.path(pred.key)
+	}
+	if succ != nil {
+		p.Succ = t.path(succ.key)
+	}
+	return p
+}
+
+// path returns the path to item, which must be in t.
+func (t *Tree) path(item []byte) *ProofPath {
+	p := &ProofPath{Item: item}
+	for n := t.root; !n.isLeaf(); {
+		bit := childIdx(item, len(n.key), n.keybit())
+		p.Dirs = append(p.Dirs, bit)
+		p.Siblings = append(p.Siblings, n.children[1-bit].Hash())
+		n = &n.children[bit]
+	}
+	return p
+}
+
+func leafHash(item []byte) [32]byte {
+	var hash [32]byte
+	h := sha3pool.Get256()
+	h.Write(leafPrefix)
+	h.Write(item)
+	io.ReadFull(h, hash[:])
+	sha3pool.Put256(h)
+	return hash
+}
+
+func interiorHash(left, right [32]byte) [32]byte {
+	var hash [32]byte
+	h := sha3pool.Get256()
+	h.Write(interiorPrefix)
+	h.Write(left[:])
+	h.Write(right[:])
+	io.ReadFull(h, hash[:])
+	sha3pool.Put256(h)
+	return hash
+}
+
+func leftmost(n *node) *node {
+	for !n.isLeaf() {
+		n = &n.children[0]
+	}
+	return n
+}
+
+func rightmost(n *node) *node {
+	for !n.isLeaf() {
+		n = &n.children[1]
+	}
+	return n
+}
+
+// bitLen returns the number of bits in n's key.
+func bitLen(n *node) int {
+	return 8*(len(n.key)-1) + int(n.keybit()) + 1
+}
+
+// root returns the root hash of the tree that path is in.
+func (path *ProofPath) root() [32]byte {
+	h := leafHash(path.Item)
+	for i := len(path.Dirs) - 1; i >= 0; i-- {
+		if path.Dirs[i] == 0 {
+			h = interiorHash(h, path.Siblings[i])
+		} else {
+			h = interiorHash(path.Siblings[i], h)
+		}
+	}
+	return h
+}
+
+// Verify checks that p holds for the tree with the given root hash.
+func (p *Proof) Verify(root [32]byte) error {
+	for _, path := range []*ProofPath{p.Path, p.Pred, p.Succ} {
+		if path == nil {
+			continue
+		}
+		if len(path.Dirs) != len(path.Siblings) {
+			return errors.WithDetail(ErrProof, "mismatched directions and siblings")
+		}
+		for _, d := range path.Dirs {
+			if d > 1 {
+				return errors.WithDetailf(ErrProof, "direction %d", d)
+			}
+		}
+		if path.root() != root {
+			return errors.WithDetailf(ErrProof, "path to %x does not lead to the root", path.Item)
+		}
+	}
+
+	if p.Path != nil {
+		if p.Pred != nil || p.Succ != nil {
+			return errors.WithDetail(ErrProof, "both inclusion and exclusion")
+		}
+		if !bytes.Equal(p.Path.Item, p.Item) {
+			return errors.WithDetail(ErrProof, "inclusion path to another item")
+		}
+		return nil
+	}
+
+	switch {
+	case p.Pred == nil && p.Succ == nil:
+		if root != ([32]byte{}) {
+			return errors.WithDetail(ErrProof, "no neighbors in a nonempty tree")
+		}
+		return nil
+	case p.Pred != nil && bytes.Compare(p.Pred.Item, p.Item) >= 0:
+		return errors.WithDetail(ErrProof, "predecessor not less than item")
+	case p.Succ != nil && bytes.Compare(p.Succ.Item, p.Item) <= 0:
+		return errors.WithDetail(ErrProof, "successor not greater than item")
+	case p.Succ == nil:
+		if !constDirs(p.Pred.Dirs, 1) {
+			return errors.WithDetail(ErrProof, "predecessor not the last leaf")
+		}
+		return nil
+	case p.Pred == nil:
+		if !constDirs(p.Succ.Dirs, 0) {
+			return errors.WithDetail(ErrProof, "successor not the first leaf")
+		}
+		return nil
+	}
+
+	// The paths must part at some node, the predecessor's going left
+	// and then always right, the successor's going right and then
+	// always left.
+	pred, succ := p.Pred, p.Succ
+	j := commonLen(pred.Dirs, succ.Dirs)
+	if j >= len(pred.Dirs) || j >= len(succ.Dirs) || pred.Dirs[j] != 0 ||
+		!constDirs(pred.Dirs[j+1:], 1) || !constDirs(succ.Dirs[j+1:], 0) {
+		return errors.WithDetail(ErrProof, "neighbors not adjacent")
+	}
+	for i := 0; i < j; i++ {
+		if pred.Siblings[i] != succ.Siblings[i] {
+			return errors.WithDetail(ErrProof, "neighbors not adjacent")
+		}
+	}
+	return nil
+}
+
+func constDirs(dirs []byte, d byte) bool {
+	for _, x := range dirs {
+		if x != d {
+			return false
+		}
+	}
+	return true
+}
+
+// commonLen returns the length of the common prefix of a and b.
+func commonLen(a, b []byte) int {
+	var n int
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// Proof encoding flags
+const (
+	proofIncluded = 1 << iota
+	proofPred
+	proofSucc
+)
+
+// Bytes returns the encoding of p, of version ProofVersion.
+func (p *Proof) Bytes() []byte {
+	var flags byte
+	var paths []*ProofPath
+	if p.Path != nil {
+		flags |= proofIncluded
+		paths = append(paths, p.Path)
+	}
+	if p.Pred != nil {
+		flags |= proofPred
+		paths = append(paths, p.Pred)
+	}
+	if p.Succ != nil {
+		flags |= proofSucc
+		paths = append(paths, p.Succ)
+	}
+	b := []byte{ProofVersion, flags}
+	b = appendUvarint(b, uint64(len(p.Item)))
+	b = append(b, p.Item...)
+	for i, path := range paths {
+		prefix := commonLen(path.Item, p.Item)
+		b = appendUvarint(b, uint64(prefix))
+		b = appendUvarint(b, uint64(len(path.Item)-prefix))
+		b = append(b, path.Item[prefix:]...)
+
+		b = appendUvarint(b, uint64(len(path.Dirs)))
+		bitmap := make([]byte, (len(path.Dirs)+7)/8)
+		for j, d := range path.Dirs {
+			bitmap[j/8] |= d << uint(7-j%8)
+		}
+		b = append(b, bitmap...)
+
+		var shared int
+		if i > 0 {
+			shared = sharedSiblings(paths[0], path)
+		}
+		b = appendUvarint(b, uint64(shared))
+		for _, h := range path.Siblings[shared:] {
+			b = append(b, h[:]...)
+		}
+	}
+	return b
+}
+
+// sharedSiblings returns the number of levels, from the root, at which
+// paths a and b take the same direction and have the same sibling.
+func sharedSiblings(a, b *ProofPath) int {
+	n := commonLen(a.Dirs, b.Dirs)
+	for i := 0; i < n; i++ {
+		if a.Siblings[i] != b.Siblings[i] {
+			return i
+		}
+	}
+	return n
+}
+
+func appendUvarint(b []byte, x uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], x)]...)
+}
+
+// ParseProof parses the encoding of a proof. It does not check that
+// the proof holds; for that, see Verify.
+func ParseProof(b []byte) (*Proof, error) {
+	d := proofDecoder{b: b}
+	version := d.byte()
+	if d.err == nil && version != ProofVersion {
+		return nil, errors.WithDetailf(ErrProof, "unknown version %d", version)
+	}
+	flags := d.byte()
+	if d.err == nil && (flags > proofIncluded|proofPred|proofSucc || flags&proofIncluded != 0 && flags != proofIncluded) {
+		return nil, errors.WithDetailf(ErrProof, "flags %#x", flags)
+	}
+	p := &Proof{Item: d.bytes(d.uvarint())}
+	var paths []*ProofPath
+	for _, field := range []struct {
+		flag byte
+		path **ProofPath
+	}{{proofIncluded, &p.Path}, {proofPred, &p.Pred}, {proofSucc, &p.Succ}} {
+		if flags&field.flag == 0 {
+			continue
+		}
+		path := new(ProofPath)
+		prefix := d.uvarint()
+		if d.err == nil && prefix > uint64(len(p.Item)) {
+			return nil, errors.WithDetailf(ErrProof, "leaf prefix %d of %d-byte item", prefix, len(p.Item))
+		}
+		suffix := d.bytes(d.uvarint())
+		if d.err != nil {
+			break
+		}
+		path.Item = append(append([]byte(nil), p.Item[:prefix]...), suffix...)
+		if field.flag == proofIncluded && !bytes.Equal(path.Item, p.Item) {
+			return nil, errors.WithDetail(ErrProof, "inclusion path to another item")
+		}
+
+		depth := d.uvarint()
+		// Each level of a tree consumes at least one bit of its
+		// items.
+		if d.err == nil && depth > 8*uint64(len(path.Item)) {
+			return nil, errors.WithDetailf(ErrProof, "depth %d for a %d-byte leaf", depth, len(path.Item))
+		}
+		bitmap := d.bytes((depth + 7) / 8)
+		if d.err != nil {
+			break
+		}
+		path.Dirs = make([]byte, depth)
+		for j := range path.Dirs {
+			path.Dirs[j] = bitmap[j/8] >> uint(7-j%8) & 1
+		}
+		if depth%8 != 0 && bitmap[len(bitmap)-1]<<uint(depth%8) != 0 {
+			return nil, errors.WithDetail(ErrProof, "nonzero padding bits")
+		}
+
+		shared := d.uvarint()
+		if d.err == nil && shared > 0 && (len(paths) == 0 || shared > uint64(commonLen(paths[0].Dirs, path.Dirs))) {
+			return nil, errors.WithDetailf(ErrProof, "%d shared siblings", shared)
+		}
+		if d.err != nil {
+			break
+		}
+		path.Siblings = make([][32]byte, depth)
+		if shared > 0 {
+			copy(path.Siblings, paths[0].Siblings[:shared])
+		}
+		for j := shared; j < depth && d.err == nil; j++ {
+			copy(path.Siblings[j][:], d.bytes(32))
+		}
+		paths = append(paths, path)
+		*field.path = path
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if len(d.b) > 0 {
+		return nil, errors.WithDetailf(ErrProof, "%d trailing bytes", len(d.b))
+	}
+	return p, nil
+}
+
+// proofDecoder reads the parts of an encoded proof, recording the
+// first error.
+type proofDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *proofDecoder) byte() byte {
+	b := d.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (d *proofDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errors.WithDetail(ErrProof, "bad varint")
+		return 0
+	}
+	d.b = d.b[n:]
+	return x
+}
+
+func (d *proofDecoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.b)) {
+		d.err = errors.WithDetail(ErrProof, "truncated")
+		return nil
+	}
+	b := d.b[:n:n]
+	d.b = d.b[n:]
+	return b
+}
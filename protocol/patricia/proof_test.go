@@ -0,0 +1,141 @@
+package patricia
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"i10r.io/crypto/sha3"
+	"i10r.io/errors"
+)
+
+// The vectors in testdata/proofs.json are for other implementations
+// of the proof encoding, too. Each tree lists its items, its root
+// hash, and the encoded proof for each of a set of items; each
+// invalid entry is a proof that must not verify against its root.
+type proofVectors struct {
+	Version int `json:"version"`
+	Trees   []struct {
+		Items  []string `json:"items"`
+		Root   string   `json:"root"`
+		Proofs []struct {
+			Item     string `json:"item"`
+			Included bool   `json:"included"`
+			Proof    string `json:"proof"`
+		} `json:"proofs"`
+	} `json:"trees"`
+	Invalid []struct {
+		Root   string `json:"root"`
+		Proof  string `json:"proof"`
+		Reason string `json:"reason"`
+	} `json:"invalid"`
+}
+
+func TestProofVectors(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/proofs.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v proofVectors
+	err = json.Unmarshal(b, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != ProofVersion {
+		t.Fatalf("vectors version %d, want %d", v.Version, ProofVersion)
+	}
+
+	for i, vt := range v.Trees {
+		tree := new(Tree)
+		for _, item := range vt.Items {
+			tree.Insert(mustDecodeHex(item))
+		}
+		root := tree.RootHash()
+		if hex.EncodeToString(root[:]) != vt.Root {
+			t.Errorf("tree %d: root %x, want %s", i, root, vt.Root)
+			continue
+		}
+		for _, vp := range vt.Proofs {
+			item, enc := mustDecodeHex(vp.Item), mustDecodeHex(vp.Proof)
+			if got := tree.Prove(item).Bytes(); !bytes.Equal(got, enc) {
+				t.Errorf("tree %d: Prove(%s).Bytes() = %x, want %s", i, vp.Item, got, vp.Proof)
+			}
+			p, err := ParseProof(enc)
+			if err != nil {
+				t.Errorf("tree %d: parsing proof for %s: %s", i, vp.Item, err)
+				continue
+			}
+			if !bytes.Equal(p.Item, item) || p.Included() != vp.Included {
+				t.Errorf("tree %d: parsed proof for %x, included %v; want %s, %v", i, p.Item, p.Included(), vp.Item, vp.Included)
+			}
+			if err := p.Verify(root); err != nil {
+				t.Errorf("tree %d: verifying proof for %s: %s", i, vp.Item, err)
+			}
+		}
+	}
+
+	for _, vi := range v.Invalid {
+		var root [32]byte
+		copy(root[:], mustDecodeHex(vi.Root))
+		p, err := ParseProof(mustDecodeHex(vi.Proof))
+		if err == nil {
+			err = p.Verify(root)
+		}
+		if errors.Root(err) != ErrProof {
+			t.Errorf("%s: got error %v, want %v", vi.Reason, err, ErrProof)
+		}
+	}
+}
+
+func TestProofRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for n := 0; n < 40; n++ {
+		tree := new(Tree)
+		var items [][]byte
+		for i := 0; i < n; i++ {
+			h := sha3.Sum256([]byte(fmt.Sprintf("%d %d", n, i)))
+			tree.Insert(h[:])
+			items = append(items, h[:])
+		}
+		root := tree.RootHash()
+		for j := 0; j < 10; j++ {
+			var item []byte
+			if n > 0 && j%2 == 0 {
+				item = items[r.Intn(n)]
+			} else {
+				item = make([]byte, 32)
+				r.Read(item)
+			}
+			p := tree.Prove(item)
+			if p.Included() != tree.Contains(item) {
+				t.Errorf("%d items: proof for %x included %v", n, item, p.Included())
+			}
+			p2, err := ParseProof(p.Bytes())
+			if err != nil {
+				t.Fatalf("%d items: parsing proof for %x: %s", n, item, err)
+			}
+			if err := p2.Verify(root); err != nil {
+				t.Errorf("%d items: verifying proof for %x: %s", n, item, err)
+			}
+			if n > 0 {
+				var other [32]byte
+				r.Read(other[:])
+				if p2.Verify(other) == nil {
+					t.Errorf("%d items: proof for %x verified against a random root", n, item)
+				}
+			}
+		}
+	}
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
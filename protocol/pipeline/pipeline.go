@@ -0,0 +1,353 @@
+/*
+Package pipeline applies a run of stored blocks to a state snapshot
+in stages that overlap, so that the time spent reading each block
+is hidden behind the time spent validating the ones before it.
+
+Applying a block has four stages:
+
+  - decode: fetch the block's encoding and parse it, without yet
+    running its transactions (see bc.DecodeRawBlock);
+  - check: validate the block's header and its signatures against
+    the previous block's header;
+  - execute: run the transactions' programs (see bc.RawBlock.Block);
+  - apply: update the state's trees and check them against the
+    block's declared roots.
+
+Decode and execute depend on nothing but the block, and run for
+several blocks at once. Check depends only on the previous block's
+header, which is known once that block is decoded, so it runs ahead
+of execution. Only apply depends on the state, and runs in order.
+
+Between execute and apply, the contracts each block spends are
+looked up early in the most recently applied state, which the apply
+stage shares as it goes (see state.Snapshot for why it may). A
+contract created by one of the blocks still in flight cannot be in
+that state yet, and its lookup waits for the apply stage; the rest
+are prefetched, so that a tree that must read its nodes from disk
+has them by the time they are needed, and a block spending a
+contract that exists nowhere fails without waiting its turn.
+*/
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"i10r.io/errors"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/fee"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/validation"
+)
+
+// ErrMissingInput is the root of the error for a block spending a
+// contract in neither the state nor a block before it.
+var ErrMissingInput = errors.New("block spends a contract not in the state")
+
+// An Applier applies blocks, fetched with Fetch, to a state.
+type Applier struct {
+	// Fetch returns the encoding of the block at the given height.
+	// It is called for several heights at once. See
+	// filestore.Store.BlockBytes.
+	Fetch func(ctx context.Context, height uint64) ([]byte, error)
+
+	// Depth is the number of blocks in flight at once, and Workers
+	// the number of them fetched, decoded, or executed at once.
+	// Zero values mean 8 and runtime.GOMAXPROCS(0).
+	Depth, Workers int
+
+	// Plugins are the plugin operations available to the blocks'
+	// transactions (see protocol.Chain.SetPlugins).
+	Plugins *txvm.Plugins
+
+	// SkipSignatures disables checking each block's signatures
+	// against the previous block's predicate.
+	SkipSignatures bool
+
+	// Commit, if set, is called in order with each block applied
+	// and the state after it. If it returns an error, Apply stops.
+	// Apply checks the blocks themselves, but not against a chain's
+	// network, clock, or checkpoints; protocol.Chain.CommitAppliedBlock
+	// does.
+	Commit func(ctx context.Context, b *bc.Block, snapshot *state.Snapshot) error
+}
+
+// job is one block in flight. Its worker sets raw or decodeErr
+// before closing decoded, and block or execErr before closing
+// executed. Err belongs to the ordered stages, each of which sets it
+// before passing the job on.
+type job struct {
+	height uint64
+
+	raw       *bc.RawBlock
+	decodeErr error
+	decoded   chan struct{}
+
+	block    *bc.Block
+	execErr  error
+	executed chan struct{}
+
+	err error
+}
+
+// Apply applies the blocks after snapshot, through height to, and
+// returns the resulting state. Snapshot itself is not changed, and
+// must be frozen. If a block cannot be applied, Apply returns the
+// state as of the block before it along with the error.
+func (a *Applier) Apply(ctx context.Context, snapshot *state.Snapshot, to uint64) (*state.Snapshot, error) {
+	depth, workers := a.Depth, a.Workers
+	if depth <= 0 {
+		depth = 8
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	var latest atomic.Value // the latest applied *state.Snapshot
+	latest.Store(snapshot)
+
+	var (
+		jobs     = make(chan *job, depth)
+		checked  = make(chan *job, depth)
+		resolved = make(chan *job, depth)
+		sem      = make(chan struct{}, workers)
+	)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for h := snapshot.Height() + 1; h <= to; h++ {
+			j := &job{height: h, decoded: make(chan struct{}), executed: make(chan struct{})}
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				a.decodeAndExecute(ctx, j, sem)
+			}()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		defer close(checked)
+		a.check(ctx, snapshot.Header, jobs, checked)
+	}()
+	go func() {
+		defer wg.Done()
+		defer close(resolved)
+		prefetch(ctx, snapshot.Height(), &latest, checked, resolved)
+	}()
+
+	for j := range resolved {
+		if j.err != nil {
+			return snapshot, j.err
+		}
+		next, err := apply(snapshot, j.block)
+		if err != nil {
+			return snapshot, errors.Wrapf(err, "applying block %d", j.height)
+		}
+		if a.Commit != nil {
+			err = a.Commit(ctx, j.block, next)
+			if err != nil {
+				return snapshot, errors.Wrapf(err, "committing block %d", j.height)
+			}
+		}
+		snapshot = next
+		latest.Store(snapshot)
+	}
+	if err := ctx.Err(); err != nil && snapshot.Height() < to {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+// decodeAndExecute is the decode and execute stages for j. It holds
+// a place in sem while it works.
+func (a *Applier) decodeAndExecute(ctx context.Context, j *job, sem chan struct{}) {
+	defer close(j.executed)
+	acquire := func() error {
+		select {
+		case sem <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	err := acquire()
+	if err == nil {
+		var bits []byte
+		bits, err = a.Fetch(ctx, j.height)
+		if err == nil {
+			j.raw, err = bc.DecodeRawBlock(bits)
+		}
+		<-sem
+	}
+	if err != nil {
+		j.decodeErr = errors.Wrapf(err, "decoding block %d", j.height)
+		close(j.decoded)
+		return
+	}
+	close(j.decoded)
+
+	err = acquire()
+	if err == nil {
+		j.block, err = j.raw.Block(ctx, txvm.WithPlugins(a.Plugins))
+		<-sem
+	}
+	if err != nil {
+		j.execErr = errors.Wrapf(err, "executing block %d", j.height)
+	}
+}
+
+// check is the check stage. It validates, in order, each block's
+// header against the one before it, as soon as the block is decoded.
+func (a *Applier) check(ctx context.Context, prev *bc.BlockHeader, in <-chan *job, out chan<- *job) {
+	for j := range in {
+		select {
+		case <-j.decoded:
+		case <-ctx.Done():
+			return
+		}
+		j.err = j.decodeErr
+		if j.err == nil && prev == nil && j.height > 1 {
+			j.err = errors.Wrapf(errors.New("no previous block"), "checking block %d", j.height)
+		}
+		if j.err == nil && prev != nil {
+			hdr := &bc.Block{
+				UnsignedBlock: &bc.UnsignedBlock{BlockHeader: j.raw.Header},
+				Arguments:     j.raw.BlockArguments(),
+			}
+			err := validation.BlockPrev(hdr.UnsignedBlock, prev)
+			if err == nil && !a.SkipSignatures {
+				err = validation.BlockSig(hdr, prev.NextPredicate)
+			}
+			if err != nil {
+				j.err = errors.Wrapf(err, "checking block %d", j.height)
+			}
+		}
+		if j.err == nil {
+			prev = j.raw.Header
+		}
+		failed := j.err != nil
+		select {
+		case out <- j:
+		case <-ctx.Done():
+			return
+		}
+		if failed {
+			return // blocks after j cannot be checked
+		}
+	}
+}
+
+// prefetch is the stage between execute and apply. It finishes
+// validating each block, then looks up the contracts it spends in
+// the latest applied state, except for those created by blocks
+// since, on which the block depends.
+func prefetch(ctx context.Context, height uint64, latest *atomic.Value, in <-chan *job, out chan<- *job) {
+	// created holds the contracts created by each block in flight
+	// after height.
+	created := make(map[uint64]map[bc.Hash]bool)
+	for j := range in {
+		select {
+		case <-j.executed:
+		case <-ctx.Done():
+			return
+		}
+		if j.err == nil {
+			j.err = j.execErr
+		}
+		if j.err == nil {
+			err := validation.BlockOnly(j.block.UnsignedBlock)
+			if err != nil {
+				j.err = errors.Wrapf(err, "validating block %d", j.height)
+			}
+		}
+		if j.err == nil {
+			snapshot := latest.Load().(*state.Snapshot)
+			for ; height < snapshot.Height(); height++ {
+				delete(created, height+1)
+			}
+			j.err = lookup(j.block, snapshot, created)
+			created[j.height] = outputs(j.block)
+		}
+		failed := j.err != nil
+		select {
+		case out <- j:
+		case <-ctx.Done():
+			return
+		}
+		if failed {
+			return
+		}
+	}
+}
+
+// lookup looks up in snapshot each contract b spends that no block
+// in created creates.
+func lookup(b *bc.Block, snapshot *state.Snapshot, created map[uint64]map[bc.Hash]bool) error {
+	for _, tx := range b.Transactions {
+	contracts:
+		for _, con := range tx.Contracts {
+			if con.Type != bc.InputType {
+				continue
+			}
+			for _, ids := range created {
+				if ids[con.ID] {
+					continue contracts // depends on a block in flight
+				}
+			}
+			if _, ok := snapshot.Contract(con.ID); !ok {
+				return errors.WithDetailf(ErrMissingInput, "block %d, contract %x", b.Height, con.ID.Bytes())
+			}
+		}
+	}
+	return nil
+}
+
+// outputs returns the IDs of the contracts b creates.
+func outputs(b *bc.Block) map[bc.Hash]bool {
+	ids := make(map[bc.Hash]bool)
+	for _, tx := range b.Transactions {
+		for _, con := range tx.Contracts {
+			if con.Type == bc.OutputType {
+				ids[con.ID] = true
+			}
+		}
+	}
+	outs, _ := fee.Outputs(b.UnsignedBlock) // an error is reported when b is applied
+	for _, out := range outs {
+		ids[out.ID] = true
+	}
+	return ids
+}
+
+// apply is the apply stage for b.
+func apply(prev *state.Snapshot, b *bc.Block) (*state.Snapshot, error) {
+	snapshot := state.Copy(prev)
+	err := snapshot.ApplyBlock(b.UnsignedBlock)
+	if err != nil {
+		return nil, err
+	}
+	if b.ContractsRoot == nil || b.ContractsRoot.Byte32() != snapshot.ContractsTree.RootHash() {
+		return nil, protocol.ErrBadContractsRoot
+	}
+	if b.NoncesRoot == nil || b.NoncesRoot.Byte32() != snapshot.NonceTree.RootHash() {
+		return nil, protocol.ErrBadNoncesRoot
+	}
+	snapshot.Freeze()
+	return snapshot, nil
+}
@@ -0,0 +1,175 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/prottest/memstore"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txgen"
+	"i10r.io/testutil"
+)
+
+func newTestChain(t *testing.T, blocks int) (*memstore.MemStore, *state.Snapshot) {
+	store := memstore.New()
+	c := prottest.NewChain(t, prottest.WithStore(store))
+	g := txgen.New(1)
+	for i := 0; i < blocks; i++ {
+		txs, err := g.Txs(c.State(), time.Now(), 4)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		prottest.MakeBlock(t, c, txs)
+	}
+	return store, c.State()
+}
+
+func fetcher(store *memstore.MemStore) func(context.Context, uint64) ([]byte, error) {
+	return func(_ context.Context, height uint64) ([]byte, error) {
+		return store.Blocks[height].Bytes()
+	}
+}
+
+func initial(t *testing.T, store *memstore.MemStore) *state.Snapshot {
+	s := state.Empty()
+	err := s.ApplyBlock(store.Blocks[1].UnsignedBlock)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	s.Freeze()
+	return s
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	store, want := newTestChain(t, 20)
+	height := uint64(len(store.Blocks))
+
+	for _, depth := range []int{1, 3, 0} {
+		var committed []uint64
+		a := &Applier{
+			Fetch: fetcher(store),
+			Depth: depth,
+			Commit: func(_ context.Context, b *bc.Block, s *state.Snapshot) error {
+				if s.Height() != b.Height {
+					t.Errorf("committed block %d with state at height %d", b.Height, s.Height())
+				}
+				committed = append(committed, b.Height)
+				return nil
+			},
+		}
+		got, err := a.Apply(ctx, initial(t, store), height)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		if got.Height() != height {
+			t.Errorf("depth %d: applied through %d, want %d", depth, got.Height(), height)
+		}
+		if got.ContractsTree.RootHash() != want.ContractsTree.RootHash() {
+			t.Errorf("depth %d: contracts root differs from the chain's", depth)
+		}
+		for i, h := range committed {
+			if h != uint64(i+2) {
+				t.Fatalf("depth %d: committed %v, want 2 through %d in order", depth, committed, height)
+			}
+		}
+	}
+}
+
+func TestApplyChain(t *testing.T) {
+	ctx := context.Background()
+	store, want := newTestChain(t, 10)
+	height := uint64(len(store.Blocks))
+
+	// A chain following the store commits each block as it is applied.
+	c, err := protocol.NewChain(ctx, store.Blocks[1], memstore.New(), nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = c.CommitAppliedBlock(ctx, store.Blocks[1], initial(t, store))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	a := &Applier{Fetch: fetcher(store), Commit: c.CommitAppliedBlock}
+	_, err = a.Apply(ctx, c.State(), height)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if c.Height() != height || c.State().ContractsTree.RootHash() != want.ContractsTree.RootHash() {
+		t.Errorf("chain at height %d, want %d with the store's state", c.Height(), height)
+	}
+}
+
+func TestApplyError(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestChain(t, 10)
+	height := uint64(len(store.Blocks))
+
+	// An error in block 5 is reported, with the state as of block 4,
+	// even though a later block fails too, and may fail first.
+	fetch := fetcher(store)
+	a := &Applier{
+		Fetch: func(ctx context.Context, h uint64) ([]byte, error) {
+			if h == 5 || h == 8 {
+				return []byte{0xff}, nil
+			}
+			return fetch(ctx, h)
+		},
+	}
+	got, err := a.Apply(ctx, initial(t, store), height)
+	if err == nil {
+		t.Fatal("got no error applying a bad block")
+	}
+	if got.Height() != 4 {
+		t.Errorf("returned state at height %d, want 4", got.Height())
+	}
+
+	// A block that does not follow the one before it fails the check
+	// stage.
+	a.Fetch = func(ctx context.Context, h uint64) ([]byte, error) {
+		if h == 6 {
+			return fetch(ctx, 7)
+		}
+		return fetch(ctx, h)
+	}
+	got, err = a.Apply(ctx, initial(t, store), height)
+	if err == nil {
+		t.Fatal("got no error applying blocks out of order")
+	}
+	if got.Height() != 5 {
+		t.Errorf("returned state at height %d, want 5", got.Height())
+	}
+}
+
+func TestLookup(t *testing.T) {
+	store, _ := newTestChain(t, 3)
+	s := initial(t, store)
+	b := store.Blocks[3]
+
+	var spent bc.Hash
+	for _, tx := range b.Transactions {
+		for _, con := range tx.Contracts {
+			if con.Type == bc.InputType {
+				spent = con.ID
+			}
+		}
+	}
+	if spent.IsZero() {
+		t.Skip("block 3 spends nothing")
+	}
+
+	created := map[uint64]map[bc.Hash]bool{2: outputs(store.Blocks[2])}
+	err := lookup(b, s, created)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	err = lookup(b, s, nil)
+	if errors.Root(err) != ErrMissingInput {
+		t.Errorf("got error %v, want %v", err, ErrMissingInput)
+	}
+}
@@ -84,7 +84,9 @@ import (
 	"i10r.io/errors"
 	"i10r.io/log"
 	"i10r.io/protocol/bc"
+	"i10r.io/protocol/checkpoint"
 	"i10r.io/protocol/state"
+	"i10r.io/protocol/txvm"
 )
 
 const defaultBlocksPerSnapshot = uint64(100)
@@ -113,6 +115,12 @@ type Store interface {
 type Chain struct {
 	InitialBlockHash bc.Hash
 	bb               *BlockBuilder
+	finality         *checkpoint.Finality
+	plugins          *txvm.Plugins
+	timePolicy       TimePolicy
+
+	slotMu    sync.Mutex
+	slotStats SlotStats
 
 	state struct {
 		cond     sync.Cond // protects height, block, snapshot
@@ -186,6 +194,10 @@ func (c *Chain) Height() uint64 {
 // State returns the most recent state available. It will not be current
 // unless the current process is the leader. Callers should examine the
 // returned state header's height if they need to verify the current state.
+//
+// The returned state is frozen (see state.Snapshot) and may be read
+// concurrently with the application of later blocks. Callers must
+// not change it; to apply to it, use state.Copy.
 func (c *Chain) State() *state.Snapshot {
 	c.state.cond.L.Lock()
 	defer c.state.cond.L.Unlock()
@@ -0,0 +1,348 @@
+package remotesigner
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/sha3"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+)
+
+// The HTTP API has three endpoints, all POST and all exchanging
+// JSON. Headers, templates, and signatures travel in their protobuf
+// encodings, as hex.
+//
+//   POST /public-key    {"key": ...} -> {"pubkey": ...}
+//   POST /sign-block    {"key": ..., "header": <BlockHeader>} -> {"signature": ...}
+//   POST /sign-template {"request": <SignRequest>} -> {"response": <SignResponse>}
+//
+// Every request carries four headers:
+//
+//   X-Signer-Client     the client's public key, in hex
+//   X-Signer-Time       the time of the request, in milliseconds
+//   X-Signer-Nonce      16 random bytes, in hex
+//   X-Signer-Signature  the client's signature, in hex, of the
+//                       SHA3-256 hash of "RemoteSigner", the path,
+//                       the time as 8 big-endian bytes, the nonce,
+//                       and the body
+//
+// The server refuses a request whose time is more than MaxSkew from
+// its own, and one whose signature it has already seen.
+
+// MaxSkew is how far the time of a request may be from the server's.
+const MaxSkew = 30 * time.Second
+
+const nonceSize = 16
+
+const (
+	headerClient    = "X-Signer-Client"
+	headerTime      = "X-Signer-Time"
+	headerNonce     = "X-Signer-Nonce"
+	headerSignature = "X-Signer-Signature"
+)
+
+type keyRequest struct {
+	Key    string             `json:"key"`
+	Header chainjson.HexBytes `json:"header,omitempty"`
+}
+
+type keyResponse struct {
+	Pubkey chainjson.HexBytes `json:"pubkey"`
+}
+
+type blockResponse struct {
+	Signature chainjson.HexBytes `json:"signature"`
+}
+
+type templateRequest struct {
+	Request chainjson.HexBytes `json:"request"`
+}
+
+type templateResponse struct {
+	Response chainjson.HexBytes `json:"response"`
+}
+
+// requestHash returns the hash a client signs to authenticate a
+// request.
+func requestHash(path string, timeMS uint64, nonce, body []byte) []byte {
+	h := sha3.New256()
+	h.Write([]byte("RemoteSigner"))
+	h.Write([]byte(path))
+	var t [8]byte
+	binary.BigEndian.PutUint64(t[:], timeMS)
+	h.Write(t[:])
+	h.Write(nonce)
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Handler returns an HTTP handler serving the calls of s to
+// authenticated clients.
+func Handler(s *Server) http.Handler {
+	a := &authenticator{seen: make(map[string]time.Time)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/public-key", func(w http.ResponseWriter, req *http.Request) {
+		var kr keyRequest
+		client, ok := a.decode(w, req, &kr)
+		if !ok {
+			return
+		}
+		pub, err := s.PublicKey(client, kr.Key)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, keyResponse{Pubkey: chainjson.HexBytes(pub)})
+	})
+	mux.HandleFunc("/sign-block", func(w http.ResponseWriter, req *http.Request) {
+		var kr keyRequest
+		client, ok := a.decode(w, req, &kr)
+		if !ok {
+			return
+		}
+		var h bc.BlockHeader
+		err := proto.Unmarshal(kr.Header, &h)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sig, err := s.SignBlock(req.Context(), client, kr.Key, &h)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, blockResponse{Signature: sig})
+	})
+	mux.HandleFunc("/sign-template", func(w http.ResponseWriter, req *http.Request) {
+		var tr templateRequest
+		client, ok := a.decode(w, req, &tr)
+		if !ok {
+			return
+		}
+		var sr txbuilder.SignRequest
+		err := proto.Unmarshal(tr.Request, &sr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp, err := s.SignTemplate(req.Context(), client, &sr)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		b, err := proto.Marshal(resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, templateResponse{Response: b})
+	})
+	return mux
+}
+
+// authenticator checks the headers of requests, remembering the
+// signatures it has seen for as long as they are fresh.
+type authenticator struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // expiry, by signature
+}
+
+// decode authenticates a POST request, decodes its body into v, and
+// returns the client's key. If it fails, it writes the error to w and
+// returns false.
+func (a *authenticator) decode(w http.ResponseWriter, req *http.Request, v interface{}) (ed25519.PublicKey, bool) {
+	if req.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	client, err := a.check(req, body, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	err = json.Unmarshal(body, v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	return client, true
+}
+
+func (a *authenticator) check(req *http.Request, body []byte, now time.Time) (ed25519.PublicKey, error) {
+	client, err := hex.DecodeString(req.Header.Get(headerClient))
+	if err != nil || len(client) != ed25519.PublicKeySize {
+		return nil, errors.WithDetail(ErrAuth, "bad client key")
+	}
+	sig, err := hex.DecodeString(req.Header.Get(headerSignature))
+	if err != nil {
+		return nil, errors.WithDetail(ErrAuth, "bad signature")
+	}
+	nonce, err := hex.DecodeString(req.Header.Get(headerNonce))
+	if err != nil || len(nonce) != nonceSize {
+		return nil, errors.WithDetail(ErrAuth, "bad nonce")
+	}
+	timeMS, err := strconv.ParseUint(req.Header.Get(headerTime), 10, 64)
+	if err != nil {
+		return nil, errors.WithDetail(ErrAuth, "bad time")
+	}
+	t := bc.FromMillis(timeMS)
+	if t.Before(now.Add(-MaxSkew)) || t.After(now.Add(MaxSkew)) {
+		return nil, errors.WithDetailf(ErrAuth, "time %s is too far from %s", t, now)
+	}
+	if !ed25519.Verify(client, requestHash(req.URL.Path, timeMS, nonce, body), sig) {
+		return nil, errors.WithDetail(ErrAuth, "bad signature")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for s, exp := range a.seen {
+		if now.After(exp) {
+			delete(a.seen, s)
+		}
+	}
+	if _, ok := a.seen[string(sig)]; ok {
+		return nil, errors.WithDetail(ErrAuth, "replayed request")
+	}
+	a.seen[string(sig)] = t.Add(MaxSkew)
+	return client, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch errors.Root(err) {
+	case ErrAuth:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case ErrNotAllowed:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case ErrDoubleSign:
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// Client calls a signer served by Handler at URL, authenticating
+// with Key.
+type Client struct {
+	URL    string
+	Key    ed25519.PrivateKey
+	Client *http.Client // if nil, http.DefaultClient is used
+}
+
+// PublicKey returns the public key of the named key.
+func (c *Client) PublicKey(ctx context.Context, name string) (ed25519.PublicKey, error) {
+	var kr keyResponse
+	err := c.post(ctx, "/public-key", keyRequest{Key: name}, &kr)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.PublicKey(kr.Pubkey), nil
+}
+
+// SignBlock returns the named block key's signature of the block
+// with header h.
+func (c *Client) SignBlock(ctx context.Context, name string, h *bc.BlockHeader) ([]byte, error) {
+	b, err := proto.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	var br blockResponse
+	err = c.post(ctx, "/sign-block", keyRequest{Key: name, Header: b}, &br)
+	if err != nil {
+		return nil, err
+	}
+	return br.Signature, nil
+}
+
+// SignTemplate adds to tpl the signatures the signer makes for it.
+func (c *Client) SignTemplate(ctx context.Context, tpl *txbuilder.Template) error {
+	b, err := proto.Marshal(&txbuilder.SignRequest{Template: tpl.Raw()})
+	if err != nil {
+		return err
+	}
+	var tr templateResponse
+	err = c.post(ctx, "/sign-template", templateRequest{Request: b}, &tr)
+	if err != nil {
+		return err
+	}
+	var resp txbuilder.SignResponse
+	err = proto.Unmarshal(tr.Response, &resp)
+	if err != nil {
+		return errors.Wrap(err, "decoding signatures")
+	}
+	return tpl.AddSignatures(resp.Signatures)
+}
+
+func (c *Client) post(ctx context.Context, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", c.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timeMS := bc.Millis(time.Now())
+	nonce := make([]byte, nonceSize)
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerClient, hex.EncodeToString(c.Key.Public().(ed25519.PublicKey)))
+	req.Header.Set(headerTime, strconv.FormatUint(timeMS, 10))
+	req.Header.Set(headerNonce, hex.EncodeToString(nonce))
+	req.Header.Set(headerSignature, hex.EncodeToString(ed25519.Sign(c.Key, requestHash(path, timeMS, nonce, body))))
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	msg := string(bytes.TrimSpace(bits))
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return errors.WithDetail(ErrAuth, msg)
+	case http.StatusForbidden:
+		return errors.WithDetail(ErrNotAllowed, msg)
+	case http.StatusConflict:
+		return errors.WithDetail(ErrDoubleSign, msg)
+	default:
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL, resp.Status, msg)
+	}
+	return errors.Wrapf(json.Unmarshal(bits, out), "decoding %s", req.URL)
+}
@@ -0,0 +1,288 @@
+// Package remotesigner lets a node sign blocks and transactions with
+// keys held by a separate signing process, on a separate host if
+// need be, so that the node, which faces the network, never holds
+// them.
+//
+// A Server holds the keys, in a keystore, and the policy for each
+// client allowed to use them. Clients authenticate each request with
+// an Ed25519 key of their own (see Handler). The server decides what
+// to sign from the block header or transaction template itself, not
+// from a message the client computed, so a compromised node can get
+// no more than its policy allows:
+//
+//   - block keys sign at most one block at each height, at heights
+//     that only increase, which a client cannot reverse;
+//   - transaction keys sign only templates the client's
+//     signpolicy engine, if it has one, allows.
+package remotesigner
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/ed25519/chainkd"
+	"i10r.io/crypto/keystore"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/signpolicy"
+)
+
+// Errors
+var (
+	ErrAuth       = errors.New("client not authenticated")
+	ErrNotAllowed = errors.New("not allowed by signer policy")
+	ErrDoubleSign = errors.New("block height already signed")
+)
+
+// Keys are the keys a Server signs with. A keystore.Keystore is
+// Keys.
+type Keys interface {
+	keystore.Signer
+	PublicKey(name string) (ed25519.PublicKey, error)
+	XPub(name string) (chainkd.XPub, error)
+}
+
+// Policy is what one client may sign.
+type Policy struct {
+	// Pubkey is the client's key, with which it authenticates its
+	// requests.
+	Pubkey ed25519.PublicKey
+
+	// BlockKeys name the keys the client may sign blocks with.
+	BlockKeys []string
+
+	// TxKeys name the keys the client may sign templates with.
+	TxKeys []string
+
+	// Engine, if not nil, checks templates before TxKeys sign them.
+	Engine *signpolicy.Engine
+}
+
+// Server signs, for its clients, with the keys it holds. It is safe
+// for concurrent use.
+type Server struct {
+	keys    Keys
+	clients map[string]*Policy
+
+	// StateFile, if not empty, keeps the last height each block key
+	// signed at across restarts. Set it before the first request.
+	StateFile string
+
+	mu     sync.Mutex
+	loaded bool
+	signed map[string]signedBlock // by block key name
+}
+
+type signedBlock struct {
+	Height uint64  `json:"height"`
+	Hash   bc.Hash `json:"hash"`
+}
+
+// NewServer returns a Server signing with keys for the clients with
+// the given policies.
+func NewServer(keys Keys, policies ...*Policy) *Server {
+	s := &Server{keys: keys, clients: make(map[string]*Policy), signed: make(map[string]signedBlock)}
+	for _, p := range policies {
+		s.clients[string(p.Pubkey)] = p
+	}
+	return s
+}
+
+// policy returns the policy of the client with the given key.
+func (s *Server) policy(client ed25519.PublicKey) (*Policy, error) {
+	p, ok := s.clients[string(client)]
+	if !ok {
+		return nil, errors.WithDetailf(ErrAuth, "unknown client %x", []byte(client))
+	}
+	return p, nil
+}
+
+// PublicKey returns the public key, the root public key for an
+// extended key, of a key the client may sign with.
+func (s *Server) PublicKey(client ed25519.PublicKey, name string) (ed25519.PublicKey, error) {
+	p, err := s.policy(client)
+	if err != nil {
+		return nil, err
+	}
+	if !contains(p.BlockKeys, name) && !contains(p.TxKeys, name) {
+		return nil, errors.WithDetailf(ErrNotAllowed, "key %s", name)
+	}
+	return s.keys.PublicKey(name)
+}
+
+// SignBlock signs the block with header h for the client with the
+// named block key. It refuses to sign a block at or below a height
+// the key has already signed at, except to sign the same block
+// again.
+func (s *Server) SignBlock(ctx context.Context, client ed25519.PublicKey, name string, h *bc.BlockHeader) ([]byte, error) {
+	p, err := s.policy(client)
+	if err != nil {
+		return nil, err
+	}
+	if !contains(p.BlockKeys, name) {
+		return nil, errors.WithDetailf(ErrNotAllowed, "block key %s", name)
+	}
+	if h.NextPredicate == nil {
+		return nil, errors.WithDetail(ErrNotAllowed, "header has no next predicate")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err = s.loadState()
+	if err != nil {
+		return nil, err
+	}
+	hash := h.Hash()
+	last, ok := s.signed[name]
+	if ok && h.Height <= last.Height && !(h.Height == last.Height && hash == last.Hash) {
+		return nil, errors.WithDetailf(ErrDoubleSign, "height %d, with %d signed", h.Height, last.Height)
+	}
+	s.signed[name] = signedBlock{h.Height, hash}
+	err = s.saveState()
+	if err != nil {
+		s.signed[name] = last
+		return nil, err
+	}
+	return s.keys.Sign(ctx, name, nil, hash.Bytes())
+}
+
+// loadState reads StateFile the first time it is called. The caller
+// must hold s.mu.
+func (s *Server) loadState() error {
+	if s.loaded || s.StateFile == "" {
+		return nil
+	}
+	bits, err := ioutil.ReadFile(s.StateFile)
+	if os.IsNotExist(err) {
+		s.loaded = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(bits, &s.signed)
+	if err != nil {
+		return errors.Wrapf(err, "parsing %s", s.StateFile)
+	}
+	s.loaded = true
+	return nil
+}
+
+// saveState writes StateFile, replacing it atomically. The caller
+// must hold s.mu.
+func (s *Server) saveState() error {
+	if s.StateFile == "" {
+		return nil
+	}
+	bits, err := json.Marshal(s.signed)
+	if err != nil {
+		return err
+	}
+	tmp := s.StateFile + ".tmp"
+	err = ioutil.WriteFile(tmp, bits, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.StateFile)
+}
+
+// SignTemplate signs, for the client, each issuance and input of
+// the template in req that needs a signature from one of the
+// client's transaction keys: a key whose public key, derived along
+// the entry's path for an extended key, is one of the entry's key
+// hashes. The signing instructions in req are ignored; the server
+// computes its own from the template.
+func (s *Server) SignTemplate(ctx context.Context, client ed25519.PublicKey, req *txbuilder.SignRequest) (*txbuilder.SignResponse, error) {
+	p, err := s.policy(client)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.TxKeys) == 0 {
+		return nil, errors.WithDetail(ErrNotAllowed, "no transaction keys")
+	}
+	if req.Template == nil {
+		return nil, errors.WithDetail(ErrNotAllowed, "no template")
+	}
+	tpl, err := txbuilder.TemplateFromRaw(req.Template)
+	if err != nil {
+		return nil, err
+	}
+	insts, err := tpl.SigningInstructions()
+	if err != nil {
+		return nil, err
+	}
+
+	type match struct {
+		inst *txbuilder.SigningInstruction
+		i    int
+		name string
+	}
+	var matches []match
+	for _, inst := range insts {
+		for i, kh := range inst.KeyHashes {
+			if name, ok := s.keyFor(p.TxKeys, kh, inst.DerivationPath); ok {
+				matches = append(matches, match{inst, i, name})
+			}
+		}
+	}
+	if len(matches) > 0 && p.Engine != nil {
+		// Check the template and count its outflows toward the
+		// client's limits. The signatures are made below, so Sign's
+		// callback makes none.
+		err = p.Engine.Sign(ctx, tpl, func(context.Context, []byte, []byte, [][]byte) ([]byte, error) {
+			return nil, nil
+		}, time.Now())
+		if err != nil {
+			return nil, errors.Sub(ErrNotAllowed, err)
+		}
+	}
+
+	resp := new(txbuilder.SignResponse)
+	for _, m := range matches {
+		sig, err := s.keys.Sign(ctx, m.name, m.inst.DerivationPath, m.inst.Message)
+		if err != nil {
+			return nil, errors.Wrapf(err, "signing entry %d", m.inst.EntryIndex)
+		}
+		resp.Signatures = append(resp.Signatures, &txbuilder.Signature{
+			EntryIndex: m.inst.EntryIndex,
+			KeyIndex:   uint64(m.i),
+			Signature:  sig,
+		})
+	}
+	return resp, nil
+}
+
+// keyFor returns the name of the key, among names, that signs for
+// keyHash along path.
+func (s *Server) keyFor(names []string, keyHash []byte, path [][]byte) (string, bool) {
+	if len(keyHash) == 0 {
+		return "", false // already signed
+	}
+	for _, name := range names {
+		if xpub, err := s.keys.XPub(name); err == nil {
+			if string(xpub.Derive(path).PublicKey()) == string(keyHash) {
+				return name, true
+			}
+			continue
+		}
+		if pub, err := s.keys.PublicKey(name); err == nil && len(path) == 0 && string(pub) == string(keyHash) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,214 @@
+package remotesigner
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/keystore"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/signpolicy"
+	"i10r.io/testutil"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "remotesigner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ks, err := keystore.Create(filepath.Join(dir, "keys.json"), []byte("secret"), &keystore.Params{Time: 1, Memory: 64, Threads: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockInfo, err := ks.Generate("block", keystore.Ed25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ks.AddXPrv("wallet", testutil.TestXPrv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ks.Generate("spare", keystore.Ed25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := [][]byte{{1}}
+	own := testutil.TestXPub.Derive(path).PublicKey()
+	payee, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, clientPrv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, strangerPrv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stateFile := filepath.Join(dir, "signed.json")
+	policy := &Policy{
+		Pubkey:    clientPrv.Public().(ed25519.PublicKey),
+		BlockKeys: []string{"block"},
+		TxKeys:    []string{"wallet"},
+		Engine: signpolicy.NewEngine(&signpolicy.Policy{
+			Own:          []signpolicy.Destination{{Quorum: 1, Pubkeys: []ed25519.PublicKey{own}}},
+			Destinations: []signpolicy.Destination{{Quorum: 1, Pubkeys: []ed25519.PublicKey{payee}}},
+		}),
+	}
+	s := NewServer(ks, policy)
+	s.StateFile = stateFile
+	hs := httptest.NewServer(Handler(s))
+	defer hs.Close()
+	c := &Client{URL: hs.URL, Key: clientPrv}
+
+	pub, err := c.PublicKey(ctx, "block")
+	if err != nil || !bytes.Equal(pub, blockInfo.Public) {
+		t.Errorf("PublicKey(block) = %x, %v; want %x", pub, err, blockInfo.Public)
+	}
+	if _, err := c.PublicKey(ctx, "spare"); errors.Root(err) != ErrNotAllowed {
+		t.Errorf("PublicKey(spare): got error %v, want %v", err, ErrNotAllowed)
+	}
+	stranger := &Client{URL: hs.URL, Key: strangerPrv}
+	if _, err := stranger.PublicKey(ctx, "block"); errors.Root(err) != ErrAuth {
+		t.Errorf("PublicKey from an unknown client: got error %v, want %v", err, ErrAuth)
+	}
+
+	// Block signing.
+	header := func(height uint64, ts uint64) *bc.BlockHeader {
+		return &bc.BlockHeader{Version: 3, Height: height, TimestampMs: ts, NextPredicate: &bc.Predicate{Version: 1, Quorum: 1, Pubkeys: [][]byte{pub}}}
+	}
+	h2 := header(2, 100)
+	for i := 0; i < 2; i++ {
+		sig, err := c.SignBlock(ctx, "block", h2)
+		if err != nil || !ed25519.Verify(pub, h2.Hash().Bytes(), sig) {
+			t.Errorf("SignBlock(h2) #%d = %x, %v; want a valid signature", i, sig, err)
+		}
+	}
+	if _, err := c.SignBlock(ctx, "block", header(2, 101)); errors.Root(err) != ErrDoubleSign {
+		t.Errorf("SignBlock(another block at 2): got error %v, want %v", err, ErrDoubleSign)
+	}
+	if _, err := c.SignBlock(ctx, "block", header(1, 50)); errors.Root(err) != ErrDoubleSign {
+		t.Errorf("SignBlock(height 1): got error %v, want %v", err, ErrDoubleSign)
+	}
+	if _, err := c.SignBlock(ctx, "wallet", header(3, 200)); errors.Root(err) != ErrNotAllowed {
+		t.Errorf("SignBlock with a transaction key: got error %v, want %v", err, ErrNotAllowed)
+	}
+	if _, err := c.SignBlock(ctx, "block", header(3, 200)); err != nil {
+		t.Errorf("SignBlock(h3): %v", err)
+	}
+
+	// A restarted server remembers the heights it signed at.
+	s2 := NewServer(ks, policy)
+	s2.StateFile = stateFile
+	if _, err := s2.SignBlock(ctx, policy.Pubkey, "block", header(3, 201)); errors.Root(err) != ErrDoubleSign {
+		t.Errorf("SignBlock(another block at 3) after restart: got error %v, want %v", err, ErrDoubleSign)
+	}
+
+	// Template signing.
+	assetID := bc.HashFromBytes([]byte("assetID"))
+	newTemplate := func(dest ed25519.PublicKey) *txbuilder.Template {
+		tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+		tpl.AddInput(1, [][]byte{own}, path, []ed25519.PublicKey{own}, 100, assetID, []byte("anchor"), nil, 2)
+		tpl.AddOutput(1, []ed25519.PublicKey{dest}, 100, assetID, nil, nil)
+		return tpl
+	}
+	tpl := newTemplate(payee)
+	err = c.SignTemplate(ctx, tpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpl.Inputs[0].Sigs) != 1 || tpl.Inputs[0].Sigs[0] == nil {
+		t.Errorf("after SignTemplate, input signatures %x, want one", tpl.Inputs[0].Sigs)
+	}
+	strangerPub := strangerPrv.Public().(ed25519.PublicKey)
+	tpl = newTemplate(strangerPub)
+	if err := c.SignTemplate(ctx, tpl); errors.Root(err) != ErrNotAllowed {
+		t.Errorf("SignTemplate paying a stranger: got error %v, want %v", err, ErrNotAllowed)
+	}
+}
+
+func TestAuthenticator(t *testing.T) {
+	_, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	body := []byte(`{"key":"block"}`)
+	nonce := make([]byte, nonceSize)
+	newRequest := func(ts time.Time, signed []byte) *testRequest {
+		ms := bc.Millis(ts)
+		nonce[0]++
+		return &testRequest{
+			client: hex.EncodeToString(prv.Public().(ed25519.PublicKey)),
+			time:   strconv.FormatUint(ms, 10),
+			nonce:  hex.EncodeToString(nonce),
+			sig:    hex.EncodeToString(ed25519.Sign(prv, requestHash("/public-key", ms, nonce, signed))),
+		}
+	}
+
+	good := newRequest(now, body)
+	cases := []struct {
+		name string
+		req  *testRequest
+		ok   bool
+	}{
+		{"good", good, true},
+		{"replayed", good, false},
+		{"stale", newRequest(now.Add(-2*MaxSkew), body), false},
+		{"future", newRequest(now.Add(2*MaxSkew), body), false},
+		{"other body", newRequest(now.Add(time.Millisecond), []byte("{}")), false},
+	}
+	a := &authenticator{seen: make(map[string]time.Time)}
+	for _, c := range cases {
+		req := httptest.NewRequest("POST", "/public-key", bytes.NewReader(body))
+		req.Header.Set(headerClient, c.req.client)
+		req.Header.Set(headerTime, c.req.time)
+		req.Header.Set(headerNonce, c.req.nonce)
+		req.Header.Set(headerSignature, c.req.sig)
+		_, err := a.check(req, body, now)
+		if c.ok && err != nil {
+			t.Errorf("%s: got error %v", c.name, err)
+		}
+		if !c.ok && errors.Root(err) != ErrAuth {
+			t.Errorf("%s: got error %v, want %v", c.name, err, ErrAuth)
+		}
+	}
+
+	// Signatures are forgotten once stale.
+	if n := len(a.seen); n != 1 {
+		t.Errorf("remembering %d signatures, want 1", n)
+	}
+	req := httptest.NewRequest("POST", "/public-key", bytes.NewReader(body))
+	later := now.Add(3 * MaxSkew)
+	r := newRequest(later, body)
+	req.Header.Set(headerClient, r.client)
+	req.Header.Set(headerTime, r.time)
+	req.Header.Set(headerNonce, r.nonce)
+	req.Header.Set(headerSignature, r.sig)
+	if _, err := a.check(req, body, later); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(a.seen); n != 1 {
+		t.Errorf("later, remembering %d signatures, want 1", n)
+	}
+}
+
+type testRequest struct {
+	client, time, nonce, sig string
+}
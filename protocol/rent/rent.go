@@ -0,0 +1,134 @@
+// Package rent implements an experiment in bounding the growth of
+// the blockchain state: contracts that expire.
+//
+// A block of version bc.CommitmentsVersion or later may carry, in its
+// header's commitments area, a rent commitment giving a lifetime.
+// Each output its transactions add to the state then expires that
+// long after the block's timestamp. The output's item in the
+// contracts tree is not its ID alone but its ID followed by the
+// expiration time (see Key). Fee outputs, and outputs added before
+// rent began, do not expire.
+//
+// An expiring contract can be spent as usual, before and after it
+// expires; spending it and adding an output in its place renews it.
+// Once it has expired, it can also be reclaimed: removed from the
+// state, with whatever value it holds, by any transaction that logs
+// a Reclamation of it. In a block without rent, a reclamation is an
+// ordinary log entry.
+//
+// The rent commitment acts as a network flag. Once a block carries
+// it, every later block must carry the same commitment (see
+// state.Snapshot.ApplyBlock).
+package rent
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/validation"
+)
+
+// Commitment is the name of the header commitment holding a block's
+// rent lifetime.
+const Commitment = "rent"
+
+// Marker is the first element of the log data of a reclamation.
+var Marker = []byte("reclaim")
+
+// ErrLifetime is returned for a malformed rent commitment.
+var ErrLifetime = errors.New("malformed rent lifetime")
+
+func init() {
+	validation.RegisterCommitment(Commitment, func(_ *bc.UnsignedBlock, value []byte) error {
+		_, err := ParseLifetime(value)
+		return err
+	})
+}
+
+// Value returns the encoding of a lifetime in milliseconds for a
+// header commitment: 8 bytes, little-endian.
+func Value(lifetimeMS uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], lifetimeMS)
+	return buf[:]
+}
+
+// ParseLifetime parses the value of a rent commitment, returning the
+// lifetime in milliseconds.
+func ParseLifetime(value []byte) (uint64, error) {
+	if len(value) != 8 {
+		return 0, errors.WithDetailf(ErrLifetime, "length %d", len(value))
+	}
+	ms := binary.LittleEndian.Uint64(value)
+	if ms == 0 {
+		return 0, errors.WithDetail(ErrLifetime, "zero lifetime")
+	}
+	return ms, nil
+}
+
+// Lifetime returns the rent lifetime, in milliseconds, of the block
+// with header bh, or 0 if the block has no rent.
+func Lifetime(bh *bc.BlockHeader) (uint64, error) {
+	if bh.Version < bc.CommitmentsVersion {
+		return 0, nil
+	}
+	value, ok := bh.Commitment(Commitment)
+	if !ok {
+		return 0, nil
+	}
+	return ParseLifetime(value)
+}
+
+// Key returns the contracts tree item for the contract with the
+// given ID expiring at expMS: the ID followed by the time, 8 bytes
+// little-endian, as in the nonce tree.
+func Key(id bc.Hash, expMS uint64) []byte {
+	key := make([]byte, 40)
+	copy(key, id.Bytes())
+	binary.LittleEndian.PutUint64(key[32:], expMS)
+	return key
+}
+
+// ParseKey parses a contracts tree item. It returns the contract's ID
+// and its expiration time, which is 0 for a contract that does not
+// expire.
+func ParseKey(item []byte) (bc.Hash, uint64) {
+	id := bc.HashFromBytes(item[:32])
+	if len(item) < 40 {
+		return id, 0
+	}
+	return id, binary.LittleEndian.Uint64(item[32:])
+}
+
+// Reclamation returns the data for a log entry reclaiming the
+// contract with the given ID.
+func Reclamation(id bc.Hash) txvm.Tuple {
+	return txvm.Tuple{txvm.Bytes(Marker), txvm.Bytes(id.Bytes())}
+}
+
+// Reclaimed returns the IDs of the contracts tx reclaims.
+func Reclaimed(tx *bc.Tx) []bc.Hash {
+	var ids []bc.Hash
+	for _, entry := range tx.Log {
+		if len(entry) != 3 {
+			continue
+		}
+		if code, ok := entry[0].(txvm.Bytes); !ok || len(code) != 1 || code[0] != txvm.LogCode {
+			continue
+		}
+		data, ok := entry[2].(txvm.Tuple)
+		if !ok || len(data) != 2 {
+			continue
+		}
+		if marker, ok := data[0].(txvm.Bytes); !ok || !bytes.Equal(marker, Marker) {
+			continue
+		}
+		if id, ok := data[1].(txvm.Bytes); ok && len(id) == 32 {
+			ids = append(ids, bc.HashFromBytes(id))
+		}
+	}
+	return ids
+}
@@ -0,0 +1,142 @@
+package rent_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/rent"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/validation"
+)
+
+func TestLifetime(t *testing.T) {
+	got, err := rent.ParseLifetime(rent.Value(3600000))
+	if err != nil || got != 3600000 {
+		t.Errorf("ParseLifetime(Value(3600000)) = %d, %v", got, err)
+	}
+	for _, v := range [][]byte{nil, rent.Value(0), append(rent.Value(1), 0)} {
+		if _, err := rent.ParseLifetime(v); errors.Root(err) != rent.ErrLifetime {
+			t.Errorf("ParseLifetime(%x): got error %v, want %v", v, err, rent.ErrLifetime)
+		}
+	}
+
+	id := bc.NewHash([32]byte{1, 2, 3})
+	if gotID, exp := rent.ParseKey(rent.Key(id, 42)); gotID != id || exp != 42 {
+		t.Errorf("ParseKey(Key(%x, 42)) = %x, %d", id.Bytes(), gotID.Bytes(), exp)
+	}
+	if _, exp := rent.ParseKey(id.Bytes()); exp != 0 {
+		t.Errorf("ParseKey(id) expires at %d, want 0", exp)
+	}
+}
+
+func TestReclaim(t *testing.T) {
+	c := prottest.NewChain(t)
+	bb := c.BlockBuilder()
+	bb.Version = bc.CommitmentsVersion
+	bb.RentLifetime = time.Millisecond
+
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubs := []ed25519.PublicKey{pub}
+	tag := []byte("gold")
+	assetID := bc.NewHash(standard.AssetID(2, 1, pubs, tag))
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.AddIssuance(2, c.InitialBlockHash.Bytes(), tag, 1, [][]byte{pub}, nil, pubs, 10, nil, nil)
+	tpl.AddOutput(1, pubs, 10, assetID, nil, nil)
+	err = tpl.Sign(context.Background(), func(_ context.Context, msg, _ []byte, _ [][]byte) ([]byte, error) {
+		return ed25519.Sign(prv, msg), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue, err := tpl.Tx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := issue.Outputs[0].ID
+
+	prev := c.State()
+	b := prottest.MakeBlock(t, c, []*bc.Tx{issue})
+	if lifetime, err := rent.Lifetime(b.BlockHeader); err != nil || lifetime != 1 {
+		t.Fatalf("block rent lifetime %d, %v, want 1", lifetime, err)
+	}
+	exp, ok := c.State().Contract(out)
+	if !ok || exp != b.TimestampMs+1 {
+		t.Fatalf("output in state %v, expiring at %d, want true, %d", ok, exp, b.TimestampMs+1)
+	}
+
+	// A validator applying the block gets the same state.
+	err = validation.Block(b.UnsignedBlock, prev.Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := state.Copy(prev)
+	err = snapshot.ApplyBlock(b.UnsignedBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.ContractsTree.RootHash() != b.ContractsRoot.Byte32() {
+		t.Error("applying the block gives a different contracts root")
+	}
+
+	// One that has not expired cannot be reclaimed.
+	reclaim := reclaimTx(t, c.InitialBlockHash, out)
+	snapshot = state.Copy(c.State())
+	err = snapshot.ApplyTxRent(bc.NewCommitmentsTx(reclaim), exp-1, 1)
+	if err == nil {
+		t.Error("reclaimed an unexpired contract")
+	}
+
+	// The next block's rent is the same, and the output has expired.
+	time.Sleep(2 * time.Millisecond)
+	bb.RentLifetime = time.Hour
+	prev = c.State()
+	b = prottest.MakeBlock(t, c, []*bc.Tx{reclaim})
+	if len(b.Transactions) != 1 {
+		t.Fatal("reclamation not included in block")
+	}
+	if lifetime, _ := rent.Lifetime(b.BlockHeader); lifetime != 1 {
+		t.Errorf("next block rent lifetime %d, want 1", lifetime)
+	}
+	if _, ok := c.State().Contract(out); ok {
+		t.Error("reclaimed output still in state")
+	}
+
+	// A block without the same rent is invalid.
+	h := *b.BlockHeader
+	h.SetCommitments(nil)
+	snapshot = state.Copy(prev)
+	if err := snapshot.ApplyBlock(&bc.UnsignedBlock{BlockHeader: &h, Transactions: b.Transactions}); err == nil || !strings.Contains(err.Error(), "rent") {
+		t.Errorf("applying a block dropping rent: got error %v", err)
+	}
+}
+
+func reclaimTx(t *testing.T, blockchainID bc.Hash, id bc.Hash) *bc.Tx {
+	exp := bc.Millis(time.Now().Add(time.Minute))
+	data, err := asm.Disassemble(txvm.Encode(rent.Reclamation(id)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prog, err := asm.Assemble(fmt.Sprintf("%s log x'%x' %d nonce finalize", data, blockchainID.Bytes(), exp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := bc.NewTx(prog, 3, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
@@ -0,0 +1,115 @@
+/*
+Package replay re-validates a blockchain from its initial block,
+checking every block's declared state commitments against values
+recomputed from scratch.
+
+It is meant for auditing: after a change to the VM or to state
+application, replaying an existing chain (for example one exported
+with package archive) shows whether the new code still agrees with
+the old, and if not, exactly where it first disagrees.
+*/
+package replay
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/archive"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/validation"
+)
+
+// Divergence is the error reported when a block's declared
+// commitment differs from the recomputed one.
+type Divergence struct {
+	Height   uint64
+	Field    string // TransactionsRoot, ContractsRoot, or NoncesRoot
+	Declared bc.Hash
+	Computed bc.Hash
+}
+
+func (d *Divergence) Error() string {
+	return fmt.Sprintf("block %d: %s declared %x, computed %x", d.Height, d.Field, d.Declared.Bytes(), d.Computed.Bytes())
+}
+
+// Replayer applies a sequence of blocks to an initially empty state,
+// checking each one as it goes.
+type Replayer struct {
+	// SkipSignatures disables checking each block's signatures
+	// against the previous block's predicate.
+	SkipSignatures bool
+
+	snapshot *state.Snapshot
+	prev     *bc.BlockHeader
+}
+
+// New returns a Replayer starting from an empty state.
+func New() *Replayer {
+	return &Replayer{snapshot: state.Empty()}
+}
+
+// Snapshot returns the state after the last successfully applied
+// block.
+func (r *Replayer) Snapshot() *state.Snapshot {
+	return r.snapshot
+}
+
+// Apply validates b against the previous block and the current
+// state, then applies it. If one of b's declared roots disagrees
+// with the recomputed value, the root of the returned error is a
+// *Divergence. On any error the Replayer's state is unchanged.
+func (r *Replayer) Apply(b *bc.Block) error {
+	txRoot := bc.TxMerkleRoot(b.Transactions)
+	if b.TransactionsRoot == nil || txRoot != *b.TransactionsRoot {
+		return diverge(b.Height, "TransactionsRoot", b.TransactionsRoot, txRoot)
+	}
+
+	err := validation.Block(b.UnsignedBlock, r.prev)
+	if err != nil {
+		return errors.Wrapf(err, "validating block %d", b.Height)
+	}
+	if r.prev != nil && !r.SkipSignatures {
+		err = validation.BlockSig(b, r.prev.NextPredicate)
+		if err != nil {
+			return errors.Wrapf(err, "checking signatures of block %d", b.Height)
+		}
+	}
+
+	snapshot := state.Copy(r.snapshot)
+	err = snapshot.ApplyBlock(b.UnsignedBlock)
+	if err != nil {
+		return errors.Wrapf(err, "applying block %d", b.Height)
+	}
+	if got := bc.NewHash(snapshot.ContractsTree.RootHash()); b.ContractsRoot == nil || got != *b.ContractsRoot {
+		return diverge(b.Height, "ContractsRoot", b.ContractsRoot, got)
+	}
+	if got := bc.NewHash(snapshot.NonceTree.RootHash()); b.NoncesRoot == nil || got != *b.NoncesRoot {
+		return diverge(b.Height, "NoncesRoot", b.NoncesRoot, got)
+	}
+
+	r.snapshot = snapshot
+	r.prev = b.BlockHeader
+	return nil
+}
+
+func diverge(height uint64, field string, declared *bc.Hash, computed bc.Hash) error {
+	d := &Divergence{Height: height, Field: field, Computed: computed}
+	if declared != nil {
+		d.Declared = *declared
+	}
+	return errors.Wrap(d)
+}
+
+// Archive replays every block in the archive read from rd (see
+// package archive), returning the final state. If replay fails, the
+// state as of the last good block is returned along with the error.
+func Archive(ctx context.Context, rd io.Reader) (*state.Snapshot, error) {
+	r := New()
+	_, err := archive.Import(ctx, rd, func(_ context.Context, b *bc.Block) error {
+		return r.Apply(b)
+	})
+	return r.Snapshot(), err
+}
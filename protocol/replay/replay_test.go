@@ -0,0 +1,91 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/archive"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/bc/bctest"
+	"i10r.io/protocol/prottest"
+	"i10r.io/protocol/prottest/memstore"
+	"i10r.io/testutil"
+)
+
+func newTestStore(t *testing.T) *memstore.MemStore {
+	store := memstore.New()
+	c := prottest.NewChain(t, prottest.WithStore(store))
+	b1 := prottest.Initial(t, c)
+	for i := 0; i < 3; i++ {
+		tx := bctest.EmptyTx(t, b1.Hash(), time.Now().Add(time.Hour))
+		prottest.MakeBlock(t, c, []*bc.Tx{tx})
+	}
+	return store
+}
+
+func TestArchive(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	height := uint64(len(store.Blocks))
+
+	var buf bytes.Buffer
+	err := archive.Export(ctx, &buf, store, 1, height)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	snapshot, err := Archive(ctx, &buf)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if snapshot.Height() != height {
+		t.Errorf("replayed to height %d, want %d", snapshot.Height(), height)
+	}
+}
+
+func TestDivergence(t *testing.T) {
+	store := newTestStore(t)
+	height := uint64(len(store.Blocks))
+
+	cases := []struct {
+		field  string
+		mutate func(*bc.BlockHeader)
+	}{
+		{"TransactionsRoot", func(bh *bc.BlockHeader) { bh.TransactionsRoot = &bc.Hash{V0: 1} }},
+		{"ContractsRoot", func(bh *bc.BlockHeader) { bh.ContractsRoot = &bc.Hash{V0: 1} }},
+		{"NoncesRoot", func(bh *bc.BlockHeader) { bh.NoncesRoot = &bc.Hash{V0: 1} }},
+	}
+	for _, c := range cases {
+		t.Run(c.field, func(t *testing.T) {
+			r := New()
+			for h := uint64(1); h < height; h++ {
+				err := r.Apply(store.Blocks[h])
+				if err != nil {
+					testutil.FatalErr(t, err)
+				}
+			}
+			before := r.Snapshot()
+
+			last := store.Blocks[height]
+			bh := *last.BlockHeader
+			c.mutate(&bh)
+			bad := &bc.Block{
+				UnsignedBlock: &bc.UnsignedBlock{BlockHeader: &bh, Transactions: last.Transactions},
+				Arguments:     last.Arguments,
+			}
+			err := r.Apply(bad)
+			d, ok := errors.Root(err).(*Divergence)
+			if !ok {
+				t.Fatalf("got error %v, want *Divergence", err)
+			}
+			if d.Height != height || d.Field != c.field {
+				t.Errorf("got divergence at %d in %s, want %d in %s", d.Height, d.Field, height, c.field)
+			}
+			if r.Snapshot() != before {
+				t.Error("failed Apply changed the replayer's state")
+			}
+		})
+	}
+}
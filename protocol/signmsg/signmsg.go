@@ -0,0 +1,210 @@
+// Package signmsg implements signed off-chain messages, with which
+// the holder of blockchain keys proves control of them to a service,
+// for instance to log in as the owner of an output.
+//
+// A Message names the blockchain, the service (its domain), and a
+// one-time challenge nonce chosen by the service, and carries an
+// arbitrary payload. It is signed by the keys of a quorum-of-pubkeys
+// predicate, the same predicate that locks outputs of the standard
+// pay-to-multisig contract. The signed hash is domain-separated from
+// transaction signatures, which sign a txvm program rather than a
+// hash, so a signed message can never be replayed as a spend; and a
+// Verifier accepts each challenge once, before it expires, so it
+// cannot be replayed to the service either.
+package signmsg
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+)
+
+// Prefix is the domain-separation string of signed-message hashes.
+const Prefix = "txvm signed message"
+
+var (
+	// ErrQuorum is returned for a message without a quorum of valid
+	// signatures.
+	ErrQuorum = errors.New("not enough valid signatures")
+
+	// ErrContext is returned for a message for another blockchain or
+	// service.
+	ErrContext = errors.New("message for another blockchain or service")
+
+	// ErrChallenge is returned for a message answering a challenge
+	// that the verifier did not issue or has already accepted.
+	ErrChallenge = errors.New("unknown challenge")
+
+	// ErrExpired is returned for a message verified after its
+	// expiration time.
+	ErrExpired = errors.New("message expired")
+)
+
+// Message is an off-chain statement by the keys of a predicate.
+// Signatures is parallel to Pubkeys; an empty entry is a key that
+// did not sign.
+type Message struct {
+	Network    bc.Hash              `json:"network"` // initial block ID
+	Domain     string               `json:"domain"`
+	Nonce      chainjson.HexBytes   `json:"nonce"`
+	ExpMS      uint64               `json:"expiration_ms"`
+	Payload    chainjson.HexBytes   `json:"payload"`
+	Quorum     int                  `json:"quorum"`
+	Pubkeys    []ed25519.PublicKey  `json:"pubkeys"`
+	Signatures []chainjson.HexBytes `json:"signatures"`
+}
+
+// SigningMessage returns the message the keys sign: the hash, under
+// Prefix, of every field but the signatures, encoded as a txvm
+// tuple.
+func (m *Message) SigningMessage() []byte {
+	pubkeys := make(txvm.Tuple, 0, len(m.Pubkeys))
+	for _, pk := range m.Pubkeys {
+		pubkeys = append(pubkeys, txvm.Bytes(pk))
+	}
+	tup := txvm.Tuple{
+		txvm.Bytes(m.Network.Bytes()),
+		txvm.Bytes(m.Domain),
+		txvm.Bytes(m.Nonce),
+		txvm.Int(m.ExpMS),
+		txvm.Bytes(m.Payload),
+		txvm.Int(m.Quorum),
+		pubkeys,
+	}
+	h := txvm.VMHash(Prefix, txvm.Encode(tup))
+	return h[:]
+}
+
+// Sign adds a signature by the key pub, which must be one of m's
+// pubkeys, made with signFn. Either an ed25519 private key or a
+// derived chainkd key can supply signFn:
+//
+//	m.Sign(pub, func(msg []byte) []byte { return ed25519.Sign(prv, msg) })
+//	m.Sign(xprv.XPub().PublicKey(), xprv.Sign)
+func (m *Message) Sign(pub ed25519.PublicKey, signFn func([]byte) []byte) error {
+	for i, pk := range m.Pubkeys {
+		if string(pk) == string(pub) {
+			if len(m.Signatures) != len(m.Pubkeys) {
+				sigs := make([]chainjson.HexBytes, len(m.Pubkeys))
+				copy(sigs, m.Signatures)
+				m.Signatures = sigs
+			}
+			m.Signatures[i] = signFn(m.SigningMessage())
+			return nil
+		}
+	}
+	return errors.New("key is not one of the message's pubkeys")
+}
+
+// VerifySignatures checks that m carries a quorum of valid
+// signatures from its pubkeys. A message always needs at least one
+// signature, even for a quorum of zero. It does not check m's
+// context; see Verifier.
+func (m *Message) VerifySignatures() error {
+	if len(m.Signatures) > len(m.Pubkeys) {
+		return errors.WithDetailf(ErrQuorum, "%d signatures for %d keys", len(m.Signatures), len(m.Pubkeys))
+	}
+	msg := m.SigningMessage()
+	var n int
+	for i, sig := range m.Signatures {
+		if len(sig) == 0 {
+			continue
+		}
+		if !ed25519.Verify(m.Pubkeys[i], msg, sig) {
+			return errors.WithDetailf(ErrQuorum, "bad signature for key %d", i)
+		}
+		n++
+	}
+	if n == 0 || n < m.Quorum {
+		return errors.WithDetailf(ErrQuorum, "%d of %d", n, m.Quorum)
+	}
+	return nil
+}
+
+// Controls reports whether m's predicate locks the output with the
+// given ID, which holds amount units of assetID with the given
+// anchor, under either version of the standard pay-to-multisig
+// contract. Together with VerifySignatures it proves that the
+// signers can spend the output.
+func (m *Message) Controls(outputID bc.Hash, amount int64, assetID bc.Hash, anchor []byte) bool {
+	for _, seed := range [][32]byte{standard.PayToMultisigSeed1, standard.PayToMultisigSeed2} {
+		if standard.MultisigOutputID(m.Quorum, m.Pubkeys, amount, assetID, anchor, seed[:]) == outputID {
+			return true
+		}
+	}
+	return false
+}
+
+// Verifier issues challenges for a service and verifies the signed
+// messages answering them. Each challenge is accepted at most once.
+//
+// It is safe for concurrent use.
+type Verifier struct {
+	Network bc.Hash
+	Domain  string
+
+	// TTL is how long a challenge remains valid.
+	TTL time.Duration
+
+	mu      sync.Mutex
+	pending map[string]uint64 // hex nonce -> expiration
+}
+
+// Challenge returns an unsigned message, valid until v.TTL after
+// now, for a client to fill in with its payload and predicate and to
+// sign.
+func (v *Verifier) Challenge(now time.Time) (*Message, error) {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	exp := bc.Millis(now.Add(v.TTL))
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.pending == nil {
+		v.pending = make(map[string]uint64)
+	}
+	v.pending[hex.EncodeToString(nonce)] = exp
+	return &Message{Network: v.Network, Domain: v.Domain, Nonce: nonce, ExpMS: exp}, nil
+}
+
+// Verify checks that m answers an outstanding challenge of v before
+// it expires, and carries a quorum of valid signatures. If so, the
+// challenge is used up. Verify also forgets challenges that have
+// expired by now.
+func (v *Verifier) Verify(m *Message, now time.Time) error {
+	if m.Network != v.Network || m.Domain != v.Domain {
+		return errors.WithDetailf(ErrContext, "network %x, domain %q", m.Network.Bytes(), m.Domain)
+	}
+	err := m.VerifySignatures()
+	if err != nil {
+		return err
+	}
+	nowMS := bc.Millis(now)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for n, exp := range v.pending {
+		if exp < nowMS {
+			delete(v.pending, n)
+		}
+	}
+	key := hex.EncodeToString(m.Nonce)
+	exp, ok := v.pending[key]
+	if !ok || exp != m.ExpMS {
+		if m.ExpMS < nowMS {
+			return errors.WithDetailf(ErrExpired, "expired at %d", m.ExpMS)
+		}
+		return errors.WithDetailf(ErrChallenge, "nonce %x", []byte(m.Nonce))
+	}
+	delete(v.pending, key)
+	return nil
+}
@@ -0,0 +1,99 @@
+package signmsg
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/testutil"
+)
+
+func TestVerifier(t *testing.T) {
+	xprv := testutil.TestXPrv.Derive([][]byte{[]byte("login")})
+	pub := xprv.XPub().PublicKey()
+	pub2, prv2, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	v := &Verifier{Network: bc.HashFromBytes([]byte("net")), Domain: "example.com", TTL: time.Minute}
+
+	m, err := v.Challenge(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Payload = []byte("log me in")
+	m.Quorum = 2
+	m.Pubkeys = []ed25519.PublicKey{pub, pub2}
+	if err := m.Sign(pub, xprv.Sign); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(m, now); errors.Root(err) != ErrQuorum {
+		t.Errorf("1 of 2: got %v, want %v", err, ErrQuorum)
+	}
+	if err := m.Sign(pub2, func(msg []byte) []byte { return ed25519.Sign(prv2, msg) }); err != nil {
+		t.Fatal(err)
+	}
+
+	// The message survives a JSON round trip.
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Message
+	err = json.Unmarshal(b, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := &Verifier{Network: v.Network, Domain: "evil.example.com"}
+	if err := other.Verify(&got, now); errors.Root(err) != ErrContext {
+		t.Errorf("other domain: got %v, want %v", err, ErrContext)
+	}
+	tampered := got
+	tampered.Payload = []byte("log someone else in")
+	if err := v.Verify(&tampered, now); errors.Root(err) != ErrQuorum {
+		t.Errorf("tampered: got %v, want %v", err, ErrQuorum)
+	}
+	if err := v.Verify(&got, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(&got, now); errors.Root(err) != ErrChallenge {
+		t.Errorf("replayed: got %v, want %v", err, ErrChallenge)
+	}
+
+	m, err = v.Challenge(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Quorum = 1
+	m.Pubkeys = []ed25519.PublicKey{pub}
+	m.Sign(pub, xprv.Sign)
+	if err := v.Verify(m, now.Add(2*time.Minute)); errors.Root(err) != ErrExpired {
+		t.Errorf("late: got %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestControls(t *testing.T) {
+	m := &Message{Quorum: 1, Pubkeys: testutil.TestPubs}
+	assetID := bc.HashFromBytes([]byte("asset"))
+	anchor := []byte("anchor")
+	for _, seed := range [][32]byte{standard.PayToMultisigSeed1, standard.PayToMultisigSeed2} {
+		id := standard.MultisigOutputID(1, testutil.TestPubs, 10, assetID, anchor, seed[:])
+		if !m.Controls(id, 10, assetID, anchor) {
+			t.Errorf("does not control output of %x", seed[:])
+		}
+		if m.Controls(id, 11, assetID, anchor) {
+			t.Errorf("controls output of %x with the wrong amount", seed[:])
+		}
+	}
+	m.Quorum = 2
+	id := standard.MultisigOutputID(1, testutil.TestPubs, 10, assetID, anchor, standard.PayToMultisigSeed2[:])
+	if m.Controls(id, 10, assetID, anchor) {
+		t.Error("controls output with the wrong quorum")
+	}
+}
@@ -0,0 +1,63 @@
+package protocol
+
+import (
+	"context"
+	"encoding/hex"
+
+	"i10r.io/log"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/chainparams"
+)
+
+// SlotStats are counts of the block slots of a chain whose
+// parameters set a block interval (see chainparams.Params.Slot),
+// since the Chain started.
+type SlotStats struct {
+	Blocks int64 `json:"blocks"` // blocks committed in their slots
+
+	// Missed maps the hex public key of each block signer that has
+	// led a slot without a block to the number of such slots. A
+	// signer whose count keeps growing is absent.
+	Missed map[string]int64 `json:"missed,omitempty"`
+}
+
+// SlotStats returns counts of c's block slots.
+func (c *Chain) SlotStats() SlotStats {
+	c.slotMu.Lock()
+	defer c.slotMu.Unlock()
+	st := SlotStats{Blocks: c.slotStats.Blocks}
+	if len(c.slotStats.Missed) > 0 {
+		st.Missed = make(map[string]int64, len(c.slotStats.Missed))
+		for k, n := range c.slotStats.Missed {
+			st.Missed[k] = n
+		}
+	}
+	return st
+}
+
+// countSlots counts the slots between prev and b, which follows it,
+// logging the signers that missed theirs.
+func (c *Chain) countSlots(ctx context.Context, b, prev *bc.BlockHeader) {
+	missed, err := chainparams.MissedSlots(b, prev)
+	if err != nil || missed == nil {
+		return
+	}
+	signers, _, err := prev.NextPredicate.Signers()
+	if err != nil {
+		return
+	}
+	c.slotMu.Lock()
+	defer c.slotMu.Unlock()
+	c.slotStats.Blocks++
+	for i, n := range missed {
+		if n == 0 {
+			continue
+		}
+		key := hex.EncodeToString(signers[i].Pubkey)
+		if c.slotStats.Missed == nil {
+			c.slotStats.Missed = make(map[string]int64)
+		}
+		c.slotStats.Missed[key] += n
+		log.Printkv(ctx, "event", "missed-slots", "height", b.Height, "signer", key, "slots", n)
+	}
+}
@@ -0,0 +1,116 @@
+package protocol
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/chainparams"
+	"i10r.io/protocol/prottest/memstore"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/validation"
+	"i10r.io/testutil"
+)
+
+func TestSlots(t *testing.T) {
+	ctx := context.Background()
+	var (
+		pubs []ed25519.PublicKey
+		prvs []ed25519.PrivateKey
+	)
+	for i := 0; i < 2; i++ {
+		pub, prv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, pub)
+		prvs = append(prvs, prv)
+	}
+	start := time.Unix(1500000000, 0)
+	b1, err := NewInitialBlock(pubs, 1, start)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	newChain := func() *Chain {
+		c, err := NewChain(ctx, b1, memstore.New(), nil)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		snapshot := state.Empty()
+		err = snapshot.ApplyBlock(b1.UnsignedBlock)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		err = c.CommitAppliedBlock(ctx, b1, snapshot)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		c.SetTimePolicy(TimePolicy{Clock: NewManualClock(start.Add(10500 * time.Millisecond))})
+		return c
+	}
+	gen, follower := newChain(), newChain()
+	gen.bb.Version = bc.CommitmentsVersion
+	gen.bb.Params = &chainparams.Params{BlockIntervalMS: 1000}
+
+	// The next block goes at the start of the next slot.
+	ts := gen.NextTimestampMS(ctx)
+	if want := bc.Millis(start) + 11000; ts != want {
+		t.Fatalf("NextTimestampMS = %d, want %d", ts, want)
+	}
+
+	// makeBlock makes the block at ts, signed by signer.
+	makeBlock := func(ts uint64, signer int) *bc.Block {
+		ub, _, err := gen.GenerateBlock(ctx, ts, nil)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		b, err := bc.SignBlock(ub, gen.State().Header, func(i int) (interface{}, error) {
+			if i != signer {
+				return nil, nil
+			}
+			return ed25519.Sign(prvs[i], ub.Hash().Bytes()), nil
+		})
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		for i, arg := range b.Arguments {
+			if arg == nil {
+				b.Arguments[i] = []byte{} // as decoded
+			}
+		}
+		return b
+	}
+
+	slot := ts / 1000
+	leader := int(slot % 2)
+	pred := follower.State().Header.NextPredicate
+	wrong := makeBlock(ts, 1-leader)
+	if err := validation.BlockSig(wrong, pred); errors.Root(err) != chainparams.ErrSlot {
+		t.Fatalf("block signed by the other signer: got %v, want %v", err, chainparams.ErrSlot)
+	}
+	b2 := makeBlock(ts, leader)
+	if err := validation.BlockSig(b2, pred); err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if err := follower.CommitBlock(ctx, b2); err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if err := gen.CommitBlock(ctx, b2); err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	// Skipping the next slot counts against its leader.
+	b3 := makeBlock(ts+2000, leader)
+	if err := follower.CommitBlock(ctx, b3); err != nil {
+		testutil.FatalErr(t, err)
+	}
+	st := follower.SlotStats()
+	missing := hex.EncodeToString(pubs[1-leader])
+	if st.Blocks != 2 || len(st.Missed) != 1 || st.Missed[missing] != 1 {
+		t.Errorf("SlotStats = %+v, want 2 blocks and 1 slot missed by %s", st, missing)
+	}
+}
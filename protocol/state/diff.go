@@ -0,0 +1,127 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/patricia"
+	"i10r.io/protocol/rent"
+)
+
+// A SnapshotDiff is the difference between two snapshots: for
+// auditing what a block, or the import of a checkpoint, changed.
+type SnapshotDiff struct {
+	FromHeight, ToHeight uint64
+
+	// The roots of the contracts and nonce trees before and after.
+	FromContractsRoot, ToContractsRoot bc.Hash
+	FromNoncesRoot, ToNoncesRoot       bc.Hash
+
+	// The IDs of the contracts added and removed, in increasing
+	// order.
+	AddedContracts, RemovedContracts []bc.Hash
+
+	// The nonces added and removed, by nonce commitment, in
+	// increasing order. A removed nonce has usually expired.
+	AddedNonces, RemovedNonces []DiffNonce
+}
+
+// A DiffNonce is a nonce added to or removed from a snapshot.
+type DiffNonce struct {
+	ID    bc.Hash // the first 32 bytes of the nonce commitment
+	ExpMS uint64
+}
+
+// Diff returns the difference between snapshots a and b.
+func Diff(a, b *Snapshot) *SnapshotDiff {
+	d := &SnapshotDiff{
+		FromHeight:        a.Height(),
+		ToHeight:          b.Height(),
+		FromContractsRoot: bc.NewHash(a.ContractsTree.RootHash()),
+		ToContractsRoot:   bc.NewHash(b.ContractsTree.RootHash()),
+		FromNoncesRoot:    bc.NewHash(a.NonceTree.RootHash()),
+		ToNoncesRoot:      bc.NewHash(b.NonceTree.RootHash()),
+	}
+	if d.FromContractsRoot != d.ToContractsRoot {
+		added, removed := diffTrees(a.ContractsTree, b.ContractsTree)
+		for _, item := range added {
+			id, _ := rent.ParseKey(item)
+			d.AddedContracts = append(d.AddedContracts, id)
+		}
+		for _, item := range removed {
+			id, _ := rent.ParseKey(item)
+			d.RemovedContracts = append(d.RemovedContracts, id)
+		}
+	}
+	if d.FromNoncesRoot != d.ToNoncesRoot {
+		added, removed := diffTrees(a.NonceTree, b.NonceTree)
+		for _, item := range added {
+			id, exp := idTime(item)
+			d.AddedNonces = append(d.AddedNonces, DiffNonce{id, exp})
+		}
+		for _, item := range removed {
+			id, exp := idTime(item)
+			d.RemovedNonces = append(d.RemovedNonces, DiffNonce{id, exp})
+		}
+	}
+	return d
+}
+
+// diffTrees returns the items in b and not a, and the items in a and
+// not b.
+func diffTrees(a, b *patricia.Tree) (added, removed [][]byte) {
+	// Walk visits items in increasing order, so the two lists can be
+	// merged.
+	as, bs := treeToBytes(a), treeToBytes(b)
+	for len(as) > 0 || len(bs) > 0 {
+		var c int
+		switch {
+		case len(as) == 0:
+			c = 1
+		case len(bs) == 0:
+			c = -1
+		default:
+			c = bytes.Compare(as[0], bs[0])
+		}
+		switch {
+		case c < 0:
+			removed = append(removed, as[0])
+			as = as[1:]
+		case c > 0:
+			added = append(added, bs[0])
+			bs = bs[1:]
+		default:
+			as, bs = as[1:], bs[1:]
+		}
+	}
+	return added, removed
+}
+
+// Empty reports whether d records no change to the contracts or
+// nonces.
+func (d *SnapshotDiff) Empty() bool {
+	return d.FromContractsRoot == d.ToContractsRoot && d.FromNoncesRoot == d.ToNoncesRoot
+}
+
+// String returns a report of d, one change per line.
+func (d *SnapshotDiff) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "height %d -> %d\n", d.FromHeight, d.ToHeight)
+	fmt.Fprintf(&b, "contracts root %x -> %x\n", d.FromContractsRoot.Bytes(), d.ToContractsRoot.Bytes())
+	fmt.Fprintf(&b, "nonces root %x -> %x\n", d.FromNoncesRoot.Bytes(), d.ToNoncesRoot.Bytes())
+	for _, id := range d.AddedContracts {
+		fmt.Fprintf(&b, "+ contract %x\n", id.Bytes())
+	}
+	for _, id := range d.RemovedContracts {
+		fmt.Fprintf(&b, "- contract %x\n", id.Bytes())
+	}
+	for _, n := range d.AddedNonces {
+		fmt.Fprintf(&b, "+ nonce %x expiring %d\n", n.ID.Bytes(), n.ExpMS)
+	}
+	for _, n := range d.RemovedNonces {
+		fmt.Fprintf(&b, "- nonce %x expiring %d\n", n.ID.Bytes(), n.ExpMS)
+	}
+	return b.String()
+}
@@ -13,11 +13,11 @@ func (s *Snapshot) FromBytes(b []byte) error {
 	if err != nil {
 		return errors.Wrap(err, "unmarshaling state snapshot proto")
 	}
-	s.ContractsTree, err = treeFromBytes(rs.ContractNodes)
+	s.ContractsTree, err = patricia.Build(rs.ContractNodes)
 	if err != nil {
 		return errors.Wrap(err, "reconstructing contracts tree")
 	}
-	s.NonceTree, err = treeFromBytes(rs.NonceNodes)
+	s.NonceTree, err = patricia.Build(rs.NonceNodes)
 	if err != nil {
 		return errors.Wrap(err, "reconstructing nonce tree")
 	}
@@ -56,14 +56,3 @@ func treeToBytes(tree *patricia.Tree) [][]byte {
 	})
 	return nodes
 }
-
-func treeFromBytes(keys [][]byte) (*patricia.Tree, error) {
-	tree := new(patricia.Tree)
-	for _, k := range keys {
-		err := tree.Insert(k)
-		if err != nil {
-			return nil, err
-		}
-	}
-	return tree, nil
-}
@@ -9,17 +9,27 @@ import (
 	"fmt"
 
 	"i10r.io/errors"
+	"i10r.io/protocol/alloc"
 	"i10r.io/protocol/bc"
+	"i10r.io/protocol/chainparams"
+	"i10r.io/protocol/fee"
 	"i10r.io/protocol/patricia"
+	"i10r.io/protocol/rent"
 )
 
 // Snapshot contains a blockchain's state.
 //
-// TODO: consider making type Snapshot truly immutable.  We already
-// handle it that way in many places (with explicit calls to Copy to
-// get the right behavior).  PruneNonces and the Apply functions would
-// have to produce new Snapshots rather than updating Snapshots in
-// place.
+// The methods that change a Snapshot (PruneNonces, AddAllocations,
+// AddFeeOutputs, and the Apply functions) replace its fields with new values
+// rather than changing the old ones: its trees with new trees that
+// share unchanged nodes with the old (see package patricia), and
+// its header and RefIDs with new ones. Nothing reachable from a
+// Snapshot is changed except through its own fields.
+//
+// So a frozen Snapshot (see Freeze) may be read by any number of
+// goroutines at once, with no lock, while one goroutine applies a
+// block to a Copy of it. A Snapshot must not be changed once other
+// goroutines can read it.
 type Snapshot struct {
 	ContractsTree *patricia.Tree
 	NonceTree     *patricia.Tree
@@ -46,6 +56,15 @@ func (s *Snapshot) PruneNonces(timestampMS uint64) {
 	s.NonceTree = newTree
 }
 
+// Freeze computes and keeps the root hashes of s's trees, after
+// which s may be read concurrently (see Snapshot). Freeze must be
+// called, and s no longer changed, before s is shared with other
+// goroutines.
+func (s *Snapshot) Freeze() {
+	s.ContractsTree.RootHash()
+	s.NonceTree.RootHash()
+}
+
 // Copy makes a copy of provided snapshot. Copying a snapshot is an
 // O(n) operation where n is the number of nonces in the snapshot's
 // nonce set.
@@ -73,25 +92,96 @@ func Empty() *Snapshot {
 	}
 }
 
-// ApplyBlock updates s in place. It runs in three phases:
-// PruneNonces, ApplyBlockHeader, and ApplyTx
-// (the latter called in a loop for each transaction). Callers
-// are free to invoke those phases separately.
+// ApplyBlock updates s in place. It runs in five phases:
+// PruneNonces, ApplyBlockHeader, AddAllocations (for the initial
+// block only), ApplyTx (called in a loop for each transaction), and
+// AddFeeOutputs. Callers are free to invoke those phases separately.
+//
+// If block has rent (see package rent), ApplyTxRent takes the place
+// of ApplyTx. A block must have the same rent as the previous block,
+// if that has any, and the same parameters unless it approves an
+// update (see package chainparams).
 func (s *Snapshot) ApplyBlock(block *bc.UnsignedBlock) error {
 	s.PruneNonces(block.TimestampMs)
 
-	err := s.ApplyBlockHeader(block.BlockHeader)
+	lifetime, err := rent.Lifetime(block.BlockHeader)
+	if err != nil {
+		return errors.Wrap(err, "parsing rent")
+	}
+	if s.Header != nil {
+		prevLifetime, err := rent.Lifetime(s.Header)
+		if err != nil {
+			return errors.Wrap(err, "parsing previous block rent")
+		}
+		if prevLifetime != 0 && lifetime != prevLifetime {
+			return fmt.Errorf("block rent lifetime %d, previous block %d", lifetime, prevLifetime)
+		}
+		err = chainparams.Next(block, s.Header, s.InitialBlockID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.ApplyBlockHeader(block.BlockHeader)
 	if err != nil {
 		return errors.Wrap(err, "applying block header")
 	}
 
+	allocs, err := alloc.Outputs(block)
+	if err != nil {
+		return errors.Wrap(err, "computing allocations")
+	}
+	err = s.AddAllocations(allocs)
+	if err != nil {
+		return err
+	}
+
 	for i, tx := range block.Transactions {
-		err = s.ApplyTx(bc.NewCommitmentsTx(tx))
+		err = s.ApplyTxRent(bc.NewCommitmentsTx(tx), block.TimestampMs, lifetime)
 		if err != nil {
 			return errors.Wrapf(err, "applying block transaction %d", i)
 		}
 	}
 
+	outs, err := fee.Outputs(block)
+	if err != nil {
+		return errors.Wrap(err, "computing fee outputs")
+	}
+	return s.AddFeeOutputs(outs)
+}
+
+// AddAllocations adds an initial block's allocations (see package
+// alloc) to s.
+func (s *Snapshot) AddAllocations(outs []alloc.Output) error {
+	ids := make([]bc.Hash, 0, len(outs))
+	for _, out := range outs {
+		ids = append(ids, out.ID)
+	}
+	return s.addOutputs(ids, "allocation")
+}
+
+// AddFeeOutputs adds a block's fee outputs (see package fee) to s.
+func (s *Snapshot) AddFeeOutputs(outs []fee.Output) error {
+	ids := make([]bc.Hash, 0, len(outs))
+	for _, out := range outs {
+		ids = append(ids, out.ID)
+	}
+	return s.addOutputs(ids, "fee output")
+}
+
+func (s *Snapshot) addOutputs(ids []bc.Hash, what string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	conTree := new(patricia.Tree)
+	*conTree = *s.ContractsTree
+	for _, id := range ids {
+		err := conTree.Insert(id.Bytes())
+		if err != nil {
+			return errors.Wrapf(err, "adding %s %x", what, id.Bytes())
+		}
+	}
+	s.ContractsTree = conTree
 	return nil
 }
 
@@ -117,6 +207,15 @@ func (s *Snapshot) ApplyBlockHeader(bh *bc.BlockHeader) error {
 
 // ApplyTx updates s in place.
 func (s *Snapshot) ApplyTx(p *bc.CommitmentsTx) error {
+	return s.ApplyTxRent(p, 0, 0)
+}
+
+// ApplyTxRent is ApplyTx for a transaction in a block with timestamp
+// nowMS and a rent lifetime of lifetimeMS (see package rent). The
+// outputs p adds expire at nowMS+lifetimeMS, and the contracts it
+// reclaims, which must have expired by nowMS, are removed. With a
+// lifetime of 0, it is the same as ApplyTx.
+func (s *Snapshot) ApplyTxRent(p *bc.CommitmentsTx, nowMS, lifetimeMS uint64) error {
 	if s.InitialBlockID.IsZero() {
 		return fmt.Errorf("cannot apply a transaction to an empty state")
 	}
@@ -156,25 +255,57 @@ func (s *Snapshot) ApplyTx(p *bc.CommitmentsTx) error {
 	for _, con := range p.Tx.Contracts {
 		switch con.Type {
 		case bc.InputType:
-			if !conTree.Contains(con.ID.Bytes()) {
+			// The contract's item is its ID, or, if it expires, its ID
+			// and expiration time.
+			item := conTree.WithPrefix(con.ID.Bytes())
+			if item == nil {
 				return fmt.Errorf("invalid prevout %x", con.ID.Bytes())
 			}
-			conTree.Delete(con.ID.Bytes())
+			conTree.Delete(item)
 
 		case bc.OutputType:
-			err := conTree.Insert(con.ID.Bytes())
+			item := con.ID.Bytes()
+			if lifetimeMS > 0 {
+				item = rent.Key(con.ID, nowMS+lifetimeMS)
+			}
+			err := conTree.Insert(item)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
+	if lifetimeMS > 0 {
+		for _, id := range rent.Reclaimed(p.Tx) {
+			item := conTree.WithPrefix(id.Bytes())
+			if item == nil {
+				return fmt.Errorf("reclaiming contract %x not in state", id.Bytes())
+			}
+			if _, exp := rent.ParseKey(item); exp == 0 || exp > nowMS {
+				return fmt.Errorf("reclaiming unexpired contract %x", id.Bytes())
+			}
+			conTree.Delete(item)
+		}
+	}
+
 	s.NonceTree = nonceTree
 	s.ContractsTree = conTree
 
 	return nil
 }
 
+// Contract reports whether the contract with the given ID is in s,
+// and the time at which it expires, or 0 if it does not (see package
+// rent).
+func (s *Snapshot) Contract(id bc.Hash) (expMS uint64, ok bool) {
+	item := s.ContractsTree.WithPrefix(id.Bytes())
+	if item == nil {
+		return 0, false
+	}
+	_, expMS = rent.ParseKey(item)
+	return expMS, true
+}
+
 // Height returns the height from the stored latest header.
 func (s *Snapshot) Height() uint64 {
 	if s == nil || s.Header == nil {
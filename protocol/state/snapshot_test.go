@@ -2,6 +2,7 @@ package state
 
 import (
 	"reflect"
+	"sync"
 	"testing"
 
 	"i10r.io/protocol/bc"
@@ -254,3 +255,97 @@ func TestHeaderAccessors(t *testing.T) {
 		}
 	}
 }
+
+func TestDiff(t *testing.T) {
+	a := empty(t)
+	spent, kept := bc.NewHash([32]byte{1}), bc.NewHash([32]byte{2})
+	a.ContractsTree.Insert(spent.Bytes())
+	a.ContractsTree.Insert(kept.Bytes())
+
+	if d := Diff(a, Copy(a)); !d.Empty() || len(d.AddedContracts)+len(d.RemovedContracts) != 0 {
+		t.Errorf("diff of a copy: %s", d)
+	}
+
+	b := Copy(a)
+	added := bc.NewHash([32]byte{3})
+	tx := &bc.Tx{
+		Contracts: []bc.Contract{{Type: bc.InputType, ID: spent}, {Type: bc.OutputType, ID: added}},
+		Nonces:    []bc.Nonce{{ID: bc.NewHash([32]byte{4}), ExpMS: 5}},
+	}
+	err := b.ApplyTx(bc.NewCommitmentsTx(tx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := Diff(a, b)
+	if d.Empty() {
+		t.Fatal("diff is empty")
+	}
+	if !reflect.DeepEqual(d.AddedContracts, []bc.Hash{added}) || !reflect.DeepEqual(d.RemovedContracts, []bc.Hash{spent}) {
+		t.Errorf("got added contracts %v, removed %v; want [%v], [%v]", d.AddedContracts, d.RemovedContracts, added, spent)
+	}
+	if want := []DiffNonce{{bc.NewHash([32]byte{4}), 5}}; !reflect.DeepEqual(d.AddedNonces, want) || len(d.RemovedNonces) != 0 {
+		t.Errorf("got added nonces %v, removed %v; want %v, none", d.AddedNonces, d.RemovedNonces, want)
+	}
+	if d.ToContractsRoot != bc.NewHash(b.ContractsTree.RootHash()) {
+		t.Error("wrong contracts root")
+	}
+
+	// The reverse diff swaps additions and removals.
+	r := Diff(b, a)
+	if !reflect.DeepEqual(r.AddedContracts, d.RemovedContracts) || !reflect.DeepEqual(r.RemovedNonces, d.AddedNonces) {
+		t.Errorf("reverse diff: %s", r)
+	}
+}
+
+func TestConcurrentReaders(t *testing.T) {
+	s := empty(t)
+	for i := byte(0); i < 100; i++ {
+		s.ContractsTree.Insert(bc.NewHash([32]byte{i}).Bytes())
+	}
+	s.Freeze()
+	want := s.ContractsTree.RootHash()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if got := s.ContractsTree.RootHash(); got != want {
+					t.Errorf("root hash %x, want %x", got[:], want[:])
+					return
+				}
+				if _, ok := s.Contract(bc.NewHash([32]byte{7})); !ok {
+					t.Error("reader lost contract 7")
+					return
+				}
+			}
+		}()
+	}
+
+	next := s
+	for i := byte(0); i < 100; i++ {
+		next = Copy(next)
+		tx := &bc.Tx{Contracts: []bc.Contract{
+			{Type: bc.InputType, ID: bc.NewHash([32]byte{i})},
+			{Type: bc.OutputType, ID: bc.NewHash([32]byte{i, 1})},
+		}}
+		err := next.ApplyTx(bc.NewCommitmentsTx(tx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		next.Freeze()
+	}
+	close(done)
+	wg.Wait()
+
+	if got := s.ContractsTree.RootHash(); got != want {
+		t.Errorf("after writes, root hash %x, want %x", got[:], want[:])
+	}
+}
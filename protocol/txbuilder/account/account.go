@@ -0,0 +1,395 @@
+// Package account presents the outputs controlled by an account's
+// keys as per-asset balances.
+//
+// A Tracker learns of outputs from blocks (ApplyBlock) and from
+// transactions that have been submitted but not yet included in a
+// block (AddPending). It reports, for each asset, how much is
+// confirmed, how much of that is committed to pending spends, and
+// how much is on its way in. When an account has accumulated many
+// small outputs of one asset, Consolidations builds transactions
+// merging them.
+package account
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/math/amount"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/descriptor"
+	"i10r.io/protocol/txbuilder/txresult"
+)
+
+var (
+	// ErrInsufficientFunds is returned by Select when the available
+	// balance of an asset is less than the amount requested.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+
+	// ErrBlockOrder is returned by ApplyBlock for a block that does
+	// not follow the last one applied.
+	ErrBlockOrder = errors.New("block out of order")
+
+	// ErrDescriptor is returned by AddDescriptor for a descriptor
+	// of something other than a single key.
+	ErrDescriptor = errors.New("descriptor is not a single key")
+)
+
+// State is the state of a tracked output.
+type State int
+
+const (
+	// Confirmed outputs are in a block and not spent by any known
+	// transaction.
+	Confirmed State = iota
+
+	// Incoming outputs are created by a pending transaction.
+	Incoming
+
+	// Spending outputs are in a block and spent by a pending
+	// transaction.
+	Spending
+)
+
+// UTXO is an unspent output controlled by a single account key.
+type UTXO struct {
+	OutputID bc.Hash
+	AssetID  bc.Hash
+	Amount   int64
+	Anchor   []byte
+	Version  int
+	Pubkey   ed25519.PublicKey
+	Path     [][]byte
+	State    State
+}
+
+// Balance is an account's balance of one asset.
+type Balance struct {
+	AssetID bc.Hash
+
+	// Confirmed is the total of outputs in blocks, including those
+	// that pending transactions spend.
+	Confirmed int64
+
+	// Spending is the part of Confirmed that pending transactions
+	// spend.
+	Spending int64
+
+	// Incoming is the total of outputs of pending transactions.
+	Incoming int64
+
+	// UTXOs is the number of confirmed outputs not being spent.
+	UTXOs int
+}
+
+// Available is the amount that can be spent now: confirmed and not
+// being spent.
+func (b Balance) Available() int64 {
+	return b.Confirmed - b.Spending
+}
+
+// Tracker tracks the outputs of one account. It is safe for
+// concurrent use.
+type Tracker struct {
+	mu      sync.Mutex
+	keys    map[string][][]byte // pubkey -> derivation path
+	utxos   map[bc.Hash]*UTXO
+	pending map[bc.Hash]*bc.Tx // by tx ID
+	height  uint64
+}
+
+// NewTracker returns a Tracker for an account with no keys.
+func NewTracker() *Tracker {
+	return &Tracker{
+		keys:    make(map[string][][]byte),
+		utxos:   make(map[bc.Hash]*UTXO),
+		pending: make(map[bc.Hash]*bc.Tx),
+	}
+}
+
+// AddKey adds a key of the account. Path is its derivation path,
+// passed to the txbuilder.SignFunc when spending its outputs. Only
+// outputs in blocks and transactions seen after the key is added
+// are tracked.
+func (t *Tracker) AddKey(pub ed25519.PublicKey, path [][]byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.keys[string(pub)] = path
+}
+
+// AddDescriptor adds the keys of a pk descriptor, one for each index
+// from 0 through gap-1 if it is ranged, with their derivation paths
+// from the descriptor's xpub. It is an error for d to describe
+// anything but a single key, since a Tracker tracks only outputs
+// controlled by one key.
+func (t *Tracker) AddDescriptor(d *descriptor.Descriptor, gap uint64) error {
+	if len(d.Keys) != 1 || d.UnlockMS > 0 {
+		return errors.WithDetailf(ErrDescriptor, "%s", d)
+	}
+	n := uint64(1)
+	if d.Ranged() {
+		n = gap
+	}
+	for i := uint64(0); i < n; i++ {
+		p := d.Expand(i)
+		t.AddKey(p.Pubkeys[0], p.Paths[0])
+	}
+	return nil
+}
+
+// Height returns the height of the last block applied.
+func (t *Tracker) Height() uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.height
+}
+
+// ApplyBlock updates t with the transactions in b, which must be
+// the block after the last one applied. Pending transactions that b
+// includes are confirmed, and those it conflicts with are dropped.
+func (t *Tracker) ApplyBlock(b *bc.Block) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.height > 0 && b.Height != t.height+1 {
+		return errors.WithDetailf(ErrBlockOrder, "block %d after %d", b.Height, t.height)
+	}
+	spent := make(map[bc.Hash]bool)
+	for _, tx := range b.Transactions {
+		if p, ok := t.pending[tx.ID]; ok {
+			t.drop(p)
+		}
+		res := txresult.New(tx)
+		for _, inp := range res.Inputs {
+			spent[inp.OutputID] = true
+			delete(t.utxos, inp.OutputID)
+		}
+		t.addOutputs(res, Confirmed)
+	}
+	for _, p := range t.pending {
+		for _, inp := range txresult.New(p).Inputs {
+			if spent[inp.OutputID] {
+				t.drop(p)
+				break
+			}
+		}
+	}
+	t.height = b.Height
+	return nil
+}
+
+// AddPending records tx, which has been submitted but is not yet in
+// a block. Its outputs to the account are Incoming and the
+// account's outputs it spends are Spending.
+func (t *Tracker) AddPending(tx *bc.Tx) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.pending[tx.ID]; ok {
+		return
+	}
+	t.pending[tx.ID] = tx
+	res := txresult.New(tx)
+	for _, inp := range res.Inputs {
+		if u, ok := t.utxos[inp.OutputID]; ok && u.State == Confirmed {
+			u.State = Spending
+		}
+	}
+	t.addOutputs(res, Incoming)
+}
+
+// DropPending forgets the pending transaction with the given ID,
+// for instance because it expired or was rejected. The outputs it
+// spent are Confirmed again.
+func (t *Tracker) DropPending(id bc.Hash) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if p, ok := t.pending[id]; ok {
+		t.drop(p)
+	}
+}
+
+// drop removes the effects of pending transaction p.
+// The caller must hold t.mu.
+func (t *Tracker) drop(p *bc.Tx) {
+	delete(t.pending, p.ID)
+	res := txresult.New(p)
+	for _, out := range res.Outputs {
+		if u, ok := t.utxos[out.OutputID]; ok && u.State == Incoming {
+			delete(t.utxos, out.OutputID)
+		}
+	}
+	for _, inp := range res.Inputs {
+		if u, ok := t.utxos[inp.OutputID]; ok && u.State == Spending {
+			u.State = Confirmed
+		}
+	}
+}
+
+// addOutputs adds the outputs of res controlled by one account key.
+// The caller must hold t.mu.
+func (t *Tracker) addOutputs(res *txresult.Result, state State) {
+	for _, out := range res.Outputs {
+		if out.Value == nil || len(out.Pubkeys) != 1 {
+			continue
+		}
+		path, ok := t.keys[string(out.Pubkeys[0])]
+		if !ok {
+			continue
+		}
+		t.utxos[out.OutputID] = &UTXO{
+			OutputID: out.OutputID,
+			AssetID:  out.Value.AssetID,
+			Amount:   int64(out.Value.Amount),
+			Anchor:   out.Value.Anchor,
+			Version:  out.Version,
+			Pubkey:   out.Pubkeys[0],
+			Path:     path,
+			State:    state,
+		}
+	}
+}
+
+// Balance returns the account's balance of assetID.
+func (t *Tracker) Balance(assetID bc.Hash) Balance {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	bal := Balance{AssetID: assetID}
+	for _, u := range t.utxos {
+		if u.AssetID == assetID {
+			bal.add(u)
+		}
+	}
+	return bal
+}
+
+// Balances returns the account's balance of each asset it holds or
+// is receiving, ordered by asset ID.
+func (t *Tracker) Balances() []Balance {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	byAsset := make(map[bc.Hash]*Balance)
+	for _, u := range t.utxos {
+		bal, ok := byAsset[u.AssetID]
+		if !ok {
+			bal = &Balance{AssetID: u.AssetID}
+			byAsset[u.AssetID] = bal
+		}
+		bal.add(u)
+	}
+	var bals []Balance
+	for _, bal := range byAsset {
+		bals = append(bals, *bal)
+	}
+	sort.Slice(bals, func(i, j int) bool {
+		return bytes.Compare(bals[i].AssetID.Bytes(), bals[j].AssetID.Bytes()) < 0
+	})
+	return bals
+}
+
+// add adds u to b.
+func (b *Balance) add(u *UTXO) {
+	switch u.State {
+	case Confirmed:
+		b.Confirmed += u.Amount
+		b.UTXOs++
+	case Spending:
+		b.Confirmed += u.Amount
+		b.Spending += u.Amount
+	case Incoming:
+		b.Incoming += u.Amount
+	}
+}
+
+// UTXOs returns copies of the account's outputs of assetID in the
+// given state, smallest first.
+func (t *Tracker) UTXOs(assetID bc.Hash, state State) []*UTXO {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.list(assetID, state)
+}
+
+// list is UTXOs. The caller must hold t.mu.
+func (t *Tracker) list(assetID bc.Hash, state State) []*UTXO {
+	var us []*UTXO
+	for _, u := range t.utxos {
+		if u.AssetID == assetID && u.State == state {
+			c := *u
+			us = append(us, &c)
+		}
+	}
+	sort.Slice(us, func(i, j int) bool {
+		if us[i].Amount != us[j].Amount {
+			return us[i].Amount < us[j].Amount
+		}
+		return bytes.Compare(us[i].OutputID.Bytes(), us[j].OutputID.Bytes()) < 0
+	})
+	return us
+}
+
+// Select returns available outputs of assetID totaling at least
+// amt, and their total. It prefers large outputs, so it spends as
+// few as it can.
+func (t *Tracker) Select(assetID bc.Hash, amt int64) ([]*UTXO, int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	us := t.list(assetID, Confirmed)
+	var total int64
+	for i := len(us) - 1; i >= 0; i-- {
+		var err error
+		total, err = amount.Add(total, us[i].Amount)
+		if err != nil {
+			return nil, 0, err
+		}
+		if total >= amt {
+			return us[i:], total, nil
+		}
+	}
+	return nil, 0, errors.WithDetailf(ErrInsufficientFunds, "%d available, %d needed", total, amt)
+}
+
+// AddInputs adds us to tpl as inputs.
+func AddInputs(tpl *txbuilder.Template, us []*UTXO) {
+	for _, u := range us {
+		tpl.AddInput(1, [][]byte{u.Pubkey}, u.Path, []ed25519.PublicKey{u.Pubkey}, u.Amount, u.AssetID, u.Anchor, nil, u.Version)
+	}
+}
+
+// Policy says when to consolidate an asset's outputs.
+type Policy struct {
+	// Threshold is the number of available outputs of an asset
+	// above which Consolidations merges them.
+	Threshold int
+
+	// MaxInputs is the largest number of outputs merged by one
+	// consolidation transaction. If it is zero, there is no limit.
+	MaxInputs int
+}
+
+// Consolidations returns unsigned templates, valid until maxTime,
+// that merge available outputs of each asset of which the account
+// has more than p.Threshold. Each template spends the smallest
+// outputs, up to p.MaxInputs of them, into a single output to to,
+// which should be an account key. Once a template is signed and
+// submitted, its transaction should be passed to AddPending.
+func (t *Tracker) Consolidations(p Policy, to ed25519.PublicKey, maxTime time.Time) ([]*txbuilder.Template, error) {
+	var tpls []*txbuilder.Template
+	for _, bal := range t.Balances() {
+		if bal.UTXOs <= p.Threshold || bal.UTXOs < 2 {
+			continue
+		}
+		us := t.UTXOs(bal.AssetID, Confirmed)
+		if p.MaxInputs > 1 && len(us) > p.MaxInputs {
+			us = us[:p.MaxInputs]
+		}
+		a, err := mergeAction(bal.AssetID, us)
+		if err != nil {
+			return nil, err
+		}
+		tpl := a.Template(to, maxTime)
+		tpls = append(tpls, tpl)
+	}
+	return tpls, nil
+}
@@ -0,0 +1,251 @@
+package account
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/log"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/descriptor"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/testutil"
+)
+
+func sign(t *testing.T, tpl *txbuilder.Template) *bc.Tx {
+	err := tpl.Sign(context.Background(), func(_ context.Context, msg, _ []byte, path [][]byte) ([]byte, error) {
+		return testutil.TestXPrv.Derive(path).Sign(msg), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := tpl.Tx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func block(height uint64, txs ...*bc.Tx) *bc.Block {
+	return &bc.Block{UnsignedBlock: &bc.UnsignedBlock{
+		BlockHeader:  &bc.BlockHeader{Height: height},
+		Transactions: txs,
+	}}
+}
+
+// issue returns a tracker for an account holding outputs of the
+// given amounts, and their asset ID.
+func issue(t *testing.T, amounts ...int64) (*Tracker, ed25519.PublicKey, bc.Hash) {
+	path := [][]byte{[]byte("account")}
+	pub := testutil.TestXPrv.Derive(path).XPub().PublicKey()
+	tr := NewTracker()
+	tr.AddKey(pub, path)
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	var total int64
+	for _, amt := range amounts {
+		total += amt
+	}
+	tpl.AddIssuance(2, []byte{1}, nil, 1, [][]byte{testutil.TestPub}, nil, testutil.TestPubs, total, nil, nil)
+	assetID := bc.NewHash(standard.AssetID(2, 1, testutil.TestPubs, nil))
+	for _, amt := range amounts {
+		tpl.AddOutput(1, []ed25519.PublicKey{pub}, amt, assetID, nil, nil)
+	}
+	if err := tr.ApplyBlock(block(1, sign(t, tpl))); err != nil {
+		t.Fatal(err)
+	}
+	return tr, pub, assetID
+}
+
+func TestTracker(t *testing.T) {
+	path := [][]byte{[]byte("account")}
+	pub := testutil.TestXPrv.Derive(path).XPub().PublicKey()
+	tr := NewTracker()
+	tr.AddKey(pub, path)
+
+	// Issue three outputs to the account and one elsewhere.
+	maxTime := time.Now().Add(time.Minute)
+	tpl := txbuilder.NewTemplate(maxTime, nil)
+	tpl.AddIssuance(2, []byte{1}, nil, 1, [][]byte{testutil.TestPub}, nil, testutil.TestPubs, 100, nil, nil)
+	assetID := bc.NewHash(standard.AssetID(2, 1, testutil.TestPubs, nil))
+	for _, amt := range []int64{10, 20, 30} {
+		tpl.AddOutput(1, []ed25519.PublicKey{pub}, amt, assetID, nil, nil)
+	}
+	tpl.AddOutput(1, testutil.TestPubs, 40, assetID, nil, nil)
+	if err := tr.ApplyBlock(block(1, sign(t, tpl))); err != nil {
+		t.Fatal(err)
+	}
+	want := Balance{AssetID: assetID, Confirmed: 60, UTXOs: 3}
+	if got := tr.Balance(assetID); got != want {
+		t.Fatalf("after issuance: got %+v, want %+v", got, want)
+	}
+
+	us, total, err := tr.Select(assetID, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(us) != 1 || total != 30 {
+		t.Errorf("Select(25) = %d outputs totaling %d, want 1 totaling 30", len(us), total)
+	}
+	if _, _, err := tr.Select(assetID, 61); errors.Root(err) != ErrInsufficientFunds {
+		t.Errorf("Select(61): got %v, want %v", err, ErrInsufficientFunds)
+	}
+
+	tpls, err := tr.Consolidations(Policy{Threshold: 3}, pub, maxTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpls) != 0 {
+		t.Errorf("at threshold: got %d consolidations, want 0", len(tpls))
+	}
+	tpls, err = tr.Consolidations(Policy{Threshold: 2, MaxInputs: 2}, pub, maxTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tpls) != 1 {
+		t.Fatalf("over threshold: got %d consolidations, want 1", len(tpls))
+	}
+	tx := sign(t, tpls[0])
+	tr.AddPending(tx)
+	want = Balance{AssetID: assetID, Confirmed: 60, Spending: 30, Incoming: 30, UTXOs: 1}
+	if got := tr.Balance(assetID); got != want {
+		t.Errorf("pending: got %+v, want %+v", got, want)
+	}
+	tr.DropPending(tx.ID)
+	want = Balance{AssetID: assetID, Confirmed: 60, UTXOs: 3}
+	if got := tr.Balance(assetID); got != want {
+		t.Errorf("dropped: got %+v, want %+v", got, want)
+	}
+
+	tr.AddPending(tx)
+	if err := tr.ApplyBlock(block(3)); errors.Root(err) != ErrBlockOrder {
+		t.Errorf("skipped block: got %v, want %v", err, ErrBlockOrder)
+	}
+	if err := tr.ApplyBlock(block(2, tx)); err != nil {
+		t.Fatal(err)
+	}
+	want = Balance{AssetID: assetID, Confirmed: 60, UTXOs: 2}
+	if got := tr.Balances(); len(got) != 1 || got[0] != want {
+		t.Errorf("confirmed: got %+v, want [%+v]", got, want)
+	}
+	if us := tr.UTXOs(assetID, Confirmed); us[0].Amount != 30 || us[1].Amount != 30 {
+		t.Errorf("confirmed amounts %d, %d, want 30, 30", us[0].Amount, us[1].Amount)
+	}
+}
+
+func TestAddDescriptor(t *testing.T) {
+	d, err := descriptor.Parse("pk(" + testutil.TestXPub.String() + "/*)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := NewTracker()
+	if err := tr.AddDescriptor(d, 5); err != nil {
+		t.Fatal(err)
+	}
+	p := d.Expand(4)
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	tpl.AddIssuance(2, []byte{1}, nil, 1, [][]byte{testutil.TestPub}, nil, testutil.TestPubs, 10, nil, nil)
+	assetID := bc.NewHash(standard.AssetID(2, 1, testutil.TestPubs, nil))
+	tpl.AddOutput(1, p.Pubkeys, 10, assetID, nil, nil)
+	if err := tr.ApplyBlock(block(1, sign(t, tpl))); err != nil {
+		t.Fatal(err)
+	}
+	us := tr.UTXOs(assetID, Confirmed)
+	if len(us) != 1 || !reflect.DeepEqual(us[0].Path, p.Paths[0]) {
+		t.Fatalf("got outputs %+v, want one with path %x", us, p.Paths[0])
+	}
+
+	d, err = descriptor.Parse("multi(1," + testutil.TestXPub.String() + "/*," + testutil.TestXPub.String() + ")")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.AddDescriptor(d, 5); errors.Root(err) != ErrDescriptor {
+		t.Errorf("AddDescriptor(multi): got error %v, want %v", err, ErrDescriptor)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	tr, _, assetID := issue(t, 1, 2, 3, 1000)
+	p := &MaintenancePolicy{
+		Dust:          5,
+		MinDust:       3,
+		MaxFeeRate:    0.5,
+		Denominations: map[bc.Hash]Denomination{assetID: {Amount: 100, Count: 4}},
+	}
+	actions, err := tr.Plan(p, 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		fmt.Sprintf("split 1 outputs of %x totaling 1000 into [100 100 100 100 600]", assetID.Bytes()),
+		fmt.Sprintf("consolidate 3 outputs of %x totaling 6 into [6]", assetID.Bytes()),
+	}
+	var got []string
+	for _, a := range actions {
+		got = append(got, a.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("low fees: got %q, want %q", got, want)
+	}
+	actions, err = tr.Plan(p, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Kind != Split {
+		t.Errorf("high fees: got %v, want only the split", actions)
+	}
+}
+
+func TestMaintainer(t *testing.T) {
+	tr, pub, assetID := issue(t, 1, 2, 3, 1000)
+	var logbuf bytes.Buffer
+	log.SetOutput(&logbuf)
+	defer log.SetOutput(os.Stdout)
+
+	var submitted []*bc.Tx
+	m := &Maintainer{
+		Tracker: tr,
+		Policy:  &MaintenancePolicy{Dust: 5},
+		Pubkey:  func() ed25519.PublicKey { return pub },
+		Submit: func(_ context.Context, tpl *txbuilder.Template) (*bc.Tx, error) {
+			tx := sign(t, tpl)
+			submitted = append(submitted, tx)
+			return tx, nil
+		},
+		TTL:    time.Minute,
+		DryRun: true,
+	}
+	ctx := context.Background()
+	actions, err := m.RunOnce(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || len(submitted) != 0 {
+		t.Fatalf("dry run: %d actions, %d submitted, want 1, 0", len(actions), len(submitted))
+	}
+	if !strings.Contains(logbuf.String(), "dry_run=true") {
+		t.Errorf("dry run not logged: %q", logbuf.String())
+	}
+
+	m.DryRun = false
+	if _, err := m.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(submitted) != 1 {
+		t.Fatalf("submitted %d transactions, want 1", len(submitted))
+	}
+	want := Balance{AssetID: assetID, Confirmed: 1006, Spending: 6, Incoming: 6, UTXOs: 1}
+	if got := tr.Balance(assetID); got != want {
+		t.Errorf("after submitting: got %+v, want %+v", got, want)
+	}
+	if actions, _ := m.RunOnce(ctx); len(actions) != 0 {
+		t.Errorf("second run planned %v", actions)
+	}
+}
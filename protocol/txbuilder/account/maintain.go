@@ -0,0 +1,259 @@
+package account
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/log"
+	"i10r.io/math/amount"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+)
+
+// Kind is the kind of a maintenance Action.
+type Kind int
+
+const (
+	// Consolidate merges several outputs into one.
+	Consolidate Kind = iota
+
+	// Split divides one output into several of a denomination.
+	Split
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Consolidate:
+		return "consolidate"
+	case Split:
+		return "split"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Action is a maintenance transaction: it spends Inputs, all of
+// AssetID, into new account outputs with the amounts in Outputs.
+type Action struct {
+	Kind    Kind
+	AssetID bc.Hash
+	Inputs  []*UTXO
+	Outputs []int64
+}
+
+func (a *Action) String() string {
+	var in int64
+	for _, u := range a.Inputs {
+		in += u.Amount
+	}
+	return fmt.Sprintf("%s %d outputs of %x totaling %d into %v", a.Kind, len(a.Inputs), a.AssetID.Bytes(), in, a.Outputs)
+}
+
+// Template returns an unsigned template, valid until maxTime, that
+// performs a, paying every output to to.
+func (a *Action) Template(to ed25519.PublicKey, maxTime time.Time) *txbuilder.Template {
+	tpl := txbuilder.NewTemplate(maxTime, nil)
+	AddInputs(tpl, a.Inputs)
+	for _, amt := range a.Outputs {
+		tpl.AddOutput(1, []ed25519.PublicKey{to}, amt, a.AssetID, nil, nil)
+	}
+	return tpl
+}
+
+// Denomination says how to pre-split an asset.
+type Denomination struct {
+	// Amount is the amount of each split output.
+	Amount int64
+
+	// Count is the number of available outputs of exactly Amount
+	// to keep on hand, so that that many payments of up to Amount
+	// can be made in parallel without waiting for change to
+	// confirm.
+	Count int
+}
+
+// MaintenancePolicy says what Plan does.
+type MaintenancePolicy struct {
+	// Dust is the amount at or below which an available output is
+	// dust.
+	Dust int64
+
+	// MinDust is the number of dust outputs of an asset at which they
+	// are consolidated. Values below 2 are treated as 2.
+	MinDust int
+
+	// MaxFeeRate is the fee rate (see fee.Rate) above which dust is
+	// not consolidated; consolidation waits for a period of low
+	// fees. Zero means consolidation happens only when fees are
+	// zero.
+	MaxFeeRate float64
+
+	// MaxInputs is the largest number of outputs consolidated by one
+	// action. If it is zero, there is no limit.
+	MaxInputs int
+
+	// Denominations gives, by asset ID, how to pre-split that asset.
+	// Splitting is not deferred for high fees, since it is done
+	// to make spending possible.
+	Denominations map[bc.Hash]Denomination
+}
+
+// Plan returns the maintenance actions p calls for on t's available
+// outputs, given the prevailing fee rate. Each action spends
+// distinct outputs. Plan changes nothing; to carry out an action,
+// sign and submit its Template and pass the transaction to
+// AddPending.
+func (t *Tracker) Plan(p *MaintenancePolicy, feeRate float64) ([]*Action, error) {
+	var actions []*Action
+	for _, bal := range t.Balances() {
+		us := t.UTXOs(bal.AssetID, Confirmed) // smallest first
+		denom, hasDenom := p.Denominations[bal.AssetID]
+		if hasDenom && denom.Amount > 0 {
+			var have int
+			for _, u := range us {
+				if u.Amount == denom.Amount {
+					have++
+				}
+			}
+			if have < denom.Count && len(us) > 0 {
+				biggest := us[len(us)-1]
+				if a := splitAction(biggest, denom.Amount, denom.Count-have); a != nil {
+					actions = append(actions, a)
+					us = us[:len(us)-1]
+				}
+			}
+		}
+
+		var dust []*UTXO
+		for _, u := range us {
+			if u.Amount > p.Dust {
+				break
+			}
+			if hasDenom && u.Amount == denom.Amount {
+				continue
+			}
+			dust = append(dust, u)
+		}
+		min := p.MinDust
+		if min < 2 {
+			min = 2
+		}
+		if len(dust) < min || feeRate > p.MaxFeeRate {
+			continue
+		}
+		if p.MaxInputs > 1 && len(dust) > p.MaxInputs {
+			dust = dust[:p.MaxInputs]
+		}
+		a, err := mergeAction(bal.AssetID, dust)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+// splitAction returns an action splitting u into up to n outputs of
+// denom plus change, or nil if u is not large enough to yield one
+// output of denom and change.
+func splitAction(u *UTXO, denom int64, n int) *Action {
+	k := (u.Amount - 1) / denom
+	if k < 1 {
+		return nil
+	}
+	if k > int64(n) {
+		k = int64(n)
+	}
+	a := &Action{Kind: Split, AssetID: u.AssetID, Inputs: []*UTXO{u}}
+	for i := int64(0); i < k; i++ {
+		a.Outputs = append(a.Outputs, denom)
+	}
+	a.Outputs = append(a.Outputs, u.Amount-k*denom)
+	return a
+}
+
+func mergeAction(assetID bc.Hash, us []*UTXO) (*Action, error) {
+	var total int64
+	for _, u := range us {
+		var err error
+		total, err = amount.Add(total, u.Amount)
+		if err != nil {
+			return nil, errors.Wrapf(err, "consolidating %x", assetID.Bytes())
+		}
+	}
+	return &Action{Kind: Consolidate, AssetID: assetID, Inputs: us, Outputs: []int64{total}}, nil
+}
+
+// Maintainer runs maintenance for an account in the background.
+type Maintainer struct {
+	Tracker *Tracker
+	Policy  *MaintenancePolicy
+
+	// FeeRate reports the prevailing fee rate, for instance from
+	// recent blocks or the pending transactions of a node. If it is
+	// nil, the fee rate is taken to be zero.
+	FeeRate func(context.Context) (float64, error)
+
+	// Pubkey returns the account key to pay an action's outputs to.
+	Pubkey func() ed25519.PublicKey
+
+	// Submit signs and submits a template, after adding any fee
+	// it should pay, and returns the transaction.
+	Submit func(context.Context, *txbuilder.Template) (*bc.Tx, error)
+
+	// TTL is how long the transaction of each action remains valid.
+	TTL time.Duration
+
+	// DryRun causes actions to be logged but not submitted.
+	DryRun bool
+}
+
+// RunOnce plans maintenance and, unless m.DryRun is set, submits
+// each action, recording the transactions as pending in m.Tracker.
+// It returns the actions planned and stops at the first that fails.
+func (m *Maintainer) RunOnce(ctx context.Context) ([]*Action, error) {
+	var rate float64
+	if m.FeeRate != nil {
+		var err error
+		rate, err = m.FeeRate(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "getting fee rate")
+		}
+	}
+	actions, err := m.Tracker.Plan(m.Policy, rate)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range actions {
+		if m.DryRun {
+			log.Printkv(ctx, "action", a, "fee_rate", rate, "dry_run", true)
+			continue
+		}
+		tx, err := m.Submit(ctx, a.Template(m.Pubkey(), time.Now().Add(m.TTL)))
+		if err != nil {
+			return actions, errors.Wrapf(err, "submitting %s", a.Kind)
+		}
+		m.Tracker.AddPending(tx)
+		log.Printkv(ctx, "action", a, "fee_rate", rate, "tx", fmt.Sprintf("%x", tx.ID.Bytes()))
+	}
+	return actions, nil
+}
+
+// Run calls RunOnce once per interval until ctx is done.
+func (m *Maintainer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		_, err := m.RunOnce(ctx)
+		if err != nil {
+			log.Error(ctx, err, "account maintenance")
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Package address implements addresses: bech32m strings (see BIP
+// 350) naming the keys of a standard pay-to-multisig output, for
+// payees to hand to payers in place of hex keys and quorums.
+//
+// An address's human-readable part names the network it is for, so
+// that an address for a test network is not mistaken for one on the
+// main network. Its data is a kind byte followed by the predicate:
+//
+//	0x00 pubkey              pay to one key
+//	0x01 quorum pubkey...    pay to quorum of two or more keys
+//
+// where each pubkey is 32 bytes and quorum is one byte. There is no
+// pay-to-pubkey-hash kind: the standard contracts keep their keys,
+// not hashes of them, in their outputs.
+package address
+
+import (
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+)
+
+// The human-readable parts of addresses for the known networks.
+const (
+	Mainnet = "txvm"
+	Testnet = "ttxvm"
+	Devnet  = "dtxvm"
+)
+
+// MaxPubkeys is the most keys an address can name.
+const MaxPubkeys = 16
+
+// Address kinds
+const (
+	kindPubkey   = 0
+	kindMultisig = 1
+)
+
+// ErrAddress is returned for a malformed address.
+var ErrAddress = errors.New("malformed address")
+
+// Address is a network, given by its human-readable part, and the
+// predicate of a standard pay-to-multisig output on it: Quorum of
+// Pubkeys.
+type Address struct {
+	HRP     string
+	Quorum  int
+	Pubkeys []ed25519.PublicKey
+}
+
+// Encode returns the bech32m string of a.
+func (a *Address) Encode() (string, error) {
+	if !validHRP(a.HRP) {
+		return "", errors.WithDetailf(ErrAddress, "bad human-readable part %q", a.HRP)
+	}
+	if len(a.Pubkeys) < 1 || len(a.Pubkeys) > MaxPubkeys {
+		return "", errors.WithDetailf(ErrAddress, "%d keys", len(a.Pubkeys))
+	}
+	if a.Quorum < 1 || a.Quorum > len(a.Pubkeys) {
+		return "", errors.WithDetailf(ErrAddress, "quorum %d of %d keys", a.Quorum, len(a.Pubkeys))
+	}
+	for _, pub := range a.Pubkeys {
+		if len(pub) != ed25519.PublicKeySize {
+			return "", errors.WithDetailf(ErrAddress, "%d-byte key", len(pub))
+		}
+	}
+	var data []byte
+	if len(a.Pubkeys) == 1 {
+		data = append(data, kindPubkey)
+	} else {
+		data = append(data, kindMultisig, byte(a.Quorum))
+	}
+	for _, pub := range a.Pubkeys {
+		data = append(data, pub...)
+	}
+	return encode(a.HRP, data), nil
+}
+
+// Parse parses an address.
+func Parse(s string) (*Address, error) {
+	hrp, data, err := decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.WithDetail(ErrAddress, "no data")
+	}
+	a := &Address{HRP: hrp}
+	switch data[0] {
+	case kindPubkey:
+		data = data[1:]
+		if len(data) != ed25519.PublicKeySize {
+			return nil, errors.WithDetailf(ErrAddress, "%d-byte key", len(data))
+		}
+		a.Quorum = 1
+	case kindMultisig:
+		if len(data) < 2 {
+			return nil, errors.WithDetail(ErrAddress, "no quorum")
+		}
+		a.Quorum, data = int(data[1]), data[2:]
+		n := len(data) / ed25519.PublicKeySize
+		if len(data)%ed25519.PublicKeySize != 0 || n < 2 || n > MaxPubkeys {
+			return nil, errors.WithDetailf(ErrAddress, "%d bytes of keys", len(data))
+		}
+		if a.Quorum < 1 || a.Quorum > n {
+			return nil, errors.WithDetailf(ErrAddress, "quorum %d of %d keys", a.Quorum, n)
+		}
+	default:
+		return nil, errors.WithDetailf(ErrAddress, "unknown kind %d", data[0])
+	}
+	for ; len(data) > 0; data = data[ed25519.PublicKeySize:] {
+		a.Pubkeys = append(a.Pubkeys, ed25519.PublicKey(data[:ed25519.PublicKeySize]))
+	}
+	return a, nil
+}
+
+// Pay adds to tpl an output of amount units of assetID to a, with
+// the given reference data.
+func (a *Address) Pay(tpl *txbuilder.Template, amount int64, assetID bc.Hash, refData []byte) *txbuilder.Output {
+	return tpl.AddOutput(a.Quorum, a.Pubkeys, amount, assetID, refData, nil)
+}
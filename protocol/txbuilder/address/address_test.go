@@ -0,0 +1,143 @@
+package address
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+)
+
+// The test vectors of BIP 350.
+func TestBech32m(t *testing.T) {
+	valid := []string{
+		"A1LQFN3A",
+		"a1lqfn3a",
+		"an83characterlonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11sg7hg6",
+		"abcdef1l7aum6echk45nj3s0wdvt2fg8x9yrzpqzd3ryx",
+		"11llllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllllludsr8",
+		"split1checkupstagehandshakeupstreamerranterredcaperredlc445v",
+		"?1v759aa",
+	}
+	for _, s := range valid {
+		if _, _, err := decode5(s); err != nil {
+			t.Errorf("decode5(%q): %s", s, err)
+		}
+	}
+	invalid := []string{
+		"\x201xj0phk",
+		"\x7f1g6xzxy",
+		"\x801vctc34",
+		"an84characterslonghumanreadablepartthatcontainsthetheexcludedcharactersbioandnumber11d6pts4",
+		"qyrz8wqd2c9m",
+		"1qyrz8wqd2c9m",
+		"y1b0jsk6g",
+		"lt1igcx5c0",
+		"in1muywd",
+		"mm1crxm3i",
+		"au1s5cgom",
+		"M1VUXWEZ",
+		"16plkw9",
+		"1p2gdwpf",
+		"abcdef1qpzry9x8gf2tvdw0s3jn54khce6mua7lmqqqxw", // a bech32, not bech32m, checksum
+	}
+	for _, s := range invalid {
+		if _, _, err := decode5(s); errors.Root(err) != ErrAddress {
+			t.Errorf("decode5(%q): got error %v, want %v", s, err, ErrAddress)
+		}
+	}
+}
+
+func TestAddress(t *testing.T) {
+	var pubs []ed25519.PublicKey
+	for i := 0; i < 3; i++ {
+		pub, _, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, pub)
+	}
+	for _, a := range []*Address{
+		{HRP: Mainnet, Quorum: 1, Pubkeys: pubs[:1]},
+		{HRP: Testnet, Quorum: 1, Pubkeys: pubs},
+		{HRP: Devnet, Quorum: 2, Pubkeys: pubs},
+	} {
+		s, err := a.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(s, a.HRP+"1") {
+			t.Errorf("address %s does not begin with %s1", s, a.HRP)
+		}
+		for _, s := range []string{s, strings.ToUpper(s)} {
+			got, err := Parse(s)
+			if err != nil {
+				t.Fatalf("Parse(%s): %s", s, err)
+			}
+			if got.HRP != a.HRP || got.Quorum != a.Quorum || len(got.Pubkeys) != len(a.Pubkeys) {
+				t.Fatalf("Parse(%s) = %+v, want %+v", s, got, a)
+			}
+			for i := range got.Pubkeys {
+				if !bytes.Equal(got.Pubkeys[i], a.Pubkeys[i]) {
+					t.Errorf("Parse(%s) key %d = %x, want %x", s, i, got.Pubkeys[i], a.Pubkeys[i])
+				}
+			}
+		}
+
+		// Changing a character breaks the checksum.
+		b := []byte(s)
+		if b[len(b)-1] == 'q' {
+			b[len(b)-1] = 'p'
+		} else {
+			b[len(b)-1] = 'q'
+		}
+		if _, err := Parse(string(b)); errors.Root(err) != ErrAddress {
+			t.Errorf("Parse(%s): got error %v, want %v", b, err, ErrAddress)
+		}
+	}
+
+	for _, a := range []*Address{
+		{HRP: Mainnet, Quorum: 1},
+		{HRP: Mainnet, Quorum: 0, Pubkeys: pubs},
+		{HRP: Mainnet, Quorum: 4, Pubkeys: pubs},
+		{HRP: "", Quorum: 1, Pubkeys: pubs},
+		{HRP: "TXVM", Quorum: 1, Pubkeys: pubs},
+		{HRP: Mainnet, Quorum: 1, Pubkeys: []ed25519.PublicKey{pubs[0][:31]}},
+	} {
+		if _, err := a.Encode(); errors.Root(err) != ErrAddress {
+			t.Errorf("Encode(%+v): got error %v, want %v", a, err, ErrAddress)
+		}
+	}
+
+	// A multisig address of one key, which has its own kind.
+	data := append([]byte{kindMultisig, 1}, pubs[0]...)
+	if _, err := Parse(encode(Mainnet, data)); errors.Root(err) != ErrAddress {
+		t.Errorf("Parse(multisig of one key): got error %v, want %v", err, ErrAddress)
+	}
+	data = append([]byte{kindMultisig, 3}, pubs[0]...)
+	data = append(data, pubs[1]...)
+	if _, err := Parse(encode(Mainnet, data)); errors.Root(err) != ErrAddress {
+		t.Errorf("Parse(quorum 3 of 2 keys): got error %v, want %v", err, ErrAddress)
+	}
+	if _, err := Parse(encode(Mainnet, []byte{7})); errors.Root(err) != ErrAddress {
+		t.Errorf("Parse(unknown kind): got error %v, want %v", err, ErrAddress)
+	}
+}
+
+func TestPay(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Address{HRP: Mainnet, Quorum: 1, Pubkeys: []ed25519.PublicKey{pub}}
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	assetID := bc.NewHash([32]byte{1})
+	out := a.Pay(tpl, 10, assetID, []byte("ref"))
+	if out.Quorum != 1 || len(out.Pubkeys) != 1 || !bytes.Equal(out.Pubkeys[0], pub) || out.Amount != 10 || out.AssetID != assetID {
+		t.Errorf("Pay added output %+v", out)
+	}
+}
@@ -0,0 +1,166 @@
+package address
+
+import (
+	"strings"
+
+	"i10r.io/errors"
+)
+
+// This file implements bech32m, as specified in BIP 350, without
+// BIP 173's limit of 90 characters, which a multisig address
+// exceeds.
+
+const charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+const bech32mConst = 0x2bc830a3
+
+var charsetRev = func() [128]int8 {
+	var rev [128]int8
+	for i := range rev {
+		rev[i] = -1
+	}
+	for i, c := range charset {
+		rev[c] = int8(i)
+	}
+	return rev
+}()
+
+func polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (b>>uint(i))&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func hrpExpand(hrp string) []byte {
+	res := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		res = append(res, hrp[i]>>5)
+	}
+	res = append(res, 0)
+	for i := 0; i < len(hrp); i++ {
+		res = append(res, hrp[i]&31)
+	}
+	return res
+}
+
+func checksum(hrp string, data []byte) []byte {
+	values := append(hrpExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	mod := polymod(values) ^ bech32mConst
+	res := make([]byte, 6)
+	for i := range res {
+		res[i] = byte(mod>>uint(5*(5-i))) & 31
+	}
+	return res
+}
+
+func validHRP(hrp string) bool {
+	if len(hrp) < 1 || len(hrp) > 83 {
+		return false
+	}
+	for i := 0; i < len(hrp); i++ {
+		if hrp[i] < 33 || hrp[i] > 126 || hrp[i] >= 'A' && hrp[i] <= 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// encode returns the bech32m string of hrp, which must be valid and
+// lowercase, and data, in bytes.
+func encode(hrp string, data []byte) string {
+	d := convertBits(data, 8, 5, true)
+	var b strings.Builder
+	b.WriteString(hrp)
+	b.WriteByte('1')
+	for _, v := range append(d, checksum(hrp, d)...) {
+		b.WriteByte(charset[v])
+	}
+	return b.String()
+}
+
+// decode5 parses a bech32m string, returning its lowercase hrp and
+// its data, in 5-bit groups, without the checksum.
+func decode5(s string) (hrp string, data []byte, err error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errors.WithDetail(ErrAddress, "mixed case")
+	}
+	s = strings.ToLower(s)
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 0 {
+		return "", nil, errors.WithDetail(ErrAddress, "no separator")
+	}
+	hrp = s[:sep]
+	if !validHRP(hrp) {
+		return "", nil, errors.WithDetailf(ErrAddress, "bad human-readable part %q", hrp)
+	}
+	if len(s)-sep-1 < 6 {
+		return "", nil, errors.WithDetail(ErrAddress, "too short")
+	}
+	d := make([]byte, 0, len(s)-sep-1)
+	for i := sep + 1; i < len(s); i++ {
+		c := s[i]
+		if c >= 128 || charsetRev[c] < 0 {
+			return "", nil, errors.WithDetailf(ErrAddress, "bad character %q", c)
+		}
+		d = append(d, byte(charsetRev[c]))
+	}
+	if polymod(append(hrpExpand(hrp), d...)) != bech32mConst {
+		return "", nil, errors.WithDetail(ErrAddress, "bad checksum")
+	}
+	return hrp, d[:len(d)-6], nil
+}
+
+// decode parses a bech32m string, returning its lowercase hrp and
+// its data, in bytes.
+func decode(s string) (hrp string, data []byte, err error) {
+	hrp, d, err := decode5(s)
+	if err != nil {
+		return "", nil, err
+	}
+	data = convertBits(d, 5, 8, false)
+	if data == nil {
+		return "", nil, errors.WithDetail(ErrAddress, "bad padding")
+	}
+	return hrp, data, nil
+}
+
+// convertBits regroups data from groups of from bits into groups of
+// to bits. Without pad, it returns nil if data has leftover bits
+// that are too many or nonzero.
+func convertBits(data []byte, from, to uint, pad bool) []byte {
+	var (
+		acc  uint32
+		bits uint
+		res  []byte
+		max  = uint32(1)<<to - 1
+	)
+	for _, v := range data {
+		acc = acc<<from | uint32(v)
+		bits += from
+		for bits >= to {
+			bits -= to
+			res = append(res, byte(acc>>bits&max))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			res = append(res, byte(acc<<(to-bits)&max))
+		}
+	} else if bits >= from || acc&(1<<bits-1) != 0 {
+		return nil
+	}
+	if res == nil {
+		res = []byte{}
+	}
+	return res
+}
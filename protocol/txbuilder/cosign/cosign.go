@@ -0,0 +1,242 @@
+// Package cosign coordinates signing by the keys of m-of-n accounts.
+//
+// A proposer submits a transaction template to a Coordinator, which
+// holds it as a proposal. Each cosigner fetches the proposal,
+// reviews the template, and approves it by sending back signatures
+// answering the template's signing instructions. Once every
+// issuance and input of the template has a quorum of signatures, the
+// Coordinator builds the transaction and broadcasts it.
+//
+// Proposers and cosigners reach the Coordinator through a Transport.
+// The Coordinator is itself the in-process Transport; Handler and
+// Client carry the same calls over HTTP.
+package cosign
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+)
+
+// ErrNotFound is returned for a proposal ID the coordinator does not
+// know, including one that has expired.
+var ErrNotFound = errors.New("proposal not found")
+
+// Status is the state of a proposal.
+type Status struct {
+	ID   string
+	Memo string
+
+	// Template is the proposed template with every signature
+	// collected so far.
+	Template *txbuilder.Template
+
+	// Complete says whether every issuance and input of Template has
+	// a quorum of signatures.
+	Complete bool
+
+	// TxID is the ID of the transaction, once it has been broadcast.
+	TxID *bc.Hash
+}
+
+// Transport carries the calls of proposers and cosigners to a
+// coordinator.
+type Transport interface {
+	// Propose submits a template for signing and returns the status
+	// of the new proposal. Any signatures the template already has
+	// count toward its quorums.
+	Propose(ctx context.Context, memo string, raw *txbuilder.RawTemplate) (*Status, error)
+
+	// Status returns the status of the proposal with the given ID.
+	Status(ctx context.Context, id string) (*Status, error)
+
+	// Approve adds signatures to the proposal with the given ID and
+	// returns its new status. If that completes the proposal, the
+	// transaction is broadcast.
+	Approve(ctx context.Context, id string, sigs []*txbuilder.Signature) (*Status, error)
+}
+
+// Coordinator holds proposals in memory and broadcasts them once
+// they are complete. It implements Transport.
+//
+// It is safe for concurrent use.
+type Coordinator struct {
+	// Broadcast submits a completed transaction to the network.
+	Broadcast func(context.Context, *bc.Tx) error
+
+	mu    sync.Mutex
+	props map[string]*proposal
+}
+
+type proposal struct {
+	mu   sync.Mutex // protects all fields but id and memo
+	id   string
+	memo string
+	tpl  *txbuilder.Template
+	txid *bc.Hash
+}
+
+// Propose implements Transport.
+func (c *Coordinator) Propose(ctx context.Context, memo string, raw *txbuilder.RawTemplate) (*Status, error) {
+	tpl, err := txbuilder.TemplateFromRaw(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing template")
+	}
+	if _, _, err := tpl.Materialize(); err != nil {
+		return nil, errors.Wrap(err, "building template")
+	}
+	var idbytes [16]byte
+	_, err = rand.Read(idbytes[:])
+	if err != nil {
+		return nil, err
+	}
+	p := &proposal{id: hex.EncodeToString(idbytes[:]), memo: memo, tpl: tpl}
+	c.mu.Lock()
+	if c.props == nil {
+		c.props = make(map[string]*proposal)
+	}
+	c.props[p.id] = p
+	c.mu.Unlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return c.advance(ctx, p)
+}
+
+// Status implements Transport.
+func (c *Coordinator) Status(ctx context.Context, id string) (*Status, error) {
+	p, err := c.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.status()
+}
+
+// Approve implements Transport. It rejects the whole approval if any
+// signature does not verify. Approving a complete proposal that
+// could not be broadcast retries the broadcast.
+func (c *Coordinator) Approve(ctx context.Context, id string, sigs []*txbuilder.Signature) (*Status, error) {
+	p, err := c.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.txid == nil {
+		err = p.tpl.AddSignatures(sigs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "approving %s", id)
+		}
+	}
+	return c.advance(ctx, p)
+}
+
+// Expire forgets the proposals whose templates expire before now and
+// returns how many there were.
+func (c *Coordinator) Expire(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int
+	for id, p := range c.props {
+		p.mu.Lock()
+		exp := p.tpl.MaxTimeMS
+		p.mu.Unlock()
+		if exp < bc.Millis(now) {
+			delete(c.props, id)
+			n++
+		}
+	}
+	return n
+}
+
+func (c *Coordinator) lookup(id string) (*proposal, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.props[id]
+	if !ok {
+		return nil, errors.WithDetailf(ErrNotFound, "proposal %s", id)
+	}
+	return p, nil
+}
+
+// advance broadcasts p's transaction if p is complete and not yet
+// broadcast. The caller must hold p.mu.
+func (c *Coordinator) advance(ctx context.Context, p *proposal) (*Status, error) {
+	st, err := p.status()
+	if err != nil || !st.Complete || p.txid != nil {
+		return st, err
+	}
+	tx, err := p.tpl.Tx()
+	if err != nil {
+		return nil, errors.Wrapf(err, "building transaction for %s", p.id)
+	}
+	if c.Broadcast != nil {
+		err = c.Broadcast(ctx, tx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "broadcasting %s", p.id)
+		}
+	}
+	p.txid = &tx.ID
+	st.TxID = p.txid
+	return st, nil
+}
+
+// status returns the status of p, with a copy of its template. The
+// caller must hold p.mu.
+func (p *proposal) status() (*Status, error) {
+	insts, err := p.tpl.SigningInstructions()
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := txbuilder.TemplateFromRaw(p.tpl.Raw())
+	if err != nil {
+		return nil, err
+	}
+	return &Status{
+		ID:       p.id,
+		Memo:     p.memo,
+		Template: tpl,
+		Complete: len(insts) == 0,
+		TxID:     p.txid,
+	}, nil
+}
+
+// Cosign signs the proposal with the given status using signFn,
+// which is called as by Template.Sign, and sends the signatures
+// through t. The caller should review st.Template first. The
+// signing messages are computed from the template itself, not taken
+// from the coordinator. Cosign returns the proposal's new status.
+func Cosign(ctx context.Context, t Transport, st *Status, signFn txbuilder.SignFunc) (*Status, error) {
+	insts, err := st.Template.SigningInstructions()
+	if err != nil {
+		return nil, err
+	}
+	var sigs []*txbuilder.Signature
+	for _, inst := range insts {
+		for i, kh := range inst.KeyHashes {
+			if len(kh) == 0 {
+				continue // already signed
+			}
+			sig, err := signFn(ctx, inst.Message, kh, inst.DerivationPath)
+			if err != nil {
+				return nil, errors.Wrapf(err, "signing entry %d", inst.EntryIndex)
+			}
+			if len(sig) > 0 {
+				sigs = append(sigs, &txbuilder.Signature{
+					EntryIndex: inst.EntryIndex,
+					KeyIndex:   uint64(i),
+					Signature:  sig,
+				})
+			}
+		}
+	}
+	return t.Approve(ctx, st.ID, sigs)
+}
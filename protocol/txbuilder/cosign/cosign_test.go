@@ -0,0 +1,129 @@
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+)
+
+// signer returns a SignFunc that signs with prv for its public key,
+// used as the key ID, and with no other key.
+func signer(prv ed25519.PrivateKey) txbuilder.SignFunc {
+	pub := prv.Public().(ed25519.PublicKey)
+	return func(_ context.Context, msg, keyID []byte, _ [][]byte) ([]byte, error) {
+		if !bytes.Equal(keyID, pub) {
+			return nil, nil
+		}
+		return ed25519.Sign(prv, msg), nil
+	}
+}
+
+func TestCoordinator(t *testing.T) {
+	var (
+		pubs   []ed25519.PublicKey
+		prvs   []ed25519.PrivateKey
+		keyIDs [][]byte
+	)
+	for i := 0; i < 3; i++ {
+		pub, prv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, pub)
+		prvs = append(prvs, prv)
+		keyIDs = append(keyIDs, pub)
+	}
+	var broadcast []*bc.Tx
+	c := &Coordinator{
+		Broadcast: func(_ context.Context, tx *bc.Tx) error {
+			broadcast = append(broadcast, tx)
+			return nil
+		},
+	}
+	srv := httptest.NewServer(Handler(c))
+	defer srv.Close()
+
+	for _, tr := range []struct {
+		name string
+		t    Transport
+	}{
+		{"in-process", c},
+		{"http", &Client{URL: srv.URL}},
+	} {
+		t.Run(tr.name, func(t *testing.T) {
+			broadcast = nil
+			ctx := context.Background()
+			assetID := bc.HashFromBytes([]byte("assetID"))
+			tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+			tpl.AddInput(2, keyIDs, nil, pubs, 10, assetID, []byte("anchor"), nil, 2)
+			tpl.AddOutput(1, pubs[:1], 10, assetID, nil, nil)
+
+			st, err := tr.t.Propose(ctx, "pay", tpl.Raw())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st.Complete || st.Memo != "pay" {
+				t.Fatalf("proposed: complete %v, memo %q", st.Complete, st.Memo)
+			}
+
+			// A signature by the wrong key is rejected.
+			bad := &txbuilder.Signature{EntryIndex: 0, KeyIndex: 1, Signature: make([]byte, 64)}
+			_, err = tr.t.Approve(ctx, st.ID, []*txbuilder.Signature{bad})
+			if err == nil {
+				t.Error("bad signature accepted")
+			}
+
+			st, err = tr.t.Status(ctx, st.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			st, err = Cosign(ctx, tr.t, st, signer(prvs[2]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if st.Complete || st.TxID != nil || len(broadcast) != 0 {
+				t.Fatalf("1 of 2: complete %v, txid %v, %d broadcast", st.Complete, st.TxID, len(broadcast))
+			}
+			st, err = Cosign(ctx, tr.t, st, signer(prvs[0]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !st.Complete || st.TxID == nil || len(broadcast) != 1 {
+				t.Fatalf("2 of 2: complete %v, txid %v, %d broadcast", st.Complete, st.TxID, len(broadcast))
+			}
+			if *st.TxID != broadcast[0].ID {
+				t.Errorf("txid %x, broadcast %x", st.TxID.Bytes(), broadcast[0].ID.Bytes())
+			}
+			if len(broadcast[0].Inputs) != 1 || len(broadcast[0].Outputs) != 1 {
+				t.Errorf("broadcast %d inputs, %d outputs, want 1, 1", len(broadcast[0].Inputs), len(broadcast[0].Outputs))
+			}
+
+			// Further approvals do not broadcast again.
+			st, err = Cosign(ctx, tr.t, st, signer(prvs[1]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(broadcast) != 1 {
+				t.Errorf("broadcast %d times", len(broadcast))
+			}
+
+			if _, err := tr.t.Status(ctx, "nonesuch"); errors.Root(err) != ErrNotFound {
+				t.Errorf("unknown proposal: got %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+
+	if n := c.Expire(time.Now()); n != 0 {
+		t.Errorf("expired %d proposals early", n)
+	}
+	if n := c.Expire(time.Now().Add(time.Hour)); n != 2 {
+		t.Errorf("expired %d proposals, want 2", n)
+	}
+}
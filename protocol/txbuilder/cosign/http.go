@@ -0,0 +1,211 @@
+package cosign
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/golang/protobuf/proto"
+
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+)
+
+// The HTTP API has three endpoints, all exchanging JSON. Templates
+// and signatures travel in their protobuf encodings, as hex.
+//
+//   POST /propose   {"memo": ..., "template": <RawTemplate>}
+//   GET  /status?id=<id>
+//   POST /approve   {"id": ..., "signatures": <SignResponse>}
+//
+// Each responds with a status object.
+
+type proposeRequest struct {
+	Memo     string             `json:"memo"`
+	Template chainjson.HexBytes `json:"template"`
+}
+
+type approveRequest struct {
+	ID         string             `json:"id"`
+	Signatures chainjson.HexBytes `json:"signatures"`
+}
+
+type statusResponse struct {
+	ID       string             `json:"id"`
+	Memo     string             `json:"memo"`
+	Template chainjson.HexBytes `json:"template"`
+	Complete bool               `json:"complete"`
+	TxID     *bc.Hash           `json:"tx_id,omitempty"`
+}
+
+// Handler returns an HTTP handler serving the calls of t.
+func Handler(t Transport) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/propose", func(w http.ResponseWriter, req *http.Request) {
+		var pr proposeRequest
+		if !decodePost(w, req, &pr) {
+			return
+		}
+		var raw txbuilder.RawTemplate
+		err := proto.Unmarshal(pr.Template, &raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		st, err := t.Propose(req.Context(), pr.Memo, &raw)
+		writeStatus(w, st, err)
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, req *http.Request) {
+		st, err := t.Status(req.Context(), req.URL.Query().Get("id"))
+		writeStatus(w, st, err)
+	})
+	mux.HandleFunc("/approve", func(w http.ResponseWriter, req *http.Request) {
+		var ar approveRequest
+		if !decodePost(w, req, &ar) {
+			return
+		}
+		var resp txbuilder.SignResponse
+		err := proto.Unmarshal(ar.Signatures, &resp)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		st, err := t.Approve(req.Context(), ar.ID, resp.Signatures)
+		writeStatus(w, st, err)
+	})
+	return mux
+}
+
+func decodePost(w http.ResponseWriter, req *http.Request, v interface{}) bool {
+	if req.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	err := json.NewDecoder(req.Body).Decode(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeStatus(w http.ResponseWriter, st *Status, err error) {
+	if errors.Root(err) == ErrNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tpl, err := proto.Marshal(st.Template.Raw())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statusResponse{
+		ID:       st.ID,
+		Memo:     st.Memo,
+		Template: tpl,
+		Complete: st.Complete,
+		TxID:     st.TxID,
+	})
+}
+
+// Client is a Transport that calls a coordinator served by Handler at
+// URL.
+type Client struct {
+	URL    string
+	Client *http.Client // if nil, http.DefaultClient is used
+}
+
+// Propose implements Transport.
+func (c *Client) Propose(ctx context.Context, memo string, raw *txbuilder.RawTemplate) (*Status, error) {
+	tpl, err := proto.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	return c.post(ctx, "/propose", proposeRequest{Memo: memo, Template: tpl})
+}
+
+// Status implements Transport.
+func (c *Client) Status(ctx context.Context, id string) (*Status, error) {
+	req, err := http.NewRequest("GET", c.URL+"/status?id="+url.QueryEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req.WithContext(ctx))
+}
+
+// Approve implements Transport.
+func (c *Client) Approve(ctx context.Context, id string, sigs []*txbuilder.Signature) (*Status, error) {
+	b, err := proto.Marshal(&txbuilder.SignResponse{Signatures: sigs})
+	if err != nil {
+		return nil, err
+	}
+	return c.post(ctx, "/approve", approveRequest{ID: id, Signatures: b})
+}
+
+func (c *Client) post(ctx context.Context, path string, v interface{}) (*Status, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", c.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req.WithContext(ctx))
+}
+
+func (c *Client) do(req *http.Request) (*Status, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	bits, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errors.WithDetail(ErrNotFound, string(bytes.TrimSpace(bits)))
+	default:
+		return nil, fmt.Errorf("%s %s: %s: %s", req.Method, req.URL, resp.Status, bytes.TrimSpace(bits))
+	}
+	var sr statusResponse
+	err = json.Unmarshal(bits, &sr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding %s", req.URL)
+	}
+	var raw txbuilder.RawTemplate
+	err = proto.Unmarshal(sr.Template, &raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding template from %s", req.URL)
+	}
+	tpl, err := txbuilder.TemplateFromRaw(&raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding template from %s", req.URL)
+	}
+	return &Status{
+		ID:       sr.ID,
+		Memo:     sr.Memo,
+		Template: tpl,
+		Complete: sr.Complete,
+		TxID:     sr.TxID,
+	}, nil
+}
@@ -0,0 +1,433 @@
+// Package descriptor implements output descriptors: strings that
+// describe a family of output predicates, for wallets to derive
+// addresses from and to scan blocks with.
+//
+// The grammar is
+//
+//	desc  = "pk(" key ")"
+//	      | "multi(" quorum "," key { "," key } ")"
+//	      | "after(" ms "," inner ")"
+//	inner = "pk(" key ")" | "multi(" quorum "," key { "," key } ")"
+//	key   = pubkey | xpub { "/" index } [ "/*" ]
+//
+// where pubkey is a hex ed25519 public key, xpub a hex chainkd
+// extended public key, and quorum, ms, and index decimal integers.
+// pk and multi describe the standard pay-to-multisig contract, and
+// after the standard vesting contract, locking its value until the
+// given time in milliseconds and then paying it to its inner
+// predicate.
+//
+// An xpub key stands for the key derived from it along its path,
+// each index a selector of 8 bytes, little-endian. A path ending in
+// "*" makes the descriptor ranged: it describes one predicate for
+// each index in place of the "*" (see Expand).
+package descriptor
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/ed25519/chainkd"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/address"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+// ErrSyntax is returned for a malformed descriptor.
+var ErrSyntax = errors.New("descriptor syntax error")
+
+// ErrNoAddress is returned by Address for a descriptor that has no
+// address.
+var ErrNoAddress = errors.New("descriptor has no address")
+
+// Descriptor is a parsed descriptor.
+type Descriptor struct {
+	Quorum int
+	Keys   []Key
+
+	// UnlockMS, if nonzero, is the time of an after descriptor.
+	UnlockMS uint64
+}
+
+// Key is a key of a descriptor: Pubkey, or a key derived from XPub.
+type Key struct {
+	Pubkey ed25519.PublicKey
+
+	XPub     *chainkd.XPub
+	Path     []uint64
+	Wildcard bool
+}
+
+// Parse parses a descriptor.
+func Parse(s string) (*Descriptor, error) {
+	p := &parser{s: s}
+	d, err := p.desc(true)
+	if err != nil {
+		return nil, err
+	}
+	if p.s != "" {
+		return nil, p.errorf("trailing %q", p.s)
+	}
+	return d, nil
+}
+
+type parser struct {
+	s string
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return errors.WithDetailf(ErrSyntax, format, args...)
+}
+
+func (p *parser) consume(prefix string) bool {
+	if strings.HasPrefix(p.s, prefix) {
+		p.s = p.s[len(prefix):]
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(prefix string) error {
+	if !p.consume(prefix) {
+		return p.errorf("expected %q at %q", prefix, p.s)
+	}
+	return nil
+}
+
+// token returns the text up to the next delimiter.
+func (p *parser) token() string {
+	i := strings.IndexAny(p.s, ",()/")
+	if i < 0 {
+		i = len(p.s)
+	}
+	tok := p.s[:i]
+	p.s = p.s[i:]
+	return tok
+}
+
+func (p *parser) uint(what string) (uint64, error) {
+	tok := p.token()
+	n, err := strconv.ParseUint(tok, 10, 64)
+	if err != nil {
+		return 0, p.errorf("bad %s %q", what, tok)
+	}
+	return n, nil
+}
+
+func (p *parser) desc(top bool) (*Descriptor, error) {
+	switch {
+	case p.consume("pk("):
+		k, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		return &Descriptor{Quorum: 1, Keys: []Key{k}}, p.expect(")")
+
+	case p.consume("multi("):
+		q, err := p.uint("quorum")
+		if err != nil {
+			return nil, err
+		}
+		d := new(Descriptor)
+		for p.consume(",") {
+			k, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			d.Keys = append(d.Keys, k)
+		}
+		if q < 1 || q > uint64(len(d.Keys)) {
+			return nil, p.errorf("quorum %d of %d keys", q, len(d.Keys))
+		}
+		d.Quorum = int(q)
+		return d, p.expect(")")
+
+	case top && p.consume("after("):
+		ms, err := p.uint("time")
+		if err != nil {
+			return nil, err
+		}
+		if ms == 0 {
+			return nil, p.errorf("zero time")
+		}
+		err = p.expect(",")
+		if err != nil {
+			return nil, err
+		}
+		d, err := p.desc(false)
+		if err != nil {
+			return nil, err
+		}
+		d.UnlockMS = ms
+		return d, p.expect(")")
+	}
+	return nil, p.errorf("unknown expression at %q", p.s)
+}
+
+func (p *parser) key() (Key, error) {
+	tok := p.token()
+	b, err := hex.DecodeString(tok)
+	if err != nil {
+		return Key{}, p.errorf("bad key %q", tok)
+	}
+	switch len(b) {
+	case ed25519.PublicKeySize:
+		return Key{Pubkey: ed25519.PublicKey(b)}, nil
+	case len(chainkd.XPub{}):
+	default:
+		return Key{}, p.errorf("%d-byte key", len(b))
+	}
+	k := Key{XPub: new(chainkd.XPub)}
+	copy(k.XPub[:], b)
+	for p.consume("/") {
+		if p.consume("*") {
+			k.Wildcard = true
+			break
+		}
+		n, err := p.uint("index")
+		if err != nil {
+			return Key{}, err
+		}
+		k.Path = append(k.Path, n)
+	}
+	return k, nil
+}
+
+// String returns d in the form Parse parses.
+func (d *Descriptor) String() string {
+	keys := make([]string, 0, len(d.Keys))
+	for _, k := range d.Keys {
+		keys = append(keys, k.String())
+	}
+	var s string
+	if len(d.Keys) == 1 && d.Quorum == 1 {
+		s = fmt.Sprintf("pk(%s)", keys[0])
+	} else {
+		s = fmt.Sprintf("multi(%d,%s)", d.Quorum, strings.Join(keys, ","))
+	}
+	if d.UnlockMS > 0 {
+		s = fmt.Sprintf("after(%d,%s)", d.UnlockMS, s)
+	}
+	return s
+}
+
+// String returns k in the form Parse parses.
+func (k Key) String() string {
+	if k.XPub == nil {
+		return hex.EncodeToString(k.Pubkey)
+	}
+	var b strings.Builder
+	b.WriteString(k.XPub.String())
+	for _, n := range k.Path {
+		fmt.Fprintf(&b, "/%d", n)
+	}
+	if k.Wildcard {
+		b.WriteString("/*")
+	}
+	return b.String()
+}
+
+// Ranged reports whether d has a key with a wildcard.
+func (d *Descriptor) Ranged() bool {
+	for _, k := range d.Keys {
+		if k.Wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Predicate is a concrete predicate described by a descriptor.
+type Predicate struct {
+	Quorum   int
+	Pubkeys  []ed25519.PublicKey
+	UnlockMS uint64
+
+	// Paths are the derivation paths, from their xpubs, of
+	// Pubkeys, for signing; nil for a key given as a pubkey.
+	Paths [][][]byte
+}
+
+// Expand returns the predicate d describes for the given index,
+// which is ignored if d is not ranged.
+func (d *Descriptor) Expand(index uint64) *Predicate {
+	p := &Predicate{Quorum: d.Quorum, UnlockMS: d.UnlockMS}
+	for _, k := range d.Keys {
+		if k.XPub == nil {
+			p.Pubkeys = append(p.Pubkeys, k.Pubkey)
+			p.Paths = append(p.Paths, nil)
+			continue
+		}
+		path := k.Path
+		if k.Wildcard {
+			path = append(path[:len(path):len(path)], index)
+		}
+		sels := make([][]byte, 0, len(path))
+		for _, n := range path {
+			sel := make([]byte, 8)
+			binary.LittleEndian.PutUint64(sel, n)
+			sels = append(sels, sel)
+		}
+		p.Pubkeys = append(p.Pubkeys, k.XPub.Derive(sels).PublicKey())
+		p.Paths = append(p.Paths, sels)
+	}
+	return p
+}
+
+// Address returns the address of d for the given index on the
+// network with the given human-readable part. It returns
+// ErrNoAddress for an after descriptor, which is not a
+// pay-to-multisig predicate.
+func (d *Descriptor) Address(hrp string, index uint64) (string, error) {
+	if d.UnlockMS > 0 {
+		return "", errors.WithDetail(ErrNoAddress, "after descriptor")
+	}
+	p := d.Expand(index)
+	a := &address.Address{HRP: hrp, Quorum: p.Quorum, Pubkeys: p.Pubkeys}
+	return a.Encode()
+}
+
+// Lock writes txvm bytecode to b that locks a value with p, with the
+// given refdata. The caller must first put the value on the argument
+// stack.
+func (p *Predicate) Lock(b *txvmutil.Builder, refdata []byte) {
+	if p.UnlockMS > 0 {
+		s := &standard.VestingSchedule{
+			Quorum:   p.Quorum,
+			Pubkeys:  p.Pubkeys,
+			Tranches: []standard.VestingTranche{{UnlockMS: p.UnlockMS}},
+		}
+		standard.FundVesting(b, s, refdata)
+		return
+	}
+	b.Op(op.Get)                              // get
+	b.PushdataBytes(refdata).Op(op.Put)       // '<refdata>' put
+	b.PushdataBytes(nil).Op(op.Put)           // '' put (no tags)
+	b.Op(op.Put)                              // put
+	b.Tuple(func(tb *txvmutil.TupleBuilder) { // {<pubkeys>}
+		for _, pub := range p.Pubkeys {
+			tb.PushdataBytes(pub)
+		}
+	})
+	b.Op(op.Put)                                 // put
+	b.PushdataInt64(int64(p.Quorum)).Op(op.Put)  // <quorum> put
+	b.PushdataBytes(standard.PayToMultisigProg2) // [<multisig program>]
+	b.Op(op.Contract).Op(op.Call)                // contract call
+}
+
+// Matches reports whether out, an output of a transaction, is locked
+// with p.
+func (p *Predicate) Matches(out bc.Output) bool {
+	var quorum int64
+	var pubkeys txvm.Tuple
+	if p.UnlockMS > 0 {
+		// [{int unlock} {tuple {quorum {p1,...,p_n}}} value]
+		if out.Seed.Byte32() != standard.VestingSeed || len(out.Stack) != 3 {
+			return false
+		}
+		if unlock, ok := stackInt(out.Stack[0]); !ok || uint64(unlock) != p.UnlockMS {
+			return false
+		}
+		party, ok := stackTuple(out.Stack[1])
+		if !ok || len(party) != 2 {
+			return false
+		}
+		q, ok := party[0].(txvm.Int)
+		if !ok {
+			return false
+		}
+		quorum = int64(q)
+		if pubkeys, ok = party[1].(txvm.Tuple); !ok {
+			return false
+		}
+	} else {
+		// [{int quorum} {tuple {p1,...,p_n}} value]
+		if out.Seed.Byte32() != standard.PayToMultisigSeed1 && out.Seed.Byte32() != standard.PayToMultisigSeed2 {
+			return false
+		}
+		if len(out.Stack) != 3 {
+			return false
+		}
+		var ok bool
+		if quorum, ok = stackInt(out.Stack[0]); !ok {
+			return false
+		}
+		if pubkeys, ok = stackTuple(out.Stack[1]); !ok {
+			return false
+		}
+	}
+	if quorum != int64(p.Quorum) || len(pubkeys) != len(p.Pubkeys) {
+		return false
+	}
+	for i, pk := range pubkeys {
+		if b, ok := pk.(txvm.Bytes); !ok || string(b) != string(p.Pubkeys[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// stackInt and stackTuple unwrap the int or tuple of a contract
+// stack item, as the log records it.
+func stackInt(item txvm.Data) (int64, bool) {
+	t, ok := item.(txvm.Tuple)
+	if !ok || len(t) != 2 {
+		return 0, false
+	}
+	if code, ok := t[0].(txvm.Bytes); !ok || len(code) != 1 || code[0] != txvm.IntCode {
+		return 0, false
+	}
+	n, ok := t[1].(txvm.Int)
+	return int64(n), ok
+}
+
+func stackTuple(item txvm.Data) (txvm.Tuple, bool) {
+	t, ok := item.(txvm.Tuple)
+	if !ok || len(t) != 2 {
+		return nil, false
+	}
+	if code, ok := t[0].(txvm.Bytes); !ok || len(code) != 1 || code[0] != txvm.TupleCode {
+		return nil, false
+	}
+	inner, ok := t[1].(txvm.Tuple)
+	return inner, ok
+}
+
+// Match is an output matched by Scan.
+type Match struct {
+	Output    bc.Output
+	Index     uint64 // the index expanding the descriptor
+	Predicate *Predicate
+}
+
+// Scan returns the outputs of tx that d describes, trying the
+// indexes from 0 to gap-1 if d is ranged. A wallet typically passes
+// a gap past the greatest index in use.
+func (d *Descriptor) Scan(tx *bc.Tx, gap uint64) []Match {
+	n := uint64(1)
+	if d.Ranged() {
+		n = gap
+	}
+	preds := make([]*Predicate, 0, n)
+	for i := uint64(0); i < n; i++ {
+		preds = append(preds, d.Expand(i))
+	}
+	var matches []Match
+	for _, out := range tx.Outputs {
+		for i, p := range preds {
+			if p.Matches(out) {
+				matches = append(matches, Match{Output: out, Index: uint64(i), Predicate: p})
+				break
+			}
+		}
+	}
+	return matches
+}
@@ -0,0 +1,154 @@
+package descriptor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/address"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/txvmutil"
+	"i10r.io/testutil"
+)
+
+func TestParse(t *testing.T) {
+	pub := fmt.Sprintf("%x", []byte(testutil.TestPub))
+	xpub := testutil.TestXPub.String()
+	for _, s := range []string{
+		"pk(" + pub + ")",
+		"pk(" + xpub + ")",
+		"pk(" + xpub + "/1/2/*)",
+		"multi(2," + pub + "," + xpub + "/0/*," + xpub + "/7)",
+		"after(1500000000000,pk(" + pub + "))",
+		"after(1500000000000,multi(1," + pub + "," + xpub + "/*))",
+	} {
+		d, err := Parse(s)
+		if err != nil {
+			t.Errorf("Parse(%s): %s", s, err)
+			continue
+		}
+		if got := d.String(); got != s {
+			t.Errorf("Parse(%s).String() = %s", s, got)
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"pk()",
+		"pk(" + pub,
+		"pk(" + pub + "))",
+		"pk(" + pub + "/1)",
+		"pk(" + pub[:62] + ")",
+		"pk(" + xpub + "/x)",
+		"pk(" + xpub + "/*/1)",
+		"multi(0," + pub + ")",
+		"multi(2," + pub + ")",
+		"multi(1)",
+		"after(0,pk(" + pub + "))",
+		"after(1,after(2,pk(" + pub + ")))",
+		"wpkh(" + pub + ")",
+	} {
+		if _, err := Parse(s); errors.Root(err) != ErrSyntax {
+			t.Errorf("Parse(%q): got error %v, want %v", s, err, ErrSyntax)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	xpub := testutil.TestXPub.String()
+	d, err := Parse("multi(1," + xpub + "/5/*," + xpub + ")")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Ranged() {
+		t.Error("ranged descriptor not Ranged")
+	}
+	p := d.Expand(9)
+	path := [][]byte{sel(5), sel(9)}
+	if want := testutil.TestXPrv.Derive(path).XPub().PublicKey(); !bytes.Equal(p.Pubkeys[0], want) {
+		t.Errorf("expanded key %x, want %x", p.Pubkeys[0], want)
+	}
+	if len(p.Paths[0]) != 2 || !bytes.Equal(p.Paths[0][1], path[1]) {
+		t.Errorf("expanded path %x, want %x", p.Paths[0], path)
+	}
+	if !bytes.Equal(p.Pubkeys[1], testutil.TestPub) || len(p.Paths[1]) != 0 {
+		t.Errorf("expanded unranged key %x, path %x", p.Pubkeys[1], p.Paths[1])
+	}
+
+	s, err := d.Address(address.Testnet, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := address.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Quorum != 1 || len(a.Pubkeys) != 2 || !bytes.Equal(a.Pubkeys[0], p.Pubkeys[0]) {
+		t.Errorf("address %s is for %+v, want %+v", s, a, p)
+	}
+
+	d, err = Parse("after(10,pk(" + xpub + "))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Address(address.Mainnet, 0); errors.Root(err) != ErrNoAddress {
+		t.Errorf("Address(after): got error %v, want %v", err, ErrNoAddress)
+	}
+}
+
+func TestScan(t *testing.T) {
+	xpub := testutil.TestXPub.String()
+	for _, s := range []string{
+		"pk(" + xpub + "/*)",
+		"after(40000,multi(1," + xpub + "/*," + xpub + "))",
+	} {
+		d, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Lock a value from a 0-of-0 multisig output with the
+		// predicate for index 3.
+		assetID := bc.HashFromBytes([]byte("assetID"))
+		var b txvmutil.Builder
+		b.Concat(mustAssemble("'' put"))
+		standard.SpendMultisig(&b, 0, nil, 1000, assetID, []byte("anchor"), standard.PayToMultisigSeed2[:])
+		b.Concat(mustAssemble("get get put"))
+		d.Expand(3).Lock(&b, []byte("ref"))
+		b.Concat(mustAssemble("'' put call 'id' 10 nonce finalize"))
+		tx, err := bc.NewTx(b.Build(), 3, 100000)
+		if err != nil {
+			t.Fatalf("%s: %s", s, err)
+		}
+
+		if got := d.Scan(tx, 3); len(got) != 0 {
+			t.Errorf("%s: Scan with gap 3 found %d outputs", s, len(got))
+		}
+		got := d.Scan(tx, 10)
+		if len(got) != 1 || got[0].Index != 3 || got[0].Output.ID != tx.Outputs[0].ID {
+			t.Errorf("%s: Scan with gap 10 = %+v, want output %x at index 3", s, got, tx.Outputs[0].ID.Bytes())
+		}
+		if strings.HasPrefix(s, "after") && tx.Outputs[0].Seed.Byte32() != standard.VestingSeed {
+			t.Errorf("%s: output seed %x, want the vesting seed", s, tx.Outputs[0].Seed.Bytes())
+		}
+	}
+}
+
+func sel(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, n)
+	return b
+}
+
+func mustAssemble(src string) []byte {
+	prog, err := asm.Assemble(src)
+	if err != nil {
+		panic(err)
+	}
+	return prog
+}
@@ -0,0 +1,3 @@
+package txbuilder
+
+//go:generate protoc -I$I10R/../ -I./ --go_out=. template.proto
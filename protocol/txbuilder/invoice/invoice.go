@@ -0,0 +1,265 @@
+// Package invoice implements signed payment requests.
+//
+// An Invoice asks for an amount of an asset to be paid to an output
+// locked with the standard pay-to-multisig contract to the payee's
+// keys, before an expiration time. The payee signs it, so a payer
+// who knows the payee's key can check that the request is genuine
+// before paying. Invoices travel as URIs (see URI and Parse), which
+// also serve as the content of QR codes. Pay and PayFrom add the
+// payment to a transaction template.
+//
+// A payee whose key rotates (see package identity) attaches the
+// certificates leading from its root key to the signing key, so a
+// payer who knows only the root key can check the invoice with
+// VerifyPayee.
+package invoice
+
+import (
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/identity"
+	"i10r.io/protocol/logdata"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/account"
+	"i10r.io/protocol/txvm"
+)
+
+// Scheme is the URI scheme of invoices.
+const Scheme = "txvm"
+
+var (
+	// ErrInvalid is returned for an invoice requesting a payment
+	// that cannot be made.
+	ErrInvalid = errors.New("invalid invoice")
+
+	// ErrSignature is returned for an invoice without a valid payee
+	// signature.
+	ErrSignature = errors.New("invalid invoice signature")
+
+	// ErrExpired is returned for an invoice used after its
+	// expiration time.
+	ErrExpired = errors.New("invoice expired")
+
+	// ErrURI is returned by Parse for a malformed invoice URI.
+	ErrURI = errors.New("malformed invoice URI")
+)
+
+// Invoice is a payee's signed request for payment. The payment is
+// an output of Amount units of AssetID to the standard
+// pay-to-multisig contract with Quorum and Pubkeys, carrying RefData
+// as its reference data so the payee can match it to the invoice.
+type Invoice struct {
+	AssetID   bc.Hash             `json:"asset_id"`
+	Amount    int64               `json:"amount"`
+	Quorum    int                 `json:"quorum"`
+	Pubkeys   []ed25519.PublicKey `json:"pubkeys"`
+	RefData   chainjson.HexBytes  `json:"reference_data"`
+	ExpMS     uint64              `json:"expiration_ms"`
+	Memo      string              `json:"memo"`
+	Payee     ed25519.PublicKey   `json:"payee"`
+	Signature chainjson.HexBytes  `json:"signature"`
+
+	// PayeeCerts, if any, rotate the payee's identity from its root
+	// key to Payee. They are signed on their own and not covered by
+	// Signature.
+	PayeeCerts []*identity.Cert `json:"payee_certs,omitempty"`
+}
+
+// SigningMessage returns the message the payee signs. It covers
+// every field but the signature, encoded as a txvm tuple.
+func (inv *Invoice) SigningMessage() []byte {
+	pubkeys := make(txvm.Tuple, 0, len(inv.Pubkeys))
+	for _, pk := range inv.Pubkeys {
+		pubkeys = append(pubkeys, txvm.Bytes(pk))
+	}
+	tup := txvm.Tuple{
+		txvm.Bytes(inv.AssetID.Bytes()),
+		txvm.Int(inv.Amount),
+		txvm.Int(inv.Quorum),
+		pubkeys,
+		txvm.Bytes(inv.RefData),
+		txvm.Int(inv.ExpMS),
+		txvm.Bytes(inv.Memo),
+		txvm.Bytes(inv.Payee),
+	}
+	h := txvm.VMHash("Invoice", txvm.Encode(tup))
+	return h[:]
+}
+
+// Ref returns a log data entry referring to inv, for a payer to
+// log as transaction tags (see logdata.InvoiceRef).
+func (inv *Invoice) Ref() *logdata.InvoiceRef {
+	return &logdata.InvoiceRef{
+		Payee: inv.Payee,
+		Hash:  bc.HashFromBytes(inv.SigningMessage()),
+	}
+}
+
+// Sign sets the payee to the public key of prv and signs inv.
+func (inv *Invoice) Sign(prv ed25519.PrivateKey) {
+	inv.Payee = prv.Public().(ed25519.PublicKey)
+	inv.Signature = ed25519.Sign(prv, inv.SigningMessage())
+}
+
+// SignAs signs inv with prv, the private key of id's current key,
+// attaching id's certificates.
+func (inv *Invoice) SignAs(id *identity.Identity, prv ed25519.PrivateKey) {
+	inv.Sign(prv)
+	inv.PayeeCerts = id.Certs
+}
+
+// Verify checks that inv is well formed, unexpired at now, and
+// signed by its payee. Checking that the payee is who the payer
+// means to pay is up to the caller; see VerifyPayee.
+func (inv *Invoice) Verify(now time.Time) error {
+	if inv.Amount <= 0 {
+		return errors.WithDetailf(ErrInvalid, "amount %d", inv.Amount)
+	}
+	if inv.Quorum < 1 || inv.Quorum > len(inv.Pubkeys) {
+		return errors.WithDetailf(ErrInvalid, "quorum %d of %d keys", inv.Quorum, len(inv.Pubkeys))
+	}
+	if len(inv.Payee) != ed25519.PublicKeySize || !ed25519.Verify(inv.Payee, inv.SigningMessage(), inv.Signature) {
+		return errors.Wrap(ErrSignature)
+	}
+	if bc.Millis(now) > inv.ExpMS {
+		return errors.WithDetailf(ErrExpired, "expired at %d", inv.ExpMS)
+	}
+	return nil
+}
+
+// VerifyPayee verifies inv and checks that its payee is, at now,
+// the key of the identity with root key root, by way of inv's
+// payee certificates.
+func (inv *Invoice) VerifyPayee(root ed25519.PublicKey, now time.Time) error {
+	if err := inv.Verify(now); err != nil {
+		return err
+	}
+	id := &identity.Identity{Root: root, Certs: inv.PayeeCerts}
+	return errors.Wrap(id.CheckKey(inv.Payee, now), "invoice payee")
+}
+
+// URI returns inv as a URI of the form
+// txvm:<payee>?asset=<id>&amount=<n>&quorum=<n>&pubkeys=<k1>,<k2>&ref=<data>&exp=<ms>&memo=<text>&sig=<sig>&certs=<certs>,
+// with binary fields in hex and certs, if any, the concatenated
+// binary encodings of the payee certificates.
+func (inv *Invoice) URI() string {
+	pubkeys := make([]string, 0, len(inv.Pubkeys))
+	for _, pk := range inv.Pubkeys {
+		pubkeys = append(pubkeys, hex.EncodeToString(pk))
+	}
+	q := url.Values{}
+	q.Set("asset", hex.EncodeToString(inv.AssetID.Bytes()))
+	q.Set("amount", strconv.FormatInt(inv.Amount, 10))
+	q.Set("quorum", strconv.Itoa(inv.Quorum))
+	q.Set("pubkeys", strings.Join(pubkeys, ","))
+	if len(inv.RefData) > 0 {
+		q.Set("ref", hex.EncodeToString(inv.RefData))
+	}
+	q.Set("exp", strconv.FormatUint(inv.ExpMS, 10))
+	if inv.Memo != "" {
+		q.Set("memo", inv.Memo)
+	}
+	q.Set("sig", hex.EncodeToString(inv.Signature))
+	if len(inv.PayeeCerts) > 0 {
+		var certs []byte
+		for _, c := range inv.PayeeCerts {
+			certs = append(certs, c.Bytes()...)
+		}
+		q.Set("certs", hex.EncodeToString(certs))
+	}
+	u := url.URL{Scheme: Scheme, Opaque: hex.EncodeToString(inv.Payee), RawQuery: q.Encode()}
+	return u.String()
+}
+
+// Parse parses an invoice URI produced by URI. It does not verify
+// the invoice.
+func Parse(s string) (*Invoice, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, errors.Sub(ErrURI, err)
+	}
+	if u.Scheme != Scheme {
+		return nil, errors.WithDetailf(ErrURI, "scheme %q", u.Scheme)
+	}
+	q, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return nil, errors.Sub(ErrURI, err)
+	}
+	var (
+		inv  = &Invoice{Memo: q.Get("memo")}
+		perr error
+	)
+	hexField := func(name, s string) []byte {
+		b, err := hex.DecodeString(s)
+		if err != nil && perr == nil {
+			perr = errors.WithDetailf(ErrURI, "%s: %s", name, err)
+		}
+		return b
+	}
+	inv.Payee = hexField("payee", u.Opaque)
+	inv.AssetID = bc.HashFromBytes(hexField("asset", q.Get("asset")))
+	if ps := q.Get("pubkeys"); ps != "" {
+		for _, p := range strings.Split(ps, ",") {
+			inv.Pubkeys = append(inv.Pubkeys, hexField("pubkeys", p))
+		}
+	}
+	inv.RefData = hexField("ref", q.Get("ref"))
+	inv.Signature = hexField("sig", q.Get("sig"))
+	certs := hexField("certs", q.Get("certs"))
+	if perr != nil {
+		return nil, perr
+	}
+	if inv.PayeeCerts, err = identity.ParseCerts(certs); err != nil {
+		return nil, errors.Sub(ErrURI, err)
+	}
+	if inv.Amount, err = strconv.ParseInt(q.Get("amount"), 10, 64); err != nil {
+		return nil, errors.WithDetailf(ErrURI, "amount: %s", err)
+	}
+	if inv.Quorum, err = strconv.Atoi(q.Get("quorum")); err != nil {
+		return nil, errors.WithDetailf(ErrURI, "quorum: %s", err)
+	}
+	if inv.ExpMS, err = strconv.ParseUint(q.Get("exp"), 10, 64); err != nil {
+		return nil, errors.WithDetailf(ErrURI, "exp: %s", err)
+	}
+	return inv, nil
+}
+
+// Pay adds to tpl the output inv requests and restricts tpl's
+// maximum time to inv's expiration. The caller must add inputs
+// supplying the value.
+func (inv *Invoice) Pay(tpl *txbuilder.Template) *txbuilder.Output {
+	tpl.RestrictMaxTime(bc.FromMillis(inv.ExpMS))
+	return tpl.AddOutput(inv.Quorum, inv.Pubkeys, inv.Amount, inv.AssetID, inv.RefData, nil)
+}
+
+// PayFrom verifies inv and returns an unsigned template paying it
+// from the outputs tracked by tr, valid until the invoice expires or
+// maxTime, whichever is earlier. Change goes to the account key
+// change. It returns the outputs spent; once the template is signed
+// and submitted, its transaction should be passed to
+// tr.AddPending.
+func PayFrom(tr *account.Tracker, inv *Invoice, change ed25519.PublicKey, now, maxTime time.Time) (*txbuilder.Template, []*account.UTXO, error) {
+	err := inv.Verify(now)
+	if err != nil {
+		return nil, nil, err
+	}
+	us, total, err := tr.Select(inv.AssetID, inv.Amount)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "paying invoice")
+	}
+	tpl := txbuilder.NewTemplate(maxTime, nil)
+	account.AddInputs(tpl, us)
+	inv.Pay(tpl)
+	if c := total - inv.Amount; c > 0 {
+		tpl.AddOutput(1, []ed25519.PublicKey{change}, c, inv.AssetID, nil, nil)
+	}
+	return tpl, us, nil
+}
@@ -0,0 +1,183 @@
+package invoice
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/identity"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/account"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txbuilder/txresult"
+	"i10r.io/testutil"
+)
+
+func testInvoice(t *testing.T, now time.Time) (*Invoice, ed25519.PrivateKey) {
+	_, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv := &Invoice{
+		AssetID: bc.NewHash(standard.AssetID(2, 1, testutil.TestPubs, nil)),
+		Amount:  25,
+		Quorum:  1,
+		Pubkeys: []ed25519.PublicKey{prv.Public().(ed25519.PublicKey)},
+		RefData: []byte("order 1234"),
+		ExpMS:   bc.Millis(now.Add(time.Hour)),
+		Memo:    "2 widgets & 1 gadget",
+	}
+	inv.Sign(prv)
+	return inv, prv
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	inv, _ := testInvoice(t, now)
+	if err := inv.Verify(now); err != nil {
+		t.Fatal(err)
+	}
+	if err := inv.Verify(now.Add(2 * time.Hour)); errors.Root(err) != ErrExpired {
+		t.Errorf("after expiry: got %v, want %v", err, ErrExpired)
+	}
+	altered := *inv
+	altered.Amount++
+	if err := altered.Verify(now); errors.Root(err) != ErrSignature {
+		t.Errorf("altered amount: got %v, want %v", err, ErrSignature)
+	}
+	altered = *inv
+	altered.Quorum = 2
+	if err := altered.Verify(now); errors.Root(err) != ErrInvalid {
+		t.Errorf("quorum 2 of 1: got %v, want %v", err, ErrInvalid)
+	}
+}
+
+func TestVerifyPayee(t *testing.T) {
+	now := time.Now()
+	inv, rootPrv := testInvoice(t, now)
+	root := rootPrv.Public().(ed25519.PublicKey)
+	if err := inv.VerifyPayee(root, now); err != nil {
+		t.Fatal(err)
+	}
+
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := &identity.Identity{Root: root}
+	err = id.Rotate(rootPrv, pub, now.Add(-time.Minute), time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inv.SignAs(id, prv)
+	got, err := Parse(inv.URI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.VerifyPayee(root, now); err != nil {
+		t.Error(err)
+	}
+	if err := got.VerifyPayee(pub, now); errors.Root(err) != identity.ErrChain {
+		t.Errorf("payee as root: got %v, want %v", err, identity.ErrChain)
+	}
+	got.PayeeCerts = nil
+	if err := got.VerifyPayee(root, now); errors.Root(err) != identity.ErrKey {
+		t.Errorf("without certificates: got %v, want %v", err, identity.ErrKey)
+	}
+}
+
+func TestURI(t *testing.T) {
+	inv, _ := testInvoice(t, time.Now())
+	got, err := Parse(inv.URI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, inv) {
+		t.Errorf("Parse(URI()) = %+v, want %+v", got, inv)
+	}
+	for _, s := range []string{
+		"http://example.com/",
+		"txvm:zz?amount=1&quorum=1&exp=1",
+		"txvm:00?amount=x&quorum=1&exp=1",
+	} {
+		if _, err := Parse(s); errors.Root(err) != ErrURI {
+			t.Errorf("Parse(%q): got %v, want %v", s, err, ErrURI)
+		}
+	}
+}
+
+func TestPayFrom(t *testing.T) {
+	now := time.Now()
+	inv, _ := testInvoice(t, now)
+
+	path := [][]byte{[]byte("payer")}
+	pub := testutil.TestXPrv.Derive(path).XPub().PublicKey()
+	tr := account.NewTracker()
+	tr.AddKey(pub, path)
+	tpl := txbuilder.NewTemplate(now.Add(time.Minute), nil)
+	tpl.AddIssuance(2, []byte{1}, nil, 1, [][]byte{testutil.TestPub}, nil, testutil.TestPubs, 40, nil, nil)
+	tpl.AddOutput(1, []ed25519.PublicKey{pub}, 40, inv.AssetID, nil, nil)
+	b := &bc.Block{UnsignedBlock: &bc.UnsignedBlock{
+		BlockHeader:  &bc.BlockHeader{Height: 1},
+		Transactions: []*bc.Tx{sign(t, tpl)},
+	}}
+	if err := tr.ApplyBlock(b); err != nil {
+		t.Fatal(err)
+	}
+
+	tpl, spent, err := PayFrom(tr, inv, pub, now, now.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spent) != 1 {
+		t.Errorf("spent %d outputs, want 1", len(spent))
+	}
+	if tpl.MaxTimeMS != inv.ExpMS {
+		t.Errorf("max time %d, want invoice expiration %d", tpl.MaxTimeMS, inv.ExpMS)
+	}
+	res := txresult.New(sign(t, tpl))
+	var paid, change int64
+	for _, out := range res.Outputs {
+		switch {
+		case string(out.RefData) == string(inv.RefData) && reflect.DeepEqual(out.Pubkeys, inv.Pubkeys):
+			paid += int64(out.Value.Amount)
+		case reflect.DeepEqual(out.Pubkeys, []ed25519.PublicKey{pub}):
+			change += int64(out.Value.Amount)
+		}
+	}
+	if paid != 25 || change != 15 {
+		t.Errorf("paid %d with %d change, want 25 with 15", paid, change)
+	}
+
+	inv.Amount = 41
+	inv.Sign(mustKey(t))
+	if _, _, err := PayFrom(tr, inv, pub, now, now.Add(time.Hour)); errors.Root(err) != account.ErrInsufficientFunds {
+		t.Errorf("too much: got %v, want %v", err, account.ErrInsufficientFunds)
+	}
+}
+
+func mustKey(t *testing.T) ed25519.PrivateKey {
+	_, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prv
+}
+
+func sign(t *testing.T, tpl *txbuilder.Template) *bc.Tx {
+	err := tpl.Sign(context.Background(), func(_ context.Context, msg, _ []byte, path [][]byte) ([]byte, error) {
+		return testutil.TestXPrv.Derive(path).Sign(msg), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := tpl.Tx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
@@ -0,0 +1,351 @@
+package txbuilder
+
+import (
+	"i10r.io/crypto/ed25519"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder/standard"
+)
+
+var (
+	// ErrBadEntryIndex is returned by AddSignatures for a signature
+	// naming no issuance or input of the template.
+	ErrBadEntryIndex = errors.New("no issuance or input with that index")
+
+	// ErrBadKeyIndex is returned by AddSignatures for a signature
+	// naming a key its entry doesn't have.
+	ErrBadKeyIndex = errors.New("key index out of range")
+
+	// ErrBadSignature is returned by AddSignatures for a signature
+	// that does not verify.
+	ErrBadSignature = errors.New("invalid signature")
+
+	errBadPubkey = errors.New("bad public key")
+)
+
+// Raw returns the protobuf form of tpl, for exchange with signers
+// that may be written in other languages. It does not include
+// callbacks registered with OnRollback and OnBuild.
+func (tpl *Template) Raw() *RawTemplate {
+	raw := &RawTemplate{
+		TransactionTags: tpl.TxTags,
+		MinTimeMs:       tpl.MinTimeMS,
+		MaxTimeMs:       tpl.MaxTimeMS,
+	}
+	for _, iss := range tpl.Issuances {
+		raw.Issuances = append(raw.Issuances, &RawIssuance{
+			ContractVersion: int64(iss.Version),
+			BlockchainId:    iss.BlockchainID,
+			Quorum:          int64(iss.Quorum),
+			KeyHashes:       asBytes(iss.KeyHashes),
+			DerivationPath:  asBytes(iss.Path),
+			Pubkeys:         pubkeyBytes(iss.Pubkeys),
+			Amount:          iss.Amount,
+			AssetTag:        iss.AssetTag,
+			Signatures:      asBytes(iss.Sigs),
+			ReferenceData:   iss.Refdata,
+			Index:           iss.Index,
+			Nonce:           iss.Nonce,
+		})
+	}
+	for _, inp := range tpl.Inputs {
+		rinp := &RawInput{
+			Quorum:         int64(inp.Quorum),
+			KeyHashes:      asBytes(inp.KeyHashes),
+			DerivationPath: asBytes(inp.Path),
+			Pubkeys:        pubkeyBytes(inp.Pubkeys),
+			Amount:         inp.Amount,
+			AssetId:        rawHash(inp.AssetID),
+			Anchor:         inp.Anchor,
+			Signatures:     asBytes(inp.Sigs),
+			ReferenceData:  inp.InputRefdata,
+			Index:          inp.Index,
+			OutputVersion:  int64(inp.OutputVersion),
+		}
+		if inp.OutputIndex != nil {
+			rinp.SpendsOutput = true
+			rinp.OutputIndex = int64(*inp.OutputIndex)
+			rinp.Anchor = nil // recomputed from the output
+		}
+		raw.Inputs = append(raw.Inputs, rinp)
+	}
+	for _, out := range tpl.Outputs {
+		raw.Outputs = append(raw.Outputs, &RawOutput{
+			Quorum:        int64(out.Quorum),
+			Pubkeys:       pubkeyBytes(out.Pubkeys),
+			Amount:        out.Amount,
+			AssetId:       rawHash(out.AssetID),
+			ReferenceData: out.Refdata,
+			TokenTags:     out.TokenTags,
+			Index:         out.Index,
+		})
+	}
+	for _, ret := range tpl.Retirements {
+		raw.Retirements = append(raw.Retirements, &RawRetirement{
+			Amount:        ret.Amount,
+			AssetId:       rawHash(ret.AssetID),
+			ReferenceData: ret.Refdata,
+			Index:         ret.Index,
+		})
+	}
+	return raw
+}
+
+// TemplateFromRaw returns the Template described by raw. Further
+// entries added to it are ordered after every entry in raw.
+func TemplateFromRaw(raw *RawTemplate) (*Template, error) {
+	tpl := &Template{
+		TxTags:    raw.TransactionTags,
+		MinTimeMS: raw.MinTimeMs,
+		MaxTimeMS: raw.MaxTimeMs,
+	}
+	seen := make(map[uint64]bool)
+	addIndex := func(idx uint64) error {
+		if seen[idx] {
+			return errors.WithDetailf(ErrBadEntryIndex, "duplicate index %d", idx)
+		}
+		seen[idx] = true
+		if idx >= tpl.index {
+			tpl.index = idx + 1
+		}
+		return nil
+	}
+	for _, riss := range raw.Issuances {
+		err := addIndex(riss.Index)
+		if err != nil {
+			return nil, err
+		}
+		pubkeys, err := parsePubkeys(riss.Pubkeys)
+		if err != nil {
+			return nil, errors.Wrapf(err, "issuance %d", riss.Index)
+		}
+		tpl.Issuances = append(tpl.Issuances, &Issuance{
+			Version:      int(riss.ContractVersion),
+			BlockchainID: riss.BlockchainId,
+			Quorum:       int(riss.Quorum),
+			KeyHashes:    asHexBytes(riss.KeyHashes),
+			Path:         asHexBytes(riss.DerivationPath),
+			Pubkeys:      pubkeys,
+			Amount:       riss.Amount,
+			AssetTag:     riss.AssetTag,
+			Sigs:         asHexBytes(riss.Signatures),
+			Refdata:      riss.ReferenceData,
+			Index:        riss.Index,
+			Nonce:        riss.Nonce,
+		})
+	}
+	for _, rinp := range raw.Inputs {
+		err := addIndex(rinp.Index)
+		if err != nil {
+			return nil, err
+		}
+		pubkeys, err := parsePubkeys(rinp.Pubkeys)
+		if err != nil {
+			return nil, errors.Wrapf(err, "input %d", rinp.Index)
+		}
+		inp := &Input{
+			Quorum:        int(rinp.Quorum),
+			KeyHashes:     asHexBytes(rinp.KeyHashes),
+			Path:          asHexBytes(rinp.DerivationPath),
+			Pubkeys:       pubkeys,
+			Amount:        rinp.Amount,
+			AssetID:       hashFromRaw(rinp.AssetId),
+			Anchor:        rinp.Anchor,
+			Sigs:          asHexBytes(rinp.Signatures),
+			InputRefdata:  rinp.ReferenceData,
+			Index:         rinp.Index,
+			OutputVersion: int(rinp.OutputVersion),
+		}
+		if rinp.SpendsOutput {
+			if rinp.OutputIndex < 0 || rinp.OutputIndex >= int64(len(raw.Outputs)) {
+				return nil, errors.WithDetailf(ErrBadEntryIndex, "input %d spends nonexistent output %d", rinp.Index, rinp.OutputIndex)
+			}
+			outIdx := int(rinp.OutputIndex)
+			inp.OutputIndex = &outIdx
+		}
+		tpl.Inputs = append(tpl.Inputs, inp)
+	}
+	for _, rout := range raw.Outputs {
+		err := addIndex(rout.Index)
+		if err != nil {
+			return nil, err
+		}
+		pubkeys, err := parsePubkeys(rout.Pubkeys)
+		if err != nil {
+			return nil, errors.Wrapf(err, "output %d", rout.Index)
+		}
+		tpl.Outputs = append(tpl.Outputs, &Output{
+			Quorum:    int(rout.Quorum),
+			Pubkeys:   pubkeys,
+			Amount:    rout.Amount,
+			AssetID:   hashFromRaw(rout.AssetId),
+			Refdata:   rout.ReferenceData,
+			TokenTags: rout.TokenTags,
+			Index:     rout.Index,
+		})
+	}
+	for _, rret := range raw.Retirements {
+		err := addIndex(rret.Index)
+		if err != nil {
+			return nil, err
+		}
+		tpl.Retirements = append(tpl.Retirements, &Retirement{
+			Amount:  rret.Amount,
+			AssetID: hashFromRaw(rret.AssetId),
+			Refdata: rret.ReferenceData,
+			Index:   rret.Index,
+		})
+	}
+	return tpl, nil
+}
+
+// SigningInstructions returns one instruction for each issuance and
+// input that still needs signatures. The messages are the ones Sign
+// would pass to its callback, so a remote signer that answers the
+// instructions produces the same signatures Sign would.
+func (tpl *Template) SigningInstructions() ([]*SigningInstruction, error) {
+	txID, _, err := tpl.Materialize()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get txid for computing signature message")
+	}
+
+	txidProg := standard.VerifyTxID(txID.Byte32())
+	var insts []*SigningInstruction
+	add := func(index uint64, quorum int, keyHashes, path []chainjson.HexBytes, anchor []byte, sigs []chainjson.HexBytes) {
+		inst := &SigningInstruction{
+			EntryIndex:     index,
+			Message:        append(txidProg[:len(txidProg):len(txidProg)], anchor...),
+			DerivationPath: asBytes(path),
+		}
+		for i, kh := range keyHashes {
+			if i < len(sigs) && len(sigs[i]) > 0 {
+				quorum--
+				kh = nil
+			}
+			inst.KeyHashes = append(inst.KeyHashes, kh)
+		}
+		if quorum <= 0 {
+			return
+		}
+		inst.Quorum = int64(quorum)
+		insts = append(insts, inst)
+	}
+	for _, iss := range tpl.Issuances {
+		add(iss.Index, iss.Quorum, iss.KeyHashes, iss.Path, iss.anchor, iss.Sigs)
+	}
+	for _, inp := range tpl.Inputs {
+		add(inp.Index, inp.Quorum, inp.KeyHashes, inp.Path, inp.Anchor, inp.Sigs)
+	}
+	return insts, nil
+}
+
+// AddSignatures adds signatures produced in answer to the template's
+// SigningInstructions. Each signature is checked against the
+// corresponding public key, so a misbehaving signer cannot spoil the
+// template. A signature for a key that has already signed replaces
+// the earlier one.
+func (tpl *Template) AddSignatures(sigs []*Signature) error {
+	insts, err := tpl.SigningInstructions()
+	if err != nil {
+		return err
+	}
+	msgs := make(map[uint64][]byte)
+	for _, inst := range insts {
+		msgs[inst.EntryIndex] = inst.Message
+	}
+	for _, sig := range sigs {
+		pubkeys, keySigs, ok := tpl.sigEntry(sig.EntryIndex)
+		if !ok {
+			return errors.WithDetailf(ErrBadEntryIndex, "entry %d", sig.EntryIndex)
+		}
+		msg, ok := msgs[sig.EntryIndex]
+		if !ok {
+			continue // already has a quorum
+		}
+		if sig.KeyIndex >= uint64(len(pubkeys)) {
+			return errors.WithDetailf(ErrBadKeyIndex, "entry %d key %d", sig.EntryIndex, sig.KeyIndex)
+		}
+		if !ed25519.Verify(pubkeys[sig.KeyIndex], msg, sig.Signature) {
+			return errors.WithDetailf(ErrBadSignature, "entry %d key %d", sig.EntryIndex, sig.KeyIndex)
+		}
+		if *keySigs == nil {
+			*keySigs = make([]chainjson.HexBytes, len(pubkeys))
+		}
+		(*keySigs)[sig.KeyIndex] = sig.Signature
+	}
+	return nil
+}
+
+// SignedKeys returns the public keys with a valid signature on some
+// issuance or input of the template, each once.
+func (tpl *Template) SignedKeys() ([]ed25519.PublicKey, error) {
+	txID, _, err := tpl.Materialize()
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get txid for computing signature message")
+	}
+
+	txidProg := standard.VerifyTxID(txID.Byte32())
+	var keys []ed25519.PublicKey
+	seen := make(map[string]bool)
+	check := func(pubkeys []ed25519.PublicKey, anchor []byte, sigs []chainjson.HexBytes) {
+		msg := append(txidProg[:len(txidProg):len(txidProg)], anchor...)
+		for i, pub := range pubkeys {
+			if i < len(sigs) && len(sigs[i]) > 0 && !seen[string(pub)] && ed25519.Verify(pub, msg, sigs[i]) {
+				seen[string(pub)] = true
+				keys = append(keys, pub)
+			}
+		}
+	}
+	for _, iss := range tpl.Issuances {
+		check(iss.Pubkeys, iss.anchor, iss.Sigs)
+	}
+	for _, inp := range tpl.Inputs {
+		check(inp.Pubkeys, inp.Anchor, inp.Sigs)
+	}
+	return keys, nil
+}
+
+func (tpl *Template) sigEntry(index uint64) ([]ed25519.PublicKey, *[]chainjson.HexBytes, bool) {
+	for _, iss := range tpl.Issuances {
+		if iss.Index == index {
+			return iss.Pubkeys, &iss.Sigs, true
+		}
+	}
+	for _, inp := range tpl.Inputs {
+		if inp.Index == index {
+			return inp.Pubkeys, &inp.Sigs, true
+		}
+	}
+	return nil, nil, false
+}
+
+func pubkeyBytes(pubkeys []ed25519.PublicKey) [][]byte {
+	var res [][]byte
+	for _, pk := range pubkeys {
+		res = append(res, pk)
+	}
+	return res
+}
+
+func parsePubkeys(b [][]byte) ([]ed25519.PublicKey, error) {
+	var res []ed25519.PublicKey
+	for _, pk := range b {
+		if len(pk) != ed25519.PublicKeySize {
+			return nil, errors.Wrapf(errBadPubkey, "length %d", len(pk))
+		}
+		res = append(res, ed25519.PublicKey(pk))
+	}
+	return res, nil
+}
+
+func rawHash(h bc.Hash) *bc.Hash {
+	return &h
+}
+
+func hashFromRaw(h *bc.Hash) bc.Hash {
+	if h == nil {
+		return bc.Hash{}
+	}
+	return *h
+}
@@ -0,0 +1,253 @@
+// Package signaudit keeps a tamper-evident log of the signatures a
+// signer produces.
+//
+// Each entry of a Log records one signature: when it was made, by
+// which key, and on what message, with the transaction ID and anchor
+// of the template input or issuance the message authorizes. Each
+// entry also commits to the hash of the one before it, so that a log
+// whose entries have been changed, dropped, or reordered fails
+// Verify, and a log's last hash, noted elsewhere, commits to its
+// whole history.
+//
+// A Log appends its entries to a file as JSON, one per line, as they
+// are made, and keeps only the last one's sequence number and hash.
+// Export copies the file, and Verify and Open read it back, an entry
+// at a time.
+package signaudit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"i10r.io/crypto/sha3"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/standard"
+)
+
+// ErrChain is returned for a log whose entries are malformed or do
+// not chain.
+var ErrChain = errors.New("audit log chain broken")
+
+// Entry is an entry of a Log: a record of one signature.
+type Entry struct {
+	Seq    uint64 `json:"seq"`
+	TimeMS uint64 `json:"time_ms"`
+
+	// TxID and Anchor are those of the template input or issuance
+	// signed, if Message is a template signature message (see
+	// txbuilder.Template.Sign), and zero otherwise.
+	TxID    bc.Hash            `json:"txid"`
+	Anchor  chainjson.HexBytes `json:"anchor,omitempty"`
+	Message bc.Hash            `json:"message"` // hash of the message signed
+
+	KeyID     chainjson.HexBytes   `json:"key_id"`
+	Path      []chainjson.HexBytes `json:"path,omitempty"`
+	Signature chainjson.HexBytes   `json:"signature"`
+
+	Prev bc.Hash `json:"prev"`
+	Hash bc.Hash `json:"hash"`
+}
+
+// hash computes the hash of e, which commits to all its other fields.
+func (e *Entry) hash() bc.Hash {
+	var buf bytes.Buffer
+	buf.WriteString("SignAudit")
+	e.Prev.WriteTo(&buf)
+	var n [8]byte
+	for _, v := range []uint64{e.Seq, e.TimeMS} {
+		binary.BigEndian.PutUint64(n[:], v)
+		buf.Write(n[:])
+	}
+	e.TxID.WriteTo(&buf)
+	e.Message.WriteTo(&buf)
+	field := func(b []byte) {
+		binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+		buf.Write(n[:])
+		buf.Write(b)
+	}
+	field(e.Anchor)
+	field(e.KeyID)
+	binary.BigEndian.PutUint64(n[:], uint64(len(e.Path)))
+	buf.Write(n[:])
+	for _, p := range e.Path {
+		field(p)
+	}
+	field(e.Signature)
+	return bc.NewHash(sha3.Sum256(buf.Bytes()))
+}
+
+// Log is a hash-chained log of signatures, kept in a file. It is
+// safe for concurrent use.
+type Log struct {
+	mu   sync.Mutex
+	f    *os.File
+	size int64   // of the entries written to f
+	next uint64  // the sequence number of the next entry
+	head bc.Hash // the hash of the last entry
+	now  func() time.Time
+}
+
+// Open returns a Log appending to the audit log in the file name,
+// creating the file if it does not exist. The entries already in it
+// must verify (see Verify), and the Log continues after the last.
+func Open(name string) (*Log, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	last, err := Verify(f)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "verifying %s", name)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	l := &Log{f: f, size: fi.Size(), now: time.Now}
+	if last != nil {
+		l.next, l.head = last.Seq+1, last.Hash
+	}
+	return l, nil
+}
+
+// Close closes l's file.
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// Wrap returns a SignFunc that signs with signFn and records in l
+// each signature it produces. If the signature cannot be recorded,
+// it returns an error and not the signature, so that no signature
+// escapes the log.
+func (l *Log) Wrap(signFn txbuilder.SignFunc) txbuilder.SignFunc {
+	return func(ctx context.Context, msg, keyID []byte, path [][]byte) ([]byte, error) {
+		sig, err := signFn(ctx, msg, keyID, path)
+		if err != nil || len(sig) == 0 {
+			return sig, err
+		}
+		_, err = l.Record(msg, keyID, path, sig)
+		if err != nil {
+			return nil, err
+		}
+		return sig, nil
+	}
+}
+
+// Record adds to l an entry for sig, a signature on msg by the key
+// with the given ID and derivation path, and returns the entry.
+func (l *Log) Record(msg, keyID []byte, path [][]byte, sig []byte) (*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := &Entry{
+		TimeMS:    bc.Millis(l.now()),
+		Message:   bc.NewHash(sha3.Sum256(msg)),
+		KeyID:     append(chainjson.HexBytes(nil), keyID...),
+		Signature: append(chainjson.HexBytes(nil), sig...),
+	}
+	if txid, anchor, ok := parseMessage(msg); ok {
+		e.TxID = bc.NewHash(txid)
+		e.Anchor = anchor
+	}
+	for _, p := range path {
+		e.Path = append(e.Path, append(chainjson.HexBytes(nil), p...))
+	}
+	e.Seq, e.Prev = l.next, l.head
+	e.Hash = e.hash()
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	n, err := l.f.Write(append(b, '\n'))
+	l.size += int64(n)
+	if err != nil {
+		return nil, errors.Wrap(err, "writing audit entry")
+	}
+	l.next, l.head = e.Seq+1, e.Hash
+	return e, nil
+}
+
+// Head returns the hash of l's last entry, or the zero hash if l is
+// empty.
+func (l *Log) Head() bc.Hash {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.head
+}
+
+// Export copies l's entries from its file to w, one JSON object per
+// line. Entries recorded during the copy are not included.
+func (l *Log) Export(w io.Writer) error {
+	l.mu.Lock()
+	size := l.size
+	l.mu.Unlock()
+	_, err := io.Copy(w, io.NewSectionReader(l.f, 0, size))
+	return err
+}
+
+// Verify reads a log written by Export, or by a Log as it records,
+// and checks that its entries chain. It reads one entry at a time,
+// and returns the last, or nil if there are none.
+func Verify(r io.Reader) (*Entry, error) {
+	var (
+		last *Entry
+		next uint64
+		prev bc.Hash
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		e := new(Entry)
+		err := json.Unmarshal(sc.Bytes(), e)
+		if err != nil {
+			return nil, errors.Sub(ErrChain, errors.Wrapf(err, "entry %d", next))
+		}
+		if e.Seq != next {
+			return nil, errors.WithDetailf(ErrChain, "entry %d has sequence number %d", next, e.Seq)
+		}
+		if e.Prev != prev {
+			return nil, errors.WithDetailf(ErrChain, "entry %d does not follow entry %d", e.Seq, e.Seq-1)
+		}
+		if e.hash() != e.Hash {
+			return nil, errors.WithDetailf(ErrChain, "entry %d does not match its hash", e.Seq)
+		}
+		last, next, prev = e, e.Seq+1, e.Hash
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading audit log")
+	}
+	return last, nil
+}
+
+// parseMessage returns the transaction ID and anchor of msg if it is
+// a template signature message: standard.VerifyTxID(txid) followed
+// by an anchor.
+func parseMessage(msg []byte) (txid [32]byte, anchor []byte, ok bool) {
+	n := len(standard.VerifyTxID(txid))
+	if len(msg) < n {
+		return txid, nil, false
+	}
+	// The txid is the 32 bytes pushed before the trailing eq and
+	// verify.
+	copy(txid[:], msg[n-34:n-2])
+	if !bytes.Equal(standard.VerifyTxID(txid), msg[:n]) {
+		return [32]byte{}, nil, false
+	}
+	return txid, append([]byte(nil), msg[n:]...), true
+}
@@ -0,0 +1,132 @@
+package signaudit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/testutil"
+)
+
+func TestLog(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "signaudit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "audit.log")
+	l, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.now = func() time.Time { return time.Unix(1500000000, 0) }
+
+	prv := testutil.TestXPrv
+	signFn := l.Wrap(func(_ context.Context, msg, _ []byte, path [][]byte) ([]byte, error) {
+		return prv.Derive(path).Sign(msg), nil
+	})
+
+	assetID := bc.HashFromBytes([]byte("assetID"))
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Minute), nil)
+	path := [][]byte{{1}, {2}}
+	pub := prv.Derive(path).XPub().PublicKey()
+	tpl.AddInput(1, [][]byte{[]byte("key")}, path, []ed25519.PublicKey{pub}, 10, assetID, []byte("anchor1"), nil, 2)
+	tpl.AddInput(1, [][]byte{[]byte("key")}, path, []ed25519.PublicKey{pub}, 10, assetID, []byte("anchor2"), nil, 2)
+	tpl.AddOutput(1, []ed25519.PublicKey{pub}, 20, assetID, nil, nil)
+	err = tpl.Sign(ctx, signFn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txid, _, err := tpl.Materialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var export bytes.Buffer
+	err = l.Export(&export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []*Entry
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(export.String(), "\n"), "\n") {
+		e := new(Entry)
+		if err := json.Unmarshal([]byte(line), e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	for i, e := range entries {
+		if e.Seq != uint64(i) || e.TxID != txid || string(e.KeyID) != "key" || e.TimeMS != 1500000000000 {
+			t.Errorf("entry %d = %+v", i, e)
+		}
+		if !bytes.Equal(e.Signature, tpl.Inputs[i].Sigs[0]) {
+			t.Errorf("entry %d signature %x, want %x", i, e.Signature, tpl.Inputs[i].Sigs[0])
+		}
+	}
+	if string(entries[1].Anchor) != "anchor2" || entries[1].Prev != entries[0].Hash {
+		t.Errorf("entry 1 = %+v, want anchor2 following entry 0", entries[1])
+	}
+	if l.Head() != entries[1].Hash {
+		t.Errorf("head %x, want %x", l.Head().Bytes(), entries[1].Hash.Bytes())
+	}
+
+	if last, err := Verify(strings.NewReader(export.String())); err != nil || last.Hash != entries[1].Hash {
+		t.Errorf("verifying export: got last entry %+v, error %v", last, err)
+	}
+	err = l.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l2, err := Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l2.Close()
+	e, err := l2.Record([]byte("not a template message"), []byte("key2"), nil, []byte("sig"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Seq != 2 || e.Prev != entries[1].Hash || !e.TxID.IsZero() {
+		t.Errorf("resumed entry = %+v", e)
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	last, err := Verify(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Seq != 2 || last.Hash != e.Hash || l2.Head() != e.Hash {
+		t.Errorf("verified up to entry %d, %x, want 2, %x", last.Seq, last.Hash.Bytes(), e.Hash.Bytes())
+	}
+
+	lines := strings.SplitAfter(export.String(), "\n")
+	cases := map[string]string{
+		"dropped":   lines[1],
+		"reordered": lines[1] + lines[0],
+		"altered":   strings.Replace(lines[0], `"key_id":"6b6579"`, `"key_id":"6b6578"`, 1) + lines[1],
+		"garbled":   lines[0] + "{" + lines[1],
+	}
+	for name, s := range cases {
+		if _, err := Verify(strings.NewReader(s)); errors.Root(err) != ErrChain {
+			t.Errorf("%s log: got error %v, want %v", name, err, ErrChain)
+		}
+	}
+}
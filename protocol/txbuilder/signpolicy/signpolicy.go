@@ -0,0 +1,280 @@
+// Package signpolicy checks transaction templates against a key's
+// usage policy before the key signs them.
+//
+// A Policy limits where a key's value may go: to a list of allowed
+// destinations, up to an amount per asset per day, and, above a
+// threshold, only with the signatures of co-signers. An Engine holds
+// a policy and the history of the signer's spending, and signs only
+// the templates that pass.
+//
+// An asset's outflow in a template is the total of its outputs to
+// predicates other than the signer's own, plus its retirements.
+// Outputs to the signer's own predicates, such as change, are always
+// allowed and count toward no limit.
+package signpolicy
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/math/checked"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+)
+
+var (
+	// ErrDestination is returned for a template paying a predicate
+	// the policy does not allow.
+	ErrDestination = errors.New("destination not allowed")
+
+	// ErrLimit is returned for a template that would take an
+	// asset's outflow over its limit.
+	ErrLimit = errors.New("amount limit exceeded")
+
+	// ErrCosign is returned for a template that lacks the
+	// signatures of the co-signers its outflow requires.
+	ErrCosign = errors.New("co-signatures required")
+)
+
+// DefaultWindow is the window of a Limit with none given.
+const DefaultWindow = 24 * time.Hour
+
+// Policy is a key's usage policy.
+type Policy struct {
+	// Own are the predicates of the signer's own outputs.
+	Own []Destination
+
+	// Destinations, if not empty, are the only predicates, besides
+	// Own, that outputs may pay.
+	Destinations []Destination
+
+	// Limits bound the outflow of assets over time.
+	Limits []Limit
+
+	// Cosign rules require co-signatures above thresholds.
+	Cosign []Cosign
+}
+
+// Destination is the predicate of a standard pay-to-multisig
+// output.
+type Destination struct {
+	Quorum  int
+	Pubkeys []ed25519.PublicKey
+}
+
+// Limit bounds the outflow of AssetID, over any period of Window, to
+// Amount.
+type Limit struct {
+	AssetID bc.Hash
+	Amount  int64
+	Window  time.Duration // DefaultWindow if zero
+}
+
+// Cosign requires, of a template whose outflow of AssetID is more
+// than Above, valid signatures on its issuances and inputs by at
+// least Quorum of Keys.
+type Cosign struct {
+	AssetID bc.Hash
+	Above   int64
+	Quorum  int
+	Keys    []ed25519.PublicKey
+}
+
+// Engine checks templates against a policy, keeping the history of
+// outflows its limits need. It is safe for concurrent use.
+type Engine struct {
+	policy *Policy
+
+	mu      sync.Mutex
+	history []spend
+}
+
+type spend struct {
+	atMS    uint64
+	assetID bc.Hash
+	amount  int64
+}
+
+// NewEngine returns an Engine for p with no history.
+func NewEngine(p *Policy) *Engine {
+	return &Engine{policy: p}
+}
+
+// Record adds an outflow of amount of assetID at time t to e's
+// history, for instance when restoring it on startup.
+func (e *Engine) Record(assetID bc.Hash, amount int64, t time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.history = append(e.history, spend{bc.Millis(t), assetID, amount})
+}
+
+// Spent returns the outflow of assetID recorded in the window ending
+// at now.
+func (e *Engine) Spent(assetID bc.Hash, window time.Duration, now time.Time) int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.spent(assetID, window, now)
+}
+
+// spent is Spent. The caller must hold e.mu.
+func (e *Engine) spent(assetID bc.Hash, window time.Duration, now time.Time) int64 {
+	if window == 0 {
+		window = DefaultWindow
+	}
+	since := bc.Millis(now.Add(-window))
+	var total int64
+	for _, s := range e.history {
+		if s.assetID == assetID && s.atMS > since {
+			total, _ = checked.AddInt64(total, s.amount)
+		}
+	}
+	return total
+}
+
+// Check checks tpl against e's policy at time now.
+func (e *Engine) Check(tpl *txbuilder.Template, now time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := e.check(tpl, now)
+	return err
+}
+
+// check checks tpl and returns its outflows. The caller must hold
+// e.mu.
+func (e *Engine) check(tpl *txbuilder.Template, now time.Time) (map[bc.Hash]int64, error) {
+	p := e.policy
+	outflow := make(map[bc.Hash]int64)
+	add := func(assetID bc.Hash, amount int64) error {
+		total, ok := checked.AddInt64(outflow[assetID], amount)
+		if !ok {
+			return errors.WithDetailf(ErrLimit, "outflow of %x overflows", assetID.Bytes())
+		}
+		outflow[assetID] = total
+		return nil
+	}
+	for _, out := range tpl.Outputs {
+		dest := Destination{out.Quorum, out.Pubkeys}
+		if contains(p.Own, dest) {
+			continue
+		}
+		if len(p.Destinations) > 0 && !contains(p.Destinations, dest) {
+			return nil, errors.WithDetailf(ErrDestination, "output %d", out.Index)
+		}
+		if err := add(out.AssetID, out.Amount); err != nil {
+			return nil, err
+		}
+	}
+	for _, ret := range tpl.Retirements {
+		if err := add(ret.AssetID, ret.Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, l := range p.Limits {
+		amount := outflow[l.AssetID]
+		if amount == 0 {
+			continue
+		}
+		total, ok := checked.AddInt64(e.spent(l.AssetID, l.Window, now), amount)
+		if !ok || total > l.Amount {
+			return nil, errors.WithDetailf(ErrLimit, "%d of %x, with %d already spent, exceeds %d", amount, l.AssetID.Bytes(), total-amount, l.Amount)
+		}
+	}
+
+	for _, c := range p.Cosign {
+		if outflow[c.AssetID] <= c.Above {
+			continue
+		}
+		n, err := cosigners(tpl, c.Keys)
+		if err != nil {
+			return nil, err
+		}
+		if n < c.Quorum {
+			return nil, errors.WithDetailf(ErrCosign, "%d of %d co-signers for %d of %x", n, c.Quorum, outflow[c.AssetID], c.AssetID.Bytes())
+		}
+	}
+	return outflow, nil
+}
+
+// Sign checks tpl against e's policy at time now and, if it passes,
+// signs it with signFn (see txbuilder.Template.Sign) and records its
+// outflows.
+func (e *Engine) Sign(ctx context.Context, tpl *txbuilder.Template, signFn txbuilder.SignFunc, now time.Time) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	outflow, err := e.check(tpl, now)
+	if err != nil {
+		return err
+	}
+	err = tpl.Sign(ctx, signFn)
+	if err != nil {
+		return err
+	}
+	atMS := bc.Millis(now)
+	for assetID, amount := range outflow {
+		e.history = append(e.history, spend{atMS, assetID, amount})
+	}
+	e.prune(now)
+	return nil
+}
+
+// prune drops the history that no limit's window reaches. The caller
+// must hold e.mu.
+func (e *Engine) prune(now time.Time) {
+	window := DefaultWindow
+	for _, l := range e.policy.Limits {
+		if l.Window > window {
+			window = l.Window
+		}
+	}
+	since := bc.Millis(now.Add(-window))
+	h := e.history[:0]
+	for _, s := range e.history {
+		if s.atMS > since {
+			h = append(h, s)
+		}
+	}
+	e.history = h
+}
+
+// cosigners returns the number of keys with a valid signature on
+// some issuance or input of tpl.
+func cosigners(tpl *txbuilder.Template, keys []ed25519.PublicKey) (int, error) {
+	signed, err := tpl.SignedKeys()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, k := range keys {
+		for _, s := range signed {
+			if bytes.Equal(k, s) {
+				n++
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+func contains(ds []Destination, d Destination) bool {
+	for _, x := range ds {
+		if x.Quorum != d.Quorum || len(x.Pubkeys) != len(d.Pubkeys) {
+			continue
+		}
+		match := true
+		for i := range x.Pubkeys {
+			if !bytes.Equal(x.Pubkeys[i], d.Pubkeys[i]) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package signpolicy
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+)
+
+// signer returns a SignFunc that signs with prv for its public key,
+// used as the key ID, and with no other key.
+func signer(prv ed25519.PrivateKey) txbuilder.SignFunc {
+	pub := prv.Public().(ed25519.PublicKey)
+	return func(_ context.Context, msg, keyID []byte, _ [][]byte) ([]byte, error) {
+		if !bytes.Equal(keyID, pub) {
+			return nil, nil
+		}
+		return ed25519.Sign(prv, msg), nil
+	}
+}
+
+func TestEngine(t *testing.T) {
+	ctx := context.Background()
+	var (
+		pubs []ed25519.PublicKey
+		prvs []ed25519.PrivateKey
+	)
+	for i := 0; i < 4; i++ {
+		pub, prv, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pubs = append(pubs, pub)
+		prvs = append(prvs, prv)
+	}
+	own, payee, stranger, cosigner := pubs[0], pubs[1], pubs[2], pubs[3]
+	assetID := bc.HashFromBytes([]byte("assetID"))
+	now := time.Now()
+
+	e := NewEngine(&Policy{
+		Own:          []Destination{{1, []ed25519.PublicKey{own}}},
+		Destinations: []Destination{{1, []ed25519.PublicKey{payee}}},
+		Limits:       []Limit{{AssetID: assetID, Amount: 100}},
+		Cosign:       []Cosign{{AssetID: assetID, Above: 50, Quorum: 1, Keys: []ed25519.PublicKey{cosigner}}},
+	})
+
+	// newTemplate returns a template spending 1000 units from own,
+	// or, with cosign, from own and cosigner, paying amount to
+	// dest and the rest to own.
+	newTemplate := func(dest ed25519.PublicKey, amount int64, cosign bool) *txbuilder.Template {
+		tpl := txbuilder.NewTemplate(now.Add(time.Minute), nil)
+		keys := []ed25519.PublicKey{own}
+		if cosign {
+			keys = append(keys, cosigner)
+		}
+		keyIDs := make([][]byte, len(keys))
+		for i, k := range keys {
+			keyIDs[i] = k
+		}
+		tpl.AddInput(len(keys), keyIDs, nil, keys, 1000, assetID, []byte("anchor"), nil, 2)
+		tpl.AddOutput(1, []ed25519.PublicKey{dest}, amount, assetID, nil, nil)
+		tpl.AddOutput(1, []ed25519.PublicKey{own}, 1000-amount, assetID, nil, nil)
+		return tpl
+	}
+
+	tpl := newTemplate(stranger, 10, false)
+	if err := e.Sign(ctx, tpl, signer(prvs[0]), now); errors.Root(err) != ErrDestination {
+		t.Errorf("paying a stranger: got error %v, want %v", err, ErrDestination)
+	}
+	if len(tpl.Inputs[0].Sigs) > 0 {
+		t.Error("rejected template signed")
+	}
+
+	for i := 0; i < 2; i++ {
+		tpl = newTemplate(payee, 40, false)
+		if err := e.Sign(ctx, tpl, signer(prvs[0]), now); err != nil {
+			t.Fatalf("payment %d: %s", i, err)
+		}
+		if _, err := tpl.Tx(); err != nil {
+			t.Fatalf("payment %d: %s", i, err)
+		}
+	}
+	if got := e.Spent(assetID, 0, now); got != 80 {
+		t.Errorf("spent %d, want 80", got)
+	}
+	tpl = newTemplate(payee, 40, false)
+	if err := e.Check(tpl, now); errors.Root(err) != ErrLimit {
+		t.Errorf("over the limit: got error %v, want %v", err, ErrLimit)
+	}
+
+	// A day later, the earlier payments are out of the window.
+	now = now.Add(DefaultWindow + time.Second)
+	tpl = newTemplate(payee, 60, false)
+	if err := e.Check(tpl, now); errors.Root(err) != ErrCosign {
+		t.Errorf("above the threshold: got error %v, want %v", err, ErrCosign)
+	}
+	tpl = newTemplate(payee, 60, true)
+	if err := tpl.Sign(ctx, signer(prvs[3])); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Sign(ctx, tpl, signer(prvs[0]), now); err != nil {
+		t.Fatalf("co-signed payment: %s", err)
+	}
+	if _, err := tpl.Tx(); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Spent(assetID, 0, now); got != 60 {
+		t.Errorf("spent %d, want 60", got)
+	}
+}
@@ -0,0 +1,203 @@
+package standard
+
+import (
+	"fmt"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/sha3"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+// bridgeReserveSrcFmt expects the argument stack
+// [... refdata value {p1,...,p_n} quorum]. It locks value in a
+// bridge reserve held by the federation of keys p_i, which releases
+// it with bridgeReleaseSrc.
+const bridgeReserveSrcFmt = `
+	               # Contract stack                         Argument stack              Log
+	               # []                                     [refdata v {p1,...,p_n} q]  []
+	get get        # [q {p1,...,p_n}]                       [refdata v]                 []
+	get            # [q {p1,...,p_n} v]                     [refdata]                   []
+	get log        # [q {p1,...,p_n} v]                     []                          [{"L", <cid>, refdata}]
+	[%s]           # [q {p1,...,p_n} v <release>]           []                          [{"L", <cid>, refdata}]
+	output         # [q {p1,...,p_n} v]                     []                          [{"L", <cid>, refdata} {"O", <caller>, <outputid>}]
+`
+
+// bridgeReleaseSrcFmt expects the argument stack
+// [... s1 ... s_n rq {r1,...,r_m} amount paymentid] and the contract
+// stack [... quorum {p1,...,p_n} value]. It checks that each `s_i`
+// is a valid signature by `p_i` of the release message (see
+// BridgeReleaseMessage), with exactly `quorum` of them non-empty. It then logs `paymentid`, pays `amount` of
+// `value` to the standard pay-to-multisig contract for `rq` of the
+// keys `r_i`, and locks the remainder, if any, in a new reserve
+// with the same federation.
+const bridgeReleaseSrcFmt = `
+	                      # Contract stack                                    Argument stack
+	                      # [q {p} v]                                         [s1 ... s_n rq {r} amt pid]
+	2 peek 2 peek 2 roll  # [q {p} q {p} v]                                   [s1 ... s_n rq {r} amt pid]
+	get get get get       # [q {p} q {p} v pid amt {r} rq]                    [s1 ... s_n]
+	4 roll anchor         # [q {p} q {p} pid amt {r} rq v anchor]             [s1 ... s_n]
+	5 peek 5 peek         # [q {p} q {p} pid amt {r} rq v anchor pid amt]     [s1 ... s_n]
+	5 peek 5 peek         # [... v anchor pid amt {r} rq]                     [s1 ... s_n]
+	5 tuple encode        # [q {p} q {p} pid amt {r} rq v enc]                [s1 ... s_n]
+	'BridgeRelease'       # [... rq v enc 'BridgeRelease']                    [s1 ... s_n]
+	swap cat sha3         # [q {p} q {p} pid amt {r} rq v msg]                [s1 ... s_n]
+	6 roll untuple        # [q {p} q pid amt {r} rq v msg p1 ... p_n n]       [s1 ... s_n]
+	0 swap                # [q {p} q pid amt {r} rq v msg p1 ... p_n 0 n]     [s1 ... s_n]
+	$sigstart             # [... msg p1 ... p_n t n],  t = 0..n               [s1 ... s_n]
+	    dup 0 eq          # [... msg p1 ... p_n t n (n==0)]                   [s1 ... s_n]
+	    jumpif:$sigend    # [... msg p1 ... p_n t n]                          [s1 ... s_n]
+	    dup 2 add peek    # [... msg p1 ... p_n t n msg]                      [s1 ... s_n]
+	    3 roll            # [... msg p1 ... p_n-1 t n msg p_n]                [s1 ... s_n]
+	    get               # [... msg p1 ... p_n-1 t n msg p_n s_n]            [s1 ... s_n-1]
+	    0 checksig        # [... msg p1 ... p_n-1 t n bool]                   [s1 ... s_n-1]
+	    2 roll add        # [... msg p1 ... p_n-1 n t’]                       [s1 ... s_n-1]
+	    swap 1 sub        # [... msg p1 ... p_n-1 t’ (n-1)]                   [s1 ... s_n-1]
+	    jump:$sigstart    # [... msg p1 ... p_n-1 t’ (n-1)]                   [s1 ... s_n-1]
+	$sigend               # [q {p} q pid amt {r} rq v msg t 0]                []
+	drop swap drop        # [q {p} q pid amt {r} rq v t]                      []
+	6 roll eq verify      # [q {p} pid amt {r} rq v]                          []
+	4 roll log            # [q {p} amt {r} rq v]                              []             [{"L", <cid>, pid}]
+	3 roll split          # [q {p} {r} rq rest payment]                       []
+	'' put '' put put     # [q {p} {r} rq rest]                               ['' '' payment]
+	2 roll put swap put   # [q {p} rest]                                      ['' '' payment {r} rq]
+	[%s]                  # [q {p} rest <multisigprog>]                       ['' '' payment {r} rq]
+	contract call         # [q {p} rest]                                      []             [... {"O", <cid>, <outputid>}]
+	amount 0 eq           # [q {p} rest (rest.amount==0)]                     []
+	jumpif:$spent         # [q {p} rest]                                      []
+	contractprogram       # [q {p} rest <release>]                            []
+	output                # [q {p} rest]                                      []             [... {"O", <caller>, <outputid>}]
+	$spent                # [q {p} zeroval]                                   []
+	drop drop drop        # []                                                []
+`
+
+var (
+	bridgeReleaseSrc = fmt.Sprintf(bridgeReleaseSrcFmt, payToMultisigProgSrc2)
+
+	// bridgeRelease is the bytecode of the "release" phase of the
+	// standard bridge-reserve contract.
+	bridgeRelease = mustAssemble(bridgeReleaseSrc)
+
+	bridgeReserveSrc = fmt.Sprintf(bridgeReserveSrcFmt, bridgeReleaseSrc)
+
+	// BridgeReserveProg is the txvm bytecode of the standard
+	// bridge-reserve contract, which holds value on behalf of a
+	// federation operating a two-way peg with another blockchain.
+	// The federation releases it, in whole or in part, when a quorum
+	// of its members has verified a payment on the other blockchain
+	// (see package bridge) and signed the corresponding
+	// BridgeReleaseMessage.
+	BridgeReserveProg = mustAssemble(bridgeReserveSrc)
+
+	// BridgeReserveSeed is the seed of the standard bridge-reserve
+	// contract.
+	BridgeReserveSeed = txvm.ContractSeed(BridgeReserveProg)
+)
+
+// BridgeReleaseMessage returns the message that federation members
+// sign to release amount units of the reserve value with the given
+// anchor to the standard pay-to-multisig contract for quorum of
+// pubkeys, in exchange for the external payment with the given ID.
+//
+// Because the message names the reserve's anchor, each signature
+// releases from one reserve output only. When a release leaves a
+// remainder, the new reserve's anchor is VMHash("Split1", anchor).
+func BridgeReleaseMessage(anchor, paymentID []byte, amount int64, quorum int, pubkeys []ed25519.PublicKey) []byte {
+	var pks txvm.Tuple
+	for _, pk := range pubkeys {
+		pks = append(pks, txvm.Bytes(pk))
+	}
+	enc := txvm.Encode(txvm.Tuple{
+		txvm.Bytes(anchor),
+		txvm.Bytes(paymentID),
+		txvm.Int(amount),
+		pks,
+		txvm.Int(quorum),
+	})
+	h := sha3.Sum256(append([]byte("BridgeRelease"), enc...))
+	return h[:]
+}
+
+// Release holds the arguments to ReleaseBridgeReserve describing
+// what to release and to whom.
+type Release struct {
+	PaymentID []byte
+	Amount    int64
+	Quorum    int
+	Pubkeys   []ed25519.PublicKey
+
+	// Sigs holds the federation's signatures of the release message,
+	// parallel to the reserve's pubkeys. A key that did not sign has
+	// an empty signature.
+	Sigs [][]byte
+}
+
+// ReleaseBridgeReserve writes txvm bytecode to b, spending a value
+// previously locked with the standard bridge-reserve contract and
+// releasing part or all of it as r describes.
+func ReleaseBridgeReserve(
+	b *txvmutil.Builder,
+	quorum int,
+	pubkeys []ed25519.PublicKey,
+	amount int64,
+	assetID bc.Hash,
+	anchor []byte,
+	r *Release,
+) {
+	for _, sig := range r.Sigs {
+		b.PushdataBytes(sig).Op(op.Put) // x'<sig>' put
+	}
+	b.PushdataInt64(int64(r.Quorum)).Op(op.Put) // <quorum> put
+	b.Tuple(func(tup *txvmutil.TupleBuilder) {  // {r1,...,r_m} put
+		for _, pk := range r.Pubkeys {
+			tup.PushdataBytes(pk)
+		}
+	})
+	b.Op(op.Put)
+	b.PushdataInt64(r.Amount).Op(op.Put)    // <amount> put
+	b.PushdataBytes(r.PaymentID).Op(op.Put) // x'<paymentid>' put
+	b.Tuple(func(contract *txvmutil.TupleBuilder) {
+		contract.PushdataByte(txvm.ContractCode)          // 'C'
+		contract.PushdataBytes(BridgeReserveSeed[:])      // <seed>
+		contract.PushdataBytes(bridgeRelease)             // [<release prog>]
+		contract.Tuple(func(tup *txvmutil.TupleBuilder) { // {'Z', quorum}
+			tup.PushdataByte(txvm.IntCode)
+			tup.PushdataInt64(int64(quorum))
+		})
+		contract.Tuple(func(tup *txvmutil.TupleBuilder) { // {'T', {p1,...,p_n}}
+			tup.PushdataByte(txvm.TupleCode)
+			tup.Tuple(func(pktup *txvmutil.TupleBuilder) {
+				for _, pubkey := range pubkeys {
+					pktup.PushdataBytes(pubkey)
+				}
+			})
+		})
+		contract.Tuple(func(tup *txvmutil.TupleBuilder) { // {'V', amount, assetID, anchor}
+			tup.PushdataByte(txvm.ValueCode)
+			tup.PushdataInt64(amount)
+			tup.PushdataBytes(assetID.Bytes())
+			tup.PushdataBytes(anchor)
+		})
+	})
+	b.Op(op.Input).Op(op.Call)
+}
+
+// BridgeReserveOutputID returns the ID of the reserve output that
+// ReleaseBridgeReserve spends with the same arguments.
+func BridgeReserveOutputID(quorum int, pubkeys []ed25519.PublicKey, amount int64, assetID bc.Hash, anchor []byte) bc.Hash {
+	var pks txvm.Tuple
+	for _, pk := range pubkeys {
+		pks = append(pks, txvm.Bytes(pk))
+	}
+	snapshot := txvm.Tuple{
+		txvm.Bytes{txvm.ContractCode},
+		txvm.Bytes(BridgeReserveSeed[:]),
+		txvm.Bytes(bridgeRelease),
+		txvm.Tuple{txvm.Bytes{txvm.IntCode}, txvm.Int(quorum)},
+		txvm.Tuple{txvm.Bytes{txvm.TupleCode}, pks},
+		txvm.Tuple{txvm.Bytes{txvm.ValueCode}, txvm.Int(amount), txvm.Bytes(assetID.Bytes()), txvm.Bytes(anchor)},
+	}
+	return bc.NewHash(txvm.VMHash("SnapshotID", txvm.Encode(snapshot)))
+}
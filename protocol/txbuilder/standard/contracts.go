@@ -0,0 +1,176 @@
+package standard
+
+import (
+	"strconv"
+
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/contracts"
+	"i10r.io/protocol/txvm"
+)
+
+// Stack item types of the standard contracts (see contracts.Arg).
+const (
+	pubkeysType     = "{pubkey, ...}"
+	partyType       = "{quorum, {pubkey, ...}}"
+	escrowTermsType = "{buyer, seller, arbiter, deadline}"
+	oracleTermsType = "{topic, strike, above, below}"
+	streamTermsType = "{start, end, total, payee}"
+)
+
+func init() {
+	contracts.Register(&contracts.Contract{
+		Name:  "standard pay-to-multisig v1",
+		Seed:  bc.NewHash(PayToMultisigSeed1),
+		Args:  args("refdata", "bytes", "value", "value", "pubkeys", pubkeysType, "quorum", "int"),
+		Stack: args("quorum", "int", "pubkeys", pubkeysType, "value", "value"),
+		Label: quorumLabel,
+	})
+	contracts.Register(&contracts.Contract{
+		Name:  "standard pay-to-multisig v2",
+		Seed:  bc.NewHash(PayToMultisigSeed2),
+		Args:  args("refdata", "bytes", "tags", "bytes", "value", "value", "pubkeys", pubkeysType, "quorum", "int"),
+		Stack: args("quorum", "int", "pubkeys", pubkeysType, "value", "value"),
+		Label: quorumLabel,
+	})
+	contracts.Register(&contracts.Contract{
+		Name: "standard asset issuance v1",
+		Seed: bc.NewHash(AssetContractSeed[1]),
+		Args: args("refdata", "bytes", "pubkeys", pubkeysType, "quorum", "int", "tag", "bytes", "amount", "int",
+			"zerovalue or blockid", "value or bytes", "maxtime", "int"),
+	})
+	contracts.Register(&contracts.Contract{
+		Name: "standard asset issuance v2",
+		Seed: bc.NewHash(AssetContractSeed[2]),
+		Args: args("refdata", "bytes", "pubkeys", pubkeysType, "quorum", "int", "tag", "bytes", "amount", "int",
+			"zerovalue or blockid, nonce", "value or bytes, bytes", "maxtime", "int"),
+	})
+	contracts.Register(&contracts.Contract{
+		Name: "standard retirement",
+		Seed: bc.NewHash(RetireContractSeed),
+		Args: args("refdata", "bytes", "value", "value"),
+	})
+	contracts.Register(&contracts.Contract{
+		Name:  "standard escrow",
+		Seed:  bc.NewHash(EscrowSeed),
+		Args:  args("refdata", "bytes", "value", "value", "terms", escrowTermsType),
+		Stack: args("terms", escrowTermsType, "disputed", "int", "value", "value"),
+		Label: func(stack []txvm.Data) string {
+			if len(stack) != 3 {
+				return ""
+			}
+			if disputed, ok := intItem(stack[1]); ok && disputed != 0 {
+				return "disputed"
+			}
+			return ""
+		},
+	})
+	contracts.Register(&contracts.Contract{
+		Name:  "standard oracle settlement",
+		Seed:  bc.NewHash(OracleSettlementSeed),
+		Args:  args("refdata", "bytes", "value", "value", "terms", oracleTermsType, "oracle", partyType),
+		Stack: args("oracle", partyType, "terms", oracleTermsType, "value", "value"),
+		Label: func(stack []txvm.Data) string {
+			if len(stack) != 3 {
+				return ""
+			}
+			terms, ok := tupleItem(stack[1])
+			if !ok || len(terms) != 4 {
+				return ""
+			}
+			topic, ok := terms[0].(txvm.Bytes)
+			if !ok {
+				return ""
+			}
+			return strconv.Quote(string(topic))
+		},
+	})
+	contracts.Register(&contracts.Contract{
+		Name:  "standard streaming payment",
+		Seed:  bc.NewHash(StreamSeed),
+		Args:  args("refdata", "bytes", "value", "value", "terms", streamTermsType, "payer", partyType),
+		Stack: args("payer", partyType, "terms", streamTermsType, "value", "value", "withdrawn", "int"),
+	})
+	contracts.Register(&contracts.Contract{
+		Name:  "standard vesting",
+		Seed:  bc.NewHash(VestingSeed),
+		Args:  args("refdata", "bytes", "value", "value", "beneficiary", partyType, "unlock", "int"),
+		Stack: args("unlock", "int", "beneficiary", partyType, "value", "value"),
+		Label: func(stack []txvm.Data) string {
+			if len(stack) != 3 {
+				return ""
+			}
+			party, ok := tupleItem(stack[1])
+			if !ok || len(party) != 2 {
+				return ""
+			}
+			quorum, ok := party[0].(txvm.Int)
+			if !ok {
+				return ""
+			}
+			pubkeys, ok := party[1].(txvm.Tuple)
+			if !ok {
+				return ""
+			}
+			return formatQuorum(quorum, pubkeys)
+		},
+	})
+	contracts.Register(&contracts.Contract{
+		Name:  "standard bridge reserve",
+		Seed:  bc.NewHash(BridgeReserveSeed),
+		Args:  args("refdata", "bytes", "value", "value", "pubkeys", pubkeysType, "quorum", "int"),
+		Stack: args("quorum", "int", "pubkeys", pubkeysType, "value", "value"),
+		Label: quorumLabel,
+	})
+}
+
+// args returns the stack items described by pairs of names and
+// types.
+func args(namesAndTypes ...string) []contracts.Arg {
+	var a []contracts.Arg
+	for i := 0; i+1 < len(namesAndTypes); i += 2 {
+		a = append(a, contracts.Arg{Name: namesAndTypes[i], Type: namesAndTypes[i+1]})
+	}
+	return a
+}
+
+// quorumLabel labels a contract whose stack begins with a quorum and
+// a tuple of pubkeys as "Q-of-N".
+func quorumLabel(stack []txvm.Data) string {
+	if len(stack) < 2 {
+		return ""
+	}
+	quorum, ok := intItem(stack[0])
+	if !ok {
+		return ""
+	}
+	pubkeys, ok := tupleItem(stack[1])
+	if !ok {
+		return ""
+	}
+	return formatQuorum(quorum, pubkeys)
+}
+
+func formatQuorum(quorum txvm.Int, pubkeys txvm.Tuple) string {
+	return strconv.Itoa(int(quorum)) + "-of-" + strconv.Itoa(len(pubkeys))
+}
+
+// intItem returns the int in item, a stack item in inspected form.
+func intItem(item txvm.Data) (txvm.Int, bool) {
+	t, ok := item.(txvm.Tuple)
+	if !ok || len(t) != 2 {
+		return 0, false
+	}
+	n, ok := t[1].(txvm.Int)
+	return n, ok
+}
+
+// tupleItem returns the tuple in item, a stack item in inspected
+// form.
+func tupleItem(item txvm.Data) (txvm.Tuple, bool) {
+	t, ok := item.(txvm.Tuple)
+	if !ok || len(t) != 2 {
+		return nil, false
+	}
+	tup, ok := t[1].(txvm.Tuple)
+	return tup, ok
+}
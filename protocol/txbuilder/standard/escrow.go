@@ -0,0 +1,250 @@
+package standard
+
+import (
+	"fmt"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/sha3"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+// escrowSrcFmt expects the argument stack [... refdata value terms],
+// where terms is {buyer, seller, arbiter, deadline} and each party
+// is {quorum, {p1,...,p_n}}. It locks value, undisputed, until
+// escrowStepSrcFmt pays it out.
+const escrowSrcFmt = `
+	               # Contract stack          Argument stack          Log
+	               # []                      [refdata v terms]       []
+	get get        # [terms v]               [refdata]               []
+	get log        # [terms v]               []                      [{"L", <cid>, refdata}]
+	0 swap         # [terms 0 v]             []                      [{"L", <cid>, refdata}]
+	[%s]           # [terms 0 v <step>]      []                      [{"L", <cid>, refdata}]
+	output         # [terms 0 v]             []                      [{"L", <cid>, refdata} {"O", <caller>, <outputid>}]
+`
+
+// escrowStepSrcFmt expects the contract stack
+// [... {B, S, A, deadline} disputed v] and the argument stack
+// [... s1 ... s_n action]. Unless action is 5 (timeout), each `s_i`
+// must be a valid signature by `p_i` of the escrow message for v's
+// anchor and action (see EscrowMessage), with exactly `q` of them
+// non-empty, where {q, {p1,...,p_n}} is the party that action
+// requires: B for 0 (release) and 2 (dispute), S for 1 (refund), and
+// A for 3 and 4 (award). Then:
+//
+// Release and award 3 pay v to the standard pay-to-multisig contract
+// for S; refund and award 4 pay it to B. Awards require the escrow
+// to be disputed.
+//
+// Dispute requires that it is not, and that the transaction be no
+// later than the deadline, and locks v again, disputed.
+//
+// Timeout requires that the escrow not be disputed and that the
+// transaction be no earlier than the deadline, and pays v to S.
+const escrowStepSrcFmt = `
+	                           # Contract stack                              Argument stack
+	                           # [T d v]                                     [... act]
+	get                        # [T d v act]                                 [...]
+	dup 5 eq jumpif:$timeout   # [T d v act]                                 [...]
+	swap anchor                # [T d act v anchor]                          [s1 ... s_n]
+	2 peek 2 tuple encode      # [T d act v enc]                             [s1 ... s_n]
+	'Escrow' swap cat sha3     # [T d act v msg]                             [s1 ... s_n]
+	0 1 0 2 2 5 tuple          # [T d act v msg {0,1,0,2,2}]                 [s1 ... s_n]
+	3 peek field               # [T d act v msg i]                           [s1 ... s_n]
+	5 peek swap field          # [T d act v msg P]                           [s1 ... s_n]      P = T.i
+	untuple drop               # [T d act v msg q {p}]                       [s1 ... s_n]
+	2 roll swap                # [T d act v q msg {p}]                       [s1 ... s_n]
+	untuple                    # [T d act v q msg p1 ... p_n n]              [s1 ... s_n]
+	0 swap                     # [T d act v q msg p1 ... p_n 0 n]            [s1 ... s_n]
+	$sigstart                  # [... msg p1 ... p_n t n],  t = 0..n         [s1 ... s_n]
+	    dup 0 eq               # [... msg p1 ... p_n t n (n==0)]             [s1 ... s_n]
+	    jumpif:$sigend         # [... msg p1 ... p_n t n]                    [s1 ... s_n]
+	    dup 2 add peek         # [... msg p1 ... p_n t n msg]                [s1 ... s_n]
+	    3 roll                 # [... msg p1 ... p_n-1 t n msg p_n]          [s1 ... s_n]
+	    get                    # [... msg p1 ... p_n-1 t n msg p_n s_n]      [s1 ... s_n-1]
+	    0 checksig             # [... msg p1 ... p_n-1 t n bool]             [s1 ... s_n-1]
+	    2 roll add             # [... msg p1 ... p_n-1 n t’]                 [s1 ... s_n-1]
+	    swap 1 sub             # [... msg p1 ... p_n-1 t’ (n-1)]             [s1 ... s_n-1]
+	    jump:$sigstart         # [... msg p1 ... p_n-1 t’ (n-1)]             [s1 ... s_n-1]
+	$sigend                    # [T d act v q msg t 0]                       []
+	drop swap drop             # [T d act v q t]                             []
+	eq verify                  # [T d act v]                                 []
+	swap                       # [T d v act]                                 []
+	dup 2 eq jumpif:$dispute   # [T d v act]                                 []
+	dup 3 ge                   # [T d v act (act>=3)]                        []
+	3 peek not and not verify  # [T d v act]                                 []
+	1 0 0 1 0 5 tuple          # [T d v act {1,0,0,1,0}]                     []
+	swap field                 # [T d v j]                                   []
+	3 peek swap field          # [T d v R]                                   []                R = T.j
+	untuple drop               # [T d v rq {r}]                              []
+	'' put '' put              # [T d v rq {r}]                              ['' '']
+	2 roll put put put         # [T d]                                       ['' '' v {r} rq]
+	[%s]                       # [T d <multisigprog>]                        ['' '' v {r} rq]
+	contract call              # [T d]                                       []                [... {"O", <cid>, <outputid>}]
+	drop drop                  # []                                          []
+	jump:$end
+
+	$dispute                   # [T d v act]                                 []
+	drop swap not verify       # [T v]                                       []
+	0 2 peek 3 field           # [T v 0 deadline]                            []
+	timerange                  # [T v]                                       []                [{"R", <cid>, 0, deadline}]
+	1 swap                     # [T 1 v]                                     []
+	contractprogram            # [T 1 v <step>]                              []
+	output                     # [T 1 v]                                     []                [{"O", <caller>, <outputid>}]
+
+	$timeout                   # [T d v act]                                 []
+	drop swap not verify       # [T v]                                       []
+	1 peek 3 field 0           # [T v deadline 0]                            []
+	timerange                  # [T v]                                       []                [{"R", <cid>, deadline, 0}]
+	1 peek 1 field             # [T v S]                                     []
+	untuple drop               # [T v sq {s}]                                []
+	'' put '' put              # [T v sq {s}]                                ['' '']
+	2 roll put put put         # [T]                                         ['' '' v {s} sq]
+	[%s]                       # [T <multisigprog>]                          ['' '' v {s} sq]
+	contract call              # [T]                                         []                [{"O", <cid>, <outputid>}]
+	drop                       # []                                          []
+	$end
+`
+
+var (
+	escrowStepSrc = fmt.Sprintf(escrowStepSrcFmt, payToMultisigProgSrc2, payToMultisigProgSrc2)
+
+	// escrowStep is the bytecode of the "step" phase of the
+	// standard escrow contract.
+	escrowStep = mustAssemble(escrowStepSrc)
+
+	escrowSrc = fmt.Sprintf(escrowSrcFmt, escrowStepSrc)
+
+	// EscrowProg is the txvm bytecode of the standard escrow
+	// contract. It holds a buyer's payment to a seller. The buyer
+	// may release it to the seller and the seller may refund it to
+	// the buyer. Until a deadline the buyer may instead dispute it,
+	// after which an arbiter awards it to one or the other. If the
+	// deadline passes without a dispute, it can be paid to the
+	// seller.
+	EscrowProg = mustAssemble(escrowSrc)
+
+	// EscrowSeed is the seed of the standard escrow contract.
+	EscrowSeed = txvm.ContractSeed(EscrowProg)
+)
+
+// EscrowAction is a way of spending a value locked in the standard
+// escrow contract.
+type EscrowAction int
+
+const (
+	// EscrowRelease pays the seller, with the buyer's signatures.
+	EscrowRelease EscrowAction = iota
+
+	// EscrowRefund pays the buyer, with the seller's signatures.
+	EscrowRefund
+
+	// EscrowDispute marks the escrow disputed, with the buyer's
+	// signatures, in a transaction no later than the deadline.
+	EscrowDispute
+
+	// EscrowAwardSeller pays the seller of a disputed escrow, with
+	// the arbiter's signatures.
+	EscrowAwardSeller
+
+	// EscrowAwardBuyer pays the buyer of a disputed escrow, with the
+	// arbiter's signatures.
+	EscrowAwardBuyer
+
+	// EscrowTimeout pays the seller of an undisputed escrow in a
+	// transaction no earlier than the deadline. It needs no
+	// signatures.
+	EscrowTimeout
+)
+
+// EscrowTerms are the parameters of the standard escrow contract.
+type EscrowTerms struct {
+	BuyerQuorum    int
+	BuyerPubkeys   []ed25519.PublicKey
+	SellerQuorum   int
+	SellerPubkeys  []ed25519.PublicKey
+	ArbiterQuorum  int
+	ArbiterPubkeys []ed25519.PublicKey
+
+	// DeadlineMS ends the dispute window.
+	DeadlineMS uint64
+}
+
+func (t *EscrowTerms) tuple() txvm.Tuple {
+	return txvm.Tuple{
+		party(t.BuyerQuorum, t.BuyerPubkeys),
+		party(t.SellerQuorum, t.SellerPubkeys),
+		party(t.ArbiterQuorum, t.ArbiterPubkeys),
+		txvm.Int(t.DeadlineMS),
+	}
+}
+
+// EscrowMessage returns the message that a party's keys sign to
+// take the given action on the escrowed value with the given
+// anchor. Disputing an escrow does not change its value's anchor.
+func EscrowMessage(anchor []byte, action EscrowAction) []byte {
+	enc := txvm.Encode(txvm.Tuple{
+		txvm.Bytes(anchor),
+		txvm.Int(action),
+	})
+	h := sha3.Sum256(append([]byte("Escrow"), enc...))
+	return h[:]
+}
+
+// FundEscrow writes txvm bytecode to b that locks a value in the
+// standard escrow contract with the given terms. The caller must
+// first put the refdata and then the value on the argument stack.
+func FundEscrow(b *txvmutil.Builder, t *EscrowTerms) {
+	pushTuple(b, t.tuple())
+	b.Op(op.Put)                // {terms} put
+	b.PushdataBytes(EscrowProg) // [<escrow program>]
+	b.Op(op.Contract).Op(op.Call)
+}
+
+// SpendEscrow writes txvm bytecode to b that takes the given action
+// on a value locked in the standard escrow contract. Disputed says
+// whether the escrow has been disputed. Sigs are the signatures of
+// the EscrowMessage by the keys of the party the action requires,
+// parallel to its pubkeys; a key that did not sign has an empty
+// signature. EscrowTimeout takes no signatures.
+func SpendEscrow(
+	b *txvmutil.Builder,
+	t *EscrowTerms,
+	amount int64,
+	assetID bc.Hash,
+	anchor []byte,
+	disputed bool,
+	action EscrowAction,
+	sigs [][]byte,
+) {
+	for _, sig := range sigs {
+		b.PushdataBytes(sig).Op(op.Put) // x'<sig>' put
+	}
+	b.PushdataInt64(int64(action)).Op(op.Put) // <action> put
+	pushTuple(b, escrowSnapshot(t, amount, assetID, anchor, disputed))
+	b.Op(op.Input).Op(op.Call)
+}
+
+// EscrowOutputID returns the ID of the output that SpendEscrow
+// spends with the same arguments.
+func EscrowOutputID(t *EscrowTerms, amount int64, assetID bc.Hash, anchor []byte, disputed bool) bc.Hash {
+	snapshot := escrowSnapshot(t, amount, assetID, anchor, disputed)
+	return bc.NewHash(txvm.VMHash("SnapshotID", txvm.Encode(snapshot)))
+}
+
+func escrowSnapshot(t *EscrowTerms, amount int64, assetID bc.Hash, anchor []byte, disputed bool) txvm.Tuple {
+	var d txvm.Int
+	if disputed {
+		d = 1
+	}
+	return txvm.Tuple{
+		txvm.Bytes{txvm.ContractCode},
+		txvm.Bytes(EscrowSeed[:]),
+		txvm.Bytes(escrowStep),
+		txvm.Tuple{txvm.Bytes{txvm.TupleCode}, t.tuple()},
+		txvm.Tuple{txvm.Bytes{txvm.IntCode}, d},
+		txvm.Tuple{txvm.Bytes{txvm.ValueCode}, txvm.Int(amount), txvm.Bytes(assetID.Bytes()), txvm.Bytes(anchor)},
+	}
+}
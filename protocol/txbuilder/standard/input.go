@@ -72,3 +72,21 @@ func SpendMultisig(
 	})
 	b.Op(op.Input).Op(op.Call)
 }
+
+// MultisigOutputID returns the ID of the output that SpendMultisig
+// spends with the same arguments.
+func MultisigOutputID(quorum int, pubkeys []ed25519.PublicKey, amount int64, assetID bc.Hash, anchor []byte, seed []byte) bc.Hash {
+	var pks txvm.Tuple
+	for _, pk := range pubkeys {
+		pks = append(pks, txvm.Bytes(pk))
+	}
+	snapshot := txvm.Tuple{
+		txvm.Bytes{txvm.ContractCode},
+		txvm.Bytes(seed),
+		txvm.Bytes(payToMultisigProgUnlock),
+		txvm.Tuple{txvm.Bytes{txvm.IntCode}, txvm.Int(quorum)},
+		txvm.Tuple{txvm.Bytes{txvm.TupleCode}, pks},
+		txvm.Tuple{txvm.Bytes{txvm.ValueCode}, txvm.Int(amount), txvm.Bytes(assetID.Bytes()), txvm.Bytes(anchor)},
+	}
+	return bc.NewHash(txvm.VMHash("SnapshotID", txvm.Encode(snapshot)))
+}
@@ -0,0 +1,227 @@
+package standard
+
+import (
+	"fmt"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/sha3"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+// oracleSettlementSrcFmt expects the argument stack
+// [... refdata value terms oracle], where oracle is
+// {quorum, {p1,...,p_n}} and terms is {topic, strike, above, below}
+// with above and below each {quorum, {r1,...,r_m}}. It locks value
+// until an oracle attestation settles it with oracleSettleSrcFmt.
+const oracleSettlementSrcFmt = `
+	               # Contract stack                 Argument stack          Log
+	               # []                             [refdata v terms O]     []
+	get get        # [O terms]                      [refdata v]             []
+	get            # [O terms v]                    [refdata]               []
+	get log        # [O terms v]                    []                      [{"L", <cid>, refdata}]
+	[%s]           # [O terms v <settle>]           []                      [{"L", <cid>, refdata}]
+	output         # [O terms v]                    []                      [{"L", <cid>, refdata} {"O", <caller>, <outputid>}]
+`
+
+// oracleSettleSrcFmt expects the argument stack
+// [... s1 ... s_n expms value] and the contract stack
+// [... {q, {p1,...,p_n}} {topic, strike, above, below} v]. It checks
+// that each `s_i` is a valid signature by `p_i` of the attestation
+// message for topic, value, and expms (see AttestationMessage), with
+// exactly `q` of them non-empty, and that the transaction is no
+// later than expms. It then logs `value` and pays all of `v` to the
+// standard pay-to-multisig contract for `above` if value is at
+// least strike, and for `below` otherwise.
+const oracleSettleSrcFmt = `
+	                      # Contract stack                            Argument stack
+	                      # [O T v]                                   [s1 ... s_n exp val]
+	2 roll untuple drop   # [T v q {p}]                               [s1 ... s_n exp val]
+	get get swap          # [T v q {p} exp val]                       [s1 ... s_n]
+	5 peek 0 field        # [T v q {p} exp val topic]                 [s1 ... s_n]
+	1 peek 3 peek         # [T v q {p} exp val topic val exp]         [s1 ... s_n]
+	3 tuple encode        # [T v q {p} exp val enc]                   [s1 ... s_n]
+	'Attestation'         # [T v q {p} exp val enc 'Attestation']     [s1 ... s_n]
+	swap cat sha3         # [T v q {p} exp val msg]                   [s1 ... s_n]
+	0 3 peek timerange    # [T v q {p} exp val msg]                   [s1 ... s_n]         [{"R", <cid>, 0, exp}]
+	2 roll drop           # [T v q {p} val msg]                       [s1 ... s_n]
+	2 roll untuple        # [T v q val msg p1 ... p_n n]              [s1 ... s_n]
+	0 swap                # [T v q val msg p1 ... p_n 0 n]            [s1 ... s_n]
+	$sigstart             # [... msg p1 ... p_n t n],  t = 0..n       [s1 ... s_n]
+	    dup 0 eq          # [... msg p1 ... p_n t n (n==0)]           [s1 ... s_n]
+	    jumpif:$sigend    # [... msg p1 ... p_n t n]                  [s1 ... s_n]
+	    dup 2 add peek    # [... msg p1 ... p_n t n msg]              [s1 ... s_n]
+	    3 roll            # [... msg p1 ... p_n-1 t n msg p_n]        [s1 ... s_n]
+	    get               # [... msg p1 ... p_n-1 t n msg p_n s_n]    [s1 ... s_n-1]
+	    0 checksig        # [... msg p1 ... p_n-1 t n bool]           [s1 ... s_n-1]
+	    2 roll add        # [... msg p1 ... p_n-1 n t’]               [s1 ... s_n-1]
+	    swap 1 sub        # [... msg p1 ... p_n-1 t’ (n-1)]           [s1 ... s_n-1]
+	    jump:$sigstart    # [... msg p1 ... p_n-1 t’ (n-1)]           [s1 ... s_n-1]
+	$sigend               # [T v q val msg t 0]                       []
+	drop swap drop        # [T v q val t]                             []
+	2 roll eq verify      # [T v val]                                 []
+	dup log               # [T v val]                                 []                   [... {"L", <cid>, val}]
+	2 roll untuple drop   # [v val topic strike A B]                  []
+	3 roll drop           # [v val strike A B]                        []
+	3 roll 3 roll ge      # [v A B (val>=strike)]                     []
+	jumpif:$above         # [v A B]                                   []
+	swap drop             # [v B]                                     []
+	jump:$pay
+	$above                # [v A B]                                   []
+	drop                  # [v A]                                     []
+	$pay                  # [v R]                                     []
+	'' put '' put         # [v R]                                     ['' '']
+	swap put              # [R]                                       ['' '' v]
+	untuple drop          # [rq {r}]                                  ['' '' v]
+	put put               # []                                        ['' '' v {r} rq]
+	[%s]                  # [<multisigprog>]                          ['' '' v {r} rq]
+	contract call         # []                                        []                   [... {"O", <cid>, <outputid>}]
+`
+
+var (
+	oracleSettleSrc = fmt.Sprintf(oracleSettleSrcFmt, payToMultisigProgSrc2)
+
+	// oracleSettle is the bytecode of the "settle" phase of the
+	// standard oracle-settlement contract.
+	oracleSettle = mustAssemble(oracleSettleSrc)
+
+	oracleSettlementSrc = fmt.Sprintf(oracleSettlementSrcFmt, oracleSettleSrc)
+
+	// OracleSettlementProg is the txvm bytecode of the standard
+	// oracle-settlement contract. It holds value until a quorum of
+	// oracle keys attests to the value of some topic, such as a
+	// price or the outcome of an event, and then pays it to one of
+	// two parties depending on whether the attested value reaches a
+	// strike. Derivatives and insurance contracts build on it.
+	OracleSettlementProg = mustAssemble(oracleSettlementSrc)
+
+	// OracleSettlementSeed is the seed of the standard
+	// oracle-settlement contract.
+	OracleSettlementSeed = txvm.ContractSeed(OracleSettlementProg)
+)
+
+// AttestationMessage returns the message that oracle keys sign to
+// attest that topic had the given value, for use until expMS.
+func AttestationMessage(topic []byte, value int64, expMS uint64) []byte {
+	enc := txvm.Encode(txvm.Tuple{
+		txvm.Bytes(topic),
+		txvm.Int(value),
+		txvm.Int(int64(expMS)),
+	})
+	h := sha3.Sum256(append([]byte("Attestation"), enc...))
+	return h[:]
+}
+
+// OracleTerms are the parameters of the standard oracle-settlement
+// contract.
+type OracleTerms struct {
+	OracleQuorum  int
+	OraclePubkeys []ed25519.PublicKey
+
+	Topic  []byte
+	Strike int64
+
+	// The value goes to AboveQuorum of AbovePubkeys if the attested
+	// value is at least Strike, and to BelowQuorum of BelowPubkeys
+	// otherwise.
+	AboveQuorum  int
+	AbovePubkeys []ed25519.PublicKey
+	BelowQuorum  int
+	BelowPubkeys []ed25519.PublicKey
+}
+
+func (t *OracleTerms) tuples() (terms, oracle txvm.Tuple) {
+	party := func(quorum int, pubkeys []ed25519.PublicKey) txvm.Tuple {
+		var pks txvm.Tuple
+		for _, pk := range pubkeys {
+			pks = append(pks, txvm.Bytes(pk))
+		}
+		return txvm.Tuple{txvm.Int(quorum), pks}
+	}
+	terms = txvm.Tuple{
+		txvm.Bytes(t.Topic),
+		txvm.Int(t.Strike),
+		party(t.AboveQuorum, t.AbovePubkeys),
+		party(t.BelowQuorum, t.BelowPubkeys),
+	}
+	return terms, party(t.OracleQuorum, t.OraclePubkeys)
+}
+
+// LockOracleSettlement writes txvm bytecode to b that locks a value
+// in the standard oracle-settlement contract with the given terms.
+// The caller must first put the refdata and then the value on the
+// argument stack.
+func LockOracleSettlement(b *txvmutil.Builder, t *OracleTerms) {
+	terms, oracle := t.tuples()
+	pushTuple(b, terms)
+	b.Op(op.Put) // {terms} put
+	pushTuple(b, oracle)
+	b.Op(op.Put)                          // {oracle} put
+	b.PushdataBytes(OracleSettlementProg) // [<oracle settlement program>]
+	b.Op(op.Contract).Op(op.Call)         // contract call
+}
+
+// SettleOracle writes txvm bytecode to b, spending a value
+// previously locked with the standard oracle-settlement contract by
+// presenting an attestation that the terms' topic had the given
+// value. Sigs are the oracle keys' signatures of the
+// AttestationMessage, parallel to t.OraclePubkeys; a key that did
+// not sign has an empty signature.
+func SettleOracle(
+	b *txvmutil.Builder,
+	t *OracleTerms,
+	amount int64,
+	assetID bc.Hash,
+	anchor []byte,
+	value int64,
+	expMS uint64,
+	sigs [][]byte,
+) {
+	for _, sig := range sigs {
+		b.PushdataBytes(sig).Op(op.Put) // x'<sig>' put
+	}
+	b.PushdataUint64(expMS).Op(op.Put) // <expMS> put
+	b.PushdataInt64(value).Op(op.Put)  // <value> put
+	pushTuple(b, oracleSettlementSnapshot(t, amount, assetID, anchor))
+	b.Op(op.Input).Op(op.Call)
+}
+
+// OracleSettlementOutputID returns the ID of the output that
+// SettleOracle spends with the same arguments.
+func OracleSettlementOutputID(t *OracleTerms, amount int64, assetID bc.Hash, anchor []byte) bc.Hash {
+	snapshot := oracleSettlementSnapshot(t, amount, assetID, anchor)
+	return bc.NewHash(txvm.VMHash("SnapshotID", txvm.Encode(snapshot)))
+}
+
+func oracleSettlementSnapshot(t *OracleTerms, amount int64, assetID bc.Hash, anchor []byte) txvm.Tuple {
+	terms, oracle := t.tuples()
+	return txvm.Tuple{
+		txvm.Bytes{txvm.ContractCode},
+		txvm.Bytes(OracleSettlementSeed[:]),
+		txvm.Bytes(oracleSettle),
+		txvm.Tuple{txvm.Bytes{txvm.TupleCode}, oracle},
+		txvm.Tuple{txvm.Bytes{txvm.TupleCode}, terms},
+		txvm.Tuple{txvm.Bytes{txvm.ValueCode}, txvm.Int(amount), txvm.Bytes(assetID.Bytes()), txvm.Bytes(anchor)},
+	}
+}
+
+// pushTuple writes bytecode to b that pushes tup, which may hold
+// only strings, ints, and tuples.
+func pushTuple(b *txvmutil.Builder, tup txvm.Tuple) {
+	var items func(*txvmutil.TupleBuilder, txvm.Tuple)
+	items = func(tb *txvmutil.TupleBuilder, tup txvm.Tuple) {
+		for _, item := range tup {
+			switch item := item.(type) {
+			case txvm.Bytes:
+				tb.PushdataBytes(item)
+			case txvm.Int:
+				tb.PushdataInt64(int64(item))
+			case txvm.Tuple:
+				tb.Tuple(func(tb *txvmutil.TupleBuilder) { items(tb, item) })
+			}
+		}
+	}
+	b.Tuple(func(tb *txvmutil.TupleBuilder) { items(tb, tup) })
+}
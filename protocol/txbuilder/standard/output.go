@@ -3,6 +3,7 @@ package standard
 import (
 	"fmt"
 
+	"i10r.io/crypto/ed25519"
 	"i10r.io/protocol/txvm"
 )
 
@@ -60,3 +61,16 @@ var (
 	// PayToMultisigSeed2 is the seed of the standard pay-to-multisig-program contract.
 	PayToMultisigSeed2 = txvm.ContractSeed(PayToMultisigProg2)
 )
+
+// PredicateHash returns a hash identifying the predicate of a
+// standard pay-to-multisig output: quorum of pubkeys. It is the same
+// for outputs of either version, so a watcher can match outputs to
+// an address without knowing its keys.
+func PredicateHash(quorum int, pubkeys []ed25519.PublicKey) [32]byte {
+	var pubkeysTuple txvm.Tuple
+	for _, pubkey := range pubkeys {
+		pubkeysTuple = append(pubkeysTuple, txvm.Bytes(pubkey))
+	}
+	tuple := txvm.Tuple{txvm.Int(quorum), pubkeysTuple}
+	return txvm.VMHash("PayToMultisigPredicate", txvm.Encode(tuple))
+}
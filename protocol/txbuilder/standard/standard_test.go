@@ -3,10 +3,16 @@ package standard
 import (
 	"bytes"
 	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
 	"testing"
 
 	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
 	"i10r.io/protocol/bc"
+	"i10r.io/protocol/contracts"
+	"i10r.io/protocol/txvm"
 	"i10r.io/protocol/txvm/txvmutil"
 	"i10r.io/testutil"
 )
@@ -41,6 +47,31 @@ func TestSeeds(t *testing.T) {
 	if RetireContractSeed != wantRetireContractSeed {
 		t.Errorf("RetireContractSeed is %x, want %x", RetireContractSeed[:], wantRetireContractSeed[:])
 	}
+
+	wantBridgeReserveSeed := mustDecodeHex("6d41a633c8cc3aef118507c3a4cec8173c4056e72fc35170fc71d38d01e2a1ac")
+	if BridgeReserveSeed != wantBridgeReserveSeed {
+		t.Errorf("BridgeReserveSeed is %x, want %x", BridgeReserveSeed[:], wantBridgeReserveSeed[:])
+	}
+
+	wantOracleSettlementSeed := mustDecodeHex("b8d8ca69a57e57f031f176b2d0a4dc3424ed2f1849734c0a0a216c06312bb64c")
+	if OracleSettlementSeed != wantOracleSettlementSeed {
+		t.Errorf("OracleSettlementSeed is %x, want %x", OracleSettlementSeed[:], wantOracleSettlementSeed[:])
+	}
+
+	wantEscrowSeed := mustDecodeHex("e1497e5e821afab437adbe98610685e0d31fea2dff15451ad5230dfcc69dd4a3")
+	if EscrowSeed != wantEscrowSeed {
+		t.Errorf("EscrowSeed is %x, want %x", EscrowSeed[:], wantEscrowSeed[:])
+	}
+
+	wantVestingSeed := mustDecodeHex("01f621793d42c3575668dc8fbee5f47b98acdfde8ae4afec1e084f716e69367b")
+	if VestingSeed != wantVestingSeed {
+		t.Errorf("VestingSeed is %x, want %x", VestingSeed[:], wantVestingSeed[:])
+	}
+
+	wantStreamSeed := mustDecodeHex("e7b6347dec39711919b967b7f2cd715b51f26b10695950cce8dede9c4c641ca4")
+	if StreamSeed != wantStreamSeed {
+		t.Errorf("StreamSeed is %x, want %x", StreamSeed[:], wantStreamSeed[:])
+	}
 }
 
 func TestProgCreation(t *testing.T) {
@@ -132,6 +163,407 @@ func TestProgCreation(t *testing.T) {
 	}
 }
 
+func TestStream(t *testing.T) {
+	payerPub, payerPrv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	terms := &StreamTerms{
+		PayerQuorum:  1,
+		PayerPubkeys: []ed25519.PublicKey{payerPub},
+		PayeeQuorum:  1,
+		PayeePubkeys: testutil.TestPubs,
+		StartMS:      1000000,
+		EndMS:        1100000, // 100 seconds later
+		Total:        1000,
+	}
+	assetID := bc.HashFromBytes([]byte("assetID"))
+	anchor := []byte("anchor")
+	fin := mustAssemble("'id' 10 nonce finalize")
+	run := func(build func(*txvmutil.Builder)) (outputs []bc.Hash, ranges [][2]int64, err error) {
+		var b txvmutil.Builder
+		build(&b)
+		b.Concat(fin)
+		vm, err := txvm.Validate(b.Build(), 3, 100000)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, item := range vm.Log {
+			switch item[0].(txvm.Bytes)[0] {
+			case txvm.OutputCode:
+				outputs = append(outputs, bc.HashFromBytes(item[2].(txvm.Bytes)))
+			case txvm.TimerangeCode:
+				if bytes.Equal(item[1].(txvm.Bytes), StreamSeed[:]) {
+					ranges = append(ranges, [2]int64{int64(item[2].(txvm.Int)), int64(item[3].(txvm.Int))})
+				}
+			}
+		}
+		return outputs, ranges, nil
+	}
+	split := func(n int, anchor []byte) []byte {
+		h := txvm.VMHash(fmt.Sprintf("Split%d", n), anchor)
+		return h[:]
+	}
+
+	// Lock the output of a 0-of-0 multisig output in a stream.
+	outputs, _, err := run(func(b *txvmutil.Builder) {
+		b.Concat(mustAssemble("'' put"))
+		SpendMultisig(b, 0, nil, 1000, assetID, anchor, PayToMultisigSeed2[:])
+		b.Concat(mustAssemble("get get 'ref' put put"))
+		LockStream(b, terms)
+		b.Concat(mustAssemble("'' put call"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := StreamOutputID(terms, 1000, assetID, anchor, 0); len(outputs) != 1 || outputs[0] != want {
+		t.Errorf("lock: outputs %x, want [%x]", outputs, want)
+	}
+
+	// Withdraw after 30.5 seconds: 30 seconds have vested.
+	outputs, ranges, err := run(func(b *txvmutil.Builder) {
+		WithdrawStream(b, terms, 1000, assetID, anchor, 0, 1030500)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := terms.Vested(1030500); v != 300 {
+		t.Errorf("Vested(30.5s) = %d, want 300", v)
+	}
+	anchor1 := split(1, anchor)
+	want := []bc.Hash{
+		MultisigOutputID(1, testutil.TestPubs, 300, assetID, split(2, anchor), PayToMultisigSeed2[:]),
+		StreamOutputID(terms, 700, assetID, anchor1, 300),
+	}
+	if !reflect.DeepEqual(outputs, want) {
+		t.Errorf("withdraw: outputs %x, want %x", outputs, want)
+	}
+	if want := [][2]int64{{1030500, 0}}; !reflect.DeepEqual(ranges, want) {
+		t.Errorf("withdraw: timeranges %v, want %v", ranges, want)
+	}
+	if _, _, err := run(func(b *txvmutil.Builder) {
+		WithdrawStream(b, terms, 700, assetID, anchor1, 300, 1030999)
+	}); err == nil {
+		t.Error("withdrew nothing: got no error")
+	}
+
+	// Withdraw everything after the end.
+	outputs, _, err = run(func(b *txvmutil.Builder) {
+		WithdrawStream(b, terms, 700, assetID, anchor1, 300, 2000000)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []bc.Hash{MultisigOutputID(1, testutil.TestPubs, 700, assetID, split(2, anchor1), PayToMultisigSeed2[:])}
+	if !reflect.DeepEqual(outputs, want) {
+		t.Errorf("final withdraw: outputs %x, want %x", outputs, want)
+	}
+
+	// Cancel at 50 seconds.
+	cancel := func(sig []byte, atMS uint64) ([]bc.Hash, [][2]int64, error) {
+		return run(func(b *txvmutil.Builder) {
+			CancelStream(b, terms, 700, assetID, anchor1, 300, atMS, [][]byte{sig})
+		})
+	}
+	sig := ed25519.Sign(payerPrv, StreamCancelMessage(anchor1, 1050000))
+	outputs, ranges, err = cancel(sig, 1050000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []bc.Hash{
+		MultisigOutputID(1, testutil.TestPubs, 200, assetID, split(2, anchor1), PayToMultisigSeed2[:]),
+		MultisigOutputID(1, []ed25519.PublicKey{payerPub}, 500, assetID, split(1, anchor1), PayToMultisigSeed2[:]),
+	}
+	if !reflect.DeepEqual(outputs, want) {
+		t.Errorf("cancel: outputs %x, want %x", outputs, want)
+	}
+	if want := [][2]int64{{0, 1050000}}; !reflect.DeepEqual(ranges, want) {
+		t.Errorf("cancel: timeranges %v, want %v", ranges, want)
+	}
+	if _, _, err := cancel(sig, 1060000); err == nil {
+		t.Error("cancel with signature for another time: got no error")
+	}
+	if _, _, err := cancel(nil, 1050000); err == nil {
+		t.Error("cancel without signature: got no error")
+	}
+
+	// Cancelling before the start returns everything to the payer.
+	outputs, _, err = run(func(b *txvmutil.Builder) {
+		sig := ed25519.Sign(payerPrv, StreamCancelMessage(anchor, 0))
+		CancelStream(b, terms, 1000, assetID, anchor, 0, 0, [][]byte{sig})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []bc.Hash{MultisigOutputID(1, []ed25519.PublicKey{payerPub}, 1000, assetID, split(1, anchor), PayToMultisigSeed2[:])}
+	if !reflect.DeepEqual(outputs, want) {
+		t.Errorf("cancel before start: outputs %x, want %x", outputs, want)
+	}
+}
+
+func TestEscrow(t *testing.T) {
+	var (
+		pubs [3]ed25519.PublicKey
+		prvs [3]ed25519.PrivateKey
+	)
+	for i := range pubs {
+		var err error
+		pubs[i], prvs[i], err = ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	const buyer, seller, arbiter = 0, 1, 2
+	terms := &EscrowTerms{
+		BuyerQuorum:    1,
+		BuyerPubkeys:   pubs[buyer : buyer+1],
+		SellerQuorum:   1,
+		SellerPubkeys:  pubs[seller : seller+1],
+		ArbiterQuorum:  1,
+		ArbiterPubkeys: pubs[arbiter : arbiter+1],
+		DeadlineMS:     5000,
+	}
+	assetID := bc.HashFromBytes([]byte("assetID"))
+	anchor := []byte("anchor")
+	fin := mustAssemble("'id' 10 nonce finalize")
+	run := func(build func(*txvmutil.Builder)) (outputs []bc.Hash, ranges [][2]int64, err error) {
+		var b txvmutil.Builder
+		build(&b)
+		b.Concat(fin)
+		vm, err := txvm.Validate(b.Build(), 3, 100000)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, item := range vm.Log {
+			switch item[0].(txvm.Bytes)[0] {
+			case txvm.OutputCode:
+				outputs = append(outputs, bc.HashFromBytes(item[2].(txvm.Bytes)))
+			case txvm.TimerangeCode:
+				if bytes.Equal(item[1].(txvm.Bytes), EscrowSeed[:]) {
+					ranges = append(ranges, [2]int64{int64(item[2].(txvm.Int)), int64(item[3].(txvm.Int))})
+				}
+			}
+		}
+		return outputs, ranges, nil
+	}
+
+	outputs, _, err := run(func(b *txvmutil.Builder) {
+		b.Concat(mustAssemble("'' put"))
+		SpendMultisig(b, 0, nil, 100, assetID, anchor, PayToMultisigSeed2[:])
+		b.Concat(mustAssemble("get get 'ref' put put"))
+		FundEscrow(b, terms)
+		b.Concat(mustAssemble("'' put call"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := EscrowOutputID(terms, 100, assetID, anchor, false); len(outputs) != 1 || outputs[0] != want {
+		t.Errorf("fund: outputs %x, want [%x]", outputs, want)
+	}
+
+	pay := func(who int) bc.Hash {
+		return MultisigOutputID(1, pubs[who:who+1], 100, assetID, anchor, PayToMultisigSeed2[:])
+	}
+	cases := []struct {
+		name     string
+		disputed bool
+		action   EscrowAction
+		signer   int // -1 for none
+		want     bc.Hash
+		ranges   [][2]int64
+		ok       bool
+	}{
+		{"release", false, EscrowRelease, buyer, pay(seller), nil, true},
+		{"release signed by seller", false, EscrowRelease, seller, bc.Hash{}, nil, false},
+		{"refund", false, EscrowRefund, seller, pay(buyer), nil, true},
+		{"dispute", false, EscrowDispute, buyer, EscrowOutputID(terms, 100, assetID, anchor, true), [][2]int64{{0, 5000}}, true},
+		{"dispute twice", true, EscrowDispute, buyer, bc.Hash{}, nil, false},
+		{"award undisputed", false, EscrowAwardSeller, arbiter, bc.Hash{}, nil, false},
+		{"award seller", true, EscrowAwardSeller, arbiter, pay(seller), nil, true},
+		{"award buyer", true, EscrowAwardBuyer, arbiter, pay(buyer), nil, true},
+		{"award signed by buyer", true, EscrowAwardBuyer, buyer, bc.Hash{}, nil, false},
+		{"release disputed", true, EscrowRelease, buyer, pay(seller), nil, true},
+		{"timeout", false, EscrowTimeout, -1, pay(seller), [][2]int64{{5000, 0}}, true},
+		{"timeout disputed", true, EscrowTimeout, -1, bc.Hash{}, nil, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var sigs [][]byte
+			if c.signer >= 0 {
+				sigs = [][]byte{ed25519.Sign(prvs[c.signer], EscrowMessage(anchor, c.action))}
+			}
+			outputs, ranges, err := run(func(b *txvmutil.Builder) {
+				SpendEscrow(b, terms, 100, assetID, anchor, c.disputed, c.action, sigs)
+			})
+			if !c.ok {
+				if err == nil {
+					t.Error("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(outputs) != 1 || outputs[0] != c.want {
+				t.Errorf("outputs %x, want [%x]", outputs, c.want)
+			}
+			if !reflect.DeepEqual(ranges, c.ranges) {
+				t.Errorf("timeranges %v, want %v", ranges, c.ranges)
+			}
+		})
+	}
+}
+
+func TestLinearVesting(t *testing.T) {
+	got, err := LinearVesting(1000, 0, 30000, 100000, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VestingTranche{{30000, 300}}
+	for ms := uint64(40000); ms <= 100000; ms += 10000 {
+		want = append(want, VestingTranche{ms, 100})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Three units over four periods: a period with nothing vesting is
+	// omitted.
+	got, err = LinearVesting(3, 1000, 1000, 5000, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []VestingTranche{{3000, 1}, {4000, 1}, {5000, 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Large totals must not overflow.
+	got, err = LinearVesting(math.MaxInt64, 0, 0, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := (&VestingSchedule{Tranches: got}).Total(); len(got) != 3 || s != math.MaxInt64 {
+		t.Errorf("got %v totaling %d, want 3 tranches totaling %d", got, s, int64(math.MaxInt64))
+	}
+
+	for _, c := range [][5]int64{
+		{0, 0, 0, 10, 1},
+		{10, 0, 11, 10, 1},
+		{10, 5, 0, 10, 1},
+		{10, 10, 10, 10, 1},
+		{10, 0, 0, 10, 0},
+	} {
+		_, err := LinearVesting(c[0], uint64(c[1]), uint64(c[2]), uint64(c[3]), uint64(c[4]))
+		if errors.Root(err) != ErrVestingSchedule {
+			t.Errorf("LinearVesting%v: got error %v, want %v", c, err, ErrVestingSchedule)
+		}
+	}
+}
+
+func TestVesting(t *testing.T) {
+	tranches, err := LinearVesting(1000, 0, 30000, 100000, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &VestingSchedule{
+		Quorum:   1,
+		Pubkeys:  testutil.TestPubs,
+		Tranches: tranches,
+	}
+	assetID := bc.HashFromBytes([]byte("assetID"))
+	anchor := []byte("anchor")
+
+	// Fund the schedule from the output of a 0-of-0 multisig output.
+	var b txvmutil.Builder
+	b.Concat(mustAssemble("'' put"))
+	SpendMultisig(&b, 0, nil, 1000, assetID, anchor, PayToMultisigSeed2[:])
+	b.Concat(mustAssemble("get get put"))
+	FundVesting(&b, s, []byte("ref"))
+	b.Concat(mustAssemble("'' put call 'id' 10 nonce finalize"))
+	tx, err := bc.NewTx(b.Build(), 3, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []bc.Hash
+	for i := range s.Tranches {
+		want = append(want, VestingOutputID(s, i, assetID, anchor))
+	}
+	var got []bc.Hash
+	for _, out := range tx.Outputs {
+		got = append(got, out.ID)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fund: outputs %x, want %x", got, want)
+	}
+	wantLabel := fmt.Sprintf("standard vesting (1-of-%d)", len(testutil.TestPubs))
+	for _, out := range tx.Outputs {
+		if l := contracts.Label(out.Seed.Bytes(), out.Stack); l != wantLabel {
+			t.Errorf("fund: output labeled %q, want %q", l, wantLabel)
+		}
+	}
+
+	// Claim what has unlocked at 45 seconds.
+	is := s.Claimable(45000)
+	if !reflect.DeepEqual(is, []int{0, 1}) {
+		t.Fatalf("Claimable(45s) = %v, want [0 1]", is)
+	}
+	tx, err = VestingClaimTx(s, assetID, anchor, is, []byte("id"), 50000, 3, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VestingClaimTx(s, assetID, anchor, is, []byte("id"), 50000, txvm.NetworkVersion, 100000); err == nil {
+		t.Error("claim: no error for a version 5 transaction with a short blockchain ID")
+	}
+	network := bytes.Repeat([]byte{7}, 32)
+	tx5, err := VestingClaimTx(s, assetID, anchor, is, network, 50000, txvm.NetworkVersion, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := txvm.Validate(tx5.Program, txvm.NetworkVersion, 100000, bc.NetworkOption(bc.HashFromBytes(network)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx5.ID != bc.NewHash(vm.TxID) {
+		t.Errorf("claim: version 5 ID %x, want %x", tx5.ID.Bytes(), vm.TxID[:])
+	}
+	if len(tx.Inputs) != 2 || tx.Inputs[0].ID != want[0] || tx.Inputs[1].ID != want[1] {
+		t.Errorf("claim: inputs %v, want %x", tx.Inputs, want[:2])
+	}
+	anchor1 := txvm.VMHash("Split1", anchor)
+	wantOut := []bc.Hash{
+		MultisigOutputID(1, testutil.TestPubs, 300, assetID, split2(anchor), PayToMultisigSeed2[:]),
+		MultisigOutputID(1, testutil.TestPubs, 100, assetID, split2(anchor1[:]), PayToMultisigSeed2[:]),
+	}
+	got = nil
+	for _, out := range tx.Outputs {
+		got = append(got, out.ID)
+	}
+	if !reflect.DeepEqual(got, wantOut) {
+		t.Errorf("claim: outputs %x, want %x", got, wantOut)
+	}
+	wantLabel = fmt.Sprintf("standard pay-to-multisig v2 (1-of-%d)", len(testutil.TestPubs))
+	for _, out := range tx.Outputs {
+		if l := contracts.Label(out.Seed.Bytes(), out.Stack); l != wantLabel {
+			t.Errorf("claim: output labeled %q, want %q", l, wantLabel)
+		}
+	}
+	var min int64
+	for _, tr := range tx.Timeranges {
+		if tr.MinMS > min {
+			min = tr.MinMS
+		}
+	}
+	if min != 40000 {
+		t.Errorf("claim: minimum time %d, want 40000", min)
+	}
+}
+
+func split2(anchor []byte) []byte {
+	h := txvm.VMHash("Split2", anchor)
+	return h[:]
+}
+
 func mustDecodeHex(s string) [32]byte {
 	var result [32]byte
 	_, err := hex.Decode(result[:], []byte(s))
@@ -0,0 +1,294 @@
+package standard
+
+import (
+	"fmt"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/sha3"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+// streamSrcFmt expects the argument stack
+// [... refdata value terms payer], where payer is
+// {quorum, {p1,...,p_n}} and terms is {start, end, total, payee}
+// with payee {quorum, {r1,...,r_m}}. It locks value, with nothing
+// yet withdrawn, until streamStepSrcFmt withdraws or cancels it.
+const streamSrcFmt = `
+	               # Contract stack             Argument stack            Log
+	               # []                         [refdata v terms P]       []
+	get get        # [P terms]                  [refdata v]               []
+	get            # [P terms v]                [refdata]                 []
+	get log        # [P terms v]                []                        [{"L", <cid>, refdata}]
+	0              # [P terms v 0]              []                        [{"L", <cid>, refdata}]
+	[%s]           # [P terms v 0 <step>]       []                        [{"L", <cid>, refdata}]
+	output         # [P terms v 0]              []                        [{"L", <cid>, refdata} {"O", <caller>, <outputid>}]
+`
+
+// streamStepSrcFmt expects the contract stack
+// [... {q, {p1,...,p_n}} {start, end, total, R} v w], where w is the
+// amount withdrawn so far, and the argument stack [... t sel]. It
+// computes the amount vested at time t: total times the fraction of
+// the period from start to end that has elapsed by t, limited to
+// [0, total] and counted in whole seconds. It computes it as
+// q*e + r*e/d, with total = q*d + r, so that it cannot overflow.
+//
+// If sel is false it withdraws: it requires that the transaction be
+// no earlier than t, pays the vested amount less w to the standard
+// pay-to-multisig contract for R, and locks the rest of v again
+// with the vested amount as the new w.
+//
+// If sel is true it cancels. The argument stack must then be
+// [... s1 ... s_n t sel], where each `s_i` is a valid signature by
+// `p_i` of the cancel message for v's anchor and t (see
+// StreamCancelMessage), with exactly `q` of them non-empty. It
+// requires that the transaction be no later than t, pays the vested
+// amount less w to R and the rest of v to the payer, omitting
+// either payment if it is zero.
+const streamStepSrcFmt = `
+	                           # Contract stack                              Argument stack
+	                           # [P T v w]                                   [... t sel]
+	get get                    # [P T v w sel t]                             [...]
+	dup 5 peek                 # [P T v w sel t t T]                         [...]
+	untuple drop drop          # [P T v w sel t t start end total]           [...]
+	3 roll 3 peek sub          # [P T v w sel t start end total e]           [...]      e = t-start
+	2 roll 3 roll sub          # [P T v w sel t total e d]                   [...]      d = end-start
+	swap                       # [P T v w sel t total d e]                   [...]
+	1 peek 1 peek lt           # [P T v w sel t total d e (d<e)]             [...]
+	not jumpif:$notlate        # [P T v w sel t total d e]                   [...]
+	drop dup                   # [P T v w sel t total d d]                   [...]
+	$notlate                   # [P T v w sel t total d e]                   [...]
+	dup 0 lt                   # [P T v w sel t total d e (e<0)]             [...]
+	not jumpif:$notearly       # [P T v w sel t total d e]                   [...]
+	drop 0                     # [P T v w sel t total d 0]                   [...]
+	$notearly                  # [P T v w sel t total d e],  0 <= e <= d     [...]
+	1000 div swap 1000 div     # [P T v w sel t total e' d']                 [...]      in seconds
+	swap                       # [P T v w sel t total d' e']                 [...]
+	2 peek 2 peek div          # [P T v w sel t total d' e' q]               [...]      q = total div d'
+	1 peek mul                 # [P T v w sel t total d' e' q*e']            [...]
+	3 roll 3 peek mod          # [P T v w sel t d' e' q*e' r]                [...]      r = total mod d'
+	2 roll mul                 # [P T v w sel t d' q*e' r*e']                [...]
+	2 roll div add             # [P T v w sel t vested]                      [...]
+	2 roll                     # [P T v w t vested sel]                      [...]
+	jumpif:$cancel             # [P T v w t vested]                          [...]
+
+	swap 0 timerange           # [P T v w vested]                            []         [{"R", <cid>, t, 0}]
+	dup 2 roll sub             # [P T v vested amt]                          []
+	dup 0 gt verify            # [P T v vested amt]                          []
+	2 roll swap split          # [P T vested rest payment]                   []
+	3 peek 3 field             # [P T vested rest payment R]                 []
+	untuple drop               # [P T vested rest payment rq {r}]            []
+	'' put '' put              # [P T vested rest payment rq {r}]            ['' '']
+	2 roll put put put         # [P T vested rest]                           ['' '' payment {r} rq]
+	[%s]                       # [P T vested rest <multisigprog>]            ['' '' payment {r} rq]
+	contract call              # [P T vested rest]                           []         [... {"O", <cid>, <outputid>}]
+	amount 0 eq                # [P T vested rest (rest.amount==0)]          []
+	jumpif:$spent              # [P T vested rest]                           []
+	swap                       # [P T rest vested]                           []
+	contractprogram            # [P T rest vested <step>]                    []
+	output                     # [P T rest vested]                           []         [... {"O", <caller>, <outputid>}]
+	$spent                     # [P T vested zeroval]                        []
+	drop drop drop drop        # []                                          []
+	jump:$end
+
+	$cancel                    # [P T v w t vested]                          [s1 ... s_n]
+	1 peek 0 swap timerange    # [P T v w t vested]                          [s1 ... s_n]         [{"R", <cid>, 0, t}]
+	3 roll anchor              # [P T w t vested v anchor]                   [s1 ... s_n]
+	3 roll 2 tuple encode      # [P T w vested v enc]                        [s1 ... s_n]
+	'StreamCancel'             # [P T w vested v enc 'StreamCancel']         [s1 ... s_n]
+	swap cat sha3              # [P T w vested v msg]                        [s1 ... s_n]
+	5 peek untuple drop        # [P T w vested v msg q {p}]                  [s1 ... s_n]
+	2 roll swap                # [P T w vested v q msg {p}]                  [s1 ... s_n]
+	untuple                    # [P T w vested v q msg p1 ... p_n n]         [s1 ... s_n]
+	0 swap                     # [P T w vested v q msg p1 ... p_n 0 n]       [s1 ... s_n]
+	$sigstart                  # [... msg p1 ... p_n t n],  t = 0..n         [s1 ... s_n]
+	    dup 0 eq               # [... msg p1 ... p_n t n (n==0)]             [s1 ... s_n]
+	    jumpif:$sigend         # [... msg p1 ... p_n t n]                    [s1 ... s_n]
+	    dup 2 add peek         # [... msg p1 ... p_n t n msg]                [s1 ... s_n]
+	    3 roll                 # [... msg p1 ... p_n-1 t n msg p_n]          [s1 ... s_n]
+	    get                    # [... msg p1 ... p_n-1 t n msg p_n s_n]      [s1 ... s_n-1]
+	    0 checksig             # [... msg p1 ... p_n-1 t n bool]             [s1 ... s_n-1]
+	    2 roll add             # [... msg p1 ... p_n-1 n t’]                 [s1 ... s_n-1]
+	    swap 1 sub             # [... msg p1 ... p_n-1 t’ (n-1)]             [s1 ... s_n-1]
+	    jump:$sigstart         # [... msg p1 ... p_n-1 t’ (n-1)]             [s1 ... s_n-1]
+	$sigend                    # [P T w vested v q msg t 0]                  []
+	drop swap drop             # [P T w vested v q t]                        []
+	eq verify                  # [P T w vested v]                            []
+	2 roll 2 roll swap sub     # [P T v amt]                                 []
+	split                      # [P T rest payment]                          []
+	amount 0 eq                # [P T rest payment (payment.amount==0)]      []
+	jumpif:$nopayee            # [P T rest payment]                          []
+	2 peek 3 field             # [P T rest payment R]                        []
+	untuple drop               # [P T rest payment rq {r}]                   []
+	'' put '' put              # [P T rest payment rq {r}]                   ['' '']
+	2 roll put put put         # [P T rest]                                  ['' '' payment {r} rq]
+	[%s]                       # [P T rest <multisigprog>]                   ['' '' payment {r} rq]
+	contract call              # [P T rest]                                  []                   [... {"O", <cid>, <outputid>}]
+	jump:$payer
+	$nopayee                   # [P T rest zeroval]                          []
+	drop                       # [P T rest]                                  []
+	$payer                     # [P T rest]                                  []
+	swap drop                  # [P rest]                                    []
+	amount 0 eq                # [P rest (rest.amount==0)]                   []
+	jumpif:$nopayer            # [P rest]                                    []
+	swap untuple drop          # [rest q {p}]                                []
+	'' put '' put              # [rest q {p}]                                ['' '']
+	2 roll put put put         # []                                          ['' '' rest {p} q]
+	[%s]                       # [<multisigprog>]                            ['' '' rest {p} q]
+	contract call              # []                                          []                   [... {"O", <cid>, <outputid>}]
+	jump:$end
+	$nopayer                   # [P zeroval]                                 []
+	drop drop                  # []                                          []
+	$end
+`
+
+var (
+	streamStepSrc = fmt.Sprintf(streamStepSrcFmt, payToMultisigProgSrc2, payToMultisigProgSrc2, payToMultisigProgSrc2)
+
+	// streamStep is the bytecode of the "step" phase of the
+	// standard streaming-payment contract.
+	streamStep = mustAssemble(streamStepSrc)
+
+	streamSrc = fmt.Sprintf(streamSrcFmt, streamStepSrc)
+
+	// StreamProg is the txvm bytecode of the standard
+	// streaming-payment contract. It holds value that vests to a
+	// payee in proportion to the time elapsed over a period, as for
+	// a salary. The payee may withdraw what has vested at any time,
+	// and the payer may cancel, taking back what has not.
+	StreamProg = mustAssemble(streamSrc)
+
+	// StreamSeed is the seed of the standard streaming-payment
+	// contract.
+	StreamSeed = txvm.ContractSeed(StreamProg)
+)
+
+// StreamTerms are the parameters of the standard streaming-payment
+// contract. Total units vest to the payee evenly, second by second,
+// from StartMS to EndMS, which must be at least a second later and
+// less than 96 years later.
+type StreamTerms struct {
+	PayerQuorum  int
+	PayerPubkeys []ed25519.PublicKey
+	PayeeQuorum  int
+	PayeePubkeys []ed25519.PublicKey
+
+	StartMS uint64
+	EndMS   uint64
+	Total   int64
+}
+
+// Vested returns the amount of a stream with terms t that has vested
+// by time atMS. This is the computation the contract performs.
+func (t *StreamTerms) Vested(atMS uint64) int64 {
+	var e int64
+	switch {
+	case atMS <= t.StartMS:
+		e = 0
+	case atMS >= t.EndMS:
+		e = int64(t.EndMS - t.StartMS)
+	default:
+		e = int64(atMS - t.StartMS)
+	}
+	d := int64(t.EndMS-t.StartMS) / 1000
+	e /= 1000
+	return t.Total/d*e + t.Total%d*e/d
+}
+
+func (t *StreamTerms) tuples() (terms, payer txvm.Tuple) {
+	terms = txvm.Tuple{
+		txvm.Int(t.StartMS),
+		txvm.Int(t.EndMS),
+		txvm.Int(t.Total),
+		party(t.PayeeQuorum, t.PayeePubkeys),
+	}
+	return terms, party(t.PayerQuorum, t.PayerPubkeys)
+}
+
+// party returns the {quorum, {p1,...,p_n}} tuple of a set of keys.
+func party(quorum int, pubkeys []ed25519.PublicKey) txvm.Tuple {
+	var pks txvm.Tuple
+	for _, pk := range pubkeys {
+		pks = append(pks, txvm.Bytes(pk))
+	}
+	return txvm.Tuple{txvm.Int(quorum), pks}
+}
+
+// StreamCancelMessage returns the message that payer keys sign to
+// cancel the stream whose value has the given anchor, as of time
+// atMS, which must be no earlier than the cancelling transaction.
+// After each withdrawal the value's anchor is VMHash("Split1",
+// anchor).
+func StreamCancelMessage(anchor []byte, atMS uint64) []byte {
+	enc := txvm.Encode(txvm.Tuple{
+		txvm.Bytes(anchor),
+		txvm.Int(atMS),
+	})
+	h := sha3.Sum256(append([]byte("StreamCancel"), enc...))
+	return h[:]
+}
+
+// LockStream writes txvm bytecode to b that locks a value in the
+// standard streaming-payment contract with the given terms. The
+// caller must first put the refdata and then the value on the
+// argument stack. The value should be t.Total units, though the
+// contract does not require it.
+func LockStream(b *txvmutil.Builder, t *StreamTerms) {
+	terms, payer := t.tuples()
+	pushTuple(b, terms)
+	b.Op(op.Put) // {terms} put
+	pushTuple(b, payer)
+	b.Op(op.Put)                // {payer} put
+	b.PushdataBytes(StreamProg) // [<stream program>]
+	b.Op(op.Contract).Op(op.Call)
+}
+
+// WithdrawStream writes txvm bytecode to b that withdraws what has
+// vested by atMS from a value locked in the standard
+// streaming-payment contract, of which withdrawn units have already
+// been withdrawn. The transaction's minimum time must be at least
+// atMS.
+func WithdrawStream(b *txvmutil.Builder, t *StreamTerms, amount int64, assetID bc.Hash, anchor []byte, withdrawn int64, atMS uint64) {
+	b.PushdataUint64(atMS).Op(op.Put) // <atMS> put
+	b.PushdataInt64(0).Op(op.Put)     // 0 put
+	pushTuple(b, streamSnapshot(t, amount, assetID, anchor, withdrawn))
+	b.Op(op.Input).Op(op.Call)
+}
+
+// CancelStream writes txvm bytecode to b that cancels a stream as of
+// atMS, paying what has vested by then and not been withdrawn to the
+// payee and the rest to the payer. Sigs are the payer keys'
+// signatures of the StreamCancelMessage, parallel to t.PayerPubkeys;
+// a key that did not sign has an empty signature. The transaction's
+// maximum time must not be later than atMS.
+func CancelStream(b *txvmutil.Builder, t *StreamTerms, amount int64, assetID bc.Hash, anchor []byte, withdrawn int64, atMS uint64, sigs [][]byte) {
+	for _, sig := range sigs {
+		b.PushdataBytes(sig).Op(op.Put) // x'<sig>' put
+	}
+	b.PushdataUint64(atMS).Op(op.Put) // <atMS> put
+	b.PushdataInt64(1).Op(op.Put)     // 1 put
+	pushTuple(b, streamSnapshot(t, amount, assetID, anchor, withdrawn))
+	b.Op(op.Input).Op(op.Call)
+}
+
+// StreamOutputID returns the ID of the output that WithdrawStream
+// and CancelStream spend with the same arguments.
+func StreamOutputID(t *StreamTerms, amount int64, assetID bc.Hash, anchor []byte, withdrawn int64) bc.Hash {
+	snapshot := streamSnapshot(t, amount, assetID, anchor, withdrawn)
+	return bc.NewHash(txvm.VMHash("SnapshotID", txvm.Encode(snapshot)))
+}
+
+func streamSnapshot(t *StreamTerms, amount int64, assetID bc.Hash, anchor []byte, withdrawn int64) txvm.Tuple {
+	terms, payer := t.tuples()
+	return txvm.Tuple{
+		txvm.Bytes{txvm.ContractCode},
+		txvm.Bytes(StreamSeed[:]),
+		txvm.Bytes(streamStep),
+		txvm.Tuple{txvm.Bytes{txvm.TupleCode}, payer},
+		txvm.Tuple{txvm.Bytes{txvm.TupleCode}, terms},
+		txvm.Tuple{txvm.Bytes{txvm.ValueCode}, txvm.Int(amount), txvm.Bytes(assetID.Bytes()), txvm.Bytes(anchor)},
+		txvm.Tuple{txvm.Bytes{txvm.IntCode}, txvm.Int(withdrawn)},
+	}
+}
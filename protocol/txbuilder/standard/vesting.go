@@ -0,0 +1,239 @@
+package standard
+
+import (
+	"fmt"
+	"math/bits"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+	"i10r.io/protocol/txvm/txvmutil"
+)
+
+// vestingSrcFmt expects the argument stack
+// [... refdata value beneficiary unlock], where beneficiary is
+// {quorum, {p1,...,p_n}}. It locks value until unlock.
+const vestingSrcFmt = `
+	               # Contract stack             Argument stack            Log
+	               # []                         [refdata v B unlock]      []
+	get get get    # [unlock B v]               [refdata]                 []
+	get log        # [unlock B v]               []                        [{"L", <cid>, refdata}]
+	[%s]           # [unlock B v <step>]        []                        [{"L", <cid>, refdata}]
+	output         # [unlock B v]               []                        [{"L", <cid>, refdata} {"O", <caller>, <outputid>}]
+`
+
+// vestingStepSrcFmt expects the contract stack
+// [... unlock {q, {p1,...,p_n}} v]. It requires that the transaction
+// be no earlier than unlock and pays v to the standard
+// pay-to-multisig contract for the beneficiary. It needs no
+// signatures, since only the beneficiary can be paid.
+const vestingStepSrcFmt = `
+	                           # Contract stack                 Argument stack         Log
+	                           # [unlock B v]                   []                     []
+	2 roll 0 timerange         # [B v]                          []                     [{"R", <cid>, unlock, 0}]
+	swap untuple drop          # [v q {p}]                      []                     [{"R", <cid>, unlock, 0}]
+	'' put '' put              # [v q {p}]                      ['' '']                [{"R", <cid>, unlock, 0}]
+	2 roll put put put         # []                             ['' '' v {p} q]        [{"R", <cid>, unlock, 0}]
+	[%s]                       # [<multisigprog>]               ['' '' v {p} q]        [{"R", <cid>, unlock, 0}]
+	contract call              # []                             []                     [... {"O", <cid>, <outputid>}]
+`
+
+var (
+	vestingStepSrc = fmt.Sprintf(vestingStepSrcFmt, payToMultisigProgSrc2)
+
+	// vestingStep is the bytecode of the "step" phase of the
+	// standard vesting contract.
+	vestingStep = mustAssemble(vestingStepSrc)
+
+	vestingSrc = fmt.Sprintf(vestingSrcFmt, vestingStepSrc)
+
+	// VestingProg is the txvm bytecode of the standard vesting
+	// contract. It locks one tranche of a vesting schedule until
+	// the tranche's unlock time, after which anyone may pay it to
+	// the beneficiary.
+	VestingProg = mustAssemble(vestingSrc)
+
+	// VestingSeed is the seed of the standard vesting contract.
+	VestingSeed = txvm.ContractSeed(VestingProg)
+)
+
+// ErrVestingSchedule is returned by LinearVesting for parameters
+// that do not describe a schedule.
+var ErrVestingSchedule = errors.New("invalid vesting schedule")
+
+// VestingTranche is an amount that vests at a time.
+type VestingTranche struct {
+	UnlockMS uint64
+	Amount   int64
+}
+
+// VestingSchedule is a beneficiary and the tranches that vest to
+// it. FundVesting locks each tranche in its own output of the
+// standard vesting contract.
+type VestingSchedule struct {
+	Quorum   int
+	Pubkeys  []ed25519.PublicKey
+	Tranches []VestingTranche
+}
+
+// Total returns the sum of the amounts of s's tranches.
+func (s *VestingSchedule) Total() int64 {
+	var total int64
+	for _, tr := range s.Tranches {
+		total += tr.Amount
+	}
+	return total
+}
+
+// Claimable returns the indexes of the tranches of s that have
+// unlocked by atMS.
+func (s *VestingSchedule) Claimable(atMS uint64) []int {
+	var is []int
+	for i, tr := range s.Tranches {
+		if tr.UnlockMS <= atMS {
+			is = append(is, i)
+		}
+	}
+	return is
+}
+
+// LinearVesting returns tranches in which total vests evenly from
+// startMS to endMS, one tranche every periodMS and a last one at
+// endMS, except that nothing unlocks before cliffMS: everything
+// that has vested by then unlocks at cliffMS. Tranches that would
+// be empty are omitted.
+func LinearVesting(total int64, startMS, cliffMS, endMS, periodMS uint64) ([]VestingTranche, error) {
+	if total <= 0 {
+		return nil, errors.WithDetailf(ErrVestingSchedule, "total %d", total)
+	}
+	if startMS >= endMS || cliffMS < startMS || cliffMS > endMS {
+		return nil, errors.WithDetailf(ErrVestingSchedule, "start %d, cliff %d, end %d", startMS, cliffMS, endMS)
+	}
+	if periodMS == 0 {
+		return nil, errors.WithDetail(ErrVestingSchedule, "zero period")
+	}
+	d := endMS - startMS
+	vested := func(t uint64) int64 {
+		// total*(t-start)/d, which is at most total.
+		hi, lo := bits.Mul64(uint64(total), t-startMS)
+		q, _ := bits.Div64(hi, lo, d)
+		return int64(q)
+	}
+	var (
+		tranches []VestingTranche
+		prev     int64
+	)
+	add := func(t uint64) {
+		v := vested(t)
+		if v > prev {
+			tranches = append(tranches, VestingTranche{UnlockMS: t, Amount: v - prev})
+			prev = v
+		}
+	}
+	add(cliffMS)
+	for k := (cliffMS-startMS)/periodMS + 1; k*periodMS < d; k++ {
+		add(startMS + k*periodMS)
+	}
+	add(endMS)
+	return tranches, nil
+}
+
+// FundVesting writes txvm bytecode to b that splits a value into the
+// tranches of s and locks each in the standard vesting contract with
+// the given refdata. The caller must first put the value, which must
+// be s.Total() units, on the argument stack.
+func FundVesting(b *txvmutil.Builder, s *VestingSchedule, refdata []byte) {
+	beneficiary := party(s.Quorum, s.Pubkeys)
+	for i, tr := range s.Tranches {
+		b.Op(op.Get) // get
+		if i < len(s.Tranches)-1 {
+			b.PushdataInt64(tr.Amount).Op(op.Split) // <amount> split
+		}
+		b.PushdataBytes(refdata).Op(op.Put) // '<refdata>' put
+		b.Op(op.Put)                        // put
+		pushTuple(b, beneficiary)
+		b.Op(op.Put)                             // {beneficiary} put
+		b.PushdataUint64(tr.UnlockMS).Op(op.Put) // <unlock> put
+		b.PushdataBytes(VestingProg)             // [<vesting program>]
+		b.Op(op.Contract).Op(op.Call)
+		if i < len(s.Tranches)-1 {
+			b.Op(op.Put) // put
+		}
+	}
+}
+
+// ClaimVesting writes txvm bytecode to b that pays the tranches of s
+// with the given indexes to the beneficiary, from the outputs that
+// FundVesting created from a value with the given asset ID and
+// anchor. The transaction's minimum time must be no earlier than
+// the latest of their unlock times.
+func ClaimVesting(b *txvmutil.Builder, s *VestingSchedule, assetID bc.Hash, anchor []byte, is ...int) {
+	anchors := vestingAnchors(s, anchor)
+	for _, i := range is {
+		pushTuple(b, vestingSnapshot(s, i, assetID, anchors[i]))
+		b.Op(op.Input).Op(op.Call)
+	}
+}
+
+// VestingClaimTx returns a complete transaction that claims the
+// tranches of s with the given indexes, as ClaimVesting does. Since
+// claiming needs no signatures, the beneficiary or anyone else can
+// submit it. Its anchor comes from a nonce with the given blockchain
+// ID and expiration time, which must be no earlier than the latest
+// of the tranches' unlock times. The transaction has the given
+// version; from txvm.NetworkVersion on, its ID commits to the
+// blockchain, whose ID must then be a 32-byte hash.
+func VestingClaimTx(s *VestingSchedule, assetID bc.Hash, anchor []byte, is []int, blockchainID []byte, expMS uint64, version, runlimit int64) (*bc.Tx, error) {
+	var opts []txvm.Option
+	if version >= txvm.NetworkVersion {
+		if len(blockchainID) != 32 {
+			return nil, fmt.Errorf("blockchain ID of %d bytes for a version %d transaction", len(blockchainID), version)
+		}
+		opts = append(opts, bc.NetworkOption(bc.HashFromBytes(blockchainID)))
+	}
+	var b txvmutil.Builder
+	ClaimVesting(&b, s, assetID, anchor, is...)
+	b.PushdataBytes(blockchainID).PushdataUint64(expMS)
+	b.Op(op.Nonce).Op(op.Finalize)
+	return bc.NewTx(b.Build(), version, runlimit, opts...)
+}
+
+// VestingOutputID returns the ID of the output holding tranche i of
+// s that FundVesting created from a value with the given asset ID
+// and anchor.
+func VestingOutputID(s *VestingSchedule, i int, assetID bc.Hash, anchor []byte) bc.Hash {
+	snapshot := vestingSnapshot(s, i, assetID, vestingAnchors(s, anchor)[i])
+	return bc.NewHash(txvm.VMHash("SnapshotID", txvm.Encode(snapshot)))
+}
+
+// vestingAnchors returns the anchors of the tranches FundVesting
+// splits from a value with the given anchor. Each split but the
+// last gives the tranche VMHash("Split2", a) and leaves
+// VMHash("Split1", a) for the rest.
+func vestingAnchors(s *VestingSchedule, anchor []byte) [][]byte {
+	anchors := make([][]byte, len(s.Tranches))
+	for i := range s.Tranches {
+		if i == len(s.Tranches)-1 {
+			anchors[i] = anchor
+			break
+		}
+		a2 := txvm.VMHash("Split2", anchor)
+		a1 := txvm.VMHash("Split1", anchor)
+		anchors[i], anchor = a2[:], a1[:]
+	}
+	return anchors
+}
+
+func vestingSnapshot(s *VestingSchedule, i int, assetID bc.Hash, anchor []byte) txvm.Tuple {
+	tr := s.Tranches[i]
+	return txvm.Tuple{
+		txvm.Bytes{txvm.ContractCode},
+		txvm.Bytes(VestingSeed[:]),
+		txvm.Bytes(vestingStep),
+		txvm.Tuple{txvm.Bytes{txvm.IntCode}, txvm.Int(tr.UnlockMS)},
+		txvm.Tuple{txvm.Bytes{txvm.TupleCode}, party(s.Quorum, s.Pubkeys)},
+		txvm.Tuple{txvm.Bytes{txvm.ValueCode}, txvm.Int(tr.Amount), txvm.Bytes(assetID.Bytes()), txvm.Bytes(anchor)},
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"i10r.io/errors"
 	"i10r.io/math/checked"
 	"i10r.io/protocol/bc"
+	"i10r.io/protocol/fee"
 	"i10r.io/protocol/txbuilder/standard"
 	"i10r.io/protocol/txvm"
 	"i10r.io/protocol/txvm/op"
@@ -55,6 +56,7 @@ type Template struct {
 	callbacks []func() error
 
 	legacyOutputs bool
+	network       *bc.Hash
 	index         uint64
 }
 
@@ -65,6 +67,16 @@ func (t *Template) SetOutputV1() {
 	t.legacyOutputs = true
 }
 
+// SetNetwork causes Template.Tx to produce a transaction of version
+// txvm.NetworkVersion bound to the blockchain with the given initial
+// block ID, for inclusion in blocks of version bc.NetworkVersion or
+// later. The default is a version 3 transaction. The setting is not
+// part of the template's Raw form.
+func (t *Template) SetNetwork(initialBlockID bc.Hash) {
+	t.network = &initialBlockID
+	t.materialization = nil
+}
+
 // Issuance contains information needed to add an issuance to a
 // transaction. It is added to a template with AddIssuance.
 type Issuance struct {
@@ -290,6 +302,12 @@ func (tpl *Template) AddRetirement(amount int64, assetID bc.Hash, refData []byte
 	return ret
 }
 
+// AddFee adds a Retirement to the template that pays amount of
+// assetID as a transaction fee (see package fee).
+func (tpl *Template) AddFee(amount int64, assetID bc.Hash) *Retirement {
+	return tpl.AddRetirement(amount, assetID, fee.Marker)
+}
+
 // SignFunc is the type of a callback that can generate a signature
 // for a given message and a given keyID and derivation path. If the
 // function does not recognize the keyID, it should return (nil, nil),
@@ -592,7 +610,13 @@ func (tpl *Template) materializeTx() (*materialization, error) {
 	}
 
 	// Run the finalized but not-yet-signed tx to get the txid
-	tx, err := bc.NewTx(b.Build(), 3, math.MaxInt64, txvm.Resumer(&m.resumer), txvm.GetRunlimit(&m.runlimit))
+	version := int64(3)
+	opts := []txvm.Option{txvm.Resumer(&m.resumer), txvm.GetRunlimit(&m.runlimit)}
+	if tpl.network != nil {
+		version = txvm.NetworkVersion
+		opts = append(opts, bc.NetworkOption(*tpl.network))
+	}
+	tx, err := bc.NewTx(b.Build(), version, math.MaxInt64, opts...)
 	if err != nil {
 		return nil, errors.Wrap(err, "computing transaction ID")
 	}
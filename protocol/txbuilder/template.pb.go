@@ -0,0 +1,633 @@
+// Code generated by protoc-gen-go.
+// source: template.proto
+// DO NOT EDIT!
+
+/*
+Package txbuilder is a generated protocol buffer package.
+
+It is generated from these files:
+	template.proto
+
+It has these top-level messages:
+	RawTemplate
+	RawIssuance
+	RawInput
+	RawOutput
+	RawRetirement
+	SigningInstruction
+	Signature
+	SignRequest
+	SignResponse
+*/
+package txbuilder
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+import bc "i10r.io/protocol/bc"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+// RawTemplate is the interchange form of a Template: a partially
+// built, possibly partially signed transaction.
+type RawTemplate struct {
+	Issuances       []*RawIssuance   `protobuf:"bytes,1,rep,name=issuances" json:"issuances,omitempty"`
+	Inputs          []*RawInput      `protobuf:"bytes,2,rep,name=inputs" json:"inputs,omitempty"`
+	Outputs         []*RawOutput     `protobuf:"bytes,3,rep,name=outputs" json:"outputs,omitempty"`
+	Retirements     []*RawRetirement `protobuf:"bytes,4,rep,name=retirements" json:"retirements,omitempty"`
+	TransactionTags []byte           `protobuf:"bytes,5,opt,name=transaction_tags,json=transactionTags,proto3" json:"transaction_tags,omitempty"`
+	MinTimeMs       uint64           `protobuf:"varint,6,opt,name=min_time_ms,json=minTimeMs" json:"min_time_ms,omitempty"`
+	MaxTimeMs       uint64           `protobuf:"varint,7,opt,name=max_time_ms,json=maxTimeMs" json:"max_time_ms,omitempty"`
+}
+
+func (m *RawTemplate) Reset()                    { *m = RawTemplate{} }
+func (m *RawTemplate) String() string            { return proto.CompactTextString(m) }
+func (*RawTemplate) ProtoMessage()               {}
+func (*RawTemplate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+func (m *RawTemplate) GetIssuances() []*RawIssuance {
+	if m != nil {
+		return m.Issuances
+	}
+	return nil
+}
+
+func (m *RawTemplate) GetInputs() []*RawInput {
+	if m != nil {
+		return m.Inputs
+	}
+	return nil
+}
+
+func (m *RawTemplate) GetOutputs() []*RawOutput {
+	if m != nil {
+		return m.Outputs
+	}
+	return nil
+}
+
+func (m *RawTemplate) GetRetirements() []*RawRetirement {
+	if m != nil {
+		return m.Retirements
+	}
+	return nil
+}
+
+func (m *RawTemplate) GetTransactionTags() []byte {
+	if m != nil {
+		return m.TransactionTags
+	}
+	return nil
+}
+
+func (m *RawTemplate) GetMinTimeMs() uint64 {
+	if m != nil {
+		return m.MinTimeMs
+	}
+	return 0
+}
+
+func (m *RawTemplate) GetMaxTimeMs() uint64 {
+	if m != nil {
+		return m.MaxTimeMs
+	}
+	return 0
+}
+
+// RawIssuance is the interchange form of an Issuance.
+type RawIssuance struct {
+	ContractVersion int64    `protobuf:"varint,1,opt,name=contract_version,json=contractVersion" json:"contract_version,omitempty"`
+	BlockchainId    []byte   `protobuf:"bytes,2,opt,name=blockchain_id,json=blockchainId,proto3" json:"blockchain_id,omitempty"`
+	Quorum          int64    `protobuf:"varint,3,opt,name=quorum" json:"quorum,omitempty"`
+	KeyHashes       [][]byte `protobuf:"bytes,4,rep,name=key_hashes,json=keyHashes,proto3" json:"key_hashes,omitempty"`
+	DerivationPath  [][]byte `protobuf:"bytes,5,rep,name=derivation_path,json=derivationPath,proto3" json:"derivation_path,omitempty"`
+	Pubkeys         [][]byte `protobuf:"bytes,6,rep,name=pubkeys,proto3" json:"pubkeys,omitempty"`
+	Amount          int64    `protobuf:"varint,7,opt,name=amount" json:"amount,omitempty"`
+	AssetTag        []byte   `protobuf:"bytes,8,opt,name=asset_tag,json=assetTag,proto3" json:"asset_tag,omitempty"`
+	Signatures      [][]byte `protobuf:"bytes,9,rep,name=signatures,proto3" json:"signatures,omitempty"`
+	ReferenceData   []byte   `protobuf:"bytes,10,opt,name=reference_data,json=referenceData,proto3" json:"reference_data,omitempty"`
+	Index           uint64   `protobuf:"varint,11,opt,name=index" json:"index,omitempty"`
+	Nonce           []byte   `protobuf:"bytes,12,opt,name=nonce,proto3" json:"nonce,omitempty"`
+}
+
+func (m *RawIssuance) Reset()                    { *m = RawIssuance{} }
+func (m *RawIssuance) String() string            { return proto.CompactTextString(m) }
+func (*RawIssuance) ProtoMessage()               {}
+func (*RawIssuance) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+func (m *RawIssuance) GetContractVersion() int64 {
+	if m != nil {
+		return m.ContractVersion
+	}
+	return 0
+}
+
+func (m *RawIssuance) GetBlockchainId() []byte {
+	if m != nil {
+		return m.BlockchainId
+	}
+	return nil
+}
+
+func (m *RawIssuance) GetQuorum() int64 {
+	if m != nil {
+		return m.Quorum
+	}
+	return 0
+}
+
+func (m *RawIssuance) GetKeyHashes() [][]byte {
+	if m != nil {
+		return m.KeyHashes
+	}
+	return nil
+}
+
+func (m *RawIssuance) GetDerivationPath() [][]byte {
+	if m != nil {
+		return m.DerivationPath
+	}
+	return nil
+}
+
+func (m *RawIssuance) GetPubkeys() [][]byte {
+	if m != nil {
+		return m.Pubkeys
+	}
+	return nil
+}
+
+func (m *RawIssuance) GetAmount() int64 {
+	if m != nil {
+		return m.Amount
+	}
+	return 0
+}
+
+func (m *RawIssuance) GetAssetTag() []byte {
+	if m != nil {
+		return m.AssetTag
+	}
+	return nil
+}
+
+func (m *RawIssuance) GetSignatures() [][]byte {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+func (m *RawIssuance) GetReferenceData() []byte {
+	if m != nil {
+		return m.ReferenceData
+	}
+	return nil
+}
+
+func (m *RawIssuance) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *RawIssuance) GetNonce() []byte {
+	if m != nil {
+		return m.Nonce
+	}
+	return nil
+}
+
+// RawInput is the interchange form of an Input.
+type RawInput struct {
+	Quorum         int64    `protobuf:"varint,1,opt,name=quorum" json:"quorum,omitempty"`
+	KeyHashes      [][]byte `protobuf:"bytes,2,rep,name=key_hashes,json=keyHashes,proto3" json:"key_hashes,omitempty"`
+	DerivationPath [][]byte `protobuf:"bytes,3,rep,name=derivation_path,json=derivationPath,proto3" json:"derivation_path,omitempty"`
+	Pubkeys        [][]byte `protobuf:"bytes,4,rep,name=pubkeys,proto3" json:"pubkeys,omitempty"`
+	Amount         int64    `protobuf:"varint,5,opt,name=amount" json:"amount,omitempty"`
+	AssetId        *bc.Hash `protobuf:"bytes,6,opt,name=asset_id,json=assetId" json:"asset_id,omitempty"`
+	Anchor         []byte   `protobuf:"bytes,7,opt,name=anchor,proto3" json:"anchor,omitempty"`
+	Signatures     [][]byte `protobuf:"bytes,8,rep,name=signatures,proto3" json:"signatures,omitempty"`
+	ReferenceData  []byte   `protobuf:"bytes,9,opt,name=reference_data,json=referenceData,proto3" json:"reference_data,omitempty"`
+	Index          uint64   `protobuf:"varint,10,opt,name=index" json:"index,omitempty"`
+	// SpendsOutput is true for an input that spends one of the
+	// template's own outputs, given by OutputIndex.
+	SpendsOutput  bool  `protobuf:"varint,11,opt,name=spends_output,json=spendsOutput" json:"spends_output,omitempty"`
+	OutputIndex   int64 `protobuf:"varint,12,opt,name=output_index,json=outputIndex" json:"output_index,omitempty"`
+	OutputVersion int64 `protobuf:"varint,13,opt,name=output_version,json=outputVersion" json:"output_version,omitempty"`
+}
+
+func (m *RawInput) Reset()                    { *m = RawInput{} }
+func (m *RawInput) String() string            { return proto.CompactTextString(m) }
+func (*RawInput) ProtoMessage()               {}
+func (*RawInput) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *RawInput) GetQuorum() int64 {
+	if m != nil {
+		return m.Quorum
+	}
+	return 0
+}
+
+func (m *RawInput) GetKeyHashes() [][]byte {
+	if m != nil {
+		return m.KeyHashes
+	}
+	return nil
+}
+
+func (m *RawInput) GetDerivationPath() [][]byte {
+	if m != nil {
+		return m.DerivationPath
+	}
+	return nil
+}
+
+func (m *RawInput) GetPubkeys() [][]byte {
+	if m != nil {
+		return m.Pubkeys
+	}
+	return nil
+}
+
+func (m *RawInput) GetAmount() int64 {
+	if m != nil {
+		return m.Amount
+	}
+	return 0
+}
+
+func (m *RawInput) GetAssetId() *bc.Hash {
+	if m != nil {
+		return m.AssetId
+	}
+	return nil
+}
+
+func (m *RawInput) GetAnchor() []byte {
+	if m != nil {
+		return m.Anchor
+	}
+	return nil
+}
+
+func (m *RawInput) GetSignatures() [][]byte {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+func (m *RawInput) GetReferenceData() []byte {
+	if m != nil {
+		return m.ReferenceData
+	}
+	return nil
+}
+
+func (m *RawInput) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+func (m *RawInput) GetSpendsOutput() bool {
+	if m != nil {
+		return m.SpendsOutput
+	}
+	return false
+}
+
+func (m *RawInput) GetOutputIndex() int64 {
+	if m != nil {
+		return m.OutputIndex
+	}
+	return 0
+}
+
+func (m *RawInput) GetOutputVersion() int64 {
+	if m != nil {
+		return m.OutputVersion
+	}
+	return 0
+}
+
+// RawOutput is the interchange form of an Output.
+type RawOutput struct {
+	Quorum        int64    `protobuf:"varint,1,opt,name=quorum" json:"quorum,omitempty"`
+	Pubkeys       [][]byte `protobuf:"bytes,2,rep,name=pubkeys,proto3" json:"pubkeys,omitempty"`
+	Amount        int64    `protobuf:"varint,3,opt,name=amount" json:"amount,omitempty"`
+	AssetId       *bc.Hash `protobuf:"bytes,4,opt,name=asset_id,json=assetId" json:"asset_id,omitempty"`
+	ReferenceData []byte   `protobuf:"bytes,5,opt,name=reference_data,json=referenceData,proto3" json:"reference_data,omitempty"`
+	TokenTags     []byte   `protobuf:"bytes,6,opt,name=token_tags,json=tokenTags,proto3" json:"token_tags,omitempty"`
+	Index         uint64   `protobuf:"varint,7,opt,name=index" json:"index,omitempty"`
+}
+
+func (m *RawOutput) Reset()                    { *m = RawOutput{} }
+func (m *RawOutput) String() string            { return proto.CompactTextString(m) }
+func (*RawOutput) ProtoMessage()               {}
+func (*RawOutput) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *RawOutput) GetQuorum() int64 {
+	if m != nil {
+		return m.Quorum
+	}
+	return 0
+}
+
+func (m *RawOutput) GetPubkeys() [][]byte {
+	if m != nil {
+		return m.Pubkeys
+	}
+	return nil
+}
+
+func (m *RawOutput) GetAmount() int64 {
+	if m != nil {
+		return m.Amount
+	}
+	return 0
+}
+
+func (m *RawOutput) GetAssetId() *bc.Hash {
+	if m != nil {
+		return m.AssetId
+	}
+	return nil
+}
+
+func (m *RawOutput) GetReferenceData() []byte {
+	if m != nil {
+		return m.ReferenceData
+	}
+	return nil
+}
+
+func (m *RawOutput) GetTokenTags() []byte {
+	if m != nil {
+		return m.TokenTags
+	}
+	return nil
+}
+
+func (m *RawOutput) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+// RawRetirement is the interchange form of a Retirement.
+type RawRetirement struct {
+	Amount        int64    `protobuf:"varint,1,opt,name=amount" json:"amount,omitempty"`
+	AssetId       *bc.Hash `protobuf:"bytes,2,opt,name=asset_id,json=assetId" json:"asset_id,omitempty"`
+	ReferenceData []byte   `protobuf:"bytes,3,opt,name=reference_data,json=referenceData,proto3" json:"reference_data,omitempty"`
+	Index         uint64   `protobuf:"varint,4,opt,name=index" json:"index,omitempty"`
+}
+
+func (m *RawRetirement) Reset()                    { *m = RawRetirement{} }
+func (m *RawRetirement) String() string            { return proto.CompactTextString(m) }
+func (*RawRetirement) ProtoMessage()               {}
+func (*RawRetirement) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *RawRetirement) GetAmount() int64 {
+	if m != nil {
+		return m.Amount
+	}
+	return 0
+}
+
+func (m *RawRetirement) GetAssetId() *bc.Hash {
+	if m != nil {
+		return m.AssetId
+	}
+	return nil
+}
+
+func (m *RawRetirement) GetReferenceData() []byte {
+	if m != nil {
+		return m.ReferenceData
+	}
+	return nil
+}
+
+func (m *RawRetirement) GetIndex() uint64 {
+	if m != nil {
+		return m.Index
+	}
+	return 0
+}
+
+// SigningInstruction asks for signatures on one issuance or input of
+// a template.
+type SigningInstruction struct {
+	// EntryIndex is the Index of the issuance or input.
+	EntryIndex uint64 `protobuf:"varint,1,opt,name=entry_index,json=entryIndex" json:"entry_index,omitempty"`
+	// Message is the message to sign.
+	Message []byte `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// Quorum is the number of signatures still needed.
+	Quorum int64 `protobuf:"varint,3,opt,name=quorum" json:"quorum,omitempty"`
+	// KeyHashes identify the keys that may sign, in order. An empty
+	// entry is a key that has already signed.
+	KeyHashes      [][]byte `protobuf:"bytes,4,rep,name=key_hashes,json=keyHashes,proto3" json:"key_hashes,omitempty"`
+	DerivationPath [][]byte `protobuf:"bytes,5,rep,name=derivation_path,json=derivationPath,proto3" json:"derivation_path,omitempty"`
+}
+
+func (m *SigningInstruction) Reset()                    { *m = SigningInstruction{} }
+func (m *SigningInstruction) String() string            { return proto.CompactTextString(m) }
+func (*SigningInstruction) ProtoMessage()               {}
+func (*SigningInstruction) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *SigningInstruction) GetEntryIndex() uint64 {
+	if m != nil {
+		return m.EntryIndex
+	}
+	return 0
+}
+
+func (m *SigningInstruction) GetMessage() []byte {
+	if m != nil {
+		return m.Message
+	}
+	return nil
+}
+
+func (m *SigningInstruction) GetQuorum() int64 {
+	if m != nil {
+		return m.Quorum
+	}
+	return 0
+}
+
+func (m *SigningInstruction) GetKeyHashes() [][]byte {
+	if m != nil {
+		return m.KeyHashes
+	}
+	return nil
+}
+
+func (m *SigningInstruction) GetDerivationPath() [][]byte {
+	if m != nil {
+		return m.DerivationPath
+	}
+	return nil
+}
+
+// Signature is one signature in answer to a SigningInstruction.
+type Signature struct {
+	EntryIndex uint64 `protobuf:"varint,1,opt,name=entry_index,json=entryIndex" json:"entry_index,omitempty"`
+	// KeyIndex is the position of the signing key in the
+	// instruction's KeyHashes.
+	KeyIndex  uint64 `protobuf:"varint,2,opt,name=key_index,json=keyIndex" json:"key_index,omitempty"`
+	Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *Signature) Reset()                    { *m = Signature{} }
+func (m *Signature) String() string            { return proto.CompactTextString(m) }
+func (*Signature) ProtoMessage()               {}
+func (*Signature) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *Signature) GetEntryIndex() uint64 {
+	if m != nil {
+		return m.EntryIndex
+	}
+	return 0
+}
+
+func (m *Signature) GetKeyIndex() uint64 {
+	if m != nil {
+		return m.KeyIndex
+	}
+	return 0
+}
+
+func (m *Signature) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+// SignRequest asks a signer to sign a template.
+type SignRequest struct {
+	Template     *RawTemplate          `protobuf:"bytes,1,opt,name=template" json:"template,omitempty"`
+	Instructions []*SigningInstruction `protobuf:"bytes,2,rep,name=instructions" json:"instructions,omitempty"`
+}
+
+func (m *SignRequest) Reset()                    { *m = SignRequest{} }
+func (m *SignRequest) String() string            { return proto.CompactTextString(m) }
+func (*SignRequest) ProtoMessage()               {}
+func (*SignRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *SignRequest) GetTemplate() *RawTemplate {
+	if m != nil {
+		return m.Template
+	}
+	return nil
+}
+
+func (m *SignRequest) GetInstructions() []*SigningInstruction {
+	if m != nil {
+		return m.Instructions
+	}
+	return nil
+}
+
+// SignResponse carries a signer's signatures. A signer that does not
+// hold a requested key omits its signature.
+type SignResponse struct {
+	Signatures []*Signature `protobuf:"bytes,1,rep,name=signatures" json:"signatures,omitempty"`
+}
+
+func (m *SignResponse) Reset()                    { *m = SignResponse{} }
+func (m *SignResponse) String() string            { return proto.CompactTextString(m) }
+func (*SignResponse) ProtoMessage()               {}
+func (*SignResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *SignResponse) GetSignatures() []*Signature {
+	if m != nil {
+		return m.Signatures
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*RawTemplate)(nil), "chain.protocol.txbuilder.RawTemplate")
+	proto.RegisterType((*RawIssuance)(nil), "chain.protocol.txbuilder.RawIssuance")
+	proto.RegisterType((*RawInput)(nil), "chain.protocol.txbuilder.RawInput")
+	proto.RegisterType((*RawOutput)(nil), "chain.protocol.txbuilder.RawOutput")
+	proto.RegisterType((*RawRetirement)(nil), "chain.protocol.txbuilder.RawRetirement")
+	proto.RegisterType((*SigningInstruction)(nil), "chain.protocol.txbuilder.SigningInstruction")
+	proto.RegisterType((*Signature)(nil), "chain.protocol.txbuilder.Signature")
+	proto.RegisterType((*SignRequest)(nil), "chain.protocol.txbuilder.SignRequest")
+	proto.RegisterType((*SignResponse)(nil), "chain.protocol.txbuilder.SignResponse")
+}
+
+func init() { proto.RegisterFile("template.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 826 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb5, 0x95, 0x4b, 0x6f, 0xd3, 0x40,
+	0x10, 0xc7, 0xe5, 0xbc, 0x3d, 0x76, 0x5a, 0xb4, 0x42, 0xc8, 0x2a, 0xe5, 0xe5, 0xaa, 0xb4, 0x48,
+	0x28, 0x85, 0x72, 0x43, 0xe2, 0xc0, 0xe3, 0x40, 0x0e, 0x88, 0xca, 0x2d, 0x1c, 0xb8, 0x58, 0x1b,
+	0x67, 0x49, 0xac, 0xc6, 0x76, 0xea, 0x5d, 0x97, 0xf4, 0xcc, 0x89, 0xaf, 0x81, 0xc4, 0x99, 0x6f,
+	0xc3, 0xd7, 0x81, 0xd9, 0x5d, 0xdb, 0x71, 0x29, 0x4e, 0x7a, 0x41, 0xca, 0x21, 0xfb, 0x9f, 0xc7,
+	0x8e, 0x7f, 0x33, 0xbb, 0x0b, 0x1b, 0x82, 0x45, 0xf3, 0x19, 0x15, 0x6c, 0x30, 0x4f, 0x13, 0x91,
+	0x10, 0x27, 0x98, 0xd2, 0x30, 0xd6, 0x8b, 0x20, 0x99, 0x0d, 0xc4, 0x62, 0x94, 0x85, 0xb3, 0x31,
+	0x4b, 0xb7, 0xb6, 0xc3, 0xa7, 0x4f, 0xd2, 0x41, 0x98, 0x1c, 0x14, 0xb6, 0x83, 0x51, 0x80, 0x3f,
+	0xed, 0xea, 0x7e, 0x6d, 0x82, 0xe5, 0xd1, 0x2f, 0x27, 0x79, 0x36, 0xf2, 0x1a, 0xcc, 0x90, 0xf3,
+	0x8c, 0xc6, 0x01, 0xe3, 0x8e, 0x71, 0xbf, 0xb9, 0x6f, 0x1d, 0xee, 0x0e, 0xea, 0x72, 0x0f, 0x30,
+	0x72, 0x98, 0x7b, 0x7b, 0xcb, 0x38, 0xf2, 0x1c, 0x3a, 0x61, 0x3c, 0xcf, 0x04, 0x77, 0x1a, 0x2a,
+	0x83, 0xbb, 0x3a, 0x83, 0x74, 0xf5, 0xf2, 0x08, 0xf2, 0x02, 0xba, 0x49, 0x26, 0x54, 0x70, 0x53,
+	0x05, 0xef, 0xac, 0x0c, 0x7e, 0xaf, 0x7c, 0xbd, 0x22, 0x86, 0x0c, 0xc1, 0x4a, 0x99, 0x08, 0x53,
+	0x16, 0xb1, 0x18, 0x53, 0xb4, 0x54, 0x8a, 0xbd, 0x95, 0x29, 0xbc, 0xd2, 0xdf, 0xab, 0xc6, 0x92,
+	0x47, 0x70, 0x43, 0xa4, 0x34, 0xe6, 0x34, 0x10, 0x61, 0x12, 0xfb, 0x82, 0x4e, 0xb8, 0xd3, 0xbe,
+	0x6f, 0xec, 0xdb, 0xde, 0x66, 0x45, 0x3f, 0x41, 0x99, 0xdc, 0x05, 0x2b, 0x0a, 0xd1, 0x25, 0x8c,
+	0x98, 0x1f, 0x71, 0xa7, 0x83, 0x5e, 0x2d, 0xcf, 0x44, 0xe9, 0x04, 0x95, 0x77, 0xda, 0x4e, 0x17,
+	0xa5, 0xbd, 0x9b, 0xdb, 0xe9, 0x42, 0xdb, 0xdd, 0xdf, 0x0d, 0xd5, 0x85, 0x82, 0xa5, 0xdc, 0x3a,
+	0x48, 0x62, 0xdc, 0x25, 0x10, 0xfe, 0x39, 0x4b, 0x39, 0xee, 0x83, 0xcd, 0x30, 0xf6, 0x9b, 0xde,
+	0x66, 0xa1, 0x7f, 0xd4, 0x32, 0xd9, 0x81, 0xfe, 0x68, 0x96, 0x04, 0xa7, 0xea, 0x0b, 0xfd, 0x70,
+	0x8c, 0xc8, 0x65, 0x89, 0xf6, 0x52, 0x1c, 0x8e, 0xc9, 0x2d, 0xe8, 0x9c, 0x65, 0x49, 0x9a, 0x45,
+	0xc8, 0x54, 0x66, 0xc9, 0x57, 0xe4, 0x0e, 0xc0, 0x29, 0xbb, 0xf0, 0xa7, 0x94, 0x4f, 0x99, 0x86,
+	0x65, 0x7b, 0x26, 0x2a, 0x6f, 0x95, 0x40, 0xf6, 0x60, 0x13, 0x19, 0x85, 0xe7, 0x54, 0x01, 0x98,
+	0x53, 0x31, 0x45, 0x00, 0xd2, 0x67, 0x63, 0x29, 0x1f, 0xa1, 0x4a, 0x1c, 0xe8, 0xce, 0xb3, 0x11,
+	0x06, 0xca, 0x6f, 0x97, 0x0e, 0xc5, 0x52, 0xee, 0x4c, 0xa3, 0x24, 0x8b, 0x85, 0xfa, 0x68, 0xdc,
+	0x59, 0xaf, 0xc8, 0x6d, 0x30, 0x29, 0xe7, 0x4c, 0x48, 0xac, 0x4e, 0x4f, 0x95, 0xdc, 0x53, 0x02,
+	0xf2, 0x44, 0x5c, 0xc0, 0xc3, 0x49, 0x4c, 0x45, 0x96, 0x62, 0x59, 0xa6, 0xca, 0x58, 0x51, 0xc8,
+	0x2e, 0x6c, 0xa4, 0xec, 0x33, 0x4b, 0x19, 0xb2, 0xf2, 0xc7, 0x54, 0x50, 0x07, 0x54, 0x86, 0x7e,
+	0xa9, 0xbe, 0x41, 0x91, 0xdc, 0x84, 0x76, 0x18, 0x8f, 0xd9, 0xc2, 0xb1, 0x14, 0x6f, 0xbd, 0x90,
+	0x6a, 0x9c, 0xa0, 0x8b, 0x63, 0xab, 0x18, 0xbd, 0x70, 0x7f, 0x34, 0xa1, 0x57, 0xcc, 0x62, 0x05,
+	0x97, 0xb1, 0x02, 0x57, 0xe3, 0x1a, 0xb8, 0x9a, 0xeb, 0x70, 0xb5, 0xea, 0x70, 0xb5, 0x2f, 0xe1,
+	0xda, 0x01, 0x4d, 0x47, 0x36, 0x58, 0x4e, 0x97, 0x75, 0xd8, 0x1b, 0xe0, 0x19, 0x96, 0x1b, 0x7b,
+	0x5d, 0x65, 0xd1, 0x5d, 0xc6, 0xe9, 0x99, 0x26, 0xa9, 0x62, 0x6d, 0x7b, 0xf9, 0xea, 0x2f, 0x9c,
+	0xbd, 0x6b, 0xe0, 0x34, 0x57, 0xe2, 0x84, 0x2a, 0x4e, 0x9c, 0x3f, 0x3e, 0x67, 0xf1, 0x98, 0xfb,
+	0xfa, 0x08, 0x2a, 0xd8, 0x3d, 0xcf, 0xd6, 0xa2, 0x3e, 0x9e, 0xe4, 0x01, 0xd8, 0xda, 0xea, 0xeb,
+	0x0c, 0xb6, 0xfa, 0x38, 0x4b, 0x6b, 0x43, 0x95, 0x07, 0x8b, 0xc8, 0x5d, 0x8a, 0x81, 0xef, 0x2b,
+	0xa7, 0xbe, 0x56, 0xf3, 0x71, 0x77, 0x7f, 0x19, 0x60, 0x96, 0xc7, 0xbe, 0xb6, 0x51, 0x15, 0xc0,
+	0x8d, 0x3a, 0xc0, 0xcd, 0x5a, 0xc0, 0xad, 0x3a, 0xc0, 0x57, 0x41, 0xb5, 0xff, 0x05, 0x0a, 0xc7,
+	0x44, 0x24, 0xa7, 0x2c, 0xbf, 0x32, 0x3a, 0xca, 0xc5, 0x54, 0x8a, 0xba, 0x2c, 0x4a, 0x8e, 0xdd,
+	0x0a, 0x47, 0xf7, 0x9b, 0x01, 0xfd, 0x4b, 0x97, 0x51, 0xa5, 0x54, 0xa3, 0xb6, 0xd4, 0xc6, 0xf5,
+	0x4b, 0x6d, 0xae, 0xec, 0x69, 0xab, 0x5a, 0xcb, 0x4f, 0x03, 0xc8, 0x31, 0xce, 0x47, 0x18, 0x4f,
+	0x86, 0x31, 0x17, 0x69, 0xa6, 0x6e, 0x3a, 0x72, 0x0f, 0x2c, 0xac, 0x2b, 0xbd, 0xc8, 0x9b, 0x68,
+	0xa8, 0x10, 0x50, 0x92, 0xee, 0x21, 0x62, 0x8f, 0x18, 0xe7, 0x74, 0xc2, 0xf2, 0x5b, 0xa8, 0x58,
+	0xfe, 0xef, 0x0b, 0xc8, 0x9d, 0x80, 0x79, 0x5c, 0x0c, 0xf4, 0xfa, 0x3a, 0xf1, 0xf2, 0x91, 0xbb,
+	0x6a, 0x73, 0x43, 0x99, 0x7b, 0x28, 0x68, 0xe3, 0x36, 0x98, 0xe5, 0xd9, 0xc8, 0xa1, 0x2d, 0x05,
+	0xf7, 0xbb, 0x01, 0x96, 0xdc, 0xc9, 0x63, 0x67, 0x19, 0xe3, 0x82, 0xbc, 0x84, 0x5e, 0xf1, 0x12,
+	0xab, 0x8d, 0xd6, 0x3d, 0x97, 0xc5, 0x43, 0xeb, 0x95, 0x61, 0xe4, 0x08, 0xec, 0x70, 0x49, 0xb9,
+	0x78, 0x33, 0x1f, 0xd7, 0xa7, 0xb9, 0xda, 0x1a, 0xef, 0x52, 0x06, 0xf7, 0x18, 0x6c, 0x5d, 0x23,
+	0x9f, 0xe3, 0x52, 0x3e, 0xea, 0xd5, 0x0b, 0xc0, 0x58, 0xf7, 0xac, 0x96, 0x24, 0xab, 0xb7, 0xc4,
+	0xa1, 0x0f, 0x1d, 0x69, 0x60, 0x29, 0xf9, 0x00, 0x2d, 0xf9, 0x8f, 0xec, 0xae, 0x4e, 0x91, 0x23,
+	0xda, 0x7a, 0xb8, 0xce, 0x4d, 0x57, 0xf9, 0xca, 0xfa, 0x64, 0x96, 0x96, 0x51, 0x47, 0x79, 0x3f,
+	0xfb, 0x03, 0xd3, 0x9a, 0xa5, 0x3f, 0xe8, 0x08, 0x00, 0x00,
+}
@@ -7,6 +7,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
+
 	"i10r.io/crypto/ed25519"
 	"i10r.io/crypto/sha3pool"
 	"i10r.io/errors"
@@ -481,3 +483,92 @@ func checkLogTypes(t *testing.T, log []txvm.Tuple,
 		}
 	}
 }
+
+func TestRawTemplate(t *testing.T) {
+	assetID := bc.HashFromBytes([]byte{1})
+	keyIDs := [][]byte{keyHash(testutil.TestXPub[:])}
+	pubkeys := []ed25519.PublicKey{testutil.TestPub}
+
+	tpl := NewTemplate(time.Now().Add(time.Minute), []byte("tags"))
+	tpl.AddIssuance(2, []byte{1}, []byte("tag"), 1, keyIDs, nil, pubkeys, 5, []byte("iss"), []byte{2})
+	issAsset := bc.NewHash(tpl.Issuances[0].assetID())
+	tpl.AddInput(1, keyIDs, nil, pubkeys, 10, assetID, []byte{1}, []byte("inp"), 0)
+	tpl.AddOutput(1, pubkeys, 7, assetID, []byte("out"), []byte("tt"))
+	tpl.AddRetirement(3, assetID, nil)
+	tpl.AddOutput(1, pubkeys, 5, issAsset, nil, nil)
+
+	b, err := proto.Marshal(tpl.Raw())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw RawTemplate
+	err = proto.Unmarshal(b, &raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := TemplateFromRaw(&raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testutil.DeepEqual(got.Raw(), tpl.Raw()) {
+		t.Errorf("round trip:\ngot  %v\nwant %v", got.Raw(), tpl.Raw())
+	}
+	wantID, _, err := tpl.Materialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotID, _, err := got.Materialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotID != wantID {
+		t.Errorf("txid after round trip = %x, want %x", gotID.Bytes(), wantID.Bytes())
+	}
+
+	// Sign remotely: send instructions, get signatures back.
+	insts, err := got.SigningInstructions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(insts) != 2 {
+		t.Fatalf("got %d signing instructions, want 2", len(insts))
+	}
+	var sigs []*Signature
+	for _, inst := range insts {
+		var path [][]byte
+		path = append(path, inst.DerivationPath...)
+		sigs = append(sigs, &Signature{
+			EntryIndex: inst.EntryIndex,
+			Signature:  testutil.TestXPrv.Derive(path).Sign(inst.Message),
+		})
+	}
+	bad := *sigs[0]
+	bad.Signature = append([]byte(nil), bad.Signature...)
+	bad.Signature[0] ^= 1
+	err = got.AddSignatures([]*Signature{&bad})
+	if errors.Root(err) != ErrBadSignature {
+		t.Errorf("AddSignatures(bad) error = %v, want %v", err, ErrBadSignature)
+	}
+	err = got.AddSignatures(sigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	insts, err = got.SigningInstructions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(insts) != 0 {
+		t.Errorf("got %d signing instructions after signing, want 0", len(insts))
+	}
+	_, err = got.Tx()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign(t, tpl)
+	for i := range tpl.Inputs {
+		if !testutil.DeepEqual(got.Inputs[i].Sigs, tpl.Inputs[i].Sigs) {
+			t.Errorf("input %d sigs differ from Sign's", i)
+		}
+	}
+}
@@ -41,6 +41,7 @@ type Output struct {
 	LogPos    uint64
 	OutputID  bc.Hash
 	Value     *Value
+	Quorum    int
 	Pubkeys   []ed25519.PublicKey
 	RefData   []byte
 	TokenTags []byte
@@ -174,6 +175,7 @@ func addOutputMeta(out *Output, txOut bc.Output, tx *bc.Tx, logPos int) {
 		Anchor:  val[3].(txvm.Bytes),
 	}
 
+	out.Quorum = int(txOut.Stack[len(txOut.Stack)-3].(txvm.Tuple)[1].(txvm.Int))
 	out.Pubkeys = pubkeys
 	out.RefData = refdata
 }
@@ -0,0 +1,275 @@
+/*
+Package txgen generates random but valid transactions, for fuzzing
+code that consumes them: state application, block building, and
+anything else that wants realistic inputs rather than hand-written
+fixtures.
+
+A Generator keeps track of the outputs it has created, along with the
+keys that control them. Each transaction it generates either issues
+new value or spends some of those outputs, choosing at random among
+splits, merges, partial retirements, multisignature quorums, and
+time windows. Only outputs present in the snapshot passed to Tx are
+spent, so as long as the caller applies each batch of generated
+transactions before asking for more, every transaction is valid
+against the snapshot it was generated for.
+
+Generation is deterministic for a given seed.
+*/
+package txgen
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txbuilder/txresult"
+)
+
+const (
+	numKeys   = 8
+	numAssets = 4
+	maxAmount = 1000000
+	maxSplit  = 4 // the most outputs a spend produces
+	maxMerge  = 3 // the most inputs a spend consumes
+
+	issuanceVersion = 2
+)
+
+// UTXO is an output created by a Generator.
+type UTXO struct {
+	OutputID bc.Hash
+	AssetID  bc.Hash
+	Amount   int64
+	Anchor   []byte
+	Quorum   int
+	Pubkeys  []ed25519.PublicKey
+
+	seen bool // whether a snapshot has ever contained it
+}
+
+// Generator produces random valid transactions.
+type Generator struct {
+	rand  *rand.Rand
+	keys  map[string]ed25519.PrivateKey // keyed by public key
+	pubs  []ed25519.PublicKey
+	utxos []*UTXO
+}
+
+// New returns a Generator whose choices are determined by seed.
+func New(seed int64) *Generator {
+	g := &Generator{
+		rand: rand.New(rand.NewSource(seed)),
+		keys: make(map[string]ed25519.PrivateKey),
+	}
+	for i := 0; i < numKeys; i++ {
+		pub, prv, err := ed25519.GenerateKey(g.rand)
+		if err != nil {
+			panic(err)
+		}
+		g.keys[string(pub)] = prv
+		g.pubs = append(g.pubs, pub)
+	}
+	return g
+}
+
+// UTXOs returns the outputs created by g that are not known to have
+// been spent.
+func (g *Generator) UTXOs() []*UTXO {
+	return g.utxos
+}
+
+// Tx generates a single transaction valid against snapshot, for a
+// block with a timestamp shortly after now.
+func (g *Generator) Tx(snapshot *state.Snapshot, now time.Time) (*bc.Tx, error) {
+	txs, err := g.Txs(snapshot, now, 1)
+	if err != nil {
+		return nil, err
+	}
+	return txs[0], nil
+}
+
+// Txs generates n transactions that are valid against snapshot and
+// do not conflict with one another, so they may all go in the same
+// block.
+func (g *Generator) Txs(snapshot *state.Snapshot, now time.Time, n int) ([]*bc.Tx, error) {
+	if snapshot.InitialBlockID.IsZero() {
+		return nil, errors.New("cannot generate transactions for an empty state")
+	}
+
+	// An output that was once in a snapshot and no longer is has
+	// been spent. One never yet seen may still be pending; keep it,
+	// but don't spend it.
+	var avail []*UTXO
+	kept := g.utxos[:0]
+	for _, u := range g.utxos {
+		if _, ok := snapshot.Contract(u.OutputID); ok {
+			u.seen = true
+			avail = append(avail, u)
+		} else if u.seen {
+			continue
+		}
+		kept = append(kept, u)
+	}
+	g.utxos = kept
+
+	var txs []*bc.Tx
+	for i := 0; i < n; i++ {
+		var (
+			tx  *bc.Tx
+			err error
+		)
+		if len(avail) == 0 || g.rand.Intn(4) == 0 {
+			tx, err = g.issue(snapshot.InitialBlockID, now)
+		} else {
+			var inputs []*UTXO
+			inputs, avail = g.pickInputs(avail)
+			tx, err = g.spend(inputs, now)
+		}
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// pickInputs chooses up to maxMerge outputs of a single asset from
+// avail, returning them and the remainder of avail.
+func (g *Generator) pickInputs(avail []*UTXO) (picked, rest []*UTXO) {
+	first := g.rand.Intn(len(avail))
+	assetID := avail[first].AssetID
+	picked = []*UTXO{avail[first]}
+	want := 1 + g.rand.Intn(maxMerge)
+	for i, u := range avail {
+		switch {
+		case i == first:
+		case len(picked) < want && u.AssetID == assetID:
+			picked = append(picked, u)
+		default:
+			rest = append(rest, u)
+		}
+	}
+	return picked, rest
+}
+
+// randomKeys chooses a random quorum and set of public keys.
+func (g *Generator) randomKeys() (int, []ed25519.PublicKey) {
+	n := 1 + g.rand.Intn(3)
+	perm := g.rand.Perm(len(g.pubs))
+	var pubkeys []ed25519.PublicKey
+	for _, i := range perm[:n] {
+		pubkeys = append(pubkeys, g.pubs[i])
+	}
+	return 1 + g.rand.Intn(n), pubkeys
+}
+
+// template returns a new template with a random time window
+// containing now.
+func (g *Generator) template(now time.Time) *txbuilder.Template {
+	maxTime := now.Add(time.Minute + time.Duration(g.rand.Int63n(int64(time.Hour))))
+	tpl := txbuilder.NewTemplate(maxTime, nil)
+	if g.rand.Intn(2) == 0 {
+		tpl.RestrictMinTime(now.Add(-time.Duration(g.rand.Int63n(int64(time.Hour)))))
+	}
+	return tpl
+}
+
+func (g *Generator) issue(blockchainID bc.Hash, now time.Time) (*bc.Tx, error) {
+	tpl := g.template(now)
+	quorum, pubkeys := g.randomKeys()
+	tag := []byte(fmt.Sprintf("asset%d", g.rand.Intn(numAssets)))
+	amount := 1 + g.rand.Int63n(maxAmount)
+	nonce := make([]byte, 8)
+	g.rand.Read(nonce)
+	tpl.AddIssuance(issuanceVersion, blockchainID.Bytes(), tag, quorum, keyIDs(pubkeys), nil, pubkeys, amount, nil, nonce)
+	assetID := bc.NewHash(standard.AssetID(issuanceVersion, quorum, pubkeys, tag))
+	g.addOutputs(tpl, assetID, amount)
+	return g.finish(tpl)
+}
+
+func (g *Generator) spend(inputs []*UTXO, now time.Time) (*bc.Tx, error) {
+	tpl := g.template(now)
+	var total int64
+	for _, u := range inputs {
+		tpl.AddInput(u.Quorum, keyIDs(u.Pubkeys), nil, u.Pubkeys, u.Amount, u.AssetID, u.Anchor, nil, 0)
+		total += u.Amount
+	}
+	assetID := inputs[0].AssetID
+	if total > 1 && g.rand.Intn(4) == 0 {
+		retire := 1 + g.rand.Int63n(total-1)
+		tpl.AddRetirement(retire, assetID, nil)
+		total -= retire
+	}
+	g.addOutputs(tpl, assetID, total)
+	return g.finish(tpl)
+}
+
+// addOutputs splits amount of assetID into a random number of
+// outputs to random keys.
+func (g *Generator) addOutputs(tpl *txbuilder.Template, assetID bc.Hash, amount int64) {
+	n := 1 + g.rand.Intn(maxSplit)
+	if int64(n) > amount {
+		n = int(amount)
+	}
+	for i := 0; i < n; i++ {
+		a := amount
+		if i < n-1 {
+			a = 1 + g.rand.Int63n(amount-int64(n-i-1))
+		}
+		amount -= a
+		quorum, pubkeys := g.randomKeys()
+		tpl.AddOutput(quorum, pubkeys, a, assetID, nil, nil)
+	}
+}
+
+func (g *Generator) finish(tpl *txbuilder.Template) (*bc.Tx, error) {
+	err := tpl.Sign(context.Background(), func(_ context.Context, msg, keyID []byte, _ [][]byte) ([]byte, error) {
+		prv, ok := g.keys[string(keyID)]
+		if !ok {
+			return nil, nil
+		}
+		return ed25519.Sign(prv, msg), nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "signing")
+	}
+	tx, err := tpl.Tx()
+	if err != nil {
+		return nil, errors.Wrap(err, "building")
+	}
+	res := txresult.New(tx)
+	if len(res.Outputs) != len(tpl.Outputs) {
+		return nil, fmt.Errorf("built %d outputs, want %d", len(res.Outputs), len(tpl.Outputs))
+	}
+	for i, out := range res.Outputs {
+		if out.Value == nil {
+			return nil, fmt.Errorf("cannot parse output %d", i)
+		}
+		g.utxos = append(g.utxos, &UTXO{
+			OutputID: out.OutputID,
+			AssetID:  out.Value.AssetID,
+			Amount:   int64(out.Value.Amount),
+			Anchor:   out.Value.Anchor,
+			Quorum:   tpl.Outputs[i].Quorum,
+			Pubkeys:  tpl.Outputs[i].Pubkeys,
+		})
+	}
+	return tx, nil
+}
+
+// keyIDs uses each public key as its own key ID, so the signing
+// callback can find the corresponding private key.
+func keyIDs(pubkeys []ed25519.PublicKey) [][]byte {
+	var ids [][]byte
+	for _, pub := range pubkeys {
+		ids = append(ids, pub)
+	}
+	return ids
+}
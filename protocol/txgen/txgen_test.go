@@ -0,0 +1,53 @@
+package txgen
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/prottest"
+	"i10r.io/testutil"
+)
+
+func TestGenerate(t *testing.T) {
+	c := prottest.NewChain(t)
+	prottest.Initial(t, c)
+	g := New(1)
+
+	var spent, issued int
+	for i := 0; i < 10; i++ {
+		txs, err := g.Txs(c.State(), time.Now(), 5)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		for _, tx := range txs {
+			spent += len(tx.Inputs)
+			issued += len(tx.Issuances)
+		}
+		prottest.MakeBlock(t, c, txs) // fails the test if any tx is invalid
+	}
+	if spent == 0 || issued == 0 {
+		t.Errorf("generated %d inputs and %d issuances, want some of each", spent, issued)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	c := prottest.NewChain(t)
+	prottest.Initial(t, c)
+	now := time.Now()
+
+	gen := func() []*bc.Tx {
+		txs, err := New(7).Txs(c.State(), now, 3)
+		if err != nil {
+			testutil.FatalErr(t, err)
+		}
+		return txs
+	}
+	a, b := gen(), gen()
+	for i := range a {
+		if !bytes.Equal(a[i].Program, b[i].Program) {
+			t.Errorf("tx %d differs between runs with the same seed", i)
+		}
+	}
+}
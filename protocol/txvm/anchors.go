@@ -24,7 +24,7 @@ func opAnchor(vm *VM) {
 // NonceTuple computes a nonce tuple suitable for logging (with
 // vm.log(nonce...)) or hashing (with NonceHash).
 func NonceTuple(callerSeed, selfSeed, blockID []byte, expTimeMS int64) Tuple {
-	return Tuple{Bytes{NonceCode}, Bytes(callerSeed), Bytes(selfSeed), Bytes(blockID), Int(expTimeMS)}
+	return Tuple{typecodes[NonceCode], Bytes(callerSeed), Bytes(selfSeed), Bytes(blockID), Int(expTimeMS)}
 }
 
 // NonceHash computes the hash of a nonce tuple.
@@ -0,0 +1,56 @@
+package txvm
+
+// arena hands out the small slices a VM allocates constantly, tuple
+// and stack backing arrays, carved from larger slabs so that most
+// instructions need no allocation of their own. Each VM has its own
+// arena, so everything in it is released with the VM once the
+// transaction has been validated; the slabs are not recycled across
+// VMs because log entries, which live in them, outlive the run.
+//
+// Every slice handed out has its capacity limited to its length (or
+// to the requested capacity), so appending to one never overwrites
+// its neighbor.
+type arena struct {
+	data  []Data // unused remainder of the current tuple slab
+	items []Item // unused remainder of the current stack slab
+}
+
+const (
+	arenaSlab     = 128 // elements per slab
+	arenaMaxTuple = 16  // larger tuples are allocated separately
+	arenaStackCap = 8   // initial capacity of a stack from the arena
+)
+
+// tuple returns a zeroed tuple of length n.
+func (a *arena) tuple(n int) Tuple {
+	if n > arenaMaxTuple {
+		return make(Tuple, n)
+	}
+	if len(a.data) < n {
+		a.data = make([]Data, arenaSlab)
+	}
+	t := a.data[:n:n]
+	a.data = a.data[n:]
+	return Tuple(t)
+}
+
+// stack returns an empty stack with a small initial capacity.
+func (a *arena) stack() stack {
+	if len(a.items) < arenaStackCap {
+		a.items = make([]Item, arenaSlab)
+	}
+	s := a.items[:0:arenaStackCap]
+	a.items = a.items[arenaStackCap:]
+	return stack(s)
+}
+
+// typecodes holds a one-byte Bytes for each possible type code, so
+// that building a tuple with a type code needn't allocate one. They
+// must never be modified.
+var typecodes [256]Data
+
+func init() {
+	for i := range typecodes {
+		typecodes[i] = Bytes{byte(i)}
+	}
+}
@@ -0,0 +1,28 @@
+package txvm
+
+import "testing"
+
+func TestArena(t *testing.T) {
+	var a arena
+	t1 := a.tuple(2)
+	t2 := a.tuple(2)
+	t2[0], t2[1] = Int(1), Int(2)
+	_ = append(t1, Int(3))
+	if t2[0] != Int(1) {
+		t.Error("appending to one tuple overwrote the next")
+	}
+
+	s1 := a.stack()
+	s2 := a.stack()
+	s2.push(Int(1))
+	for i := 0; i < 2*arenaStackCap; i++ {
+		s1.push(Int(0))
+	}
+	if len(s2) != 1 || s2[0] != Int(1) {
+		t.Error("growing one stack overwrote the next")
+	}
+
+	if big := a.tuple(arenaMaxTuple + 1); len(big) != arenaMaxTuple+1 {
+		t.Errorf("got tuple of length %d, want %d", len(big), arenaMaxTuple+1)
+	}
+}
@@ -34,6 +34,24 @@ var macros = []macro{
 	{ident: "lt", expansion: "swap gt"},
 	{ident: "sub", expansion: "neg add"},
 	{ident: "splitzero", expansion: "0 split"},
+
+	// Version 4 ext selectors; see txvm.ExtOpsVersion.
+	{ident: "inputcount", expansion: "1 ext"},
+	{ident: "outputcount", expansion: "2 ext"},
+	{ident: "loglen", expansion: "3 ext"},
+	{ident: "stacklen", expansion: "4 ext"},
+	{ident: "checkcommitment", expansion: "5 ext"},
+	{ident: "bigadd", expansion: "6 ext"},
+	{ident: "bigsub", expansion: "7 ext"},
+	{ident: "bigmul", expansion: "8 ext"},
+	{ident: "bigmod", expansion: "9 ext"},
+	{ident: "bigexpmod", expansion: "10 ext"},
+	{ident: "bigcmp", expansion: "11 ext"},
+	{ident: "recoversecp256k1", expansion: "12 ext"},
+	{ident: "checkblsagg", expansion: "13 ext"},
+	{ident: "checkgroth16", expansion: "14 ext"},
+	{ident: "arglen", expansion: "15 ext"},
+	{ident: "peekarg", expansion: "16 ext"},
 }
 
 // initialized in init()
@@ -54,9 +72,37 @@ var composite map[string][]byte
 // Assemble converts a string containing an assembly language txvm
 // program into the corresponding bytecode.
 func Assemble(s string) ([]byte, error) {
+	m, err := AssembleSourceMap(s)
+	if err != nil {
+		return nil, err
+	}
+	return m.Prog, nil
+}
+
+// SourceMap relates the instructions of an assembled program to the
+// assembly source they came from.
+type SourceMap struct {
+	// Prog is the assembled bytecode.
+	Prog []byte
+
+	// Pos maps the bytecode offset of each instruction that begins a
+	// source token to that token's byte offset in the source. The
+	// second and later instructions of a macro or a jump have no
+	// entry of their own.
+	Pos map[int64]int
+
+	// Quoted holds the source maps of the programs quoted with [...]
+	// in this one, in order of appearance. Their Pos offsets are also
+	// relative to the start of the whole source.
+	Quoted []*SourceMap
+}
+
+// AssembleSourceMap is like Assemble but also returns a source map
+// for the program and every program quoted within it.
+func AssembleSourceMap(s string) (*SourceMap, error) {
 	scan := new(scanner)
 	scan.initString(s)
-	bytecode, err := assemble(scan, tokEOF)
+	m, err := assemble(scan, tokEOF)
 
 	// prefer the scanner's errors over the assemblers.
 	if len(scan.errs) > 0 {
@@ -65,10 +111,10 @@ func Assemble(s string) ([]byte, error) {
 			"errors",
 			scan.errs)
 	}
-	return bytecode, err
+	return m, err
 }
 
-func assemble(s *scanner, stoptok token) ([]byte, error) {
+func assemble(s *scanner, stoptok token) (*SourceMap, error) {
 	// First construct a list of assembler "items," then "resolve" those
 	// into bytecode.
 	//
@@ -85,7 +131,22 @@ func assemble(s *scanner, stoptok token) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return resolve(a.items)
+	prog, starts, err := resolve(a.items)
+	if err != nil {
+		return nil, err
+	}
+	m := &SourceMap{
+		Prog:   prog,
+		Pos:    make(map[int64]int),
+		Quoted: a.quoted,
+	}
+	for _, mk := range a.marks {
+		pc := int64(starts[mk.item] + mk.off)
+		if _, ok := m.Pos[pc]; !ok {
+			m.Pos[pc] = mk.src
+		}
+	}
+	return m, nil
 }
 
 type assembler struct {
@@ -97,6 +158,21 @@ type assembler struct {
 
 	items []interface{}
 	buf   bytes.Buffer // current item
+
+	marks  []mark
+	quoted []*SourceMap
+}
+
+// A mark records the source offset of the instruction at byte off of
+// items[item].
+type mark struct {
+	item, off, src int
+}
+
+// mark records that the next byte written to the current item comes
+// from the current token.
+func (a *assembler) mark() {
+	a.marks = append(a.marks, mark{item: len(a.items), off: a.buf.Len(), src: a.off})
 }
 
 func (a *assembler) next() token {
@@ -125,6 +201,7 @@ func (a *assembler) assembleItems() error {
 			a.items = append(a.items, a.lit[1:])
 		case tokJump, tokJumpIf:
 			a.flush()
+			a.mark()
 			jmp := jump{isJumpIf: a.tok == tokJumpIf}
 
 			// must be followed with a label
@@ -134,6 +211,7 @@ func (a *assembler) assembleItems() error {
 			jmp.label = a.lit[1:]
 			a.items = append(a.items, &jmp)
 		case tokIdent:
+			a.mark()
 			if preassembled, ok := composite[a.lit]; ok {
 				a.buf.Write(preassembled)
 			} else if o, ok := op.Code(a.lit); ok {
@@ -154,6 +232,7 @@ func (a *assembler) assembleItems() error {
 }
 
 func (a *assembler) assembleValue() error {
+	a.mark()
 	switch a.tok {
 	case tokString:
 		data := a.lit[1 : len(a.lit)-1]
@@ -194,11 +273,12 @@ func (a *assembler) assembleValue() error {
 		writePushint64(&a.buf, count)
 		a.buf.WriteByte(op.Tuple)
 	case tokLeftBracket:
-		prog, err := assemble(a.scanner, tokRightBracket)
+		m, err := assemble(a.scanner, tokRightBracket)
 		if err != nil {
 			return err
 		}
-		writePushdata(&a.buf, prog)
+		a.quoted = append(a.quoted, m)
+		writePushdata(&a.buf, m.Prog)
 	default:
 		return fmt.Errorf("unexpected token %q at offset %d", a.lit, a.off)
 	}
@@ -232,7 +312,10 @@ func writeVarint(buf *bytes.Buffer, v uint64) {
 	buf.Write(tmp[:n])
 }
 
-func resolve(items []interface{}) ([]byte, error) {
+// resolve concatenates items into bytecode, computing the final form
+// of each jump. It also returns the bytecode offset at which each item
+// begins.
+func resolve(items []interface{}) ([]byte, []int, error) {
 	labelIdxs := make(map[string]int) // index within items of each jump label
 	for i, item := range items {
 		if l, ok := item.(string); ok {
@@ -253,7 +336,7 @@ func resolve(items []interface{}) ([]byte, error) {
 			if j, ok := item.(*jump); ok {
 				labelIdx, ok := labelIdxs[j.label]
 				if !ok {
-					return nil, fmt.Errorf("jump to unknown label $%s", j.label)
+					return nil, nil, fmt.Errorf("jump to unknown label $%s", j.label)
 				}
 				// Count the bytes of the intervening items between i and labelIdx
 				var (
@@ -308,7 +391,9 @@ func resolve(items []interface{}) ([]byte, error) {
 		}
 	}
 	var buf bytes.Buffer
-	for _, item := range items {
+	starts := make([]int, len(items)+1)
+	for i, item := range items {
+		starts[i] = buf.Len()
 		switch ii := item.(type) {
 		case []byte:
 			buf.Write(ii)
@@ -316,7 +401,8 @@ func resolve(items []interface{}) ([]byte, error) {
 			buf.Write(ii.opcodes)
 		}
 	}
-	return buf.Bytes(), nil
+	starts[len(items)] = buf.Len()
+	return buf.Bytes(), starts, nil
 }
 
 func pushint64(num int64) []byte {
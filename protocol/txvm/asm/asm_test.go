@@ -3,6 +3,7 @@ package asm
 import (
 	"bytes"
 	"io/ioutil"
+	"reflect"
 	"testing"
 
 	"i10r.io/protocol/txvm/op"
@@ -85,3 +86,26 @@ func BenchmarkAssemble(b *testing.B) {
 		}
 	}
 }
+
+func TestSourceMap(t *testing.T) {
+	const src = "1 dup\n[verify] jump:$a\n$a"
+	m, err := AssembleSourceMap(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64]int{
+		0: 0,  // 1
+		1: 2,  // dup
+		2: 6,  // [verify]
+		4: 15, // jump:$a
+	}
+	if !reflect.DeepEqual(m.Pos, want) {
+		t.Errorf("got Pos %v, want %v", m.Pos, want)
+	}
+	if len(m.Quoted) != 1 {
+		t.Fatalf("got %d quoted programs, want 1", len(m.Quoted))
+	}
+	if q := m.Quoted[0]; !bytes.Equal(q.Prog, []byte{op.Verify}) || q.Pos[0] != 7 {
+		t.Errorf("got quoted program %x with Pos %v, want %x with {0: 7}", q.Prog, q.Pos, []byte{op.Verify})
+	}
+}
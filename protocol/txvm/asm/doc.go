@@ -27,6 +27,23 @@ that expand to longer sequences of instructions:
  - ge: swap le (greater than or equal)
  - lt: swap gt (less than)
 
+and, for the ext selectors of transaction version 4 (see
+txvm.ExtOpsVersion):
+
+ - inputcount: 1 ext (number of inputs so far)
+ - outputcount: 2 ext (number of outputs so far)
+ - loglen: 3 ext (length of the log so far)
+ - stacklen: 4 ext (depth of the contract stack)
+ - checkcommitment: 5 ext (check a Pedersen commitment opening)
+ - bigadd, bigsub, bigmul, bigmod: 6 ext through 9 ext (bigint arithmetic)
+ - bigexpmod: 10 ext (bigint modular exponentiation)
+ - bigcmp: 11 ext (bigint comparison)
+ - recoversecp256k1: 12 ext (recover the key from a secp256k1 signature)
+ - checkblsagg: 13 ext (check an aggregate BLS signature)
+ - checkgroth16: 14 ext (verify a Groth16 proof)
+ - arglen: 15 ext (depth of the argument stack)
+ - peekarg: 16 ext (copy an item from the argument stack)
+
 Whitespace between tokens in assembler input is insignificant.
 Comments are introduced by # and continue to the end of line.
 
@@ -1,8 +1,12 @@
 package txvm
 
 import (
+	"bytes"
 	"encoding/base64"
 	"testing"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/protocol/txvm/op"
 )
 
 const exampleTx = `mgJfLn/3qy8LaFR7yqDw+aeF9a+Jy7tNd+qTx/z/+rEdfZh70wFULgEuXy5gZCAuf1E229nE7tfrsbgodvfbpe7AsTIRJvpRIhuZa3CISFS0LmfQMeCL+g0zrC5lpP/84KEsIC6zAS1RJwlBLVItASowAQJBUi0tLS1RBCstUQUrA1RYMy08NwEqLowBASpVLVECUwMhKlkAAypRAFATQQJTBSotADsCKiEBKgEiIQEYIkFSAypQQFJCREhDSEMtLQAyXy5fLgEqLn/s2Trv35SNyMDwpGTd7E1DJJvwTBZpd8M6698UZlEeGwFULgEunwEtLS0tPC08lQEtPDcBKi6MAQEqVS1RAlMDISpZAAMqUQBQE0ECUwUqLQA7AiohASoBIiEBGCJBUgMqUEBSQkRHSENfLmBDf0e7lWyeiES/XTzD7ZPQHidTU1IxQrb7OZm10OEalY/6lQEtPDcBKi6MAQEqVS1RAlMDISpZAAMqUQBQE0ECUwUqLQA7AiohASoBIiEBGCJBUgMqUEBSQkRgWgECVGBUf+zZOu/flI3IwPCkZN3sTUMkm/BMFml3wzrr3xRmUR4bAVQCVGBWYGQgf5RBnkqjOP4Q5Y3HvIU4x18WMiIYRaVjHQvGVpt6E/Uqfxv/u7fJoTIo28RlOVsWDbjrwDBh8FXN8zWh35sg0lBDBFQGVEZDLS1fLl8uYEsgMi5//JUFNVLJHuQwQlECbqjIPKi5IkVW/OS/HdJEP9UVmWsBVC4BLp8BLS0tLTwtPJUBLTw3ASoujAEBKlUtUQJTAyEqWQADKlEAUBNBAlMFKi0AOwIqIQEqASIhARgiQVIDKlBAUkJER0hDXy5fLi5/zZ/pslZOgHei2uYCFMVKFUWbS/jcdsRAwAwuOHyCHhgBVC4BLp8BLS0tLTwtPJUBLTw3ASoujAEBKlUtUQJTAyEqWQADKlEAUBNBAlMFKi0AOwIqIQEqASIhARgiQVIDKlBAUkJER0hDXy5gQ39Hu5VsnohEv108w+2T0B4nU1NSMUK2+zmZtdDhGpWP+pUBLTw3ASoujAEBKlUtUQJTAyEqWQADKlEAUBNBAlMFKi0AOwIqIQEqASIhARgiQVIDKlBAUkJEYFoBAlRgVH/8lQU1Uske5DBCUQJuqMg8qLkiRVb85L8d0kQ/1RWZawFUAlRgVmBLIH+UQZ5Kozj+EOWNx7yFOMdfFjIiGEWlYx0LxlabehP1Kn9k0b5en68CxpBdi1kcZHazjz/qUIURgxd3t0TwJGMDUwRUBlRGQy0tXy5fLmAyIDIuf+NzlRarXBxBWgkYs4hEpSil8Ls3T6plI9tWVqogCetcAVQuAS6fAS0tLS08LTyVAS08NwEqLowBASpVLVECUwMhKlkAAypRAFATQQJTBSotADsCKiEBKgEiIQEYIkFSAypQQFJCREdIQ18uXy4uf98gAjapNmVp7pb+lr/EtPeim81o2dZED8XSr+p8OnJ2AVQuAS6fAS0tLS08LTyVAS08NwEqLowBASpVLVECUwMhKlkAAypRAFATQQJTBSotADsCKiEBKgEiIQEYIkFSAypQQFJCREdIQ18uYEN/R7uVbJ6IRL9dPMPtk9AeJ1NTUjFCtvs5mbXQ4RqVj/qVAS08NwEqLowBASpVLVECUwMhKlkAAypRAFATQQJTBSotADsCKiEBKgEiIQEYIkFSAypQQFJCRGBaAQJUYFR/43OVFqtcHEFaCRiziESlKKXwuzdPqmUj21ZWqiAJ61wBVAJUYFZgMiB/lEGeSqM4/hDljce8hTjHXxYyIhhFpWMdC8ZWm3oT9Sp/MhMZ5p75JS+M/Y8PE9/KF1a11sJjI5DOs2hIMSRXBKEEVAZURkMtLV8uXy4ZMi5/O9i5Ha7v5J1U3DiSTUjCTYUJUoautF56RIscNmm8gTABVC4BLp8BLS0tLTwtPJUBLTw3ASoujAEBKlUtUQJTAyEqWQADKlEAUBNBAlMFKi0AOwIqIQEqASIhARgiQVIDKlBAUkJER0hDXy4uYy00LTxIQ2XE1+rgoSwgZaT//OChLCBNXzwDKj+fASO3Lc4BhxJ/f3cK89dIZzYa/oIUQO0jxnWP8leFdKO0eETh/31gF1dxUOcPZp5rVANQ0Vh/J5St2JXbnC+Vcg8ugwE+f7FD5TjPQn3WMumDCdXKZVTwe82wHnjskJB//n3mIPHYUEAuQ58Bybo8SvUBeBgJOHoOv93a2vKe+gatYmSbN6F8EOYYTrYPZ3RhDF8/8RY1MYQFFsufh3IFS4zr+fMR/Jf6GmfvBi6DAT5/sUPlOM9CfdYy6YMJ1cplVPB7zbAeeOyQkH/+feYg8dhQQC5DnwGSC2QYSaU7JUnYS4Klw9p7S/oBOQcYT/iFrz7oWdv3DmM2PXES0Eo7+2IkXnasB/26gv+3GIR/VSsdiVHZXxQOLoMBPn+xQ+U4z0J91jLpgwnVymVU8HvNsB547JCQf/595iDx2FBALkOfAWab3AadtjkTMnixI67TBInk6LLrjA7qKN+7Vt+NTj2dEOfAuspSC7FPsyZ4O2RsST90Kyu76pSWPB30NrFwWw0ugwE+f7FD5TjPQn3WMumDCdXKZVTwe82wHnjskJB//n3mIPHYUEAuQw==`
@@ -22,3 +26,131 @@ func BenchmarkExampleTx(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkExampleTxCached(b *testing.B) {
+	prog, err := base64.StdEncoding.DecodeString(exampleTx)
+	if err != nil {
+		b.Fatal(err)
+	}
+	c := NewProgramCache(1 << 20)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := Validate(prog, 3, 16801, WithProgramCache(c))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// dispatchProg is a long program of cheap instructions, so that the
+// cost of running it is dominated by instruction decoding and
+// dispatch rather than by any one operation.
+var dispatchProg = func() []byte {
+	var prog []byte
+	for i := 0; i < 1000; i++ {
+		prog = append(prog, op.MinSmallInt+1, op.MinSmallInt+2, op.Add, op.Dup, op.Eq, op.Verify)
+		prog = append(prog, op.MinPushdata+3, 'a', 'b', 'c', op.Len, op.Drop)
+	}
+	return prog
+}()
+
+// BenchmarkOpcodes runs, for each of a selection of instructions, a
+// program repeating it, with the pushes and drops that keep the stack
+// level, n times.
+func BenchmarkOpcodes(b *testing.B) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	msg := bytes.Repeat([]byte{1}, 32)
+	sig := ed25519.Sign(prv, msg)
+	push := func(data []byte) []byte {
+		var buf bytes.Buffer
+		writePushdata(&buf, data)
+		return buf.Bytes()
+	}
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	small := func(n byte) []byte { return []byte{op.MinSmallInt + n} }
+	cases := []struct {
+		name string
+		body []byte
+		n    int
+	}{
+		{"add", concat(small(1), small(2), []byte{op.Add, op.Drop}), 1000},
+		{"mul", concat(small(3), small(5), []byte{op.Mul, op.Drop}), 1000},
+		{"div", concat(small(15), small(5), []byte{op.Div, op.Drop}), 1000},
+		{"eq", concat(small(1), small(1), []byte{op.Eq, op.Verify}), 1000},
+		{"dup", concat(small(1), []byte{op.Dup, op.Drop, op.Drop}), 1000},
+		{"roll", concat(small(1), small(2), small(3), small(2), []byte{op.Roll, op.Drop, op.Drop, op.Drop}), 1000},
+		{"cat", concat(push([]byte("abc")), push([]byte("def")), []byte{op.Cat, op.Drop}), 1000},
+		{"tuple", concat(small(1), small(2), small(2), []byte{op.Tuple, op.Untuple, op.Drop, op.Drop, op.Drop}), 1000},
+		{"encode", concat(small(1), small(2), small(2), []byte{op.Tuple, op.Encode, op.Drop}), 1000},
+		{"sha256", concat(push(msg), []byte{op.SHA256, op.Drop}), 1000},
+		{"sha3", concat(push(msg), []byte{op.SHA3, op.Drop}), 1000},
+		{"vmhash", concat(push(msg), push([]byte("f")), []byte{op.VMHash, op.Drop}), 1000},
+		{"checksig", concat(push(msg), push(pub), push(sig), small(SchemeEd25519), []byte{op.CheckSig, op.Verify}), 10},
+	}
+	for _, c := range cases {
+		prog := bytes.Repeat(c.body, c.n)
+		b.Run(c.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := Validate(prog, 3, 1<<30)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDispatch(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		_, err := Validate(dispatchProg, 3, 1<<20)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestDecodeInst checks that the VM's decoder agrees with
+// op.DecodeInst on every one- and two-byte instruction prefix, whole
+// or truncated.
+func TestDecodeInst(t *testing.T) {
+	for b0 := 0; b0 < 256; b0++ {
+		for b1 := 0; b1 < 256; b1++ {
+			full := append([]byte{byte(b0), byte(b1)}, make([]byte, 40)...)
+			for _, prog := range [][]byte{full[:1], full[:2], full[:3], full} {
+				gotOp, gotData, gotN, gotErr := decodeInst(prog)
+				wantOp, wantData, wantN, wantErr := op.DecodeInst(prog)
+				if gotOp != wantOp || !bytes.Equal(gotData, wantData) || gotN != wantN || (gotErr == nil) != (wantErr == nil) {
+					t.Fatalf("decodeInst(%x) = %d, %x, %d, %v; op.DecodeInst gives %d, %x, %d, %v",
+						prog, gotOp, gotData, gotN, gotErr, wantOp, wantData, wantN, wantErr)
+				}
+				if gotData != nil && cap(gotData) != len(gotData) {
+					t.Fatalf("decodeInst(%x) data has capacity %d, length %d", prog, cap(gotData), len(gotData))
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeInst(b *testing.B) {
+	b.Run("fast", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for pc := 0; pc < len(dispatchProg); {
+				_, _, n, _ := decodeInst(dispatchProg[pc:])
+				pc += int(n)
+			}
+		}
+	})
+	b.Run("op", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for pc := 0; pc < len(dispatchProg); {
+				_, _, n, _ := op.DecodeInst(dispatchProg[pc:])
+				pc += int(n)
+			}
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package txvm
+
+import (
+	"math/big"
+
+	"i10r.io/errors"
+)
+
+// Big integers are not a separate type on the stack. The bigint
+// operations treat a string as an unsigned integer in big-endian
+// order, of any length up to MaxBigLen (the empty string is zero),
+// and produce strings in the shortest such form.
+//
+// Each operation costs runlimit in proportion to the work it does,
+// measured in 64-bit words: the sum of the operands' lengths for
+// bigadd, bigsub, and bigcmp; their product for bigmul and bigmod;
+// and, for bigexpmod, the exponent's length in bits times the square
+// of the modulus's length, divided by 8.
+const MaxBigLen = 512
+
+var (
+	// ErrBigLen is returned when a bigint operand or result exceeds
+	// MaxBigLen bytes.
+	ErrBigLen = errorf("bigint too long")
+
+	// ErrBigRange is returned for a bigint subtraction with a
+	// negative result, or for division by zero.
+	ErrBigRange = errorf("bigint result out of range")
+)
+
+func opBigAdd(vm *VM) {
+	a, b, wa, wb := vm.popBigs()
+	vm.charge(1 + wa + wb)
+	vm.pushBig(a.Add(a, b))
+}
+
+func opBigSub(vm *VM) {
+	a, b, wa, wb := vm.popBigs()
+	vm.charge(1 + wa + wb)
+	if a.Cmp(b) < 0 {
+		panic(errors.Wrap(ErrBigRange, "bigsub"))
+	}
+	vm.pushBig(a.Sub(a, b))
+}
+
+func opBigMul(vm *VM) {
+	a, b, wa, wb := vm.popBigs()
+	vm.charge(1 + wa*wb)
+	vm.pushBig(a.Mul(a, b))
+}
+
+func opBigMod(vm *VM) {
+	a, b, wa, wb := vm.popBigs()
+	vm.charge(1 + wa*wb)
+	if b.Sign() == 0 {
+		panic(errors.Wrap(ErrBigRange, "bigmod"))
+	}
+	vm.pushBig(a.Mod(a, b))
+}
+
+// opBigExpMod computes base^exp mod m, with base exp m on the stack
+// (m on top).
+func opBigExpMod(vm *VM) {
+	m, wm := vm.popBig()
+	e, _ := vm.popBig()
+	x, _ := vm.popBig()
+	vm.charge(1 + int64(e.BitLen())*wm*wm/8)
+	if m.Sign() == 0 {
+		panic(errors.Wrap(ErrBigRange, "bigexpmod"))
+	}
+	vm.pushBig(x.Exp(x, e, m))
+}
+
+// opBigCmp pushes -1, 0, or 1 as a is less than, equal to, or greater
+// than b, with a b on the stack.
+func opBigCmp(vm *VM) {
+	a, b, wa, wb := vm.popBigs()
+	vm.charge(1 + wa + wb)
+	vm.push(Int(a.Cmp(b)))
+}
+
+// popBig pops a bigint operand and returns it with its length in
+// words.
+func (vm *VM) popBig() (*big.Int, int64) {
+	s := vm.popBytes()
+	if len(s) > MaxBigLen {
+		panic(errors.Wrapf(ErrBigLen, "operand is %d bytes", len(s)))
+	}
+	return new(big.Int).SetBytes(s), int64(len(s)+7) / 8
+}
+
+// popBigs pops the operands of a binary bigint operation, with b on
+// top of the stack.
+func (vm *VM) popBigs() (a, b *big.Int, wa, wb int64) {
+	b, wb = vm.popBig()
+	a, wa = vm.popBig()
+	return a, b, wa, wb
+}
+
+func (vm *VM) pushBig(x *big.Int) {
+	res := x.Bytes()
+	if len(res) > MaxBigLen {
+		panic(errors.Wrapf(ErrBigLen, "result is %d bytes", len(res)))
+	}
+	v := Bytes(res)
+	vm.chargeCreate(v)
+	vm.push(v)
+}
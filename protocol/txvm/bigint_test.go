@@ -0,0 +1,78 @@
+package txvm_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+)
+
+func TestBigint(t *testing.T) {
+	long := bytes.Repeat([]byte{0xff}, txvm.MaxBigLen)
+	cases := []struct {
+		src  string
+		want txvm.Data
+		err  error
+	}{
+		{"x'ffffffffffffffff' x'01' bigadd", txvm.Bytes{1, 0, 0, 0, 0, 0, 0, 0, 0}, nil},
+		{"x'0100' x'01' bigsub", txvm.Bytes{0xff}, nil},
+		{"x'05' x'05' bigsub", txvm.Bytes{}, nil},
+		{"x'01' x'02' bigsub", nil, txvm.ErrBigRange},
+		{"x'0100' x'0100' bigmul", txvm.Bytes{1, 0, 0}, nil},
+		{"x'0107' x'0100' bigmod", txvm.Bytes{7}, nil},
+		{"x'01' '' bigmod", nil, txvm.ErrBigRange},
+		{"x'03' x'04' x'07' bigexpmod", txvm.Bytes{4}, nil}, // 81 mod 7
+		{"x'0003' x'03' bigcmp", txvm.Int(0), nil},
+		{"x'02' x'03' bigcmp", txvm.Int(-1), nil},
+		{fmt.Sprintf("x'%x' x'02' bigmul", long), nil, txvm.ErrBigLen},
+		{fmt.Sprintf("x'00%x' '' bigadd", long), nil, txvm.ErrBigLen},
+	}
+	for _, c := range cases {
+		prog, err := asm.Assemble(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got txvm.Data
+		_, err = txvm.Validate(prog, 4, 100000, txvm.AfterStep(func(vm *txvm.VM) {
+			if vm.StackLen() > 0 {
+				got = vm.StackItem(vm.StackLen() - 1).(txvm.Tuple)[1]
+			}
+		}))
+		name := c.src
+		if len(name) > 40 {
+			name = name[:40] + "..."
+		}
+		if c.err != nil {
+			if errors.Root(err) != c.err {
+				t.Errorf("%s: got error %v, want %v", name, err, c.err)
+			}
+			continue
+		}
+		if errors.Root(err) != txvm.ErrResidue {
+			t.Errorf("%s: got error %v, want residue", name, err)
+		}
+		if txvm.Encode(got) == nil || !bytes.Equal(txvm.Encode(got), txvm.Encode(c.want)) {
+			t.Errorf("%s: got %v, want %v", name, got, c.want)
+		}
+	}
+}
+
+func TestBigintCost(t *testing.T) {
+	cost := func(src string) int64 {
+		prog, err := asm.Assemble(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vm, _ := txvm.Validate(prog, 4, 1e9)
+		return 1e9 - vm.Runlimit()
+	}
+	m := bytes.Repeat([]byte{0xfd}, 256)
+	small := cost(fmt.Sprintf("x'02' x'03' x'%x' bigexpmod", m))
+	large := cost(fmt.Sprintf("x'02' x'%x' x'%x' bigexpmod", m, m))
+	if large < 100*small {
+		t.Errorf("bigexpmod with 2048-bit exponent cost %d, want much more than %d", large, small)
+	}
+}
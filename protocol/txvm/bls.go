@@ -0,0 +1,108 @@
+package txvm
+
+import (
+	"i10r.io/crypto/bls"
+	"i10r.io/errors"
+)
+
+// BLS signature checks are deferred: checksig and checkblsagg only
+// decode their arguments, pushing true, and the pairing checks for
+// the whole transaction are done together after the program
+// finishes, in one batch. As with any non-empty signature, a check
+// that fails makes the transaction invalid.
+//
+// The costs are measured ones, at about 40 units of runlimit per
+// microsecond, as for an Ed25519 checksig. Each signature costs about
+// 17ms to decode and to add to the batch, which is charged as it is
+// deferred, and the batch itself about 18ms more, charged with the
+// first signature in it. Each key of an aggregate costs about 1.5ms
+// to decode.
+const (
+	blsCheckCost  = 330 * 2048 // in addition to checksig's 2048
+	blsBatchCost  = 340 * 2048
+	blsPubkeyCost = 28 * 2048
+)
+
+func (vm *VM) checkBLS(msg, pubkey, sig Bytes) {
+	vm.charge(blsCheckCost)
+	pub := parseBLSPubkey(pubkey)
+	vm.deferBLS(pub, msg, sig)
+}
+
+// opCheckBLSAggregate checks a BLS signature by a set of keys on a
+// common message. With msg pubkeys sig on the stack (sig on top),
+// where pubkeys is a tuple of 48-byte public keys, it pushes 0 if sig
+// is empty; otherwise it pushes 1 and defers the check that sig is
+// the aggregate of signatures on msg by each of pubkeys.
+//
+// The keys must be known to be safe to aggregate: a contract must not
+// accept keys whose possession has not been proven (see
+// bls.VerifyPossession).
+func opCheckBLSAggregate(vm *VM) {
+	sig := vm.popBytes()
+	pubkeys := vm.popTuple()
+	msg := vm.popBytes()
+	if len(sig) == 0 {
+		vm.pushBool(false)
+		return
+	}
+	vm.charge(2048 + blsCheckCost + blsPubkeyCost*int64(len(pubkeys)))
+	vm.countSigCheck()
+	if len(pubkeys) == 0 {
+		panic(errors.WithDetail(ErrPubSize, "no public keys"))
+	}
+	pubs := make([]*bls.PublicKey, 0, len(pubkeys))
+	for _, item := range pubkeys {
+		b, ok := item.(Bytes)
+		if !ok {
+			panic(errors.WithDetailf(ErrType, "public key is %s, want string", item))
+		}
+		pubs = append(pubs, parseBLSPubkey(b))
+	}
+	agg := bls.AggregatePublicKeys(pubs...)
+	if agg == nil {
+		panic(errors.WithData(ErrSignature, "message", []byte(msg)))
+	}
+	vm.deferBLS(agg, msg, sig)
+	vm.pushBool(true)
+}
+
+func parseBLSPubkey(b Bytes) *bls.PublicKey {
+	if len(b) != bls.PublicKeySize {
+		panic(errors.WithData(ErrPubSize, "got", len(b), "want", bls.PublicKeySize))
+	}
+	pub, err := bls.ParsePublicKey(b)
+	if err != nil {
+		panic(errors.WithData(ErrSignature, "public key", []byte(b)))
+	}
+	return pub
+}
+
+func (vm *VM) deferBLS(pub *bls.PublicKey, msg, sig Bytes) {
+	if len(sig) != bls.SignatureSize {
+		panic(errors.WithData(ErrSigSize, "got", len(sig), "want", bls.SignatureSize))
+	}
+	s, err := bls.ParseSignature(sig)
+	if err != nil {
+		panic(errors.WithData(ErrSignature, "signature", []byte(sig)))
+	}
+	if vm.blsBatch.Len() == 0 {
+		vm.charge(blsBatchCost) // for verifyBLS
+	}
+	vm.blsBatch.Add(pub, msg, s)
+}
+
+// verifyBLS performs the deferred BLS checks.
+func (vm *VM) verifyBLS() error {
+	if vm.blsBatch.Len() == 0 {
+		return nil
+	}
+	ok, err := vm.blsBatch.Verify(nil)
+	if err != nil {
+		return errors.Wrap(err, "verifying BLS signatures")
+	}
+	if !ok {
+		return errors.WithDetailf(ErrSignature, "%d deferred BLS signature checks", vm.blsBatch.Len())
+	}
+	return nil
+}
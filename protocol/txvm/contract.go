@@ -50,7 +50,14 @@ func opCall(vm *VM) {
 	vm.caller = vm.contract.seed
 	vm.contract = con
 
-	vm.exec(con.program)
+	var dec *decodedProg
+	if vm.programs != nil {
+		dec = vm.programs.get(con.program)
+		if dec != nil && vm.strict && !dec.canonical {
+			dec = nil
+		}
+	}
+	vm.execDecoded(con.program, dec)
 
 	if !vm.unwinding && len(vm.contract.stack) > 0 {
 		panic(errors.Wrapf(ErrNonEmpty, "contract %x", con.seed))
@@ -85,7 +92,7 @@ func opInput(vm *VM) {
 	vm.chargeCreate(con)
 	vm.push(con)
 
-	vm.logInput(snapshotID)
+	vm.logInput(con, snapshotID)
 }
 
 func opYield(vm *VM) {
@@ -144,13 +151,6 @@ func opPut(vm *VM) {
 	vm.argstack.push(item)
 }
 
-func opExt(vm *VM) {
-	if !vm.extension {
-		panic(errors.Wrap(ErrExt, "ext"))
-	}
-	_ = vm.popData()
-}
-
 func opPrv(vm *VM) {
 	panic(ErrPrv)
 }
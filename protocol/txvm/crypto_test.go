@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"testing"
+
+	"i10r.io/crypto/ed25519"
 )
 
 func TestVMHash(t *testing.T) {
@@ -27,3 +29,32 @@ func TestVMHash(t *testing.T) {
 		})
 	}
 }
+
+func TestSigCache(t *testing.T) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("message")
+	sig := ed25519.Sign(prv, msg)
+	bad := append([]byte(nil), sig...)
+	bad[0] ^= 1
+
+	c := NewSigCache()
+	for i, want := range []bool{true, true, false} {
+		s := sig
+		if !want {
+			s = bad
+		}
+		if got := c.verify(msg, pub, s); got != want {
+			t.Errorf("check %d: got %v, want %v", i, got, want)
+		}
+	}
+	if c.verify(msg, append([]byte{2}, make([]byte, 31)...), sig) {
+		t.Error("signature accepted under a key that does not decode")
+	}
+	want := SigCacheStats{Keys: 2, KeyHits: 1, Verified: 1, SigHits: 1}
+	if got := c.Stats(); got != want {
+		t.Errorf("Stats() = %+v, want %+v", got, want)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"i10r.io/errors"
+	"i10r.io/protocol/txvm/op"
 )
 
 // ErrInt is returned when int is called on a byte string
@@ -65,12 +66,13 @@ func opTuple(vm *VM) {
 	if n > int64(vm.contract.stack.Len()) || n < 0 {
 		panic(errors.Wrapf(errors.WithData(ErrUnderflow, "len(stack)", vm.contract.stack.Len()), "tuple %d", n))
 	}
-	vals := make(Tuple, n)
+	vals := vm.arena.tuple(int(n))
 	for n > 0 {
 		n--
 		v := vm.popData()
 		vals[n] = v
 	}
+	vm.checkTupleDepth(vals)
 	vm.chargeCreate(vals)
 	vm.push(vals)
 }
@@ -107,6 +109,14 @@ func opInt(vm *VM) {
 	if n <= 0 {
 		panic(errors.WithData(ErrInt, "int", a))
 	}
+	if vm.strict {
+		if n != len(a) || !minimalVarint(a, int64(n)) {
+			panic(errors.WithData(ErrEncoding, "int", a))
+		}
+		if vm.afterPush && op.IsSmallInt(int64(res)) {
+			panic(errors.Wrapf(ErrEncoding, "pushdata int for small integer %d", res))
+		}
+	}
 	// Note: if res > math.MaxInt64, this will convert it to a negative
 	// number. This is intentional!
 	vm.push(Int(res))
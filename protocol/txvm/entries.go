@@ -40,7 +40,7 @@ func (x *value) isDroppable() bool { return x.isZero() }
 
 func (x *value) inspect() Tuple {
 	return Tuple{
-		Bytes{ValueCode},
+		typecodes[ValueCode],
 		Int(x.amount),
 		Bytes(x.assetID),
 		Bytes(x.anchor),
@@ -91,17 +91,19 @@ type contract struct {
 	seed     []byte
 	program  []byte
 	stack    stack
+
+	cost       int64 // runlimit charged while this was the current contract
+	attributed bool  // cost has been assigned to a log entry
 }
 
 func (x *contract) isPortable() bool  { return x.typecode == WrappedContractCode }
 func (x *contract) isDroppable() bool { return false }
 
 func (x *contract) inspect() Tuple {
-	result := Tuple{
-		Bytes{x.typecode},
-		Bytes(x.seed),
-		Bytes(x.program),
-	}
+	result := make(Tuple, 3, 3+len(x.stack))
+	result[0] = typecodes[x.typecode]
+	result[1] = Bytes(x.seed)
+	result[2] = Bytes(x.program)
 	for _, item := range x.stack {
 		result = append(result, item.inspect())
 	}
@@ -153,7 +155,7 @@ func (x *contract) String() string {
 func (vm *VM) createContract(prog []byte) *contract {
 	vm.charge(128)
 	seed := ContractSeed(prog)
-	return &contract{typecode: ContractCode, seed: seed[:], program: prog}
+	return &contract{typecode: ContractCode, seed: seed[:], program: prog, stack: vm.arena.stack()}
 }
 
 func extractTypeCode(t Tuple) byte {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"i10r.io/errors"
+	"i10r.io/protocol/txvm/op"
 )
 
 type vmError error
@@ -12,6 +13,189 @@ func errorf(msg string, arg ...interface{}) error {
 	return vmError(fmt.Errorf(msg, arg...))
 }
 
+// ErrorCode is a machine-readable classification of a validation
+// failure. Codes are stable strings, suitable for returning from an
+// RPC or matching in tests.
+type ErrorCode string
+
+// Error codes, one per error value defined in this package, plus
+// CodeBadInstruction for a malformed program and CodeUnknown for
+// anything else.
+const (
+	CodeUnknown        ErrorCode = "unknown"
+	CodeBadInstruction ErrorCode = "bad-instruction"
+	CodeAnchorValue    ErrorCode = "anchor-value"
+	CodeBigLen         ErrorCode = "big-len"
+	CodeBigRange       ErrorCode = "big-range"
+	CodeBitLen         ErrorCode = "bit-len"
+	CodeCanceled       ErrorCode = "canceled"
+	CodeCommitment     ErrorCode = "commitment"
+	CodeEncoding       ErrorCode = "encoding"
+	CodeExt            ErrorCode = "ext"
+	CodeFields         ErrorCode = "fields"
+	CodeGroth16        ErrorCode = "groth16"
+	CodeFinalized      ErrorCode = "finalized"
+	CodeInt            ErrorCode = "int"
+	CodeIntOverflow    ErrorCode = "int-overflow"
+	CodeJump           ErrorCode = "jump"
+	CodeMergeAsset     ErrorCode = "merge-asset"
+	CodeNegAmount      ErrorCode = "neg-amount"
+	CodeNonEmpty       ErrorCode = "non-empty"
+	CodePlugin         ErrorCode = "plugin"
+	CodePrv            ErrorCode = "prv"
+	CodePubSize        ErrorCode = "pub-size"
+	CodeRange          ErrorCode = "range"
+	CodeResidue        ErrorCode = "residue"
+	CodeRunlimit       ErrorCode = "runlimit"
+	CodeSigSize        ErrorCode = "sig-size"
+	CodeSignature      ErrorCode = "signature"
+	CodeSliceRange     ErrorCode = "slice-range"
+	CodeSplit          ErrorCode = "split"
+	CodeStackRange     ErrorCode = "stack-range"
+	CodeStackDepth     ErrorCode = "stack-depth"
+	CodeTupleDepth     ErrorCode = "tuple-depth"
+	CodeLogSize        ErrorCode = "log-size"
+	CodeSigChecks      ErrorCode = "sig-checks"
+	CodeType           ErrorCode = "type"
+	CodeUnderflow      ErrorCode = "underflow"
+	CodeUnfinalized    ErrorCode = "unfinalized"
+	CodeUnportable     ErrorCode = "unportable"
+	CodeVerifyFail     ErrorCode = "verify-fail"
+)
+
+var errorCodes = map[error]ErrorCode{
+	ErrAnchorVal:   CodeAnchorValue,
+	ErrBigLen:      CodeBigLen,
+	ErrBigRange:    CodeBigRange,
+	ErrBitLen:      CodeBitLen,
+	ErrCanceled:    CodeCanceled,
+	ErrCommitment:  CodeCommitment,
+	ErrEncoding:    CodeEncoding,
+	ErrExt:         CodeExt,
+	ErrFields:      CodeFields,
+	ErrGroth16:     CodeGroth16,
+	ErrFinalized:   CodeFinalized,
+	ErrInt:         CodeInt,
+	ErrIntOverflow: CodeIntOverflow,
+	ErrJump:        CodeJump,
+	ErrMergeAsset:  CodeMergeAsset,
+	ErrNegAmount:   CodeNegAmount,
+	ErrNonEmpty:    CodeNonEmpty,
+	ErrPlugin:      CodePlugin,
+	ErrPrv:         CodePrv,
+	ErrPubSize:     CodePubSize,
+	ErrRange:       CodeRange,
+	ErrResidue:     CodeResidue,
+	ErrRunlimit:    CodeRunlimit,
+	ErrSigSize:     CodeSigSize,
+	ErrSignature:   CodeSignature,
+	ErrSliceRange:  CodeSliceRange,
+	ErrSplit:       CodeSplit,
+	ErrStackRange:  CodeStackRange,
+	ErrType:        CodeType,
+	ErrUnderflow:   CodeUnderflow,
+	ErrUnfinalized: CodeUnfinalized,
+	ErrUnportable:  CodeUnportable,
+	ErrVerifyFail:  CodeVerifyFail,
+}
+
+// maxFaultStack is the most stack items a FaultError summarizes.
+const maxFaultStack = 8
+
+// FaultError describes where and why validation failed. Errors
+// returned by Validate keep their error value (such as ErrRunlimit)
+// as their errors.Root; use Fault to obtain the FaultError.
+type FaultError struct {
+	Code ErrorCode
+
+	// PC is the offset, within Program, of the instruction that
+	// failed, and Opcode is that instruction. PC is -1 for failures
+	// not caused by any one instruction, such as ErrResidue.
+	PC      int64
+	Opcode  byte
+	Program []byte
+
+	// Seed is the seed of the contract that was running.
+	Seed []byte
+
+	// Runlimit is the runlimit remaining at the time of the failure.
+	Runlimit int64
+
+	// StackLen is the depth of the running contract's stack, and
+	// Stack describes the items on top of it, topmost first (at
+	// most 8 of them, each truncated to 64 bytes).
+	StackLen int
+	Stack    []string
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *FaultError) Error() string {
+	if e.PC < 0 {
+		return fmt.Sprintf("%s [%s]", e.Err, e.Code)
+	}
+	return fmt.Sprintf("%s [%s] at pc %d (%s)", e.Err, e.Code, e.PC, opName(e.Opcode))
+}
+
+// Fault returns the FaultError for an error returned by Validate, or
+// nil if err did not come from a failed VM run.
+func Fault(err error) *FaultError {
+	f, _ := errors.Data(err)["fault"].(*FaultError)
+	return f
+}
+
 func (vm *VM) wraperr(e error) error {
-	return errors.WithData(e, "vm", vm)
+	return errors.WithData(e, "vm", vm, "fault", vm.fault(e))
+}
+
+func (vm *VM) fault(e error) *FaultError {
+	root := errors.Root(e)
+	code, ok := errorCodes[root]
+	if !ok {
+		code = CodeUnknown
+		if _, ok := root.(decodeError); ok {
+			code = CodeBadInstruction
+		}
+	}
+	f := &FaultError{
+		Code:     code,
+		PC:       -1,
+		Runlimit: vm.runlimit,
+		Err:      e,
+	}
+	if prog := vm.run.prog; len(prog) > 0 {
+		f.PC = vm.run.inst
+		f.Opcode = prog[f.PC]
+		if opcode, _, _, err := op.DecodeInst(prog[f.PC:]); err == nil {
+			f.Opcode = opcode
+		}
+		f.Program = prog
+	}
+	if vm.contract != nil {
+		f.Seed = vm.contract.seed
+		s := vm.contract.stack
+		f.StackLen = len(s)
+		for i := len(s) - 1; i >= 0 && len(f.Stack) < maxFaultStack; i-- {
+			str := s[i].String()
+			if len(str) > 64 {
+				str = str[:61] + "..."
+			}
+			f.Stack = append(f.Stack, str)
+		}
+	}
+	return f
+}
+
+// decodeError is an error decoding an instruction.
+type decodeError struct{ error }
+
+func opName(opcode byte) string {
+	switch {
+	case op.IsSmallIntOp(opcode):
+		return fmt.Sprintf("%d", opcode-op.MinSmallInt)
+	case op.IsPushdataOp(opcode):
+		return "pushdata"
+	}
+	return op.Name(opcode)
 }
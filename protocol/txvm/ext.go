@@ -0,0 +1,125 @@
+package txvm
+
+import "i10r.io/errors"
+
+// Every opcode is already assigned, so instructions added after
+// transaction version 3 are reached through ext: in a transaction of
+// version ExtOpsVersion or later, ext with one of the selectors below
+// on top of the stack pops it and performs the corresponding
+// operation, whether or not the extension flag is set. Any other ext
+// retains its usual meaning.
+//
+// The assembler provides a mnemonic for each; see package asm.
+const (
+	// ExtInputCount pushes the number of input entries logged so
+	// far.
+	ExtInputCount = 1
+
+	// ExtOutputCount pushes the number of output entries logged so
+	// far.
+	ExtOutputCount = 2
+
+	// ExtLogLen pushes the length of the transaction log.
+	ExtLogLen = 3
+
+	// ExtStackLen pushes the depth of the current contract's stack
+	// (not counting the selector), so a contract can check how many
+	// arguments it was given.
+	ExtStackLen = 4
+
+	// ExtCheckCommitment pops a Pedersen commitment and its opening
+	// and pushes whether they match. See opCheckCommitment.
+	ExtCheckCommitment = 5
+
+	// ExtBigAdd through ExtBigCmp are arithmetic on arbitrary-precision
+	// unsigned integers. See MaxBigLen.
+	ExtBigAdd    = 6
+	ExtBigSub    = 7
+	ExtBigMul    = 8
+	ExtBigMod    = 9
+	ExtBigExpMod = 10
+	ExtBigCmp    = 11
+
+	// ExtRecoverSecp256k1 recovers a secp256k1 public key from a
+	// signature. See opRecoverSecp256k1.
+	ExtRecoverSecp256k1 = 12
+
+	// ExtCheckBLSAggregate checks an aggregate BLS signature. See
+	// opCheckBLSAggregate.
+	ExtCheckBLSAggregate = 13
+
+	// ExtCheckGroth16 verifies a Groth16 proof. See opCheckGroth16.
+	ExtCheckGroth16 = 14
+
+	// ExtArgLen pushes the depth of the argument stack, so a
+	// contract can check how many arguments it was passed with put.
+	// (The current contract's seed is pushed by self.)
+	ExtArgLen = 15
+
+	// ExtPeekArg pops an Int n and pushes a copy of the nth item
+	// from the top of the argument stack, which must be Data. It is
+	// to the argument stack what peek is to the contract stack.
+	ExtPeekArg = 16
+)
+
+// ExtOpsVersion is the first transaction version with the ext
+// selectors above.
+const ExtOpsVersion = 4
+
+var extFuncs = map[Int]func(*VM){
+	ExtInputCount:      func(vm *VM) { vm.push(Int(vm.inputs)) },
+	ExtOutputCount:     func(vm *VM) { vm.push(Int(vm.outputs)) },
+	ExtLogLen:          func(vm *VM) { vm.push(Int(len(vm.Log))) },
+	ExtStackLen:        func(vm *VM) { vm.push(Int(len(vm.contract.stack))) },
+	ExtCheckCommitment: opCheckCommitment,
+	ExtBigAdd:          opBigAdd,
+	ExtBigSub:          opBigSub,
+	ExtBigMul:          opBigMul,
+	ExtBigMod:          opBigMod,
+	ExtBigExpMod:       opBigExpMod,
+	ExtBigCmp:          opBigCmp,
+
+	ExtRecoverSecp256k1:  opRecoverSecp256k1,
+	ExtCheckBLSAggregate: opCheckBLSAggregate,
+	ExtCheckGroth16:      opCheckGroth16,
+
+	ExtArgLen:  func(vm *VM) { vm.push(Int(len(vm.argstack))) },
+	ExtPeekArg: opPeekArg,
+}
+
+func opExt(vm *VM) {
+	if !vm.extension && vm.txVersion < ExtOpsVersion {
+		panic(errors.Wrap(ErrExt, "ext"))
+	}
+	sel := vm.popData()
+	if vm.txVersion < ExtOpsVersion {
+		return
+	}
+	if sel, ok := sel.(Int); ok {
+		if f, ok := extFuncs[sel]; ok {
+			f(vm)
+			return
+		}
+		if f, ok := vm.plugin(sel); ok {
+			f(vm)
+			return
+		}
+	}
+	if !vm.extension {
+		panic(errors.Wrapf(ErrExt, "ext %s", sel))
+	}
+}
+
+func opPeekArg(vm *VM) {
+	n := int64(vm.popInt())
+	item, ok := vm.argstack.peek(n)
+	if !ok {
+		panic(errors.Wrapf(ErrStackRange, "peeking argument %d", n))
+	}
+	d, ok := item.(Data)
+	if !ok {
+		panic(errors.WithData(ErrType, "want", "Data"))
+	}
+	vm.chargeCopy(d)
+	vm.push(d)
+}
@@ -27,6 +27,8 @@ func main() {
 	for _, op := range ops {
 		fmt.Fprintf(out, "\topFuncs[op.%s] = op%s\n", op, op)
 	}
+	fmt.Fprint(out, "\tfor i := op.MinSmallInt; i <= op.MaxSmallInt; i++ {\n\t\topFuncs[i] = opSmallInt\n\t}\n")
+	fmt.Fprint(out, "\tfor i := op.MinPushdata; i < len(opFuncs); i++ {\n\t\topFuncs[i] = opPushdata\n\t}\n")
 	fmt.Fprint(out, "}\n")
 
 	out.Close()
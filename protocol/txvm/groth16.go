@@ -0,0 +1,77 @@
+package txvm
+
+import (
+	"math/big"
+
+	"i10r.io/crypto/bls"
+	"i10r.io/crypto/groth16"
+	"i10r.io/errors"
+)
+
+// ErrGroth16 is returned when checkgroth16 is called with a
+// malformed verifying key, proof, or public input.
+var ErrGroth16 = errorf("malformed groth16 verifying key, proof, or input")
+
+// Costs of checkgroth16, measured as for the BLS signature checks:
+// per pairing (it computes four, in about 45ms), per point decoded
+// (about 1.5ms for G1 and 2.4ms for G2, for the subgroup checks), and
+// per public input (about 6ms, for a scalar multiplication).
+const (
+	groth16PairingCost = 220 * 2048
+	groth16G1Cost      = 28 * 2048
+	groth16G2Cost      = 48 * 2048
+	groth16InputCost   = 120 * 2048
+)
+
+// groth16KeyCost is the cost of decoding a verifying key of n bytes,
+// charged before decoding it: three G2 points and, in the rest, as
+// many G1 points as fit.
+func groth16KeyCost(n int) int64 {
+	cost := int64(3 * groth16G2Cost)
+	if n > 3*bls.G2Size {
+		cost += groth16G1Cost * int64((n-3*bls.G2Size)/bls.G1Size)
+	}
+	return cost
+}
+
+// opCheckGroth16 verifies a Groth16 proof over BLS12-381 (see package
+// groth16). With vk proof inputs on the stack (inputs on top), where
+// vk and proof are strings and inputs is a tuple of 32-byte strings,
+// it pushes 1 if the proof is valid and 0 if it is not.
+//
+// A contract normally keeps the verifying key, or its hash, in its
+// own state, so that only proofs for its circuit are accepted:
+//
+//	<vk> ... get [proof] get [inputs] checkgroth16 verify
+func opCheckGroth16(vm *VM) {
+	tup := vm.popTuple()
+	proofBytes := vm.popBytes()
+	vkBytes := vm.popBytes()
+	vm.charge(4*groth16PairingCost + 2*groth16G1Cost + groth16G2Cost) // and the proof
+	vm.charge(groth16InputCost * int64(len(tup)))
+	vm.charge(groth16KeyCost(len(vkBytes)))
+
+	vk, err := groth16.ParseVerifyingKey(vkBytes)
+	if err != nil {
+		panic(errors.WithDetail(ErrGroth16, err.Error()))
+	}
+	proof, err := groth16.ParseProof(proofBytes)
+	if err != nil {
+		panic(errors.WithDetail(ErrGroth16, err.Error()))
+	}
+	inputs := make([]*big.Int, len(tup))
+	for i, item := range tup {
+		b, ok := item.(Bytes)
+		if !ok {
+			panic(errors.WithDetailf(ErrType, "input %d is %s, want string", i, item))
+		}
+		if inputs[i], err = groth16.ParseScalar(b); err != nil {
+			panic(errors.WithDetail(ErrGroth16, err.Error()))
+		}
+	}
+	ok, err := groth16.Verify(vk, proof, inputs)
+	if err != nil {
+		panic(errors.WithDetail(ErrGroth16, err.Error()))
+	}
+	vm.pushBool(ok)
+}
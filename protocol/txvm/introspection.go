@@ -33,3 +33,17 @@ func (vm *VM) StackItem(i int) Data {
 func (vm *VM) Seed() []byte {
 	return vm.contract.seed
 }
+
+// PC returns the offset within the current program of the
+// instruction being executed. In a BeforeStep callback this is the
+// offset of the instruction about to run; in an AfterStep callback
+// it is the offset of the next instruction.
+func (vm *VM) PC() int64 {
+	return vm.run.pc
+}
+
+// Program returns the program currently being executed. Callers
+// must not modify it.
+func (vm *VM) Program() []byte {
+	return vm.run.prog
+}
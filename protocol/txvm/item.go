@@ -42,9 +42,9 @@ const (
 	TupleCode byte = 'T'
 )
 
-func (i Int) inspect() Tuple   { return Tuple{Bytes{IntCode}, i} }
-func (b Bytes) inspect() Tuple { return Tuple{Bytes{BytesCode}, b} }
-func (t Tuple) inspect() Tuple { return Tuple{Bytes{TupleCode}, t} }
+func (i Int) inspect() Tuple   { return Tuple{typecodes[IntCode], i} }
+func (b Bytes) inspect() Tuple { return Tuple{typecodes[BytesCode], b} }
+func (t Tuple) inspect() Tuple { return Tuple{typecodes[TupleCode], t} }
 
 func (i Int) isPortable() bool   { return true }
 func (b Bytes) isPortable() bool { return true }
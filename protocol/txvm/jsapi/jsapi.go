@@ -0,0 +1,181 @@
+// Package jsapi provides string- and JSON-oriented entry points to
+// txvm assembly, validation, and transaction construction.
+//
+// It is the portable half of the JavaScript bindings in
+// i10r.io/cmd/txvmwasm: every function here takes and returns only
+// values that map directly onto JavaScript types (strings, numbers,
+// and plain objects), so that the syscall/js layer can stay a thin
+// translation shim. Byte strings are hex-encoded throughout.
+package jsapi
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/errors"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+)
+
+// Result is the outcome of validating a transaction program.
+type Result struct {
+	Finalized bool     `json:"finalized"`
+	TxID      string   `json:"txid,omitempty"`
+	Log       []string `json:"log"`
+	Runlimit  int64    `json:"runlimit"` // remaining
+	Error     string   `json:"error,omitempty"`
+	ErrorCode string   `json:"error_code,omitempty"` // a txvm.ErrorCode
+}
+
+// Assemble assembles txvm assembly language source and returns the
+// hex-encoded bytecode.
+func Assemble(src string) (string, error) {
+	prog, err := asm.Assemble(src)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(prog), nil
+}
+
+// Disassemble disassembles hex-encoded bytecode.
+func Disassemble(progHex string) (string, error) {
+	prog, err := hex.DecodeString(progHex)
+	if err != nil {
+		return "", errors.Wrap(err, "decoding program")
+	}
+	return asm.Disassemble(prog)
+}
+
+// Validate runs the hex-encoded program in the txvm virtual machine
+// with the given version and runlimit, for the blockchain whose
+// initial block has the hex-encoded ID network. Validation failures
+// are reported in the Error field of the result, not as an error;
+// the returned error is non-nil only for malformed arguments.
+//
+// The network may be empty only for a version before
+// txvm.NetworkVersion, since the transaction ID of later versions
+// commits to it.
+func Validate(progHex string, version, runlimit int64, network string) (*Result, error) {
+	prog, err := hex.DecodeString(progHex)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding program")
+	}
+	opts, err := networkOptions(version, network)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Log: []string{}}
+	vm, err := txvm.Validate(prog, version, runlimit, append(opts, txvm.GetRunlimit(&res.Runlimit))...)
+	if vm != nil {
+		res.Finalized = vm.Finalized
+		if vm.Finalized {
+			res.TxID = hex.EncodeToString(vm.TxID[:])
+		}
+		for _, entry := range vm.Log {
+			res.Log = append(res.Log, entry.String())
+		}
+	}
+	if err != nil {
+		res.Error = err.Error()
+		if f := txvm.Fault(err); f != nil {
+			res.ErrorCode = string(f.Code)
+		}
+	}
+	return res, nil
+}
+
+// TxID computes the ID of the hex-encoded transaction program,
+// stopping after its finalize instruction, so that it works on
+// programs that do not yet have their signatures. The network is as
+// for Validate.
+func TxID(progHex string, version, runlimit int64, network string) (string, error) {
+	prog, err := hex.DecodeString(progHex)
+	if err != nil {
+		return "", errors.Wrap(err, "decoding program")
+	}
+	opts, err := networkOptions(version, network)
+	if err != nil {
+		return "", err
+	}
+	vm, err := txvm.Validate(prog, version, runlimit, append(opts, txvm.StopAfterFinalize)...)
+	if err != nil {
+		return "", err
+	}
+	if !vm.Finalized {
+		return "", txvm.ErrUnfinalized
+	}
+	return hex.EncodeToString(vm.TxID[:]), nil
+}
+
+// networkOptions returns the txvm options setting the hex-encoded
+// network, which must be given for a version of
+// txvm.NetworkVersion or later.
+func networkOptions(version int64, network string) ([]txvm.Option, error) {
+	if network == "" {
+		if version >= txvm.NetworkVersion {
+			return nil, fmt.Errorf("transactions of version %d need a network", version)
+		}
+		return nil, nil
+	}
+	id, err := hex.DecodeString(network)
+	if err != nil {
+		return nil, errors.Wrap(err, "decoding network")
+	}
+	if len(id) != 32 {
+		return nil, fmt.Errorf("network of %d bytes, want 32", len(id))
+	}
+	var id32 [32]byte
+	copy(id32[:], id)
+	return []txvm.Option{txvm.Network(id32)}, nil
+}
+
+// Sign adds signatures to the JSON-encoded txbuilder.Template and
+// returns the updated template. Keys maps hex-encoded key IDs (as
+// they appear in the template's key_hashes fields) to hex-encoded
+// ed25519 private keys. Key IDs not in the map are skipped.
+func Sign(templateJSON string, keys map[string]string) (string, error) {
+	tpl := new(txbuilder.Template)
+	err := json.Unmarshal([]byte(templateJSON), tpl)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing template")
+	}
+	err = tpl.Sign(context.Background(), func(_ context.Context, msg, keyID []byte, _ [][]byte) ([]byte, error) {
+		prvHex, ok := keys[hex.EncodeToString(keyID)]
+		if !ok {
+			return nil, nil
+		}
+		prv, err := hex.DecodeString(prvHex)
+		if err != nil {
+			return nil, errors.Wrap(err, "decoding private key")
+		}
+		if len(prv) != ed25519.PrivateKeySize {
+			return nil, errors.New("bad private key length")
+		}
+		return ed25519.Sign(prv, msg), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(tpl)
+	return string(b), err
+}
+
+// Build renders the JSON-encoded txbuilder.Template as a complete
+// transaction, returning its hex-encoded ID and program and its
+// runlimit.
+func Build(templateJSON string) (txid, progHex string, runlimit int64, err error) {
+	tpl := new(txbuilder.Template)
+	err = json.Unmarshal([]byte(templateJSON), tpl)
+	if err != nil {
+		return "", "", 0, errors.Wrap(err, "parsing template")
+	}
+	tx, err := tpl.Tx()
+	if err != nil {
+		return "", "", 0, err
+	}
+	return hex.EncodeToString(tx.ID.Bytes()), hex.EncodeToString(tx.Program), tx.Runlimit, nil
+}
@@ -0,0 +1,129 @@
+package jsapi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txbuilder"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txvm"
+	"i10r.io/testutil"
+)
+
+func TestAssembleValidate(t *testing.T) {
+	progHex, err := Assemble("1 2 add 3 eq verify")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	dis, err := Disassemble(progHex)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if dis != "1 2 add 3 eq verify" {
+		t.Errorf("Disassemble = %q", dis)
+	}
+	res, err := Validate(progHex, 3, 100, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if res.Error != "" || res.Finalized || res.Runlimit <= 0 {
+		t.Errorf("Validate = %+v", res)
+	}
+
+	res, err = Validate(progHex, 3, 2, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if !strings.Contains(res.Error, "runlimit") || res.ErrorCode != "runlimit" {
+		t.Errorf("Validate with low runlimit: error %q, code %q", res.Error, res.ErrorCode)
+	}
+
+	_, err = Validate("zz", 3, 100, "")
+	if err == nil {
+		t.Error("expected error decoding bad hex")
+	}
+}
+
+func TestSignBuild(t *testing.T) {
+	pub, prv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	keyID := []byte{0xaa}
+
+	tpl := txbuilder.NewTemplate(time.Now().Add(time.Hour), nil)
+	tpl.AddIssuance(2, make([]byte, 32), nil, 1, [][]byte{keyID}, nil, []ed25519.PublicKey{pub}, 10, nil, []byte("nonce"))
+	assetID := bc.NewHash(standard.AssetID(2, 1, []ed25519.PublicKey{pub}, nil))
+	tpl.AddOutput(1, []ed25519.PublicKey{pub}, 10, assetID, nil, nil)
+	b, err := json.Marshal(tpl)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	signed, err := Sign(string(b), map[string]string{hex.EncodeToString(keyID): hex.EncodeToString(prv)})
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	txid, progHex, runlimit, err := Build(signed)
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+
+	res, err := Validate(progHex, 3, runlimit, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if res.Error != "" {
+		t.Fatalf("validating built tx: %s", res.Error)
+	}
+	if !res.Finalized || res.TxID != txid {
+		t.Errorf("got finalized %v txid %s, want true %s", res.Finalized, res.TxID, txid)
+	}
+	id, err := TxID(progHex, 3, runlimit, "")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if id != txid {
+		t.Errorf("TxID = %s, want %s", id, txid)
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	progHex, err := Assemble("'id' 10 nonce finalize")
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if _, err := TxID(progHex, txvm.NetworkVersion, 1000, ""); err == nil {
+		t.Error("TxID of version 5 without a network: got no error")
+	}
+	if _, err := Validate(progHex, txvm.NetworkVersion, 1000, "07"); err == nil {
+		t.Error("Validate with a short network: got no error")
+	}
+
+	network := [32]byte{7}
+	prog, _ := hex.DecodeString(progHex)
+	vm, err := txvm.Validate(prog, txvm.NetworkVersion, 1000, txvm.Network(network))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	want := hex.EncodeToString(vm.TxID[:])
+	id, err := TxID(progHex, txvm.NetworkVersion, 1000, hex.EncodeToString(network[:]))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if id != want {
+		t.Errorf("TxID = %s, want %s", id, want)
+	}
+	res, err := Validate(progHex, txvm.NetworkVersion, 1000, hex.EncodeToString(network[:]))
+	if err != nil {
+		testutil.FatalErr(t, err)
+	}
+	if res.TxID != want {
+		t.Errorf("Validate: txid %s, want %s", res.TxID, want)
+	}
+}
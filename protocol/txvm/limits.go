@@ -0,0 +1,151 @@
+package txvm
+
+import (
+	"encoding/binary"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/txvm/op"
+)
+
+var (
+	// ErrStackDepth is returned when a stack grows beyond
+	// Limits.StackDepth.
+	ErrStackDepth = errorf("stack too deep")
+
+	// ErrTupleDepth is returned when tuple creates a tuple nested
+	// more deeply than Limits.TupleDepth.
+	ErrTupleDepth = errorf("tuple nested too deeply")
+
+	// ErrLogSize is returned when the transaction log grows beyond
+	// Limits.LogSize.
+	ErrLogSize = errorf("log too large")
+
+	// ErrSigChecks is returned when a transaction performs more
+	// signature checks than Limits.SigChecks.
+	ErrSigChecks = errorf("too many signature checks")
+)
+
+// Limits caps resources that the runlimit alone does not bound
+// tightly enough, because a program can consume them cheaply. A zero
+// field means no limit.
+type Limits struct {
+	// StackDepth is the most items allowed on the argument stack or
+	// on the running contract's stack, checked after each
+	// instruction.
+	StackDepth int
+
+	// TupleDepth is the deepest nesting allowed in a tuple made by
+	// the tuple instruction. A tuple containing no tuples has depth
+	// 1.
+	TupleDepth int
+
+	// LogSize is the most bytes allowed in the transaction log, with
+	// each entry measured by the length of its Encode encoding.
+	LogSize int64
+
+	// SigChecks is the most signature checks (checksig with a
+	// non-empty signature) allowed. These are the checks a
+	// validator may defer and verify in a batch.
+	SigChecks int
+}
+
+// WithLimits can be passed as an option to Validate. It enforces l
+// in addition to the runlimit.
+func WithLimits(l Limits) Option {
+	return Option{
+		apply: func(vm *VM) { vm.limits = l },
+	}
+}
+
+// usage tracks a VM's consumption of the resources in Limits.
+type usage struct {
+	logSize    int64
+	sigChecks  int
+	tupleDepth map[*Data]int // depth of tuples made by tuple, keyed by first element
+}
+
+func (vm *VM) checkStackDepth() {
+	n := vm.limits.StackDepth
+	if len(vm.contract.stack) > n || len(vm.argstack) > n {
+		panic(errors.WithData(ErrStackDepth, "limit", n, "contract stack", len(vm.contract.stack), "argument stack", len(vm.argstack)))
+	}
+}
+
+func (vm *VM) checkTupleDepth(t Tuple) {
+	if vm.limits.TupleDepth == 0 || len(t) == 0 {
+		return
+	}
+	if vm.usage.tupleDepth == nil {
+		vm.usage.tupleDepth = make(map[*Data]int)
+	}
+	d := vm.tupleDepth(t)
+	if d > vm.limits.TupleDepth {
+		panic(errors.WithData(ErrTupleDepth, "limit", vm.limits.TupleDepth, "depth", d))
+	}
+}
+
+func (vm *VM) tupleDepth(t Tuple) int {
+	if len(t) == 0 {
+		return 1
+	}
+	if d, ok := vm.usage.tupleDepth[&t[0]]; ok {
+		return d
+	}
+	max := 0
+	for _, item := range t {
+		if sub, ok := item.(Tuple); ok {
+			if d := vm.tupleDepth(sub); d > max {
+				max = d
+			}
+		}
+	}
+	vm.usage.tupleDepth[&t[0]] = max + 1
+	return max + 1
+}
+
+func (vm *VM) countLog(t Tuple) {
+	if vm.limits.LogSize == 0 {
+		return
+	}
+	vm.usage.logSize += encodedLen(t)
+	if vm.usage.logSize > vm.limits.LogSize {
+		panic(errors.WithData(ErrLogSize, "limit", vm.limits.LogSize, "size", vm.usage.logSize))
+	}
+}
+
+func (vm *VM) countSigCheck() {
+	if vm.limits.SigChecks == 0 {
+		return
+	}
+	vm.usage.sigChecks++
+	if vm.usage.sigChecks > vm.limits.SigChecks {
+		panic(errors.WithData(ErrSigChecks, "limit", vm.limits.SigChecks))
+	}
+}
+
+// encodedLen returns len(Encode(d)) without building the encoding.
+func encodedLen(d Data) int64 {
+	switch d := d.(type) {
+	case Int:
+		if op.IsSmallInt(int64(d)) {
+			return 1
+		}
+		var buf [binary.MaxVarintLen64]byte
+		return pushdataLen(int64(binary.PutUvarint(buf[:], uint64(d)))) + 1
+	case Bytes:
+		return pushdataLen(int64(len(d)))
+	case Tuple:
+		n := encodedLen(Int(len(d))) + 1
+		for _, item := range d {
+			n += encodedLen(item)
+		}
+		return n
+	}
+	return 0
+}
+
+// pushdataLen is the length of a pushdata instruction for n bytes.
+func pushdataLen(n int64) int64 {
+	var buf [binary.MaxVarintLen64]byte
+	return int64(binary.PutUvarint(buf[:], uint64(n)+op.MinPushdata)) + n
+}
@@ -8,7 +8,7 @@ var ErrFinalized = errorf("cannot add to tx log after finalize")
 
 func opLog(vm *VM) {
 	data := vm.popData()
-	vm.log(Bytes{LogCode}, Bytes(vm.contract.seed[:]), data)
+	vm.log(typecodes[LogCode], Bytes(vm.contract.seed[:]), data)
 }
 
 func opPeekLog(vm *VM) {
@@ -25,9 +25,12 @@ func (vm *VM) log(v ...Data) Tuple {
 	if vm.Finalized {
 		panic(ErrFinalized)
 	}
-	t := Tuple(v)
+	t := vm.arena.tuple(len(v))
+	copy(t, v)
 	vm.chargeCreate(t)
+	vm.countLog(t)
 	vm.Log = append(vm.Log, t)
+	vm.entryCons = append(vm.entryCons, vm.contract)
 	vm.runHooks(vm.onLog)
 	return t
 }
@@ -37,25 +40,39 @@ func (vm *VM) logNonce(blockID []byte, exp int64) Tuple {
 }
 
 func (vm *VM) logTimeRange(mintime, maxtime Int) {
-	vm.log(Bytes{TimerangeCode}, Bytes(vm.contract.seed), mintime, maxtime)
+	vm.log(typecodes[TimerangeCode], Bytes(vm.contract.seed), mintime, maxtime)
 }
 
 func (vm *VM) logOutput(snapshotID []byte) {
-	vm.log(Bytes{OutputCode}, Bytes(vm.caller), Bytes(snapshotID))
+	vm.outputs++
+	vm.log(typecodes[OutputCode], Bytes(vm.caller), Bytes(snapshotID))
 }
 
-func (vm *VM) logInput(snapshotID []byte) {
-	vm.log(Bytes{InputCode}, Bytes(vm.contract.seed), Bytes(snapshotID))
+func (vm *VM) logInput(con *contract, snapshotID []byte) {
+	vm.inputs++
+	vm.log(typecodes[InputCode], Bytes(vm.contract.seed), Bytes(snapshotID))
+	vm.entryCons[len(vm.entryCons)-1] = con
 }
 
 func (vm *VM) logFinalize(anchor []byte) {
-	vm.log(Bytes{FinalizeCode}, Bytes(vm.contract.seed), Int(vm.txVersion), Bytes(anchor))
+	vm.log(typecodes[FinalizeCode], Bytes(vm.contract.seed), Int(vm.txVersion), Bytes(anchor))
 }
 
 func (vm *VM) logRetirement(amount int64, assetID, anchor []byte) {
-	vm.log(Bytes{RetireCode}, Bytes(vm.contract.seed), Int(amount), Bytes(assetID), Bytes(anchor))
+	vm.log(typecodes[RetireCode], Bytes(vm.contract.seed), Int(amount), Bytes(assetID), Bytes(anchor))
 }
 
 func (vm *VM) logIssuance(amount int64, assetID, anchor []byte) {
-	vm.log(Bytes{IssueCode}, Bytes(vm.caller), Int(amount), Bytes(assetID), Bytes(anchor))
+	vm.log(typecodes[IssueCode], Bytes(vm.caller), Int(amount), Bytes(assetID), Bytes(anchor))
+}
+
+// attributeRunlimit sets vm.EntryRunlimit. See Validate.
+func (vm *VM) attributeRunlimit() {
+	vm.EntryRunlimit = make([]int64, len(vm.Log))
+	for i, con := range vm.entryCons {
+		if !con.attributed {
+			vm.EntryRunlimit[i] = con.cost
+			con.attributed = true
+		}
+	}
 }
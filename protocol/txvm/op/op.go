@@ -149,7 +149,9 @@ func IsPushdataOp(o byte) bool {
 // For pushdata instructions, DecodeInst returns (MinPushdata, data,
 // n, err) where data is the immediate argument to the pushdata
 // instruction (the bytes to be pushed) and n is the number of bytes
-// of prog consumed by decoding this instruction.
+// of prog consumed by decoding this instruction. The data is a slice
+// of prog, not a copy; its capacity is limited to its length, so
+// appending to it does not overwrite the rest of prog.
 func DecodeInst(prog []byte) (byte, []byte, int64, error) {
 	if len(prog) == 0 {
 		return 0, nil, 0, fmt.Errorf("empty program")
@@ -166,5 +168,5 @@ func DecodeInst(prog []byte) (byte, []byte, int64, error) {
 	if uint64(len(prog)) < r {
 		return MinPushdata, nil, 0, fmt.Errorf("pushdata: only %d of %d bytes available", len(prog)-n, l)
 	}
-	return MinPushdata, append([]byte{}, prog[n:r]...), int64(r), nil
+	return MinPushdata, prog[n:r:r], int64(r), nil
 }
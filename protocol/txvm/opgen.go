@@ -70,4 +70,10 @@ func init() {
 	opFuncs[op.BitAnd] = opBitAnd
 	opFuncs[op.BitOr] = opBitOr
 	opFuncs[op.BitXor] = opBitXor
+	for i := op.MinSmallInt; i <= op.MaxSmallInt; i++ {
+		opFuncs[i] = opSmallInt
+	}
+	for i := op.MinPushdata; i < len(opFuncs); i++ {
+		opFuncs[i] = opPushdata
+	}
 }
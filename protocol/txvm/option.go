@@ -1,6 +1,7 @@
 package txvm
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -64,6 +65,40 @@ var EnableExtension = Option{
 	apply: func(vm *VM) { vm.extension = true },
 }
 
+// StrictEncoding can be passed as an option to Validate. It rejects,
+// with ErrEncoding, programs that encode something in more than one
+// way, so that nobody but the author can re-encode a transaction
+// program without changing its witness hash:
+//
+//   - an instruction whose opcode is a non-minimal varint;
+//   - an int whose argument is not exactly a minimal varint;
+//   - an int applied directly to pushdata when its result is a
+//     small integer, which has an opcode of its own.
+//
+// It is a local policy for now, not a consensus rule.
+var StrictEncoding = Option{
+	apply: func(vm *VM) { vm.strict = true },
+}
+
+// Context can be passed as an option to Validate. It causes execution
+// to stop with ErrCanceled if ctx is done before the program
+// finishes. See also ValidateContext.
+func Context(ctx context.Context) Option {
+	return Option{
+		apply: func(vm *VM) { vm.ctx = ctx },
+	}
+}
+
+// Network can be passed as an option to Validate. It sets the ID of
+// the blockchain the transaction is for, to which the transaction ID
+// commits in transactions of version NetworkVersion or later. See
+// opFinalize.
+func Network(id [32]byte) Option {
+	return Option{
+		apply: func(vm *VM) { vm.network = id },
+	}
+}
+
 // GetRunlimit causes the vm to write its ending runlimit to the given
 // pointer on exit.
 func GetRunlimit(runlimit *int64) Option {
@@ -105,9 +140,64 @@ func Resumer(f *func(rest []byte) error) Option {
 	}
 }
 
+// Recording holds what Record captures from a VM run.
+type Recording struct {
+	// Log is the transaction log as far as execution got. On
+	// success it is the same as the VM's Log.
+	Log []Tuple
+
+	// Steps lists every instruction executed, in order. On failure
+	// the last one is the instruction that failed, if any.
+	Steps []Step
+
+	// Err is the error returned by Validate, and Fault its
+	// FaultError, if any.
+	Err   error
+	Fault *FaultError
+}
+
+// Step is one instruction executed by a VM.
+type Step struct {
+	Depth  int    // nesting of the running program, 0 for the transaction program
+	Seed   []byte // seed of the running contract
+	PC     int64  // offset of the instruction in the running program
+	Opcode byte
+}
+
+// Record can be passed as an option to Validate. It fills in r with
+// the log and a trace of the run, including when the run fails, so
+// that a caller can see how far a failing transaction got and which
+// contract rejected it.
+func Record(r *Recording) Option {
+	return Option{
+		apply: func(vm *VM) {
+			vm.beforeStep = append(vm.beforeStep, func(vm *VM) {
+				r.Steps = append(r.Steps, Step{
+					Depth:  len(vm.runstack),
+					Seed:   vm.contract.seed,
+					PC:     vm.run.pc,
+					Opcode: vm.opcode,
+				})
+			})
+			vm.onExit = append(vm.onExit, func(vm *VM) {
+				r.Log = vm.Log
+				r.Err = vm.err
+				r.Fault = Fault(vm.err)
+			})
+		},
+	}
+}
+
 // Trace can be passed as an option to Validate. It causes a textual
 // execution trace to be written to the given io.Writer.
 func Trace(w io.Writer) Option {
+	return TraceNames(w, nil)
+}
+
+// TraceNames is like Trace, but shows contracts by the names that
+// name returns for their seeds (see contracts.Name) instead of in
+// hex.
+func TraceNames(w io.Writer, name func(seed []byte) string) Option {
 	return Option{
 		apply: func(vm *VM) {
 			var loglen int
@@ -117,20 +207,24 @@ func Trace(w io.Writer) Option {
 					fmt.Fprintf(w, "=> vm %d\n", len(vm.runstack))
 					lastRunstack = len(vm.runstack)
 				}
-				var name string
+				var opname string
 				switch {
 				case op.IsSmallIntOp(vm.opcode):
-					name = fmt.Sprintf("%d", vm.opcode-op.MinSmallInt)
+					opname = fmt.Sprintf("%d", vm.opcode-op.MinSmallInt)
 				case op.IsPushdataOp(vm.opcode):
-					name = fmt.Sprintf("pushdata%d", len(vm.data))
+					opname = fmt.Sprintf("pushdata%d", len(vm.data))
 				default:
-					name = op.Name(vm.opcode)
+					opname = op.Name(vm.opcode)
 				}
 				fmt.Fprintf(w, "vm %d pc %d limit %d ", len(vm.runstack), vm.run.pc, vm.runlimit)
 				if vm.contract != nil {
-					fmt.Fprintf(w, "contract %x ", vm.contract.seed)
+					if name != nil {
+						fmt.Fprintf(w, "contract %q ", name(vm.contract.seed))
+					} else {
+						fmt.Fprintf(w, "contract %x ", vm.contract.seed)
+					}
 				}
-				fmt.Fprintf(w, "%s (%02x)", name, vm.opcode)
+				fmt.Fprintf(w, "%s (%02x)", opname, vm.opcode)
 				if op.IsPushdataOp(vm.opcode) {
 					fmt.Fprintf(w, " %s", Bytes(vm.data))
 				}
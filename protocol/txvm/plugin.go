@@ -0,0 +1,139 @@
+package txvm
+
+import (
+	"fmt"
+	"sort"
+
+	"i10r.io/errors"
+)
+
+// Application-specific chains can add operations of their own
+// without changing the interpreter. A plugin operation is reached,
+// like the built-in operations added after version 3, through ext
+// with its selector on top of the stack, in a transaction of version
+// ExtOpsVersion or later. Plugin selectors are taken from a reserved
+// range, so they never collide with the selectors above.
+//
+// Every node on a chain must run with the same plugins, or they will
+// disagree about which transactions are valid. Plugins.Hash
+// fingerprints a set of plugins for comparison; see also package
+// consensus.
+const (
+	MinPluginSelector = 1 << 16
+	MaxPluginSelector = 1<<17 - 1
+)
+
+// ErrPlugin is returned when a plugin operation fails.
+var ErrPlugin = errorf("plugin operation failed")
+
+// PluginOp is an operation added by a plugin.
+type PluginOp struct {
+	// Selector selects the operation with ext. It must be from
+	// MinPluginSelector to MaxPluginSelector.
+	Selector int64
+
+	// Name identifies the operation and its behavior. It is part of
+	// the fingerprint of a set of plugins, so it should change
+	// whenever Func changes what it computes.
+	Name string
+
+	// In is the number of arguments the operation pops. They must
+	// be data items (not values or contracts).
+	In int
+
+	// Cost is the runlimit the operation consumes, besides the cost
+	// of ext itself and of creating its results.
+	Cost int64
+
+	// Func computes the operation's results from its arguments,
+	// which are in stack order: the topmost item is last. The
+	// results are pushed in order, so the last ends up on top. An
+	// error fails the transaction.
+	Func func(args []Data) ([]Data, error)
+}
+
+// Plugins is a validated set of plugin operations.
+type Plugins struct {
+	ops  map[Int]PluginOp
+	hash [32]byte
+}
+
+// NewPlugins checks that ops have distinct selectors in the plugin
+// range and returns them as a set that can be passed to Validate
+// with WithPlugins.
+func NewPlugins(ops ...PluginOp) (*Plugins, error) {
+	p := &Plugins{ops: make(map[Int]PluginOp, len(ops))}
+	for _, o := range ops {
+		switch {
+		case o.Selector < MinPluginSelector || o.Selector > MaxPluginSelector:
+			return nil, fmt.Errorf("plugin %s: selector %d out of range", o.Name, o.Selector)
+		case o.Name == "":
+			return nil, fmt.Errorf("plugin with selector %d has no name", o.Selector)
+		case o.In < 0 || o.Cost < 0:
+			return nil, fmt.Errorf("plugin %s: negative arity or cost", o.Name)
+		case o.Func == nil:
+			return nil, fmt.Errorf("plugin %s has no func", o.Name)
+		}
+		if prev, ok := p.ops[Int(o.Selector)]; ok {
+			return nil, fmt.Errorf("plugins %s and %s both have selector %d", prev.Name, o.Name, o.Selector)
+		}
+		p.ops[Int(o.Selector)] = o
+	}
+
+	sels := make([]int64, 0, len(p.ops))
+	for sel := range p.ops {
+		sels = append(sels, int64(sel))
+	}
+	sort.Slice(sels, func(i, j int) bool { return sels[i] < sels[j] })
+	var t Tuple
+	for _, sel := range sels {
+		o := p.ops[Int(sel)]
+		t = append(t, Tuple{Int(sel), Bytes(o.Name), Int(o.In), Int(o.Cost)})
+	}
+	p.hash = VMHash("Plugins", Encode(t))
+	return p, nil
+}
+
+// Hash returns a fingerprint of the selector, name, arity and cost of
+// each operation in p. A nil *Plugins has the hash of an empty set.
+func (p *Plugins) Hash() [32]byte {
+	if p == nil {
+		return VMHash("Plugins", Encode(Tuple{}))
+	}
+	return p.hash
+}
+
+// WithPlugins can be passed as an option to Validate. It makes the
+// operations in p available through ext.
+func WithPlugins(p *Plugins) Option {
+	return Option{
+		apply: func(vm *VM) { vm.plugins = p },
+	}
+}
+
+// plugin returns the plugin operation with the given selector, if
+// any, as a function on the VM.
+func (vm *VM) plugin(sel Int) (func(*VM), bool) {
+	if vm.plugins == nil {
+		return nil, false
+	}
+	o, ok := vm.plugins.ops[sel]
+	if !ok {
+		return nil, false
+	}
+	return func(vm *VM) {
+		vm.charge(o.Cost)
+		args := make([]Data, o.In)
+		for i := o.In - 1; i >= 0; i-- {
+			args[i] = vm.popData()
+		}
+		res, err := o.Func(args)
+		if err != nil {
+			panic(errors.Wrapf(ErrPlugin, "%s: %s", o.Name, err))
+		}
+		for _, d := range res {
+			vm.chargeCreate(d)
+			vm.push(d)
+		}
+	}, true
+}
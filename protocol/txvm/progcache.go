@@ -0,0 +1,165 @@
+package txvm
+
+import (
+	"container/list"
+	"sync"
+)
+
+// ProgramCache holds decoded contract programs, so that a program
+// run many times, such as the standard pay-to-pubkey contract of
+// every input, is decoded once: its instruction boundaries found
+// and its pushdata items made ready to push. Programs are keyed by
+// their bytes, and the least recently used are evicted to keep the
+// total size under a bound.
+//
+// Only programs run by call are cached; a transaction program is
+// run only once, and one run by exec is usually made on the fly.
+// Caching never changes the outcome of a run.
+//
+// A ProgramCache is safe for concurrent use, and can be shared by
+// any number of VMs.
+type ProgramCache struct {
+	maxBytes int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List // of *decodedProg, most recently used first
+	stats   ProgramCacheStats
+}
+
+// ProgramCacheStats describes the use of a ProgramCache.
+type ProgramCacheStats struct {
+	Hits      int64 `json:"hits"`      // runs of a cached program
+	Misses    int64 `json:"misses"`    // runs of a program not yet cached
+	Evictions int64 `json:"evictions"` // programs evicted
+	Uncached  int64 `json:"uncached"`  // runs of programs that could not be cached
+	Programs  int   `json:"programs"`  // programs now held
+	Bytes     int   `json:"bytes"`     // approximate memory they use
+}
+
+// NewProgramCache returns a cache holding decoded programs using up
+// to about maxBytes of memory.
+func NewProgramCache(maxBytes int) *ProgramCache {
+	return &ProgramCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Stats returns c's statistics.
+func (c *ProgramCache) Stats() ProgramCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := c.stats
+	st.Programs = len(c.entries)
+	return st
+}
+
+// WithProgramCache can be passed as an option to Validate. It causes
+// the programs of contracts called in the transaction to be taken
+// from, and added to, c.
+func WithProgramCache(c *ProgramCache) Option {
+	return Option{
+		apply: func(vm *VM) { vm.programs = c },
+	}
+}
+
+// get returns the decoded form of prog, decoding and adding it if
+// necessary. It returns nil for a program that does not decode
+// completely, or that is too large for c. Such a program is run the
+// usual way, failing if and when it reaches the bad instruction.
+func (c *ProgramCache) get(prog []byte) *decodedProg {
+	c.mu.Lock()
+	if e, ok := c.entries[string(prog)]; ok {
+		c.lru.MoveToFront(e)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return e.Value.(*decodedProg)
+	}
+	c.mu.Unlock()
+
+	// Decode outside the lock. Two VMs may decode the same program
+	// at once; the second to finish keeps the first's copy.
+	d := decodeProg(prog)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d == nil || d.size > c.maxBytes {
+		c.stats.Uncached++
+		return nil
+	}
+	if e, ok := c.entries[string(prog)]; ok {
+		c.lru.MoveToFront(e)
+		c.stats.Hits++
+		return e.Value.(*decodedProg)
+	}
+	c.stats.Misses++
+	c.entries[string(d.prog)] = c.lru.PushFront(d)
+	c.stats.Bytes += d.size
+	for c.stats.Bytes > c.maxBytes {
+		e := c.lru.Back()
+		old := c.lru.Remove(e).(*decodedProg)
+		delete(c.entries, string(old.prog))
+		c.stats.Bytes -= old.size
+		c.stats.Evictions++
+	}
+	return d
+}
+
+// decodedProg is a program split into its instructions.
+type decodedProg struct {
+	prog  []byte
+	insts []decodedInst
+
+	// at maps each offset in prog at which an instruction begins to
+	// 1 + its index in insts, and every other offset to 0. A jump
+	// can land between instructions; there the VM decodes as usual.
+	at []int32
+
+	size int // approximate memory used, key included
+
+	// canonical is true if every opcode is a minimal varint. If
+	// not, a VM with StrictEncoding does not use the decoded form.
+	canonical bool
+}
+
+type decodedInst struct {
+	opcode byte
+	n      int64
+	data   []byte
+	item   Item // for pushdata, Bytes(data), made once
+}
+
+// approximate sizes, in bytes, of a decodedProg and a decodedInst
+const (
+	decodedProgSize = 128
+	decodedInstSize = 64
+)
+
+func decodeProg(prog []byte) *decodedProg {
+	if len(prog) > 1<<31-1 {
+		return nil
+	}
+	// The cache's copy, so that later changes to the caller's slice
+	// cannot affect it.
+	prog = append([]byte(nil), prog...)
+	d := &decodedProg{prog: prog, at: make([]int32, len(prog)), canonical: true}
+	for pc := 0; pc < len(prog); {
+		opcode, data, n, err := decodeInst(prog[pc:])
+		if err != nil {
+			return nil
+		}
+		if n > 1 && !minimalVarint(prog[pc:], n-int64(len(data))) {
+			d.canonical = false
+		}
+		in := decodedInst{opcode: opcode, n: n, data: data}
+		if data != nil {
+			in.item = Bytes(data)
+		}
+		d.insts = append(d.insts, in)
+		d.at[pc] = int32(len(d.insts))
+		pc += int(n)
+	}
+	d.size = decodedProgSize + 2*len(prog) + 4*len(d.at) + decodedInstSize*len(d.insts)
+	return d
+}
@@ -0,0 +1,95 @@
+package txvm
+
+import (
+	"sync"
+
+	"i10r.io/crypto/ed25519"
+)
+
+// SigCache holds the Ed25519 work of checksig for reuse across
+// transactions: each public key is decompressed once, into an
+// ed25519.VerifierKey, and each signature once found valid is not
+// checked again. It is meant for a batch of transactions validated
+// together, which often share keys and sometimes signatures, and it
+// grows without bound, so it should not outlive the batch.
+//
+// Signatures are not verified as a batch: a batch equation can
+// accept a signature that a single check would reject, and checksig
+// must give the same answer however it is run. Caching never
+// changes the outcome of a run.
+//
+// A SigCache is safe for concurrent use, and can be shared by any
+// number of VMs.
+type SigCache struct {
+	mu    sync.Mutex
+	keys  map[string]*ed25519.VerifierKey // nil for a key that does not decode
+	valid map[string]bool                 // pubkey, sig, msg
+	stats SigCacheStats
+}
+
+// SigCacheStats describes the use of a SigCache.
+type SigCacheStats struct {
+	Keys     int64 `json:"keys"`     // public keys decompressed
+	KeyHits  int64 `json:"key_hits"` // checks by a key already decompressed
+	Verified int64 `json:"verified"` // signatures found valid
+	SigHits  int64 `json:"sig_hits"` // checks of a signature already found valid
+}
+
+// NewSigCache returns an empty SigCache.
+func NewSigCache() *SigCache {
+	return &SigCache{
+		keys:  make(map[string]*ed25519.VerifierKey),
+		valid: make(map[string]bool),
+	}
+}
+
+// Stats returns c's statistics.
+func (c *SigCache) Stats() SigCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// WithSigCache can be passed as an option to Validate. It causes
+// Ed25519 signature checks to use, and add to, c.
+func WithSigCache(c *SigCache) Option {
+	return Option{
+		apply: func(vm *VM) { vm.sigs = c },
+	}
+}
+
+// verify reports whether sig is a valid signature of msg by pubkey,
+// which must have the sizes checkEd25519 requires.
+func (c *SigCache) verify(msg, pubkey, sig []byte) bool {
+	key := string(pubkey) + string(sig) + string(msg)
+
+	c.mu.Lock()
+	if c.valid[key] {
+		c.stats.SigHits++
+		c.mu.Unlock()
+		return true
+	}
+	vk, ok := c.keys[string(pubkey)]
+	if ok {
+		c.stats.KeyHits++
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		// As in ProgramCache.get, two VMs may expand the same key at
+		// once; either copy will do.
+		vk, _ = ed25519.NewVerifierKey(pubkey)
+		c.mu.Lock()
+		c.keys[string(pubkey)] = vk
+		c.stats.Keys++
+		c.mu.Unlock()
+	}
+	if vk == nil || !vk.Verify(msg, sig) {
+		return false
+	}
+	c.mu.Lock()
+	c.valid[key] = true
+	c.stats.Verified++
+	c.mu.Unlock()
+	return true
+}
@@ -21,9 +21,19 @@ func opFinalize(vm *VM) {
 	}
 
 	vm.TxID = merkle.Root(items)
+	if vm.txVersion >= NetworkVersion {
+		vm.TxID = VMHash("NetworkTxID", append(vm.network[:], vm.TxID[:]...))
+	}
 	vm.runHooks(vm.onFinalize)
 }
 
+// NetworkVersion is the first transaction version whose ID commits
+// to the blockchain the transaction is for, given by the Network
+// option, as well as to its log. Since signatures in the standard
+// contracts cover the transaction ID, such a transaction cannot be
+// replayed on another blockchain.
+const NetworkVersion = 5
+
 func opTxID(vm *VM) {
 	if !vm.Finalized {
 		panic(errors.Wrap(ErrUnfinalized, "txid"))
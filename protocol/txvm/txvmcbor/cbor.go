@@ -0,0 +1,373 @@
+/*
+Package txvmcbor encodes txvm data as deterministic CBOR (RFC 8949),
+for exchanging structured data with systems outside txvm and for
+hashing it.
+
+Ints encode as CBOR integers, Bytes as byte strings, and Tuples as
+arrays. For data that does not come from txvm, the package also
+handles text strings (Text), maps (Map), and arrays containing them
+(Array).
+
+Encoding follows the core deterministic encoding requirements of RFC
+8949 section 4.2.1: arguments are as short as possible, lengths are
+definite, and map entries are sorted by the bytewise order of their
+encoded keys. Decoding is strict: it accepts only the encoding Marshal
+would produce, rejecting anything non-minimal or unsorted, duplicate
+map keys, tags, floating-point and simple values, and trailing bytes.
+So two parties who agree on a value always produce byte-identical
+encodings of it, and an encoding can be hashed as a commitment to the
+value.
+*/
+package txvmcbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/txvm"
+)
+
+// A Value is a txvm.Int, txvm.Bytes, txvm.Tuple, Text, Array, or Map.
+type Value interface{}
+
+// Text is a CBOR text string. It must be valid UTF-8.
+type Text string
+
+// Array is a CBOR array whose items are not all txvm data.
+type Array []Value
+
+// Map is a CBOR map. Keys must be txvm.Int, txvm.Bytes, or Text, and
+// must be distinct. Marshal sorts the entries.
+type Map []MapEntry
+
+// MapEntry is an entry of a Map.
+type MapEntry struct {
+	Key, Value Value
+}
+
+// Get returns the value for key in m, or nil if there is none.
+func (m Map) Get(key Value) Value {
+	k, err := Marshal(key)
+	if err != nil {
+		return nil
+	}
+	for _, e := range m {
+		if ek, err := Marshal(e.Key); err == nil && bytes.Equal(ek, k) {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+// Errors returned by Unmarshal. ErrNonCanonical covers any encoding
+// that is well-formed CBOR but not the deterministic encoding.
+var (
+	ErrTruncated    = errors.New("truncated CBOR")
+	ErrNonCanonical = errors.New("non-canonical CBOR")
+	ErrUnsupported  = errors.New("unsupported CBOR item")
+	ErrDuplicateKey = errors.New("duplicate CBOR map key")
+	ErrTrailing     = errors.New("trailing bytes after CBOR item")
+	ErrDepth        = errors.New("CBOR nested too deeply")
+)
+
+// MaxDepth is the deepest nesting of arrays and maps Unmarshal
+// accepts.
+const MaxDepth = 256
+
+// CBOR major types.
+const (
+	majorUint  = 0
+	majorNint  = 1
+	majorBytes = 2
+	majorText  = 3
+	majorArray = 4
+	majorMap   = 5
+)
+
+// Encode returns the deterministic encoding of d.
+func Encode(d txvm.Data) []byte {
+	var buf bytes.Buffer
+	encodeData(&buf, d)
+	return buf.Bytes()
+}
+
+func encodeData(w *bytes.Buffer, d txvm.Data) {
+	switch d := d.(type) {
+	case txvm.Int:
+		encodeInt(w, int64(d))
+	case txvm.Bytes:
+		writeHead(w, majorBytes, uint64(len(d)))
+		w.Write(d)
+	case txvm.Tuple:
+		writeHead(w, majorArray, uint64(len(d)))
+		for _, item := range d {
+			encodeData(w, item)
+		}
+	}
+}
+
+// Marshal returns the deterministic encoding of v. It fails if v
+// contains a type other than those of Value, invalid UTF-8 in a Text,
+// or a Map with an invalid or duplicate key.
+func Marshal(v Value) ([]byte, error) {
+	var buf bytes.Buffer
+	err := marshal(&buf, v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshal(w *bytes.Buffer, v Value) error {
+	switch v := v.(type) {
+	case txvm.Int, txvm.Bytes, txvm.Tuple:
+		encodeData(w, v.(txvm.Data))
+	case Text:
+		if !utf8.ValidString(string(v)) {
+			return errors.WithDetail(ErrUnsupported, "invalid UTF-8 in text string")
+		}
+		writeHead(w, majorText, uint64(len(v)))
+		w.WriteString(string(v))
+	case Array:
+		writeHead(w, majorArray, uint64(len(v)))
+		for _, item := range v {
+			if err := marshal(w, item); err != nil {
+				return err
+			}
+		}
+	case Map:
+		type entry struct{ key, val []byte }
+		entries := make([]entry, len(v))
+		for i, e := range v {
+			switch e.Key.(type) {
+			case txvm.Int, txvm.Bytes, Text:
+			default:
+				return errors.WithDetailf(ErrUnsupported, "map key of type %T", e.Key)
+			}
+			k, err := Marshal(e.Key)
+			if err != nil {
+				return err
+			}
+			val, err := Marshal(e.Value)
+			if err != nil {
+				return err
+			}
+			entries[i] = entry{k, val}
+		}
+		sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i].key, entries[j].key) < 0 })
+		for i := 1; i < len(entries); i++ {
+			if bytes.Equal(entries[i-1].key, entries[i].key) {
+				return errors.WithDetailf(ErrDuplicateKey, "key %x", entries[i].key)
+			}
+		}
+		writeHead(w, majorMap, uint64(len(entries)))
+		for _, e := range entries {
+			w.Write(e.key)
+			w.Write(e.val)
+		}
+	default:
+		return errors.WithDetailf(ErrUnsupported, "value of type %T", v)
+	}
+	return nil
+}
+
+func encodeInt(w *bytes.Buffer, n int64) {
+	if n >= 0 {
+		writeHead(w, majorUint, uint64(n))
+	} else {
+		writeHead(w, majorNint, uint64(-1-n))
+	}
+}
+
+// writeHead writes the initial byte and argument of an item, in the
+// shortest form.
+func writeHead(w *bytes.Buffer, major byte, arg uint64) {
+	m := major << 5
+	var buf [8]byte
+	switch {
+	case arg < 24:
+		w.WriteByte(m | byte(arg))
+	case arg <= math.MaxUint8:
+		w.WriteByte(m | 24)
+		w.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		w.WriteByte(m | 25)
+		binary.BigEndian.PutUint16(buf[:], uint16(arg))
+		w.Write(buf[:2])
+	case arg <= math.MaxUint32:
+		w.WriteByte(m | 26)
+		binary.BigEndian.PutUint32(buf[:], uint32(arg))
+		w.Write(buf[:4])
+	default:
+		w.WriteByte(m | 27)
+		binary.BigEndian.PutUint64(buf[:], arg)
+		w.Write(buf[:])
+	}
+}
+
+// Decode decodes the deterministic encoding of txvm data. It fails
+// for any encoding Encode would not produce, including one containing
+// text strings or maps.
+func Decode(b []byte) (txvm.Data, error) {
+	v, err := Unmarshal(b)
+	if err != nil {
+		return nil, err
+	}
+	d, ok := v.(txvm.Data)
+	if !ok {
+		return nil, errors.WithDetailf(ErrUnsupported, "%T is not txvm data", v)
+	}
+	return d, nil
+}
+
+// Unmarshal decodes a deterministic encoding. Arrays whose items are
+// all txvm data decode as txvm.Tuple, and other arrays as Array.
+// Decoded byte strings refer to b.
+func Unmarshal(b []byte) (Value, error) {
+	d := decoder{b: b}
+	v, err := d.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.b) > 0 {
+		return nil, errors.WithDetailf(ErrTrailing, "%d bytes", len(d.b))
+	}
+	return v, nil
+}
+
+type decoder struct {
+	b []byte
+}
+
+func (d *decoder) head() (major byte, arg uint64, err error) {
+	if len(d.b) == 0 {
+		return 0, 0, ErrTruncated
+	}
+	major, info := d.b[0]>>5, d.b[0]&31
+	d.b = d.b[1:]
+	var n int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		n = 1 << (info - 24)
+	default:
+		// Reserved values and indefinite lengths.
+		return 0, 0, errors.WithDetailf(ErrUnsupported, "additional information %d", info)
+	}
+	if len(d.b) < n {
+		return 0, 0, ErrTruncated
+	}
+	for _, c := range d.b[:n] {
+		arg = arg<<8 | uint64(c)
+	}
+	d.b = d.b[n:]
+	// The argument must not fit in a shorter form.
+	var min uint64 = 24
+	if n > 1 {
+		min = 1 << (4 * uint(n))
+	}
+	if arg < min {
+		return 0, 0, errors.WithDetailf(ErrNonCanonical, "%d-byte argument %d", n, arg)
+	}
+	return major, arg, nil
+}
+
+func (d *decoder) value(depth int) (Value, error) {
+	major, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case majorUint:
+		if arg > math.MaxInt64 {
+			return nil, errors.WithDetailf(ErrUnsupported, "integer %d out of range", arg)
+		}
+		return txvm.Int(arg), nil
+	case majorNint:
+		if arg > math.MaxInt64 {
+			return nil, errors.WithDetailf(ErrUnsupported, "integer -1-%d out of range", arg)
+		}
+		return txvm.Int(-1 - int64(arg)), nil
+	case majorBytes, majorText:
+		if arg > uint64(len(d.b)) {
+			return nil, ErrTruncated
+		}
+		s := d.b[:arg:arg]
+		d.b = d.b[arg:]
+		if major == majorBytes {
+			return txvm.Bytes(s), nil
+		}
+		if !utf8.Valid(s) {
+			return nil, errors.WithDetail(ErrUnsupported, "invalid UTF-8 in text string")
+		}
+		return Text(s), nil
+	case majorArray:
+		if depth >= MaxDepth {
+			return nil, ErrDepth
+		}
+		// Each item takes at least a byte.
+		if arg > uint64(len(d.b)) {
+			return nil, ErrTruncated
+		}
+		items := make(Array, arg)
+		allData := true
+		for i := range items {
+			if items[i], err = d.value(depth + 1); err != nil {
+				return nil, err
+			}
+			_, ok := items[i].(txvm.Data)
+			allData = allData && ok
+		}
+		if !allData {
+			return items, nil
+		}
+		tup := make(txvm.Tuple, len(items))
+		for i, item := range items {
+			tup[i] = item.(txvm.Data)
+		}
+		return tup, nil
+	case majorMap:
+		if depth >= MaxDepth {
+			return nil, ErrDepth
+		}
+		if arg > uint64(len(d.b))/2 {
+			return nil, ErrTruncated
+		}
+		m := make(Map, arg)
+		var prev []byte
+		for i := range m {
+			start := d.b
+			key, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case txvm.Int, txvm.Bytes, Text:
+			default:
+				return nil, errors.WithDetailf(ErrUnsupported, "map key of type %T", key)
+			}
+			enc := start[:len(start)-len(d.b)]
+			if i > 0 {
+				switch c := bytes.Compare(prev, enc); {
+				case c == 0:
+					return nil, errors.WithDetailf(ErrDuplicateKey, "key %x", enc)
+				case c > 0:
+					return nil, errors.WithDetailf(ErrNonCanonical, "map key %x out of order", enc)
+				}
+			}
+			prev = enc
+			val, err := d.value(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[i] = MapEntry{key, val}
+		}
+		return m, nil
+	}
+	return nil, errors.WithDetailf(ErrUnsupported, "major type %d", major)
+}
@@ -0,0 +1,131 @@
+package txvmcbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/txvm"
+)
+
+func TestEncode(t *testing.T) {
+	cases := []struct {
+		d    txvm.Data
+		want string
+	}{
+		// From RFC 8949 appendix A.
+		{txvm.Int(0), "00"},
+		{txvm.Int(23), "17"},
+		{txvm.Int(24), "1818"},
+		{txvm.Int(1000), "1903e8"},
+		{txvm.Int(1000000), "1a000f4240"},
+		{txvm.Int(1000000000000), "1b000000e8d4a51000"},
+		{txvm.Int(-1), "20"},
+		{txvm.Int(-1000), "3903e7"},
+		{txvm.Int(-9223372036854775808), "3b7fffffffffffffff"},
+		{txvm.Bytes{}, "40"},
+		{txvm.Bytes{1, 2, 3, 4}, "4401020304"},
+		{txvm.Tuple{}, "80"},
+		{txvm.Tuple{txvm.Int(1), txvm.Tuple{txvm.Int(2), txvm.Int(3)}}, "8201820203"},
+	}
+	for _, c := range cases {
+		got := Encode(c.d)
+		if hex.EncodeToString(got) != c.want {
+			t.Errorf("Encode(%s) = %x, want %s", c.d, got, c.want)
+		}
+		back, err := Decode(got)
+		if err != nil {
+			t.Errorf("Decode(%x): %v", got, err)
+			continue
+		}
+		if !bytes.Equal(txvm.Encode(back), txvm.Encode(c.d)) {
+			t.Errorf("Decode(%x) = %s, want %s", got, back, c.d)
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	m := Map{
+		{Text("name"), Text("gold")},
+		{Text("decimals"), txvm.Int(2)},
+		{txvm.Int(10), txvm.Bytes("x")},
+	}
+	got, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Keys sort by encoding: 10 (0a), "name" (646e...), "decimals" (68...).
+	want := "a30a4178646e616d6564676f6c6468646563696d616c7302"
+	if hex.EncodeToString(got) != want {
+		t.Errorf("Marshal = %x, want %s", got, want)
+	}
+	// Reordering the entries does not change the encoding.
+	m[0], m[2] = m[2], m[0]
+	if again, _ := Marshal(m); !bytes.Equal(again, got) {
+		t.Errorf("Marshal of reordered map = %x, want %x", again, got)
+	}
+	v, err := Unmarshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := v.(Map).Get(Text("decimals")); d != txvm.Int(2) {
+		t.Errorf("decimals = %v, want 2", d)
+	}
+	arr, err := Unmarshal(append([]byte{0x82, 0x01}, got...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := arr.(Array); !ok {
+		t.Errorf("array containing a map decodes as %T, want Array", arr)
+	}
+
+	if _, err := Marshal(Map{{Text("a"), txvm.Int(1)}, {Text("a"), txvm.Int(2)}}); errors.Root(err) != ErrDuplicateKey {
+		t.Errorf("Marshal with duplicate keys: error %v, want ErrDuplicateKey", err)
+	}
+	if _, err := Marshal(Map{{txvm.Tuple{}, txvm.Int(1)}}); errors.Root(err) != ErrUnsupported {
+		t.Errorf("Marshal with tuple key: error %v, want ErrUnsupported", err)
+	}
+	if !reflect.DeepEqual(v.(Map).Get(Text("missing")), nil) {
+		t.Error("Get of a missing key is not nil")
+	}
+}
+
+func TestStrict(t *testing.T) {
+	cases := []struct {
+		hex string
+		err error
+	}{
+		{"1817", ErrNonCanonical},           // 23 in two bytes
+		{"190017", ErrNonCanonical},         // 23 in three bytes
+		{"1a0000ffff", ErrNonCanonical},     // 65535 in five bytes
+		{"5f4101ff", ErrUnsupported},        // indefinite-length bytes
+		{"a2616101616102", ErrDuplicateKey}, // {"a": 1, "a": 2}
+		{"a2616201616101", ErrNonCanonical}, // {"b": 1, "a": 1}
+		{"c11a514b67b0", ErrUnsupported},    // tag 1
+		{"f5", ErrUnsupported},              // true
+		{"f93c00", ErrUnsupported},          // 1.0
+		{"1bffffffffffffffff", ErrUnsupported},
+		{"0000", ErrTrailing},
+		{"8201", ErrTruncated},
+		{"43010203ff", ErrTrailing},
+		{"9bffffffffffffffff", ErrTruncated},
+		{"62c328", ErrUnsupported}, // invalid UTF-8
+	}
+	for _, c := range cases {
+		b, _ := hex.DecodeString(c.hex)
+		_, err := Unmarshal(b)
+		if errors.Root(err) != c.err {
+			t.Errorf("Unmarshal(%s): error %v, want %v", c.hex, err, c.err)
+		}
+	}
+
+	deep := bytes.Repeat([]byte{0x81}, MaxDepth+1)
+	if _, err := Unmarshal(append(deep, 0)); errors.Root(err) != ErrDepth {
+		t.Errorf("Unmarshal(deep): error %v, want ErrDepth", err)
+	}
+	if _, err := Decode([]byte{0x61, 'a'}); errors.Root(err) != ErrUnsupported {
+		t.Errorf("Decode(text): error %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,232 @@
+// Package txvmcov collects instruction-level coverage of txvm
+// contract programs.
+//
+// A Profile is attached to each VM run with its Option method. It
+// records how many times each instruction of each program was
+// executed, across as many runs as the caller likes (typically a
+// whole test suite). Programs whose assembly source was registered
+// with AddSource can then be reported per source line, in LCOV or
+// HTML form, showing contract authors which clauses their tests never
+// reach.
+package txvmcov
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+)
+
+// Profile accumulates execution counts. It is safe for concurrent
+// use.
+type Profile struct {
+	mu      sync.Mutex
+	hits    map[string]map[int64]int // program -> pc -> count
+	sources []*source
+}
+
+type source struct {
+	name string
+	text string
+	m    *asm.SourceMap
+}
+
+// Line is the coverage of one line of a registered source.
+type Line struct {
+	Num  int // 1-based
+	Text string
+
+	// Insts is the number of instructions starting on this line.
+	// Lines with no instructions (blank lines, comments) are not
+	// counted as covered or uncovered.
+	Insts int
+
+	// Covered is the number of those instructions executed at
+	// least once.
+	Covered int
+
+	// Hits is the smallest execution count of any instruction on
+	// the line, so a line counts as covered only if all of its
+	// instructions ran.
+	Hits int
+}
+
+// New returns an empty Profile.
+func New() *Profile {
+	return &Profile{hits: make(map[string]map[int64]int)}
+}
+
+// Option returns a txvm.Option that records the instructions
+// executed by a VM into p.
+func (p *Profile) Option() txvm.Option {
+	return txvm.BeforeStep(p.step)
+}
+
+func (p *Profile) step(vm *txvm.VM) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prog := string(vm.Program())
+	m := p.hits[prog]
+	if m == nil {
+		m = make(map[int64]int)
+		p.hits[prog] = m
+	}
+	m[vm.PC()]++
+}
+
+// Hits reports how many times the instruction at offset pc of prog
+// has been executed.
+func (p *Profile) Hits(prog []byte, pc int64) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.hits[string(prog)][pc]
+}
+
+// AddSource assembles src and registers it, under name, for
+// reporting. It returns the assembled program. Any program quoted
+// within src is reported along with it when executed as a contract
+// or with exec.
+func (p *Profile) AddSource(name, src string) ([]byte, error) {
+	m, err := asm.AssembleSourceMap(src)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.sources = append(p.sources, &source{name: name, text: src, m: m})
+	p.mu.Unlock()
+	return m.Prog, nil
+}
+
+// Lines returns the per-line coverage of the source registered as
+// name, or nil if there is none.
+func (p *Profile) Lines(name string) []Line {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.sources {
+		if s.name == name {
+			return p.lines(s)
+		}
+	}
+	return nil
+}
+
+func (p *Profile) lines(s *source) []Line {
+	texts := strings.Split(s.text, "\n")
+	lines := make([]Line, len(texts))
+	for i, t := range texts {
+		lines[i] = Line{Num: i + 1, Text: t}
+	}
+
+	// starts[i] is the source offset at which line i+1 begins.
+	starts := make([]int, len(texts))
+	off := 0
+	for i, t := range texts {
+		starts[i] = off
+		off += len(t) + 1
+	}
+
+	var walk func(*asm.SourceMap)
+	walk = func(m *asm.SourceMap) {
+		hits := p.hits[string(m.Prog)]
+		for pc, srcOff := range m.Pos {
+			i := sort.SearchInts(starts, srcOff+1) - 1
+			l := &lines[i]
+			n := hits[pc]
+			if l.Insts == 0 || n < l.Hits {
+				l.Hits = n
+			}
+			l.Insts++
+			if n > 0 {
+				l.Covered++
+			}
+		}
+		for _, q := range m.Quoted {
+			walk(q)
+		}
+	}
+	walk(s.m)
+	return lines
+}
+
+// WriteLCOV writes the coverage of every registered source to w in
+// LCOV tracefile format, with each source's name as its SF record.
+func (p *Profile) WriteLCOV(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.sources {
+		var found, hit int
+		_, err := fmt.Fprintf(w, "TN:\nSF:%s\n", s.name)
+		if err != nil {
+			return err
+		}
+		for _, l := range p.lines(s) {
+			if l.Insts == 0 {
+				continue
+			}
+			found++
+			if l.Hits > 0 {
+				hit++
+			}
+			_, err = fmt.Fprintf(w, "DA:%d,%d\n", l.Num, l.Hits)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprintf(w, "LF:%d\nLH:%d\nend_of_record\n", found, hit)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type htmlSource struct {
+	Name  string
+	Lines []Line
+}
+
+var htmlTemplate = template.Must(template.New("cov").Funcs(template.FuncMap{
+	"class": func(l Line) string {
+		switch {
+		case l.Insts == 0:
+			return ""
+		case l.Covered == l.Insts:
+			return "cov"
+		case l.Covered == 0:
+			return "uncov"
+		}
+		return "part"
+	},
+	"html": template.HTMLEscapeString,
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>txvm coverage</title>
+<style>
+pre { font-family: monospace; }
+.cov { background: #cfc; }
+.uncov { background: #fcc; }
+.part { background: #ffc; }
+.n { color: #888; display: inline-block; width: 4em; }
+</style></head><body>
+{{range .}}<h2>{{html .Name}}</h2>
+<pre>{{range .Lines}}<span class="{{class .}}"><span class="n">{{.Num}} {{if .Insts}}{{.Hits}}{{end}}</span>{{html .Text}}</span>
+{{end}}</pre>
+{{end}}</body></html>
+`))
+
+// WriteHTML writes an HTML page to w showing every registered
+// source, with lines colored according to whether all, some, or none
+// of their instructions were executed.
+func (p *Profile) WriteHTML(w io.Writer) error {
+	p.mu.Lock()
+	var srcs []htmlSource
+	for _, s := range p.sources {
+		srcs = append(srcs, htmlSource{Name: s.name, Lines: p.lines(s)})
+	}
+	p.mu.Unlock()
+	return htmlTemplate.Execute(w, srcs)
+}
@@ -0,0 +1,71 @@
+package txvmcov
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"i10r.io/protocol/txvm"
+)
+
+const testSrc = `1 jumpif:$a
+2 drop
+$a
+[3 drop] exec
+[4 drop]
+drop`
+
+func TestProfile(t *testing.T) {
+	p := New()
+	prog, err := p.AddSource("test.asm", testSrc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = txvm.Validate(prog, 3, 10000, p.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ insts, covered, hits int }{
+		{2, 2, 1}, // 1 jumpif:$a
+		{2, 0, 0}, // 2 drop
+		{0, 0, 0}, // $a
+		{4, 4, 1}, // [3 drop] exec
+		{3, 1, 0}, // [4 drop]
+		{1, 1, 1}, // drop
+	}
+	lines := p.Lines("test.asm")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(want))
+	}
+	for i, l := range lines {
+		w := want[i]
+		if l.Insts != w.insts || l.Covered != w.covered || l.Hits != w.hits {
+			t.Errorf("line %d (%q): got %d/%d instructions covered, %d hits; want %d/%d, %d hits",
+				l.Num, l.Text, l.Covered, l.Insts, l.Hits, w.covered, w.insts, w.hits)
+		}
+	}
+
+	_, err = txvm.Validate(prog, 3, 10000, p.Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lcov bytes.Buffer
+	err = p.WriteLCOV(&lcov)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLCOV := "TN:\nSF:test.asm\nDA:1,2\nDA:2,0\nDA:4,2\nDA:5,0\nDA:6,2\nLF:5\nLH:3\nend_of_record\n"
+	if lcov.String() != wantLCOV {
+		t.Errorf("got LCOV:\n%s\nwant:\n%s", lcov.String(), wantLCOV)
+	}
+
+	var html bytes.Buffer
+	err = p.WriteHTML(&html)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.String(), `class="uncov"`) || !strings.Contains(html.String(), `class="part"`) {
+		t.Errorf("HTML report lacks uncovered or partially covered lines:\n%s", html.String())
+	}
+}
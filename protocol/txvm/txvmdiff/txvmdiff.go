@@ -0,0 +1,154 @@
+/*
+Package txvmdiff runs transaction programs against two VM
+implementations and reports where their results differ.
+
+It is meant for de-risking changes to the VM: an opcode refactor or a
+change to the cost table should leave the finalized transaction ID,
+the log, and (for pure refactors) the runlimit consumed exactly as
+they were. Running a corpus of transactions through both the current
+VM and a reference build shows any such change before it reaches a
+network.
+
+A reference VM can be supplied as any Engine. Package txvmref is
+one, written independently of package txvm. LoadPlugin loads one
+from a Go plugin, which lets the reference be built from an older
+checkout. Because a plugin may not contain a different version of a
+package the host program also links, the reference copy of txvm must
+live at a different import path (for example, a vendored snapshot
+under the plugin's own directory).
+*/
+package txvmdiff
+
+import (
+	"bytes"
+	"fmt"
+	"plugin"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/txvm"
+)
+
+// Outcome is the observable result of running a transaction
+// program.
+type Outcome struct {
+	Finalized bool
+	TxID      [32]byte
+	Log       [][]byte // each entry encoded with txvm.Encode
+	Runlimit  int64    // remaining after the run
+	Err       error
+}
+
+// Engine runs transaction programs.
+type Engine interface {
+	Run(prog []byte, version, runlimit int64) *Outcome
+}
+
+// EngineFunc is a function usable as an Engine.
+type EngineFunc func(prog []byte, version, runlimit int64) *Outcome
+
+// Run implements Engine.
+func (f EngineFunc) Run(prog []byte, version, runlimit int64) *Outcome {
+	return f(prog, version, runlimit)
+}
+
+// Current is the Engine implemented by this build of package txvm.
+var Current Engine = EngineFunc(runCurrent)
+
+func runCurrent(prog []byte, version, runlimit int64) *Outcome {
+	out := new(Outcome)
+	vm, err := txvm.Validate(prog, version, runlimit)
+	out.Err = err
+	if vm != nil {
+		out.Finalized = vm.Finalized
+		out.TxID = vm.TxID
+		out.Runlimit = vm.Runlimit()
+		for _, entry := range vm.Log {
+			out.Log = append(out.Log, txvm.Encode(entry))
+		}
+	}
+	return out
+}
+
+// PluginSymbol is the name of the function LoadPlugin looks up. It
+// must have the type of ValidateFunc, which mentions only builtin
+// types so that the plugin need not share any package with its host.
+const PluginSymbol = "Validate"
+
+// ValidateFunc is the type of a plugin's Validate function.
+type ValidateFunc = func(prog []byte, version, runlimit int64) (finalized bool, txid [32]byte, log [][]byte, remaining int64, err error)
+
+// LoadPlugin opens the Go plugin at path and returns an Engine that
+// calls its Validate function.
+func LoadPlugin(path string) (Engine, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening plugin %s", path)
+	}
+	sym, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, errors.Wrapf(err, "plugin %s", path)
+	}
+	var f ValidateFunc
+	switch s := sym.(type) {
+	case ValidateFunc:
+		f = s
+	case *ValidateFunc: // a variable rather than a function
+		f = *s
+	default:
+		return nil, fmt.Errorf("plugin %s: %s has type %T", path, PluginSymbol, sym)
+	}
+	return EngineFunc(func(prog []byte, version, runlimit int64) *Outcome {
+		var out Outcome
+		out.Finalized, out.TxID, out.Log, out.Runlimit, out.Err = f(prog, version, runlimit)
+		return &out
+	}), nil
+}
+
+// Diff is a single difference between two outcomes.
+type Diff struct {
+	Field string // Finalized, TxID, Log, Runlimit, or Err
+	A, B  string
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("%s: %s vs. %s", d.Field, d.A, d.B)
+}
+
+// Compare runs prog on a and b and returns their outcomes along with
+// the differences between them. The runlimit consumed is compared
+// only if compareRunlimit is true, since a cost-table change is
+// expected to alter it.
+func Compare(a, b Engine, prog []byte, version, runlimit int64, compareRunlimit bool) (outA, outB *Outcome, diffs []Diff) {
+	outA = a.Run(prog, version, runlimit)
+	outB = b.Run(prog, version, runlimit)
+	return outA, outB, Outcomes(outA, outB, compareRunlimit)
+}
+
+// Outcomes returns the differences between two outcomes. Errors are
+// compared only by whether each is nil, since two implementations
+// need not phrase the same failure the same way.
+func Outcomes(a, b *Outcome, compareRunlimit bool) []Diff {
+	var diffs []Diff
+	if a.Finalized != b.Finalized {
+		diffs = append(diffs, Diff{"Finalized", fmt.Sprint(a.Finalized), fmt.Sprint(b.Finalized)})
+	}
+	if a.TxID != b.TxID {
+		diffs = append(diffs, Diff{"TxID", fmt.Sprintf("%x", a.TxID[:]), fmt.Sprintf("%x", b.TxID[:])})
+	}
+	if len(a.Log) != len(b.Log) {
+		diffs = append(diffs, Diff{"Log", fmt.Sprintf("%d entries", len(a.Log)), fmt.Sprintf("%d entries", len(b.Log))})
+	} else {
+		for i := range a.Log {
+			if !bytes.Equal(a.Log[i], b.Log[i]) {
+				diffs = append(diffs, Diff{fmt.Sprintf("Log[%d]", i), fmt.Sprintf("%x", a.Log[i]), fmt.Sprintf("%x", b.Log[i])})
+			}
+		}
+	}
+	if compareRunlimit && a.Runlimit != b.Runlimit {
+		diffs = append(diffs, Diff{"Runlimit", fmt.Sprint(a.Runlimit), fmt.Sprint(b.Runlimit)})
+	}
+	if (a.Err == nil) != (b.Err == nil) {
+		diffs = append(diffs, Diff{"Err", fmt.Sprint(a.Err), fmt.Sprint(b.Err)})
+	}
+	return diffs
+}
@@ -0,0 +1,52 @@
+package txvmdiff
+
+import (
+	"testing"
+
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/txvmtest"
+)
+
+func TestCompare(t *testing.T) {
+	prog, err := asm.Assemble(txvmtest.SimplePayment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, diffs := Compare(Current, Current, prog, 3, 100000, true)
+	if len(diffs) != 0 {
+		t.Errorf("current VM differs from itself: %v", diffs)
+	}
+
+	costlier := EngineFunc(func(prog []byte, version, runlimit int64) *Outcome {
+		out := Current.Run(prog, version, runlimit)
+		out.Runlimit--
+		return out
+	})
+	_, _, diffs = Compare(Current, costlier, prog, 3, 100000, false)
+	if len(diffs) != 0 {
+		t.Errorf("got diffs %v ignoring runlimit, want none", diffs)
+	}
+	_, _, diffs = Compare(Current, costlier, prog, 3, 100000, true)
+	if len(diffs) != 1 || diffs[0].Field != "Runlimit" {
+		t.Errorf("got diffs %v, want a Runlimit diff", diffs)
+	}
+
+	broken := EngineFunc(func(prog []byte, version, runlimit int64) *Outcome {
+		out := Current.Run(prog, version, runlimit)
+		out.Log[1] = append([]byte{}, out.Log[1]...)
+		out.Log[1][0] ^= 1
+		out.TxID[0] ^= 1
+		return out
+	})
+	_, _, diffs = Compare(Current, broken, prog, 3, 100000, false)
+	if len(diffs) != 2 || diffs[0].Field != "TxID" || diffs[1].Field != "Log[1]" {
+		t.Errorf("got diffs %v, want TxID and Log[1]", diffs)
+	}
+}
+
+func TestLoadPluginMissing(t *testing.T) {
+	_, err := LoadPlugin("/nonexistent/plugin.so")
+	if err == nil {
+		t.Error("LoadPlugin of a missing file succeeded")
+	}
+}
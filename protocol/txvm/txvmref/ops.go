@@ -0,0 +1,434 @@
+package txvmref
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/big"
+
+	"i10r.io/crypto/sha3"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+)
+
+// ops holds the instructions other than small ints, pushdata, and
+// jumpif, which exec handles itself because jumpif needs the program
+// counter. (It is set in init, since exec and call refer to it.)
+var ops map[byte]func(m *machine)
+
+func init() {
+	ops = map[byte]func(m *machine){
+		op.Int: func(m *machine) {
+			a := m.popBytes()
+			n, l := binary.Uvarint(a)
+			if l <= 0 {
+				fail("bad varint")
+			}
+			m.push(int64(n)) // values above MaxInt64 wrap around to negative
+		},
+		op.Add: func(m *machine) { binOp(m, (*big.Int).Add) },
+		op.Neg: func(m *machine) {
+			a := m.popInt()
+			m.push(arith(func(z, a, _ *big.Int) *big.Int { return z.Neg(a) }, a, 0))
+		},
+		op.Mul: func(m *machine) { binOp(m, (*big.Int).Mul) },
+		op.Div: func(m *machine) { divOp(m, (*big.Int).Quo) },
+		op.Mod: func(m *machine) { divOp(m, (*big.Int).Rem) },
+		op.GT: func(m *machine) {
+			b := m.popInt()
+			a := m.popInt()
+			m.pushBool(a > b)
+		},
+		op.Not: func(m *machine) { m.pushBool(!m.popBool()) },
+		op.And: func(m *machine) {
+			q := m.popBool()
+			p := m.popBool()
+			m.pushBool(p && q)
+		},
+		op.Or: func(m *machine) {
+			q := m.popBool()
+			p := m.popBool()
+			m.pushBool(p || q)
+		},
+
+		op.Roll: func(m *machine) {
+			n := m.popInt()
+			x := m.peek(n)
+			s := m.contract.stack
+			i := len(s) - 1 - int(n)
+			m.contract.stack = append(append(append([]item{}, s[:i]...), s[i+1:]...), x)
+			m.charge(n)
+		},
+		op.Bury: func(m *machine) {
+			n := m.popInt()
+			m.peek(n)
+			x := m.pop()
+			s := m.contract.stack
+			i := len(s) - int(n)
+			m.contract.stack = append(append(append([]item{}, s[:i]...), x), s[i:]...)
+			m.charge(n)
+		},
+		op.Reverse: func(m *machine) {
+			n := m.popInt()
+			s := m.contract.stack
+			if n < 0 || n > int64(len(s)) {
+				fail("reverse %d of %d items", n, len(s))
+			}
+			for i, j := len(s)-int(n), len(s)-1; i < j; i, j = i+1, j-1 {
+				s[i], s[j] = s[j], s[i]
+			}
+			m.charge(n)
+		},
+		op.Get: func(m *machine) {
+			if len(m.argstack) == 0 {
+				fail("argument stack underflow")
+			}
+			x := m.argstack[len(m.argstack)-1]
+			m.argstack = m.argstack[:len(m.argstack)-1]
+			m.push(x)
+		},
+		op.Put:   func(m *machine) { m.argstack = append(m.argstack, m.pop()) },
+		op.Depth: func(m *machine) { m.push(int64(len(m.argstack))) },
+
+		op.Nonce: func(m *machine) {
+			exp := m.popInt()
+			blockID := m.popBytes()
+			nonce := tuple{code(txvm.NonceCode), m.caller, m.contract.seed, blockID, exp}
+			m.logEntry(nonce...)
+			m.logEntry(code(txvm.TimerangeCode), m.contract.seed, int64(0), exp)
+			m.charge(entryCost)
+			m.push(&value{amount: 0, assetID: make([]byte, 32), anchor: vmhash("Nonce", encode(nonce))})
+		},
+		op.Merge: func(m *machine) {
+			a := m.popValue()
+			b := m.popValue()
+			if !bytes.Equal(a.assetID, b.assetID) {
+				fail("merging different assets")
+			}
+			anchor := vmhash("Merge", cat(a.anchor, b.anchor))
+			sum := arith((*big.Int).Add, a.amount, b.amount)
+			m.charge(entryCost)
+			m.push(&value{amount: sum, assetID: a.assetID, anchor: anchor})
+		},
+		op.Split: func(m *machine) {
+			amount := m.popInt()
+			if amount < 0 {
+				fail("negative amount")
+			}
+			a := m.popValue()
+			if amount > a.amount {
+				fail("splitting %d from %d", amount, a.amount)
+			}
+			m.charge(entryCost)
+			rest := &value{amount: a.amount - amount, assetID: a.assetID, anchor: vmhash("Split1", a.anchor)}
+			m.charge(entryCost)
+			split := &value{amount: amount, assetID: a.assetID, anchor: vmhash("Split2", a.anchor)}
+			m.push(rest)
+			m.push(split)
+		},
+		op.Issue: func(m *machine) {
+			tag := m.popBytes()
+			amount := m.popInt()
+			if amount < 0 {
+				fail("negative amount")
+			}
+			anchor := m.popZeroValue().anchor
+			assetID := vmhash("AssetID", cat(m.contract.seed, tag))
+			m.charge(entryCost)
+			m.push(&value{amount: amount, assetID: assetID, anchor: anchor})
+			m.logEntry(code(txvm.IssueCode), m.caller, amount, assetID, anchor)
+		},
+		op.Retire: func(m *machine) {
+			v := m.popValue()
+			m.logEntry(code(txvm.RetireCode), m.contract.seed, v.amount, v.assetID, v.anchor)
+		},
+		op.Amount:  func(m *machine) { m.push(m.peekValue().amount) },
+		op.AssetID: func(m *machine) { m.pushCopy(m.peekValue().assetID) },
+		op.Anchor:  func(m *machine) { m.pushCopy(m.peekValue().anchor) },
+
+		op.VMHash: func(m *machine) {
+			f := m.popBytes()
+			x := m.popBytes()
+			m.pushNew(vmhash(string(f), x))
+		},
+		op.SHA256: func(m *machine) {
+			h := sha256.Sum256(m.popBytes())
+			m.pushNew(h[:])
+		},
+		op.SHA3: func(m *machine) {
+			h := sha3.Sum256(m.popBytes())
+			m.pushNew(h[:])
+		},
+		op.CheckSig: func(m *machine) {
+			scheme := m.popData()
+			sig := m.popBytes()
+			pubkey := m.popBytes()
+			msg := m.popBytes()
+			if len(sig) == 0 {
+				m.pushBool(false)
+				return
+			}
+			m.charge(2048)
+			if scheme != int64(0) {
+				fail("unknown signature scheme")
+			}
+			checkSig(msg, pubkey, sig)
+			m.pushBool(true)
+		},
+
+		op.Log: func(m *machine) {
+			m.logEntry(code(txvm.LogCode), m.contract.seed, m.popData())
+		},
+		op.PeekLog: func(m *machine) {
+			i := m.popInt()
+			if i < 0 || i >= int64(len(m.log)) {
+				fail("peeklog %d of %d entries", i, len(m.log))
+			}
+			m.charge(size(m.log[i]))
+			m.push(m.log[i])
+		},
+		op.TxID: func(m *machine) {
+			if !m.finalized {
+				fail("txid before finalize")
+			}
+			m.pushCopy(m.txid[:])
+		},
+		op.Finalize: func(m *machine) {
+			anchor := m.popZeroValue().anchor
+			m.logEntry(code(txvm.FinalizeCode), m.contract.seed, int64(Version), anchor)
+			m.finalized = true
+			var entries [][]byte
+			for _, entry := range m.log {
+				entries = append(entries, encode(entry))
+			}
+			m.txid = merkleRoot(entries)
+		},
+
+		op.Verify: func(m *machine) {
+			if !m.popBool() {
+				fail("verify failed")
+			}
+		},
+		op.Exec: func(m *machine) { m.exec(m.popBytes()) },
+		op.Call: func(m *machine) {
+			con, ok := m.pop().(*contract)
+			if !ok {
+				fail("calling a non-contract")
+			}
+			con.wrapped = false
+			saved, savedCaller := m.contract, m.caller
+			m.caller, m.contract = m.contract.seed, con
+			m.exec(con.program)
+			if !m.unwinding && len(con.stack) > 0 {
+				fail("contract ended with items on its stack")
+			}
+			m.unwinding = false
+			m.contract, m.caller = saved, savedCaller
+		},
+		op.Yield: func(m *machine) {
+			m.contract.program = m.popBytes()
+			m.argstack = append(m.argstack, m.contract)
+			m.unwinding = true
+		},
+		op.Wrap: func(m *machine) {
+			m.checkPortable()
+			m.contract.program = m.popBytes()
+			m.contract.wrapped = true
+			m.argstack = append(m.argstack, m.contract)
+			m.unwinding = true
+		},
+		op.Input: func(m *machine) {
+			t := m.popTuple()
+			snapshot := encode(t)
+			con, ok := uninspect(t).(*contract)
+			if !ok {
+				fail("input of a non-contract")
+			}
+			m.charge(entryCost)
+			m.push(con)
+			m.logEntry(code(txvm.InputCode), m.contract.seed, vmhash("SnapshotID", snapshot))
+		},
+		op.Output: func(m *machine) {
+			m.checkPortable()
+			m.contract.program = m.popBytes()
+			snapshot := encode(inspect(m.contract))
+			m.charge(size(snapshot))
+			m.logEntry(code(txvm.OutputCode), m.caller, vmhash("SnapshotID", snapshot))
+			m.unwinding = true
+		},
+		op.Contract: func(m *machine) {
+			prog := m.popBytes()
+			m.charge(entryCost)
+			m.push(&contract{seed: vmhash("ContractSeed", prog), program: prog})
+		},
+		op.Seed: func(m *machine) {
+			con, ok := m.peek(0).(*contract)
+			if !ok {
+				fail("not a contract")
+			}
+			m.pushCopy(con.seed)
+		},
+		op.Self:            func(m *machine) { m.pushCopy(m.contract.seed) },
+		op.Caller:          func(m *machine) { m.pushCopy(m.caller) },
+		op.ContractProgram: func(m *machine) { m.pushCopy(m.contract.program) },
+		op.TimeRange: func(m *machine) {
+			max := m.popInt()
+			min := m.popInt()
+			m.logEntry(code(txvm.TimerangeCode), m.contract.seed, min, max)
+		},
+
+		op.Prv: func(m *machine) { fail("prv") },
+		op.Ext: func(m *machine) { fail("ext without the extension flag") },
+
+		op.Eq: func(m *machine) {
+			a := m.popData()
+			b := m.popData()
+			eq := false
+			switch a := a.(type) {
+			case int64:
+				eq = a == b
+			case []byte:
+				if b, ok := b.([]byte); ok {
+					eq = bytes.Equal(a, b)
+				}
+			}
+			m.pushBool(eq) // tuples are never equal
+		},
+		op.Dup: func(m *machine) { m.pushCopy(m.peekData(0)) },
+		op.Drop: func(m *machine) {
+			x := m.pop()
+			if v, ok := x.(*value); !isData(x) && !(ok && v.amount == 0) {
+				fail("dropping %T", x)
+			}
+		},
+		op.Peek: func(m *machine) { m.pushCopy(m.peekData(m.popInt())) },
+		op.Tuple: func(m *machine) {
+			n := m.popInt()
+			if n < 0 || n > int64(len(m.contract.stack)) {
+				fail("tuple of %d from %d items", n, len(m.contract.stack))
+			}
+			t := make(tuple, n)
+			for i := n - 1; i >= 0; i-- {
+				t[i] = m.popData()
+			}
+			m.pushNew(t)
+		},
+		op.Untuple: func(m *machine) {
+			t := m.popTuple()
+			for _, x := range t {
+				m.push(x)
+			}
+			m.push(int64(len(t)))
+			m.charge(int64(len(t)))
+		},
+		op.Len: func(m *machine) {
+			switch x := m.popData().(type) {
+			case []byte:
+				m.push(int64(len(x)))
+			case tuple:
+				m.push(int64(len(x)))
+			default:
+				fail("len of an int")
+			}
+		},
+		op.Field: func(m *machine) {
+			n := m.popInt()
+			t := m.popTuple()
+			if n < 0 || n >= int64(len(t)) {
+				fail("field %d of %d", n, len(t))
+			}
+			m.pushCopy(t[n])
+		},
+		op.Encode: func(m *machine) { m.pushNew(encode(m.popData())) },
+		op.Cat: func(m *machine) {
+			b := m.popBytes()
+			a := m.popBytes()
+			m.pushNew(cat(a, b))
+		},
+		op.Slice: func(m *machine) {
+			end := m.popInt()
+			start := m.popInt()
+			s := m.popBytes()
+			if start < 0 || end < start || end > int64(len(s)) {
+				fail("slice %d:%d of %d bytes", start, end, len(s))
+			}
+			m.pushNew(append([]byte{}, s[start:end]...))
+		},
+		op.BitNot: func(m *machine) {
+			a := m.popBytes()
+			c := make([]byte, len(a))
+			for i := range a {
+				c[i] = ^a[i]
+			}
+			m.pushNew(c)
+		},
+		op.BitAnd: func(m *machine) { bitOp(m, func(a, b byte) byte { return a & b }) },
+		op.BitOr:  func(m *machine) { bitOp(m, func(a, b byte) byte { return a | b }) },
+		op.BitXor: func(m *machine) { bitOp(m, func(a, b byte) byte { return a ^ b }) },
+	}
+}
+
+// pushNew pushes a newly created data item, charging for it.
+func (m *machine) pushNew(x item) {
+	m.charge(size(x))
+	m.push(x)
+}
+
+// pushCopy pushes a copy of a data item, charging for it.
+func (m *machine) pushCopy(x item) {
+	if b, ok := x.([]byte); ok {
+		x = append([]byte{}, b...)
+	}
+	m.charge(size(x))
+	m.push(x)
+}
+
+func (m *machine) peekData(n int64) item {
+	x := m.peek(n)
+	if !isData(x) {
+		fail("%T is not data", x)
+	}
+	return x
+}
+
+func (m *machine) checkPortable() {
+	for _, x := range m.contract.stack {
+		if !portable(x) {
+			fail("unwrapped contract on the stack")
+		}
+	}
+}
+
+func binOp(m *machine, f func(z, a, b *big.Int) *big.Int) {
+	b := m.popInt()
+	a := m.popInt()
+	m.push(arith(f, a, b))
+}
+
+func divOp(m *machine, f func(z, a, b *big.Int) *big.Int) {
+	b := m.popInt()
+	a := m.popInt()
+	if b == 0 {
+		fail("division by zero")
+	}
+	if a == math.MinInt64 && b == -1 {
+		// The quotient overflows. So, by fiat, does the remainder,
+		// 0.
+		fail("integer overflow")
+	}
+	m.push(arith(f, a, b))
+}
+
+func bitOp(m *machine, f func(a, b byte) byte) {
+	b := m.popBytes()
+	a := m.popBytes()
+	if len(a) != len(b) {
+		fail("bitwise op on %d and %d bytes", len(a), len(b))
+	}
+	c := make([]byte, len(a))
+	for i := range a {
+		c[i] = f(a[i], b[i])
+	}
+	m.pushNew(c)
+}
@@ -0,0 +1,499 @@
+/*
+Package txvmref is a reference interpreter for txvm, for checking
+package txvm against in tests.
+
+It implements the semantics of transaction version 3, the original
+instruction set without extensions, as plainly as possible: items are
+ordinary Go values, stacks are slices, every instruction is a function
+in a map keyed by opcode, and integer arithmetic is checked by doing
+it in math/big and testing whether the result fits. Nothing is
+cached, pooled, or decoded in advance, so it is much slower than
+package txvm, and shares none of its code beyond the hash functions
+and the opcode numbering. Where the two disagree on a program, one of
+them is wrong.
+
+It must agree with package txvm on everything txvmdiff compares: the
+transaction ID, the log, whether the program succeeds, and the
+runlimit remaining afterward, including for programs that fail. That
+means charging runlimit, and logging, in the same order.
+
+Engine runs it as a txvmdiff.Engine.
+*/
+package txvmref
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"i10r.io/crypto/ed25519"
+	"i10r.io/crypto/sha3"
+	"i10r.io/errors"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+	"i10r.io/protocol/txvm/txvmdiff"
+)
+
+// Version is the transaction version the interpreter implements.
+const Version = 3
+
+// ErrVersion is the error for running a program with a version
+// other than Version. (A version below 3 fails as it does in package
+// txvm.)
+var ErrVersion = errors.New("unsupported transaction version")
+
+// Engine runs programs with Run.
+var Engine txvmdiff.Engine = txvmdiff.EngineFunc(Run)
+
+// The kinds of items are int64, []byte, tuple, *value, and *contract.
+// The first three are data.
+type (
+	item  interface{}
+	tuple []item
+	value struct {
+		amount  int64
+		assetID []byte
+		anchor  []byte
+	}
+	contract struct {
+		wrapped bool
+		seed    []byte
+		program []byte
+		stack   []item
+	}
+)
+
+// failure is a panic value that ends the run unsuccessfully. Any
+// other panic is a bug in the interpreter, and is not recovered.
+type failure string
+
+type machine struct {
+	runlimit  int64
+	argstack  []item
+	contract  *contract // the running contract
+	caller    []byte    // the seed of the contract that called it
+	unwinding bool      // an output, yield, or wrap is ending the contract
+	finalized bool
+	txid      [32]byte
+	log       []tuple
+}
+
+// Run runs prog as a transaction of the given version with the given
+// runlimit.
+func Run(prog []byte, version, runlimit int64) *txvmdiff.Outcome {
+	if version < 3 {
+		return &txvmdiff.Outcome{Err: txvm.ErrVersion}
+	}
+	if version != Version {
+		return &txvmdiff.Outcome{Err: errors.WithDetailf(ErrVersion, "version %d", version)}
+	}
+	zero := make([]byte, 32)
+	m := &machine{
+		runlimit: runlimit,
+		contract: &contract{seed: zero, program: prog},
+		caller:   zero,
+	}
+	err := m.run(prog)
+	out := &txvmdiff.Outcome{
+		Finalized: m.finalized,
+		TxID:      m.txid,
+		Runlimit:  m.runlimit,
+		Err:       err,
+	}
+	for _, entry := range m.log {
+		out.Log = append(out.Log, encode(entry))
+	}
+	return out
+}
+
+func (m *machine) run(prog []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			f, ok := r.(failure)
+			if !ok {
+				panic(r)
+			}
+			err = errors.New(string(f))
+		}
+	}()
+	if int64(len(prog)) > m.runlimit {
+		fail("program longer than runlimit")
+	}
+	m.exec(prog)
+	if len(m.contract.stack) > 0 || len(m.argstack) > 0 {
+		fail("residue on stack")
+	}
+	return nil
+}
+
+func fail(format string, args ...interface{}) {
+	panic(failure(fmt.Sprintf(format, args...)))
+}
+
+// exec runs prog in the current contract until it ends or the
+// contract unwinds.
+func (m *machine) exec(prog []byte) {
+	pc := 0
+	for pc < len(prog) && !m.unwinding {
+		opcode, data, n := decode(prog[pc:])
+		m.charge(1)
+		pc += n
+		switch {
+		case opcode <= op.MaxSmallInt:
+			m.push(int64(opcode - op.MinSmallInt))
+		case opcode == op.MinPushdata:
+			s := append([]byte{}, data...)
+			m.charge(size(s))
+			m.push(s)
+		case opcode == op.JumpIf:
+			offset := m.popInt()
+			if !m.popBool() {
+				continue
+			}
+			if offset < int64(-pc) || offset > int64(len(prog)-pc) {
+				fail("jump to %d%+d outside program of length %d", pc, offset, len(prog))
+			}
+			pc += int(offset)
+		default:
+			ops[opcode](m)
+		}
+	}
+}
+
+// decode returns the opcode of the instruction at the start of prog,
+// op.MinPushdata for any pushdata instruction, its immediate data, and
+// its length.
+func decode(prog []byte) (opcode byte, data []byte, n int) {
+	u, n := binary.Uvarint(prog)
+	if n <= 0 {
+		fail("bad opcode varint")
+	}
+	if u < op.MinPushdata {
+		return byte(u), nil, n
+	}
+	l := u - op.MinPushdata
+	if l > uint64(len(prog)-n) {
+		fail("pushdata of %d bytes with %d left", l, len(prog)-n)
+	}
+	return op.MinPushdata, prog[n : n+int(l)], n + int(l)
+}
+
+func (m *machine) charge(n int64) {
+	m.runlimit -= n
+	if m.runlimit < 0 {
+		fail("runlimit exhausted")
+	}
+}
+
+// size is the runlimit charged for creating or copying a data item.
+func size(x item) int64 {
+	switch x := x.(type) {
+	case []byte:
+		return 1 + int64(len(x))
+	case tuple:
+		return 1 + int64(len(x))
+	}
+	return 0
+}
+
+// entryCost is the runlimit charged for creating a value or contract.
+const entryCost = 128
+
+func isData(x item) bool {
+	switch x.(type) {
+	case int64, []byte, tuple:
+		return true
+	}
+	return false
+}
+
+// Stack access.
+
+func (m *machine) push(x item) {
+	m.contract.stack = append(m.contract.stack, x)
+}
+
+func (m *machine) pop() item {
+	s := m.contract.stack
+	if len(s) == 0 {
+		fail("stack underflow")
+	}
+	x := s[len(s)-1]
+	m.contract.stack = s[:len(s)-1]
+	return x
+}
+
+func (m *machine) peek(n int64) item {
+	s := m.contract.stack
+	if n < 0 || n >= int64(len(s)) {
+		fail("peek %d of %d items", n, len(s))
+	}
+	return s[int64(len(s))-1-n]
+}
+
+func (m *machine) popData() item {
+	x := m.pop()
+	if !isData(x) {
+		fail("%T is not data", x)
+	}
+	return x
+}
+
+func (m *machine) popInt() int64 {
+	x, ok := m.pop().(int64)
+	if !ok {
+		fail("not an int")
+	}
+	return x
+}
+
+func (m *machine) popBytes() []byte {
+	x, ok := m.pop().([]byte)
+	if !ok {
+		fail("not a string")
+	}
+	return x
+}
+
+func (m *machine) popTuple() tuple {
+	x, ok := m.pop().(tuple)
+	if !ok {
+		fail("not a tuple")
+	}
+	return x
+}
+
+// popBool pops a data item, which is false if and only if it is the
+// int 0.
+func (m *machine) popBool() bool {
+	x := m.popData()
+	return x != int64(0)
+}
+
+func (m *machine) pushBool(b bool) {
+	if b {
+		m.push(int64(1))
+	} else {
+		m.push(int64(0))
+	}
+}
+
+func (m *machine) popValue() *value {
+	v, ok := m.pop().(*value)
+	if !ok {
+		fail("not a value")
+	}
+	return v
+}
+
+func (m *machine) popZeroValue() *value {
+	v := m.popValue()
+	if v.amount != 0 {
+		fail("value of %d is not zero", v.amount)
+	}
+	return v
+}
+
+func (m *machine) peekValue() *value {
+	v, ok := m.peek(0).(*value)
+	if !ok {
+		fail("not a value")
+	}
+	return v
+}
+
+// Log entries.
+
+func code(c byte) []byte {
+	return []byte{c}
+}
+
+func (m *machine) logEntry(fields ...item) {
+	if m.finalized {
+		fail("log after finalize")
+	}
+	t := tuple(fields)
+	m.charge(size(t))
+	m.log = append(m.log, t)
+}
+
+// Encoding.
+
+// encode returns the program that pushes the data item x.
+func encode(x item) []byte {
+	var buf bytes.Buffer
+	var enc func(x item)
+	enc = func(x item) {
+		switch x := x.(type) {
+		case int64:
+			if x >= 0 && x <= op.MaxSmallInt-op.MinSmallInt {
+				buf.WriteByte(op.MinSmallInt + byte(x))
+				return
+			}
+			var v [binary.MaxVarintLen64]byte
+			pushdata(&buf, v[:binary.PutUvarint(v[:], uint64(x))])
+			buf.WriteByte(op.Int)
+		case []byte:
+			pushdata(&buf, x)
+		case tuple:
+			for _, y := range x {
+				enc(y)
+			}
+			enc(int64(len(x)))
+			buf.WriteByte(op.Tuple)
+		default:
+			panic(fmt.Sprintf("encoding %T", x))
+		}
+	}
+	enc(x)
+	return buf.Bytes()
+}
+
+func pushdata(buf *bytes.Buffer, data []byte) {
+	var v [binary.MaxVarintLen64]byte
+	buf.Write(v[:binary.PutUvarint(v[:], uint64(len(data))+op.MinPushdata)])
+	buf.Write(data)
+}
+
+// inspect returns the tuple describing x, as in a snapshot.
+func inspect(x item) tuple {
+	switch x := x.(type) {
+	case int64:
+		return tuple{code(txvm.IntCode), x}
+	case []byte:
+		return tuple{code(txvm.BytesCode), x}
+	case tuple:
+		return tuple{code(txvm.TupleCode), x}
+	case *value:
+		return tuple{code(txvm.ValueCode), x.amount, x.assetID, x.anchor}
+	case *contract:
+		c := txvm.ContractCode
+		if x.wrapped {
+			c = txvm.WrappedContractCode
+		}
+		t := tuple{code(c), x.seed, x.program}
+		for _, y := range x.stack {
+			t = append(t, inspect(y))
+		}
+		return t
+	}
+	panic(fmt.Sprintf("inspecting %T", x))
+}
+
+// uninspect is the inverse of inspect, for input. Like package txvm,
+// it ignores any fields after the second of a data item's tuple.
+func uninspect(t tuple) item {
+	if len(t) == 0 {
+		fail("empty tuple")
+	}
+	c, ok := t[0].([]byte)
+	if !ok || len(c) != 1 {
+		fail("no type code")
+	}
+	switch c[0] {
+	case txvm.IntCode, txvm.BytesCode, txvm.TupleCode:
+		if len(t) < 2 {
+			fail("no data")
+		}
+		var ok bool
+		switch c[0] {
+		case txvm.IntCode:
+			_, ok = t[1].(int64)
+		case txvm.BytesCode:
+			_, ok = t[1].([]byte)
+		case txvm.TupleCode:
+			_, ok = t[1].(tuple)
+		}
+		if !ok {
+			fail("data does not match type code %c", c[0])
+		}
+		return t[1]
+	case txvm.ValueCode:
+		if len(t) != 4 {
+			fail("value has %d fields", len(t))
+		}
+		amount, ok1 := t[1].(int64)
+		assetID, ok2 := t[2].([]byte)
+		anchor, ok3 := t[3].([]byte)
+		if !ok1 || !ok2 || !ok3 {
+			fail("bad value fields")
+		}
+		return &value{amount: amount, assetID: assetID, anchor: anchor}
+	case txvm.ContractCode, txvm.WrappedContractCode:
+		if len(t) < 3 {
+			fail("contract has %d fields", len(t))
+		}
+		seed, ok1 := t[1].([]byte)
+		program, ok2 := t[2].([]byte)
+		if !ok1 || !ok2 {
+			fail("bad contract fields")
+		}
+		con := &contract{wrapped: c[0] == txvm.WrappedContractCode, seed: seed, program: program}
+		for _, x := range t[3:] {
+			sub, ok := x.(tuple)
+			if !ok {
+				fail("contract stack item is not a tuple")
+			}
+			con.stack = append(con.stack, uninspect(sub))
+		}
+		return con
+	}
+	fail("unknown type code %x", c)
+	return nil
+}
+
+func portable(x item) bool {
+	if c, ok := x.(*contract); ok {
+		return c.wrapped
+	}
+	return true
+}
+
+// Hashing.
+
+func vmhash(f string, x []byte) []byte {
+	h := txvm.VMHash(f, x)
+	return h[:]
+}
+
+func cat(a, b []byte) []byte {
+	return append(append([]byte{}, a...), b...)
+}
+
+// merkleRoot is the root of the binary Merkle tree of items, as in
+// RFC 6962, with SHA3-256.
+func merkleRoot(items [][]byte) [32]byte {
+	switch len(items) {
+	case 0:
+		return sha3.Sum256(nil)
+	case 1:
+		return sha3.Sum256(cat([]byte{0}, items[0]))
+	}
+	k := 1
+	for k*2 < len(items) {
+		k *= 2
+	}
+	left, right := merkleRoot(items[:k]), merkleRoot(items[k:])
+	return sha3.Sum256(cat(cat([]byte{1}, left[:]), right[:]))
+}
+
+// Arithmetic.
+
+// arith applies f to a and b, failing if the result is not an int64.
+func arith(f func(z, a, b *big.Int) *big.Int, a, b int64) int64 {
+	z := f(new(big.Int), big.NewInt(a), big.NewInt(b))
+	if !z.IsInt64() {
+		fail("integer overflow")
+	}
+	return z.Int64()
+}
+
+func checkSig(msg, pubkey, sig []byte) {
+	if len(sig) != ed25519.SignatureSize || len(pubkey) != ed25519.PublicKeySize {
+		fail("bad signature or public key length")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pubkey), msg, sig) {
+		fail("invalid signature")
+	}
+}
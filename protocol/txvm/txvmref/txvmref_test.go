@@ -0,0 +1,206 @@
+package txvmref
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/op"
+	"i10r.io/protocol/txvm/txvmdiff"
+	"i10r.io/protocol/txvm/txvmtest"
+	"i10r.io/protocol/vectors"
+)
+
+// corpus returns the programs of the golden test vectors and the
+// sample transactions.
+func corpus(t *testing.T) [][]byte {
+	b, err := ioutil.ReadFile("../../vectors/testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v vectors.Vectors
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	var progs [][]byte
+	for _, r := range append(v.VM, v.Transactions...) {
+		progs = append(progs, r.Program)
+	}
+	for _, src := range []string{
+		txvmtest.SimplePayment,
+		txvmtest.Issuance,
+		txvmtest.SimplePayment2,
+		txvmtest.SplitPayment,
+		txvmtest.MergePayment,
+		txvmtest.Retirement,
+	} {
+		prog, err := asm.Assemble(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		progs = append(progs, prog)
+	}
+	return progs
+}
+
+func compare(t *testing.T, prog []byte, runlimit int64) bool {
+	t.Helper()
+	_, _, diffs := txvmdiff.Compare(txvmdiff.Current, Engine, prog, Version, runlimit, true)
+	if len(diffs) == 0 {
+		return true
+	}
+	src, _ := asm.Disassemble(prog)
+	t.Errorf("program %x (runlimit %d):\n%s\ndiffers (txvm vs. reference): %v", prog, runlimit, src, diffs)
+	return false
+}
+
+func TestCorpus(t *testing.T) {
+	for _, prog := range corpus(t) {
+		compare(t, prog, 100000)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	prog := []byte{op.Verify}
+	for _, v := range []int64{2, 4} {
+		if out := Run(prog, v, 100); out.Err == nil {
+			t.Errorf("version %d: got no error", v)
+		}
+	}
+	_, _, diffs := txvmdiff.Compare(txvmdiff.Current, Engine, prog, 2, 100, true)
+	if len(diffs) != 0 {
+		t.Errorf("version 2: %v", diffs)
+	}
+}
+
+// snippets are fragments of programs that give random programs
+// something to work with.
+var snippets = []string{
+	"'' 5 nonce",
+	"x'0102' 1000 nonce 10 'tag' issue",
+	"3 split",
+	"merge",
+	"retire",
+	"amount",
+	"anchor",
+	"'id' 10 nonce finalize",
+	"txid",
+	"0 peeklog",
+	"'' put",
+	"get",
+	"self caller",
+	"contractprogram",
+	"[1 verify] contract",
+	"[put] contract call",
+	"[get 'p' output] contract",
+	"[get 'p' wrap] contract call",
+	"[get 'p' yield] contract call get",
+	"[1 2 add] exec",
+	"{1, 'a', {2}} dup untuple",
+	"2 tuple encode",
+	"10 0 timerange",
+	"'x' 'y' cat",
+	"0 1 slice",
+	"sha3 sha256",
+	"'f' vmhash",
+	"x'ffffffffffffffff7f' int",
+	"-1 0 mod",
+	"1 2 jumpif",
+	"0 eq not",
+	"x'00' x'ff' bitxor bitnot",
+	"{'C', x'', x''} input",
+	"{'W', x'', x'', {'V', 1, x'', x''}} input",
+	"{'C', x'00', x'', {'Z', 1}, {'S', 'a'}, {'T', {}}, {'C', x'', x''}} input",
+	"x'' x'' x'' 0 checksig",
+	"depth 1 roll 1 bury 2 reverse",
+	"len field",
+}
+
+type generator struct {
+	r        *rand.Rand
+	corpus   [][]byte
+	snippets [][]byte
+}
+
+func (g *generator) program(depth int) []byte {
+	if depth == 0 && g.r.Intn(2) == 0 {
+		return g.mutate(g.corpus[g.r.Intn(len(g.corpus))])
+	}
+	var prog []byte
+	for n := 1 + g.r.Intn(20); n > 0; n-- {
+		prog = append(prog, g.instruction(depth)...)
+	}
+	return prog
+}
+
+func (g *generator) instruction(depth int) []byte {
+	switch k := g.r.Intn(20); {
+	case k < 5:
+		return []byte{byte(g.r.Intn(6))}
+	case k < 7:
+		data := make([]byte, g.r.Intn(34))
+		g.r.Read(data)
+		return encode(data)
+	case k < 9 && depth < 2:
+		return encode(g.program(depth + 1))
+	case k < 13:
+		return g.snippets[g.r.Intn(len(g.snippets))]
+	}
+	return []byte{byte(op.Int + g.r.Intn(op.MinPushdata-op.Int))}
+}
+
+func (g *generator) mutate(prog []byte) []byte {
+	prog = append([]byte{}, prog...)
+	for n := 1 + g.r.Intn(3); n > 0 && len(prog) > 0; n-- {
+		i := g.r.Intn(len(prog))
+		switch g.r.Intn(4) {
+		case 0:
+			prog[i] ^= byte(1 << uint(g.r.Intn(8)))
+		case 1:
+			prog[i] = byte(g.r.Intn(256))
+		case 2:
+			j := i + g.r.Intn(len(prog)-i)
+			prog = append(prog[:i], prog[j:]...)
+		case 3:
+			ins := g.instruction(1)
+			prog = append(prog[:i], append(ins, prog[i:]...)...)
+		}
+	}
+	return prog
+}
+
+func TestRandom(t *testing.T) {
+	g := &generator{r: rand.New(rand.NewSource(1)), corpus: corpus(t)}
+	for _, s := range snippets {
+		prog, err := asm.Assemble(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		g.snippets = append(g.snippets, prog)
+	}
+	n := 20000
+	if testing.Short() {
+		n = 2000
+	}
+	var failed, succeeded int
+	for i := 0; i < n && failed < 10; i++ {
+		prog := g.program(0)
+		runlimit := int64(100000)
+		if g.r.Intn(4) == 0 {
+			// Exhaust the runlimit partway through.
+			used := runlimit - txvmdiff.Current.Run(prog, Version, runlimit).Runlimit
+			if used > 0 {
+				runlimit = g.r.Int63n(used)
+			}
+		}
+		if !compare(t, prog, runlimit) {
+			failed++
+		}
+		if txvmdiff.Current.Run(prog, Version, runlimit).Err == nil {
+			succeeded++
+		}
+	}
+	t.Logf("%d of %d random programs succeeded", succeeded, n)
+}
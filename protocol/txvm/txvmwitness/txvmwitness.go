@@ -0,0 +1,182 @@
+// Package txvmwitness finds the parts of a transaction's witness that
+// a third party could change without changing the transaction's ID
+// or making it invalid.
+//
+// A transaction ID commits to the transaction log, not to the
+// program that produced it. Some changes to the program are therefore
+// always possible: inserting instructions with no net effect, such as
+// "0 drop", or re-encoding an instruction with a longer varint
+// opcode. Analyze does not report those, since every transaction
+// admits them and blocks commit to the exact program through the
+// witness commitment anyway. (The txvm.StrictEncoding option rules
+// out the second kind.)
+//
+// What Analyze looks for is data the transaction does not need to
+// fix: pushdata and small integers in the transaction program that
+// can take other values. Typical culprits are arguments that a
+// contract drops unchecked, a signature slot in a multisig that
+// holds more signatures than its quorum, and values pushed after
+// finalize that nothing consumes. A wallet that builds such a spend
+// lets relays alter it in flight (changing its witness hash and
+// perhaps its runlimit cost) before it reaches a block.
+package txvmwitness
+
+import (
+	"fmt"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/op"
+)
+
+// Kind classifies a malleation.
+type Kind string
+
+// The malleations Analyze tries.
+const (
+	// KindByte is a change to one byte of a pushdata item.
+	KindByte Kind = "byte"
+
+	// KindEmpty is the replacement of a pushdata item with the
+	// empty string.
+	KindEmpty Kind = "empty"
+
+	// KindExtend is the addition of a zero byte to the end of a
+	// pushdata item.
+	KindExtend Kind = "extend"
+
+	// KindTruncate is the removal of the last byte of a pushdata
+	// item.
+	KindTruncate Kind = "truncate"
+
+	// KindInt is the replacement of a small integer with another.
+	KindInt Kind = "int"
+)
+
+// Finding is a malleable part of a transaction program.
+type Finding struct {
+	// Offset and Len locate the instruction in the program.
+	Offset, Len int
+
+	Kind Kind
+
+	// Byte is the index, within the pushdata item, of the byte
+	// that can change, for KindByte.
+	Byte int
+
+	// AfterFinalize is true if the instruction runs after the
+	// transaction program's finalize, where its effect is not
+	// recorded in the log.
+	AfterFinalize bool
+
+	// Program is the malleated program: valid, with the same
+	// transaction ID as the original.
+	Program []byte
+}
+
+func (f Finding) String() string {
+	var what string
+	switch f.Kind {
+	case KindByte:
+		what = fmt.Sprintf("byte %d of the pushdata can change", f.Byte)
+	case KindEmpty:
+		what = "the pushdata can be emptied"
+	case KindExtend:
+		what = "the pushdata can be extended"
+	case KindTruncate:
+		what = "the pushdata can be truncated"
+	case KindInt:
+		what = "the integer can change"
+	}
+	var when string
+	if f.AfterFinalize {
+		when = ", after finalize"
+	}
+	return fmt.Sprintf("offset %d%s: %s", f.Offset, when, what)
+}
+
+// Analyze tries changes to each data instruction in prog, reporting
+// those that leave prog valid with the same transaction ID. Every
+// byte of each pushdata item is tried, so prog is run once per
+// witness byte, or so; callers may want to limit the runlimit.
+//
+// Options, such as txvm.Network, are passed to every run. It is an
+// error for prog not to be valid.
+func Analyze(prog []byte, version, runlimit int64, o ...txvm.Option) ([]Finding, error) {
+	txid, finalizeAt, err := run(prog, version, runlimit, o)
+	if err != nil {
+		return nil, errors.Wrap(err, "validating original program")
+	}
+
+	var findings []Finding
+	for pc := 0; pc < len(prog); {
+		opcode, data, n, err := op.DecodeInst(prog[pc:])
+		if err != nil {
+			return nil, err // unreachable for a valid program
+		}
+		inst := Finding{Offset: pc, Len: int(n), AfterFinalize: pc > finalizeAt}
+		try := func(k Kind, b int, repl []byte) {
+			p := splice(prog, pc, int(n), repl)
+			if id, _, err := run(p, version, runlimit, o); err == nil && id == txid {
+				f := inst
+				f.Kind, f.Byte, f.Program = k, b, p
+				findings = append(findings, f)
+			}
+		}
+
+		switch {
+		case op.IsPushdataOp(opcode):
+			for i := range data {
+				d := append([]byte(nil), data...)
+				d[i] ^= 1
+				try(KindByte, i, txvm.Encode(txvm.Bytes(d)))
+			}
+			if len(data) > 0 {
+				try(KindEmpty, 0, txvm.Encode(txvm.Bytes(nil)))
+				try(KindTruncate, 0, txvm.Encode(txvm.Bytes(data[:len(data)-1])))
+			}
+			try(KindExtend, 0, txvm.Encode(txvm.Bytes(append(append([]byte(nil), data...), 0))))
+
+		case op.IsSmallIntOp(opcode):
+			// One other value suffices to show that the integer is
+			// unconstrained.
+			other := opcode + 1
+			if opcode == op.MaxSmallInt {
+				other = opcode - 1
+			}
+			try(KindInt, 0, []byte{other})
+		}
+		pc += int(n)
+	}
+	return findings, nil
+}
+
+// run validates prog and returns its transaction ID and the offset of
+// the top-level instruction during which finalize ran.
+func run(prog []byte, version, runlimit int64, o []txvm.Option) (txid [32]byte, finalizeAt int, err error) {
+	var rec txvm.Recording
+	vm, err := txvm.Validate(prog, version, runlimit, append(o, txvm.Record(&rec))...)
+	if err != nil {
+		return txid, 0, err
+	}
+	if !vm.Finalized {
+		return txid, 0, txvm.ErrUnfinalized
+	}
+	var top int64
+	for _, s := range rec.Steps {
+		if s.Depth == 0 {
+			top = s.PC
+		}
+		if s.Opcode == op.Finalize {
+			break
+		}
+	}
+	return vm.TxID, int(top), nil
+}
+
+func splice(prog []byte, at, n int, repl []byte) []byte {
+	res := make([]byte, 0, len(prog)-n+len(repl))
+	res = append(res, prog[:at]...)
+	res = append(res, repl...)
+	return append(res, prog[at+n:]...)
+}
@@ -0,0 +1,69 @@
+package txvmwitness
+
+import (
+	"testing"
+
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+	"i10r.io/protocol/txvm/txvmtest"
+)
+
+func TestAnalyze(t *testing.T) {
+	prog, err := asm.Assemble(txvmtest.SimplePayment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, err := Analyze(prog, 3, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("simple payment: got findings %v, want none", findings)
+	}
+
+	// A value that is pushed and dropped, before finalize or after,
+	// can be anything.
+	prog, err = asm.Assemble("'ab' drop 'id' 10 nonce finalize 7 drop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	findings, err = Analyze(prog, 3, 100000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		offset int
+		kind   Kind
+		after  bool
+	}{
+		{0, KindByte, false},
+		{0, KindByte, false},
+		{0, KindEmpty, false},
+		{0, KindTruncate, false},
+		{0, KindExtend, false},
+		{len(prog) - 2, KindInt, true},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got findings %v, want %d", findings, len(want))
+	}
+	for i, w := range want {
+		f := findings[i]
+		if f.Offset != w.offset || f.Kind != w.kind || f.AfterFinalize != w.after {
+			t.Errorf("finding %d: got %s, want %s at offset %d (after finalize %v)", i, f, w.kind, w.offset, w.after)
+		}
+		vm, err := txvm.Validate(f.Program, 3, 100000)
+		if err != nil {
+			t.Errorf("finding %d: malleated program invalid: %s", i, err)
+		} else if orig, _ := txvm.Validate(prog, 3, 100000); vm.TxID != orig.TxID {
+			t.Errorf("finding %d: malleated program has txid %x, want %x", i, vm.TxID, orig.TxID)
+		}
+	}
+
+	prog, err = asm.Assemble("0 verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Analyze(prog, 3, 100000); err == nil {
+		t.Error("analyzed an invalid program")
+	}
+}
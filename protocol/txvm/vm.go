@@ -1,6 +1,9 @@
 package txvm
 
 import (
+	"context"
+
+	"i10r.io/crypto/bls"
 	"i10r.io/errors"
 	"i10r.io/math/checked"
 	"i10r.io/protocol/txvm/op"
@@ -12,6 +15,10 @@ import (
 type run struct {
 	pc   int64
 	prog []byte
+	inst int64        // offset of the instruction being executed
+	dec  *decodedProg // prog decoded, if from a ProgramCache
+
+	pushed bool // the last instruction was pushdata (only with strict)
 }
 
 // VM is a virtual machine for executing Chain Protocol transactions.
@@ -20,22 +27,39 @@ type VM struct {
 	txVersion         int64
 	runlimit          int64
 	extension         bool
+	strict            bool
 	stopAfterFinalize bool
 	onFinalize        []func(*VM)
 	onLog             []func(*VM)
 	beforeStep        []func(*VM)
 	afterStep         []func(*VM)
 	onExit            []func(*VM)
+	ctx               context.Context
+	limits            Limits
+	network           [32]byte
+	plugins           *Plugins
+	programs          *ProgramCache
+	sigs              *SigCache
 
 	// Runtime fields
 	argstack  stack
+	arena     arena
 	run       run // TODO(bobg): move run/runstack into txvmutil.
 	runstack  []run
 	unwinding bool
+	steps     int64
+	usage     usage
+	err       error     // the outcome, for onExit hooks
+	inputs    int       // input entries logged
+	outputs   int       // output entries logged
+	blsBatch  bls.Batch // deferred BLS signature checks
 	contract  *contract
 	caller    []byte
 	data      []byte
+	dataItem  Item // Bytes(data), if decoded in advance
 	opcode    byte
+	afterPush bool        // the previous instruction in this run was pushdata (only with strict)
+	entryCons []*contract // the contract each log entry is attributed to
 
 	// Results
 
@@ -46,6 +70,10 @@ type VM struct {
 	// Log is the record of the transaction's effects.
 	Log []Tuple
 
+	// EntryRunlimit is the runlimit attributed to each entry in Log,
+	// set when validation ends. See Validate.
+	EntryRunlimit []int64
+
 	// Finalized is true if and only if the finalize instruction was
 	// executed.
 	Finalized bool
@@ -86,10 +114,19 @@ var (
 	// range of int64.
 	ErrIntOverflow = vmError(checked.ErrOverflow)
 
+	// ErrCanceled is returned when validation is abandoned because
+	// the context passed to ValidateContext was canceled or its
+	// deadline passed.
+	ErrCanceled = errorf("validation canceled")
+
 	// ErrExt is returned when extension operations are performed
 	// and the extension flag is false.
 	ErrExt = errorf("extension flag is false")
 
+	// ErrEncoding is returned, with the StrictEncoding option, for a
+	// program with a non-canonical encoding.
+	ErrEncoding = errorf("non-canonical encoding")
+
 	emptySeed = make([]byte, 32)
 )
 
@@ -97,26 +134,44 @@ var (
 // producing its transaction ID if it gets as far as a "finalize"
 // instruction. Other runtmie information can be inspected via
 // callbacks, which are supplied via the Option arguments.
+//
+// Every unit of runlimit is charged to the contract running at the
+// time, and every log entry is attributed to a contract: an input
+// entry to the contract it brings into the transaction, any other
+// entry to the contract that logs it. The cost of a contract, whenever
+// incurred (for an input, that includes the signature checks it
+// defers until after finalize), is attributed to its first entry in
+// the log, and recorded in the VM's EntryRunlimit. Its other entries
+// have cost 0, as do the contracts that log nothing, which make up the
+// difference between the runlimit consumed and the sum of
+// EntryRunlimit.
 func Validate(prog []byte, txVersion, runlimit int64, o ...Option) (*VM, error) {
 	if txVersion < 3 {
 		return nil, ErrVersion
 	}
 
-	con := &contract{seed: emptySeed, program: prog, typecode: ContractCode}
 	vm := &VM{
 		txVersion: txVersion,
 		runlimit:  runlimit,
-		contract:  con,
 		caller:    emptySeed,
 	}
+	vm.argstack = vm.arena.stack()
+	vm.contract = &contract{seed: emptySeed, program: prog, typecode: ContractCode, stack: vm.arena.stack()}
 
 	for _, o := range o {
 		o.apply(vm)
 	}
 
-	err := vm.validate(prog)
+	vm.err = vm.validate(prog)
+	vm.attributeRunlimit()
 	vm.runHooks(vm.onExit)
-	return vm, err
+	return vm, vm.err
+}
+
+// ValidateContext is like Validate, but abandons execution with
+// ErrCanceled if ctx is done before the program finishes.
+func ValidateContext(ctx context.Context, prog []byte, txVersion, runlimit int64, o ...Option) (*VM, error) {
+	return Validate(prog, txVersion, runlimit, append(o, Context(ctx))...)
 }
 
 func (vm *VM) validate(txprog []byte) (err error) {
@@ -128,6 +183,9 @@ func (vm *VM) validate(txprog []byte) (err error) {
 
 	vm.exec(txprog)
 
+	if err := vm.verifyBLS(); err != nil {
+		return vm.wraperr(err)
+	}
 	if !vm.stopAfterFinalize && (!vm.contract.stack.isEmpty() || !vm.argstack.isEmpty()) {
 		return vm.wraperr(ErrResidue)
 	}
@@ -135,52 +193,139 @@ func (vm *VM) validate(txprog []byte) (err error) {
 }
 
 func (vm *VM) exec(prog []byte) {
-	if len(vm.run.prog) > 0 {
-		vm.runstack = append(vm.runstack, vm.run)
-		defer func() {
-			vm.run = vm.runstack[len(vm.runstack)-1]
-			vm.runstack = vm.runstack[:len(vm.runstack)-1]
-		}()
-	}
-	vm.run.prog = prog
-	vm.run.pc = 0
-	for vm.run.pc < int64(len(vm.run.prog)) {
-		if vm.unwinding {
-			return
-		}
+	vm.execDecoded(prog, nil)
+}
+
+// execDecoded is exec for a program that may have been decoded in
+// advance by a ProgramCache.
+func (vm *VM) execDecoded(prog []byte, dec *decodedProg) {
+	saved := vm.run
+	if len(saved.prog) > 0 {
+		vm.runstack = append(vm.runstack, saved)
+	}
+	vm.run = run{prog: prog, dec: dec}
+	for vm.run.pc < int64(len(vm.run.prog)) && !vm.unwinding {
 		vm.step()
 		if vm.Finalized && vm.stopAfterFinalize {
 			break
 		}
 	}
+	// This is not deferred: after a failure, vm.run is left as it
+	// was, so that the error can report the failing instruction.
+	if len(saved.prog) > 0 {
+		vm.runstack = vm.runstack[:len(vm.runstack)-1]
+	}
+	vm.run = saved
 }
 
+// cancelCheckInterval is how many instructions are executed between
+// checks of the VM's context.
+const cancelCheckInterval = 256
+
 func (vm *VM) step() {
-	opcode, data, n, err := op.DecodeInst(vm.run.prog[vm.run.pc:])
-	if err != nil {
-		panic(vmError(err))
+	vm.run.inst = vm.run.pc
+	if vm.ctx != nil {
+		if vm.steps%cancelCheckInterval == 0 {
+			if err := vm.ctx.Err(); err != nil {
+				panic(errors.WithDetail(ErrCanceled, err.Error()))
+			}
+		}
+		vm.steps++
+	}
+	var (
+		opcode byte
+		data   []byte
+		n      int64
+		item   Item
+	)
+	if d := vm.run.dec; d != nil && d.at[vm.run.pc] > 0 {
+		in := &d.insts[d.at[vm.run.pc]-1]
+		opcode, data, n, item = in.opcode, in.data, in.n, in.item
+	} else {
+		var err error
+		opcode, data, n, err = decodeInst(vm.run.prog[vm.run.pc:])
+		if err != nil {
+			panic(vmError(decodeError{err}))
+		}
+		if vm.strict && n > 1 && !minimalVarint(vm.run.prog[vm.run.pc:], n-int64(len(data))) {
+			panic(errors.Wrap(ErrEncoding, "non-minimal opcode"))
+		}
+	}
+	if vm.strict {
+		vm.afterPush, vm.run.pushed = vm.run.pushed, op.IsPushdataOp(opcode)
 	}
 	vm.opcode = opcode
-	vm.data = data
-	vm.runHooks(vm.beforeStep)
+	// Most instructions carry no data; skipping the store when it
+	// would not change anything saves a write barrier during GC.
+	if data != nil || vm.data != nil {
+		vm.data = data
+		vm.dataItem = item
+	}
+	if len(vm.beforeStep) > 0 {
+		vm.runHooks(vm.beforeStep)
+	}
 	vm.charge(1)
 	vm.run.pc += n
-	switch {
-	case op.IsSmallIntOp(opcode):
-		vm.push(Int(opcode - op.MinSmallInt))
-	case op.IsPushdataOp(opcode):
-		d := Bytes(data)
-		vm.chargeCreate(d)
-		vm.push(d)
-	default:
-		f := opFuncs[opcode]
-		f(vm)
-	}
-	vm.runHooks(vm.afterStep)
+	opFuncs[opcode](vm)
+	if vm.limits.StackDepth > 0 {
+		vm.checkStackDepth()
+	}
+	if len(vm.afterStep) > 0 {
+		vm.runHooks(vm.afterStep)
+	}
+}
+
+// decodeInst is op.DecodeInst, with a fast path for the instructions
+// whose opcode is a one-byte varint: every non-pushdata instruction
+// and pushdata of up to 32 bytes.
+func decodeInst(prog []byte) (byte, []byte, int64, error) {
+	if len(prog) == 0 || prog[0] >= 0x80 {
+		return op.DecodeInst(prog)
+	}
+	opcode := prog[0]
+	if opcode < op.MinPushdata {
+		return opcode, nil, 1, nil
+	}
+	n := 1 + int(opcode-op.MinPushdata)
+	if n > len(prog) {
+		return op.DecodeInst(prog)
+	}
+	return op.MinPushdata, prog[1:n:n], int64(n), nil
+}
+
+// minimalVarint reports whether the n-byte varint at the start of b
+// is minimally encoded, that is, does not end in a zero byte
+// (unless it is the single byte 0).
+func minimalVarint(b []byte, n int64) bool {
+	return n == 1 || b[n-1] != 0
+}
+
+func opSmallInt(vm *VM) {
+	vm.push(smallInts[vm.opcode-op.MinSmallInt])
+}
+
+// smallInts holds the Int pushed by each small-int instruction, made
+// once as an Item.
+var smallInts [op.MaxSmallInt - op.MinSmallInt + 1]Item
+
+func init() {
+	for i := range smallInts {
+		smallInts[i] = Int(i)
+	}
+}
+
+func opPushdata(vm *VM) {
+	d := vm.dataItem
+	if d == nil {
+		d = Bytes(vm.data)
+	}
+	vm.chargeCreate(d)
+	vm.push(d)
 }
 
 func (vm *VM) charge(n int64) {
 	vm.runlimit -= n
+	vm.contract.cost += n
 	if vm.runlimit < 0 {
 		panic(ErrRunlimit)
 	}
@@ -217,11 +362,15 @@ func (vm *VM) popData() Data {
 }
 
 func (vm *VM) popBool() bool {
-	v := vm.popData()
-	if n, ok := v.(Int); ok {
-		return n != 0
+	// A switch on the concrete types is cheaper than popData's
+	// assertion to an interface type.
+	switch v := vm.pop().(type) {
+	case Int:
+		return v != 0
+	case Bytes, Tuple:
+		return true
 	}
-	return true
+	panic(errors.WithData(ErrType, "want", "Data"))
 }
 
 func (vm *VM) popBytes() Bytes {
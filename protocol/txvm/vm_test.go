@@ -2,9 +2,19 @@ package txvm_test
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"math/big"
 	"testing"
 	"testing/quick"
+	"time"
 
+	"i10r.io/crypto/bls"
+	"i10r.io/crypto/ed25519/ecmath"
+	"i10r.io/crypto/groth16"
+	"i10r.io/crypto/pedersen"
+	"i10r.io/crypto/secp256k1"
 	"i10r.io/errors"
 	"i10r.io/protocol/txvm"
 	"i10r.io/protocol/txvm/asm"
@@ -154,6 +164,567 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestValidateContext(t *testing.T) {
+	prog, err := asm.Assemble("$loop 1 jumpif:$loop")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = txvm.ValidateContext(ctx, prog, 3, math.MaxInt64)
+	if errors.Root(err) != txvm.ErrCanceled {
+		t.Errorf("got error %v, want %v", err, txvm.ErrCanceled)
+	}
+
+	var ran bool
+	_, err = txvm.ValidateContext(ctx, prog, 3, math.MaxInt64, txvm.BeforeStep(func(*txvm.VM) { ran = true }))
+	if errors.Root(err) != txvm.ErrCanceled {
+		t.Errorf("with done context, got error %v, want %v", err, txvm.ErrCanceled)
+	}
+	if ran {
+		t.Error("with done context, executed an instruction")
+	}
+}
+
+func TestFault(t *testing.T) {
+	cases := []struct {
+		src      string
+		prog     []byte // used if src is empty
+		code     txvm.ErrorCode
+		pc       int64
+		opcode   byte
+		stackLen int
+		top      string
+	}{
+		{src: "0 verify", code: txvm.CodeVerifyFail, pc: 1, opcode: op.Verify},
+		{src: "5 6 'x' add", code: txvm.CodeType, pc: 4, opcode: op.Add, stackLen: 2, top: "6"},
+		{src: "[7 0 verify] contract call", code: txvm.CodeVerifyFail, pc: 2, opcode: op.Verify, stackLen: 1, top: "7"},
+		{src: "1", code: txvm.CodeResidue, pc: -1, stackLen: 1, top: "1"},
+		{prog: []byte{op.MinPushdata + 5}, code: txvm.CodeBadInstruction, pc: 0, opcode: op.MinPushdata + 5},
+	}
+	for _, c := range cases {
+		prog := c.prog
+		if c.src != "" {
+			var err error
+			prog, err = asm.Assemble(c.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		_, err := txvm.Validate(prog, 3, 1000)
+		f := txvm.Fault(err)
+		if f == nil {
+			t.Errorf("%q: got error %v, want a fault", c.src, err)
+			continue
+		}
+		if errors.Root(f.Err) != errors.Root(err) {
+			t.Errorf("%q: fault has error %v, want %v", c.src, f.Err, err)
+		}
+		if f.Code != c.code || f.PC != c.pc || f.Opcode != c.opcode || f.StackLen != c.stackLen {
+			t.Errorf("%q: got code %s pc %d opcode %d stack %d, want %s %d %d %d", c.src, f.Code, f.PC, f.Opcode, f.StackLen, c.code, c.pc, c.opcode, c.stackLen)
+		}
+		if c.stackLen > 0 && f.Stack[0] != c.top {
+			t.Errorf("%q: got top of stack %s, want %s", c.src, f.Stack[0], c.top)
+		}
+	}
+
+	_, err := txvm.Validate([]byte{op.Verify}, 3, 0)
+	if f := txvm.Fault(err); f == nil || f.Code != txvm.CodeRunlimit || f.Runlimit != 0 {
+		t.Errorf("got fault %+v, want runlimit failure", f)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	const sig = "'m' 'p' 's' 1 checksig drop "
+	cases := []struct {
+		src    string
+		limits txvm.Limits
+		err    error
+	}{
+		{"1 2 drop drop", txvm.Limits{StackDepth: 2}, nil},
+		{"1 2 3 drop drop drop", txvm.Limits{StackDepth: 2}, txvm.ErrStackDepth},
+		{"0 tuple 1 tuple drop", txvm.Limits{TupleDepth: 2}, nil},
+		{"0 tuple 1 tuple 1 tuple drop", txvm.Limits{TupleDepth: 2}, txvm.ErrTupleDepth},
+		{"1 log", txvm.Limits{LogSize: 38}, nil},
+		{"1 log", txvm.Limits{LogSize: 37}, txvm.ErrLogSize},
+		{sig, txvm.Limits{SigChecks: 1}, nil},
+		{sig + sig, txvm.Limits{SigChecks: 1}, txvm.ErrSigChecks},
+	}
+	for _, c := range cases {
+		prog, err := asm.Assemble(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = txvm.Validate(prog, 3, 100000, txvm.EnableExtension, txvm.WithLimits(c.limits))
+		if errors.Root(err) != c.err {
+			t.Errorf("%q with %+v: got error %v, want %v", c.src, c.limits, err, c.err)
+		}
+		if _, err = txvm.Validate(prog, 3, 100000, txvm.EnableExtension); err != nil {
+			t.Errorf("%q without limits: got error %v", c.src, err)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	prog, err := asm.Assemble("1 log [0 verify] contract call")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r txvm.Recording
+	_, err = txvm.Validate(prog, 3, 100000, txvm.Record(&r))
+	if errors.Root(err) != txvm.ErrVerifyFail || errors.Root(r.Err) != txvm.ErrVerifyFail {
+		t.Fatalf("got error %v, recorded %v, want %v", err, r.Err, txvm.ErrVerifyFail)
+	}
+	if len(r.Log) != 1 || r.Log[0][2] != txvm.Int(1) {
+		t.Errorf("got log %v, want one entry logging 1", r.Log)
+	}
+	last := r.Steps[len(r.Steps)-1]
+	if last.Depth != 1 || last.Opcode != op.Verify || last.PC != 1 {
+		t.Errorf("got last step %+v, want verify at depth 1, pc 1", last)
+	}
+	if r.Fault == nil || r.Fault.Code != txvm.CodeVerifyFail || !bytes.Equal(r.Fault.Seed, last.Seed) {
+		t.Errorf("got fault %+v, want verify failure in contract %x", r.Fault, last.Seed)
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	cases := []struct {
+		src     string
+		version int64
+		want    txvm.Int
+		err     error
+	}{
+		{"1 log 2 log loglen", 4, 2, nil},
+		{"inputcount", 4, 0, nil},
+		{"outputcount", 4, 0, nil},
+		{"7 8 stacklen", 4, 2, nil},
+		{"7 put 8 put 9 arglen", 4, 2, nil},
+		{"7 put 8 put 1 peekarg", 4, 7, nil},
+		{"7 put 1 peekarg", 4, 0, txvm.ErrStackRange},
+		{"7 put -1 peekarg", 4, 0, txvm.ErrStackRange},
+		{"loglen", 3, 0, txvm.ErrExt},
+		{"99 ext", 4, 0, txvm.ErrExt},
+	}
+	for _, c := range cases {
+		prog, err := asm.Assemble(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got txvm.Data
+		_, err = txvm.Validate(prog, c.version, 10000, txvm.AfterStep(func(vm *txvm.VM) {
+			if vm.StackLen() > 0 {
+				got = vm.StackItem(vm.StackLen() - 1).(txvm.Tuple)[1]
+			}
+		}))
+		if c.err != nil {
+			if errors.Root(err) != c.err {
+				t.Errorf("%q version %d: got error %v, want %v", c.src, c.version, err, c.err)
+			}
+			continue
+		}
+		if errors.Root(err) != txvm.ErrResidue {
+			t.Errorf("%q version %d: got error %v, want residue", c.src, c.version, err)
+		}
+		if got != c.want {
+			t.Errorf("%q version %d: got %v, want %d", c.src, c.version, got, c.want)
+		}
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	concat := txvm.PluginOp{
+		Selector: txvm.MinPluginSelector,
+		Name:     "concat3",
+		In:       3,
+		Cost:     10,
+		Func: func(args []txvm.Data) ([]txvm.Data, error) {
+			var b []byte
+			for _, a := range args {
+				s, ok := a.(txvm.Bytes)
+				if !ok {
+					return nil, fmt.Errorf("%s is not a string", a)
+				}
+				b = append(b, s...)
+			}
+			return []txvm.Data{txvm.Bytes(b)}, nil
+		},
+	}
+	p, err := txvm.NewPlugins(concat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range [][]txvm.PluginOp{
+		{{Selector: txvm.ExtBigAdd, Name: "add", Func: concat.Func}},
+		{{Selector: txvm.MaxPluginSelector + 1, Name: "big", Func: concat.Func}},
+		{{Selector: txvm.MinPluginSelector, Name: "nofunc"}},
+		{concat, concat},
+	} {
+		if _, err := txvm.NewPlugins(bad...); err == nil {
+			t.Errorf("NewPlugins(%v) succeeded", bad)
+		}
+	}
+	if p.Hash() == (*txvm.Plugins)(nil).Hash() {
+		t.Error("plugins hash like no plugins")
+	}
+	renamed := concat
+	renamed.Name = "concat3v2"
+	p2, err := txvm.NewPlugins(renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Hash() == p2.Hash() {
+		t.Error("renamed plugin has the same hash")
+	}
+
+	cases := []struct {
+		src     string
+		plugins *txvm.Plugins
+		err     error
+	}{
+		{"'a' 'b' 'c' 65536 ext 'abc' eq verify", p, nil},
+		{"'a' 'b' 'c' 65536 ext 'abc' eq verify", nil, txvm.ErrExt},
+		{"'a' 'b' 3 65536 ext drop", p, txvm.ErrPlugin},
+		{"'a' 'b' 65536 ext drop", p, txvm.ErrUnderflow},
+		{"'a' 'b' 'c' 65537 ext drop", p, txvm.ErrExt},
+	}
+	for _, c := range cases {
+		prog, err := asm.Assemble(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = txvm.Validate(prog, 4, 10000, txvm.WithPlugins(c.plugins))
+		if errors.Root(err) != c.err {
+			t.Errorf("%q: got error %v, want %v", c.src, err, c.err)
+		}
+		if c.err == txvm.ErrPlugin {
+			if f := txvm.Fault(err); f == nil || f.Code != txvm.CodePlugin {
+				t.Errorf("%q: got fault %+v, want code %s", c.src, f, txvm.CodePlugin)
+			}
+		}
+	}
+}
+
+func TestProgramCache(t *testing.T) {
+	srcs := []string{
+		txvmtest.SimplePayment,
+		txvmtest.SplitPayment,
+		txvmtest.MergePayment,
+		txvmtest.Issuance,
+		"[1 2 add 3 eq verify 'x' log] contract call [1 2 add 3 eq verify 'x' log] contract call 'id' 10 nonce finalize",
+	}
+	c := txvm.NewProgramCache(1 << 20)
+	for pass := 0; pass < 2; pass++ {
+		for _, src := range srcs {
+			prog, err := asm.Assemble(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var left, cachedLeft int64
+			vm, err := txvm.Validate(prog, 3, 100000, txvm.GetRunlimit(&left))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cached, err := txvm.Validate(prog, 3, 100000, txvm.WithProgramCache(c), txvm.GetRunlimit(&cachedLeft))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cached.TxID != vm.TxID || cachedLeft != left || len(cached.Log) != len(vm.Log) {
+				t.Errorf("pass %d, %.40q: with cache got txid %x, runlimit %d, %d log entries; want %x, %d, %d",
+					pass, src, cached.TxID, cachedLeft, len(cached.Log), vm.TxID, left, len(vm.Log))
+			}
+		}
+	}
+	st := c.Stats()
+	if st.Misses == 0 || st.Hits <= st.Misses || st.Programs != int(st.Misses) || st.Evictions != 0 {
+		t.Errorf("got stats %+v, want more hits than misses, one program per miss, no evictions", st)
+	}
+
+	// A cache too small for two programs keeps only the latest.
+	prog, err := asm.Assemble("[1 drop] contract call [2 drop] contract call [1 drop] contract call")
+	if err != nil {
+		t.Fatal(err)
+	}
+	small := txvm.NewProgramCache(300)
+	if _, err := txvm.Validate(prog, 3, 100000, txvm.WithProgramCache(small)); err != nil {
+		t.Fatal(err)
+	}
+	if st := small.Stats(); st.Misses != 3 || st.Evictions != 2 || st.Programs != 1 || st.Bytes > 300 {
+		t.Errorf("small cache: got stats %+v, want 3 misses, 2 evictions, 1 program", st)
+	}
+
+	// A program that does not decode is not cached, and fails as it
+	// would without the cache.
+	prog, err = asm.Assemble("x'0162' contract call")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, want := txvm.Validate(prog, 3, 100000)
+	_, err = txvm.Validate(prog, 3, 100000, txvm.WithProgramCache(small))
+	if want == nil || err == nil || err.Error() != want.Error() {
+		t.Errorf("bad program: got error %v, want %v", err, want)
+	}
+	if st := small.Stats(); st.Uncached != 1 {
+		t.Errorf("bad program: got stats %+v, want 1 uncached", st)
+	}
+}
+
+func TestStrictEncoding(t *testing.T) {
+	cases := []struct {
+		prog []byte
+		err  error
+	}{
+		{[]byte{op.MinSmallInt + 5, op.Drop}, nil},
+		{[]byte{op.MinPushdata + 1, 100, op.Int, op.Drop}, nil},
+		{[]byte{op.MinPushdata + 2, 0x80, 0x01, op.Int, op.Drop}, nil},
+		{[]byte{op.MinSmallInt + 1, 0x80 | op.Drop, 0}, txvm.ErrEncoding},
+		{[]byte{0x80 | op.MinPushdata, 0, op.Drop}, txvm.ErrEncoding},
+		{[]byte{op.MinPushdata + 2, 0x80 | 100, 0, op.Int, op.Drop}, txvm.ErrEncoding},
+		{[]byte{op.MinPushdata + 2, 100, 7, op.Int, op.Drop}, txvm.ErrEncoding},
+		{[]byte{op.MinPushdata + 1, 5, op.Int, op.Drop}, txvm.ErrEncoding},
+
+		// An int applied to a computed string, or to pushdata in
+		// another program, is not a redundant encoding.
+		{[]byte{op.MinPushdata + 1, 5, op.MinSmallInt + 0, op.Drop, op.Int, op.Drop}, nil},
+		{[]byte{op.MinPushdata + 3, op.MinPushdata + 1, 5, op.Put, op.Contract, op.Call, op.Get, op.Int, op.Drop}, nil},
+	}
+	for _, c := range cases {
+		if _, err := txvm.Validate(c.prog, 3, 10000); errors.Root(err) != nil && c.err == nil {
+			t.Errorf("%x without StrictEncoding: got error %v", c.prog, err)
+		}
+		_, err := txvm.Validate(c.prog, 3, 10000, txvm.StrictEncoding)
+		if errors.Root(err) != c.err {
+			t.Errorf("%x: got error %v, want %v", c.prog, err, c.err)
+		}
+	}
+
+	// The assembler's output is canonical.
+	for _, src := range []string{txvmtest.SimplePayment, txvmtest.MergePayment, txvmtest.Issuance, "100 -7 drop drop"} {
+		prog, err := asm.Assemble(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := txvm.Validate(prog, 3, 100000, txvm.StrictEncoding, txvm.StopAfterFinalize); err != nil {
+			t.Errorf("%.40q: %v", src, err)
+		}
+	}
+}
+
+func TestCheckCommitment(t *testing.T) {
+	var r ecmath.Scalar
+	r.SetInt64(12345)
+	var v ecmath.Scalar
+	v.SetInt64(100)
+	c := pedersen.Commit(&v, &r).Encode()
+	v.Neg(&v)
+	cneg := pedersen.Commit(&v, &r).Encode()
+
+	cases := []struct {
+		v    int64
+		r    []byte
+		c    []byte
+		want bool
+		err  error
+	}{
+		{100, r[:], c[:], true, nil},
+		{101, r[:], c[:], false, nil},
+		{100, make([]byte, 32), c[:], false, nil},
+		{100, r[:], c[:31], false, txvm.ErrCommitment},
+		{100, bytes.Repeat([]byte{0xff}, 32), c[:], false, txvm.ErrCommitment},
+		{-100, r[:], cneg[:], true, nil},
+		{-100, r[:], c[:], false, nil},
+		{100, r[:], cneg[:], false, nil},
+	}
+	for i, tc := range cases {
+		src := fmt.Sprintf("%d x'%x' x'%x' checkcommitment", tc.v, tc.r, tc.c)
+		prog, err := asm.Assemble(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got txvm.Data
+		_, err = txvm.Validate(prog, 4, 10000, txvm.AfterStep(func(vm *txvm.VM) {
+			if vm.StackLen() > 0 {
+				got = vm.StackItem(vm.StackLen() - 1).(txvm.Tuple)[1]
+			}
+		}))
+		if tc.err != nil {
+			if errors.Root(err) != tc.err {
+				t.Errorf("case %d: got error %v, want %v", i, err, tc.err)
+			}
+			continue
+		}
+		if errors.Root(err) != txvm.ErrResidue {
+			t.Errorf("case %d: got error %v, want residue", i, err)
+		}
+		want := txvm.Int(0)
+		if tc.want {
+			want = 1
+		}
+		if got != want {
+			t.Errorf("case %d: got %v, want %d", i, got, want)
+		}
+	}
+}
+
+func TestSecp256k1(t *testing.T) {
+	priv, err := secp256k1.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := bytes.Repeat([]byte{7}, 32)
+	sig, err := secp256k1.Sign(nil, priv, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := priv.Compressed()
+	bad := append([]byte{}, sig...)
+	bad[0] ^= 1
+
+	cases := []struct {
+		src     string
+		version int64
+		want    txvm.Data
+		err     error
+	}{
+		{fmt.Sprintf("x'%x' x'%x' x'%x' 1 checksig", msg, pub, sig[:64]), 4, txvm.Int(1), nil},
+		{fmt.Sprintf("x'%x' x'%x' x'%x' 1 checksig", msg, priv.Uncompressed(), sig[:64]), 4, txvm.Int(1), nil},
+		{fmt.Sprintf("x'%x' x'%x' x'%x' 1 checksig", msg, pub, bad[:64]), 4, nil, txvm.ErrSignature},
+		{fmt.Sprintf("x'%x' x'%x' x'%x' 1 checksig", msg, pub[1:], sig[:64]), 4, nil, txvm.ErrPubSize},
+		{fmt.Sprintf("x'%x' x'%x' x'%x' 1 checksig", msg, pub, sig), 4, nil, txvm.ErrSigSize},
+		{fmt.Sprintf("x'%x' x'%x' x'%x' 1 checksig", msg, pub, sig[:64]), 3, nil, txvm.ErrExt},
+		{fmt.Sprintf("x'%x' x'%x' recoversecp256k1", msg, sig), 4, txvm.Bytes(pub), nil},
+		{fmt.Sprintf("x'%x' x'%x' recoversecp256k1", msg, sig[:64]), 4, nil, txvm.ErrSigSize},
+	}
+	for i, c := range cases {
+		prog, err := asm.Assemble(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got txvm.Data
+		_, err = txvm.Validate(prog, c.version, 200000, txvm.AfterStep(func(vm *txvm.VM) {
+			if vm.StackLen() > 0 {
+				got = vm.StackItem(vm.StackLen() - 1).(txvm.Tuple)[1]
+			}
+		}))
+		if c.err != nil {
+			if errors.Root(err) != c.err {
+				t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+			}
+			continue
+		}
+		if errors.Root(err) != txvm.ErrResidue {
+			t.Errorf("case %d: got error %v, want residue", i, err)
+		}
+		if !bytes.Equal(txvm.Encode(got), txvm.Encode(c.want)) {
+			t.Errorf("case %d: got %v, want %v", i, got, c.want)
+		}
+	}
+}
+
+func TestBLS(t *testing.T) {
+	var (
+		privs []*bls.PrivateKey
+		pubs  []string
+		sigs  []*bls.Signature
+	)
+	msg := []byte("checkpoint")
+	for i := 0; i < 2; i++ {
+		priv, err := bls.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs = append(privs, priv)
+		pubs = append(pubs, fmt.Sprintf("x'%x'", priv.Public().Bytes()))
+		sigs = append(sigs, priv.Sign(msg))
+	}
+	sig := sigs[0].Bytes()
+	agg := bls.AggregateSignatures(sigs...).Bytes()
+	keys := fmt.Sprintf("{%s, %s}", pubs[0], pubs[1])
+
+	cases := []struct {
+		src string
+		err error
+	}{
+		{fmt.Sprintf("x'%x' %s x'%x' 2 checksig verify", msg, pubs[0], sig), nil},
+		{fmt.Sprintf("x'%x' %s x'%x' 2 checksig verify", msg, pubs[1], sig), txvm.ErrSignature},
+		{fmt.Sprintf("x'%x' %s x'%x' 2 checksig verify", msg, pubs[0], sig[1:]), txvm.ErrSigSize},
+		{fmt.Sprintf("x'%x' %s x'%x' checkblsagg verify", msg, keys, agg), nil},
+		{fmt.Sprintf("x'%x' {%s} x'%x' checkblsagg verify", msg, pubs[0], agg), txvm.ErrSignature},
+		{fmt.Sprintf("x'%x' %s '' checkblsagg not verify", msg, keys), nil},
+		{fmt.Sprintf("x'%x' %s x'%x' 2 checksig verify x'%x' %s x'%x' checkblsagg verify", msg, pubs[0], sig, msg, keys, agg), nil},
+	}
+	for i, c := range cases {
+		prog, err := asm.Assemble(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = txvm.Validate(prog, 4, 10000000)
+		if errors.Root(err) != c.err {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+		}
+	}
+}
+
+func TestGroth16(t *testing.T) {
+	// Simulate a proof for input 5 with the verifying key's trapdoor:
+	// with every secret 1 except δ = 2 and the IC scalars 1, a valid
+	// proof has a·b = 1 + (1 + 5) + 2c.
+	g1, g2 := bls.G1Generator(), bls.G2Generator()
+	two := big.NewInt(2)
+	vk := &groth16.VerifyingKey{
+		Alpha: g1, Beta: g2, Gamma: g2, Delta: g2.ScalarMult(two),
+		IC: []*bls.G1Point{g1, g1},
+	}
+	// a = 9, b = 1: c = (9 - 7)/2 = 1.
+	proof := &groth16.Proof{A: g1.ScalarMult(big.NewInt(9)), B: g2, C: g1}
+	input := func(n int64) string {
+		return fmt.Sprintf("x'%x'", big.NewInt(n).FillBytes(make([]byte, 32)))
+	}
+
+	cases := []struct {
+		src  string
+		want txvm.Data
+		err  error
+	}{
+		{fmt.Sprintf("x'%x' x'%x' {%s} checkgroth16", vk.Bytes(), proof.Bytes(), input(5)), txvm.Int(1), nil},
+		{fmt.Sprintf("x'%x' x'%x' {%s} checkgroth16", vk.Bytes(), proof.Bytes(), input(6)), txvm.Int(0), nil},
+		{fmt.Sprintf("x'%x' x'%x' {} checkgroth16", vk.Bytes(), proof.Bytes()), nil, txvm.ErrGroth16},
+		{fmt.Sprintf("x'%x' x'%x' {%s} checkgroth16", vk.Bytes(), proof.Bytes()[1:], input(5)), nil, txvm.ErrGroth16},
+		{fmt.Sprintf("x'%x' x'%x' {%s} checkgroth16", vk.Bytes(), proof.Bytes(), input(5)), nil, txvm.ErrRunlimit},
+
+		// The key is paid for before it is decoded.
+		{fmt.Sprintf("x'%x' x'%x' {%s} checkgroth16", append(vk.Bytes(), make([]byte, 200*bls.G1Size)...), proof.Bytes(), input(5)), nil, txvm.ErrRunlimit},
+	}
+	for i, c := range cases {
+		prog, err := asm.Assemble(c.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runlimit := int64(10000000)
+		if i == 4 {
+			runlimit = 1000000
+		}
+		var got txvm.Data
+		_, err = txvm.Validate(prog, 4, runlimit, txvm.AfterStep(func(vm *txvm.VM) {
+			if vm.StackLen() > 0 {
+				got = vm.StackItem(vm.StackLen() - 1).(txvm.Tuple)[1]
+			}
+		}))
+		if c.err != nil {
+			if errors.Root(err) != c.err {
+				t.Errorf("case %d: got error %v, want %v", i, err, c.err)
+			}
+			continue
+		}
+		if errors.Root(err) != txvm.ErrResidue {
+			t.Errorf("case %d: got error %v, want residue", i, err)
+		}
+		if got != c.want {
+			t.Errorf("case %d: got %v, want %v", i, got, c.want)
+		}
+	}
+}
+
 func TestOptions(t *testing.T) {
 	const startLimit int64 = 1000
 
@@ -245,3 +816,60 @@ func compareItems(t *testing.T, stackItem, testItem string) {
 		t.Fatalf("Item on top of stack does not match expected item. Got %v, wanted %v", stackItem, testItem)
 	}
 }
+
+func TestNetwork(t *testing.T) {
+	prog, err := asm.Assemble("'id' 10 nonce finalize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	txid := func(version int64, network byte) [32]byte {
+		vm, err := txvm.Validate(prog, version, 10000, txvm.Network([32]byte{network}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return vm.TxID
+	}
+	if txid(3, 1) != txid(3, 2) {
+		t.Error("version 3 transaction ID depends on the network")
+	}
+	if txid(txvm.NetworkVersion, 1) == txid(txvm.NetworkVersion, 2) {
+		t.Error("network-bound transaction ID does not depend on the network")
+	}
+	if txid(txvm.NetworkVersion, 0) == txid(txvm.NetworkVersion-1, 0) {
+		t.Error("network-bound transaction ID is the old ID")
+	}
+}
+
+func TestEntryRunlimit(t *testing.T) {
+	prog, err := asm.Assemble("'x' log [ 'y' log 'z' log ] contract call [ 1 drop ] contract call 'id' 10 nonce finalize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const runlimit = 10000
+	vm, err := txvm.Validate(prog, 3, runlimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// x, y, z, nonce, timerange, finalize
+	if len(vm.EntryRunlimit) != 6 {
+		t.Fatalf("got %d entry runlimits, want 6", len(vm.EntryRunlimit))
+	}
+	var sum int64
+	for i, n := range vm.EntryRunlimit {
+		switch i {
+		case 0, 1:
+			if n <= 0 {
+				t.Errorf("entry %d has runlimit %d, want positive", i, n)
+			}
+		default:
+			if n != 0 {
+				t.Errorf("entry %d has runlimit %d, want 0", i, n)
+			}
+		}
+		sum += n
+	}
+	// The second contract logs nothing, and its cost is not attributed.
+	if used := runlimit - vm.Runlimit(); sum >= used {
+		t.Errorf("entry runlimits sum to %d, want less than %d used", sum, used)
+	}
+}
@@ -0,0 +1,95 @@
+package validation
+
+import (
+	"sync"
+
+	"i10r.io/errors"
+	"i10r.io/protocol/bc"
+)
+
+// A CommitmentCheck validates the value of a named commitment in the
+// header of block b.
+type CommitmentCheck func(b *bc.UnsignedBlock, value []byte) error
+
+var (
+	commitmentsMu    sync.RWMutex
+	commitmentChecks = make(map[string]CommitmentCheck)
+)
+
+// RegisterCommitment makes Block and BlockOnly check commitments with
+// the given name using check. Commitments with no registered check
+// are ignored, as are the contents of commitments areas of unknown
+// versions. It panics if name is already registered.
+func RegisterCommitment(name string, check CommitmentCheck) {
+	commitmentsMu.Lock()
+	defer commitmentsMu.Unlock()
+	if _, ok := commitmentChecks[name]; ok {
+		panic("validation: commitment " + name + " registered twice")
+	}
+	commitmentChecks[name] = check
+}
+
+func blockCommitments(b *bc.UnsignedBlock) error {
+	version, cs, err := b.Commitments()
+	if err != nil {
+		return err
+	}
+	if version != bc.CommitmentsAreaVersion {
+		return nil
+	}
+	commitmentsMu.RLock()
+	defer commitmentsMu.RUnlock()
+	for _, c := range cs {
+		check, ok := commitmentChecks[c.Name]
+		if !ok {
+			continue
+		}
+		err := check(b, c.Value)
+		if err != nil {
+			return errors.Wrapf(err, "commitment %q", c.Name)
+		}
+	}
+	return nil
+}
+
+// A CommitmentSigCheck validates the value of a named commitment in the
+// header of block b against b's signatures, which BlockSig has found
+// to satisfy predicate.
+type CommitmentSigCheck func(b *bc.Block, predicate *bc.Predicate, value []byte) error
+
+var commitmentSigChecks = make(map[string]CommitmentSigCheck)
+
+// RegisterCommitmentSig makes BlockSig check commitments with the
+// given name using check, once the signatures are verified. It panics
+// if name is already registered.
+func RegisterCommitmentSig(name string, check CommitmentSigCheck) {
+	commitmentsMu.Lock()
+	defer commitmentsMu.Unlock()
+	if _, ok := commitmentSigChecks[name]; ok {
+		panic("validation: commitment " + name + " registered twice")
+	}
+	commitmentSigChecks[name] = check
+}
+
+func blockCommitmentSigs(b *bc.Block, predicate *bc.Predicate) error {
+	version, cs, err := b.Commitments()
+	if err != nil {
+		return err
+	}
+	if version != bc.CommitmentsAreaVersion {
+		return nil
+	}
+	commitmentsMu.RLock()
+	defer commitmentsMu.RUnlock()
+	for _, c := range cs {
+		check, ok := commitmentSigChecks[c.Name]
+		if !ok {
+			continue
+		}
+		err := check(b, predicate, c.Value)
+		if err != nil {
+			return errors.Wrapf(err, "commitment %q", c.Name)
+		}
+	}
+	return nil
+}
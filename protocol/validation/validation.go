@@ -5,9 +5,12 @@ Chain Protocol spec.
 package validation
 
 import (
+	"sync"
+
 	"i10r.io/crypto/ed25519"
 	"i10r.io/errors"
 	"i10r.io/protocol/bc"
+	"i10r.io/protocol/txvm"
 )
 
 var (
@@ -25,28 +28,27 @@ var (
 	errExtraFields           = errors.New("unknown field(s) in blockheader")
 )
 
-// BlockSig checks the predicate against b.
+// BlockSig checks the predicate against b. A version 1 predicate
+// requires exactly a quorum of signatures; a weighted predicate (see
+// bc.WeightedPredicateVersion) requires signatures whose weights
+// total at least its threshold. It then runs the checks registered
+// with RegisterCommitmentSig.
 func BlockSig(b *bc.Block, predicate *bc.Predicate) error {
-	if predicate.Version != 1 {
-		return errors.WithDetailf(errBadPredicate, "predicate version %d", predicate.Version)
-	}
-	if predicate.Quorum < 0 {
-		return errors.WithDetailf(errBadPredicate, "predicate quorum %d", predicate.Quorum)
-	}
-	if int(predicate.Quorum) > len(predicate.Pubkeys) {
-		return errors.WithDetailf(errBadPredicate, "predicate quorum %d, pubkeys %d", predicate.Quorum, len(predicate.Pubkeys))
+	signers, threshold, err := predicate.Signers()
+	if err != nil {
+		return errors.Sub(errBadPredicate, err)
 	}
-	if len(b.Arguments) != len(predicate.Pubkeys) {
-		return errors.WithDetailf(errBadArguments, "pubkeys %d, signatures %d", len(predicate.Pubkeys), len(b.Arguments))
+	if len(b.Arguments) != len(signers) {
+		return errors.WithDetailf(errBadArguments, "pubkeys %d, signatures %d", len(signers), len(b.Arguments))
 	}
 
 	var (
-		sigCount int32
-		hash     = b.Hash()
+		weight int64
+		hash   = b.Hash()
 	)
 
 	for i := 0; i < len(b.Arguments); i++ {
-		pk := predicate.Pubkeys[i]
+		pk := signers[i].Pubkey
 		if len(pk) != ed25519.PublicKeySize {
 			return errors.WithDetailf(errBadPredicate, "public key length %d", len(pk))
 		}
@@ -64,20 +66,49 @@ func BlockSig(b *bc.Block, predicate *bc.Predicate) error {
 			return errors.WithDetailf(errBadArguments, "invalid signature length %d", len(sig))
 		}
 
-		if !ed25519.Verify(pk, hash.Bytes(), sig) {
+		if vk := signerKey(pk); vk == nil || !vk.Verify(hash.Bytes(), sig) {
 			return errors.WithDetailf(errBadArguments, "message %x, public key %x, signature %x", hash.Bytes(), pk, sig)
 		}
 
-		sigCount++
+		weight += signers[i].Weight
 	}
 
-	if sigCount != predicate.Quorum {
+	if weight < threshold || (predicate.Version == 1 && weight != threshold) {
 		return errors.WithDetail(errBadArguments, "insufficient signatures for quorum")
 	}
 
+	if b.Version >= bc.CommitmentsVersion {
+		return blockCommitmentSigs(b, predicate)
+	}
+
 	return nil
 }
 
+// signerKeys holds the expanded keys of block signers, which verify a
+// signature in every block, so that BlockSig verifies with a comb
+// table (see ed25519.VerifierKey). It is cleared when it reaches
+// maxSignerKeys, which only a chain whose signers change often does.
+var signerKeys struct {
+	sync.Mutex
+	m map[string]*ed25519.VerifierKey // nil for a key that does not decode
+}
+
+const maxSignerKeys = 256
+
+func signerKey(pk ed25519.PublicKey) *ed25519.VerifierKey {
+	signerKeys.Lock()
+	defer signerKeys.Unlock()
+	vk, ok := signerKeys.m[string(pk)]
+	if !ok {
+		if len(signerKeys.m) >= maxSignerKeys || signerKeys.m == nil {
+			signerKeys.m = make(map[string]*ed25519.VerifierKey)
+		}
+		vk, _ = ed25519.NewVerifierKey(pk)
+		signerKeys.m[string(pk)] = vk
+	}
+	return vk
+}
+
 // Block validates a block and the transactions within.
 // It does not check the predicate; for that, see ValidateBlockSig.
 func Block(b *bc.UnsignedBlock, prev *bc.BlockHeader) error {
@@ -105,6 +136,9 @@ func BlockOnly(b *bc.UnsignedBlock) error {
 		if b.Version == 3 && tx.Version != 3 {
 			return errors.WithDetailf(errTxVersion, "block version %d, transaction version %d", b.Version, tx.Version)
 		}
+		if (b.Version >= bc.NetworkVersion) != (tx.Version >= txvm.NetworkVersion) {
+			return errors.WithDetailf(errTxVersion, "block version %d, transaction version %d", b.Version, tx.Version)
+		}
 
 		runlimit -= tx.Runlimit
 		if runlimit < 0 {
@@ -120,6 +154,14 @@ func BlockOnly(b *bc.UnsignedBlock) error {
 	if b.Version == 3 && len(b.ExtraFields) > 0 {
 		return errExtraFields
 	}
+	if b.Version >= bc.NetworkVersion {
+		if _, err := b.Network(); err != nil {
+			return err
+		}
+	}
+	if b.Version >= bc.CommitmentsVersion {
+		return blockCommitments(b)
+	}
 
 	return nil
 }
@@ -142,5 +184,14 @@ func BlockPrev(b *bc.UnsignedBlock, prev *bc.BlockHeader) error {
 	if b.RefsCount > prev.RefsCount+1 {
 		return errors.WithDetailf(errRefsCount, "previous block prevblocks %d, current block %d", prev.RefsCount, b.RefsCount)
 	}
+	if prev.Version >= bc.NetworkVersion {
+		network, err := b.Network()
+		if err != nil {
+			return err
+		}
+		if prevNetwork, _ := prev.Network(); network != prevNetwork {
+			return errors.WithDetailf(bc.ErrNetwork, "previous block network %x, current block %x", prevNetwork.Bytes(), network.Bytes())
+		}
+	}
 	return nil
 }
@@ -76,12 +76,20 @@ func TestBlockSig(t *testing.T) {
 	badPubkey := mustDecodeHex("1111111111111111111111111111111111111111111111111111111111111111")
 	badSig := mustDecodeHex("22222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222222")
 
+	// A pubkey and its valid signature of the test block.
+	goodPubkey := mustDecodeHex("af8c8a878c85b47903bd90e7cdbb239847b7712748064fb16ecbf480c89d3606")
+	goodSig := mustDecodeHex("59317b3f59a91613d801fbfada0abaf635900af8c84d66f7ad9932baa2a14bd5cad4649b993fced3d162a66ad4c688d8d5f4c943efa3da25383d168210c8980e")
+	zeroPubkey := make([]byte, ed25519.PublicKeySize)
+
 	cases := []struct {
 		pred    *bc.Predicate
 		args    []interface{}
 		wantErr error
 	}{{
-		pred:    &bc.Predicate{Version: 2}, // bad version
+		pred:    &bc.Predicate{Version: 3}, // bad version
+		wantErr: errBadPredicate,
+	}, {
+		pred:    &bc.Predicate{Version: bc.WeightedPredicateVersion}, // no threshold
 		wantErr: errBadPredicate,
 	}, {
 		pred:    &bc.Predicate{Version: 1, Quorum: -1}, // bad quorum
@@ -121,6 +129,26 @@ func TestBlockSig(t *testing.T) {
 		pred:    &bc.Predicate{Version: 1, Quorum: 1, Pubkeys: [][]byte{mustDecodeHex("af8c8a878c85b47903bd90e7cdbb239847b7712748064fb16ecbf480c89d3606")}},
 		args:    []interface{}{mustDecodeHex("59317b3f59a91613d801fbfada0abaf635900af8c84d66f7ad9932baa2a14bd5cad4649b993fced3d162a66ad4c688d8d5f4c943efa3da25383d168210c8980e")},
 		wantErr: nil,
+	}, {
+		pred:    bc.NewWeightedPredicate(3, []bc.Signer{{Pubkey: goodPubkey, Weight: 2}}), // threshold > total weight
+		args:    []interface{}{goodSig},
+		wantErr: errBadPredicate,
+	}, {
+		pred:    bc.NewWeightedPredicate(1, []bc.Signer{{Pubkey: goodPubkey, Weight: 0}}), // zero weight
+		args:    []interface{}{goodSig},
+		wantErr: errBadPredicate,
+	}, {
+		pred:    bc.NewWeightedPredicate(3, []bc.Signer{{Pubkey: goodPubkey, Weight: 2}, {Pubkey: zeroPubkey, Weight: 1}}),
+		args:    []interface{}{goodSig, []byte{}},
+		wantErr: errBadArguments, // insufficient weight
+	}, {
+		pred:    bc.NewWeightedPredicate(2, []bc.Signer{{Pubkey: goodPubkey, Weight: 2}, {Pubkey: zeroPubkey, Weight: 1}}),
+		args:    []interface{}{goodSig, []byte{}},
+		wantErr: nil,
+	}, {
+		pred:    bc.NewWeightedPredicate(1, []bc.Signer{{Pubkey: goodPubkey, Weight: 2}, {Pubkey: zeroPubkey, Weight: 1}}),
+		args:    []interface{}{goodSig, []byte{}},
+		wantErr: nil, // weight beyond the threshold
 	}}
 
 	block := &bc.Block{
@@ -202,6 +230,82 @@ func TestBlockOnly(t *testing.T) {
 	}
 }
 
+func TestBlockCommitments(t *testing.T) {
+	errBad := errors.New("bad commitment")
+	RegisterCommitment("test", func(b *bc.UnsignedBlock, value []byte) error {
+		if len(value) != 1 || int(value[0]) != len(b.Transactions) {
+			return errBad
+		}
+		return nil
+	})
+
+	b1 := newInitialBlock(t)
+	cases := []struct {
+		f       func(b *bc.UnsignedBlock)
+		wantErr error
+	}{
+		{func(b *bc.UnsignedBlock) {}, nil},
+		{func(b *bc.UnsignedBlock) { b.SetCommitments([]bc.Commitment{{Name: "test", Value: []byte{0}}}) }, nil},
+		{func(b *bc.UnsignedBlock) { b.SetCommitments([]bc.Commitment{{Name: "test", Value: []byte{1}}}) }, errBad},
+		{func(b *bc.UnsignedBlock) { b.SetCommitments([]bc.Commitment{{Name: "unknown", Value: []byte{1}}}) }, nil},
+		{func(b *bc.UnsignedBlock) {
+			b.SetCommitments([]bc.Commitment{{Name: "test", Value: []byte{1}}})
+			b.ExtraFields[0].Tuple[0].Int++ // unknown area version
+		}, nil},
+		{func(b *bc.UnsignedBlock) {
+			b.ExtraFields = []*bc.DataItem{{Type: bc.DataType_INT}}
+		}, bc.ErrCommitments},
+	}
+	for i, c := range cases {
+		b := generate(t, b1)
+		b.Version = bc.CommitmentsVersion
+		c.f(b)
+		err := Block(b, b1.BlockHeader)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.wantErr)
+		}
+	}
+}
+
+func TestBlockCommitmentSigs(t *testing.T) {
+	errBad := errors.New("bad commitment")
+	RegisterCommitmentSig("testsig", func(b *bc.Block, pred *bc.Predicate, value []byte) error {
+		if len(value) != 1 || int(value[0]) >= len(pred.Pubkeys) {
+			return errBad
+		}
+		if sig := b.Arguments[value[0]].([]byte); len(sig) == 0 {
+			return errBad
+		}
+		return nil
+	})
+
+	// A quorum of 0 needs no signatures, so the registered check
+	// alone decides.
+	pred := &bc.Predicate{Version: 1, Quorum: 0, Pubkeys: [][]byte{make([]byte, ed25519.PublicKeySize)}}
+	b1 := newInitialBlock(t)
+	cases := []struct {
+		version uint64
+		value   []byte
+		wantErr error
+	}{
+		{bc.CommitmentsVersion, nil, nil},
+		{bc.CommitmentsVersion, []byte{0}, errBad},
+		{bc.CommitmentsVersion, []byte{1}, errBad},
+		{bc.CommitmentsVersion - 1, []byte{0}, nil}, // commitments unchecked
+	}
+	for i, c := range cases {
+		b := &bc.Block{UnsignedBlock: generate(t, b1), Arguments: []interface{}{[]byte{}}}
+		b.Version = c.version
+		if c.value != nil {
+			b.SetCommitments([]bc.Commitment{{Name: "testsig", Value: c.value}})
+		}
+		err := BlockSig(b, pred)
+		if errors.Root(err) != c.wantErr {
+			t.Errorf("case %d: got error %v, want %v", i, err, c.wantErr)
+		}
+	}
+}
+
 func TestBlockPrev(t *testing.T) {
 	prev := &bc.BlockHeader{
 		Version:       3,
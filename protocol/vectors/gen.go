@@ -0,0 +1,29 @@
+//go:build ignore
+// +build ignore
+
+// This runs at "go generate" time, producing testdata/vectors.json.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+
+	"i10r.io/protocol/vectors"
+)
+
+func main() {
+	v, err := vectors.Generate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = ioutil.WriteFile("testdata/vectors.json", append(b, '\n'), 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
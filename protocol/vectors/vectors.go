@@ -0,0 +1,492 @@
+// Package vectors defines golden test vectors for the
+// consensus-critical functions of the protocol: transaction IDs and
+// witness commitments, block header hashes, asset IDs, merkle roots,
+// and the results of running txvm programs.
+//
+// The vectors, in testdata/vectors.json, are for other
+// implementations to prove byte-level compatibility with this one:
+// an implementation that reproduces every output in the file from
+// its inputs agrees with this one on everything the vectors cover.
+// Byte strings are in hex. Generate produces the file ("go generate"
+// rewrites it) and Verify checks it against this implementation, as
+// the package's tests do, so a change to any of the functions it
+// covers shows up as a change to the vectors.
+package vectors
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"i10r.io/crypto/ed25519"
+	chainjson "i10r.io/encoding/json"
+	"i10r.io/errors"
+	"i10r.io/protocol"
+	"i10r.io/protocol/bc"
+	"i10r.io/protocol/merkle"
+	"i10r.io/protocol/state"
+	"i10r.io/protocol/txbuilder/standard"
+	"i10r.io/protocol/txgen"
+	"i10r.io/protocol/txvm"
+	"i10r.io/protocol/txvm/asm"
+)
+
+//go:generate go run gen.go
+
+// Version is the version of the vectors format. It changes only
+// when the format does, not when the vectors do.
+const Version = 1
+
+// Vectors is the content of testdata/vectors.json.
+type Vectors struct {
+	Version int `json:"version"`
+
+	// VM lists txvm programs written to exercise the instruction
+	// set, some of which fail.
+	VM []Run `json:"vm"`
+
+	// Transactions lists the transactions of the blocks in Headers.
+	Transactions []Run `json:"transactions"`
+
+	Headers          []Header          `json:"block_headers"`
+	AssetIDs         []AssetID         `json:"asset_ids"`
+	IssuanceAssetIDs []IssuanceAssetID `json:"issuance_asset_ids"`
+	MerkleRoots      []MerkleRoot      `json:"merkle_roots"`
+}
+
+// Run is a txvm program and the result of running it as a
+// transaction with the given version and runlimit. The result fields
+// other than Valid are set only for a valid program, and ID and
+// WitnessCommitment only for one that finalizes.
+type Run struct {
+	Name     string             `json:"name,omitempty"`
+	Source   string             `json:"source,omitempty"` // assembly, for reference
+	Program  chainjson.HexBytes `json:"program"`
+	Version  int64              `json:"version"`
+	Runlimit int64              `json:"runlimit"`
+
+	Valid             bool                 `json:"valid"`
+	Finalized         bool                 `json:"finalized,omitempty"`
+	ID                *bc.Hash             `json:"id,omitempty"`
+	WitnessCommitment chainjson.HexBytes   `json:"witness_commitment,omitempty"`
+	RunlimitUsed      int64                `json:"runlimit_used,omitempty"`
+	Log               []chainjson.HexBytes `json:"log,omitempty"` // the txvm encoding of each entry
+}
+
+// Header is a block header and its hash. Transactions indexes the
+// block's transactions in Vectors.Transactions, whose witness
+// commitments make up the merkle tree with root TransactionsRoot.
+type Header struct {
+	Version          uint64       `json:"version"`
+	Height           uint64       `json:"height"`
+	PreviousBlockID  bc.Hash      `json:"previous_block_id"`
+	TimestampMS      uint64       `json:"timestamp_ms"`
+	RefsCount        int64        `json:"refs_count"`
+	Runlimit         int64        `json:"runlimit"`
+	TransactionsRoot bc.Hash      `json:"transactions_root"`
+	ContractsRoot    bc.Hash      `json:"contracts_root"`
+	NoncesRoot       bc.Hash      `json:"nonces_root"`
+	NextPredicate    Predicate    `json:"next_predicate"`
+	Commitments      []Commitment `json:"commitments,omitempty"`
+
+	Transactions []int   `json:"transactions"`
+	Hash         bc.Hash `json:"hash"`
+}
+
+// Predicate is a version 1 block predicate.
+type Predicate struct {
+	Quorum  int32                `json:"quorum"`
+	Pubkeys []chainjson.HexBytes `json:"pubkeys"`
+}
+
+// Commitment is an entry in a header's commitments area (see
+// bc.CommitmentsVersion).
+type Commitment struct {
+	Name  string             `json:"name"`
+	Value chainjson.HexBytes `json:"value"`
+}
+
+// AssetID is the ID of the asset with the given tag issued by the
+// contract with the given seed (see txvm.AssetID).
+type AssetID struct {
+	ContractSeed chainjson.HexBytes `json:"contract_seed"`
+	Tag          chainjson.HexBytes `json:"tag"`
+	AssetID      bc.Hash            `json:"asset_id"`
+}
+
+// IssuanceAssetID is the ID of the asset with the given tag issued
+// by the standard issuance contract of the given version with the
+// given keys (see standard.AssetID).
+type IssuanceAssetID struct {
+	Version int                  `json:"version"`
+	Quorum  int                  `json:"quorum"`
+	Pubkeys []chainjson.HexBytes `json:"pubkeys"`
+	Tag     chainjson.HexBytes   `json:"tag"`
+	AssetID bc.Hash              `json:"asset_id"`
+}
+
+// MerkleRoot is the root of the merkle tree of the given items (see
+// merkle.Root).
+type MerkleRoot struct {
+	Items []chainjson.HexBytes `json:"items"`
+	Root  bc.Hash              `json:"root"`
+}
+
+// vmCase is a program for Vectors.VM.
+type vmCase struct {
+	name     string
+	src      string
+	version  int64
+	runlimit int64
+}
+
+// base is the timestamp of the initial block of the generated chain.
+var base = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Generate returns the vectors. Its inputs are fixed, or chosen with
+// a fixed seed, so it always returns the same vectors from the same
+// implementation.
+func Generate() (*Vectors, error) {
+	r := rand.New(rand.NewSource(1))
+	pub, prv, err := ed25519.GenerateKey(r)
+	if err != nil {
+		return nil, err
+	}
+	v := &Vectors{Version: Version}
+
+	for _, c := range vmCases(pub, prv) {
+		prog, err := asm.Assemble(c.src)
+		if err != nil {
+			return nil, errors.Wrapf(err, "assembling %s", c.name)
+		}
+		run := newRun(prog, c.version, c.runlimit)
+		run.Name, run.Source = c.name, c.src
+		v.VM = append(v.VM, run)
+	}
+
+	err = v.generateChain(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, tag := range []string{"", "a", "gold", "a longer tag, of more than 32 bytes"} {
+		seed := make([]byte, 32)
+		r.Read(seed)
+		if i == 0 {
+			seed = make([]byte, 32)
+		}
+		id := txvm.AssetID(seed, []byte(tag))
+		v.AssetIDs = append(v.AssetIDs, AssetID{ContractSeed: seed, Tag: []byte(tag), AssetID: bc.NewHash(id)})
+	}
+
+	var pubs []ed25519.PublicKey
+	for i := 0; i < 3; i++ {
+		p, _, err := ed25519.GenerateKey(r)
+		if err != nil {
+			return nil, err
+		}
+		pubs = append(pubs, p)
+	}
+	issuances := []struct {
+		version, quorum, n int
+		tag                string
+	}{
+		{1, 1, 1, "asset"},
+		{2, 1, 1, "asset"},
+		{2, 2, 3, "asset"},
+		{2, 0, 0, ""},
+	}
+	for _, is := range issuances {
+		keys := pubs[:is.n]
+		id := standard.AssetID(is.version, is.quorum, keys, []byte(is.tag))
+		v.IssuanceAssetIDs = append(v.IssuanceAssetIDs, IssuanceAssetID{
+			Version: is.version,
+			Quorum:  is.quorum,
+			Pubkeys: hexKeys(keys),
+			Tag:     []byte(is.tag),
+			AssetID: bc.NewHash(id),
+		})
+	}
+
+	for _, n := range []int{0, 1, 2, 3, 4, 5, 7, 8, 9, 16, 17} {
+		var items [][]byte
+		for i := 0; i < n; i++ {
+			item := make([]byte, r.Intn(40))
+			r.Read(item)
+			items = append(items, item)
+		}
+		v.MerkleRoots = append(v.MerkleRoots, MerkleRoot{Items: hexItems(items), Root: bc.NewHash(merkle.Root(items))})
+	}
+	return v, nil
+}
+
+// generateChain adds to v the headers and transactions of a short
+// chain of random transactions, whose blocks pub signs.
+func (v *Vectors) generateChain(pub ed25519.PublicKey) error {
+	initial, err := protocol.NewInitialBlock([]ed25519.PublicKey{pub}, 1, base)
+	if err != nil {
+		return err
+	}
+	snapshot := state.Empty()
+	err = snapshot.ApplyBlock(initial.UnsignedBlock)
+	if err != nil {
+		return err
+	}
+	v.Headers = append(v.Headers, newHeader(initial.BlockHeader, nil))
+
+	g := txgen.New(1)
+	bb := protocol.NewBlockBuilder()
+	for i := 1; i <= 4; i++ {
+		if i == 3 {
+			// The rest have commitments.
+			bb.Version = bc.CommitmentsVersion
+			bb.RentLifetime = 24 * time.Hour
+		}
+		now := base.Add(time.Duration(i) * time.Minute)
+		txs, err := g.Txs(snapshot, now, 3)
+		if err != nil {
+			return errors.Wrapf(err, "generating transactions for block %d", i+1)
+		}
+		err = bb.Start(snapshot, bc.Millis(now))
+		if err != nil {
+			return err
+		}
+		var indexes []int
+		for _, tx := range txs {
+			err = bb.AddTx(bc.NewCommitmentsTx(tx))
+			if err != nil {
+				return errors.Wrapf(err, "adding transaction to block %d", i+1)
+			}
+			indexes = append(indexes, len(v.Transactions))
+			v.Transactions = append(v.Transactions, newRun(tx.Program, tx.Version, tx.Runlimit))
+		}
+		ub, next, err := bb.Build()
+		if err != nil {
+			return err
+		}
+		v.Headers = append(v.Headers, newHeader(ub.BlockHeader, indexes))
+		snapshot = next
+	}
+	return nil
+}
+
+// newRun runs prog and returns its result.
+func newRun(prog []byte, version, runlimit int64) Run {
+	run := Run{Program: prog, Version: version, Runlimit: runlimit}
+	vm, err := txvm.Validate(prog, version, runlimit)
+	if err != nil {
+		return run
+	}
+	run.Valid = true
+	run.Finalized = vm.Finalized
+	if vm.Finalized {
+		tx := &bc.Tx{RawTx: bc.RawTx{Program: prog, Version: version, Runlimit: runlimit}, ID: bc.NewHash(vm.TxID)}
+		run.ID = &tx.ID
+		var buf bytes.Buffer
+		tx.WriteWitnessCommitmentTo(&buf)
+		run.WitnessCommitment = buf.Bytes()
+	}
+	run.RunlimitUsed = runlimit - vm.Runlimit()
+	for _, entry := range vm.Log {
+		run.Log = append(run.Log, txvm.Encode(entry))
+	}
+	return run
+}
+
+func newHeader(h *bc.BlockHeader, txs []int) Header {
+	vh := Header{
+		Version:          h.Version,
+		Height:           h.Height,
+		TimestampMS:      h.TimestampMs,
+		RefsCount:        h.RefsCount,
+		Runlimit:         h.Runlimit,
+		TransactionsRoot: *h.TransactionsRoot,
+		ContractsRoot:    *h.ContractsRoot,
+		NoncesRoot:       *h.NoncesRoot,
+		NextPredicate:    Predicate{Quorum: h.NextPredicate.Quorum, Pubkeys: hexItems(h.NextPredicate.Pubkeys)},
+		Transactions:     txs,
+		Hash:             h.Hash(),
+	}
+	if h.PreviousBlockId != nil {
+		vh.PreviousBlockID = *h.PreviousBlockId
+	}
+	if vh.Transactions == nil {
+		vh.Transactions = []int{}
+	}
+	if _, cs, err := h.Commitments(); err == nil {
+		for _, c := range cs {
+			vh.Commitments = append(vh.Commitments, Commitment{Name: c.Name, Value: c.Value})
+		}
+	}
+	return vh
+}
+
+// blockHeader returns the bc.BlockHeader h describes.
+func (h *Header) blockHeader() *bc.BlockHeader {
+	prevID, txRoot, contractsRoot, noncesRoot := h.PreviousBlockID, h.TransactionsRoot, h.ContractsRoot, h.NoncesRoot
+	bh := &bc.BlockHeader{
+		Version:          h.Version,
+		Height:           h.Height,
+		TimestampMs:      h.TimestampMS,
+		RefsCount:        h.RefsCount,
+		Runlimit:         h.Runlimit,
+		TransactionsRoot: &txRoot,
+		ContractsRoot:    &contractsRoot,
+		NoncesRoot:       &noncesRoot,
+		NextPredicate:    &bc.Predicate{Version: 1, Quorum: h.NextPredicate.Quorum},
+	}
+	if !prevID.IsZero() {
+		bh.PreviousBlockId = &prevID
+	}
+	for _, pk := range h.NextPredicate.Pubkeys {
+		bh.NextPredicate.Pubkeys = append(bh.NextPredicate.Pubkeys, pk)
+	}
+	var cs []bc.Commitment
+	for _, c := range h.Commitments {
+		cs = append(cs, bc.Commitment{Name: c.Name, Value: c.Value})
+	}
+	bh.SetCommitments(cs)
+	return bh
+}
+
+// Verify recomputes each output in v from its inputs, returning an
+// error for each that differs.
+func (v *Vectors) Verify() []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	if v.Version != Version {
+		fail("vectors version %d, want %d", v.Version, Version)
+		return errs
+	}
+
+	checkRun := func(what string, want Run) {
+		got := newRun(want.Program, want.Version, want.Runlimit)
+		got.Name, got.Source = want.Name, want.Source
+		if !runsEqual(got, want) {
+			fail("%s: got result %+v, want %+v", what, got, want)
+		}
+	}
+	for _, run := range v.VM {
+		checkRun("vm "+run.Name, run)
+	}
+	for i, run := range v.Transactions {
+		checkRun(fmt.Sprintf("transaction %d", i), run)
+	}
+
+	for i, h := range v.Headers {
+		if got := h.blockHeader().Hash(); got != h.Hash {
+			fail("header %d: hash %x, want %x", i, got.Bytes(), h.Hash.Bytes())
+		}
+		var items [][]byte
+		for _, j := range h.Transactions {
+			if j < 0 || j >= len(v.Transactions) {
+				fail("header %d: transaction %d out of range", i, j)
+				continue
+			}
+			items = append(items, v.Transactions[j].WitnessCommitment)
+		}
+		if got := bc.NewHash(merkle.Root(items)); got != h.TransactionsRoot {
+			fail("header %d: transactions root %x, want %x", i, got.Bytes(), h.TransactionsRoot.Bytes())
+		}
+	}
+
+	for i, a := range v.AssetIDs {
+		if got := bc.NewHash(txvm.AssetID(a.ContractSeed, a.Tag)); got != a.AssetID {
+			fail("asset ID %d: got %x, want %x", i, got.Bytes(), a.AssetID.Bytes())
+		}
+	}
+	for i, a := range v.IssuanceAssetIDs {
+		var keys []ed25519.PublicKey
+		for _, pk := range a.Pubkeys {
+			keys = append(keys, ed25519.PublicKey(pk))
+		}
+		if got := bc.NewHash(standard.AssetID(a.Version, a.Quorum, keys, a.Tag)); got != a.AssetID {
+			fail("issuance asset ID %d: got %x, want %x", i, got.Bytes(), a.AssetID.Bytes())
+		}
+	}
+	for i, m := range v.MerkleRoots {
+		var items [][]byte
+		for _, item := range m.Items {
+			items = append(items, item)
+		}
+		if got := bc.NewHash(merkle.Root(items)); got != m.Root {
+			fail("merkle root %d: got %x, want %x", i, got.Bytes(), m.Root.Bytes())
+		}
+	}
+	return errs
+}
+
+func runsEqual(a, b Run) bool {
+	if a.Valid != b.Valid || a.Finalized != b.Finalized || a.RunlimitUsed != b.RunlimitUsed {
+		return false
+	}
+	if (a.ID == nil) != (b.ID == nil) || a.ID != nil && *a.ID != *b.ID {
+		return false
+	}
+	if !bytes.Equal(a.WitnessCommitment, b.WitnessCommitment) || len(a.Log) != len(b.Log) {
+		return false
+	}
+	for i := range a.Log {
+		if !bytes.Equal(a.Log[i], b.Log[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func hexKeys(keys []ed25519.PublicKey) []chainjson.HexBytes {
+	out := make([]chainjson.HexBytes, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, chainjson.HexBytes(k))
+	}
+	return out
+}
+
+func hexItems(items [][]byte) []chainjson.HexBytes {
+	out := make([]chainjson.HexBytes, 0, len(items))
+	for _, item := range items {
+		out = append(out, item)
+	}
+	return out
+}
+
+// vmCases returns the programs of Vectors.VM. Those that check
+// signatures use pub and prv.
+func vmCases(pub ed25519.PublicKey, prv ed25519.PrivateKey) []vmCase {
+	msg := []byte("message")
+	sig := ed25519.Sign(prv, msg)
+	blockID := make([]byte, 32)
+	nonce := fmt.Sprintf("[x'%x' %d nonce put] contract call get", blockID, bc.Millis(base.Add(time.Hour)))
+	return []vmCase{
+		{"empty", "", 3, 100},
+		{"arithmetic", "2 3 add 5 eq verify 7 2 mul 3 div 4 eq verify 9 2 mod 1 eq verify -3 neg 3 eq verify", 3, 1000},
+		{"comparison", "3 2 gt verify 2 3 lt verify 2 2 le verify 0 not verify", 3, 1000},
+		{"bitwise", "x'0c' x'0a' bitand x'08' eq verify x'0c' x'0a' bitor x'0e' eq verify x'0c' x'0a' bitxor x'06' eq verify x'0c' bitnot x'f3' eq verify", 3, 1000},
+		{"strings", "x'0102' x'03' cat len 3 eq verify 'hello' 1 3 slice 'el' eq verify", 3, 1000},
+		{"stack", "1 2 3 2 roll 1 eq verify drop drop 1 2 dup add 4 eq verify drop", 3, 1000},
+		{"tuple", "{1, 'two', x'03'} untuple 3 eq verify drop drop drop 1 2 2 tuple len 2 eq verify", 3, 1000},
+		{"encode", "{1, 'two', {x'03'}, -1} encode log", 3, 1000},
+		{"hashes", "'abc' sha256 log 'abc' sha3 log 'abc' 'f' vmhash log", 3, 1000},
+		{"checksig", fmt.Sprintf("x'%x' x'%x' x'%x' 0 checksig verify", msg, []byte(pub), sig), 3, 10000},
+		{"checksig empty", fmt.Sprintf("x'%x' x'%x' x'' 0 checksig not verify", msg, []byte(pub)), 3, 10000},
+		{"jump", "0 $loop 1 add dup 5 eq not jumpif:$loop 5 eq verify", 3, 1000},
+		{"log", "'hello' log {1, 2} log", 3, 1000},
+		{"nonce and finalize", nonce + " finalize", 3, 1000},
+		{"issue and retire", nonce + " splitzero 100 'tag' issue 40 split retire retire finalize", 3, 10000},
+		{"contract", "2 put [get 1 add log] contract call", 3, 1000},
+
+		{"residue", "1", 3, 1000},
+		{"underflow", "add", 3, 1000},
+		{"division by zero", "1 0 div", 3, 1000},
+		{"overflow", "9223372036854775807 1 add", 3, 1000},
+		{"type", "'a' 1 add", 3, 1000},
+		{"verify fails", "0 verify", 3, 1000},
+		{"bad signature", fmt.Sprintf("x'%x' x'%x' x'%x' 0 checksig", []byte("other"), []byte(pub), sig), 3, 10000},
+		{"runlimit", "0 $loop 1 add jump:$loop", 3, 200},
+		{"version", "", 2, 100},
+		{"finalize twice", nonce + " splitzero finalize finalize", 3, 1000},
+	}
+}
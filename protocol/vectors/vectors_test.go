@@ -0,0 +1,52 @@
+package vectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+func TestVectors(t *testing.T) {
+	b, err := ioutil.ReadFile("testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v Vectors
+	err = json.Unmarshal(b, &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range v.Verify() {
+		t.Error(err)
+	}
+
+	// The file is what Generate produces, so that a change to the
+	// generated vectors shows up in review as a change to the file.
+	gen, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.MarshalIndent(gen, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(append(got, '\n'), b) {
+		t.Error("Generate differs from testdata/vectors.json; run go generate")
+	}
+}
+
+func TestVerifyMismatch(t *testing.T) {
+	v, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.VM[1].RunlimitUsed++
+	v.Transactions[0].Log[0][0] ^= 1
+	v.Headers[1].TimestampMS++
+	v.AssetIDs[0].Tag = []byte("x")
+	v.MerkleRoots[2].Items[0] = append(v.MerkleRoots[2].Items[0], 0)
+	if errs := v.Verify(); len(errs) != 5 {
+		t.Errorf("Verify of altered vectors: got %d errors %v, want 5", len(errs), errs)
+	}
+}